@@ -0,0 +1,110 @@
+package api
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"phantom/backup"
+	"time"
+)
+
+var errBackupUnsupported = errors.New("backups need the mongo store")
+
+type backupRequest struct {
+	OmitPasswords bool `form:"omit_passwords"`
+}
+
+// backupDatabase is for admins to download an archive of the database,
+// see backup.Write
+func (server *Server) backupDatabase(ctx *gin.Context) {
+	database, ok := server.store.(backup.Database)
+	if !ok {
+		ctx.JSON(http.StatusNotImplemented, errorResponse(errBackupUnsupported))
+		return
+	}
+	var req backupRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	// The archive is written to a file first, so that an error
+	// isn't found after the response has started
+	file, err := os.CreateTemp("", "phantom-backup-*.tar.gz")
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	defer os.Remove(file.Name())
+	manifest, err := backup.Write(ctx, database, file, backup.Options{OmitPasswords: req.OmitPasswords})
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.FileAttachment(file.Name(), backup.FileName(manifest.CreatedAt))
+}
+
+type restoreRequest struct {
+	DryRun bool `form:"dry_run"`
+}
+
+// restoreResponse is what the restore did, with the error it stopped at
+type restoreResponse struct {
+	backup.Report
+	Error string `json:"error,omitempty"`
+}
+
+// restoreDatabase is for admins to replace the collections of the database
+// with those of the archive in the body of the request, see backup.Restore
+func (server *Server) restoreDatabase(ctx *gin.Context) {
+	database, ok := server.store.(backup.Database)
+	if !ok {
+		ctx.JSON(http.StatusNotImplemented, errorResponse(errBackupUnsupported))
+		return
+	}
+	var req restoreRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	// A restore reads the archive twice, to verify it and then to restore it
+	file, err := os.CreateTemp("", "phantom-restore-*.tar.gz")
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	defer os.Remove(file.Name())
+	_, err = io.Copy(file, ctx.Request.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	report, err := backup.Restore(ctx, database, file.Name(), req.DryRun)
+	if err == nil && !req.DryRun {
+		log.Printf("restore: replaced %d collections with the archive of %s",
+			len(report.Collections), report.CreatedAt.Format(time.RFC3339))
+		// The suggestions and the search index are of the data that was replaced
+		err = server.BuildIndexes(ctx)
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		var archiveErr *backup.ArchiveError
+		if errors.As(err, &archiveErr) {
+			status = http.StatusBadRequest
+		}
+		ctx.JSON(status, restoreResponse{Report: report, Error: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, restoreResponse{Report: report})
+}
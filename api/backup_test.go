@@ -0,0 +1,127 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"net/http"
+	"net/http/httptest"
+	mockdb "phantom/db/mock"
+	db "phantom/db/mongo"
+	"strings"
+	"testing"
+	"time"
+)
+
+// backupStore is a MockStore with the collections of a database to back up
+type backupStore struct {
+	*mockdb.MockStore
+	collections map[string][]bson.Raw
+}
+
+func (store *backupStore) CollectionNames(ctx context.Context) ([]string, error) {
+	var names []string
+	for name := range store.collections {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (store *backupStore) ExportCollection(ctx context.Context, name string, fn func(doc bson.Raw) error) error {
+	for _, doc := range store.collections[name] {
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (store *backupStore) ClearCollection(ctx context.Context, name string) error {
+	store.collections[name] = nil
+	return nil
+}
+
+func (store *backupStore) InsertDocuments(ctx context.Context, name string, docs []bson.Raw) error {
+	store.collections[name] = append(store.collections[name], docs...)
+	return nil
+}
+
+func (store *backupStore) SchemaVersion(ctx context.Context) (int64, error) {
+	return 2, nil
+}
+
+func (store *backupStore) LatestSchemaVersion() int64 {
+	return 2
+}
+
+func (store *backupStore) MigrateFrom(ctx context.Context, version int64) ([]db.Migration, error) {
+	return nil, nil
+}
+
+func sendBackupRequest(t *testing.T, server *Server, method, url string, body []byte) *httptest.ResponseRecorder {
+	request, err := http.NewRequest(method, url, bytes.NewReader(body))
+	require.NoError(t, err)
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "user", time.Minute)
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestBackupAndRestoreAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mockdb.NewMockStore(ctrl)
+	movie, err := bson.Marshal(bson.M{"title": "Alien", "year": 1979})
+	require.NoError(t, err)
+	store := &backupStore{MockStore: mockStore, collections: map[string][]bson.Raw{"movies": {movie}}}
+	server := newTestServer(t, store)
+
+	expectAdmin(mockStore)
+	recorder := sendBackupRequest(t, server, http.MethodGet, "/backup", nil)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Contains(t, recorder.Header().Get("Content-Disposition"), ".tar.gz")
+	archive := recorder.Body.Bytes()
+
+	// A dry run leaves the collections as they are
+	store.collections["movies"] = nil
+	expectAdmin(mockStore)
+	recorder = sendBackupRequest(t, server, http.MethodPost, "/restore?dry_run=true", archive)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var rsp restoreResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+	require.True(t, rsp.DryRun)
+	require.Equal(t, int64(1), rsp.Collections[0].Documents)
+	require.Empty(t, store.collections["movies"])
+
+	expectAdmin(mockStore)
+	// The suggestions pick the restored movies up
+	mockStore.EXPECT().GetAllMovies(gomock.Any()).Times(1).Return([]db.Movies{{Title: "Alien"}}, nil)
+	recorder = sendBackupRequest(t, server, http.MethodPost, "/restore", archive)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, []bson.Raw{movie}, store.collections["movies"])
+
+	expectAdmin(mockStore)
+	recorder = sendBackupRequest(t, server, http.MethodPost, "/restore", archive[:len(archive)/2])
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestBackupAPIUnsupported(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+	for _, url := range []string{"/backup", "/restore"} {
+		method := http.MethodGet
+		if strings.HasPrefix(url, "/restore") {
+			method = http.MethodPost
+		}
+		expectAdmin(store)
+		recorder := sendBackupRequest(t, server, method, url, nil)
+		require.Equal(t, http.StatusNotImplemented, recorder.Code)
+	}
+}
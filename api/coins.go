@@ -0,0 +1,294 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"net/http"
+	db "phantom/db/mongo"
+	"phantom/token"
+)
+
+// What users earn for taking part, and what they spend coins on
+const (
+	commentReward    = 2
+	ratingReward     = 1
+	moderationReward = 5
+	pinDanmakuCost   = 10
+	requestTitleCost = 20
+)
+
+// idempotencyKeyHeader lets a client retry a payment without paying twice
+const idempotencyKeyHeader = "Idempotency-Key"
+
+var errDanmakuNotFound = errors.New("danmaku is not found")
+
+// coinErrorResponse answers the errors of spending coins
+func coinErrorResponse(ctx *gin.Context, err error) {
+	switch err {
+	case db.ErrInsufficientCoins:
+		ctx.JSON(http.StatusPaymentRequired, errorResponse(err))
+	case db.ErrCoinKeyReused, db.ErrDanmakuPinned:
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+	default:
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+	}
+}
+
+// idempotencyKey is the key the client sent, or a new one
+// when it doesn't retry the request
+func idempotencyKey(ctx *gin.Context) string {
+	if key := ctx.GetHeader(idempotencyKeyHeader); key != "" {
+		return key
+	}
+	return primitive.NewObjectID().Hex()
+}
+
+// reward is the transfer that rewards a user for taking part,
+// the key keeps it from being paid twice
+func reward(kind, key, name string, amount int64) db.TransferCoinsParams {
+	return db.TransferCoinsParams{
+		Key:    kind + ":" + key,
+		Kind:   kind,
+		From:   db.CoinAccountIssued,
+		To:     db.UserCoinAccount(name),
+		Amount: amount,
+	}
+}
+
+// earnCoins pays a reward on its own. Such rewards are best effort, as
+// nothing takes them back, so a failure is only logged
+func (server *Server) earnCoins(ctx *gin.Context, kind, key, name string, amount int64) {
+	_, err := server.store.TransferCoinsTx(ctx, reward(kind, key, name, amount))
+	if err != nil {
+		log.Printf("cannot reward %s with %s: %v", name, kind, err)
+	}
+}
+
+// revokeCoins takes back, in the transaction of q, the reward of kind earned
+// with the key, as what it was paid for is undone, such as a comment that is
+// deleted. A user who spent the reward owes it, and a reward that was never
+// paid is left alone
+func revokeCoins(ctx context.Context, q db.Querier, earned, kind, key string) error {
+	_, err := q.ReverseCoins(ctx, db.ReverseCoinsParams{
+		Key:      kind + ":" + key,
+		Kind:     kind,
+		Reverses: earned + ":" + key,
+	})
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	return err
+}
+
+type walletResponse struct {
+	Balance   int64              `json:"balance"`
+	UpdatedAt primitive.DateTime `json:"updated_at"`
+}
+
+// getWallet is for the signed-in user to get their balance
+func (server *Server) getWallet(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	wallet, err := server.store.GetCoinWallet(ctx, db.UserCoinAccount(authPayload.Username))
+	if err != nil && mongo.ErrNoDocuments != err {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, walletResponse{Balance: wallet.Balance, UpdatedAt: wallet.UpdatedAt})
+}
+
+// coinTransactionResponse is a transaction as seen from one account,
+// Amount being what it added to the account
+type coinTransactionResponse struct {
+	Id        string             `json:"id"`
+	Kind      string             `json:"kind"`
+	Amount    int64              `json:"amount"`
+	Memo      string             `json:"memo,omitempty"`
+	CreatedAt primitive.DateTime `json:"created_at"`
+}
+
+func newCoinTransactionResponse(transaction db.CoinTransaction, account string) coinTransactionResponse {
+	rsp := coinTransactionResponse{
+		Id:        transaction.ID.Hex(),
+		Kind:      transaction.Kind,
+		Memo:      transaction.Memo,
+		CreatedAt: transaction.CreatedAt,
+	}
+	for _, posting := range transaction.Postings {
+		if posting.Account == account {
+			rsp.Amount += posting.Amount
+		}
+	}
+	return rsp
+}
+
+// listWalletTransactions is for the signed-in user to get a page of
+// the transactions of their wallet, the latest first
+func (server *Server) listWalletTransactions(ctx *gin.Context) {
+	var req getMoviesRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	account := db.UserCoinAccount(authPayload.Username)
+	transactions, err := server.store.GetCoinTransactions(ctx, db.GetCoinTransactionsParams{
+		Account: account,
+		Skip:    req.PageSize * (req.PageId - 1),
+		Limit:   req.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	rsp := make([]coinTransactionResponse, len(transactions))
+	for i, transaction := range transactions {
+		rsp[i] = newCoinTransactionResponse(transaction, account)
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+type grantCoinsRequest struct {
+	Name   string `json:"name" binding:"required"`
+	Amount int64  `json:"amount" binding:"required,min=1,max=1000000"`
+	Memo   string `json:"memo" binding:"max=200"`
+}
+
+// grantCoins is for admins to give coins to a user. Sending the request
+// again with the same Idempotency-Key grants them once, the key being
+// the admin's own for the user
+func (server *Server) grantCoins(ctx *gin.Context) {
+	var req grantCoinsRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if _, err := server.store.GetUserByName(ctx, req.Name); err != nil {
+		if mongo.ErrNoDocuments == err {
+			ctx.JSON(http.StatusNotFound, errorResponse(errUserNotFound))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	account := db.UserCoinAccount(req.Name)
+	result, err := server.store.TransferCoinsTx(ctx, db.TransferCoinsParams{
+		Key:    db.CoinGrant + ":" + authPayload.Username + ":" + req.Name + ":" + idempotencyKey(ctx),
+		Kind:   db.CoinGrant,
+		From:   db.CoinAccountIssued,
+		To:     account,
+		Amount: req.Amount,
+		Memo:   req.Memo,
+	})
+	if err != nil {
+		coinErrorResponse(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, newCoinTransactionResponse(result.Transaction, account))
+}
+
+type reconcileCoinsResponse struct {
+	OK bool `json:"ok"`
+	db.CoinReconciliation
+}
+
+// reconcileCoins is for admins to check the balances against the transactions
+func (server *Server) reconcileCoins(ctx *gin.Context) {
+	result, err := server.store.ReconcileCoins(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, reconcileCoinsResponse{OK: result.OK(), CoinReconciliation: result})
+}
+
+// pinDanmaku is for the signed-in user to pin a danmaku with coins,
+// so that it shows even in a busy scene
+func (server *Server) pinDanmaku(ctx *gin.Context) {
+	var req commentUriRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	danmakuID, err := primitive.ObjectIDFromHex(req.Id)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	result, err := server.store.PinDanmakuTx(ctx, db.PinDanmakuTxParams{
+		DanmakuID: danmakuID,
+		Name:      authPayload.Username,
+		Cost:      pinDanmakuCost,
+	})
+	if err != nil {
+		if mongo.ErrNoDocuments == err {
+			ctx.JSON(http.StatusNotFound, errorResponse(errDanmakuNotFound))
+			return
+		}
+		coinErrorResponse(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, newDanmakuResponse(result.Danmaku))
+}
+
+type requestTitleRequest struct {
+	Title string `json:"title" binding:"required,max=200"`
+	Year  int32  `json:"year" binding:"omitempty,min=1878,max=2100"`
+	Note  string `json:"note" binding:"max=500"`
+}
+
+// requestTitle is for the signed-in user to ask for a title to be added
+// to the catalog, with coins. Sending the request again with the same
+// Idempotency-Key pays once
+func (server *Server) requestTitle(ctx *gin.Context) {
+	var req requestTitleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	request, err := server.store.RequestTitleTx(ctx, db.RequestTitleTxParams{
+		Key:   authPayload.Username + ":" + idempotencyKey(ctx),
+		Name:  authPayload.Username,
+		Title: req.Title,
+		Year:  req.Year,
+		Note:  req.Note,
+		Cost:  requestTitleCost,
+	})
+	if err != nil {
+		coinErrorResponse(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, request)
+}
+
+// listTitleRequests is for admins to get a page of the title requests, the latest first
+func (server *Server) listTitleRequests(ctx *gin.Context) {
+	var req getMoviesRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	requests, err := server.store.GetTitleRequests(ctx, db.GetTitleRequestsParams{
+		Skip:  req.PageSize * (req.PageId - 1),
+		Limit: req.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if requests == nil {
+		requests = []db.TitleRequest{}
+	}
+	ctx.JSON(http.StatusOK, requests)
+}
@@ -0,0 +1,505 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"net/http/httptest"
+	mockdb "phantom/db/mock"
+	db "phantom/db/mongo"
+	"testing"
+	"time"
+)
+
+// expectReward expects the user with the name to earn amount coins of kind, once for key
+func expectReward(store *mockdb.MockStore, kind, key, name string, amount int64) {
+	arg := reward(kind, key, name, amount)
+	store.EXPECT().TransferCoinsTx(gomock.Any(), gomock.Eq(arg)).Times(1).Return(db.TransferCoinsResult{}, nil)
+}
+
+// expectCommentReward expects the author of the comment with the id to earn
+// its reward, in the transaction that writes the comment
+func expectCommentReward(store *mockdb.MockStore, id primitive.ObjectID, name string) {
+	arg := reward(db.CoinEarnComment, id.Hex(), name, commentReward)
+	store.EXPECT().TransferCoins(gomock.Any(), gomock.Eq(arg)).Times(1).Return(db.TransferCoinsResult{}, nil)
+}
+
+// expectRevoke expects the reward of kind earned with the key to be taken back
+func expectRevoke(store *mockdb.MockStore, earned, kind, key string, err error) {
+	arg := db.ReverseCoinsParams{
+		Key:      kind + ":" + key,
+		Kind:     kind,
+		Reverses: earned + ":" + key,
+	}
+	store.EXPECT().ReverseCoins(gomock.Any(), gomock.Eq(arg)).Times(1).Return(db.TransferCoinsResult{}, err)
+}
+
+func TestGetWalletAPI(t *testing.T) {
+	testCase := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetCoinWallet(gomock.Any(), gomock.Eq("user:user")).Times(1).
+					Return(db.CoinWallet{Account: "user:user", Balance: 42}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp walletResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, int64(42), rsp.Balance)
+			},
+		},
+		{
+			name: "NoTransactionsYet",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetCoinWallet(gomock.Any(), gomock.Any()).Times(1).Return(db.CoinWallet{}, mongo.ErrNoDocuments)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp walletResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Zero(t, rsp.Balance)
+			},
+		},
+		{
+			name: "InternalError",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetCoinWallet(gomock.Any(), gomock.Any()).Times(1).Return(db.CoinWallet{}, mongo.ErrClientDisconnected)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := sendAuthorizedJSON(t, server, http.MethodGet, "/me/wallet", nil)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestListWalletTransactionsAPI(t *testing.T) {
+	transactions := []db.CoinTransaction{
+		{
+			ID:       primitive.NewObjectID(),
+			Kind:     db.CoinPinDanmaku,
+			Postings: []db.CoinPosting{{Account: "user:user", Amount: -10}, {Account: db.CoinAccountSpent, Amount: 10}},
+		},
+		{
+			ID:       primitive.NewObjectID(),
+			Kind:     db.CoinEarnComment,
+			Postings: []db.CoinPosting{{Account: db.CoinAccountIssued, Amount: -2}, {Account: "user:user", Amount: 2}},
+		},
+	}
+
+	testCase := []struct {
+		name          string
+		query         string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			query: "?s=2&p=2",
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.GetCoinTransactionsParams{Account: "user:user", Limit: 2, Skip: 2}
+				store.EXPECT().GetCoinTransactions(gomock.Any(), gomock.Eq(arg)).Times(1).Return(transactions, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp []coinTransactionResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Len(t, rsp, 2)
+				require.Equal(t, int64(-10), rsp[0].Amount)
+				require.Equal(t, int64(2), rsp[1].Amount)
+				require.Equal(t, db.CoinEarnComment, rsp[1].Kind)
+			},
+		},
+		{
+			name:  "Empty",
+			query: "?s=10&p=1",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetCoinTransactions(gomock.Any(), gomock.Any()).Times(1).Return(nil, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Equal(t, "[]", recorder.Body.String())
+			},
+		},
+		{
+			name:  "InvalidPage",
+			query: "?s=100&p=1",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetCoinTransactions(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := sendAuthorizedJSON(t, server, http.MethodGet, "/me/wallet/transactions"+tc.query, nil)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestGrantCoinsAPI(t *testing.T) {
+	transaction := db.CoinTransaction{
+		ID:       primitive.NewObjectID(),
+		Kind:     db.CoinGrant,
+		Postings: []db.CoinPosting{{Account: db.CoinAccountIssued, Amount: -50}, {Account: "user:alice", Amount: 50}},
+	}
+
+	testCase := []struct {
+		name          string
+		key           string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			key:  "grant-1",
+			body: gin.H{"name": "alice", "amount": 50, "memo": "welcome"},
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("alice")).Times(1).Return(db.User{Name: "alice"}, nil)
+				arg := db.TransferCoinsParams{
+					Key:    "grant:user:alice:grant-1",
+					Kind:   db.CoinGrant,
+					From:   db.CoinAccountIssued,
+					To:     "user:alice",
+					Amount: 50,
+					Memo:   "welcome",
+				}
+				store.EXPECT().TransferCoinsTx(gomock.Any(), gomock.Eq(arg)).Times(1).
+					Return(db.TransferCoinsResult{Transaction: transaction}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp coinTransactionResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, transaction.ID.Hex(), rsp.Id)
+				require.Equal(t, int64(50), rsp.Amount)
+			},
+		},
+		{
+			name: "KeyForAnotherUser",
+			key:  "grant-1",
+			body: gin.H{"name": "bob", "amount": 50},
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("bob")).Times(1).Return(db.User{Name: "bob"}, nil)
+				arg := db.TransferCoinsParams{
+					Key:    "grant:user:bob:grant-1",
+					Kind:   db.CoinGrant,
+					From:   db.CoinAccountIssued,
+					To:     "user:bob",
+					Amount: 50,
+				}
+				store.EXPECT().TransferCoinsTx(gomock.Any(), gomock.Eq(arg)).Times(1).
+					Return(db.TransferCoinsResult{Transaction: transaction}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "KeyReused",
+			key:  "grant-1",
+			body: gin.H{"name": "alice", "amount": 60},
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("alice")).Times(1).Return(db.User{Name: "alice"}, nil)
+				store.EXPECT().TransferCoinsTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferCoinsResult{}, db.ErrCoinKeyReused)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "UserNotFound",
+			body: gin.H{"name": "nobody", "amount": 50},
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("nobody")).Times(1).Return(db.User{}, mongo.ErrNoDocuments)
+				store.EXPECT().TransferCoinsTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidAmount",
+			body: gin.H{"name": "alice", "amount": -5},
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().TransferCoinsTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "NotAdmin",
+			body: gin.H{"name": "alice", "amount": 50},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("user")).Times(1).Return(db.User{Name: "user"}, nil)
+				store.EXPECT().TransferCoinsTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+			request, err := http.NewRequest(http.MethodPost, "/coins/grants", bytes.NewReader(data))
+			require.NoError(t, err)
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "user", time.Minute)
+			if tc.key != "" {
+				request.Header.Set(idempotencyKeyHeader, tc.key)
+			}
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestReconcileCoinsAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	expectAdmin(store)
+	store.EXPECT().ReconcileCoins(gomock.Any()).Times(1).Return(db.CoinReconciliation{
+		Transactions: 3,
+		Mismatches:   []db.CoinMismatch{{Account: "user:alice", Balance: 100, Expected: 50}},
+		Total:        50,
+	}, nil)
+	server := newTestServer(t, store)
+
+	recorder := sendAuthorizedJSON(t, server, http.MethodGet, "/coins/reconcile", nil)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var rsp reconcileCoinsResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+	require.False(t, rsp.OK)
+	require.Equal(t, int64(3), rsp.Transactions)
+	require.Equal(t, "user:alice", rsp.Mismatches[0].Account)
+}
+
+func TestPinDanmakuAPI(t *testing.T) {
+	danmaku := db.Danmaku{ID: primitive.NewObjectID(), MovieID: primitive.NewObjectID(), Text: "前方高能", Pinned: true}
+
+	testCase := []struct {
+		name          string
+		id            string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			id:   danmaku.ID.Hex(),
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.PinDanmakuTxParams{DanmakuID: danmaku.ID, Name: "user", Cost: pinDanmakuCost}
+				store.EXPECT().PinDanmakuTx(gomock.Any(), gomock.Eq(arg)).Times(1).Return(db.PinDanmakuTxResult{Danmaku: danmaku}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp danmakuResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.True(t, rsp.Pinned)
+			},
+		},
+		{
+			name: "NotEnoughCoins",
+			id:   danmaku.ID.Hex(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().PinDanmakuTx(gomock.Any(), gomock.Any()).Times(1).Return(db.PinDanmakuTxResult{}, db.ErrInsufficientCoins)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusPaymentRequired, recorder.Code)
+			},
+		},
+		{
+			name: "AlreadyPinned",
+			id:   danmaku.ID.Hex(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().PinDanmakuTx(gomock.Any(), gomock.Any()).Times(1).Return(db.PinDanmakuTxResult{}, db.ErrDanmakuPinned)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "NotFound",
+			id:   danmaku.ID.Hex(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().PinDanmakuTx(gomock.Any(), gomock.Any()).Times(1).Return(db.PinDanmakuTxResult{}, mongo.ErrNoDocuments)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidID",
+			id:   "123",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().PinDanmakuTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := sendAuthorizedJSON(t, server, http.MethodPost, fmt.Sprintf("/danmaku/%s/pin", tc.id), nil)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestRequestTitleAPI(t *testing.T) {
+	testCase := []struct {
+		name          string
+		key           string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			key:  "req-1",
+			body: gin.H{"title": "Dune: Part Two", "year": 2024},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.RequestTitleTxParams{
+					Key:   "user:req-1",
+					Name:  "user",
+					Title: "Dune: Part Two",
+					Year:  2024,
+					Cost:  requestTitleCost,
+				}
+				store.EXPECT().RequestTitleTx(gomock.Any(), gomock.Eq(arg)).Times(1).
+					Return(db.TitleRequest{ID: primitive.NewObjectID(), Name: "user", Title: "Dune: Part Two", Year: 2024}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp db.TitleRequest
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, "Dune: Part Two", rsp.Title)
+			},
+		},
+		{
+			name: "NotEnoughCoins",
+			body: gin.H{"title": "Dune"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().RequestTitleTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TitleRequest{}, db.ErrInsufficientCoins)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusPaymentRequired, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidYear",
+			body: gin.H{"title": "Dune", "year": 1500},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().RequestTitleTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+			request, err := http.NewRequest(http.MethodPost, "/title-requests", bytes.NewReader(data))
+			require.NoError(t, err)
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "user", time.Minute)
+			if tc.key != "" {
+				request.Header.Set(idempotencyKeyHeader, tc.key)
+			}
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestListTitleRequestsAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	expectAdmin(store)
+	arg := db.GetTitleRequestsParams{Limit: 10, Skip: 0}
+	store.EXPECT().GetTitleRequests(gomock.Any(), gomock.Eq(arg)).Times(1).Return(nil, nil)
+	server := newTestServer(t, store)
+
+	recorder := sendAuthorizedJSON(t, server, http.MethodGet, "/title-requests?s=10&p=1", nil)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, "[]", recorder.Body.String())
+}
@@ -0,0 +1,280 @@
+package api
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	db "phantom/db/mongo"
+)
+
+var (
+	errCollectionNotFound = errors.New("collection is not found")
+	errCollectionKind     = errors.New("a collection has either movie_ids or a filter, not both")
+	errUnknownMovie       = errors.New("movie_ids name a movie that doesn't exist")
+)
+
+type collectionFilterRequest struct {
+	Genres    []string `json:"genres"`
+	Cast      []string `json:"cast"`
+	Directors []string `json:"directors"`
+	Writers   []string `json:"writers"`
+	Countries []string `json:"countries"`
+	Type      string   `json:"type" binding:"omitempty,oneof=movie series"`
+	YearFrom  int64    `json:"year_from" binding:"min=0"`
+	YearTo    int64    `json:"year_to" binding:"omitempty,gtefield=YearFrom"`
+	MinRating float64  `json:"min_rating" binding:"min=0,max=10"`
+	Sort      string   `json:"sort" binding:"omitempty,oneof=year rating title"`
+}
+
+type createCollectionRequest struct {
+	Title       string                   `json:"title" binding:"required,min=1"`
+	Poster      string                   `json:"poster" binding:"omitempty,url"`
+	Description string                   `json:"description"`
+	MovieIDs    []string                 `json:"movie_ids" binding:"unique,dive,hexadecimal,len=24"`
+	Filter      *collectionFilterRequest `json:"filter"`
+}
+
+type collectionResponse struct {
+	Id          string               `json:"id"`
+	Title       string               `json:"title"`
+	Poster      string               `json:"poster"`
+	Description string               `json:"description"`
+	Smart       bool                 `json:"smart"`
+	MovieIDs    []string             `json:"movie_ids,omitempty"`
+	Filter      *db.CollectionFilter `json:"filter,omitempty"`
+}
+
+func newCollectionResponse(collection db.Collection) collectionResponse {
+	rsp := collectionResponse{
+		Id:          collection.ID.Hex(),
+		Title:       collection.Title,
+		Poster:      collection.Poster,
+		Description: collection.Description,
+		Smart:       collection.Smart(),
+		Filter:      collection.Filter,
+	}
+	for _, id := range collection.MovieIDs {
+		rsp.MovieIDs = append(rsp.MovieIDs, id.Hex())
+	}
+	return rsp
+}
+
+// collectionSummary is how a movie shows the collections it is in
+type collectionSummary struct {
+	Id    string `json:"id"`
+	Title string `json:"title"`
+}
+
+// newCollection checks a create or update request, the movies
+// of a curated collection have to be in the catalog
+func (server *Server) newCollection(ctx *gin.Context, req createCollectionRequest) (db.Collection, error) {
+	if req.Filter != nil && len(req.MovieIDs) > 0 {
+		return db.Collection{}, errCollectionKind
+	}
+	collection := db.Collection{
+		Title:       req.Title,
+		Poster:      req.Poster,
+		Description: req.Description,
+	}
+	if req.Filter != nil {
+		collection.Filter = &db.CollectionFilter{
+			Genres:    req.Filter.Genres,
+			Cast:      req.Filter.Cast,
+			Directors: req.Filter.Directors,
+			Writers:   req.Filter.Writers,
+			Countries: req.Filter.Countries,
+			Type:      req.Filter.Type,
+			YearFrom:  req.Filter.YearFrom,
+			YearTo:    req.Filter.YearTo,
+			MinRating: req.Filter.MinRating,
+			Sort:      req.Filter.Sort,
+		}
+		return collection, nil
+	}
+	if len(req.MovieIDs) == 0 {
+		return collection, nil
+	}
+
+	for _, hex := range req.MovieIDs {
+		id, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			return db.Collection{}, errUnknownMovie
+		}
+		collection.MovieIDs = append(collection.MovieIDs, id)
+	}
+	movies, err := server.store.GetMoviesByIDs(ctx, collection.MovieIDs)
+	if err != nil {
+		return db.Collection{}, err
+	}
+	if len(movies) != len(collection.MovieIDs) {
+		return db.Collection{}, errUnknownMovie
+	}
+	return collection, nil
+}
+
+// createCollection adds a curated collection of movies in the order given,
+// or a smart collection of the movies that match a filter
+func (server *Server) createCollection(ctx *gin.Context) {
+	var req createCollectionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	collection, err := server.newCollection(ctx, req)
+	if err != nil {
+		if err == errCollectionKind || err == errUnknownMovie {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	id, err := server.store.AddCollection(ctx, db.AddCollectionParams{
+		Title:       collection.Title,
+		Poster:      collection.Poster,
+		Description: collection.Description,
+		MovieIDs:    collection.MovieIDs,
+		Filter:      collection.Filter,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"id": id.Hex()})
+}
+
+type collectionIdRequest struct {
+	Id string `uri:"id" binding:"required,hexadecimal,min=24"`
+}
+
+// updateCollection replaces a collection, to reorder its movies for example
+func (server *Server) updateCollection(ctx *gin.Context) {
+	var reqUri collectionIdRequest
+	var reqJson createCollectionRequest
+	if err := ctx.ShouldBindUri(&reqUri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if err := ctx.ShouldBindJSON(&reqJson); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	objectID, err := primitive.ObjectIDFromHex(reqUri.Id)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	collection, err := server.newCollection(ctx, reqJson)
+	if err != nil {
+		if err == errCollectionKind || err == errUnknownMovie {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	collection.ID = objectID
+	if _, err = server.store.ReplaceCollection(ctx, collection); err != nil {
+		if mongo.ErrNoDocuments == err {
+			ctx.JSON(http.StatusNotFound, errorResponse(errCollectionNotFound))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"updated": "OK"})
+}
+
+func (server *Server) deleteCollection(ctx *gin.Context) {
+	var req collectionIdRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	objectID, err := primitive.ObjectIDFromHex(req.Id)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if _, err = server.store.DeleteCollection(ctx, objectID); err != nil {
+		if mongo.ErrNoDocuments == err {
+			ctx.JSON(http.StatusNotFound, errorResponse(errCollectionNotFound))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"deleted": "OK"})
+}
+
+type listCollectionsRequest struct {
+	PageSize int64 `form:"s" binding:"required,min=1,max=50"`
+	PageId   int64 `form:"p" binding:"required,min=1"`
+}
+
+// listCollections is to get a page of collections by title
+func (server *Server) listCollections(ctx *gin.Context) {
+	var req listCollectionsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	collections, err := server.store.GetCollections(ctx, db.GetCollectionsParams{
+		Skip:  req.PageSize * (req.PageId - 1),
+		Limit: req.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := []collectionResponse{}
+	for _, collection := range collections {
+		rsp = append(rsp, newCollectionResponse(collection))
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// listCollectionMovies is to get a page of the movies of a collection,
+// a smart collection is evaluated on every request
+func (server *Server) listCollectionMovies(ctx *gin.Context) {
+	var reqUri collectionIdRequest
+	var reqQuery listCollectionsRequest
+	if err := ctx.ShouldBindUri(&reqUri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if err := ctx.ShouldBindQuery(&reqQuery); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	objectID, err := primitive.ObjectIDFromHex(reqUri.Id)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	collection, err := server.store.GetCollectionByID(ctx, objectID)
+	if err != nil {
+		if mongo.ErrNoDocuments == err {
+			ctx.JSON(http.StatusNotFound, errorResponse(errCollectionNotFound))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	movies, err := server.store.GetCollectionMovies(ctx, db.GetCollectionMoviesParams{
+		Collection: collection,
+		Skip:       reqQuery.PageSize * (reqQuery.PageId - 1),
+		Limit:      reqQuery.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, generateGetMoviesResponse(movies, server.preferredLanguages(ctx)))
+}
@@ -0,0 +1,280 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"net/http/httptest"
+	mockdb "phantom/db/mock"
+	db "phantom/db/mongo"
+	"phantom/util"
+	"testing"
+)
+
+func expectAdmin(store *mockdb.MockStore) {
+	store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("user")).
+		Times(1).
+		Return(db.User{Name: "user", Role: db.UserRoleAdmin}, nil)
+}
+
+func TestCreateCollectionAPI(t *testing.T) {
+	movies := []db.Movies{{Id: primitive.NewObjectID()}, {Id: primitive.NewObjectID()}}
+	ids := []primitive.ObjectID{movies[1].Id, movies[0].Id}
+	title := util.RandomString(10)
+	returnId := primitive.NewObjectID()
+
+	testCase := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "Curated",
+			body: gin.H{"title": title, "movie_ids": []string{ids[0].Hex(), ids[1].Hex()}},
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().GetMoviesByIDs(gomock.Any(), gomock.Eq(ids)).Times(1).Return(movies, nil)
+				arg := db.AddCollectionParams{Title: title, MovieIDs: ids}
+				store.EXPECT().AddCollection(gomock.Any(), gomock.Eq(arg)).Times(1).Return(returnId, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchObjectId(t, returnId, recorder.Body)
+			},
+		},
+		{
+			name: "Smart",
+			body: gin.H{"title": title, "filter": gin.H{
+				"directors":  []string{"Hayao Miyazaki"},
+				"year_from":  1984,
+				"year_to":    1997,
+				"min_rating": 7.5,
+			}},
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().GetMoviesByIDs(gomock.Any(), gomock.Any()).Times(0)
+				arg := db.AddCollectionParams{Title: title, Filter: &db.CollectionFilter{
+					Directors: []string{"Hayao Miyazaki"},
+					YearFrom:  1984,
+					YearTo:    1997,
+					MinRating: 7.5,
+				}}
+				store.EXPECT().AddCollection(gomock.Any(), gomock.Eq(arg)).Times(1).Return(returnId, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "BothKinds",
+			body: gin.H{"title": title, "movie_ids": []string{ids[0].Hex()}, "filter": gin.H{"genres": []string{"Animation"}}},
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().AddCollection(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidYears",
+			body: gin.H{"title": title, "filter": gin.H{"year_from": 1997, "year_to": 1984}},
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().AddCollection(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "UnknownMovie",
+			body: gin.H{"title": title, "movie_ids": []string{ids[0].Hex(), ids[1].Hex()}},
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().GetMoviesByIDs(gomock.Any(), gomock.Eq(ids)).Times(1).Return(movies[:1], nil)
+				store.EXPECT().AddCollection(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "NotAdmin",
+			body: gin.H{"title": title},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("user")).Times(1).Return(db.User{Name: "user"}, nil)
+				store.EXPECT().AddCollection(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := sendAuthorizedJSON(t, server, http.MethodPost, "/collections", tc.body)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestUpdateCollectionAPI(t *testing.T) {
+	id := primitive.NewObjectID()
+	title := util.RandomString(10)
+
+	testCase := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				arg := db.Collection{ID: id, Title: title}
+				store.EXPECT().ReplaceCollection(gomock.Any(), gomock.Eq(arg)).Times(1).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "NotFound",
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().ReplaceCollection(gomock.Any(), gomock.Any()).Times(1).Return(nil, mongo.ErrNoDocuments)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := sendAuthorizedJSON(t, server, http.MethodPut, "/collections/"+id.Hex(), gin.H{"title": title})
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestListCollectionsAPI(t *testing.T) {
+	collections := []db.Collection{
+		{ID: primitive.NewObjectID(), Title: "A", MovieIDs: []primitive.ObjectID{primitive.NewObjectID()}},
+		{ID: primitive.NewObjectID(), Title: "B", Filter: &db.CollectionFilter{Genres: []string{"Animation"}}},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	arg := db.GetCollectionsParams{Skip: 0, Limit: 10}
+	store.EXPECT().GetCollections(gomock.Any(), gomock.Eq(arg)).Times(1).Return(collections, nil)
+	server := newTestServer(t, store)
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/collections?s=10&p=1", nil)
+	require.NoError(t, err)
+	server.router.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var rsp []collectionResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+	require.Len(t, rsp, 2)
+	require.False(t, rsp[0].Smart)
+	require.Equal(t, []string{collections[0].MovieIDs[0].Hex()}, rsp[0].MovieIDs)
+	require.True(t, rsp[1].Smart)
+	require.Equal(t, collections[1].Filter, rsp[1].Filter)
+}
+
+func TestListCollectionMoviesAPI(t *testing.T) {
+	collection := db.Collection{ID: primitive.NewObjectID(), Title: util.RandomString(8), Filter: &db.CollectionFilter{Genres: []string{"Animation"}}}
+	movies := []db.Movies{{Id: primitive.NewObjectID(), Title: util.RandomString(8)}}
+
+	testCase := []struct {
+		name          string
+		query         string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			query: "?s=5&p=2",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetCollectionByID(gomock.Any(), gomock.Eq(collection.ID)).Times(1).Return(collection, nil)
+				arg := db.GetCollectionMoviesParams{Collection: collection, Skip: 5, Limit: 5}
+				store.EXPECT().GetCollectionMovies(gomock.Any(), gomock.Eq(arg)).Times(1).Return(movies, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp []getMoviesResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Len(t, rsp, 1)
+				require.Equal(t, movies[0].Title, rsp[0].Title)
+			},
+		},
+		{
+			name:  "NotFound",
+			query: "?s=5&p=1",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetCollectionByID(gomock.Any(), gomock.Eq(collection.ID)).Times(1).Return(db.Collection{}, mongo.ErrNoDocuments)
+				store.EXPECT().GetCollectionMovies(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:  "InvalidPageSize",
+			query: "?s=0&p=1",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetCollectionByID(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, "/collections/"+collection.ID.Hex()+"/movies"+tc.query, nil)
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
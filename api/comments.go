@@ -7,16 +7,36 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"net/http"
 	db "phantom/db/mongo"
+	"phantom/filter"
+	"phantom/live"
 	"phantom/token"
 )
 
 var errUnAuthorizedUser = errors.New("Unauthorized User")
 var errCommentNotFound = errors.New("Comment is not found")
+var errParentNotFound = errors.New("Parent comment is not found")
+var errParentOfOtherMovie = errors.New("Parent comment is on another movie")
+var errThreadTooDeep = errors.New("Thread is too deep to reply to")
+var errReactionNotFound = errors.New("Reaction is not found")
+var errUserBanned = errors.New("User is banned from commenting")
+
+// maxCommentDepth is how many comments can be above a reply
+const maxCommentDepth = 5
+
+// inlineReplies is how many replies a threaded listing shows under each comment
+const inlineReplies = 3
+
+// deletedPlaceholder stands for the name and text of a deleted comment that has replies
+const deletedPlaceholder = "[deleted]"
+
+// hiddenPlaceholder stands for the name and text of a comment hidden for its reports
+const hiddenPlaceholder = "[hidden]"
 
 type createCommentRequest struct {
-	Email   string `json:"email" binding:"email|max=0"`
-	MovieID string `json:"movie_id" binding:"required,hexadecimal,min=24"`
-	Text    string `json:"text" binding:"required,min=1"`
+	Email    string `json:"email" binding:"email|max=0"`
+	MovieID  string `json:"movie_id" binding:"required,hexadecimal,min=24"`
+	ParentID string `json:"parent_id" binding:"omitempty,hexadecimal,len=24"`
+	Text     string `json:"text" binding:"required,min=1"`
 }
 
 func (server *Server) createComment(ctx *gin.Context) {
@@ -32,29 +52,189 @@ func (server *Server) createComment(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
+	if !server.checkNotBanned(ctx, authPayload.Username) {
+		return
+	}
 	arg := db.AddCommentParams{
 		Name:    authPayload.Username,
 		Email:   req.Email,
 		MovieID: objectId,
 		Text:    req.Text,
 	}
-	id, err := server.store.AddComment(ctx, arg)
+	if req.ParentID != "" {
+		parentId, err := primitive.ObjectIDFromHex(req.ParentID)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		parent, err := server.store.GetComment(ctx, parentId)
+		if err != nil && mongo.ErrNoDocuments != err {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		if err != nil || parent.Deleted {
+			ctx.JSON(http.StatusNotFound, errorResponse(errParentNotFound))
+			return
+		}
+		if parent.MovieID != objectId {
+			ctx.JSON(http.StatusBadRequest, errorResponse(errParentOfOtherMovie))
+			return
+		}
+		if parent.Depth >= maxCommentDepth {
+			ctx.JSON(http.StatusBadRequest, errorResponse(errThreadTooDeep))
+			return
+		}
+		arg.ParentID = parentId
+		arg.Depth = parent.Depth + 1
+	}
+	result, ok := server.filterComment(ctx, filter.Input{Name: arg.Name, Text: arg.Text})
+	if !ok {
+		return
+	}
+	arg.Text = result.Text
+	arg.Flags = result.Flags
+
+	// The comment, the count of comments on the movie and the reward of the
+	// author, who only gets it for a comment that isn't held back, are written together
+	var id primitive.ObjectID
+	err = server.store.ExecTx(ctx, func(q db.Querier) error {
+		var err error
+		id, err = q.AddComment(ctx, arg)
+		if err != nil {
+			return err
+		}
+		if err = q.IncrementMovieComments(ctx, arg.MovieID, 1); err != nil || len(arg.Flags) > 0 {
+			return err
+		}
+		_, err = q.TransferCoins(ctx, reward(db.CoinEarnComment, id.Hex(), arg.Name, commentReward))
+		return err
+	})
 	if err != nil {
+		if mongo.ErrNoDocuments == err {
+			ctx.JSON(http.StatusNotFound, errorResponse(errMovieNotFound))
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
+	if len(arg.Flags) > 0 {
+		if err = server.logFlagged(ctx, id, arg.Name); err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+	}
+	comment := newCommentDocument(id, arg)
+	server.indexComment(comment)
+	server.publish(arg.MovieID, live.EventComment, newListCommentsResponse(comment))
 	ctx.JSON(http.StatusOK, gin.H{"id": id.Hex()})
 }
 
+// checkNotBanned makes sure the user named name may post comments and danmaku.
+// It writes the response and returns false when they may not
+func (server *Server) checkNotBanned(ctx *gin.Context, name string) bool {
+	user, err := server.store.GetUserByName(ctx, name)
+	if err != nil {
+		if mongo.ErrNoDocuments == err {
+			ctx.JSON(http.StatusUnauthorized, errorResponse(errUserNotFound))
+			return false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return false
+	}
+	if user.Banned {
+		ctx.JSON(http.StatusForbidden, errorResponse(errUserBanned))
+		return false
+	}
+	return true
+}
+
+// listCommentsRequest lists every comment of a movie, replies included,
+// or only the top-level ones with their first replies when Threaded
 type listCommentsRequest struct {
 	MovieID  string `form:"movie_id" binding:"required,hexadecimal,min=24"`
 	PageSize int64  `form:"s" binding:"required,min=1,max=20"`
 	PageId   int64  `form:"p" binding:"required,min=1"`
+	Threaded bool   `form:"threaded"`
+	Sort     string `form:"sort" binding:"omitempty,oneof=top new old"`
 }
+
+// ListCommentsResponse is a comment with its reactions, and for a signed-in
+// user the reactions they left on it
 type ListCommentsResponse struct {
-	Id   string `json:"id"`
-	Name string `json:"name"`
-	Text string `json:"text"`
+	Id          string                 `json:"id"`
+	ParentId    string                 `json:"parent_id,omitempty"`
+	Name        string                 `json:"name"`
+	Text        string                 `json:"text"`
+	Date        primitive.DateTime     `json:"date"`
+	Deleted     bool                   `json:"deleted,omitempty"`
+	Hidden      bool                   `json:"hidden,omitempty"`
+	Reactions   map[string]int64       `json:"reactions,omitempty"`
+	MyReactions []string               `json:"my_reactions,omitempty"`
+	ReplyCount  *int64                 `json:"reply_count,omitempty"`
+	Replies     []ListCommentsResponse `json:"replies,omitempty"`
+}
+
+func newListCommentsResponse(comment db.Comments) ListCommentsResponse {
+	rsp := ListCommentsResponse{
+		Id:      comment.ID.Hex(),
+		Name:    comment.Name,
+		Text:    comment.Text,
+		Date:    comment.Date,
+		Deleted: comment.Deleted,
+	}
+	// Taking back the last reaction of a kind leaves a zero count
+	for reaction, count := range comment.Reactions {
+		if count > 0 {
+			if rsp.Reactions == nil {
+				rsp.Reactions = make(map[string]int64)
+			}
+			rsp.Reactions[reaction] = count
+		}
+	}
+	if !comment.ParentID.IsZero() {
+		rsp.ParentId = comment.ParentID.Hex()
+	}
+	switch {
+	case comment.Deleted:
+		rsp.Name = deletedPlaceholder
+		rsp.Text = deletedPlaceholder
+	case comment.Hidden:
+		rsp.Name = hiddenPlaceholder
+		rsp.Text = hiddenPlaceholder
+		rsp.Hidden = true
+	}
+	return rsp
+}
+
+// withReplies adds to each comment its reply count and its first n replies
+func (server *Server) withReplies(ctx *gin.Context, comments []db.Comments, n int64) ([]ListCommentsResponse, error) {
+	var rsp []ListCommentsResponse
+	if len(comments) == 0 {
+		return rsp, nil
+	}
+	ids := make([]primitive.ObjectID, len(comments))
+	for i, comment := range comments {
+		ids[i] = comment.ID
+	}
+	summaries, err := server.store.GetReplySummaries(ctx, ids, n)
+	if err != nil {
+		return nil, err
+	}
+	byParent := make(map[primitive.ObjectID]db.ReplySummary, len(summaries))
+	for _, summary := range summaries {
+		byParent[summary.ParentID] = summary
+	}
+
+	for _, comment := range comments {
+		item := newListCommentsResponse(comment)
+		summary := byParent[comment.ID]
+		item.ReplyCount = &summary.Count
+		for _, reply := range summary.Replies {
+			item.Replies = append(item.Replies, newListCommentsResponse(reply))
+		}
+		rsp = append(rsp, item)
+	}
+	return rsp, nil
 }
 
 func (server *Server) listComments(ctx *gin.Context) {
@@ -68,10 +248,15 @@ func (server *Server) listComments(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
+	if req.Sort == "" {
+		req.Sort = db.CommentSortNew
+	}
 	arg := db.GetCommentsParams{
-		MovieID: objectId,
-		Skip:    req.PageSize * (req.PageId - 1),
-		Limit:   req.PageSize,
+		MovieID:  objectId,
+		TopLevel: req.Threaded,
+		Sort:     req.Sort,
+		Skip:     req.PageSize * (req.PageId - 1),
+		Limit:    req.PageSize,
 	}
 	comments, err := server.store.GetCommentsByMovieID(ctx, arg)
 	if err != nil {
@@ -79,12 +264,120 @@ func (server *Server) listComments(ctx *gin.Context) {
 		return
 	}
 	var rsp []ListCommentsResponse
-	for _, comment := range comments {
-		rsp = append(rsp, ListCommentsResponse{
-			Id:   comment.ID.Hex(),
-			Name: comment.Name,
-			Text: comment.Text,
-		})
+	if req.Threaded {
+		rsp, err = server.withReplies(ctx, comments, inlineReplies)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+	} else {
+		for _, comment := range comments {
+			rsp = append(rsp, newListCommentsResponse(comment))
+		}
+	}
+	if err = server.markMyReactions(ctx, rsp); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// markMyReactions sets the reactions the signed-in user left on the comments
+// and their replies, it does nothing for anonymous users
+func (server *Server) markMyReactions(ctx *gin.Context, comments []ListCommentsResponse) error {
+	payload, ok := ctx.Get(authorizationPayloadKey)
+	if !ok || len(comments) == 0 {
+		return nil
+	}
+	byId := make(map[string]*ListCommentsResponse)
+	var ids []primitive.ObjectID
+	var add func(comments []ListCommentsResponse) error
+	add = func(comments []ListCommentsResponse) error {
+		for i := range comments {
+			id, err := primitive.ObjectIDFromHex(comments[i].Id)
+			if err != nil {
+				return err
+			}
+			byId[comments[i].Id] = &comments[i]
+			ids = append(ids, id)
+			if err = add(comments[i].Replies); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := add(comments); err != nil {
+		return err
+	}
+
+	reactions, err := server.store.GetReactionsByName(ctx, payload.(*token.Payload).Username, ids)
+	if err != nil {
+		return err
+	}
+	for _, reaction := range reactions {
+		if comment, ok := byId[reaction.CommentID.Hex()]; ok {
+			comment.MyReactions = append(comment.MyReactions, reaction.Reaction)
+		}
+	}
+	return nil
+}
+
+type listRepliesRequest struct {
+	PageSize int64 `form:"s" binding:"required,min=1,max=20"`
+	PageId   int64 `form:"p" binding:"required,min=1"`
+}
+
+type commentUriRequest struct {
+	Id string `uri:"id" binding:"required,hexadecimal,len=24"`
+}
+
+// listReplies is to get a page of the replies to a comment, the oldest first,
+// with how many replies each of them has
+func (server *Server) listReplies(ctx *gin.Context) {
+	var uri commentUriRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req listRepliesRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	objectId, err := primitive.ObjectIDFromHex(uri.Id)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if _, err = server.store.GetComment(ctx, objectId); err != nil {
+		if mongo.ErrNoDocuments == err {
+			ctx.JSON(http.StatusNotFound, errorResponse(errCommentNotFound))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	replies, err := server.store.GetReplies(ctx, db.GetRepliesParams{
+		ParentID: objectId,
+		Skip:     req.PageSize * (req.PageId - 1),
+		Limit:    req.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	rsp, err := server.withReplies(ctx, replies, 0)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if err = server.markMyReactions(ctx, rsp); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if rsp == nil {
+		rsp = []ListCommentsResponse{}
 	}
 	ctx.JSON(http.StatusOK, rsp)
 }
@@ -109,10 +402,15 @@ func (server *Server) updateComment(ctx *gin.Context) {
 		return
 	}
 
+	result, ok := server.filterComment(ctx, filter.Input{Name: authPayload.Username, Text: req.Text, Edit: true})
+	if !ok {
+		return
+	}
 	arg := db.Comments{
-		ID:   objectId,
-		Name: authPayload.Username,
-		Text: req.Text,
+		ID:    objectId,
+		Name:  authPayload.Username,
+		Text:  result.Text,
+		Flags: result.Flags,
 	}
 	_, err = server.store.UpdateComment(ctx, arg)
 	if err != nil {
@@ -123,6 +421,13 @@ func (server *Server) updateComment(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
+	if len(arg.Flags) > 0 {
+		if err = server.logFlagged(ctx, objectId, arg.Name); err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+	}
+	server.reindexComment(ctx, objectId)
 	ctx.JSON(http.StatusOK, gin.H{"updated": "ok"})
 }
 
@@ -144,7 +449,25 @@ func (server *Server) deleteComment(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
-	_, err = server.store.DeleteComment(ctx, objectId, authPayload.Username)
+	// The comment, the count of comments on the movie and the reward of the
+	// comment are taken back together
+	err = server.store.ExecTx(ctx, func(q db.Querier) error {
+		comment, err := q.GetComment(ctx, objectId)
+		if err != nil {
+			return err
+		}
+		// A comment kept for its replies is off the count already
+		if comment.Deleted {
+			return mongo.ErrNoDocuments
+		}
+		if _, err = q.DeleteComment(ctx, objectId, authPayload.Username); err != nil {
+			return err
+		}
+		if err = q.IncrementMovieComments(ctx, comment.MovieID, -1); err != nil {
+			return err
+		}
+		return revokeCoins(ctx, q, db.CoinEarnComment, db.CoinRevokeComment, objectId.Hex())
+	})
 	if err != nil {
 		if mongo.ErrNoDocuments == err {
 			ctx.JSON(http.StatusNotFound, errorResponse(errCommentNotFound))
@@ -153,5 +476,77 @@ func (server *Server) deleteComment(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
+	server.removeComment(objectId)
+	ctx.JSON(http.StatusOK, gin.H{"deleted": "OK"})
+}
+
+type reactionUriRequest struct {
+	Id       string `uri:"id" binding:"required,hexadecimal,len=24"`
+	Reaction string `uri:"reaction" binding:"required,oneof=like love haha wow sad angry"`
+}
+
+// addReaction is to leave a reaction on a comment, leaving it again changes nothing
+func (server *Server) addReaction(ctx *gin.Context) {
+	var req reactionUriRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	objectId, err := primitive.ObjectIDFromHex(req.Id)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	comment, err := server.store.GetComment(ctx, objectId)
+	if err != nil && mongo.ErrNoDocuments != err {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if err != nil || comment.Deleted {
+		ctx.JSON(http.StatusNotFound, errorResponse(errCommentNotFound))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	_, err = server.store.AddReaction(ctx, db.CommentReactionParams{
+		CommentID: objectId,
+		Name:      authPayload.Username,
+		Reaction:  req.Reaction,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"added": "OK"})
+}
+
+// deleteReaction is to take back a reaction the signed-in user left on a comment
+func (server *Server) deleteReaction(ctx *gin.Context) {
+	var req reactionUriRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	objectId, err := primitive.ObjectIDFromHex(req.Id)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	err = server.store.DeleteReaction(ctx, db.CommentReactionParams{
+		CommentID: objectId,
+		Name:      authPayload.Username,
+		Reaction:  req.Reaction,
+	})
+	if err != nil {
+		if mongo.ErrNoDocuments == err {
+			ctx.JSON(http.StatusNotFound, errorResponse(errReactionNotFound))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
 	ctx.JSON(http.StatusOK, gin.H{"deleted": "OK"})
 }
@@ -47,13 +47,38 @@ func TestCreateCommentAPI(t *testing.T) {
 					MovieID: comment.MovieID,
 					Text:    comment.Text,
 				}
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("user")).Times(1).Return(db.User{Name: "user"}, nil)
+				expectTx(store)
 				store.EXPECT().AddComment(gomock.Any(), gomock.Eq(arg)).Times(1).Return(returnId, nil)
+				store.EXPECT().IncrementMovieComments(gomock.Any(), gomock.Eq(comment.MovieID), gomock.Eq(int64(1))).Times(1).Return(nil)
+				expectCommentReward(store, returnId, "user")
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
 				requireBodyMatchObjectId(t, returnId, recorder.Body)
 			},
 		},
+		{
+			name: "RewardFails",
+			body: gin.H{
+				"email":    comment.Email,
+				"movie_id": comment.MovieID.Hex(),
+				"text":     comment.Text,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "user", time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("user")).Times(1).Return(db.User{Name: "user"}, nil)
+				expectTx(store)
+				store.EXPECT().AddComment(gomock.Any(), gomock.Any()).Times(1).Return(returnId, nil)
+				store.EXPECT().IncrementMovieComments(gomock.Any(), gomock.Eq(comment.MovieID), gomock.Eq(int64(1))).Times(1).Return(nil)
+				store.EXPECT().TransferCoins(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferCoinsResult{}, mongo.ErrClientDisconnected)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
 		{
 			name: "InvalidEmail",
 			body: gin.H{
@@ -154,14 +179,76 @@ func TestCreateCommentAPI(t *testing.T) {
 					MovieID: comment.MovieID,
 					Text:    comment.Text,
 				}
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("user")).Times(1).Return(db.User{Name: "user"}, nil)
+				expectTx(store)
 				store.EXPECT().AddComment(gomock.Any(), gomock.Eq(arg)).
 					Times(1).
 					Return(returnId, mongo.ErrClientDisconnected)
+				store.EXPECT().IncrementMovieComments(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusInternalServerError, recorder.Code)
 			},
 		},
+		{
+			name: "MovieNotFound",
+			body: gin.H{
+				"email":    comment.Email,
+				"movie_id": comment.MovieID.Hex(),
+				"text":     comment.Text,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "user", time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("user")).Times(1).Return(db.User{Name: "user"}, nil)
+				expectTx(store)
+				store.EXPECT().AddComment(gomock.Any(), gomock.Any()).Times(1).Return(returnId, nil)
+				store.EXPECT().IncrementMovieComments(gomock.Any(), gomock.Eq(comment.MovieID), gomock.Eq(int64(1))).
+					Times(1).
+					Return(mongo.ErrNoDocuments)
+				store.EXPECT().TransferCoins(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "Banned",
+			body: gin.H{
+				"email":    comment.Email,
+				"movie_id": comment.MovieID.Hex(),
+				"text":     comment.Text,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "user", time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("user")).Times(1).Return(db.User{Name: "user", Banned: true}, nil)
+				store.EXPECT().AddComment(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "UserNotFound",
+			body: gin.H{
+				"email":    comment.Email,
+				"movie_id": comment.MovieID.Hex(),
+				"text":     comment.Text,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "user", time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Any()).Times(1).Return(db.User{}, mongo.ErrNoDocuments)
+				store.EXPECT().AddComment(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
 	}
 	for i := range testCase {
 		tc := testCase[i]
@@ -217,6 +304,7 @@ func TestListCommentsByMovieIDAPI(t *testing.T) {
 			buildStubs: func(store *mockdb.MockStore) {
 				arg := db.GetCommentsParams{
 					MovieID: movieId,
+					Sort:    db.CommentSortNew,
 					Skip:    5,
 					Limit:   5,
 				}
@@ -293,6 +381,7 @@ func TestListCommentsByMovieIDAPI(t *testing.T) {
 			buildStubs: func(store *mockdb.MockStore) {
 				arg := db.GetCommentsParams{
 					MovieID: movieId,
+					Sort:    db.CommentSortNew,
 					Skip:    5,
 					Limit:   5,
 				}
@@ -504,6 +593,7 @@ func TestUpdateCommentAPI(t *testing.T) {
 
 func TestDeleteCommentAPI(t *testing.T) {
 	commentId := primitive.NewObjectID()
+	comment := db.Comments{ID: commentId, MovieID: primitive.NewObjectID(), Name: "user"}
 	testCase := []struct {
 		name          string
 		body          gin.H
@@ -520,9 +610,15 @@ func TestDeleteCommentAPI(t *testing.T) {
 				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "user", time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
+				expectTx(store)
+				store.EXPECT().GetComment(gomock.Any(), gomock.Eq(commentId)).Times(1).Return(comment, nil)
 				store.EXPECT().DeleteComment(gomock.Any(), gomock.Eq(commentId), gomock.Eq("user")).
 					Times(1).
 					Return(int64(1), nil)
+				store.EXPECT().IncrementMovieComments(gomock.Any(), gomock.Eq(comment.MovieID), gomock.Eq(int64(-1))).
+					Times(1).
+					Return(nil)
+				expectRevoke(store, db.CoinEarnComment, db.CoinRevokeComment, commentId.Hex(), nil)
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
@@ -587,9 +683,51 @@ func TestDeleteCommentAPI(t *testing.T) {
 				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "user", time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
+				expectTx(store)
+				store.EXPECT().GetComment(gomock.Any(), gomock.Eq(commentId)).Times(1).Return(comment, nil)
 				store.EXPECT().DeleteComment(gomock.Any(), gomock.Eq(commentId), gomock.Eq("user")).
 					Times(1).
 					Return(int64(0), mongo.ErrNoDocuments)
+				store.EXPECT().IncrementMovieComments(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "RevokeFails",
+			body: gin.H{
+				"id": commentId.Hex(),
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "user", time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				expectTx(store)
+				store.EXPECT().GetComment(gomock.Any(), gomock.Eq(commentId)).Times(1).Return(comment, nil)
+				store.EXPECT().DeleteComment(gomock.Any(), gomock.Eq(commentId), gomock.Eq("user")).Times(1).Return(int64(1), nil)
+				store.EXPECT().IncrementMovieComments(gomock.Any(), gomock.Eq(comment.MovieID), gomock.Eq(int64(-1))).Times(1).Return(nil)
+				expectRevoke(store, db.CoinEarnComment, db.CoinRevokeComment, commentId.Hex(), mongo.ErrClientDisconnected)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name: "DeletedAlready",
+			body: gin.H{
+				"id": commentId.Hex(),
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "user", time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				deleted := comment
+				deleted.Deleted = true
+				expectTx(store)
+				store.EXPECT().GetComment(gomock.Any(), gomock.Eq(commentId)).Times(1).Return(deleted, nil)
+				store.EXPECT().DeleteComment(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().IncrementMovieComments(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusNotFound, recorder.Code)
@@ -604,9 +742,12 @@ func TestDeleteCommentAPI(t *testing.T) {
 				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "user", time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
+				expectTx(store)
+				store.EXPECT().GetComment(gomock.Any(), gomock.Eq(commentId)).Times(1).Return(comment, nil)
 				store.EXPECT().DeleteComment(gomock.Any(), gomock.Eq(commentId), gomock.Eq("user")).
 					Times(1).
 					Return(int64(0), mongo.ErrClientDisconnected)
+				store.EXPECT().IncrementMovieComments(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusInternalServerError, recorder.Code)
@@ -660,3 +801,425 @@ func requireBodyMatchComments(t *testing.T, returnComments []db.Comments, body *
 	}
 
 }
+
+func TestCreateReplyAPI(t *testing.T) {
+	movieId := primitive.NewObjectID()
+	parent := db.Comments{
+		ID:      primitive.NewObjectID(),
+		Name:    util.RandomUser(),
+		MovieID: movieId,
+		Depth:   1,
+		Text:    util.RandomString(10),
+	}
+
+	testCase := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"movie_id": movieId.Hex(), "parent_id": parent.ID.Hex(), "text": "reply"},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.AddCommentParams{Name: "user", MovieID: movieId, ParentID: parent.ID, Depth: 2, Text: "reply"}
+				replyId := primitive.NewObjectID()
+				store.EXPECT().GetComment(gomock.Any(), gomock.Eq(parent.ID)).Times(1).Return(parent, nil)
+				expectTx(store)
+				store.EXPECT().AddComment(gomock.Any(), gomock.Eq(arg)).Times(1).Return(replyId, nil)
+				store.EXPECT().IncrementMovieComments(gomock.Any(), gomock.Eq(movieId), gomock.Eq(int64(1))).Times(1).Return(nil)
+				expectCommentReward(store, replyId, "user")
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "ParentNotFound",
+			body: gin.H{"movie_id": movieId.Hex(), "parent_id": parent.ID.Hex(), "text": "reply"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetComment(gomock.Any(), gomock.Eq(parent.ID)).Times(1).Return(db.Comments{}, mongo.ErrNoDocuments)
+				store.EXPECT().AddComment(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "ParentDeleted",
+			body: gin.H{"movie_id": movieId.Hex(), "parent_id": parent.ID.Hex(), "text": "reply"},
+			buildStubs: func(store *mockdb.MockStore) {
+				deleted := parent
+				deleted.Deleted = true
+				store.EXPECT().GetComment(gomock.Any(), gomock.Eq(parent.ID)).Times(1).Return(deleted, nil)
+				store.EXPECT().AddComment(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "ParentOfOtherMovie",
+			body: gin.H{"movie_id": primitive.NewObjectID().Hex(), "parent_id": parent.ID.Hex(), "text": "reply"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetComment(gomock.Any(), gomock.Eq(parent.ID)).Times(1).Return(parent, nil)
+				store.EXPECT().AddComment(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "TooDeep",
+			body: gin.H{"movie_id": movieId.Hex(), "parent_id": parent.ID.Hex(), "text": "reply"},
+			buildStubs: func(store *mockdb.MockStore) {
+				deepest := parent
+				deepest.Depth = maxCommentDepth
+				store.EXPECT().GetComment(gomock.Any(), gomock.Eq(parent.ID)).Times(1).Return(deepest, nil)
+				store.EXPECT().AddComment(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidParentId",
+			body: gin.H{"movie_id": movieId.Hex(), "parent_id": "123", "text": "reply"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetComment(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().GetUserByName(gomock.Any(), gomock.Any()).AnyTimes().Return(db.User{Name: "user"}, nil)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := sendAuthorizedJSON(t, server, http.MethodPost, "/comments", tc.body)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestListCommentsThreadedAPI(t *testing.T) {
+	movieId := primitive.NewObjectID()
+	parent := db.Comments{ID: primitive.NewObjectID(), Name: util.RandomUser(), MovieID: movieId, Deleted: true}
+	lonely := db.Comments{ID: primitive.NewObjectID(), Name: util.RandomUser(), MovieID: movieId, Text: util.RandomString(10)}
+	reply := db.Comments{ID: primitive.NewObjectID(), Name: util.RandomUser(), MovieID: movieId, ParentID: parent.ID, Depth: 1, Text: util.RandomString(10)}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	arg := db.GetCommentsParams{MovieID: movieId, TopLevel: true, Sort: db.CommentSortTop, Skip: 0, Limit: 10}
+	store.EXPECT().GetCommentsByMovieID(gomock.Any(), gomock.Eq(arg)).Times(1).Return([]db.Comments{parent, lonely}, nil)
+	summaries := []db.ReplySummary{{ParentID: parent.ID, Count: 4, Replies: []db.Comments{reply}}}
+	store.EXPECT().GetReplySummaries(gomock.Any(), gomock.Eq([]primitive.ObjectID{parent.ID, lonely.ID}), gomock.Eq(int64(inlineReplies))).
+		Times(1).
+		Return(summaries, nil)
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+	url := fmt.Sprintf("/comments?movie_id=%s&s=10&p=1&threaded=true&sort=top", movieId.Hex())
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	server.router.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var rsp []ListCommentsResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+	require.Len(t, rsp, 2)
+	require.Equal(t, deletedPlaceholder, rsp[0].Name)
+	require.Equal(t, deletedPlaceholder, rsp[0].Text)
+	require.Equal(t, int64(4), *rsp[0].ReplyCount)
+	require.Len(t, rsp[0].Replies, 1)
+	require.Equal(t, reply.ID.Hex(), rsp[0].Replies[0].Id)
+	require.Equal(t, parent.ID.Hex(), rsp[0].Replies[0].ParentId)
+	require.Equal(t, int64(0), *rsp[1].ReplyCount)
+	require.Empty(t, rsp[1].Replies)
+}
+
+func TestListRepliesAPI(t *testing.T) {
+	parent := db.Comments{ID: primitive.NewObjectID(), Name: util.RandomUser(), Text: util.RandomString(10)}
+	replies := []db.Comments{
+		{ID: primitive.NewObjectID(), Name: util.RandomUser(), ParentID: parent.ID, Depth: 1, Text: util.RandomString(10)},
+		{ID: primitive.NewObjectID(), Name: util.RandomUser(), ParentID: parent.ID, Depth: 1, Text: util.RandomString(10)},
+	}
+
+	testCase := []struct {
+		name          string
+		id            string
+		query         string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			id:    parent.ID.Hex(),
+			query: "?s=2&p=2",
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.GetRepliesParams{ParentID: parent.ID, Skip: 2, Limit: 2}
+				store.EXPECT().GetComment(gomock.Any(), gomock.Eq(parent.ID)).Times(1).Return(parent, nil)
+				store.EXPECT().GetReplies(gomock.Any(), gomock.Eq(arg)).Times(1).Return(replies, nil)
+				summaries := []db.ReplySummary{{ParentID: replies[1].ID, Count: 1}}
+				store.EXPECT().GetReplySummaries(gomock.Any(), gomock.Len(2), gomock.Eq(int64(0))).Times(1).Return(summaries, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp []ListCommentsResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Len(t, rsp, 2)
+				require.Equal(t, replies[0].Name, rsp[0].Name)
+				require.Equal(t, int64(0), *rsp[0].ReplyCount)
+				require.Equal(t, int64(1), *rsp[1].ReplyCount)
+			},
+		},
+		{
+			name:  "NoReplies",
+			id:    parent.ID.Hex(),
+			query: "?s=2&p=1",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetComment(gomock.Any(), gomock.Eq(parent.ID)).Times(1).Return(parent, nil)
+				store.EXPECT().GetReplies(gomock.Any(), gomock.Any()).Times(1).Return(nil, nil)
+				store.EXPECT().GetReplySummaries(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.JSONEq(t, "[]", recorder.Body.String())
+			},
+		},
+		{
+			name:  "NotFound",
+			id:    parent.ID.Hex(),
+			query: "?s=2&p=1",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetComment(gomock.Any(), gomock.Eq(parent.ID)).Times(1).Return(db.Comments{}, mongo.ErrNoDocuments)
+				store.EXPECT().GetReplies(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:  "InvalidId",
+			id:    util.RandomString(24),
+			query: "?s=2&p=1",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetComment(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "InternalError",
+			id:    parent.ID.Hex(),
+			query: "?s=2&p=1",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetComment(gomock.Any(), gomock.Eq(parent.ID)).Times(1).Return(parent, nil)
+				store.EXPECT().GetReplies(gomock.Any(), gomock.Any()).Times(1).Return(nil, mongo.ErrClientDisconnected)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, "/comments/"+tc.id+"/replies"+tc.query, nil)
+			require.NoError(t, err)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestAddReactionAPI(t *testing.T) {
+	comment := db.Comments{ID: primitive.NewObjectID(), Name: util.RandomUser(), Text: util.RandomString(10)}
+
+	testCase := []struct {
+		name          string
+		url           string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			url:  "/comments/" + comment.ID.Hex() + "/reactions/like",
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.CommentReactionParams{CommentID: comment.ID, Name: "user", Reaction: db.ReactionLike}
+				store.EXPECT().GetComment(gomock.Any(), gomock.Eq(comment.ID)).Times(1).Return(comment, nil)
+				store.EXPECT().AddReaction(gomock.Any(), gomock.Eq(arg)).Times(1).Return(true, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "AlreadyReacted",
+			url:  "/comments/" + comment.ID.Hex() + "/reactions/haha",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetComment(gomock.Any(), gomock.Eq(comment.ID)).Times(1).Return(comment, nil)
+				store.EXPECT().AddReaction(gomock.Any(), gomock.Any()).Times(1).Return(false, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "UnknownReaction",
+			url:  "/comments/" + comment.ID.Hex() + "/reactions/meh",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetComment(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "CommentDeleted",
+			url:  "/comments/" + comment.ID.Hex() + "/reactions/like",
+			buildStubs: func(store *mockdb.MockStore) {
+				deleted := comment
+				deleted.Deleted = true
+				store.EXPECT().GetComment(gomock.Any(), gomock.Eq(comment.ID)).Times(1).Return(deleted, nil)
+				store.EXPECT().AddReaction(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "CommentNotFound",
+			url:  "/comments/" + comment.ID.Hex() + "/reactions/like",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetComment(gomock.Any(), gomock.Eq(comment.ID)).Times(1).Return(db.Comments{}, mongo.ErrNoDocuments)
+				store.EXPECT().AddReaction(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "InternalError",
+			url:  "/comments/" + comment.ID.Hex() + "/reactions/like",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetComment(gomock.Any(), gomock.Eq(comment.ID)).Times(1).Return(comment, nil)
+				store.EXPECT().AddReaction(gomock.Any(), gomock.Any()).Times(1).Return(false, mongo.ErrClientDisconnected)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := sendAuthorizedJSON(t, server, http.MethodPut, tc.url, nil)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestDeleteReactionAPI(t *testing.T) {
+	commentId := primitive.NewObjectID()
+
+	testCase := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.CommentReactionParams{CommentID: commentId, Name: "user", Reaction: db.ReactionLove}
+				store.EXPECT().DeleteReaction(gomock.Any(), gomock.Eq(arg)).Times(1).Return(nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "NotFound",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().DeleteReaction(gomock.Any(), gomock.Any()).Times(1).Return(mongo.ErrNoDocuments)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := sendAuthorizedJSON(t, server, http.MethodDelete, "/comments/"+commentId.Hex()+"/reactions/love", nil)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestListCommentsWithMyReactionsAPI(t *testing.T) {
+	movieId := primitive.NewObjectID()
+	comments := []db.Comments{
+		{
+			ID:            primitive.NewObjectID(),
+			Name:          util.RandomUser(),
+			MovieID:       movieId,
+			Reactions:     map[string]int64{db.ReactionLike: 2, db.ReactionSad: 0},
+			ReactionCount: 2,
+		},
+		{ID: primitive.NewObjectID(), Name: util.RandomUser(), MovieID: movieId},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	arg := db.GetCommentsParams{MovieID: movieId, Sort: db.CommentSortOld, Skip: 0, Limit: 10}
+	store.EXPECT().GetCommentsByMovieID(gomock.Any(), gomock.Eq(arg)).Times(1).Return(comments, nil)
+	reactions := []db.CommentReaction{{CommentID: comments[0].ID, Name: "user", Reaction: db.ReactionLike}}
+	store.EXPECT().GetReactionsByName(gomock.Any(), gomock.Eq("user"), gomock.Len(2)).Times(1).Return(reactions, nil)
+
+	server := newTestServer(t, store)
+	url := fmt.Sprintf("/comments?movie_id=%s&s=10&p=1&sort=old", movieId.Hex())
+	recorder := sendAuthorizedJSON(t, server, http.MethodGet, url, nil)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var rsp []ListCommentsResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+	require.Len(t, rsp, 2)
+	require.Equal(t, map[string]int64{db.ReactionLike: 2}, rsp[0].Reactions)
+	require.Equal(t, []string{db.ReactionLike}, rsp[0].MyReactions)
+	require.Empty(t, rsp[1].Reactions)
+	require.Empty(t, rsp[1].MyReactions)
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	db "phantom/db/mongo"
+	"phantom/filter"
+	"phantom/live"
+	"phantom/token"
+	"strings"
+	"time"
+)
+
+// The player prefetches the danmaku of up to maxDanmakuWindow milliseconds at a time.
+// Each second of it shows at most danmakuPerSecond of them
+const (
+	maxDanmakuWindow = int64(5 * time.Minute / time.Millisecond)
+	danmakuPerSecond = 15
+	danmakuBucket    = int64(time.Second / time.Millisecond)
+)
+
+// defaultDanmakuColor is the colour of a danmaku that doesn't set one
+const defaultDanmakuColor = "#ffffff"
+
+var errDanmakuWindow = errors.New("the window is longer than 5 minutes")
+var errOffsetPastEnd = errors.New("the offset is past the end")
+
+type createDanmakuRequest struct {
+	// Offset is in milliseconds
+	Offset int64  `json:"offset" binding:"min=0"`
+	Text   string `json:"text" binding:"required,min=1,max=100"`
+	Color  string `json:"color" binding:"omitempty,hexcolor"`
+	Mode   string `json:"mode" binding:"omitempty,oneof=scroll top bottom"`
+}
+
+type danmakuResponse struct {
+	Id     string             `json:"id"`
+	Offset int64              `json:"offset"`
+	Text   string             `json:"text"`
+	Color  string             `json:"color"`
+	Mode   string             `json:"mode"`
+	Name   string             `json:"name"`
+	Date   primitive.DateTime `json:"date"`
+	Pinned bool               `json:"pinned"`
+}
+
+func newDanmakuResponse(danmaku db.Danmaku) danmakuResponse {
+	return danmakuResponse{
+		Id:     danmaku.ID.Hex(),
+		Offset: danmaku.Offset,
+		Text:   danmaku.Text,
+		Color:  danmaku.Color,
+		Mode:   danmaku.Mode,
+		Name:   danmaku.Name,
+		Date:   danmaku.Date,
+		Pinned: danmaku.Pinned,
+	}
+}
+
+// createDanmaku is for the signed-in user to post a danmaku on a movie or an episode,
+// it goes through the same content filter as comments
+func (server *Server) createDanmaku(ctx *gin.Context) {
+	var uri movieIdRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req createDanmakuRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	movieID, err := primitive.ObjectIDFromHex(uri.Id)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if !server.checkNotBanned(ctx, authPayload.Username) {
+		return
+	}
+	playable, ok := server.findPlayable(ctx, movieID)
+	if !ok {
+		return
+	}
+	if playable.Runtime > 0 && req.Offset > int64(time.Duration(playable.Runtime)*time.Minute/time.Millisecond) {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errOffsetPastEnd))
+		return
+	}
+	result, ok := server.filterComment(ctx, filter.Input{Name: authPayload.Username, Text: req.Text})
+	if !ok {
+		return
+	}
+
+	arg := db.AddDanmakuParams{
+		MovieID: movieID,
+		Offset:  req.Offset,
+		Text:    result.Text,
+		Color:   strings.ToLower(req.Color),
+		Mode:    req.Mode,
+		Name:    authPayload.Username,
+		Flags:   result.Flags,
+	}
+	if arg.Color == "" {
+		arg.Color = defaultDanmakuColor
+	}
+	if arg.Mode == "" {
+		arg.Mode = db.DanmakuScroll
+	}
+	danmaku, err := server.store.AddDanmaku(ctx, arg)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	rsp := newDanmakuResponse(danmaku)
+	// Like listDanmaku, the viewers don't see the flagged ones, which wait
+	// in the moderation queue
+	if len(danmaku.Flags) > 0 {
+		if err = server.logFlaggedDanmaku(ctx, danmaku.ID, arg.Name); err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+	} else {
+		server.publish(movieID, live.EventDanmaku, rsp)
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// listDanmakuRequest is a time window of a movie, in milliseconds
+type listDanmakuRequest struct {
+	From int64 `form:"from" binding:"min=0"`
+	To   int64 `form:"to" binding:"required,gtfield=From"`
+}
+
+// listDanmaku is to get the danmaku of a time window of a movie or an episode
+// in the order they show, so that the player can prefetch them
+func (server *Server) listDanmaku(ctx *gin.Context) {
+	var uri movieIdRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req listDanmakuRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if req.To-req.From > maxDanmakuWindow {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errDanmakuWindow))
+		return
+	}
+	movieID, err := primitive.ObjectIDFromHex(uri.Id)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	danmaku, err := server.store.GetDanmaku(ctx, db.GetDanmakuParams{
+		MovieID:   movieID,
+		From:      req.From,
+		To:        req.To,
+		Bucket:    danmakuBucket,
+		PerBucket: danmakuPerSecond,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	rsp := make([]danmakuResponse, len(danmaku))
+	for i, d := range danmaku {
+		rsp[i] = newDanmakuResponse(d)
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
@@ -0,0 +1,296 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"net/http/httptest"
+	mockdb "phantom/db/mock"
+	db "phantom/db/mongo"
+	"phantom/util"
+	"testing"
+)
+
+func TestCreateDanmakuAPI(t *testing.T) {
+	movie := db.Movies{Id: primitive.NewObjectID(), Title: util.RandomString(8), Runtime: 100}
+	series := randomSeries()
+	episode := randomEpisodes(series)[0]
+
+	testCase := []struct {
+		name          string
+		id            string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			id:   movie.Id.Hex(),
+			body: gin.H{"offset": 61500, "text": "前方高能", "color": "#FF0000", "mode": db.DanmakuTop},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.AddDanmakuParams{
+					MovieID: movie.Id,
+					Offset:  61500,
+					Text:    "前方高能",
+					Color:   "#ff0000",
+					Mode:    db.DanmakuTop,
+					Name:    "user",
+				}
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("user")).Times(1).Return(db.User{Name: "user"}, nil)
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(movie.Id)).Times(1).Return(movie, nil)
+				store.EXPECT().AddDanmaku(gomock.Any(), gomock.Eq(arg)).Times(1).
+					Return(db.Danmaku{ID: primitive.NewObjectID(), MovieID: movie.Id, Offset: 61500, Text: "前方高能"}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp danmakuResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, int64(61500), rsp.Offset)
+			},
+		},
+		{
+			name: "EpisodeWithDefaults",
+			id:   episode.ID.Hex(),
+			body: gin.H{"offset": 0, "text": "what a shit opening"},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.AddDanmakuParams{
+					MovieID: episode.ID,
+					Text:    "what a *** opening",
+					Color:   defaultDanmakuColor,
+					Mode:    db.DanmakuScroll,
+					Name:    "user",
+				}
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("user")).Times(1).Return(db.User{Name: "user"}, nil)
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(episode.ID)).Times(1).Return(db.Movies{}, mongo.ErrNoDocuments)
+				store.EXPECT().GetEpisodeByID(gomock.Any(), gomock.Eq(episode.ID)).Times(1).Return(episode, nil)
+				store.EXPECT().AddDanmaku(gomock.Any(), gomock.Eq(arg)).Times(1).Return(db.Danmaku{ID: primitive.NewObjectID()}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "Flagged",
+			id:   movie.Id.Hex(),
+			body: gin.H{"offset": 1000, "text": "剧透：结局他死了"},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.AddDanmakuParams{
+					MovieID: movie.Id,
+					Offset:  1000,
+					Text:    "剧透：结局他死了",
+					Color:   defaultDanmakuColor,
+					Mode:    db.DanmakuScroll,
+					Name:    "user",
+					Flags:   []string{"blocked word"},
+				}
+				danmaku := db.Danmaku{ID: primitive.NewObjectID(), MovieID: movie.Id, Offset: 1000, Text: arg.Text, Flags: arg.Flags}
+				log := db.AddModerationLogParams{
+					Moderator: db.ModeratorSystem,
+					Action:    db.ModerationFlag,
+					DanmakuID: danmaku.ID,
+					Author:    "user",
+				}
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("user")).Times(1).Return(db.User{Name: "user"}, nil)
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(movie.Id)).Times(1).Return(movie, nil)
+				store.EXPECT().AddDanmaku(gomock.Any(), gomock.Eq(arg)).Times(1).Return(danmaku, nil)
+				store.EXPECT().AddModerationLog(gomock.Any(), gomock.Eq(log)).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "PastTheEnd",
+			id:   movie.Id.Hex(),
+			body: gin.H{"offset": 100*60*1000 + 1, "text": "still here?"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("user")).Times(1).Return(db.User{Name: "user"}, nil)
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(movie.Id)).Times(1).Return(movie, nil)
+				store.EXPECT().AddDanmaku(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "Banned",
+			id:   movie.Id.Hex(),
+			body: gin.H{"offset": 1000, "text": "hi"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("user")).Times(1).Return(db.User{Name: "user", Banned: true}, nil)
+				store.EXPECT().AddDanmaku(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "Rejected",
+			id:   movie.Id.Hex(),
+			body: gin.H{"offset": 1000, "text": "刷粉加我"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("user")).Times(1).Return(db.User{Name: "user"}, nil)
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(movie.Id)).Times(1).Return(movie, nil)
+				store.EXPECT().AddDanmaku(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "NotFound",
+			id:   movie.Id.Hex(),
+			body: gin.H{"offset": 1000, "text": "hi"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("user")).Times(1).Return(db.User{Name: "user"}, nil)
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Any()).Times(1).Return(db.Movies{}, mongo.ErrNoDocuments)
+				store.EXPECT().GetEpisodeByID(gomock.Any(), gomock.Any()).Times(1).Return(db.Episode{}, mongo.ErrNoDocuments)
+				store.EXPECT().AddDanmaku(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidMode",
+			id:   movie.Id.Hex(),
+			body: gin.H{"offset": 1000, "text": "hi", "mode": "diagonal"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidColor",
+			id:   movie.Id.Hex(),
+			body: gin.H{"offset": 1000, "text": "hi", "color": "red"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			url := fmt.Sprintf("/movies/%s/danmaku", tc.id)
+			recorder := sendAuthorizedJSON(t, server, http.MethodPost, url, tc.body)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestListDanmakuAPI(t *testing.T) {
+	movieID := primitive.NewObjectID()
+	danmaku := []db.Danmaku{
+		{ID: primitive.NewObjectID(), MovieID: movieID, Offset: 1000, Text: "a", Mode: db.DanmakuScroll},
+		{ID: primitive.NewObjectID(), MovieID: movieID, Offset: 2500, Text: "b", Mode: db.DanmakuBottom},
+	}
+
+	testCase := []struct {
+		name          string
+		query         string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			query: "?from=0&to=30000",
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.GetDanmakuParams{
+					MovieID:   movieID,
+					From:      0,
+					To:        30000,
+					Bucket:    1000,
+					PerBucket: danmakuPerSecond,
+				}
+				store.EXPECT().GetDanmaku(gomock.Any(), gomock.Eq(arg)).Times(1).Return(danmaku, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp []danmakuResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Len(t, rsp, 2)
+				require.Equal(t, danmaku[1].ID.Hex(), rsp[1].Id)
+				require.Equal(t, db.DanmakuBottom, rsp[1].Mode)
+			},
+		},
+		{
+			name:  "Empty",
+			query: "?from=60000&to=90000",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetDanmaku(gomock.Any(), gomock.Any()).Times(1).Return(nil, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Equal(t, "[]", recorder.Body.String())
+			},
+		},
+		{
+			name:  "WindowTooLong",
+			query: fmt.Sprintf("?from=0&to=%d", maxDanmakuWindow+1),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetDanmaku(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "ToBeforeFrom",
+			query: "?from=5000&to=1000",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetDanmaku(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "InternalError",
+			query: "?from=0&to=1000",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetDanmaku(gomock.Any(), gomock.Any()).Times(1).Return(nil, mongo.ErrClientDisconnected)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := httptest.NewRecorder()
+			url := fmt.Sprintf("/movies/%s/danmaku%s", movieID.Hex(), tc.query)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
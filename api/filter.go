@@ -0,0 +1,57 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	db "phantom/db/mongo"
+	"phantom/filter"
+)
+
+var errCommentRejected = errors.New("Comment is rejected")
+
+// newCommentFilter creates the content filter of comments, with the blocklists
+// of dir or the bundled ones
+func newCommentFilter(dir string) (*filter.Pipeline, error) {
+	blocklist, err := filter.LoadBlocklist(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot load the blocklists")
+	}
+	config := filter.DefaultConfig
+	config.Blocklist = blocklist
+	return filter.Default(config), nil
+}
+
+// filterComment runs the text of a comment through the content filter.
+// It writes the response and returns false when the comment is rejected
+func (server *Server) filterComment(ctx *gin.Context, in filter.Input) (filter.Result, bool) {
+	result := server.commentFilter.Run(in)
+	if result.Rejected {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.Wrap(errCommentRejected, result.Reason)))
+		return result, false
+	}
+	return result, true
+}
+
+// logFlagged records in the moderation log that the content filter
+// sent a comment to the moderation queue
+func (server *Server) logFlagged(ctx *gin.Context, id primitive.ObjectID, author string) error {
+	return server.store.AddModerationLog(ctx, db.AddModerationLogParams{
+		Moderator: db.ModeratorSystem,
+		Action:    db.ModerationFlag,
+		CommentID: id,
+		Author:    author,
+	})
+}
+
+// logFlaggedDanmaku records in the moderation log that the content filter
+// sent a danmaku to the moderation queue, like logFlagged
+func (server *Server) logFlaggedDanmaku(ctx *gin.Context, id primitive.ObjectID, author string) error {
+	return server.store.AddModerationLog(ctx, db.AddModerationLogParams{
+		Moderator: db.ModeratorSystem,
+		Action:    db.ModerationFlag,
+		DanmakuID: id,
+		Author:    author,
+	})
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"net/http/httptest"
+	mockdb "phantom/db/mock"
+	db "phantom/db/mongo"
+	"testing"
+)
+
+func TestCreateCommentFilteredAPI(t *testing.T) {
+	movieId := primitive.NewObjectID()
+	returnId := primitive.NewObjectID()
+
+	testCase := []struct {
+		name          string
+		text          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "Masked",
+			text: "What a SHIT movie",
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.AddCommentParams{Name: "user", MovieID: movieId, Text: "What a *** movie"}
+				expectTx(store)
+				store.EXPECT().AddComment(gomock.Any(), gomock.Eq(arg)).Times(1).Return(returnId, nil)
+				store.EXPECT().IncrementMovieComments(gomock.Any(), gomock.Eq(movieId), gomock.Eq(int64(1))).Times(1).Return(nil)
+				store.EXPECT().AddModerationLog(gomock.Any(), gomock.Any()).Times(0)
+				expectCommentReward(store, returnId, "user")
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "Flagged",
+			text: "剧透：结局他死了",
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.AddCommentParams{Name: "user", MovieID: movieId, Text: "剧透：结局他死了", Flags: []string{"blocked word"}}
+				log := db.AddModerationLogParams{
+					Moderator: db.ModeratorSystem,
+					Action:    db.ModerationFlag,
+					CommentID: returnId,
+					Author:    "user",
+				}
+				expectTx(store)
+				store.EXPECT().AddComment(gomock.Any(), gomock.Eq(arg)).Times(1).Return(returnId, nil)
+				store.EXPECT().IncrementMovieComments(gomock.Any(), gomock.Eq(movieId), gomock.Eq(int64(1))).Times(1).Return(nil)
+				store.EXPECT().AddModerationLog(gomock.Any(), gomock.Eq(log)).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "Rejected",
+			text: "Free money at http://a.com",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().AddComment(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().GetUserByName(gomock.Any(), gomock.Any()).AnyTimes().Return(db.User{Name: "user"}, nil)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			body := gin.H{"movie_id": movieId.Hex(), "text": tc.text}
+			recorder := sendAuthorizedJSON(t, server, http.MethodPost, "/comments", body)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestCreateCommentFloodAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetUserByName(gomock.Any(), gomock.Any()).AnyTimes().Return(db.User{Name: "user"}, nil)
+	expectTx(store)
+	store.EXPECT().AddComment(gomock.Any(), gomock.Any()).Times(1).Return(primitive.NewObjectID(), nil)
+	store.EXPECT().IncrementMovieComments(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(nil)
+	store.EXPECT().TransferCoins(gomock.Any(), gomock.Any()).Times(1)
+	server := newTestServer(t, store)
+
+	body := gin.H{"movie_id": primitive.NewObjectID().Hex(), "text": "First!"}
+	recorder := sendAuthorizedJSON(t, server, http.MethodPost, "/comments", body)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	recorder = sendAuthorizedJSON(t, server, http.MethodPost, "/comments", body)
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestUpdateCommentFilteredAPI(t *testing.T) {
+	id := primitive.NewObjectID()
+
+	testCase := []struct {
+		name          string
+		text          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "Flagged",
+			text: "spoiler: he dies at the end",
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.Comments{ID: id, Name: "user", Text: "spoiler: he dies at the end", Flags: []string{"blocked word"}}
+				store.EXPECT().UpdateComment(gomock.Any(), gomock.Eq(arg)).Times(1).Return(nil, nil)
+				store.EXPECT().AddModerationLog(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "Rejected",
+			text: "刷粉找我",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().UpdateComment(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			body := gin.H{"id": id.Hex(), "text": tc.text}
+			recorder := sendAuthorizedJSON(t, server, http.MethodPut, "/comments", body)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	db "phantom/db/mongo"
+	"phantom/token"
+	"time"
+)
+
+// statsTop is how many genres, directors and actors the stats list
+const statsTop = 5
+
+var errHistoryEntryNotFound = errors.New("history entry not found")
+
+// historyResponse is a movie or an episode the user watched, and when
+type historyResponse struct {
+	ID string `json:"id"`
+	media
+	WatchedAt primitive.DateTime `json:"watched_at"`
+}
+
+// getHistory is to get a page of what the signed-in user watched, the latest first
+func (server *Server) getHistory(ctx *gin.Context) {
+	var req getMoviesRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	entries, err := server.store.GetHistory(ctx, db.GetHistoryParams{
+		Name:  authPayload.Username,
+		Skip:  req.PageSize * (req.PageId - 1),
+		Limit: req.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	rsp := []historyResponse{}
+	if len(entries) == 0 {
+		ctx.JSON(http.StatusOK, rsp)
+		return
+	}
+
+	refs := make([]mediaRef, len(entries))
+	for i, entry := range entries {
+		refs[i] = mediaRef{MediaID: entry.MediaID, SeriesID: entry.SeriesID}
+	}
+	found, err := server.findMedia(ctx, refs)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	for i, entry := range entries {
+		if found[i] == nil {
+			continue
+		}
+		rsp = append(rsp, historyResponse{
+			ID:        entry.ID.Hex(),
+			media:     *found[i],
+			WatchedAt: entry.WatchedAt,
+		})
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+type historyEntryRequest struct {
+	ID string `uri:"id" binding:"required,hexadecimal,len=24"`
+}
+
+// deleteHistoryEntry is to remove an entry from the history of the signed-in user,
+// it no longer counts in their stats
+func (server *Server) deleteHistoryEntry(ctx *gin.Context) {
+	var req historyEntryRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(req.ID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	err = server.store.DeleteHistoryEntry(ctx, authPayload.Username, id)
+	if err != nil {
+		if mongo.ErrNoDocuments == err {
+			ctx.JSON(http.StatusNotFound, errorResponse(errHistoryEntryNotFound))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"deleted": "OK"})
+}
+
+type getStatsRequest struct {
+	// Year limits the stats to what was watched that year, in UTC
+	Year int `form:"year" binding:"omitempty,min=1900,max=9999"`
+}
+
+// getStats is to get the viewing stats of the signed-in user, of all time or of a year
+func (server *Server) getStats(ctx *gin.Context) {
+	var req getStatsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	arg := db.GetUserStatsParams{Name: authPayload.Username, Top: statsTop}
+	if req.Year != 0 {
+		arg.From = time.Date(req.Year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		arg.To = arg.From.AddDate(1, 0, 0)
+	}
+	stats, err := server.store.GetUserStats(ctx, arg)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,230 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"net/http/httptest"
+	mockdb "phantom/db/mock"
+	db "phantom/db/mongo"
+	"phantom/util"
+	"testing"
+	"time"
+)
+
+func TestGetHistoryAPI(t *testing.T) {
+	movie := db.Movies{Id: primitive.NewObjectID(), Title: util.RandomString(8)}
+	series := randomSeries()
+	episode := randomEpisodes(series)[1]
+	entries := []db.HistoryEntry{
+		{ID: primitive.NewObjectID(), Name: "user", MediaID: episode.ID, SeriesID: series.Id},
+		{ID: primitive.NewObjectID(), Name: "user", MediaID: movie.Id},
+	}
+
+	testCase := []struct {
+		name          string
+		query         string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			query: "?s=10&p=2",
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.GetHistoryParams{Name: "user", Skip: 10, Limit: 10}
+				store.EXPECT().GetHistory(gomock.Any(), gomock.Eq(arg)).Times(1).Return(entries, nil)
+				store.EXPECT().GetMoviesByIDs(gomock.Any(), gomock.Len(2)).Times(1).Return([]db.Movies{movie, series}, nil)
+				store.EXPECT().GetEpisodesByIDs(gomock.Any(), gomock.Len(1)).Times(1).Return([]db.Episode{episode}, nil)
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Any()).AnyTimes().Return(db.User{Name: "user"}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp []historyResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Len(t, rsp, 2)
+				require.Equal(t, entries[0].ID.Hex(), rsp[0].ID)
+				require.Equal(t, series.Id.Hex(), rsp[0].Movie.Id)
+				require.Equal(t, episode.ID, rsp[0].Episode.ID)
+				require.Equal(t, movie.Id.Hex(), rsp[1].Movie.Id)
+			},
+		},
+		{
+			name:  "InvalidPage",
+			query: "?s=10&p=0",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetHistory(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "InternalError",
+			query: "?s=10&p=1",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetHistory(gomock.Any(), gomock.Any()).Times(1).Return(nil, mongo.ErrClientDisconnected)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := sendAuthorizedJSON(t, server, http.MethodGet, "/me/history"+tc.query, nil)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestDeleteHistoryEntryAPI(t *testing.T) {
+	id := primitive.NewObjectID()
+
+	testCase := []struct {
+		name          string
+		id            string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			id:   id.Hex(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().DeleteHistoryEntry(gomock.Any(), gomock.Eq("user"), gomock.Eq(id)).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "NotFound",
+			id:   id.Hex(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().DeleteHistoryEntry(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(mongo.ErrNoDocuments)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidId",
+			id:   util.RandomString(24),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().DeleteHistoryEntry(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := sendAuthorizedJSON(t, server, http.MethodDelete, "/me/history/"+tc.id, nil)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestGetStatsAPI(t *testing.T) {
+	stats := db.UserStats{
+		Movies:    3,
+		Hours:     5.5,
+		TopGenres: []db.StatsCount{{Name: "Drama", Count: 2}},
+		Streak:    db.WatchStreak{Current: 1, Longest: 2},
+		Months:    []db.MonthStats{{Month: "2021-03", Movies: 3, Hours: 5.5}},
+	}
+
+	testCase := []struct {
+		name          string
+		query         string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "AllTime",
+			query: "",
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.GetUserStatsParams{Name: "user", Top: statsTop}
+				store.EXPECT().GetUserStats(gomock.Any(), gomock.Eq(arg)).Times(1).Return(stats, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp db.UserStats
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, stats, rsp)
+			},
+		},
+		{
+			name:  "Year",
+			query: "?year=2021",
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.GetUserStatsParams{
+					Name: "user",
+					From: time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC),
+					To:   time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC),
+					Top:  statsTop,
+				}
+				store.EXPECT().GetUserStats(gomock.Any(), gomock.Eq(arg)).Times(1).Return(stats, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:  "InvalidYear",
+			query: "?year=99",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserStats(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "InternalError",
+			query: "",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserStats(gomock.Any(), gomock.Any()).Times(1).Return(db.UserStats{}, mongo.ErrClientDisconnected)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := sendAuthorizedJSON(t, server, http.MethodGet, "/me/stats"+tc.query, nil)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
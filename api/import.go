@@ -0,0 +1,65 @@
+package api
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"phantom/importer"
+)
+
+type importMoviesRequest struct {
+	Format    string `form:"format" binding:"required,oneof=json ndjson csv"`
+	Columns   string `form:"columns"`
+	BatchSize int    `form:"batch_size" binding:"min=0,max=10000"`
+	Skip      int64  `form:"skip" binding:"min=0"`
+}
+
+// importMoviesResponse is the progress of an import, with the error
+// it stopped at. It can be resumed by sending the file again with
+// skip set to the rows it did
+type importMoviesResponse struct {
+	importer.Progress
+	Error string `json:"error,omitempty"`
+}
+
+// importMovies is for admins to add or update the movies of a catalogue,
+// which is the body of the request, see importer.Import
+func (server *Server) importMovies(ctx *gin.Context) {
+	var req importMoviesRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	columns, err := importer.ParseColumns(req.Columns)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	progress, err := importer.Import(ctx, server.store, ctx.Request.Body, importer.Options{
+		Format:    req.Format,
+		Columns:   columns,
+		BatchSize: req.BatchSize,
+		Skip:      req.Skip,
+		Progress: func(progress importer.Progress) {
+			log.Printf("import: row %d, %d added, %d updated, %d failed",
+				progress.Rows, progress.Inserted, progress.Updated, progress.Failed)
+		},
+	})
+	if progress.Inserted > 0 || progress.Updated > 0 {
+		if indexErr := server.BuildIndexes(ctx); indexErr != nil && err == nil {
+			err = indexErr
+		}
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		var fileErr *importer.FileError
+		if errors.As(err, &fileErr) {
+			status = http.StatusBadRequest
+		}
+		ctx.JSON(status, importMoviesResponse{Progress: progress, Error: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, importMoviesResponse{Progress: progress})
+}
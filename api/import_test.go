@@ -0,0 +1,145 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"net/http/httptest"
+	mockdb "phantom/db/mock"
+	db "phantom/db/mongo"
+	"phantom/importer"
+	"strings"
+	"testing"
+	"time"
+)
+
+const importCSV = `Series_Title,Released_Year
+Spirited Away,2001
+,1997
+`
+
+func TestImportMoviesAPI(t *testing.T) {
+	movie := db.Movies{Title: "Spirited Away", Year: 2001}
+
+	testCase := []struct {
+		name          string
+		query         string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			query: "?format=csv&columns=title=Series_Title,year=Released_Year",
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().UpsertMovies(gomock.Any(), gomock.Eq([]db.Movies{movie})).
+					Times(1).
+					Return(&mongo.BulkWriteResult{UpsertedCount: 1}, nil)
+				// The suggestions pick the new movies up
+				store.EXPECT().GetAllMovies(gomock.Any()).Times(1).Return([]db.Movies{movie}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp importMoviesResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, int64(2), rsp.Rows)
+				require.Equal(t, int64(1), rsp.Inserted)
+				require.Equal(t, []importer.RowError{{Row: 2, Error: "title: a movie needs a title"}}, rsp.Errors)
+			},
+		},
+		{
+			name:  "Resume",
+			query: "?format=csv&columns=title=Series_Title,year=Released_Year&skip=1",
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().UpsertMovies(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:  "UnknownField",
+			query: "?format=csv&columns=name=Series_Title",
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().UpsertMovies(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "UnknownColumn",
+			query: "?format=csv&columns=title=Name",
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().UpsertMovies(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "NoFormat",
+			query: "",
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().UpsertMovies(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "InternalError",
+			query: "?format=csv&columns=title=Series_Title,year=Released_Year",
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().UpsertMovies(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, errors.New("connection reset"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+				var rsp importMoviesResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, int64(0), rsp.Rows)
+				require.NotEmpty(t, rsp.Error)
+			},
+		},
+		{
+			name:  "NotAdmin",
+			query: "?format=csv",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("user")).Times(1).Return(db.User{Name: "user"}, nil)
+				store.EXPECT().UpsertMovies(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			request, err := http.NewRequest(http.MethodPost, "/import"+tc.query, strings.NewReader(importCSV))
+			require.NoError(t, err)
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "user", time.Minute)
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"net/http"
+	db "phantom/db/mongo"
+	"strconv"
+	"time"
+)
+
+// defaultLedgerCheckpointInterval is used when the config doesn't set LEDGER_CHECKPOINT_INTERVAL
+const defaultLedgerCheckpointInterval = time.Minute
+
+var errNotInLedger = errors.New("it is not in the ledger")
+
+// ScheduleLedgerCheckpoints checkpoints the latest entries of the ledger every
+// LedgerCheckpointInterval until ctx is done, so that they can be proved before
+// an interval of entries follows them. A checkpoint that fails is logged, and
+// the next is made on time
+func (server *Server) ScheduleLedgerCheckpoints(ctx context.Context) {
+	ticker := time.NewTicker(server.config.LedgerCheckpointInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := server.store.CheckpointLedger(ctx); err != nil {
+			log.Print("ledger: ", err)
+		}
+	}
+}
+
+type ledgerProofRequest struct {
+	CommentID string `uri:"commentId" binding:"required,hexadecimal,len=24"`
+}
+
+// getLedgerProof proves the latest entry of a comment or a danmaku is in the ledger.
+// The client checks the proof with ledger.Proof.Verify, and keeps the root of
+// its checkpoint to tell if the ledger is ever rewritten. An entry that is not
+// checkpointed yet is 202 Accepted, to be asked again after Retry-After, by when
+// ScheduleLedgerCheckpoints has checkpointed it
+func (server *Server) getLedgerProof(ctx *gin.Context) {
+	var req ledgerProofRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	refID, err := primitive.ObjectIDFromHex(req.CommentID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	proof, err := server.store.GetLedgerProof(ctx, refID)
+	if err != nil {
+		switch err {
+		case mongo.ErrNoDocuments:
+			ctx.JSON(http.StatusNotFound, errorResponse(errNotInLedger))
+		case db.ErrNotCheckpointed:
+			retryAfter := int64(server.config.LedgerCheckpointInterval.Seconds())
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			ctx.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
+			ctx.JSON(http.StatusAccepted, errorResponse(err))
+		default:
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		}
+		return
+	}
+	ctx.JSON(http.StatusOK, proof)
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"net/http/httptest"
+	mockdb "phantom/db/mock"
+	db "phantom/db/mongo"
+	"phantom/ledger"
+	"testing"
+	"time"
+)
+
+func randomProof(t *testing.T, refID primitive.ObjectID) ledger.Proof {
+	var entries []ledger.Entry
+	var prev *ledger.Entry
+	for i := 0; i < 5; i++ {
+		ref := primitive.NewObjectID()
+		if i == 3 {
+			ref = refID
+		}
+		entry := ledger.Append(prev, db.LedgerComment, ledger.OpAdd, ref.Hex(), ledger.Digest([]byte(ref.Hex())))
+		entries = append(entries, entry)
+		prev = &entry
+	}
+	proof, err := ledger.NewProof(entries, 3)
+	require.NoError(t, err)
+	return proof
+}
+
+func TestGetLedgerProofAPI(t *testing.T) {
+	commentID := primitive.NewObjectID()
+	proof := randomProof(t, commentID)
+
+	testCase := []struct {
+		name          string
+		id            string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			id:   commentID.Hex(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetLedgerProof(gomock.Any(), gomock.Eq(commentID)).Times(1).Return(proof, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var got ledger.Proof
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.Equal(t, proof, got)
+				require.NoError(t, got.Verify())
+				require.Equal(t, commentID.Hex(), got.Entry.Ref)
+			},
+		},
+		{
+			name: "NotInLedger",
+			id:   commentID.Hex(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetLedgerProof(gomock.Any(), gomock.Eq(commentID)).Times(1).Return(ledger.Proof{}, mongo.ErrNoDocuments)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+				require.Contains(t, recorder.Body.String(), errNotInLedger.Error())
+			},
+		},
+		{
+			name: "NotCheckpointed",
+			id:   commentID.Hex(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetLedgerProof(gomock.Any(), gomock.Eq(commentID)).Times(1).Return(ledger.Proof{}, db.ErrNotCheckpointed)
+				store.EXPECT().CheckpointLedger(gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusAccepted, recorder.Code)
+				require.Equal(t, "60", recorder.Header().Get("Retry-After"))
+				require.Contains(t, recorder.Body.String(), db.ErrNotCheckpointed.Error())
+			},
+		},
+		{
+			name: "InternalError",
+			id:   commentID.Hex(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetLedgerProof(gomock.Any(), gomock.Any()).Times(1).Return(ledger.Proof{}, errors.New("boom"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidID",
+			id:   "123",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetLedgerProof(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/ledger/proof/%s", tc.id), nil)
+			require.NoError(t, err)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestScheduleLedgerCheckpoints(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	store := mockdb.NewMockStore(ctrl)
+	// A failed checkpoint doesn't stop the next ones
+	gomock.InOrder(
+		store.EXPECT().CheckpointLedger(gomock.Any()).Times(1).Return(errors.New("boom")),
+		store.EXPECT().CheckpointLedger(gomock.Any()).Times(1).DoAndReturn(func(context.Context) error {
+			cancel()
+			return nil
+		}),
+		// A tick can race the cancel
+		store.EXPECT().CheckpointLedger(gomock.Any()).AnyTimes().Return(nil),
+	)
+	server := newTestServer(t, store)
+	server.config.LedgerCheckpointInterval = time.Millisecond
+
+	done := make(chan struct{})
+	go func() {
+		server.ScheduleLedgerCheckpoints(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the checkpoints didn't stop with their context")
+	}
+}
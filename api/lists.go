@@ -0,0 +1,215 @@
+package api
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	db "phantom/db/mongo"
+	"phantom/token"
+)
+
+var errNotInList = errors.New("movie is not in the list")
+
+type listMovieRequest struct {
+	MovieId string `uri:"movieId" binding:"required,hexadecimal,min=24"`
+}
+
+// movieLists tells the signed-in user which of their lists have a movie
+type movieLists struct {
+	Watchlist bool `json:"watchlist"`
+	Favorites bool `json:"favorites"`
+}
+
+func newMovieLists(lists []string) *movieLists {
+	rsp := &movieLists{}
+	for _, list := range lists {
+		switch list {
+		case db.ListWatchlist:
+			rsp.Watchlist = true
+		case db.ListFavorites:
+			rsp.Favorites = true
+		}
+	}
+	return rsp
+}
+
+// addToList returns the handler that adds a movie to a list of the signed-in user
+func (server *Server) addToList(list string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req listMovieRequest
+		if err := ctx.ShouldBindUri(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		movieID, err := primitive.ObjectIDFromHex(req.MovieId)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+
+		if _, err = server.store.GetMovieByID(ctx, movieID); err != nil {
+			if mongo.ErrNoDocuments == err {
+				ctx.JSON(http.StatusNotFound, errorResponse(errMovieNotFound))
+				return
+			}
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+
+		authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+		_, err = server.store.AddListEntry(ctx, db.ListEntryParams{
+			Name:    authPayload.Username,
+			List:    list,
+			MovieID: movieID,
+		})
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"added": "OK"})
+	}
+}
+
+// removeFromList returns the handler that removes a movie from a list of the signed-in user
+func (server *Server) removeFromList(list string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req listMovieRequest
+		if err := ctx.ShouldBindUri(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		movieID, err := primitive.ObjectIDFromHex(req.MovieId)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+
+		authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+		_, err = server.store.DeleteListEntry(ctx, db.ListEntryParams{
+			Name:    authPayload.Username,
+			List:    list,
+			MovieID: movieID,
+		})
+		if err != nil {
+			if mongo.ErrNoDocuments == err {
+				ctx.JSON(http.StatusNotFound, errorResponse(errNotInList))
+				return
+			}
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"deleted": "OK"})
+	}
+}
+
+type getListRequest struct {
+	PageSize int64 `form:"s" binding:"required,min=1,max=50"`
+	PageId   int64 `form:"p" binding:"required,min=1"`
+}
+
+// getList returns the handler that gets a page of a list of the signed-in user,
+// the latest additions first unless the list was reordered, with the movies
+// the user watched marked
+func (server *Server) getList(list string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req getListRequest
+		if err := ctx.ShouldBindQuery(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+
+		authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+		entries, err := server.store.GetListEntries(ctx, db.GetListParams{
+			Name:  authPayload.Username,
+			List:  list,
+			Skip:  req.PageSize * (req.PageId - 1),
+			Limit: req.PageSize,
+		})
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		movies, err := server.moviesInOrder(ctx, entries)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		rsp := generateGetMoviesResponse(movies, server.preferredLanguages(ctx))
+		if err = server.markWatched(ctx, authPayload.Username, rsp); err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusOK, rsp)
+	}
+}
+
+// moviesInOrder gets the movies of list entries in the order of the entries,
+// leaving out the movies that were removed from the catalog
+func (server *Server) moviesInOrder(ctx *gin.Context, entries []db.ListEntry) ([]db.Movies, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	ids := make([]primitive.ObjectID, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.MovieID
+	}
+	found, err := server.store.GetMoviesByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[primitive.ObjectID]db.Movies, len(found))
+	for _, movie := range found {
+		byID[movie.Id] = movie
+	}
+
+	var movies []db.Movies
+	for _, id := range ids {
+		if movie, ok := byID[id]; ok {
+			movies = append(movies, movie)
+		}
+	}
+	return movies, nil
+}
+
+type reorderListRequest struct {
+	MovieIDs []string `json:"movie_ids" binding:"required,min=1,unique,dive,hexadecimal,len=24"`
+}
+
+// reorderList returns the handler that puts movies of a list of the signed-in
+// user in a new order, the other movies of the list keep their places
+func (server *Server) reorderList(list string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req reorderListRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		ids := make([]primitive.ObjectID, len(req.MovieIDs))
+		for i, hex := range req.MovieIDs {
+			id, err := primitive.ObjectIDFromHex(hex)
+			if err != nil {
+				ctx.JSON(http.StatusBadRequest, errorResponse(err))
+				return
+			}
+			ids[i] = id
+		}
+
+		authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+		err := server.store.ReorderList(ctx, db.ReorderListParams{
+			Name:     authPayload.Username,
+			List:     list,
+			MovieIDs: ids,
+		})
+		if err != nil {
+			if mongo.ErrNoDocuments == err {
+				ctx.JSON(http.StatusBadRequest, errorResponse(errNotInList))
+				return
+			}
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"updated": "OK"})
+	}
+}
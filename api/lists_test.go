@@ -0,0 +1,307 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"net/http/httptest"
+	mockdb "phantom/db/mock"
+	db "phantom/db/mongo"
+	"phantom/util"
+	"testing"
+	"time"
+)
+
+func TestAddToListAPI(t *testing.T) {
+	movie := db.Movies{Id: primitive.NewObjectID(), Title: util.RandomString(8)}
+
+	testCase := []struct {
+		name          string
+		list          string
+		movieId       string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:    "Watchlist",
+			list:    db.ListWatchlist,
+			movieId: movie.Id.Hex(),
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ListEntryParams{Name: "user", List: db.ListWatchlist, MovieID: movie.Id}
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(movie.Id)).Times(1).Return(movie, nil)
+				store.EXPECT().AddListEntry(gomock.Any(), gomock.Eq(arg)).Times(1).Return(&mongo.UpdateResult{UpsertedCount: 1}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:    "Favorites",
+			list:    db.ListFavorites,
+			movieId: movie.Id.Hex(),
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ListEntryParams{Name: "user", List: db.ListFavorites, MovieID: movie.Id}
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(movie.Id)).Times(1).Return(movie, nil)
+				store.EXPECT().AddListEntry(gomock.Any(), gomock.Eq(arg)).Times(1).Return(&mongo.UpdateResult{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:    "MovieNotFound",
+			list:    db.ListWatchlist,
+			movieId: movie.Id.Hex(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(movie.Id)).Times(1).Return(db.Movies{}, mongo.ErrNoDocuments)
+				store.EXPECT().AddListEntry(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:    "InvalidId",
+			list:    db.ListWatchlist,
+			movieId: util.RandomString(24),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := sendAuthorizedJSON(t, server, http.MethodPost, fmt.Sprintf("/me/%s/%s", tc.list, tc.movieId), nil)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestRemoveFromListAPI(t *testing.T) {
+	movieId := primitive.NewObjectID()
+	arg := db.ListEntryParams{Name: "user", List: db.ListWatchlist, MovieID: movieId}
+
+	testCase := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().DeleteListEntry(gomock.Any(), gomock.Eq(arg)).Times(1).Return(int64(1), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "NotInList",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().DeleteListEntry(gomock.Any(), gomock.Eq(arg)).Times(1).Return(int64(0), mongo.ErrNoDocuments)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := sendAuthorizedJSON(t, server, http.MethodDelete, "/me/watchlist/"+movieId.Hex(), nil)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestGetListAPI(t *testing.T) {
+	movies := []db.Movies{
+		{Id: primitive.NewObjectID(), Title: util.RandomString(8)},
+		{Id: primitive.NewObjectID(), Title: util.RandomString(8)},
+	}
+	// The second movie was added last, the one in between was removed from the catalog
+	entries := []db.ListEntry{
+		{MovieID: movies[1].Id, Position: 3},
+		{MovieID: primitive.NewObjectID(), Position: 2},
+		{MovieID: movies[0].Id, Position: 1},
+	}
+
+	testCase := []struct {
+		name          string
+		query         string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			query: "?s=10&p=1",
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.GetListParams{Name: "user", List: db.ListFavorites, Skip: 0, Limit: 10}
+				store.EXPECT().GetListEntries(gomock.Any(), gomock.Eq(arg)).Times(1).Return(entries, nil)
+				store.EXPECT().GetMoviesByIDs(gomock.Any(), gomock.Len(3)).Times(1).Return(movies, nil)
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Any()).AnyTimes().Return(db.User{Name: "user"}, nil)
+				progress := []db.Progress{{Name: "user", MediaID: movies[0].Id, Watched: true}}
+				store.EXPECT().GetProgressByMediaIDs(gomock.Any(), gomock.Eq("user"), gomock.Len(2)).Times(1).Return(progress, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp []getMoviesResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Len(t, rsp, 2)
+				require.Equal(t, movies[1].Id.Hex(), rsp[0].Id)
+				require.Equal(t, movies[0].Id.Hex(), rsp[1].Id)
+				require.NotNil(t, rsp[0].Watched)
+				require.False(t, *rsp[0].Watched)
+				require.NotNil(t, rsp[1].Watched)
+				require.True(t, *rsp[1].Watched)
+			},
+		},
+		{
+			name:  "InvalidPage",
+			query: "?s=10&p=0",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetListEntries(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "InternalError",
+			query: "?s=10&p=1",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetListEntries(gomock.Any(), gomock.Any()).Times(1).Return(nil, mongo.ErrClientDisconnected)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := sendAuthorizedJSON(t, server, http.MethodGet, "/me/favorites"+tc.query, nil)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestReorderListAPI(t *testing.T) {
+	ids := []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID()}
+
+	testCase := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"movie_ids": []string{ids[0].Hex(), ids[1].Hex()}},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ReorderListParams{Name: "user", List: db.ListWatchlist, MovieIDs: ids}
+				store.EXPECT().ReorderList(gomock.Any(), gomock.Eq(arg)).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "NotInList",
+			body: gin.H{"movie_ids": []string{ids[0].Hex()}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ReorderList(gomock.Any(), gomock.Any()).Times(1).Return(mongo.ErrNoDocuments)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "Duplicate",
+			body: gin.H{"movie_ids": []string{ids[0].Hex(), ids[0].Hex()}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ReorderList(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := sendAuthorizedJSON(t, server, http.MethodPut, "/me/watchlist", tc.body)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestGetMovieListsAPI(t *testing.T) {
+	movie := db.Movies{Id: primitive.NewObjectID(), Title: util.RandomString(8)}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(movie.Id)).Times(1).Return(movie, nil)
+	store.EXPECT().GetCollectionsByMovie(gomock.Any(), gomock.Any()).Times(1).Return(nil, nil)
+	store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("user")).Times(1).Return(db.User{Name: "user"}, nil)
+	store.EXPECT().GetListsByMovie(gomock.Any(), gomock.Eq("user"), gomock.Eq(movie.Id)).
+		Times(1).
+		Return([]string{db.ListFavorites}, nil)
+	store.EXPECT().GetRating(gomock.Any(), gomock.Eq("user"), gomock.Eq(movie.Id)).Times(1).Return(db.Rating{}, mongo.ErrNoDocuments)
+	server := newTestServer(t, store)
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/movies/"+movie.Id.Hex(), nil)
+	require.NoError(t, err)
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "user", time.Minute)
+	server.router.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var rsp movieResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+	require.Equal(t, &movieLists{Watchlist: false, Favorites: true}, rsp.Lists)
+	require.Nil(t, rsp.UserRating)
+}
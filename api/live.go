@@ -0,0 +1,49 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"log"
+	"net/http"
+	"phantom/live"
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// watchMovie upgrades to a WebSocket on which the signed-in user gets
+// the danmaku and comments posted on a movie or an episode while they watch it
+func (server *Server) watchMovie(ctx *gin.Context) {
+	var req movieIdRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	movieID, err := primitive.ObjectIDFromHex(req.Id)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if _, ok := server.findPlayable(ctx, movieID); !ok {
+		return
+	}
+
+	// The upgrader writes the response when it fails
+	conn, err := upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		return
+	}
+	live.Serve(conn, server.liveHub, movieID.Hex(), live.DefaultClientConfig)
+}
+
+// publish sends an event to the viewers of a movie. Live updates are best effort,
+// so a failure is only logged
+func (server *Server) publish(movieID primitive.ObjectID, eventType string, data interface{}) {
+	err := server.liveHub.Publish(movieID.Hex(), live.Event{Type: eventType, Data: data})
+	if err != nil {
+		log.Printf("cannot publish %s on %s: %v", eventType, movieID.Hex(), err)
+	}
+}
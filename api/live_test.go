@@ -0,0 +1,156 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"net/http/httptest"
+	mockdb "phantom/db/mock"
+	db "phantom/db/mongo"
+	"phantom/live"
+	"phantom/util"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchMovieAPI(t *testing.T) {
+	movie := db.Movies{Id: primitive.NewObjectID(), Title: util.RandomString(8), Runtime: 100}
+	series := randomSeries()
+
+	testCase := []struct {
+		name          string
+		id            string
+		setupAuth     func(t *testing.T, request *http.Request, server *Server)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:       "NoAuthorization",
+			id:         movie.Id.Hex(),
+			setupAuth:  func(t *testing.T, request *http.Request, server *Server) {},
+			buildStubs: func(store *mockdb.MockStore) {},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidQueryToken",
+			id:   movie.Id.Hex(),
+			setupAuth: func(t *testing.T, request *http.Request, server *Server) {
+				request.URL.RawQuery = accessTokenQueryKey + "=" + util.RandomString(20)
+			},
+			buildStubs: func(store *mockdb.MockStore) {},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "NotFound",
+			id:   movie.Id.Hex(),
+			setupAuth: func(t *testing.T, request *http.Request, server *Server) {
+				addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "user", time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(movie.Id)).Times(1).Return(db.Movies{}, mongo.ErrNoDocuments)
+				store.EXPECT().GetEpisodeByID(gomock.Any(), gomock.Eq(movie.Id)).Times(1).Return(db.Episode{}, mongo.ErrNoDocuments)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "Series",
+			id:   series.Id.Hex(),
+			setupAuth: func(t *testing.T, request *http.Request, server *Server) {
+				addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "user", time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(series.Id)).Times(1).Return(series, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "NotWebSocket",
+			id:   movie.Id.Hex(),
+			setupAuth: func(t *testing.T, request *http.Request, server *Server) {
+				addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "user", time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(movie.Id)).Times(1).Return(movie, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/ws/movies/%s", tc.id), nil)
+			require.NoError(t, err)
+			tc.setupAuth(t, request, server)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestWatchMovieLive(t *testing.T) {
+	movie := db.Movies{Id: primitive.NewObjectID(), Title: util.RandomString(8), Runtime: 100}
+	danmaku := db.Danmaku{ID: primitive.NewObjectID(), MovieID: movie.Id, Offset: 1000, Text: "前方高能", Name: "user"}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(movie.Id)).Times(2).Return(movie, nil)
+	store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("user")).Times(1).Return(db.User{Name: "user"}, nil)
+	store.EXPECT().AddDanmaku(gomock.Any(), gomock.Any()).Times(1).Return(danmaku, nil)
+
+	server := newTestServer(t, store)
+	httpServer := httptest.NewServer(server.router)
+	defer httpServer.Close()
+
+	accessToken, err := server.tokenMaker.CreateToken("viewer", time.Minute)
+	require.NoError(t, err)
+	url := fmt.Sprintf("ws%s/ws/movies/%s?%s=%s",
+		strings.TrimPrefix(httpServer.URL, "http"), movie.Id.Hex(), accessTokenQueryKey, accessToken)
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	hub := server.liveHub.(*live.MemoryHub)
+	require.Eventually(t, func() bool { return hub.Subscribers(movie.Id.Hex()) == 1 }, time.Second, 5*time.Millisecond)
+
+	recorder := sendAuthorizedJSON(t, server, http.MethodPost, fmt.Sprintf("/movies/%s/danmaku", movie.Id.Hex()),
+		map[string]interface{}{"offset": 1000, "text": "前方高能"})
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	var event struct {
+		Type string          `json:"type"`
+		Data danmakuResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(data, &event))
+	require.Equal(t, live.EventDanmaku, event.Type)
+	require.Equal(t, newDanmakuResponse(danmaku), event.Data)
+}
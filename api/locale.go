@@ -0,0 +1,84 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/text/language"
+	"net/http"
+	db "phantom/db/mongo"
+	"phantom/token"
+)
+
+var validLocale validator.Func = func(fl validator.FieldLevel) bool {
+	if locale, ok := fl.Field().Interface().(string); ok {
+		_, err := language.Parse(locale)
+		return err == nil
+	}
+	return false
+}
+
+// preferredLanguages lists the languages to show titles in, best first.
+// A signed-in user's own preference comes before the Accept-Language header
+func (server *Server) preferredLanguages(ctx *gin.Context) []language.Tag {
+	var tags []language.Tag
+	if payload, ok := ctx.Get(authorizationPayloadKey); ok {
+		user, err := server.store.GetUserByName(ctx, payload.(*token.Payload).Username)
+		if err == nil && user.Locale != "" {
+			if tag, err := language.Parse(user.Locale); err == nil {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	// A malformed header still yields the languages parsed before the error
+	accepted, _, _ := language.ParseAcceptLanguage(ctx.GetHeader("Accept-Language"))
+	return append(tags, accepted...)
+}
+
+// localizedTitle picks the localized title that best matches prefs,
+// falling back to the title of the movie
+func localizedTitle(movie db.Movies, prefs []language.Tag) string {
+	if len(movie.Titles) == 0 || len(prefs) == 0 {
+		return movie.Title
+	}
+	supported := make([]language.Tag, len(movie.Titles))
+	for i, title := range movie.Titles {
+		supported[i] = language.Make(title.Locale)
+	}
+	_, index, confidence := language.NewMatcher(supported).Match(prefs...)
+	if confidence == language.No {
+		return movie.Title
+	}
+	return movie.Titles[index].Title
+}
+
+type updateLocaleRequest struct {
+	Locale string `json:"locale" binding:"required,locale"`
+}
+
+// updateLocale sets the locale the signed-in user prefers titles in
+func (server *Server) updateLocale(ctx *gin.Context) {
+	var req updateLocaleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	user, err := server.store.GetUserByName(ctx, authPayload.Username)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			ctx.JSON(http.StatusNotFound, errorResponse(errUserNotFound))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	user.Locale = language.Make(req.Locale).String()
+	if _, err = server.store.UpdateUserLocale(ctx, user); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"locale": user.Locale})
+}
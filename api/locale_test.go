@@ -0,0 +1,232 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/text/language"
+	"net/http"
+	"net/http/httptest"
+	mockdb "phantom/db/mock"
+	db "phantom/db/mongo"
+	"phantom/token"
+	"testing"
+	"time"
+)
+
+func localizedMovie() db.Movies {
+	return db.Movies{
+		Id:            primitive.NewObjectID(),
+		Title:         "Spirited Away",
+		OriginalTitle: "千と千尋の神隠し",
+		Titles: []db.LocalizedTitle{
+			{Locale: "en", Title: "Spirited Away"},
+			{Locale: "ja", Title: "千と千尋の神隠し"},
+			{Locale: "zh-Hans", Title: "千与千寻"},
+			{Locale: "zh-Hant", Title: "神隱少女"},
+		},
+	}
+}
+
+func TestLocalizedTitle(t *testing.T) {
+	movie := localizedMovie()
+	testCases := []struct {
+		accept string
+		want   string
+	}{
+		{"", "Spirited Away"},
+		{"zh-CN,zh;q=0.9,en;q=0.8", "千与千寻"},
+		{"zh-TW", "神隱少女"},
+		{"ja-JP", "千と千尋の神隠し"},
+		{"fr-FR", "Spirited Away"},
+		{"de", "Spirited Away"},
+	}
+	for _, tc := range testCases {
+		prefs, _, _ := language.ParseAcceptLanguage(tc.accept)
+		require.Equal(t, tc.want, localizedTitle(movie, prefs), tc.accept)
+	}
+
+	// Movies without localized titles keep their title
+	movie.Titles = nil
+	require.Equal(t, "Spirited Away", localizedTitle(movie, []language.Tag{language.Japanese}))
+}
+
+func TestGetMovieLocalizedAPI(t *testing.T) {
+	movie := localizedMovie()
+	testCase := []struct {
+		name          string
+		accept        string
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "AcceptLanguage",
+			accept: "zh-CN,zh;q=0.9",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchTitle(t, "千与千寻", recorder)
+			},
+		},
+		{
+			name:   "UserPreference",
+			accept: "zh-CN,zh;q=0.9",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "user", time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("user")).
+					Times(1).
+					Return(db.User{Name: "user", Locale: "ja"}, nil)
+				store.EXPECT().GetListsByMovie(gomock.Any(), gomock.Eq("user"), gomock.Eq(movie.Id)).Times(1).Return(nil, nil)
+				store.EXPECT().GetRating(gomock.Any(), gomock.Eq("user"), gomock.Eq(movie.Id)).Times(1).Return(db.Rating{}, mongo.ErrNoDocuments)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchTitle(t, "千と千尋の神隠し", recorder)
+			},
+		},
+		{
+			name:   "UserWithoutPreference",
+			accept: "zh-TW",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "user", time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("user")).
+					Times(1).
+					Return(db.User{Name: "user"}, nil)
+				store.EXPECT().GetListsByMovie(gomock.Any(), gomock.Eq("user"), gomock.Eq(movie.Id)).Times(1).Return(nil, nil)
+				store.EXPECT().GetRating(gomock.Any(), gomock.Eq("user"), gomock.Eq(movie.Id)).Times(1).Return(db.Rating{}, mongo.ErrNoDocuments)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchTitle(t, "神隱少女", recorder)
+			},
+		},
+		{
+			name:   "Default",
+			accept: "",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchTitle(t, "Spirited Away", recorder)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(movie.Id)).Times(1).Return(movie, nil)
+			store.EXPECT().GetCollectionsByMovie(gomock.Any(), gomock.Any()).Times(1).Return(nil, nil)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/movies/%s", movie.Id.Hex()), nil)
+			require.NoError(t, err)
+			if tc.accept != "" {
+				request.Header.Set("Accept-Language", tc.accept)
+			}
+			tc.setupAuth(t, request, server.tokenMaker)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func requireBodyMatchTitle(t *testing.T, title string, recorder *httptest.ResponseRecorder) {
+	var gotMovie db.Movies
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &gotMovie))
+	require.Equal(t, title, gotMovie.Title)
+}
+
+func TestUpdateLocaleAPI(t *testing.T) {
+	user := db.User{ID: primitive.NewObjectID(), Name: "user"}
+	testCase := []struct {
+		name          string
+		body          map[string]string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: map[string]string{"locale": "zh-hans"},
+			buildStubs: func(store *mockdb.MockStore) {
+				updated := user
+				updated.Locale = "zh-Hans"
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq(user.Name)).Times(1).Return(user, nil)
+				store.EXPECT().UpdateUserLocale(gomock.Any(), gomock.Eq(updated)).Times(1).Return(&mongo.UpdateResult{ModifiedCount: 1}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.JSONEq(t, `{"locale":"zh-Hans"}`, recorder.Body.String())
+			},
+		},
+		{
+			name: "InvalidLocale",
+			body: map[string]string{"locale": "not a locale"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().UpdateUserLocale(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "UserNotFound",
+			body: map[string]string{"locale": "ja"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Any()).Times(1).Return(db.User{}, mongo.ErrNoDocuments)
+				store.EXPECT().UpdateUserLocale(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "InternalError",
+			body: map[string]string{"locale": "ja"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Any()).Times(1).Return(user, nil)
+				store.EXPECT().UpdateUserLocale(gomock.Any(), gomock.Any()).Times(1).Return(nil, mongo.ErrClientDisconnected)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := sendAuthorizedJSON(t, server, http.MethodPut, "/users/locale", tc.body)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
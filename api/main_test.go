@@ -1,19 +1,28 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	mockdb "phantom/db/mock"
 	db "phantom/db/mongo"
 	"phantom/util"
 	"testing"
 	"time"
 )
 
+// newTestServer searches with the mongo backend, so the store mocks see every search
 func newTestServer(t *testing.T, store db.Store) *Server {
 	config := util.Config{
 		TokenSymmetricKey:   util.RandomString(32),
 		AccessTokenDuration: time.Minute,
+		SearchBackend:       searchBackendMongo,
 	}
 
 	server, err := NewServer(config, store)
@@ -22,6 +31,32 @@ func newTestServer(t *testing.T, store db.Store) *Server {
 	return server
 }
 
+// sendAuthorizedJSON sends body as JSON on behalf of the user named "user"
+func sendAuthorizedJSON(t *testing.T, server *Server, method, url string, body interface{}) *httptest.ResponseRecorder {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		require.NoError(t, err)
+	}
+	request, err := http.NewRequest(method, url, bytes.NewReader(data))
+	require.NoError(t, err)
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "user", time.Minute)
+
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// expectTx lets the handler run one transaction, whose queries go to store
+func expectTx(store *mockdb.MockStore) {
+	store.EXPECT().ExecTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(ctx context.Context, fn func(q db.Querier) error) error {
+			return fn(store)
+		})
+}
+
 func TestMain(m *testing.M) {
 	gin.SetMode(gin.TestMode) // 把gin的运行模式改为测试模式，默认是调式模式，调试模式会给出详细的日志，不利于阅读
 
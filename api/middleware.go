@@ -4,15 +4,20 @@ import (
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
 	"net/http"
+	"net/url"
+	db "phantom/db/mongo"
 	"phantom/token"
 	"strings"
+	"time"
 )
 
 const (
 	authorizationHeaderKey  = "authorization"
 	authorizationTypeBearer = "bearer"
 	authorizationPayloadKey = "authorization_payload"
+	accessTokenQueryKey     = "access_token"
 )
 
 func authMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
@@ -24,21 +29,48 @@ func authMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
 			return
 		}
 
-		fields := strings.Fields(authorizationHeader)
-		if len(fields) < 2 {
-			err := errors.New("invalid authorization header format")
+		payload, err := verifyAuthorizationHeader(tokenMaker, authorizationHeader)
+		if err != nil {
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
 			return
 		}
 
-		authorizationType := strings.ToLower(fields[0])
-		if authorizationType != authorizationTypeBearer {
-			err := fmt.Errorf("unsupported authorization type %s", authorizationType)
+		ctx.Set(authorizationPayloadKey, payload)
+		ctx.Next()
+	}
+}
+
+// optionalAuthMiddleware lets anonymous requests through, and sets the payload
+// for requests that carry a valid token, such as a signed-in user browsing movies
+func optionalAuthMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		authorizationHeader := ctx.GetHeader(authorizationHeaderKey)
+		if len(authorizationHeader) == 0 {
+			ctx.Next()
+			return
+		}
+
+		payload, err := verifyAuthorizationHeader(tokenMaker, authorizationHeader)
+		if err != nil {
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
 			return
 		}
 
-		accessToken := fields[1]
+		ctx.Set(authorizationPayloadKey, payload)
+		ctx.Next()
+	}
+}
+
+// wsAuthMiddleware is authMiddleware for WebSocket requests,
+// which can carry the token in the access_token query parameter instead
+func wsAuthMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		accessToken := ctx.Query(accessTokenQueryKey)
+		if len(accessToken) == 0 {
+			authMiddleware(tokenMaker)(ctx)
+			return
+		}
+
 		payload, err := tokenMaker.VerifyToken(accessToken)
 		if err != nil {
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
@@ -49,3 +81,80 @@ func authMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
 		ctx.Next()
 	}
 }
+
+// logFormatter is the format of gin's default logger, with the access token
+// of a WebSocket request left out of the path, so the logs don't hold tokens
+func logFormatter(param gin.LogFormatterParams) string {
+	var statusColor, methodColor, resetColor string
+	if param.IsOutputColor() {
+		statusColor = param.StatusCodeColor()
+		methodColor = param.MethodColor()
+		resetColor = param.ResetColor()
+	}
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+	return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		statusColor, param.StatusCode, resetColor,
+		param.Latency,
+		param.ClientIP,
+		methodColor, param.Method, resetColor,
+		redactAccessToken(param.Path),
+		param.ErrorMessage,
+	)
+}
+
+// redactAccessToken replaces the access token in the query of path
+func redactAccessToken(path string) string {
+	i := strings.IndexByte(path, '?')
+	if i < 0 || !strings.Contains(path[i:], accessTokenQueryKey) {
+		return path
+	}
+	query, err := url.ParseQuery(path[i+1:])
+	if err != nil {
+		// A query that doesn't parse is left out whole
+		return path[:i]
+	}
+	if _, ok := query[accessTokenQueryKey]; ok {
+		query.Set(accessTokenQueryKey, "REDACTED")
+	}
+	return path[:i+1] + query.Encode()
+}
+
+func verifyAuthorizationHeader(tokenMaker token.Maker, authorizationHeader string) (*token.Payload, error) {
+	fields := strings.Fields(authorizationHeader)
+	if len(fields) < 2 {
+		return nil, errors.New("invalid authorization header format")
+	}
+
+	authorizationType := strings.ToLower(fields[0])
+	if authorizationType != authorizationTypeBearer {
+		return nil, fmt.Errorf("unsupported authorization type %s", authorizationType)
+	}
+
+	accessToken := fields[1]
+	return tokenMaker.VerifyToken(accessToken)
+}
+
+// adminMiddleware only lets admins through, it has to run after authMiddleware
+func adminMiddleware(store db.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+		user, err := store.GetUserByName(ctx, authPayload.Username)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(errUserNotFound))
+				return
+			}
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		if user.Role != db.UserRoleAdmin {
+			err := errors.New("the user is not an admin")
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse(err))
+			return
+		}
+		ctx.Next()
+	}
+}
@@ -1,11 +1,16 @@
 package api
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
 	"net/http"
 	"net/http/httptest"
+	mockdb "phantom/db/mock"
+	db "phantom/db/mongo"
 	"phantom/token"
 	"testing"
 	"time"
@@ -103,3 +108,152 @@ func TestAuthMiddleware(t *testing.T) {
 	}
 
 }
+
+func TestOptionalAuthMiddleware(t *testing.T) {
+	testCase := []struct {
+		name          string
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "user", time.Minute)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Equal(t, `{"username":"user"}`, recorder.Body.String())
+			},
+		},
+		{
+			name: "NonAuthorization",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Equal(t, `{"username":""}`, recorder.Body.String())
+			},
+		},
+		{
+			name: "Expired",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "user", -time.Minute)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			server := newTestServer(t, nil)
+
+			authPath := "/optional_auth"
+			server.router.GET(
+				authPath,
+				optionalAuthMiddleware(server.tokenMaker),
+				func(ctx *gin.Context) {
+					username := ""
+					if payload, ok := ctx.Get(authorizationPayloadKey); ok {
+						username = payload.(*token.Payload).Username
+					}
+					ctx.JSON(http.StatusOK, gin.H{"username": username})
+				},
+			)
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, authPath, nil)
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestAdminMiddleware(t *testing.T) {
+	testCase := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("user")).
+					Times(1).
+					Return(db.User{Name: "user", Role: db.UserRoleAdmin}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "NotAdmin",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("user")).Times(1).Return(db.User{Name: "user"}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "UserNotFound",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("user")).Times(1).Return(db.User{}, mongo.ErrNoDocuments)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			adminPath := "/admin"
+			server.router.GET(
+				adminPath,
+				authMiddleware(server.tokenMaker),
+				adminMiddleware(server.store),
+				func(ctx *gin.Context) {
+					ctx.JSON(http.StatusOK, gin.H{})
+				},
+			)
+			recorder := sendAuthorizedJSON(t, server, http.MethodGet, adminPath, nil)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestLogsLeaveOutAccessToken(t *testing.T) {
+	var logs bytes.Buffer
+	defaultWriter := gin.DefaultWriter
+	gin.DefaultWriter = &logs
+	defer func() { gin.DefaultWriter = defaultWriter }()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	server := newTestServer(t, mockdb.NewMockStore(ctrl))
+
+	accessToken, err := server.tokenMaker.CreateToken("user", time.Minute)
+	require.NoError(t, err)
+	request, err := http.NewRequest(http.MethodGet, "/ws/movies/bad?since=3&access_token="+accessToken, nil)
+	require.NoError(t, err)
+	server.router.ServeHTTP(httptest.NewRecorder(), request)
+
+	require.Contains(t, logs.String(), "/ws/movies/bad?access_token=REDACTED&since=3")
+	require.NotContains(t, logs.String(), accessToken)
+	require.Equal(t, "/ws/movies/1", redactAccessToken("/ws/movies/1?access_token=%zz"))
+}
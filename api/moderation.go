@@ -0,0 +1,295 @@
+package api
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	db "phantom/db/mongo"
+	"phantom/token"
+)
+
+// defaultReportThreshold is used when the config doesn't set REPORT_THRESHOLD
+const defaultReportThreshold = 3
+
+var errAlreadyReported = errors.New("comment is already reported")
+
+type reportCommentRequest struct {
+	Reason string `json:"reason" binding:"required,oneof=spam abuse spoiler other"`
+	Note   string `json:"note" binding:"max=500"`
+}
+
+// reportComment is for the signed-in user to report a comment,
+// which is hidden once it has enough reports
+func (server *Server) reportComment(ctx *gin.Context) {
+	var uri commentUriRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req reportCommentRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	objectId, err := primitive.ObjectIDFromHex(uri.Id)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	comment, err := server.store.GetComment(ctx, objectId)
+	if err != nil && mongo.ErrNoDocuments != err {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if err != nil || comment.Deleted {
+		ctx.JSON(http.StatusNotFound, errorResponse(errCommentNotFound))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	reported, err := server.store.ReportComment(ctx, db.ReportCommentParams{
+		CommentID:     objectId,
+		Name:          authPayload.Username,
+		Reason:        req.Reason,
+		Note:          req.Note,
+		HideThreshold: server.config.ReportThreshold,
+	})
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			ctx.JSON(http.StatusForbidden, errorResponse(errAlreadyReported))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if reported.Hidden && !comment.Hidden {
+		err = server.store.AddModerationLog(ctx, db.AddModerationLogParams{
+			Moderator: db.ModeratorSystem,
+			Action:    db.ModerationHide,
+			CommentID: objectId,
+			Author:    comment.Name,
+		})
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		server.removeComment(objectId)
+	}
+	ctx.JSON(http.StatusOK, gin.H{"reported": "OK"})
+}
+
+// getModerationQueue is for admins to get a page of the reported comments to review
+func (server *Server) getModerationQueue(ctx *gin.Context) {
+	var req getMoviesRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	comments, err := server.store.GetModerationQueue(ctx, db.GetModerationQueueParams{
+		Skip:  req.PageSize * (req.PageId - 1),
+		Limit: req.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if comments == nil {
+		comments = []db.Comments{}
+	}
+	ctx.JSON(http.StatusOK, comments)
+}
+
+// moderateComment returns the handler with which admins approve a reported
+// comment, remove it, or remove it and ban its author. The action is logged
+func (server *Server) moderateComment(action string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req commentUriRequest
+		if err := ctx.ShouldBindUri(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		objectId, err := primitive.ObjectIDFromHex(req.Id)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+
+		comment, err := server.store.GetComment(ctx, objectId)
+		if err != nil && mongo.ErrNoDocuments != err {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		if err != nil || comment.Deleted {
+			ctx.JSON(http.StatusNotFound, errorResponse(errCommentNotFound))
+			return
+		}
+
+		// The users who reported a comment that is taken down earn coins,
+		// their reports go with the comment
+		var reporters []string
+		switch action {
+		case db.ModerationApprove:
+			err = server.store.ApproveComment(ctx, objectId)
+		case db.ModerationRemove, db.ModerationBan:
+			reporters, err = server.store.GetCommentReporters(ctx, objectId)
+			if err != nil {
+				ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+				return
+			}
+			err = server.store.ExecTx(ctx, func(q db.Querier) error {
+				if _, err := q.RemoveComment(ctx, objectId); err != nil {
+					return err
+				}
+				if err := q.IncrementMovieComments(ctx, comment.MovieID, -1); err != nil {
+					return err
+				}
+				return revokeCoins(ctx, q, db.CoinEarnComment, db.CoinRevokeComment, objectId.Hex())
+			})
+			if err == nil && action == db.ModerationBan {
+				err = server.store.BanUser(ctx, comment.Name)
+				// Comments imported with the catalog have no user behind them
+				if err == mongo.ErrNoDocuments {
+					err = nil
+				}
+			}
+		}
+		if err != nil {
+			if mongo.ErrNoDocuments == err {
+				ctx.JSON(http.StatusNotFound, errorResponse(errCommentNotFound))
+				return
+			}
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+
+		authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+		err = server.store.AddModerationLog(ctx, db.AddModerationLogParams{
+			Moderator: authPayload.Username,
+			Action:    action,
+			CommentID: objectId,
+			Author:    comment.Name,
+		})
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		for _, reporter := range reporters {
+			server.earnCoins(ctx, db.CoinEarnModeration, objectId.Hex()+":"+reporter, reporter, moderationReward)
+		}
+		if action == db.ModerationApprove {
+			server.reindexComment(ctx, objectId)
+		} else {
+			server.removeComment(objectId)
+		}
+		ctx.JSON(http.StatusOK, gin.H{action: "OK"})
+	}
+}
+
+// danmakuModerationResponse is a flagged danmaku in the moderation queue
+type danmakuModerationResponse struct {
+	danmakuResponse
+	MovieID string   `json:"movie_id"`
+	Flags   []string `json:"flags"`
+}
+
+// getDanmakuModerationQueue is for admins to get a page of the danmaku the
+// content filter flagged, to review
+func (server *Server) getDanmakuModerationQueue(ctx *gin.Context) {
+	var req getMoviesRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	danmaku, err := server.store.GetDanmakuModerationQueue(ctx, db.GetModerationQueueParams{
+		Skip:  req.PageSize * (req.PageId - 1),
+		Limit: req.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	rsp := make([]danmakuModerationResponse, len(danmaku))
+	for i, d := range danmaku {
+		rsp[i] = danmakuModerationResponse{danmakuResponse: newDanmakuResponse(d), MovieID: d.MovieID.Hex(), Flags: d.Flags}
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// moderateDanmaku returns the handler with which admins approve a flagged
+// danmaku, remove a danmaku, or remove it and ban its author, like
+// moderateComment. The action is logged
+func (server *Server) moderateDanmaku(action string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req commentUriRequest
+		if err := ctx.ShouldBindUri(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		objectId, err := primitive.ObjectIDFromHex(req.Id)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+
+		var danmaku db.Danmaku
+		if action == db.ModerationApprove {
+			danmaku, err = server.store.ApproveDanmaku(ctx, objectId)
+		} else {
+			danmaku, err = server.store.RemoveDanmaku(ctx, objectId)
+			if err == nil && action == db.ModerationBan {
+				err = server.store.BanUser(ctx, danmaku.Name)
+				if err == mongo.ErrNoDocuments {
+					err = nil
+				}
+			}
+		}
+		if err != nil {
+			if mongo.ErrNoDocuments == err {
+				ctx.JSON(http.StatusNotFound, errorResponse(errDanmakuNotFound))
+				return
+			}
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+
+		authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+		err = server.store.AddModerationLog(ctx, db.AddModerationLogParams{
+			Moderator: authPayload.Username,
+			Action:    action,
+			DanmakuID: objectId,
+			Author:    danmaku.Name,
+		})
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{action: "OK"})
+	}
+}
+
+// getModerationLog is for admins to get a page of the moderation log, the latest first
+func (server *Server) getModerationLog(ctx *gin.Context) {
+	var req getMoviesRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	entries, err := server.store.GetModerationLog(ctx, db.GetModerationLogParams{
+		Skip:  req.PageSize * (req.PageId - 1),
+		Limit: req.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if entries == nil {
+		entries = []db.ModerationLogEntry{}
+	}
+	ctx.JSON(http.StatusOK, entries)
+}
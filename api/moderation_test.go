@@ -0,0 +1,487 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"net/http/httptest"
+	mockdb "phantom/db/mock"
+	db "phantom/db/mongo"
+	"phantom/util"
+	"testing"
+)
+
+func TestReportCommentAPI(t *testing.T) {
+	comment := db.Comments{ID: primitive.NewObjectID(), Name: util.RandomUser(), Text: util.RandomString(10)}
+
+	testCase := []struct {
+		name          string
+		id            string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			id:   comment.ID.Hex(),
+			body: gin.H{"reason": db.ReportReasonSpam},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ReportCommentParams{
+					CommentID:     comment.ID,
+					Name:          "user",
+					Reason:        db.ReportReasonSpam,
+					HideThreshold: defaultReportThreshold,
+				}
+				reported := comment
+				reported.ReportCount = 1
+				store.EXPECT().GetComment(gomock.Any(), gomock.Eq(comment.ID)).Times(1).Return(comment, nil)
+				store.EXPECT().ReportComment(gomock.Any(), gomock.Eq(arg)).Times(1).Return(reported, nil)
+				store.EXPECT().AddModerationLog(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "Hidden",
+			id:   comment.ID.Hex(),
+			body: gin.H{"reason": db.ReportReasonAbuse, "note": "rude"},
+			buildStubs: func(store *mockdb.MockStore) {
+				reported := comment
+				reported.ReportCount = defaultReportThreshold
+				reported.Hidden = true
+				log := db.AddModerationLogParams{
+					Moderator: db.ModeratorSystem,
+					Action:    db.ModerationHide,
+					CommentID: comment.ID,
+					Author:    comment.Name,
+				}
+				store.EXPECT().GetComment(gomock.Any(), gomock.Eq(comment.ID)).Times(1).Return(comment, nil)
+				store.EXPECT().ReportComment(gomock.Any(), gomock.Any()).Times(1).Return(reported, nil)
+				store.EXPECT().AddModerationLog(gomock.Any(), gomock.Eq(log)).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "AlreadyReported",
+			id:   comment.ID.Hex(),
+			body: gin.H{"reason": db.ReportReasonSpam},
+			buildStubs: func(store *mockdb.MockStore) {
+				duplicate := mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000}}}
+				store.EXPECT().GetComment(gomock.Any(), gomock.Eq(comment.ID)).Times(1).Return(comment, nil)
+				store.EXPECT().ReportComment(gomock.Any(), gomock.Any()).Times(1).Return(db.Comments{}, duplicate)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "NotFound",
+			id:   comment.ID.Hex(),
+			body: gin.H{"reason": db.ReportReasonSpam},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetComment(gomock.Any(), gomock.Eq(comment.ID)).Times(1).Return(db.Comments{}, mongo.ErrNoDocuments)
+				store.EXPECT().ReportComment(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidReason",
+			id:   comment.ID.Hex(),
+			body: gin.H{"reason": "boring"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetComment(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().ReportComment(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			url := fmt.Sprintf("/comments/%s/report", tc.id)
+			recorder := sendAuthorizedJSON(t, server, http.MethodPost, url, tc.body)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestGetModerationQueueAPI(t *testing.T) {
+	queue := []db.Comments{
+		{ID: primitive.NewObjectID(), Name: util.RandomUser(), Hidden: true, ReportCount: 3, Reports: map[string]int64{db.ReportReasonSpam: 3}},
+		{ID: primitive.NewObjectID(), Name: util.RandomUser(), ReportCount: 1, Reports: map[string]int64{db.ReportReasonSpoiler: 1}},
+	}
+
+	testCase := []struct {
+		name          string
+		query         string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			query: "?s=10&p=2",
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				arg := db.GetModerationQueueParams{Skip: 10, Limit: 10}
+				store.EXPECT().GetModerationQueue(gomock.Any(), gomock.Eq(arg)).Times(1).Return(queue, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp []db.Comments
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, queue, rsp)
+			},
+		},
+		{
+			name:  "NotAdmin",
+			query: "?s=10&p=1",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("user")).Times(1).Return(db.User{Name: "user"}, nil)
+				store.EXPECT().GetModerationQueue(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:  "InternalError",
+			query: "?s=10&p=1",
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().GetModerationQueue(gomock.Any(), gomock.Any()).Times(1).Return(nil, mongo.ErrClientDisconnected)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := sendAuthorizedJSON(t, server, http.MethodGet, "/moderation/queue"+tc.query, nil)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestModerateCommentAPI(t *testing.T) {
+	comment := db.Comments{ID: primitive.NewObjectID(), MovieID: primitive.NewObjectID(), Name: util.RandomUser(), Hidden: true, ReportCount: 3}
+	logAction := func(action string) db.AddModerationLogParams {
+		return db.AddModerationLogParams{Moderator: "user", Action: action, CommentID: comment.ID, Author: comment.Name}
+	}
+
+	testCase := []struct {
+		name          string
+		action        string
+		id            string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "Approve",
+			action: db.ModerationApprove,
+			id:     comment.ID.Hex(),
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().GetComment(gomock.Any(), gomock.Eq(comment.ID)).Times(1).Return(comment, nil)
+				store.EXPECT().ApproveComment(gomock.Any(), gomock.Eq(comment.ID)).Times(1).Return(nil)
+				store.EXPECT().AddModerationLog(gomock.Any(), gomock.Eq(logAction(db.ModerationApprove))).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:   "Remove",
+			action: db.ModerationRemove,
+			id:     comment.ID.Hex(),
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().GetComment(gomock.Any(), gomock.Eq(comment.ID)).Times(1).Return(comment, nil)
+				store.EXPECT().GetCommentReporters(gomock.Any(), gomock.Eq(comment.ID)).Times(1).Return([]string{"a", "b"}, nil)
+				expectTx(store)
+				store.EXPECT().RemoveComment(gomock.Any(), gomock.Eq(comment.ID)).Times(1).Return(int64(1), nil)
+				store.EXPECT().IncrementMovieComments(gomock.Any(), gomock.Eq(comment.MovieID), gomock.Eq(int64(-1))).Times(1).Return(nil)
+				store.EXPECT().BanUser(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().AddModerationLog(gomock.Any(), gomock.Eq(logAction(db.ModerationRemove))).Times(1).Return(nil)
+				expectReward(store, db.CoinEarnModeration, comment.ID.Hex()+":a", "a", moderationReward)
+				expectReward(store, db.CoinEarnModeration, comment.ID.Hex()+":b", "b", moderationReward)
+				expectRevoke(store, db.CoinEarnComment, db.CoinRevokeComment, comment.ID.Hex(), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:   "Ban",
+			action: db.ModerationBan,
+			id:     comment.ID.Hex(),
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().GetComment(gomock.Any(), gomock.Eq(comment.ID)).Times(1).Return(comment, nil)
+				store.EXPECT().GetCommentReporters(gomock.Any(), gomock.Eq(comment.ID)).Times(1).Return(nil, nil)
+				expectTx(store)
+				store.EXPECT().RemoveComment(gomock.Any(), gomock.Eq(comment.ID)).Times(1).Return(int64(1), nil)
+				store.EXPECT().IncrementMovieComments(gomock.Any(), gomock.Eq(comment.MovieID), gomock.Eq(int64(-1))).Times(1).Return(nil)
+				store.EXPECT().BanUser(gomock.Any(), gomock.Eq(comment.Name)).Times(1).Return(nil)
+				store.EXPECT().AddModerationLog(gomock.Any(), gomock.Eq(logAction(db.ModerationBan))).Times(1).Return(nil)
+				expectRevoke(store, db.CoinEarnComment, db.CoinRevokeComment, comment.ID.Hex(), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:   "RevokeFails",
+			action: db.ModerationBan,
+			id:     comment.ID.Hex(),
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().GetComment(gomock.Any(), gomock.Eq(comment.ID)).Times(1).Return(comment, nil)
+				store.EXPECT().GetCommentReporters(gomock.Any(), gomock.Eq(comment.ID)).Times(1).Return([]string{"a"}, nil)
+				expectTx(store)
+				store.EXPECT().RemoveComment(gomock.Any(), gomock.Eq(comment.ID)).Times(1).Return(int64(1), nil)
+				store.EXPECT().IncrementMovieComments(gomock.Any(), gomock.Eq(comment.MovieID), gomock.Eq(int64(-1))).Times(1).Return(nil)
+				expectRevoke(store, db.CoinEarnComment, db.CoinRevokeComment, comment.ID.Hex(), mongo.ErrClientDisconnected)
+				store.EXPECT().BanUser(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().AddModerationLog(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().TransferCoinsTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name:   "BanWithoutUser",
+			action: db.ModerationBan,
+			id:     comment.ID.Hex(),
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().GetComment(gomock.Any(), gomock.Eq(comment.ID)).Times(1).Return(comment, nil)
+				store.EXPECT().GetCommentReporters(gomock.Any(), gomock.Eq(comment.ID)).Times(1).Return(nil, nil)
+				expectTx(store)
+				store.EXPECT().RemoveComment(gomock.Any(), gomock.Eq(comment.ID)).Times(1).Return(int64(1), nil)
+				store.EXPECT().IncrementMovieComments(gomock.Any(), gomock.Eq(comment.MovieID), gomock.Eq(int64(-1))).Times(1).Return(nil)
+				store.EXPECT().BanUser(gomock.Any(), gomock.Eq(comment.Name)).Times(1).Return(mongo.ErrNoDocuments)
+				store.EXPECT().AddModerationLog(gomock.Any(), gomock.Eq(logAction(db.ModerationBan))).Times(1).Return(nil)
+				// Imported comments were never rewarded
+				expectRevoke(store, db.CoinEarnComment, db.CoinRevokeComment, comment.ID.Hex(), mongo.ErrNoDocuments)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:   "NotFound",
+			action: db.ModerationRemove,
+			id:     comment.ID.Hex(),
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().GetComment(gomock.Any(), gomock.Eq(comment.ID)).Times(1).Return(db.Comments{}, mongo.ErrNoDocuments)
+				store.EXPECT().RemoveComment(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().AddModerationLog(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:   "InvalidId",
+			action: db.ModerationApprove,
+			id:     util.RandomString(24),
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().GetComment(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			url := fmt.Sprintf("/moderation/comments/%s/%s", tc.id, tc.action)
+			recorder := sendAuthorizedJSON(t, server, http.MethodPost, url, nil)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestGetDanmakuModerationQueueAPI(t *testing.T) {
+	danmaku := []db.Danmaku{
+		{ID: primitive.NewObjectID(), MovieID: primitive.NewObjectID(), Text: util.RandomString(10), Name: util.RandomUser(), Flags: []string{"blocked word"}},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	expectAdmin(store)
+	arg := db.GetModerationQueueParams{Skip: 20, Limit: 20}
+	store.EXPECT().GetDanmakuModerationQueue(gomock.Any(), gomock.Eq(arg)).Times(1).Return(danmaku, nil)
+
+	server := newTestServer(t, store)
+	recorder := sendAuthorizedJSON(t, server, http.MethodGet, "/moderation/danmaku?s=20&p=2", nil)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var rsp []danmakuModerationResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+	require.Len(t, rsp, 1)
+	require.Equal(t, danmaku[0].ID.Hex(), rsp[0].Id)
+	require.Equal(t, danmaku[0].MovieID.Hex(), rsp[0].MovieID)
+	require.Equal(t, danmaku[0].Flags, rsp[0].Flags)
+}
+
+func TestModerateDanmakuAPI(t *testing.T) {
+	danmaku := db.Danmaku{ID: primitive.NewObjectID(), Name: util.RandomUser(), Text: util.RandomString(10)}
+	logAction := func(action string) db.AddModerationLogParams {
+		return db.AddModerationLogParams{Moderator: "user", Action: action, DanmakuID: danmaku.ID, Author: danmaku.Name}
+	}
+
+	testCase := []struct {
+		name          string
+		action        string
+		id            string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "Approve",
+			action: db.ModerationApprove,
+			id:     danmaku.ID.Hex(),
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().ApproveDanmaku(gomock.Any(), gomock.Eq(danmaku.ID)).Times(1).Return(danmaku, nil)
+				store.EXPECT().AddModerationLog(gomock.Any(), gomock.Eq(logAction(db.ModerationApprove))).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:   "Remove",
+			action: db.ModerationRemove,
+			id:     danmaku.ID.Hex(),
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().RemoveDanmaku(gomock.Any(), gomock.Eq(danmaku.ID)).Times(1).Return(danmaku, nil)
+				store.EXPECT().BanUser(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().AddModerationLog(gomock.Any(), gomock.Eq(logAction(db.ModerationRemove))).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:   "Ban",
+			action: db.ModerationBan,
+			id:     danmaku.ID.Hex(),
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().RemoveDanmaku(gomock.Any(), gomock.Eq(danmaku.ID)).Times(1).Return(danmaku, nil)
+				store.EXPECT().BanUser(gomock.Any(), gomock.Eq(danmaku.Name)).Times(1).Return(nil)
+				store.EXPECT().AddModerationLog(gomock.Any(), gomock.Eq(logAction(db.ModerationBan))).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:   "NotFound",
+			action: db.ModerationRemove,
+			id:     danmaku.ID.Hex(),
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().RemoveDanmaku(gomock.Any(), gomock.Eq(danmaku.ID)).Times(1).Return(db.Danmaku{}, mongo.ErrNoDocuments)
+				store.EXPECT().AddModerationLog(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:   "InvalidId",
+			action: db.ModerationApprove,
+			id:     util.RandomString(24),
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().ApproveDanmaku(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			url := fmt.Sprintf("/moderation/danmaku/%s/%s", tc.id, tc.action)
+			recorder := sendAuthorizedJSON(t, server, http.MethodPost, url, nil)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestGetModerationLogAPI(t *testing.T) {
+	entries := []db.ModerationLogEntry{
+		{ID: primitive.NewObjectID(), Moderator: "user", Action: db.ModerationBan, CommentID: primitive.NewObjectID(), Author: util.RandomUser()},
+		{ID: primitive.NewObjectID(), Moderator: db.ModeratorSystem, Action: db.ModerationHide, CommentID: primitive.NewObjectID(), Author: util.RandomUser()},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	expectAdmin(store)
+	arg := db.GetModerationLogParams{Skip: 0, Limit: 20}
+	store.EXPECT().GetModerationLog(gomock.Any(), gomock.Eq(arg)).Times(1).Return(entries, nil)
+
+	server := newTestServer(t, store)
+	recorder := sendAuthorizedJSON(t, server, http.MethodGet, "/moderation/log?s=20&p=1", nil)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var rsp []db.ModerationLogEntry
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+	require.Equal(t, entries, rsp)
+}
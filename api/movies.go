@@ -5,27 +5,32 @@ import (
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/text/language"
 	"net/http"
 	db "phantom/db/mongo"
+	"phantom/token"
 )
 
 var errMovieNotFound error = errors.New("movie is not found")
 
 type createMovieRequest struct {
-	Plot             string             `json:"plot"`
-	Genres           []string           `json:"genres"`
-	Runtime          int64              `json:"runtime"`
-	Rated            string             `json:"rated"`
-	Cast             []string           `json:"cast"`
-	NumMflixComments int64              `json:"num_mflix_comments"`
-	Poster           string             `json:"poster"`
-	Title            string             `json:"title" binding:"required,min=1"`
-	Fullplot         string             `json:"fullplot"`
-	Languages        []string           `json:"languages"`
-	Released         primitive.DateTime `json:"released"`
-	Directors        []string           `json:"directors"`
-	Writers          []string           `json:"writers"`
-	Awards           struct {
+	Plot          string                  `json:"plot"`
+	Genres        []string                `json:"genres"`
+	Runtime       int64                   `json:"runtime"`
+	Rated         string                  `json:"rated"`
+	Cast          []string                `json:"cast"`
+	Poster        string                  `json:"poster"`
+	Title         string                  `json:"title" binding:"required,min=1"`
+	OriginalTitle string                  `json:"original_title"`
+	SortTitle     string                  `json:"sort_title"`
+	Titles        []localizedTitleRequest `json:"titles" binding:"dive"`
+	Fullplot      string                  `json:"fullplot"`
+	Languages     []string                `json:"languages"`
+	Released      primitive.DateTime      `json:"released"`
+	Directors     []string                `json:"directors"`
+	Writers       []string                `json:"writers"`
+	Credits       []creditRequest         `json:"credits" binding:"dive"`
+	Awards        struct {
 		Wins        int64  `json:"wins"`
 		Nominations int64  `json:"nominations"`
 		Text        string `json:"text"`
@@ -38,7 +43,7 @@ type createMovieRequest struct {
 		Id     int64   `json:"id"`
 	} `json:"imdb"`
 	Countries []string `json:"countries"`
-	Type      string   `json:"type"`
+	Type      string   `json:"type" binding:"omitempty,oneof=movie series"`
 	Tomatoes  struct {
 		Viewer struct {
 			Rating     float64 `json:"rating"`
@@ -49,26 +54,67 @@ type createMovieRequest struct {
 	} `json:"tomatoes"`
 }
 
+type localizedTitleRequest struct {
+	Locale string `json:"locale" binding:"required,locale"`
+	Title  string `json:"title" binding:"required,min=1"`
+}
+
+func newLocalizedTitles(titles []localizedTitleRequest) []db.LocalizedTitle {
+	var rsp []db.LocalizedTitle
+	for _, title := range titles {
+		rsp = append(rsp, db.LocalizedTitle{
+			Locale: language.Make(title.Locale).String(),
+			Title:  title.Title,
+		})
+	}
+	return rsp
+}
+
 // TODO: addOneMovieInfo the function is not yet complete
 func (server *Server) createMovie(ctx *gin.Context) {
 	var req createMovieRequest
-	err := ctx.ShouldBindJSON(&req)
-	if err != nil {
+	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
+	credits, err := server.resolveCredits(ctx, req.Credits, req.Cast, req.Directors, req.Writers)
+	if err != nil {
+		if err == errUnknownPerson {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
 	arg := db.AddMovieParams{
-		Genres:   req.Genres,
-		Runtime:  req.Runtime,
-		Title:    req.Title,
-		Released: req.Released,
-		Year:     req.Year,
+		Genres:        req.Genres,
+		Runtime:       req.Runtime,
+		Title:         req.Title,
+		OriginalTitle: req.OriginalTitle,
+		SortTitle:     req.SortTitle,
+		Titles:        newLocalizedTitles(req.Titles),
+		Cast:          credits.Cast,
+		Directors:     credits.Directors,
+		Writers:       credits.Writers,
+		Credits:       credits.Credits,
+		Released:      req.Released,
+		Year:          req.Year,
+		Type:          req.Type,
 	}
 	id, err := server.store.AddMovie(ctx, arg)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
+	server.indexMovie(db.Movies{
+		Id:            id,
+		Genres:        arg.Genres,
+		Title:         arg.Title,
+		OriginalTitle: arg.OriginalTitle,
+		Titles:        arg.Titles,
+		Cast:          arg.Cast,
+		Directors:     arg.Directors,
+	})
 	ctx.JSON(http.StatusOK, gin.H{"id": id.Hex()})
 }
 
@@ -78,21 +124,37 @@ type getMoviesResponse struct {
 	Genres []string `json:"genres"`
 	Cast   []string `json:"cast"`
 	Poster string   `json:"poster"`
-	Title  string   `json:"title"`
-	Year   int64    `json:"year"`
-	Imdb   struct {
+	// Title is localized for the client, see localizedTitle
+	Title         string `json:"title"`
+	OriginalTitle string `json:"original_title"`
+	Year          int64  `json:"year"`
+	Imdb          struct {
 		Rating float64 `json:"rating"`
 		Votes  int64   `json:"votes"`
 		Id     int64   `json:"id"`
 	} `json:"imdb"`
-	Countries []string `json:"countries"`
+	Community  db.CommunityRating  `json:"community"`
+	Countries  []string            `json:"countries"`
+	Highlights map[string][]string `json:"highlights,omitempty"`
+	// Watched is only set in the lists of a signed-in user
+	Watched *bool `json:"watched,omitempty"`
+}
+
+// movieResponse is the details of a movie, with the collections it is in
+// and, for a signed-in user, which of their lists have it and their rating
+type movieResponse struct {
+	db.Movies
+	Collections []collectionSummary `json:"collections"`
+	Lists       *movieLists         `json:"lists,omitempty"`
+	UserRating  *int64              `json:"user_rating,omitempty"`
 }
 
 type movieIdRequest struct {
 	Id string `uri:"id" binding:"required,hexadecimal,min=24"`
 }
 
-// getMovie  is to get the details of a movie by its id
+// getMovie  is to get the details of a movie by its id,
+// the title is in the language the client prefers
 func (server *Server) getMovie(ctx *gin.Context) {
 	var req movieIdRequest
 	if err := ctx.ShouldBindUri(&req); err != nil {
@@ -114,7 +176,38 @@ func (server *Server) getMovie(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
-	ctx.JSON(http.StatusOK, movie)
+	collections, err := server.store.GetCollectionsByMovie(ctx, movie)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	movie.Title = localizedTitle(movie, server.preferredLanguages(ctx))
+	rsp := movieResponse{Movies: movie, Collections: []collectionSummary{}}
+	if payload, ok := ctx.Get(authorizationPayloadKey); ok {
+		lists, err := server.store.GetListsByMovie(ctx, payload.(*token.Payload).Username, movie.Id)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		rsp.Lists = newMovieLists(lists)
+
+		rating, err := server.store.GetRating(ctx, payload.(*token.Payload).Username, movie.Id)
+		if err != nil && err != mongo.ErrNoDocuments {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		if err == nil {
+			rsp.UserRating = &rating.Score
+		}
+	}
+	for _, collection := range collections {
+		rsp.Collections = append(rsp.Collections, collectionSummary{
+			Id:    collection.ID.Hex(),
+			Title: collection.Title,
+		})
+	}
+	ctx.JSON(http.StatusOK, rsp)
 }
 
 type searchForMoviesRequest struct {
@@ -130,6 +223,10 @@ func (server *Server) searchForMovies(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
+	if server.searchIndex != nil {
+		server.searchMoviesWithIndex(ctx, req)
+		return
+	}
 	arg := db.SearchForMoviesParams{
 		Text:  req.Search,
 		Skip:  req.PageSize * (req.PageId - 1),
@@ -141,13 +238,13 @@ func (server *Server) searchForMovies(ctx *gin.Context) {
 		return
 	}
 
-	rsp := generateGetMoviesResponse(movies)
+	rsp := generateGetMoviesResponse(movies, server.preferredLanguages(ctx))
 	ctx.JSON(http.StatusOK, rsp)
 }
 
 type listMoviesByGenresRequest struct {
 	Genres   string `form:"genres" binding:"required,min=1"`
-	Sort     string `form:"sort" binding:"required,oneof=hotness time rating"`
+	Sort     string `form:"sort" binding:"required,oneof=hotness time rating community title"`
 	PageSize int64  `form:"s" binding:"required,min=1,max=50"`
 	PageId   int64  `form:"p" binding:"required,min=1"`
 }
@@ -175,7 +272,7 @@ func (server *Server) listMoviesByGenres(ctx *gin.Context) {
 		return
 	}
 
-	rsp := generateGetMoviesResponse(movies)
+	rsp := generateGetMoviesResponse(movies, server.preferredLanguages(ctx))
 	ctx.JSON(http.StatusOK, rsp)
 }
 
@@ -200,7 +297,7 @@ func (server *Server) listTheMostWatchedMovies(ctx *gin.Context) {
 		return
 	}
 
-	rsp := generateGetMoviesResponse(movies)
+	rsp := generateGetMoviesResponse(movies, server.preferredLanguages(ctx))
 	ctx.JSON(http.StatusOK, rsp)
 }
 
@@ -220,7 +317,7 @@ func (server *Server) listTheLatestReleasedMovies(ctx *gin.Context) {
 		return
 	}
 
-	rsp := generateGetMoviesResponse(movies)
+	rsp := generateGetMoviesResponse(movies, server.preferredLanguages(ctx))
 	ctx.JSON(http.StatusOK, rsp)
 }
 
@@ -253,12 +350,29 @@ func (server *Server) updateMovie(ctx *gin.Context) {
 		return
 	}
 
+	credits, err := server.resolveCredits(ctx, reqJson.Credits, reqJson.Cast, reqJson.Directors, reqJson.Writers)
+	if err != nil {
+		if err == errUnknownPerson {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
 	arg := db.Movies{
-		Genres:   reqJson.Genres,
-		Runtime:  reqJson.Runtime,
-		Title:    reqJson.Title,
-		Released: reqJson.Released,
-		Year:     reqJson.Year,
+		Genres:        reqJson.Genres,
+		Runtime:       reqJson.Runtime,
+		Title:         reqJson.Title,
+		OriginalTitle: reqJson.OriginalTitle,
+		SortTitle:     reqJson.SortTitle,
+		Titles:        newLocalizedTitles(reqJson.Titles),
+		Cast:          credits.Cast,
+		Directors:     credits.Directors,
+		Writers:       credits.Writers,
+		Credits:       credits.Credits,
+		Released:      reqJson.Released,
+		Year:          reqJson.Year,
+		Type:          reqJson.Type,
 	}
 	_, err = server.store.ReplaceMovieInfoByID(ctx, objectId, arg)
 	if err != nil {
@@ -269,25 +383,66 @@ func (server *Server) updateMovie(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
+	arg.Id = objectId
+	server.indexMovie(arg)
 	ctx.JSON(http.StatusOK, gin.H{"updated": "OK"})
 }
 
-func generateGetMoviesResponse(movies []db.Movies) []getMoviesResponse {
+// deleteMovie deletes a movie and the comments on it, both or neither
+func (server *Server) deleteMovie(ctx *gin.Context) {
+	var req movieIdRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	objectId, err := primitive.ObjectIDFromHex(req.Id)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var commentIds []primitive.ObjectID
+	err = server.store.ExecTx(ctx, func(q db.Querier) error {
+		_, err := q.DeleteMovieByID(ctx, objectId)
+		if err != nil {
+			return err
+		}
+		commentIds, err = q.DeleteCommentsByMovieID(ctx, objectId)
+		return err
+	})
+	if err != nil {
+		if mongo.ErrNoDocuments == err {
+			ctx.JSON(http.StatusNotFound, errorResponse(errMovieNotFound))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	server.removeMovie(objectId)
+	for _, id := range commentIds {
+		server.removeComment(id)
+	}
+	ctx.JSON(http.StatusOK, gin.H{"deleted": "OK", "comments": len(commentIds)})
+}
+
+func generateGetMoviesResponse(movies []db.Movies, prefs []language.Tag) []getMoviesResponse {
 	var rsp []getMoviesResponse
 	for _, movie := range movies {
 		arg := getMoviesResponse{
-			Id:     movie.Id.Hex(),
-			Plot:   movie.Plot,
-			Genres: movie.Genres,
-			Cast:   movie.Cast,
-			Poster: movie.Poster,
-			Title:  movie.Title,
-			Year:   movie.Year,
+			Id:            movie.Id.Hex(),
+			Plot:          movie.Plot,
+			Genres:        movie.Genres,
+			Cast:          movie.Cast,
+			Poster:        movie.Poster,
+			Title:         localizedTitle(movie, prefs),
+			OriginalTitle: movie.OriginalTitle,
+			Year:          movie.Year,
 			Imdb: struct {
 				Rating float64 `json:"rating"`
 				Votes  int64   `json:"votes"`
 				Id     int64   `json:"id"`
 			}(movie.Imdb),
+			Community: movie.Community,
 			Countries: movie.Countries,
 		}
 		rsp = append(rsp, arg)
@@ -142,6 +142,7 @@ func TestGetMovieApi(t *testing.T) {
 	returnMovie := db.Movies{
 		Id: objectId,
 	}
+	collection := db.Collection{ID: primitive.NewObjectID(), Title: util.RandomString(8)}
 	testCase := []struct {
 		name          string
 		movieId       string
@@ -153,12 +154,33 @@ func TestGetMovieApi(t *testing.T) {
 			movieId: objectId.Hex(),
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(objectId)).Times(1).Return(returnMovie, nil)
+				store.EXPECT().GetCollectionsByMovie(gomock.Any(), gomock.Eq(returnMovie)).
+					Times(1).
+					Return([]db.Collection{collection}, nil)
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp struct {
+					Collections []collectionSummary `json:"collections"`
+				}
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, []collectionSummary{{Id: collection.ID.Hex(), Title: collection.Title}}, rsp.Collections)
 				requireBodyMatchMovieDetails(t, returnMovie, recorder.Body)
 			},
 		},
+		{
+			name:    "CollectionsError",
+			movieId: objectId.Hex(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(objectId)).Times(1).Return(returnMovie, nil)
+				store.EXPECT().GetCollectionsByMovie(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, mongo.ErrClientDisconnected)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
 		{
 			name:    "InvalidId_Length",
 			movieId: objectId.Hex()[2:],
@@ -409,6 +431,50 @@ func TestListMoviesByGenresAPI(t *testing.T) {
 				requireBodyMatchManyMovies(t, returnMovies, recorder.Body)
 			},
 		},
+		{
+			name: "SortByTitle",
+			query: Query{
+				genres:   genres[0],
+				sort:     "title",
+				pageSize: int64(n),
+				pageId:   1,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.GetMoviesParams{
+					Genres:      genres[0],
+					SortOptions: "sort_title",
+					Skip:        0,
+					Limit:       5,
+				}
+				store.EXPECT().GetMoviesByGenres(gomock.Any(), gomock.Eq(arg)).Times(1).Return(returnMovies, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchManyMovies(t, returnMovies, recorder.Body)
+			},
+		},
+		{
+			name: "SortByCommunity",
+			query: Query{
+				genres:   genres[0],
+				sort:     "community",
+				pageSize: int64(n),
+				pageId:   1,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.GetMoviesParams{
+					Genres:      genres[0],
+					SortOptions: "sort_community",
+					Skip:        0,
+					Limit:       5,
+				}
+				store.EXPECT().GetMoviesByGenres(gomock.Any(), gomock.Eq(arg)).Times(1).Return(returnMovies, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchManyMovies(t, returnMovies, recorder.Body)
+			},
+		},
 		{
 			name: "InvalidGenres",
 			query: Query{
@@ -1033,3 +1099,81 @@ func randomMovie() db.AddMovieParams {
 		Year:     int64(date.Year()),
 	}
 }
+
+func TestDeleteMovieApi(t *testing.T) {
+	id := primitive.NewObjectID()
+	commentIds := []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID()}
+
+	testCase := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				expectTx(store)
+				store.EXPECT().DeleteMovieByID(gomock.Any(), gomock.Eq(id)).Times(1).Return(int64(1), nil)
+				store.EXPECT().DeleteCommentsByMovieID(gomock.Any(), gomock.Eq(id)).Times(1).Return(commentIds, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp struct {
+					Comments int `json:"comments"`
+				}
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, len(commentIds), rsp.Comments)
+			},
+		},
+		{
+			name: "NotFound",
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				expectTx(store)
+				store.EXPECT().DeleteMovieByID(gomock.Any(), gomock.Eq(id)).Times(1).Return(int64(0), mongo.ErrNoDocuments)
+				store.EXPECT().DeleteCommentsByMovieID(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "CommentsError",
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				expectTx(store)
+				store.EXPECT().DeleteMovieByID(gomock.Any(), gomock.Eq(id)).Times(1).Return(int64(1), nil)
+				store.EXPECT().DeleteCommentsByMovieID(gomock.Any(), gomock.Eq(id)).Times(1).Return(nil, mongo.ErrClientDisconnected)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name: "NotAdmin",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("user")).Times(1).Return(db.User{Name: "user"}, nil)
+				store.EXPECT().ExecTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := sendAuthorizedJSON(t, server, http.MethodDelete, "/movies/"+id.Hex(), nil)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package api
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	db "phantom/db/mongo"
+)
+
+var (
+	errPersonNotFound = errors.New("person is not found")
+	errUnknownPerson  = errors.New("credits name a person that doesn't exist")
+)
+
+type createPersonRequest struct {
+	Name      string   `json:"name" binding:"required,min=1"`
+	Aliases   []string `json:"aliases" binding:"dive,min=1"`
+	Photo     string   `json:"photo" binding:"omitempty,url"`
+	Biography string   `json:"biography"`
+}
+
+type filmographyEntry struct {
+	MovieID string   `json:"movie_id"`
+	Title   string   `json:"title"`
+	Year    int64    `json:"year"`
+	Poster  string   `json:"poster"`
+	Roles   []string `json:"roles"`
+}
+
+type personResponse struct {
+	Id          string             `json:"id"`
+	Name        string             `json:"name"`
+	Aliases     []string           `json:"aliases"`
+	Photo       string             `json:"photo"`
+	Biography   string             `json:"biography"`
+	Filmography []filmographyEntry `json:"filmography,omitempty"`
+}
+
+func newPersonResponse(person db.Person) personResponse {
+	return personResponse{
+		Id:        person.ID.Hex(),
+		Name:      person.Name,
+		Aliases:   person.Aliases,
+		Photo:     person.Photo,
+		Biography: person.Biography,
+	}
+}
+
+func (server *Server) createPerson(ctx *gin.Context) {
+	var req createPersonRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	id, err := server.store.AddPerson(ctx, db.AddPersonParams{
+		Name:      req.Name,
+		Aliases:   req.Aliases,
+		Photo:     req.Photo,
+		Biography: req.Biography,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"id": id.Hex()})
+}
+
+type personIdRequest struct {
+	Id string `uri:"id" binding:"required,hexadecimal,min=24"`
+}
+
+// getPerson is to get the details of a person and every movie they are credited in
+func (server *Server) getPerson(ctx *gin.Context) {
+	var req personIdRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	objectID, err := primitive.ObjectIDFromHex(req.Id)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	person, err := server.store.GetPersonByID(ctx, objectID)
+	if err != nil {
+		if mongo.ErrNoDocuments == err {
+			ctx.JSON(http.StatusNotFound, errorResponse(errPersonNotFound))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	movies, err := server.store.GetMoviesByPersonID(ctx, objectID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	prefs := server.preferredLanguages(ctx)
+	rsp := newPersonResponse(person)
+	for _, movie := range movies {
+		entry := filmographyEntry{
+			MovieID: movie.Id.Hex(),
+			Title:   localizedTitle(movie, prefs),
+			Year:    movie.Year,
+			Poster:  movie.Poster,
+		}
+		for _, credit := range movie.Credits {
+			if credit.PersonID == objectID {
+				entry.Roles = append(entry.Roles, credit.Role)
+			}
+		}
+		rsp.Filmography = append(rsp.Filmography, entry)
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// updatePerson replaces the details of a person, a new name also
+// replaces the old one in the movies the person is credited in
+func (server *Server) updatePerson(ctx *gin.Context) {
+	var reqUri personIdRequest
+	var reqJson createPersonRequest
+	if err := ctx.ShouldBindUri(&reqUri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if err := ctx.ShouldBindJSON(&reqJson); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	objectID, err := primitive.ObjectIDFromHex(reqUri.Id)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	person := db.Person{
+		ID:        objectID,
+		Name:      reqJson.Name,
+		Aliases:   reqJson.Aliases,
+		Photo:     reqJson.Photo,
+		Biography: reqJson.Biography,
+	}
+	_, err = server.store.UpdatePerson(ctx, person)
+	if err != nil {
+		if mongo.ErrNoDocuments == err {
+			ctx.JSON(http.StatusNotFound, errorResponse(errPersonNotFound))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	server.reindexMoviesOf(ctx, objectID)
+	ctx.JSON(http.StatusOK, gin.H{"updated": "OK"})
+}
+
+// reindexMoviesOf reloads the movies a person is credited in,
+// whose name arrays may have just changed
+func (server *Server) reindexMoviesOf(ctx *gin.Context, personID primitive.ObjectID) {
+	credited, err := server.store.GetMoviesByPersonID(ctx, personID)
+	if err != nil || len(credited) == 0 {
+		// The next BuildIndexes picks the change up
+		return
+	}
+	ids := make([]primitive.ObjectID, len(credited))
+	for i, movie := range credited {
+		ids[i] = movie.Id
+	}
+	movies, err := server.store.GetMoviesByIDs(ctx, ids)
+	if err != nil {
+		return
+	}
+	for _, movie := range movies {
+		server.indexMovie(movie)
+	}
+}
+
+type searchPeopleRequest struct {
+	Search   string `form:"search" binding:"required,min=1"`
+	PageSize int64  `form:"s" binding:"required,min=1,max=50"`
+	PageId   int64  `form:"p" binding:"required,min=1"`
+}
+
+// searchPeople is to find people by their name or one of their aliases
+func (server *Server) searchPeople(ctx *gin.Context) {
+	var req searchPeopleRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	people, err := server.store.SearchPeople(ctx, db.SearchPeopleParams{
+		Text:  req.Search,
+		Skip:  req.PageSize * (req.PageId - 1),
+		Limit: req.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := []personResponse{}
+	for _, person := range people {
+		rsp = append(rsp, newPersonResponse(person))
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+type creditRequest struct {
+	PersonID string `json:"person_id" binding:"required,hexadecimal,min=24"`
+	Role     string `json:"role" binding:"required,oneof=cast director writer"`
+}
+
+// movieCredits are the people of a movie, both linked and by name
+type movieCredits struct {
+	Credits   []db.Credit
+	Cast      []string
+	Directors []string
+	Writers   []string
+}
+
+// resolveCredits links a movie to the people in credits, and fills the name
+// arrays from their names. Without credits, the names given are kept as they are
+func (server *Server) resolveCredits(ctx *gin.Context, credits []creditRequest, cast, directors, writers []string) (movieCredits, error) {
+	if len(credits) == 0 {
+		return movieCredits{Cast: cast, Directors: directors, Writers: writers}, nil
+	}
+
+	var rsp movieCredits
+	var ids []primitive.ObjectID
+	for _, credit := range credits {
+		id, err := primitive.ObjectIDFromHex(credit.PersonID)
+		if err != nil {
+			return movieCredits{}, errUnknownPerson
+		}
+		ids = append(ids, id)
+		rsp.Credits = append(rsp.Credits, db.Credit{PersonID: id, Role: credit.Role})
+	}
+	people, err := server.store.GetPeopleByIDs(ctx, ids)
+	if err != nil {
+		return movieCredits{}, err
+	}
+	names := make(map[primitive.ObjectID]string, len(people))
+	for _, person := range people {
+		names[person.ID] = person.Name
+	}
+
+	for _, credit := range rsp.Credits {
+		name, ok := names[credit.PersonID]
+		if !ok {
+			return movieCredits{}, errUnknownPerson
+		}
+		switch credit.Role {
+		case db.RoleCast:
+			rsp.Cast = append(rsp.Cast, name)
+		case db.RoleDirector:
+			rsp.Directors = append(rsp.Directors, name)
+		case db.RoleWriter:
+			rsp.Writers = append(rsp.Writers, name)
+		}
+	}
+	return rsp, nil
+}
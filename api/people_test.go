@@ -0,0 +1,447 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"net/http/httptest"
+	mockdb "phantom/db/mock"
+	db "phantom/db/mongo"
+	"phantom/util"
+	"testing"
+)
+
+func randomPerson() db.Person {
+	return db.Person{
+		ID:        primitive.NewObjectID(),
+		Name:      util.RandomString(6) + " " + util.RandomString(8),
+		Aliases:   []string{util.RandomString(10)},
+		Photo:     "https://example.com/" + util.RandomString(8) + ".jpg",
+		Biography: util.RandomString(30),
+	}
+}
+
+func TestCreatePersonAPI(t *testing.T) {
+	person := randomPerson()
+	testCase := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"name": person.Name, "aliases": person.Aliases, "photo": person.Photo, "biography": person.Biography},
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				arg := db.AddPersonParams{
+					Name:      person.Name,
+					Aliases:   person.Aliases,
+					Photo:     person.Photo,
+					Biography: person.Biography,
+				}
+				store.EXPECT().AddPerson(gomock.Any(), gomock.Eq(arg)).Times(1).Return(person.ID, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchObjectId(t, person.ID, recorder.Body)
+			},
+		},
+		{
+			name: "InvalidName",
+			body: gin.H{"aliases": person.Aliases},
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().AddPerson(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidPhoto",
+			body: gin.H{"name": person.Name, "photo": "not a url"},
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().AddPerson(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "InternalError",
+			body: gin.H{"name": person.Name},
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().AddPerson(gomock.Any(), gomock.Any()).Times(1).Return(primitive.ObjectID{}, mongo.ErrClientDisconnected)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name: "NotAdmin",
+			body: gin.H{"name": person.Name},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("user")).Times(1).Return(db.User{Name: "user"}, nil)
+				store.EXPECT().AddPerson(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := sendAuthorizedJSON(t, server, http.MethodPost, "/people", tc.body)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestGetPersonAPI(t *testing.T) {
+	person := randomPerson()
+	movies := []db.Movies{
+		{
+			Id:      primitive.NewObjectID(),
+			Title:   util.RandomString(8),
+			Year:    2010,
+			Credits: []db.Credit{{PersonID: person.ID, Role: db.RoleCast}, {PersonID: person.ID, Role: db.RoleDirector}},
+		},
+		{
+			Id:      primitive.NewObjectID(),
+			Title:   util.RandomString(8),
+			Year:    1990,
+			Credits: []db.Credit{{PersonID: primitive.NewObjectID(), Role: db.RoleCast}, {PersonID: person.ID, Role: db.RoleWriter}},
+		},
+	}
+
+	testCase := []struct {
+		name          string
+		personId      string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			personId: person.ID.Hex(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetPersonByID(gomock.Any(), gomock.Eq(person.ID)).Times(1).Return(person, nil)
+				store.EXPECT().GetMoviesByPersonID(gomock.Any(), gomock.Eq(person.ID)).Times(1).Return(movies, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp personResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, person.Name, rsp.Name)
+				require.Equal(t, person.Aliases, rsp.Aliases)
+				require.Len(t, rsp.Filmography, 2)
+				require.Equal(t, movies[0].Id.Hex(), rsp.Filmography[0].MovieID)
+				require.Equal(t, []string{db.RoleCast, db.RoleDirector}, rsp.Filmography[0].Roles)
+				require.Equal(t, []string{db.RoleWriter}, rsp.Filmography[1].Roles)
+			},
+		},
+		{
+			name:     "InvalidId",
+			personId: util.RandomString(24),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetPersonByID(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "NotFound",
+			personId: person.ID.Hex(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetPersonByID(gomock.Any(), gomock.Eq(person.ID)).Times(1).Return(db.Person{}, mongo.ErrNoDocuments)
+				store.EXPECT().GetMoviesByPersonID(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:     "InternalError",
+			personId: person.ID.Hex(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetPersonByID(gomock.Any(), gomock.Eq(person.ID)).Times(1).Return(person, nil)
+				store.EXPECT().GetMoviesByPersonID(gomock.Any(), gomock.Any()).Times(1).Return(nil, mongo.ErrClientDisconnected)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/people/%s", tc.personId), nil)
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestUpdatePersonAPI(t *testing.T) {
+	person := randomPerson()
+	movie := db.Movies{Id: primitive.NewObjectID(), Title: util.RandomString(8), Cast: []string{person.Name}}
+
+	testCase := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().UpdatePerson(gomock.Any(), gomock.Eq(person)).Times(1).Return(&mongo.UpdateResult{ModifiedCount: 1}, nil)
+				store.EXPECT().GetMoviesByPersonID(gomock.Any(), gomock.Eq(person.ID)).Times(1).Return([]db.Movies{{Id: movie.Id}}, nil)
+				store.EXPECT().GetMoviesByIDs(gomock.Any(), gomock.Eq([]primitive.ObjectID{movie.Id})).Times(1).Return([]db.Movies{movie}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "NotFound",
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().UpdatePerson(gomock.Any(), gomock.Any()).Times(1).Return(nil, mongo.ErrNoDocuments)
+				store.EXPECT().GetMoviesByPersonID(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "InternalError",
+			buildStubs: func(store *mockdb.MockStore) {
+				expectAdmin(store)
+				store.EXPECT().UpdatePerson(gomock.Any(), gomock.Any()).Times(1).Return(nil, mongo.ErrClientDisconnected)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name: "NotAdmin",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("user")).Times(1).Return(db.User{Name: "user"}, nil)
+				store.EXPECT().UpdatePerson(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := sendAuthorizedJSON(t, server, http.MethodPut, "/people/"+person.ID.Hex(), gin.H{
+				"name":      person.Name,
+				"aliases":   person.Aliases,
+				"photo":     person.Photo,
+				"biography": person.Biography,
+			})
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestSearchPeopleAPI(t *testing.T) {
+	people := []db.Person{randomPerson(), randomPerson()}
+
+	testCase := []struct {
+		name          string
+		query         map[string]string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			query: map[string]string{"search": "nol", "s": "10", "p": "2"},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.SearchPeopleParams{Text: "nol", Skip: 10, Limit: 10}
+				store.EXPECT().SearchPeople(gomock.Any(), gomock.Eq(arg)).Times(1).Return(people, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp []personResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Len(t, rsp, 2)
+				require.Equal(t, people[0].ID.Hex(), rsp[0].Id)
+			},
+		},
+		{
+			name:  "InvalidSearch",
+			query: map[string]string{"s": "10", "p": "1"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().SearchPeople(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "InternalError",
+			query: map[string]string{"search": "nol", "s": "10", "p": "1"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().SearchPeople(gomock.Any(), gomock.Any()).Times(1).Return(nil, mongo.ErrClientDisconnected)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, "/search/people", nil)
+			require.NoError(t, err)
+			q := request.URL.Query()
+			for key, value := range tc.query {
+				q.Add(key, value)
+			}
+			request.URL.RawQuery = q.Encode()
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestCreateMovieWithCreditsAPI(t *testing.T) {
+	director, actor := randomPerson(), randomPerson()
+	returnId := primitive.NewObjectID()
+
+	testCase := []struct {
+		name          string
+		credits       []gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			credits: []gin.H{
+				{"person_id": actor.ID.Hex(), "role": db.RoleCast},
+				{"person_id": director.ID.Hex(), "role": db.RoleDirector},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetPeopleByIDs(gomock.Any(), gomock.Eq([]primitive.ObjectID{actor.ID, director.ID})).
+					Times(1).
+					Return([]db.Person{director, actor}, nil)
+				store.EXPECT().AddMovie(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.AddMovieParams) (primitive.ObjectID, error) {
+						require.Equal(t, []string{actor.Name}, arg.Cast)
+						require.Equal(t, []string{director.Name}, arg.Directors)
+						require.Equal(t, []db.Credit{
+							{PersonID: actor.ID, Role: db.RoleCast},
+							{PersonID: director.ID, Role: db.RoleDirector},
+						}, arg.Credits)
+						return returnId, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:    "UnknownPerson",
+			credits: []gin.H{{"person_id": actor.ID.Hex(), "role": db.RoleCast}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetPeopleByIDs(gomock.Any(), gomock.Any()).Times(1).Return(nil, nil)
+				store.EXPECT().AddMovie(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:    "InvalidRole",
+			credits: []gin.H{{"person_id": actor.ID.Hex(), "role": "producer"}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetPeopleByIDs(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().AddMovie(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:    "InternalError",
+			credits: []gin.H{{"person_id": actor.ID.Hex(), "role": db.RoleCast}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetPeopleByIDs(gomock.Any(), gomock.Any()).Times(1).Return(nil, mongo.ErrClientDisconnected)
+				store.EXPECT().AddMovie(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := sendAuthorizedJSON(t, server, http.MethodPost, "/movies", gin.H{
+				"title":   util.RandomString(8),
+				"credits": tc.credits,
+			})
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package api
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	db "phantom/db/mongo"
+	"phantom/token"
+)
+
+// defaultWatchedThreshold is used when the config doesn't set WATCHED_THRESHOLD
+const defaultWatchedThreshold = 0.9
+
+var errSeriesPlayback = errors.New("a series is played episode by episode")
+
+type saveProgressRequest struct {
+	// Position and Duration are in seconds
+	Position int64 `json:"position" binding:"min=0,ltefield=Duration"`
+	Duration int64 `json:"duration" binding:"required,min=1"`
+}
+
+// saveProgress is to record how far the signed-in user got in a movie or an episode,
+// the player sends it every few seconds
+func (server *Server) saveProgress(ctx *gin.Context) {
+	var uri listMovieRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req saveProgressRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	mediaID, err := primitive.ObjectIDFromHex(uri.MovieId)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	playable, ok := server.findPlayable(ctx, mediaID)
+	if !ok {
+		return
+	}
+
+	watched := float64(req.Position) >= server.config.WatchedThreshold*float64(req.Duration)
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	err = server.store.SaveProgress(ctx, db.SaveProgressParams{
+		Name:     authPayload.Username,
+		MediaID:  mediaID,
+		SeriesID: playable.SeriesID,
+		Position: req.Position,
+		Duration: req.Duration,
+		Watched:  watched,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"watched": watched})
+}
+
+// playable is a movie, or an episode of SeriesID.
+// Runtime is in minutes, 0 when it isn't known
+type playable struct {
+	SeriesID primitive.ObjectID
+	Runtime  int64
+}
+
+// findPlayable gets the movie or else the episode id is. It writes the response
+// and returns false when there is none, or when id is a series'
+func (server *Server) findPlayable(ctx *gin.Context, id primitive.ObjectID) (playable, bool) {
+	movie, err := server.store.GetMovieByID(ctx, id)
+	switch {
+	case err == nil:
+		if movie.Type == db.TypeSeries {
+			ctx.JSON(http.StatusBadRequest, errorResponse(errSeriesPlayback))
+			return playable{}, false
+		}
+		return playable{Runtime: movie.Runtime}, true
+	case mongo.ErrNoDocuments == err:
+		episode, err := server.store.GetEpisodeByID(ctx, id)
+		if err != nil {
+			if mongo.ErrNoDocuments == err {
+				ctx.JSON(http.StatusNotFound, errorResponse(errMovieNotFound))
+				return playable{}, false
+			}
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return playable{}, false
+		}
+		return playable{SeriesID: episode.SeriesID, Runtime: episode.Runtime}, true
+	}
+	ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+	return playable{}, false
+}
+
+// media is a movie, or a series with one of its episodes
+type media struct {
+	Movie   getMoviesResponse `json:"movie"`
+	Episode *db.Episode       `json:"episode,omitempty"`
+}
+
+// mediaRef points at a movie, or at an episode when SeriesID is set
+type mediaRef struct {
+	MediaID  primitive.ObjectID
+	SeriesID primitive.ObjectID
+}
+
+// findMedia gets the movies and episodes refs point at, in the order of refs.
+// The ones that were removed from the catalog are nil
+func (server *Server) findMedia(ctx *gin.Context, refs []mediaRef) ([]*media, error) {
+	var movieIDs, episodeIDs []primitive.ObjectID
+	for _, ref := range refs {
+		if ref.SeriesID.IsZero() {
+			movieIDs = append(movieIDs, ref.MediaID)
+		} else {
+			movieIDs = append(movieIDs, ref.SeriesID)
+			episodeIDs = append(episodeIDs, ref.MediaID)
+		}
+	}
+	movies, err := server.store.GetMoviesByIDs(ctx, movieIDs)
+	if err != nil {
+		return nil, err
+	}
+	episodes := make(map[primitive.ObjectID]db.Episode, len(episodeIDs))
+	if len(episodeIDs) > 0 {
+		found, err := server.store.GetEpisodesByIDs(ctx, episodeIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, episode := range found {
+			episodes[episode.ID] = episode
+		}
+	}
+	byID := make(map[string]getMoviesResponse, len(movies))
+	for _, movie := range generateGetMoviesResponse(movies, server.preferredLanguages(ctx)) {
+		byID[movie.Id] = movie
+	}
+
+	found := make([]*media, len(refs))
+	for i, ref := range refs {
+		item := &media{}
+		movieID := ref.MediaID
+		if !ref.SeriesID.IsZero() {
+			episode, ok := episodes[ref.MediaID]
+			if !ok {
+				continue
+			}
+			item.Episode = &episode
+			movieID = ref.SeriesID
+		}
+		movie, ok := byID[movieID.Hex()]
+		if !ok {
+			continue
+		}
+		item.Movie = movie
+		found[i] = item
+	}
+	return found, nil
+}
+
+// continueWatchingResponse is a movie the user started, or a series with
+// the episode the user was in
+type continueWatchingResponse struct {
+	media
+	Position  int64              `json:"position"`
+	Duration  int64              `json:"duration"`
+	UpdatedAt primitive.DateTime `json:"updated_at"`
+}
+
+// listContinueWatching is to get the movies and episodes the signed-in user
+// started and hasn't finished, the most recently watched first
+func (server *Server) listContinueWatching(ctx *gin.Context) {
+	var req getMoviesRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	progress, err := server.store.GetContinueWatching(ctx, db.GetContinueWatchingParams{
+		Name:  authPayload.Username,
+		Skip:  req.PageSize * (req.PageId - 1),
+		Limit: req.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	rsp := []continueWatchingResponse{}
+	if len(progress) == 0 {
+		ctx.JSON(http.StatusOK, rsp)
+		return
+	}
+
+	refs := make([]mediaRef, len(progress))
+	for i, p := range progress {
+		refs[i] = mediaRef{MediaID: p.MediaID, SeriesID: p.SeriesID}
+	}
+	found, err := server.findMedia(ctx, refs)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	for i, p := range progress {
+		if found[i] == nil {
+			continue
+		}
+		rsp = append(rsp, continueWatchingResponse{
+			media:     *found[i],
+			Position:  p.Position,
+			Duration:  p.Duration,
+			UpdatedAt: p.UpdatedAt,
+		})
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// markWatched sets which movies of a list of the signed-in user they watched
+func (server *Server) markWatched(ctx *gin.Context, name string, movies []getMoviesResponse) error {
+	if len(movies) == 0 {
+		return nil
+	}
+	ids := make([]primitive.ObjectID, 0, len(movies))
+	for _, movie := range movies {
+		id, err := primitive.ObjectIDFromHex(movie.Id)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	progress, err := server.store.GetProgressByMediaIDs(ctx, name, ids)
+	if err != nil {
+		return err
+	}
+	watched := make(map[string]bool, len(progress))
+	for _, p := range progress {
+		watched[p.MediaID.Hex()] = p.Watched
+	}
+	for i := range movies {
+		w := watched[movies[i].Id]
+		movies[i].Watched = &w
+	}
+	return nil
+}
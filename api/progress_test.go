@@ -0,0 +1,241 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"net/http/httptest"
+	mockdb "phantom/db/mock"
+	db "phantom/db/mongo"
+	"phantom/util"
+	"testing"
+)
+
+func TestSaveProgressAPI(t *testing.T) {
+	movie := db.Movies{Id: primitive.NewObjectID(), Title: util.RandomString(8)}
+	series := randomSeries()
+	episode := randomEpisodes(series)[1]
+
+	testCase := []struct {
+		name          string
+		mediaId       string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:    "Movie",
+			mediaId: movie.Id.Hex(),
+			body:    gin.H{"position": 600, "duration": 6000},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.SaveProgressParams{Name: "user", MediaID: movie.Id, Position: 600, Duration: 6000}
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(movie.Id)).Times(1).Return(movie, nil)
+				store.EXPECT().SaveProgress(gomock.Any(), gomock.Eq(arg)).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp struct{ Watched bool }
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.False(t, rsp.Watched)
+			},
+		},
+		{
+			name:    "Watched",
+			mediaId: movie.Id.Hex(),
+			body:    gin.H{"position": 5400, "duration": 6000},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.SaveProgressParams{Name: "user", MediaID: movie.Id, Position: 5400, Duration: 6000, Watched: true}
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(movie.Id)).Times(1).Return(movie, nil)
+				store.EXPECT().SaveProgress(gomock.Any(), gomock.Eq(arg)).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp struct{ Watched bool }
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.True(t, rsp.Watched)
+			},
+		},
+		{
+			name:    "Episode",
+			mediaId: episode.ID.Hex(),
+			body:    gin.H{"position": 0, "duration": 1500},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.SaveProgressParams{Name: "user", MediaID: episode.ID, SeriesID: series.Id, Duration: 1500}
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(episode.ID)).Times(1).Return(db.Movies{}, mongo.ErrNoDocuments)
+				store.EXPECT().GetEpisodeByID(gomock.Any(), gomock.Eq(episode.ID)).Times(1).Return(episode, nil)
+				store.EXPECT().SaveProgress(gomock.Any(), gomock.Eq(arg)).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:    "Series",
+			mediaId: series.Id.Hex(),
+			body:    gin.H{"position": 10, "duration": 1500},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(series.Id)).Times(1).Return(series, nil)
+				store.EXPECT().SaveProgress(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:    "NotFound",
+			mediaId: movie.Id.Hex(),
+			body:    gin.H{"position": 10, "duration": 1500},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(movie.Id)).Times(1).Return(db.Movies{}, mongo.ErrNoDocuments)
+				store.EXPECT().GetEpisodeByID(gomock.Any(), gomock.Eq(movie.Id)).Times(1).Return(db.Episode{}, mongo.ErrNoDocuments)
+				store.EXPECT().SaveProgress(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:    "PositionPastDuration",
+			mediaId: movie.Id.Hex(),
+			body:    gin.H{"position": 1600, "duration": 1500},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:    "MissingDuration",
+			mediaId: movie.Id.Hex(),
+			body:    gin.H{"position": 10},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:    "InternalError",
+			mediaId: movie.Id.Hex(),
+			body:    gin.H{"position": 10, "duration": 1500},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(movie.Id)).Times(1).Return(movie, nil)
+				store.EXPECT().SaveProgress(gomock.Any(), gomock.Any()).Times(1).Return(mongo.ErrClientDisconnected)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := sendAuthorizedJSON(t, server, http.MethodPut, "/me/progress/"+tc.mediaId, tc.body)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestListContinueWatchingAPI(t *testing.T) {
+	movie := db.Movies{Id: primitive.NewObjectID(), Title: util.RandomString(8)}
+	series := randomSeries()
+	episode := randomEpisodes(series)[2]
+	progress := []db.Progress{
+		{Name: "user", MediaID: episode.ID, SeriesID: series.Id, Position: 300, Duration: 1500},
+		{Name: "user", MediaID: movie.Id, Position: 600, Duration: 6000},
+		// Removed from the catalog
+		{Name: "user", MediaID: primitive.NewObjectID(), Position: 10, Duration: 6000},
+	}
+
+	testCase := []struct {
+		name          string
+		query         string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			query: "?s=10&p=1",
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.GetContinueWatchingParams{Name: "user", Skip: 0, Limit: 10}
+				store.EXPECT().GetContinueWatching(gomock.Any(), gomock.Eq(arg)).Times(1).Return(progress, nil)
+				store.EXPECT().GetMoviesByIDs(gomock.Any(), gomock.Len(3)).Times(1).Return([]db.Movies{movie, series}, nil)
+				store.EXPECT().GetEpisodesByIDs(gomock.Any(), gomock.Eq([]primitive.ObjectID{episode.ID})).Times(1).Return([]db.Episode{episode}, nil)
+				store.EXPECT().GetUserByName(gomock.Any(), gomock.Any()).AnyTimes().Return(db.User{Name: "user"}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp []continueWatchingResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Len(t, rsp, 2)
+				require.Equal(t, series.Id.Hex(), rsp[0].Movie.Id)
+				require.NotNil(t, rsp[0].Episode)
+				require.Equal(t, episode.ID, rsp[0].Episode.ID)
+				require.Equal(t, int64(300), rsp[0].Position)
+				require.Equal(t, movie.Id.Hex(), rsp[1].Movie.Id)
+				require.Nil(t, rsp[1].Episode)
+			},
+		},
+		{
+			name:  "Empty",
+			query: "?s=10&p=1",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetContinueWatching(gomock.Any(), gomock.Any()).Times(1).Return(nil, nil)
+				store.EXPECT().GetMoviesByIDs(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.JSONEq(t, "[]", recorder.Body.String())
+			},
+		},
+		{
+			name:  "InvalidPageSize",
+			query: "?s=100&p=1",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetContinueWatching(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "InternalError",
+			query: "?s=10&p=1",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetContinueWatching(gomock.Any(), gomock.Any()).Times(1).Return(nil, mongo.ErrClientDisconnected)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := sendAuthorizedJSON(t, server, http.MethodGet, "/me/continue-watching"+tc.query, nil)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
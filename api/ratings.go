@@ -0,0 +1,91 @@
+package api
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	db "phantom/db/mongo"
+	"phantom/token"
+)
+
+var errRatingNotFound = errors.New("the movie is not rated")
+
+type rateMovieRequest struct {
+	Score int64 `json:"score" binding:"required,min=1,max=10"`
+}
+
+// rateMovie sets the score from 1 to 10 the signed-in user gives a movie,
+// a user has one score for a movie and rating it again replaces it
+func (server *Server) rateMovie(ctx *gin.Context) {
+	var reqUri movieIdRequest
+	var reqJson rateMovieRequest
+	if err := ctx.ShouldBindUri(&reqUri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if err := ctx.ShouldBindJSON(&reqJson); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	objectID, err := primitive.ObjectIDFromHex(reqUri.Id)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if _, err = server.store.GetMovieByID(ctx, objectID); err != nil {
+		if mongo.ErrNoDocuments == err {
+			ctx.JSON(http.StatusNotFound, errorResponse(errMovieNotFound))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	// The score and the community rating of the movie are written together
+	err = server.store.ExecTx(ctx, func(q db.Querier) error {
+		return q.RateMovie(ctx, db.RateMovieParams{
+			Name:    authPayload.Username,
+			MovieID: objectID,
+			Score:   reqJson.Score,
+		})
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	// Rating a movie again earns nothing
+	server.earnCoins(ctx, db.CoinEarnRating, authPayload.Username+":"+objectID.Hex(), authPayload.Username, ratingReward)
+	ctx.JSON(http.StatusOK, gin.H{"score": reqJson.Score})
+}
+
+// deleteRating takes back the score the signed-in user gave a movie
+func (server *Server) deleteRating(ctx *gin.Context) {
+	var req movieIdRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	objectID, err := primitive.ObjectIDFromHex(req.Id)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	err = server.store.ExecTx(ctx, func(q db.Querier) error {
+		return q.DeleteRating(ctx, authPayload.Username, objectID)
+	})
+	if err != nil {
+		if mongo.ErrNoDocuments == err {
+			ctx.JSON(http.StatusNotFound, errorResponse(errRatingNotFound))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"deleted": "OK"})
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"net/http/httptest"
+	mockdb "phantom/db/mock"
+	db "phantom/db/mongo"
+	"phantom/util"
+	"testing"
+	"time"
+)
+
+func TestRateMovieAPI(t *testing.T) {
+	movie := db.Movies{Id: primitive.NewObjectID(), Title: util.RandomString(8)}
+
+	testCase := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"score": 8},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.RateMovieParams{Name: "user", MovieID: movie.Id, Score: 8}
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(movie.Id)).Times(1).Return(movie, nil)
+				expectTx(store)
+				store.EXPECT().RateMovie(gomock.Any(), gomock.Eq(arg)).Times(1).Return(nil)
+				expectReward(store, db.CoinEarnRating, "user:"+movie.Id.Hex(), "user", ratingReward)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Equal(t, `{"score":8}`, recorder.Body.String())
+			},
+		},
+		{
+			name: "ScoreTooHigh",
+			body: gin.H{"score": 11},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().RateMovie(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "ScoreMissing",
+			body: gin.H{},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().RateMovie(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "MovieNotFound",
+			body: gin.H{"score": 8},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(movie.Id)).Times(1).Return(db.Movies{}, mongo.ErrNoDocuments)
+				store.EXPECT().RateMovie(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "InternalError",
+			body: gin.H{"score": 8},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(movie.Id)).Times(1).Return(movie, nil)
+				expectTx(store)
+				store.EXPECT().RateMovie(gomock.Any(), gomock.Any()).Times(1).Return(mongo.ErrClientDisconnected)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := sendAuthorizedJSON(t, server, http.MethodPut, "/movies/"+movie.Id.Hex()+"/rating", tc.body)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestDeleteRatingAPI(t *testing.T) {
+	movieId := primitive.NewObjectID()
+
+	testCase := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				expectTx(store)
+				store.EXPECT().DeleteRating(gomock.Any(), gomock.Eq("user"), gomock.Eq(movieId)).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "NotRated",
+			buildStubs: func(store *mockdb.MockStore) {
+				expectTx(store)
+				store.EXPECT().DeleteRating(gomock.Any(), gomock.Eq("user"), gomock.Eq(movieId)).Times(1).Return(mongo.ErrNoDocuments)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := sendAuthorizedJSON(t, server, http.MethodDelete, "/movies/"+movieId.Hex()+"/rating", nil)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestGetMovieUserRatingAPI(t *testing.T) {
+	movie := db.Movies{Id: primitive.NewObjectID(), Title: util.RandomString(8)}
+	movie.Community = db.CommunityRating{Rating: 7.5, Count: 2, Sum: 15}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(movie.Id)).Times(1).Return(movie, nil)
+	store.EXPECT().GetCollectionsByMovie(gomock.Any(), gomock.Any()).Times(1).Return(nil, nil)
+	store.EXPECT().GetUserByName(gomock.Any(), gomock.Eq("user")).Times(1).Return(db.User{Name: "user"}, nil)
+	store.EXPECT().GetListsByMovie(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(nil, nil)
+	store.EXPECT().GetRating(gomock.Any(), gomock.Eq("user"), gomock.Eq(movie.Id)).
+		Times(1).
+		Return(db.Rating{Name: "user", MovieID: movie.Id, Score: 9}, nil)
+	server := newTestServer(t, store)
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/movies/"+movie.Id.Hex(), nil)
+	require.NoError(t, err)
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "user", time.Minute)
+	server.router.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var rsp movieResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+	require.NotNil(t, rsp.UserRating)
+	require.Equal(t, int64(9), *rsp.UserRating)
+	require.Equal(t, movie.Community, rsp.Community)
+}
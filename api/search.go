@@ -0,0 +1,214 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	db "phantom/db/mongo"
+	"phantom/search"
+	"time"
+)
+
+const (
+	searchBackendMongo    = "mongo"
+	searchBackendEmbedded = "embedded"
+)
+
+var errSearchIndexDisabled = errors.New("comment search needs the embedded search backend")
+
+// newSearchIndex creates the search index selected by config, the embedded
+// one by default since it segments Chinese and matches pinyin, which $text can't.
+// The mongo backend uses the $text index so it needs none
+func newSearchIndex(backend string) (search.SearchIndex, error) {
+	switch backend {
+	case "", searchBackendEmbedded:
+		return search.NewIndex(search.NewChineseAnalyzer(), search.DefaultWeights), nil
+	case searchBackendMongo:
+		return nil, nil
+	}
+	return nil, errors.New("unknown search backend " + backend)
+}
+
+// BuildIndexes loads the in-process indexes from the database
+func (server *Server) BuildIndexes(ctx context.Context) error {
+	movies, err := server.store.GetAllMovies(ctx)
+	if err != nil {
+		return err
+	}
+	server.suggester.Rebuild(movies)
+
+	if server.searchIndex == nil {
+		return nil
+	}
+	comments, err := server.store.GetAllComments(ctx)
+	if err != nil {
+		return err
+	}
+	server.searchIndex.Rebuild(movies, comments)
+	return nil
+}
+
+// indexMovie keeps the in-process indexes in step with a movie that was just written
+func (server *Server) indexMovie(movie db.Movies) {
+	server.suggester.AddMovie(movie)
+	if server.searchIndex != nil {
+		server.searchIndex.IndexMovie(movie)
+	}
+}
+
+func (server *Server) removeMovie(id primitive.ObjectID) {
+	server.suggester.RemoveMovie(id)
+	if server.searchIndex != nil {
+		server.searchIndex.RemoveMovie(id)
+	}
+}
+
+func (server *Server) indexComment(comment db.Comments) {
+	if server.searchIndex != nil {
+		server.searchIndex.IndexComment(comment)
+	}
+}
+
+// reindexComment reloads a comment that was changed in place
+func (server *Server) reindexComment(ctx context.Context, id primitive.ObjectID) {
+	if server.searchIndex == nil {
+		return
+	}
+	comment, err := server.store.GetComment(ctx, id)
+	if err != nil {
+		// The next BuildIndexes picks the change up
+		return
+	}
+	server.searchIndex.IndexComment(comment)
+}
+
+func (server *Server) removeComment(id primitive.ObjectID) {
+	if server.searchIndex != nil {
+		server.searchIndex.RemoveComment(id)
+	}
+}
+
+// searchMoviesWithIndex answers searchForMovies from the embedded index,
+// with highlighted snippets of the matching fields
+func (server *Server) searchMoviesWithIndex(ctx *gin.Context, req searchForMoviesRequest) {
+	result, err := server.searchIndex.Search(search.Request{
+		Query: req.Search,
+		Type:  search.TypeMovie,
+		Skip:  int(req.PageSize * (req.PageId - 1)),
+		Limit: int(req.PageSize),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if len(result.Hits) == 0 {
+		ctx.JSON(http.StatusOK, generateGetMoviesResponse(nil, nil))
+		return
+	}
+
+	ids := make([]primitive.ObjectID, len(result.Hits))
+	for i, hit := range result.Hits {
+		ids[i] = hit.ID
+	}
+	movies, err := server.store.GetMoviesByIDs(ctx, ids)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	moviesByID := make(map[primitive.ObjectID]db.Movies, len(movies))
+	for _, movie := range movies {
+		moviesByID[movie.Id] = movie
+	}
+
+	// Keep the ranking of the index, and skip hits deleted since they were indexed
+	var ranked []db.Movies
+	var highlights []map[string][]string
+	for _, hit := range result.Hits {
+		if movie, ok := moviesByID[hit.ID]; ok {
+			ranked = append(ranked, movie)
+			highlights = append(highlights, hit.Highlights)
+		}
+	}
+	rsp := generateGetMoviesResponse(ranked, server.preferredLanguages(ctx))
+	for i := range rsp {
+		rsp[i].Highlights = highlights[i]
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+type searchCommentsRequest struct {
+	Search   string `form:"search" binding:"required,min=1"`
+	MovieID  string `form:"movie_id" binding:"omitempty,hexadecimal,min=24"`
+	PageSize int64  `form:"s" binding:"required,min=1,max=50"`
+	PageId   int64  `form:"p" binding:"required,min=1"`
+}
+
+type searchCommentsResponse struct {
+	Id         string              `json:"id"`
+	MovieID    string              `json:"movie_id"`
+	Name       string              `json:"name"`
+	Text       string              `json:"text"`
+	Highlights map[string][]string `json:"highlights,omitempty"`
+}
+
+// searchComments is to search the text of comments, optionally on one movie
+func (server *Server) searchComments(ctx *gin.Context) {
+	var req searchCommentsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if server.searchIndex == nil {
+		ctx.JSON(http.StatusNotImplemented, errorResponse(errSearchIndexDisabled))
+		return
+	}
+
+	searchReq := search.Request{
+		Query: req.Search,
+		Type:  search.TypeComment,
+		Skip:  int(req.PageSize * (req.PageId - 1)),
+		Limit: int(req.PageSize),
+	}
+	if req.MovieID != "" {
+		objectId, err := primitive.ObjectIDFromHex(req.MovieID)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		searchReq.MovieID = objectId
+	}
+	result, err := server.searchIndex.Search(searchReq)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	rsp := make([]searchCommentsResponse, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		rsp = append(rsp, searchCommentsResponse{
+			Id:         hit.ID.Hex(),
+			MovieID:    hit.MovieID.Hex(),
+			Name:       hit.Fields["name"],
+			Text:       hit.Fields["text"],
+			Highlights: hit.Highlights,
+		})
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// newCommentDocument is what gets indexed for a comment that was just added
+func newCommentDocument(id primitive.ObjectID, arg db.AddCommentParams) db.Comments {
+	return db.Comments{
+		ID:       id,
+		Name:     arg.Name,
+		Email:    arg.Email,
+		MovieID:  arg.MovieID,
+		ParentID: arg.ParentID,
+		Depth:    arg.Depth,
+		Text:     arg.Text,
+		Date:     primitive.NewDateTimeFromTime(time.Now()),
+		Flags:    arg.Flags,
+	}
+}
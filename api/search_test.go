@@ -0,0 +1,396 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"net/http/httptest"
+	mockdb "phantom/db/mock"
+	db "phantom/db/mongo"
+	"phantom/search"
+	"phantom/util"
+	"testing"
+	"time"
+)
+
+func newEmbeddedSearchServer(t *testing.T, store db.Store, movies []db.Movies, comments []db.Comments) *Server {
+	config := util.Config{
+		TokenSymmetricKey:   util.RandomString(32),
+		AccessTokenDuration: time.Minute,
+		SearchBackend:       searchBackendEmbedded,
+	}
+	server, err := NewServer(config, store)
+	require.NoError(t, err)
+	require.NotNil(t, server.searchIndex)
+
+	server.searchIndex.Rebuild(movies, comments)
+	return server
+}
+
+func searchRequest(query string) search.Request {
+	return search.Request{Query: query, Type: search.TypeComment, Limit: 10}
+}
+
+func searchTestData() ([]db.Movies, []db.Comments) {
+	movies := []db.Movies{
+		{Id: primitive.NewObjectID(), Title: "Interstellar", Directors: []string{"Christopher Nolan"}, Year: 2014},
+		{Id: primitive.NewObjectID(), Title: "Following", Plot: "Nolan's first feature", Year: 1998},
+		{Id: primitive.NewObjectID(), Title: "Amélie", Year: 2001},
+	}
+	comments := []db.Comments{
+		{ID: primitive.NewObjectID(), MovieID: movies[0].Id, Name: "alice", Text: "Best docking scene ever"},
+		{ID: primitive.NewObjectID(), MovieID: movies[1].Id, Name: "bob", Text: "A clever docking of timelines"},
+	}
+	return movies, comments
+}
+
+func TestNewServerUnknownSearchBackend(t *testing.T) {
+	config := util.Config{
+		TokenSymmetricKey: util.RandomString(32),
+		SearchBackend:     "elastic",
+	}
+	_, err := NewServer(config, nil)
+	require.Error(t, err)
+}
+
+func TestBuildIndexesEmbedded(t *testing.T) {
+	movies, comments := searchTestData()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetAllMovies(gomock.Any()).Times(1).Return(movies, nil)
+	store.EXPECT().GetAllComments(gomock.Any()).Times(1).Return(comments, nil)
+	server := newEmbeddedSearchServer(t, store, nil, nil)
+	require.NoError(t, server.BuildIndexes(context.Background()))
+
+	result, err := server.searchIndex.Search(searchRequest("docking"))
+	require.NoError(t, err)
+	require.Equal(t, 2, result.Total)
+}
+
+func TestSearchMoviesWithIndexAPI(t *testing.T) {
+	movies, comments := searchTestData()
+
+	testCase := []struct {
+		name          string
+		search        string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "OK",
+			search: "nolan -year:<2000",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMoviesByIDs(gomock.Any(), gomock.Eq([]primitive.ObjectID{movies[0].Id})).
+					Times(1).
+					Return([]db.Movies{movies[0]}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp []getMoviesResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Len(t, rsp, 1)
+				require.Equal(t, movies[0].Id.Hex(), rsp[0].Id)
+				require.Equal(t, []string{"Christopher <mark>Nolan</mark>"}, rsp[0].Highlights["directors"])
+			},
+		},
+		{
+			name:   "KeepsRanking",
+			search: "nolan",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMoviesByIDs(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.Movies{movies[1], movies[0]}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp []getMoviesResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Len(t, rsp, 2)
+				// Directors weigh more than the plot
+				require.Equal(t, movies[0].Id.Hex(), rsp[0].Id)
+				require.Equal(t, movies[1].Id.Hex(), rsp[1].Id)
+			},
+		},
+		{
+			name:   "NoHits",
+			search: "zzz",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMoviesByIDs(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:   "InvalidQuery",
+			search: "(nolan",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMoviesByIDs(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:   "InternalError",
+			search: "nolan",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMoviesByIDs(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, mongo.ErrClientDisconnected)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().SearchForMovies(gomock.Any(), gomock.Any()).Times(0)
+			tc.buildStubs(store)
+			server := newEmbeddedSearchServer(t, store, movies, comments)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, "/search", nil)
+			require.NoError(t, err)
+			q := request.URL.Query()
+			q.Add("search", tc.search)
+			q.Add("s", "10")
+			q.Add("p", "1")
+			request.URL.RawQuery = q.Encode()
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestSearchMoviesChineseAPI(t *testing.T) {
+	movie := db.Movies{Id: primitive.NewObjectID(), Title: "星際穿越", Year: 2014}
+
+	for _, query := range []string{"星际", "星際穿越", "xjcy"} {
+		t.Run(query, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().GetMoviesByIDs(gomock.Any(), gomock.Eq([]primitive.ObjectID{movie.Id})).
+				Times(1).
+				Return([]db.Movies{movie}, nil)
+			server := newEmbeddedSearchServer(t, store, []db.Movies{movie}, nil)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, "/search", nil)
+			require.NoError(t, err)
+			q := request.URL.Query()
+			q.Add("search", query)
+			q.Add("s", "10")
+			q.Add("p", "1")
+			request.URL.RawQuery = q.Encode()
+
+			server.router.ServeHTTP(recorder, request)
+			require.Equal(t, http.StatusOK, recorder.Code)
+			var rsp []getMoviesResponse
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+			require.Len(t, rsp, 1)
+			require.Equal(t, movie.Id.Hex(), rsp[0].Id)
+		})
+	}
+}
+
+// TestSearchDefaultConfigChineseAPI searches Chinese and pinyin with the backend
+// picked by the shipped app.env and by an empty SEARCH_BACKEND
+func TestSearchDefaultConfigChineseAPI(t *testing.T) {
+	shipped, err := util.LoadConfig("..")
+	require.NoError(t, err)
+	movie := db.Movies{Id: primitive.NewObjectID(), Title: "星際穿越", Year: 2014}
+
+	for name, backend := range map[string]string{"AppEnv": shipped.SearchBackend, "Empty": ""} {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().GetAllMovies(gomock.Any()).Times(1).Return([]db.Movies{movie}, nil)
+			store.EXPECT().GetAllComments(gomock.Any()).Times(1).Return(nil, nil)
+			store.EXPECT().GetMoviesByIDs(gomock.Any(), gomock.Eq([]primitive.ObjectID{movie.Id})).
+				Times(2).
+				Return([]db.Movies{movie}, nil)
+			store.EXPECT().SearchForMovies(gomock.Any(), gomock.Any()).Times(0)
+
+			config := util.Config{
+				TokenSymmetricKey:   util.RandomString(32),
+				AccessTokenDuration: time.Minute,
+				SearchBackend:       backend,
+			}
+			server, err := NewServer(config, store)
+			require.NoError(t, err)
+			require.NoError(t, server.BuildIndexes(context.Background()))
+
+			for _, query := range []string{"星际", "xjcy"} {
+				recorder := httptest.NewRecorder()
+				request, err := http.NewRequest(http.MethodGet, "/search", nil)
+				require.NoError(t, err)
+				q := request.URL.Query()
+				q.Add("search", query)
+				q.Add("s", "10")
+				q.Add("p", "1")
+				request.URL.RawQuery = q.Encode()
+
+				server.router.ServeHTTP(recorder, request)
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp []getMoviesResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Len(t, rsp, 1, query)
+				require.Equal(t, movie.Id.Hex(), rsp[0].Id)
+			}
+		})
+	}
+}
+
+func TestSearchCommentsAPI(t *testing.T) {
+	movies, comments := searchTestData()
+
+	testCase := []struct {
+		name          string
+		backend       string
+		query         map[string]string
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:    "OK",
+			backend: searchBackendEmbedded,
+			query:   map[string]string{"search": "docking", "s": "10", "p": "1"},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp []searchCommentsResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Len(t, rsp, 2)
+			},
+		},
+		{
+			name:    "ByMovie",
+			backend: searchBackendEmbedded,
+			query:   map[string]string{"search": "docking", "movie_id": movies[1].Id.Hex(), "s": "10", "p": "1"},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp []searchCommentsResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Len(t, rsp, 1)
+				require.Equal(t, comments[1].ID.Hex(), rsp[0].Id)
+				require.Equal(t, "bob", rsp[0].Name)
+				require.Equal(t, []string{"A clever <mark>docking</mark> of timelines"}, rsp[0].Highlights["text"])
+			},
+		},
+		{
+			name:    "NoHits",
+			backend: searchBackendEmbedded,
+			query:   map[string]string{"search": "zeppelin", "s": "10", "p": "1"},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.JSONEq(t, "[]", recorder.Body.String())
+			},
+		},
+		{
+			name:    "InvalidMovieID",
+			backend: searchBackendEmbedded,
+			query:   map[string]string{"search": "docking", "movie_id": util.RandomString(24), "s": "10", "p": "1"},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:    "InvalidPageSize",
+			backend: searchBackendEmbedded,
+			query:   map[string]string{"search": "docking", "s": "100", "p": "1"},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:    "MongoBackend",
+			backend: searchBackendMongo,
+			query:   map[string]string{"search": "docking", "s": "10", "p": "1"},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotImplemented, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			var server *Server
+			if tc.backend == searchBackendEmbedded {
+				server = newEmbeddedSearchServer(t, store, movies, comments)
+			} else {
+				server = newTestServer(t, store)
+			}
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, "/search/comments", nil)
+			require.NoError(t, err)
+			q := request.URL.Query()
+			for key, value := range tc.query {
+				q.Add(key, value)
+			}
+			request.URL.RawQuery = q.Encode()
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestCommentWritesUpdateSearchIndex(t *testing.T) {
+	movies, comments := searchTestData()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	server := newEmbeddedSearchServer(t, store, movies, comments)
+
+	updated := comments[0]
+	updated.Text = "The organ score is haunting"
+	store.EXPECT().UpdateComment(gomock.Any(), gomock.Any()).Times(1).Return(&mongo.UpdateResult{ModifiedCount: 1}, nil)
+	store.EXPECT().GetComment(gomock.Any(), gomock.Eq(updated.ID)).Times(1).Return(updated, nil)
+	recorder := sendAuthorizedJSON(t, server, http.MethodPut, "/comments", map[string]string{
+		"id":   updated.ID.Hex(),
+		"text": updated.Text,
+	})
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	result, err := server.searchIndex.Search(searchRequest("organ"))
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Total)
+
+	expectTx(store)
+	store.EXPECT().GetComment(gomock.Any(), gomock.Eq(updated.ID)).Times(1).Return(updated, nil)
+	store.EXPECT().DeleteComment(gomock.Any(), gomock.Eq(updated.ID), gomock.Any()).Times(1).Return(int64(1), nil)
+	store.EXPECT().IncrementMovieComments(gomock.Any(), gomock.Eq(updated.MovieID), gomock.Eq(int64(-1))).Times(1).Return(nil)
+	store.EXPECT().ReverseCoins(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferCoinsResult{}, nil)
+	recorder = sendAuthorizedJSON(t, server, http.MethodDelete, "/comments", map[string]string{
+		"id": updated.ID.Hex(),
+	})
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	result, err = server.searchIndex.Search(searchRequest("organ"))
+	require.NoError(t, err)
+	require.Equal(t, 0, result.Total)
+}
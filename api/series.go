@@ -0,0 +1,255 @@
+package api
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	db "phantom/db/mongo"
+	"phantom/token"
+)
+
+var (
+	errSeriesNotFound = errors.New("series is not found")
+	errNotASeries     = errors.New("movie is not a series")
+	errNoNextEpisode  = errors.New("there is no next episode")
+	errDuplicate      = errors.New("it already exists")
+)
+
+// getSeries gets a series by its id in the uri,
+// it writes the error response and returns false when there is none
+func (server *Server) getSeries(ctx *gin.Context) (db.Movies, bool) {
+	var req movieIdRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return db.Movies{}, false
+	}
+	objectID, err := primitive.ObjectIDFromHex(req.Id)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return db.Movies{}, false
+	}
+
+	series, err := server.store.GetMovieByID(ctx, objectID)
+	if err != nil {
+		if mongo.ErrNoDocuments == err {
+			ctx.JSON(http.StatusNotFound, errorResponse(errSeriesNotFound))
+			return db.Movies{}, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return db.Movies{}, false
+	}
+	if series.Type != db.TypeSeries {
+		ctx.JSON(http.StatusNotFound, errorResponse(errNotASeries))
+		return db.Movies{}, false
+	}
+	return series, true
+}
+
+type createSeasonRequest struct {
+	Number   int64              `json:"number" binding:"min=0"`
+	Title    string             `json:"title"`
+	Overview string             `json:"overview"`
+	Poster   string             `json:"poster" binding:"omitempty,url"`
+	AirDate  primitive.DateTime `json:"air_date"`
+}
+
+// createSeason adds a season to a series, season 0 holds the specials
+func (server *Server) createSeason(ctx *gin.Context) {
+	var req createSeasonRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	series, ok := server.getSeries(ctx)
+	if !ok {
+		return
+	}
+
+	id, err := server.store.AddSeason(ctx, db.AddSeasonParams{
+		SeriesID: series.Id,
+		Number:   req.Number,
+		Title:    req.Title,
+		Overview: req.Overview,
+		Poster:   req.Poster,
+		AirDate:  req.AirDate,
+	})
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			ctx.JSON(http.StatusForbidden, errorResponse(errDuplicate))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"id": id.Hex()})
+}
+
+// listSeasons is to get the seasons of a series
+func (server *Server) listSeasons(ctx *gin.Context) {
+	series, ok := server.getSeries(ctx)
+	if !ok {
+		return
+	}
+	seasons, err := server.store.GetSeasonsBySeriesID(ctx, series.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if seasons == nil {
+		seasons = []db.Season{}
+	}
+	ctx.JSON(http.StatusOK, seasons)
+}
+
+type createEpisodeRequest struct {
+	Season         int64              `json:"season" binding:"min=0"`
+	Number         int64              `json:"number" binding:"required,min=1"`
+	AbsoluteNumber int64              `json:"absolute_number" binding:"min=0"`
+	Title          string             `json:"title"`
+	Plot           string             `json:"plot"`
+	Runtime        int64              `json:"runtime" binding:"min=0"`
+	AirDate        primitive.DateTime `json:"air_date"`
+}
+
+// createEpisode adds an episode to a season of a series
+func (server *Server) createEpisode(ctx *gin.Context) {
+	var req createEpisodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	series, ok := server.getSeries(ctx)
+	if !ok {
+		return
+	}
+
+	id, err := server.store.AddEpisode(ctx, db.AddEpisodeParams{
+		SeriesID:       series.Id,
+		Season:         req.Season,
+		Number:         req.Number,
+		AbsoluteNumber: req.AbsoluteNumber,
+		Title:          req.Title,
+		Plot:           req.Plot,
+		Runtime:        req.Runtime,
+		AirDate:        req.AirDate,
+	})
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			ctx.JSON(http.StatusForbidden, errorResponse(errDuplicate))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"id": id.Hex()})
+}
+
+type listEpisodesRequest struct {
+	Season *int64 `form:"season" binding:"omitempty,min=0"`
+	Order  string `form:"order" binding:"omitempty,oneof=aired absolute"`
+}
+
+// listEpisodes is to get the episodes of a series, of one season when it is given.
+// They are in the order they aired unless order is absolute
+func (server *Server) listEpisodes(ctx *gin.Context) {
+	var req listEpisodesRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	series, ok := server.getSeries(ctx)
+	if !ok {
+		return
+	}
+
+	order := req.Order
+	if order == "" {
+		order = db.EpisodeOrderAired
+	}
+	episodes, err := server.store.GetEpisodes(ctx, db.GetEpisodesParams{
+		SeriesID: series.Id,
+		Season:   req.Season,
+		Order:    order,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if episodes == nil {
+		episodes = []db.Episode{}
+	}
+	ctx.JSON(http.StatusOK, episodes)
+}
+
+type nextEpisodeResponse struct {
+	Episode db.Episode `json:"episode"`
+	// Position is where to resume the episode, in seconds
+	Position int64 `json:"position"`
+}
+
+// getNextEpisode is to get the episode of a series the signed-in user should watch next
+func (server *Server) getNextEpisode(ctx *gin.Context) {
+	series, ok := server.getSeries(ctx)
+	if !ok {
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	episodes, err := server.store.GetEpisodes(ctx, db.GetEpisodesParams{
+		SeriesID: series.Id,
+		Order:    db.EpisodeOrderAired,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	progress, err := server.store.GetProgressBySeriesID(ctx, authPayload.Username, series.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp, ok := nextEpisode(episodes, progress)
+	if !ok {
+		ctx.JSON(http.StatusNotFound, errorResponse(errNoNextEpisode))
+		return
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// nextEpisode picks the episode to watch next from episodes in aired order and
+// the progress of a user, the latest first. That is the last episode the user
+// was in, or the one after it once it is watched. Specials are skipped,
+// and a user who hasn't started the series gets its first episode
+func nextEpisode(episodes []db.Episode, progress []db.Progress) (nextEpisodeResponse, bool) {
+	var regular []db.Episode
+	for _, episode := range episodes {
+		if episode.Season != db.SpecialsSeason {
+			regular = append(regular, episode)
+		}
+	}
+	if len(regular) == 0 {
+		return nextEpisodeResponse{}, false
+	}
+
+	index := make(map[primitive.ObjectID]int, len(regular))
+	for i, episode := range regular {
+		index[episode.ID] = i
+	}
+	for _, p := range progress {
+		i, ok := index[p.MediaID]
+		if !ok {
+			continue
+		}
+		if !p.Watched {
+			return nextEpisodeResponse{Episode: regular[i], Position: p.Position}, true
+		}
+		if i+1 == len(regular) {
+			return nextEpisodeResponse{}, false
+		}
+		return nextEpisodeResponse{Episode: regular[i+1]}, true
+	}
+	return nextEpisodeResponse{Episode: regular[0]}, true
+}
@@ -0,0 +1,384 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"net/http/httptest"
+	mockdb "phantom/db/mock"
+	db "phantom/db/mongo"
+	"phantom/util"
+	"testing"
+)
+
+func randomSeries() db.Movies {
+	return db.Movies{
+		Id:    primitive.NewObjectID(),
+		Title: util.RandomString(8),
+		Type:  db.TypeSeries,
+	}
+}
+
+func randomEpisodes(series db.Movies) []db.Episode {
+	var episodes []db.Episode
+	for _, number := range [][2]int64{{0, 1}, {1, 1}, {1, 2}, {2, 1}} {
+		episodes = append(episodes, db.Episode{
+			ID:       primitive.NewObjectID(),
+			SeriesID: series.Id,
+			Season:   number[0],
+			Number:   number[1],
+			Title:    util.RandomString(8),
+		})
+	}
+	return episodes
+}
+
+func TestNextEpisode(t *testing.T) {
+	episodes := randomEpisodes(randomSeries())
+	special, first, second, last := episodes[0], episodes[1], episodes[2], episodes[3]
+
+	testCase := []struct {
+		name     string
+		progress []db.Progress
+		ok       bool
+		episode  db.Episode
+		position int64
+	}{
+		{
+			name:    "NotStarted",
+			ok:      true,
+			episode: first,
+		},
+		{
+			name:     "Resume",
+			progress: []db.Progress{{MediaID: second.ID, Position: 600}, {MediaID: first.ID, Watched: true}},
+			ok:       true,
+			episode:  second,
+			position: 600,
+		},
+		{
+			name:     "AcrossSeasons",
+			progress: []db.Progress{{MediaID: second.ID, Watched: true}},
+			ok:       true,
+			episode:  last,
+		},
+		{
+			name:     "SkipSpecials",
+			progress: []db.Progress{{MediaID: special.ID, Position: 30}, {MediaID: first.ID, Watched: true}},
+			ok:       true,
+			episode:  second,
+		},
+		{
+			name:     "Finished",
+			progress: []db.Progress{{MediaID: last.ID, Watched: true}},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			rsp, ok := nextEpisode(episodes, tc.progress)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.episode, rsp.Episode)
+			require.Equal(t, tc.position, rsp.Position)
+		})
+	}
+}
+
+func TestCreateEpisodeAPI(t *testing.T) {
+	series := randomSeries()
+	movie := randomSeries()
+	movie.Type = db.TypeMovie
+	body := gin.H{"season": 1, "number": 2, "absolute_number": 2, "title": "Pilot", "runtime": 45}
+
+	testCase := []struct {
+		name          string
+		seriesId      string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			seriesId: series.Id.Hex(),
+			body:     body,
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.AddEpisodeParams{
+					SeriesID:       series.Id,
+					Season:         1,
+					Number:         2,
+					AbsoluteNumber: 2,
+					Title:          "Pilot",
+					Runtime:        45,
+				}
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(series.Id)).Times(1).Return(series, nil)
+				store.EXPECT().AddEpisode(gomock.Any(), gomock.Eq(arg)).Times(1).Return(primitive.NewObjectID(), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "InvalidNumber",
+			seriesId: series.Id.Hex(),
+			body:     gin.H{"season": 1, "number": 0},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().AddEpisode(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "NotASeries",
+			seriesId: movie.Id.Hex(),
+			body:     body,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(movie.Id)).Times(1).Return(movie, nil)
+				store.EXPECT().AddEpisode(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:     "Duplicate",
+			seriesId: series.Id.Hex(),
+			body:     body,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(series.Id)).Times(1).Return(series, nil)
+				store.EXPECT().AddEpisode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(primitive.ObjectID{}, mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000}}})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := sendAuthorizedJSON(t, server, http.MethodPost, "/series/"+tc.seriesId+"/episodes", tc.body)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestListSeasonsAPI(t *testing.T) {
+	series := randomSeries()
+	seasons := []db.Season{
+		{ID: primitive.NewObjectID(), SeriesID: series.Id, Number: 1},
+		{ID: primitive.NewObjectID(), SeriesID: series.Id, Number: 2},
+	}
+
+	testCase := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(series.Id)).Times(1).Return(series, nil)
+				store.EXPECT().GetSeasonsBySeriesID(gomock.Any(), gomock.Eq(series.Id)).Times(1).Return(seasons, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var got []db.Season
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.Equal(t, seasons, got)
+			},
+		},
+		{
+			name: "NotFound",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(series.Id)).Times(1).Return(db.Movies{}, mongo.ErrNoDocuments)
+				store.EXPECT().GetSeasonsBySeriesID(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "InternalError",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(series.Id)).Times(1).Return(series, nil)
+				store.EXPECT().GetSeasonsBySeriesID(gomock.Any(), gomock.Any()).Times(1).Return(nil, mongo.ErrClientDisconnected)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, "/series/"+series.Id.Hex()+"/seasons", nil)
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestListEpisodesAPI(t *testing.T) {
+	series := randomSeries()
+	episodes := randomEpisodes(series)
+	season := int64(1)
+
+	testCase := []struct {
+		name          string
+		query         string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "AllSeasons",
+			query: "",
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.GetEpisodesParams{SeriesID: series.Id, Order: db.EpisodeOrderAired}
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(series.Id)).Times(1).Return(series, nil)
+				store.EXPECT().GetEpisodes(gomock.Any(), gomock.Eq(arg)).Times(1).Return(episodes, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var got []db.Episode
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.Equal(t, episodes, got)
+			},
+		},
+		{
+			name:  "OneSeasonAbsolute",
+			query: "?season=1&order=absolute",
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.GetEpisodesParams{SeriesID: series.Id, Season: &season, Order: db.EpisodeOrderAbsolute}
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(series.Id)).Times(1).Return(series, nil)
+				store.EXPECT().GetEpisodes(gomock.Any(), gomock.Eq(arg)).Times(1).Return(nil, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Equal(t, "[]", recorder.Body.String())
+			},
+		},
+		{
+			name:  "InvalidOrder",
+			query: "?order=random",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().GetEpisodes(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, "/series/"+series.Id.Hex()+"/episodes"+tc.query, nil)
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestGetNextEpisodeAPI(t *testing.T) {
+	series := randomSeries()
+	episodes := randomEpisodes(series)
+	arg := db.GetEpisodesParams{SeriesID: series.Id, Order: db.EpisodeOrderAired}
+
+	testCase := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				progress := []db.Progress{{Name: "user", MediaID: episodes[1].ID, SeriesID: series.Id, Watched: true}}
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(series.Id)).Times(1).Return(series, nil)
+				store.EXPECT().GetEpisodes(gomock.Any(), gomock.Eq(arg)).Times(1).Return(episodes, nil)
+				store.EXPECT().GetProgressBySeriesID(gomock.Any(), gomock.Eq("user"), gomock.Eq(series.Id)).Times(1).Return(progress, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp nextEpisodeResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, episodes[2], rsp.Episode)
+			},
+		},
+		{
+			name: "Finished",
+			buildStubs: func(store *mockdb.MockStore) {
+				progress := []db.Progress{{Name: "user", MediaID: episodes[3].ID, SeriesID: series.Id, Watched: true}}
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(series.Id)).Times(1).Return(series, nil)
+				store.EXPECT().GetEpisodes(gomock.Any(), gomock.Eq(arg)).Times(1).Return(episodes, nil)
+				store.EXPECT().GetProgressBySeriesID(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(progress, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "InternalError",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetMovieByID(gomock.Any(), gomock.Eq(series.Id)).Times(1).Return(series, nil)
+				store.EXPECT().GetEpisodes(gomock.Any(), gomock.Eq(arg)).Times(1).Return(episodes, nil)
+				store.EXPECT().GetProgressBySeriesID(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(nil, mongo.ErrClientDisconnected)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			recorder := sendAuthorizedJSON(t, server, http.MethodGet, "/series/"+series.Id.Hex()+"/next", nil)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -5,16 +5,23 @@ import (
 	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
 	db "phantom/db/mongo"
+	"phantom/filter"
+	"phantom/live"
+	"phantom/search"
 	"phantom/token"
 	"phantom/util"
 )
 
 // Server servers HTTP request for our
 type Server struct {
-	config     util.Config
-	store      db.Store
-	tokenMaker token.Maker
-	router     *gin.Engine
+	config        util.Config
+	store         db.Store
+	tokenMaker    token.Maker
+	suggester     *search.Suggester
+	searchIndex   search.SearchIndex
+	commentFilter *filter.Pipeline
+	liveHub       live.Hub
+	router        *gin.Engine
 }
 
 func NewServer(config util.Config, store db.Store) (*Server, error) {
@@ -22,15 +29,37 @@ func NewServer(config util.Config, store db.Store) (*Server, error) {
 	if err != nil {
 		return nil, err
 	}
+	searchIndex, err := newSearchIndex(config.SearchBackend)
+	if err != nil {
+		return nil, err
+	}
+	commentFilter, err := newCommentFilter(config.BlocklistDir)
+	if err != nil {
+		return nil, err
+	}
+	if config.WatchedThreshold <= 0 || config.WatchedThreshold > 1 {
+		config.WatchedThreshold = defaultWatchedThreshold
+	}
+	if config.ReportThreshold <= 0 {
+		config.ReportThreshold = defaultReportThreshold
+	}
+	if config.LedgerCheckpointInterval <= 0 {
+		config.LedgerCheckpointInterval = defaultLedgerCheckpointInterval
+	}
 	server := &Server{
-		config:     config,
-		store:      store,
-		tokenMaker: tokenMaker,
+		config:        config,
+		store:         store,
+		tokenMaker:    tokenMaker,
+		suggester:     search.NewSuggester(),
+		searchIndex:   searchIndex,
+		commentFilter: commentFilter,
+		liveHub:       live.NewMemoryHub(),
 	}
 
 	// Registration binding tag
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
 		v.RegisterValidation("login", validLogin)
+		v.RegisterValidation("locale", validLocale)
 	}
 
 	server.setupRouter()
@@ -39,24 +68,88 @@ func NewServer(config util.Config, store db.Store) (*Server, error) {
 }
 
 func (server *Server) setupRouter() {
-	router := gin.Default()
+	router := gin.New()
+	router.Use(gin.LoggerWithFormatter(logFormatter), gin.Recovery())
 
 	router.POST("/register", server.register)
 	router.POST("/login", server.login)
 
-	router.GET("/movies/:id", server.getMovie)
-	router.GET("/search", server.searchForMovies)
-	router.GET("/movies/genres", server.listMoviesByGenres)
-	router.GET("/movies/most_watched", server.listTheMostWatchedMovies)
-	router.GET("/movies/latest", server.listTheLatestReleasedMovies)
-	router.GET("/comments", server.listComments)
+	router.GET("/search/suggest", server.suggestSearch)
+	router.GET("/search/comments", server.searchComments)
+	router.GET("/search/people", server.searchPeople)
+	router.GET("/ledger/proof/:commentId", server.getLedgerProof)
+
+	// Browsers can't set headers on a WebSocket, so the token can be in the query too
+	router.GET("/ws/movies/:id", wsAuthMiddleware(server.tokenMaker), server.watchMovie)
+
+	// Signed-in users get titles in their own locale
+	movieRoutes := router.Group("/").Use(optionalAuthMiddleware(server.tokenMaker))
+	movieRoutes.GET("/movies/:id", server.getMovie)
+	movieRoutes.GET("/movies/:id/danmaku", server.listDanmaku)
+	movieRoutes.GET("/search", server.searchForMovies)
+	movieRoutes.GET("/movies/genres", server.listMoviesByGenres)
+	movieRoutes.GET("/movies/most_watched", server.listTheMostWatchedMovies)
+	movieRoutes.GET("/movies/latest", server.listTheLatestReleasedMovies)
+	movieRoutes.GET("/people/:id", server.getPerson)
+	movieRoutes.GET("/series/:id/seasons", server.listSeasons)
+	movieRoutes.GET("/series/:id/episodes", server.listEpisodes)
+	movieRoutes.GET("/collections", server.listCollections)
+	movieRoutes.GET("/collections/:id/movies", server.listCollectionMovies)
+	movieRoutes.GET("/comments", server.listComments)
+	movieRoutes.GET("/comments/:id/replies", server.listReplies)
 
 	authRoutes := router.Group("/").Use(authMiddleware(server.tokenMaker))
 	authRoutes.POST("/movies", server.createMovie)
 	authRoutes.PUT("/movies/:id", server.updateMovie)
+	authRoutes.PUT("/movies/:id/rating", server.rateMovie)
+	authRoutes.DELETE("/movies/:id/rating", server.deleteRating)
+	authRoutes.POST("/movies/:id/danmaku", server.createDanmaku)
+	authRoutes.POST("/danmaku/:id/pin", server.pinDanmaku)
+	authRoutes.POST("/title-requests", server.requestTitle)
 	authRoutes.POST("/comments", server.createComment)
 	authRoutes.PUT("/comments", server.updateComment)
 	authRoutes.DELETE("/comments", server.deleteComment)
+	authRoutes.PUT("/comments/:id/reactions/:reaction", server.addReaction)
+	authRoutes.DELETE("/comments/:id/reactions/:reaction", server.deleteReaction)
+	authRoutes.POST("/comments/:id/report", server.reportComment)
+	authRoutes.PUT("/users/locale", server.updateLocale)
+	authRoutes.POST("/series/:id/seasons", server.createSeason)
+	authRoutes.POST("/series/:id/episodes", server.createEpisode)
+	authRoutes.GET("/series/:id/next", server.getNextEpisode)
+	authRoutes.PUT("/me/progress/:movieId", server.saveProgress)
+	authRoutes.GET("/me/continue-watching", server.listContinueWatching)
+	authRoutes.GET("/me/history", server.getHistory)
+	authRoutes.DELETE("/me/history/:id", server.deleteHistoryEntry)
+	authRoutes.GET("/me/stats", server.getStats)
+	authRoutes.GET("/me/wallet", server.getWallet)
+	authRoutes.GET("/me/wallet/transactions", server.listWalletTransactions)
+	for _, list := range []string{db.ListWatchlist, db.ListFavorites} {
+		authRoutes.GET("/me/"+list, server.getList(list))
+		authRoutes.PUT("/me/"+list, server.reorderList(list))
+		authRoutes.POST("/me/"+list+"/:movieId", server.addToList(list))
+		authRoutes.DELETE("/me/"+list+"/:movieId", server.removeFromList(list))
+	}
+
+	adminRoutes := router.Group("/").Use(authMiddleware(server.tokenMaker), adminMiddleware(server.store))
+	adminRoutes.DELETE("/movies/:id", server.deleteMovie)
+	adminRoutes.POST("/collections", server.createCollection)
+	adminRoutes.PUT("/collections/:id", server.updateCollection)
+	adminRoutes.DELETE("/collections/:id", server.deleteCollection)
+	adminRoutes.POST("/people", server.createPerson)
+	adminRoutes.PUT("/people/:id", server.updatePerson)
+	adminRoutes.GET("/moderation/queue", server.getModerationQueue)
+	adminRoutes.GET("/moderation/log", server.getModerationLog)
+	adminRoutes.GET("/moderation/danmaku", server.getDanmakuModerationQueue)
+	for _, action := range []string{db.ModerationApprove, db.ModerationRemove, db.ModerationBan} {
+		adminRoutes.POST("/moderation/comments/:id/"+action, server.moderateComment(action))
+		adminRoutes.POST("/moderation/danmaku/:id/"+action, server.moderateDanmaku(action))
+	}
+	adminRoutes.POST("/coins/grants", server.grantCoins)
+	adminRoutes.GET("/coins/reconcile", server.reconcileCoins)
+	adminRoutes.GET("/title-requests", server.listTitleRequests)
+	adminRoutes.POST("/import", server.importMovies)
+	adminRoutes.GET("/backup", server.backupDatabase)
+	adminRoutes.POST("/restore", server.restoreDatabase)
 
 	server.router = router
 }
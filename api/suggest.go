@@ -0,0 +1,26 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+const defaultSuggestLimit = 10
+
+type suggestRequest struct {
+	Query string `form:"q" binding:"required,min=1"`
+	Limit int    `form:"limit" binding:"omitempty,min=1,max=20"`
+}
+
+// suggestSearch returns title, person and genre suggestions for a partially typed query
+func (server *Server) suggestSearch(ctx *gin.Context) {
+	var req suggestRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultSuggestLimit
+	}
+	ctx.JSON(http.StatusOK, server.suggester.Suggest(req.Query, req.Limit))
+}
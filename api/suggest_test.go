@@ -0,0 +1,139 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"net/http/httptest"
+	mockdb "phantom/db/mock"
+	db "phantom/db/mongo"
+	"phantom/search"
+	"testing"
+	"time"
+)
+
+func TestSuggestSearchAPI(t *testing.T) {
+	movies := []db.Movies{
+		{
+			Id:        primitive.NewObjectID(),
+			Title:     "Interstellar",
+			Genres:    []string{"Sci-Fi"},
+			Directors: []string{"Christopher Nolan"},
+		},
+	}
+
+	testCase := []struct {
+		name          string
+		query         string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			query: "q=intersteler",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAllMovies(gomock.Any()).Times(1).Return(movies, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var suggestions []search.Suggestion
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &suggestions))
+				require.Len(t, suggestions, 1)
+				require.Equal(t, "Interstellar", suggestions[0].Text)
+				require.Equal(t, movies[0].Id.Hex(), suggestions[0].MovieID)
+			},
+		},
+		{
+			name:  "NoMatch",
+			query: "q=zzz",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAllMovies(gomock.Any()).Times(1).Return(movies, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Equal(t, "[]", recorder.Body.String())
+			},
+		},
+		{
+			name:  "EmptyQuery",
+			query: "q=",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAllMovies(gomock.Any()).Times(1).Return(movies, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "InvalidLimit",
+			query: "q=inter&limit=100",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAllMovies(gomock.Any()).Times(1).Return(movies, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCase {
+		tc := testCase[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+			require.NoError(t, server.BuildIndexes(context.Background()))
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/search/suggest?%s", tc.query), nil)
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestBuildIndexesError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetAllMovies(gomock.Any()).Times(1).Return(nil, mongo.ErrClientDisconnected)
+	server := newTestServer(t, store)
+	require.ErrorIs(t, server.BuildIndexes(context.Background()), mongo.ErrClientDisconnected)
+}
+
+func TestCreateMovieUpdatesSuggestions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	movie := randomMovie()
+	movie.Title = "Tenet"
+	returnId := primitive.NewObjectID()
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().AddMovie(gomock.Any(), gomock.Any()).Times(1).Return(returnId, nil)
+	server := newTestServer(t, store)
+
+	data, err := json.Marshal(movie)
+	require.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodPost, "/movies", bytes.NewReader(data))
+	require.NoError(t, err)
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "user", time.Minute)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	suggestions := server.suggester.Suggest("ten", 10)
+	require.Len(t, suggestions, 1)
+	require.Equal(t, returnId.Hex(), suggestions[0].MovieID)
+}
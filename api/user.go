@@ -9,12 +9,18 @@ import (
 	"phantom/util"
 )
 
+var errUserNotFound = errors.New("user not found")
+var errUserExists = errors.New("user already exists")
+
 type registerRequest struct {
 	Username string `json:"name" binding:"required,alphanum"`
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=6"`
 }
 
+// register adds a user. The name is theirs for good: their comments, danmaku,
+// lists, ratings and coin account are keyed by it, the ledger hashes it into
+// the entries of what they post, and their tokens carry it
 func (server *Server) register(ctx *gin.Context) {
 	var req registerRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
@@ -36,7 +42,7 @@ func (server *Server) register(ctx *gin.Context) {
 	id, err := server.store.AddUser(ctx, arg)
 	if err != nil {
 		if mongo.IsDuplicateKeyError(err) {
-			ctx.JSON(http.StatusForbidden, errorResponse(errors.New("user already exists")))
+			ctx.JSON(http.StatusForbidden, errorResponse(errUserExists))
 			return
 		}
 		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("add user error")))
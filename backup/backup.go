@@ -0,0 +1,203 @@
+// Package backup writes the collections of a MongoDB database to an archive,
+// and restores a database from one.
+//
+// An archive is a gzipped tar file. It has a file for each collection,
+// collections/NAME.bson, which is its documents in BSON one after another the
+// way mongodump writes them, and then manifest.json, which has the checksums
+// of those files and the schema version of the database, see Manifest
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"io"
+	"os"
+	"path/filepath"
+	db "phantom/db/mongo"
+	"time"
+)
+
+// FormatVersion is the version of the layout of the archives, a restore
+// refuses an archive of another version
+const FormatVersion = 1
+
+const (
+	manifestName   = "manifest.json"
+	collectionsDir = "collections/"
+)
+
+// Database is a database that can be backed up and restored, which
+// db.MongoStore is
+type Database interface {
+	CollectionNames(ctx context.Context) ([]string, error)
+	ExportCollection(ctx context.Context, name string, fn func(doc bson.Raw) error) error
+	ClearCollection(ctx context.Context, name string) error
+	InsertDocuments(ctx context.Context, name string, docs []bson.Raw) error
+	SchemaVersion(ctx context.Context) (int64, error)
+	LatestSchemaVersion() int64
+	MigrateFrom(ctx context.Context, version int64) ([]db.Migration, error)
+}
+
+// Manifest describes an archive, it is its last file
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	// SchemaVersion is the latest migration applied to the database the
+	// archive was written from, see db.Migrator
+	SchemaVersion int64 `json:"schema_version"`
+	// OmitPasswords tells the password hashes of the users were left out,
+	// they can't log in with a password after a restore
+	OmitPasswords bool         `json:"omit_passwords"`
+	Collections   []Collection `json:"collections"`
+}
+
+// Collection is the file of a collection in an archive
+type Collection struct {
+	Name      string `json:"name"`
+	File      string `json:"file"`
+	Documents int64  `json:"documents"`
+	Size      int64  `json:"size"`
+	SHA256    string `json:"sha256"`
+}
+
+type Options struct {
+	// OmitPasswords leaves the password hashes of the users out, for a copy
+	// of the data that is handed around, such as to debug
+	OmitPasswords bool
+}
+
+// Write writes an archive of every collection of the database to w
+func Write(ctx context.Context, database Database, w io.Writer, opt Options) (Manifest, error) {
+	manifest := Manifest{Version: FormatVersion, CreatedAt: time.Now().UTC(), OmitPasswords: opt.OmitPasswords}
+	var err error
+	manifest.SchemaVersion, err = database.SchemaVersion(ctx)
+	if err != nil {
+		return manifest, err
+	}
+	names, err := database.CollectionNames(ctx)
+	if err != nil {
+		return manifest, err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, name := range names {
+		collection, err := writeCollection(ctx, database, tw, name, manifest.CreatedAt, opt)
+		if err != nil {
+			return manifest, fmt.Errorf("collection %s: %w", name, err)
+		}
+		manifest.Collections = append(manifest.Collections, collection)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+	err = tw.WriteHeader(&tar.Header{Name: manifestName, Mode: 0600, Size: int64(len(data)), ModTime: manifest.CreatedAt})
+	if err != nil {
+		return manifest, err
+	}
+	if _, err = tw.Write(data); err != nil {
+		return manifest, err
+	}
+	if err = tw.Close(); err != nil {
+		return manifest, err
+	}
+	return manifest, gz.Close()
+}
+
+// writeCollection exports the collection to a temporary file first, as the
+// header of a file in a tar file has its size
+func writeCollection(ctx context.Context, database Database, tw *tar.Writer, name string, modTime time.Time, opt Options) (Collection, error) {
+	collection := Collection{Name: name, File: collectionsDir + name + ".bson"}
+	tmp, err := os.CreateTemp("", "phantom-backup-*.bson")
+	if err != nil {
+		return collection, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	w := bufio.NewWriter(io.MultiWriter(tmp, hash))
+	err = database.ExportCollection(ctx, name, func(doc bson.Raw) error {
+		if opt.OmitPasswords && name == "users" {
+			var err error
+			if doc, err = omitPassword(doc); err != nil {
+				return err
+			}
+		}
+		n, err := w.Write(doc)
+		collection.Documents++
+		collection.Size += int64(n)
+		return err
+	})
+	if err != nil {
+		return collection, err
+	}
+	if err = w.Flush(); err != nil {
+		return collection, err
+	}
+	collection.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+		return collection, err
+	}
+	err = tw.WriteHeader(&tar.Header{Name: collection.File, Mode: 0600, Size: collection.Size, ModTime: modTime})
+	if err != nil {
+		return collection, err
+	}
+	_, err = io.Copy(tw, tmp)
+	return collection, err
+}
+
+// omitPassword empties the password of a user, which the validator of
+// the 'users' collection requires to be there
+func omitPassword(doc bson.Raw) (bson.Raw, error) {
+	var user bson.D
+	if err := bson.Unmarshal(doc, &user); err != nil {
+		return nil, err
+	}
+	for i := range user {
+		if user[i].Key == "password" {
+			user[i].Value = ""
+		}
+	}
+	return bson.Marshal(user)
+}
+
+// FileName is the name of an archive written at t, the names of
+// archives sort by when they were written
+func FileName(t time.Time) string {
+	return "phantom-" + t.UTC().Format("20060102-150405") + ".tar.gz"
+}
+
+// WriteFile writes an archive to dir, which is made if need be, and returns
+// its path. The archive only gets its name once it is complete
+func WriteFile(ctx context.Context, database Database, dir string, opt Options) (string, Manifest, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", Manifest{}, err
+	}
+	path := filepath.Join(dir, FileName(time.Now()))
+	// The password hashes are in it, so only the owner can read it
+	file, err := os.OpenFile(path+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", Manifest{}, err
+	}
+	defer os.Remove(path + ".tmp")
+
+	manifest, err := Write(ctx, database, file, opt)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", manifest, err
+	}
+	return path, manifest, os.Rename(path+".tmp", path)
+}
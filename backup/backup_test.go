@@ -0,0 +1,254 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	db "phantom/db/mongo"
+)
+
+// fakeDatabase keeps its collections in memory
+type fakeDatabase struct {
+	collections  map[string][]bson.Raw
+	schema       int64
+	latest       int64
+	migratedFrom []int64
+}
+
+func newFakeDatabase(t *testing.T) *fakeDatabase {
+	database := &fakeDatabase{collections: make(map[string][]bson.Raw), schema: 2, latest: 2}
+	database.insert(t, "users", bson.M{"name": "alice", "email": "alice@example.com", "password": "$2a$10$hash"})
+	database.insert(t, "movies", bson.M{"title": "Alien", "year": 1979}, bson.M{"title": "Aliens", "year": 1986})
+	database.collections["lists"] = nil
+	return database
+}
+
+func (database *fakeDatabase) insert(t *testing.T, name string, docs ...bson.M) {
+	for _, doc := range docs {
+		raw, err := bson.Marshal(doc)
+		require.NoError(t, err)
+		database.collections[name] = append(database.collections[name], raw)
+	}
+}
+
+func (database *fakeDatabase) CollectionNames(ctx context.Context) ([]string, error) {
+	var names []string
+	for name := range database.collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (database *fakeDatabase) ExportCollection(ctx context.Context, name string, fn func(doc bson.Raw) error) error {
+	for _, doc := range database.collections[name] {
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (database *fakeDatabase) ClearCollection(ctx context.Context, name string) error {
+	database.collections[name] = nil
+	return nil
+}
+
+func (database *fakeDatabase) InsertDocuments(ctx context.Context, name string, docs []bson.Raw) error {
+	database.collections[name] = append(database.collections[name], docs...)
+	return nil
+}
+
+func (database *fakeDatabase) SchemaVersion(ctx context.Context) (int64, error) {
+	return database.schema, nil
+}
+
+func (database *fakeDatabase) LatestSchemaVersion() int64 {
+	return database.latest
+}
+
+func (database *fakeDatabase) MigrateFrom(ctx context.Context, version int64) ([]db.Migration, error) {
+	database.migratedFrom = append(database.migratedFrom, version)
+	return []db.Migration{{Version: database.latest, Name: "movie keys"}}, nil
+}
+
+func writeArchive(t *testing.T, database *fakeDatabase, opt Options) string {
+	path, manifest, err := WriteFile(context.Background(), database, t.TempDir(), opt)
+	require.NoError(t, err)
+	require.Equal(t, FormatVersion, manifest.Version)
+	require.Len(t, manifest.Collections, 3)
+	return path
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	ctx := context.Background()
+	database := newFakeDatabase(t)
+	want := database.collections
+	path := writeArchive(t, database, Options{})
+
+	target := &fakeDatabase{collections: map[string][]bson.Raw{"movies": nil, "sessions": nil}, latest: 2}
+	target.insert(t, "movies", bson.M{"title": "Heat"})
+
+	// A dry run verifies the archive and changes nothing
+	report, err := Restore(ctx, target, path, true)
+	require.NoError(t, err)
+	require.Equal(t, "movies", report.Collections[1].Name)
+	require.Equal(t, int64(2), report.Collections[1].Documents)
+	require.Equal(t, []string{"sessions"}, report.Kept)
+	require.Len(t, target.collections["movies"], 1)
+
+	report, err = Restore(ctx, target, path, false)
+	require.NoError(t, err)
+	require.Empty(t, report.Migrations)
+	require.Empty(t, target.migratedFrom)
+	for name, docs := range want {
+		require.Equal(t, docs, target.collections[name], name)
+	}
+	require.Contains(t, target.collections, "sessions")
+}
+
+func TestBackupOmitPasswords(t *testing.T) {
+	database := newFakeDatabase(t)
+	path := writeArchive(t, database, Options{OmitPasswords: true})
+
+	target := &fakeDatabase{collections: make(map[string][]bson.Raw), latest: 2}
+	report, err := Restore(context.Background(), target, path, false)
+	require.NoError(t, err)
+	require.True(t, report.OmitPasswords)
+	user := target.collections["users"][0]
+	require.Equal(t, "", user.Lookup("password").StringValue())
+	require.Equal(t, "alice", user.Lookup("name").StringValue())
+}
+
+func TestRestoreMigratesOlderSchema(t *testing.T) {
+	database := newFakeDatabase(t)
+	database.schema = 1
+	path := writeArchive(t, database, Options{})
+
+	report, err := Restore(context.Background(), database, path, false)
+	require.NoError(t, err)
+	require.Equal(t, []int64{1}, database.migratedFrom)
+	require.Equal(t, []string{"2 movie keys"}, report.Migrations)
+
+	// A server can't restore the data of a schema newer than it knows
+	database.latest = 0
+	_, err = Restore(context.Background(), database, path, true)
+	var archiveErr *ArchiveError
+	require.True(t, errors.As(err, &archiveErr))
+}
+
+// rewriteArchive copies the archive at path with fn changing the body of each file
+func rewriteArchive(t *testing.T, path string, fn func(name string, body []byte) []byte) string {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+
+	var out bytes.Buffer
+	gw := gzip.NewWriter(&out)
+	tw := tar.NewWriter(gw)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		body, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		if body = fn(header.Name, body); body == nil {
+			continue
+		}
+		header.Size = int64(len(body))
+		require.NoError(t, tw.WriteHeader(header))
+		_, err = tw.Write(body)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+
+	damaged := filepath.Join(t.TempDir(), "damaged.tar.gz")
+	require.NoError(t, os.WriteFile(damaged, out.Bytes(), 0600))
+	return damaged
+}
+
+func TestRestoreRefusesDamagedArchives(t *testing.T) {
+	database := newFakeDatabase(t)
+	path := writeArchive(t, database, Options{})
+
+	for name, fn := range map[string]func(name string, body []byte) []byte{
+		"changed": func(name string, body []byte) []byte {
+			if name == "collections/movies.bson" {
+				body[len(body)-2] ^= 1
+			}
+			return body
+		},
+		"cut short": func(name string, body []byte) []byte {
+			if name == "collections/movies.bson" {
+				return body[:len(body)-3]
+			}
+			return body
+		},
+		"missing": func(name string, body []byte) []byte {
+			if name == "collections/users.bson" {
+				return nil
+			}
+			return body
+		},
+		"no manifest": func(name string, body []byte) []byte {
+			if name == manifestName {
+				return nil
+			}
+			return body
+		},
+		"other format": func(name string, body []byte) []byte {
+			if name == manifestName {
+				return bytes.Replace(body, []byte(`"version": 1`), []byte(`"version": 2`), 1)
+			}
+			return body
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			target := newFakeDatabase(t)
+			before := target.collections["movies"]
+			_, err := Restore(context.Background(), target, rewriteArchive(t, path, fn), false)
+			var archiveErr *ArchiveError
+			require.True(t, errors.As(err, &archiveErr), err)
+			require.Equal(t, before, target.collections["movies"])
+		})
+	}
+
+	_, err := Restore(context.Background(), database, path+".missing", true)
+	require.Error(t, err)
+}
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var names []string
+	for i := 0; i < 4; i++ {
+		name := FileName(start.Add(time.Duration(i) * time.Hour))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0600))
+		names = append(names, name)
+	}
+
+	removed, err := Prune(dir, 2)
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(dir, names[0]), filepath.Join(dir, names[1])}, removed)
+	require.FileExists(t, filepath.Join(dir, names[3]))
+
+	removed, err = Prune(dir, 0)
+	require.NoError(t, err)
+	require.Empty(t, removed)
+}
@@ -0,0 +1,270 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"io"
+	"os"
+	"strings"
+)
+
+// restoreBatchSize is the number of documents inserted at once by a restore
+const restoreBatchSize = 1000
+
+// maxDocumentSize is the largest document MongoDB stores
+const maxDocumentSize = 16 * 1024 * 1024
+
+// ArchiveError is the error of an archive that can't be restored, such as
+// one that is damaged, unlike the errors of the database
+type ArchiveError struct {
+	Err error
+}
+
+func (e *ArchiveError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ArchiveError) Unwrap() error {
+	return e.Err
+}
+
+func archiveErrorf(format string, a ...interface{}) error {
+	return &ArchiveError{fmt.Errorf(format, a...)}
+}
+
+// Report is what a restore did, or would do on a dry run
+type Report struct {
+	Manifest
+	DryRun bool `json:"dry_run"`
+	// LatestSchemaVersion is the schema the data is migrated to
+	// when the archive has an older one
+	LatestSchemaVersion int64 `json:"latest_schema_version"`
+	// Kept are the collections of the database the archive doesn't have,
+	// which a restore leaves as they are
+	Kept []string `json:"kept,omitempty"`
+	// Migrations are the migrations run on the restored data
+	Migrations []string `json:"migrations,omitempty"`
+}
+
+// Verify reads the whole archive and checks it against its manifest: the
+// files of the collections must have their checksums and hold whole
+// documents, and the schema must be one the server can migrate from
+func Verify(r io.Reader, latestSchemaVersion int64) (Manifest, error) {
+	found := make(map[string]Collection)
+	var manifest *Manifest
+	err := readArchive(r, func(header *tar.Header, body io.Reader) error {
+		if manifest != nil {
+			return archiveErrorf("%s is after the manifest", header.Name)
+		}
+		if header.Name == manifestName {
+			manifest = &Manifest{}
+			if err := json.NewDecoder(body).Decode(manifest); err != nil {
+				return archiveErrorf("the manifest: %w", err)
+			}
+			return nil
+		}
+		if !strings.HasPrefix(header.Name, collectionsDir) {
+			return archiveErrorf("unknown file %s", header.Name)
+		}
+		if _, ok := found[header.Name]; ok {
+			return archiveErrorf("%s is twice in the archive", header.Name)
+		}
+		hash := sha256.New()
+		collection := Collection{File: header.Name}
+		err := readDocuments(io.TeeReader(body, hash), func(doc bson.Raw) error {
+			collection.Documents++
+			collection.Size += int64(len(doc))
+			return nil
+		})
+		if err != nil {
+			return archiveErrorf("%s: %w", header.Name, err)
+		}
+		collection.SHA256 = hex.EncodeToString(hash.Sum(nil))
+		found[header.Name] = collection
+		return nil
+	})
+	if err != nil {
+		return Manifest{}, err
+	}
+	if manifest == nil {
+		return Manifest{}, archiveErrorf("the archive has no manifest")
+	}
+
+	if manifest.Version != FormatVersion {
+		return *manifest, archiveErrorf("the archive has format %d, the server reads format %d", manifest.Version, FormatVersion)
+	}
+	if manifest.SchemaVersion > latestSchemaVersion {
+		return *manifest, archiveErrorf("the archive has schema version %d, which is newer than the server's %d",
+			manifest.SchemaVersion, latestSchemaVersion)
+	}
+	for _, collection := range manifest.Collections {
+		if !validName(collection.Name) || collection.File != collectionsDir+collection.Name+".bson" {
+			return *manifest, archiveErrorf("the manifest has a wrong collection %q", collection.Name)
+		}
+		file, ok := found[collection.File]
+		if !ok {
+			return *manifest, archiveErrorf("%s is missing", collection.File)
+		}
+		if file.SHA256 != collection.SHA256 || file.Size != collection.Size || file.Documents != collection.Documents {
+			return *manifest, archiveErrorf("%s doesn't match its checksum", collection.File)
+		}
+		delete(found, collection.File)
+	}
+	for name := range found {
+		return *manifest, archiveErrorf("%s is not in the manifest", name)
+	}
+	return *manifest, nil
+}
+
+// validName tells whether a collection can be restored, system collections
+// and names that aren't a file of their own can't
+func validName(name string) bool {
+	return name != "" && !strings.HasPrefix(name, "system.") && !strings.ContainsAny(name, "/\\$\x00")
+}
+
+// Restore replaces the collections of the database with those of the archive
+// at path, and migrates them when the archive has an older schema. The archive
+// is verified first, a dry run stops there. The collections the archive
+// doesn't have are kept. A restore that fails partway leaves the database
+// partly restored, and can be run again
+func Restore(ctx context.Context, database Database, path string, dryRun bool) (Report, error) {
+	report := Report{DryRun: dryRun, LatestSchemaVersion: database.LatestSchemaVersion()}
+	file, err := os.Open(path)
+	if err != nil {
+		return report, err
+	}
+	defer file.Close()
+	report.Manifest, err = Verify(file, report.LatestSchemaVersion)
+	if err != nil {
+		return report, err
+	}
+
+	names, err := database.CollectionNames(ctx)
+	if err != nil {
+		return report, err
+	}
+	restored := make(map[string]bool, len(report.Collections))
+	for _, collection := range report.Collections {
+		restored[collection.Name] = true
+	}
+	for _, name := range names {
+		if !restored[name] {
+			report.Kept = append(report.Kept, name)
+		}
+	}
+	if dryRun {
+		return report, nil
+	}
+
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return report, err
+	}
+	err = readArchive(file, func(header *tar.Header, body io.Reader) error {
+		if header.Name == manifestName {
+			return nil
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(header.Name, collectionsDir), ".bson")
+		if err := restoreCollection(ctx, database, name, body); err != nil {
+			return fmt.Errorf("collection %s: %w", name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	if report.SchemaVersion < report.LatestSchemaVersion {
+		migrations, err := database.MigrateFrom(ctx, report.SchemaVersion)
+		for _, migration := range migrations {
+			report.Migrations = append(report.Migrations, fmt.Sprintf("%d %s", migration.Version, migration.Name))
+		}
+		if err != nil {
+			return report, fmt.Errorf("migrate the restored data: %w", err)
+		}
+	}
+	return report, nil
+}
+
+func restoreCollection(ctx context.Context, database Database, name string, body io.Reader) error {
+	if err := database.ClearCollection(ctx, name); err != nil {
+		return err
+	}
+	batch := make([]bson.Raw, 0, restoreBatchSize)
+	err := readDocuments(body, func(doc bson.Raw) error {
+		batch = append(batch, doc)
+		if len(batch) < restoreBatchSize {
+			return nil
+		}
+		err := database.InsertDocuments(ctx, name, batch)
+		batch = make([]bson.Raw, 0, restoreBatchSize)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return database.InsertDocuments(ctx, name, batch)
+}
+
+// readArchive calls fn with each file of a gzipped tar file
+func readArchive(r io.Reader, fn func(header *tar.Header, body io.Reader) error) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return &ArchiveError{err}
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return &ArchiveError{err}
+		}
+		if header.Typeflag != tar.TypeReg {
+			return archiveErrorf("%s is not a file", header.Name)
+		}
+		if err = fn(header, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// readDocuments calls fn with each BSON document of r, and fails
+// on a document that is cut short or isn't valid
+func readDocuments(r io.Reader, fn func(doc bson.Raw) error) error {
+	var length [4]byte
+	for {
+		if _, err := io.ReadFull(r, length[:]); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		n := int32(binary.LittleEndian.Uint32(length[:]))
+		if n < 5 || n > maxDocumentSize {
+			return errors.New("a document has a wrong length")
+		}
+		doc := make(bson.Raw, n)
+		copy(doc, length[:])
+		if _, err := io.ReadFull(r, doc[4:]); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		if err := doc.Validate(); err != nil {
+			return err
+		}
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package backup
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Schedule writes an archive to dir every interval until ctx is done, and
+// keeps the latest keep archives, or all of them when keep is 0. An archive
+// that fails is logged, and the next is written on time
+func Schedule(ctx context.Context, database Database, dir string, interval time.Duration, keep int, opt Options) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		path, manifest, err := WriteFile(ctx, database, dir, opt)
+		if err != nil {
+			log.Print("backup: ", err)
+			continue
+		}
+		log.Printf("backup: wrote %s, %d collections", path, len(manifest.Collections))
+		removed, err := Prune(dir, keep)
+		if err != nil {
+			log.Print("backup: ", err)
+		}
+		for _, path := range removed {
+			log.Print("backup: removed ", path)
+		}
+	}
+}
+
+// Prune removes the archives of dir but the latest keep, by their names, see
+// FileName, and returns the paths it removed. It keeps them all when keep is 0
+func Prune(dir string, keep int) ([]string, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "phantom-*.tar.gz"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	var removed []string
+	for len(paths) > keep {
+		if err = os.Remove(paths[0]); err != nil {
+			return removed, err
+		}
+		removed = append(removed, paths[0])
+		paths = paths[1:]
+	}
+	return removed, nil
+}
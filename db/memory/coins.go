@@ -0,0 +1,193 @@
+package memdb
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"sort"
+	"time"
+)
+
+var errCoinAmount = errors.New("the amount has to be positive")
+
+// transferCoins makes a transfer, within a transaction of the store
+func (t *tables) transferCoins(arg db.TransferCoinsParams) (db.TransferCoinsResult, error) {
+	if arg.Amount <= 0 {
+		return db.TransferCoinsResult{}, errCoinAmount
+	}
+	postings := []db.CoinPosting{{Account: arg.From, Amount: -arg.Amount}, {Account: arg.To, Amount: arg.Amount}}
+
+	for _, existing := range t.coinTransactions {
+		if existing.Key != arg.Key {
+			continue
+		}
+		same := existing.Kind == arg.Kind && len(existing.Postings) == len(postings)
+		for i := 0; same && i < len(postings); i++ {
+			same = existing.Postings[i] == postings[i]
+		}
+		if !same {
+			return db.TransferCoinsResult{}, db.ErrCoinKeyReused
+		}
+		var transaction db.CoinTransaction
+		db.Convert(existing, &transaction)
+		return db.TransferCoinsResult{Transaction: transaction, Replayed: true}, nil
+	}
+
+	now := primitive.NewDateTimeFromTime(time.Now())
+	owes := arg.From == db.CoinAccountIssued || arg.Debt
+	from := t.findCoinWallet(arg.From)
+	if from < 0 && owes {
+		t.coinWallets = append(t.coinWallets, db.CoinWallet{Account: arg.From})
+		from = len(t.coinWallets) - 1
+	}
+	if from < 0 || (!owes && t.coinWallets[from].Balance < arg.Amount) {
+		return db.TransferCoinsResult{}, db.ErrInsufficientCoins
+	}
+	t.coinWallets[from].Balance -= arg.Amount
+	t.coinWallets[from].UpdatedAt = now
+
+	to := t.findCoinWallet(arg.To)
+	if to < 0 {
+		t.coinWallets = append(t.coinWallets, db.CoinWallet{Account: arg.To})
+		to = len(t.coinWallets) - 1
+	}
+	t.coinWallets[to].Balance += arg.Amount
+	t.coinWallets[to].UpdatedAt = now
+
+	transaction := db.CoinTransaction{
+		ID:        primitive.NewObjectID(),
+		Key:       arg.Key,
+		Kind:      arg.Kind,
+		Postings:  postings,
+		Memo:      arg.Memo,
+		CreatedAt: now,
+	}
+	t.coinTransactions = append(t.coinTransactions, transaction)
+	return db.TransferCoinsResult{Transaction: transaction}, nil
+}
+
+func (t *tables) findCoinWallet(account string) int {
+	for i, wallet := range t.coinWallets {
+		if wallet.Account == account {
+			return i
+		}
+	}
+	return -1
+}
+
+// TransferCoinsTx makes a transfer: the transaction and both balances are
+// written together or not at all
+func (store *Store) TransferCoinsTx(ctx context.Context, arg db.TransferCoinsParams) (db.TransferCoinsResult, error) {
+	var result db.TransferCoinsResult
+	err := store.execTx(func(data *tables) error {
+		var err error
+		result, err = data.transferCoins(arg)
+		return err
+	})
+	return result, err
+}
+
+// TransferCoins makes a transfer, which is written whole or not at all
+// as it fails before it writes anything
+func (q *Queries) TransferCoins(ctx context.Context, arg db.TransferCoinsParams) (db.TransferCoinsResult, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.data.transferCoins(arg)
+}
+
+// ReverseCoins moves the coins of a transaction back, and is replayed by its
+// key like a transfer. It is ErrNoDocuments when there is no transaction to reverse
+func (q *Queries) ReverseCoins(ctx context.Context, arg db.ReverseCoinsParams) (db.TransferCoinsResult, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, reversed := range q.data.coinTransactions {
+		if reversed.Key == arg.Reverses {
+			return q.data.transferCoins(arg.Transfer(reversed))
+		}
+	}
+	return db.TransferCoinsResult{}, mongo.ErrNoDocuments
+}
+
+// GetCoinWallet gets the wallet of an account, ErrNoDocuments
+// until the account has had a transaction
+func (q *Queries) GetCoinWallet(ctx context.Context, account string) (db.CoinWallet, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	i := q.data.findCoinWallet(account)
+	if i < 0 {
+		return db.CoinWallet{}, mongo.ErrNoDocuments
+	}
+	return q.data.coinWallets[i], nil
+}
+
+// GetCoinTransactions gets a page of the transactions of an account, the latest first
+func (q *Queries) GetCoinTransactions(ctx context.Context, arg db.GetCoinTransactionsParams) ([]db.CoinTransaction, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	var transactions []db.CoinTransaction
+	for _, transaction := range q.data.coinTransactions {
+		for _, posting := range transaction.Postings {
+			if posting.Account == arg.Account {
+				transactions = append(transactions, transaction)
+				break
+			}
+		}
+	}
+	sort.SliceStable(transactions, func(i, j int) bool {
+		if transactions[i].CreatedAt != transactions[j].CreatedAt {
+			return transactions[i].CreatedAt > transactions[j].CreatedAt
+		}
+		return idLess(transactions[j].ID, transactions[i].ID)
+	})
+	from, to := page(len(transactions), arg.Skip, arg.Limit)
+	var copied []db.CoinTransaction
+	copyAll(transactions[from:to], &copied)
+	return copied, nil
+}
+
+// ReconcileCoins checks the balances against the transactions
+func (q *Queries) ReconcileCoins(ctx context.Context) (db.CoinReconciliation, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	result := db.CoinReconciliation{Transactions: int64(len(q.data.coinTransactions))}
+	accounts := make(map[string]*db.CoinMismatch)
+	account := func(name string) *db.CoinMismatch {
+		if accounts[name] == nil {
+			accounts[name] = &db.CoinMismatch{Account: name}
+		}
+		return accounts[name]
+	}
+	for _, wallet := range q.data.coinWallets {
+		account(wallet.Account).Balance += wallet.Balance
+	}
+	for _, transaction := range q.data.coinTransactions {
+		var sum int64
+		for _, posting := range transaction.Postings {
+			sum += posting.Amount
+			account(posting.Account).Expected += posting.Amount
+		}
+		if sum != 0 {
+			result.Unbalanced = append(result.Unbalanced, transaction.ID)
+		}
+	}
+
+	names := make([]string, 0, len(accounts))
+	for name := range accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		a := accounts[name]
+		result.Total += a.Balance
+		if a.Balance != a.Expected {
+			result.Mismatches = append(result.Mismatches, *a)
+		}
+	}
+	return result, nil
+}
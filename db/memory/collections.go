@@ -0,0 +1,177 @@
+package memdb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"sort"
+)
+
+func (q *Queries) AddCollection(ctx context.Context, arg db.AddCollectionParams) (primitive.ObjectID, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var collection db.Collection
+	db.Convert(arg, &collection)
+	collection.ID = primitive.NewObjectID()
+	q.data.collections = append(q.data.collections, collection)
+	return collection.ID, nil
+}
+
+func (t *tables) findCollection(id primitive.ObjectID) int {
+	for i, collection := range t.collections {
+		if collection.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (q *Queries) GetCollectionByID(ctx context.Context, id primitive.ObjectID) (db.Collection, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	i := q.data.findCollection(id)
+	if i < 0 {
+		return db.Collection{}, mongo.ErrNoDocuments
+	}
+	var collection db.Collection
+	db.Convert(q.data.collections[i], &collection)
+	return collection, nil
+}
+
+// GetCollections gets a page of collections by title
+func (q *Queries) GetCollections(ctx context.Context, arg db.GetCollectionsParams) ([]db.Collection, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	collections := append([]db.Collection(nil), q.data.collections...)
+	sortCollections(collections)
+	from, to := page(len(collections), arg.Skip, arg.Limit)
+	var copied []db.Collection
+	copyAll(collections[from:to], &copied)
+	return copied, nil
+}
+
+// GetCollectionsByMovie gets the curated collections that list the movie
+// and the smart collections whose filter matches it
+func (q *Queries) GetCollectionsByMovie(ctx context.Context, movie db.Movies) ([]db.Collection, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	var collections []db.Collection
+	for _, collection := range q.data.collections {
+		if collection.Smart() {
+			if collection.Filter.Matches(movie) {
+				collections = append(collections, collection)
+			}
+			continue
+		}
+		for _, id := range collection.MovieIDs {
+			if id == movie.Id {
+				collections = append(collections, collection)
+				break
+			}
+		}
+	}
+	sortCollections(collections)
+	var copied []db.Collection
+	copyAll(collections, &copied)
+	return copied, nil
+}
+
+func sortCollections(collections []db.Collection) {
+	sort.SliceStable(collections, func(i, j int) bool {
+		if collections[i].Title != collections[j].Title {
+			return collections[i].Title < collections[j].Title
+		}
+		return idLess(collections[i].ID, collections[j].ID)
+	})
+}
+
+// GetCollectionMovies gets a page of the movies of a collection,
+// in the order of a curated collection or the sort of a smart one
+func (q *Queries) GetCollectionMovies(ctx context.Context, arg db.GetCollectionMoviesParams) ([]db.Movies, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if arg.Collection.Smart() {
+		filter := arg.Collection.Filter
+		var movies []db.Movies
+		for _, movie := range q.data.movies {
+			if filter.Matches(movie) {
+				movies = append(movies, movie)
+			}
+		}
+		sort.SliceStable(movies, func(i, j int) bool {
+			a, b := movies[i], movies[j]
+			switch filter.Sort {
+			case db.CollectionSortRating:
+				if a.Imdb.Rating != b.Imdb.Rating {
+					return a.Imdb.Rating > b.Imdb.Rating
+				}
+			case db.CollectionSortTitle:
+				if a.SortTitle != b.SortTitle {
+					return a.SortTitle < b.SortTitle
+				}
+			default:
+				if a.Year != b.Year {
+					return a.Year < b.Year
+				}
+			}
+			return idLess(a.Id, b.Id)
+		})
+		from, to := page(len(movies), arg.Skip, arg.Limit)
+		return q.data.copyMovies(movies[from:to], true), nil
+	}
+
+	ids := arg.Collection.MovieIDs
+	if arg.Skip >= int64(len(ids)) {
+		return nil, nil
+	}
+	ids = ids[arg.Skip:]
+	if arg.Limit > 0 && arg.Limit < int64(len(ids)) {
+		ids = ids[:arg.Limit]
+	}
+	// Movies removed from the catalog are left out
+	var movies []db.Movies
+	for _, id := range ids {
+		if i := q.data.findMovie(id); i >= 0 {
+			movies = append(movies, q.data.movies[i])
+		}
+	}
+	return q.data.copyMovies(movies, false), nil
+}
+
+// ReplaceCollection replaces a collection, it can turn a curated collection
+// into a smart one and back
+func (q *Queries) ReplaceCollection(ctx context.Context, collection db.Collection) (*mongo.UpdateResult, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := q.data.findCollection(collection.ID)
+	if i < 0 {
+		return nil, mongo.ErrNoDocuments
+	}
+	var replaced db.Collection
+	db.Convert(collection, &replaced)
+	res := &mongo.UpdateResult{MatchedCount: 1}
+	if !sameDocument(replaced, q.data.collections[i]) {
+		res.ModifiedCount = 1
+	}
+	q.data.collections[i] = replaced
+	return res, nil
+}
+
+func (q *Queries) DeleteCollection(ctx context.Context, id primitive.ObjectID) (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := q.data.findCollection(id)
+	if i < 0 {
+		return 0, mongo.ErrNoDocuments
+	}
+	q.data.collections = append(q.data.collections[:i:i], q.data.collections[i+1:]...)
+	return 1, nil
+}
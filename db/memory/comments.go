@@ -0,0 +1,282 @@
+package memdb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"phantom/ledger"
+	"sort"
+	"time"
+)
+
+// AddComment adds a comment dated now, and records it in the ledger.
+// It needs a text like the validator of 'comments'
+func (q *Queries) AddComment(ctx context.Context, arg db.AddCommentParams) (primitive.ObjectID, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if arg.Text == "" {
+		return primitive.ObjectID{}, errValidation()
+	}
+	var comment db.Comments
+	db.Convert(arg, &comment)
+	comment.ID = primitive.NewObjectID()
+	comment.Date = primitive.NewDateTimeFromTime(time.Now())
+	q.data.comments = append(q.data.comments, comment)
+	q.data.appendLedger(db.LedgerComment, ledger.OpAdd, comment.ID, db.CommentDigest(comment))
+	return comment.ID, nil
+}
+
+func (t *tables) findComment(id primitive.ObjectID) int {
+	for i, comment := range t.comments {
+		if comment.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (q *Queries) GetComment(ctx context.Context, id primitive.ObjectID) (db.Comments, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	i := q.data.findComment(id)
+	if i < 0 {
+		return db.Comments{}, mongo.ErrNoDocuments
+	}
+	var comment db.Comments
+	db.Convert(q.data.comments[i], &comment)
+	return comment, nil
+}
+
+// GetAllComments gets every comment but the deleted and hidden ones
+func (q *Queries) GetAllComments(ctx context.Context) ([]db.Comments, error) {
+	return q.filterComments(func(comment db.Comments) bool {
+		return !comment.Deleted && !comment.Hidden
+	}, nil, 0, 0), nil
+}
+
+func (q *Queries) GetCommentsByMovieID(ctx context.Context, arg db.GetCommentsParams) ([]db.Comments, error) {
+	var less func(a, b db.Comments) bool
+	switch arg.Sort {
+	case db.CommentSortTop:
+		less = func(a, b db.Comments) bool {
+			if a.ReactionCount != b.ReactionCount {
+				return a.ReactionCount > b.ReactionCount
+			}
+			return newerComment(a, b)
+		}
+	case db.CommentSortNew:
+		less = newerComment
+	case db.CommentSortOld:
+		less = func(a, b db.Comments) bool { return newerComment(b, a) }
+	}
+	skip := arg.Skip
+	if arg.Limit <= 0 {
+		skip = 0
+	}
+	return q.filterComments(func(comment db.Comments) bool {
+		return comment.MovieID == arg.MovieID && (!arg.TopLevel || comment.ParentID.IsZero())
+	}, less, skip, arg.Limit), nil
+}
+
+func newerComment(a, b db.Comments) bool {
+	if a.Date != b.Date {
+		return a.Date > b.Date
+	}
+	return idLess(b.ID, a.ID)
+}
+
+func (q *Queries) GetCommentsByName(ctx context.Context, arg db.GetCommentsParams) ([]db.Comments, error) {
+	skip := arg.Skip
+	if arg.Limit <= 0 {
+		skip = 0
+	}
+	return q.filterComments(func(comment db.Comments) bool {
+		return comment.Name == arg.Name && !comment.Deleted
+	}, nil, skip, arg.Limit), nil
+}
+
+// filterComments gets a page of the comments match matches, sorted by less
+// when it isn't nil and in natural order when it is
+func (q *Queries) filterComments(match func(comment db.Comments) bool, less func(a, b db.Comments) bool, skip, limit int64) []db.Comments {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	var comments []db.Comments
+	for _, comment := range q.data.comments {
+		if match(comment) {
+			comments = append(comments, comment)
+		}
+	}
+	if less != nil {
+		sort.SliceStable(comments, func(i, j int) bool { return less(comments[i], comments[j]) })
+	}
+	from, to := page(len(comments), skip, limit)
+	var copied []db.Comments
+	copyAll(comments[from:to], &copied)
+	return copied
+}
+
+// UpdateComment changes the text of a comment of a user, and adds
+// the Flags of the comment to the ones it had. The edit is recorded in the ledger
+func (q *Queries) UpdateComment(ctx context.Context, comment db.Comments) (*mongo.UpdateResult, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := q.data.findComment(comment.ID)
+	if i < 0 || q.data.comments[i].Name != comment.Name || q.data.comments[i].Deleted {
+		return nil, mongo.ErrNoDocuments
+	}
+	edited := q.data.comments[i]
+	flags := addToSet(edited.Flags, comment.Flags)
+	if edited.Text == comment.Text && len(flags) == len(edited.Flags) {
+		return nil, mongo.ErrNoDocuments
+	}
+	edited.Text = comment.Text
+	edited.Flags = flags
+	q.data.comments[i] = edited
+	q.data.appendLedger(db.LedgerComment, ledger.OpEdit, comment.ID, db.CommentDigest(edited))
+	return &mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil
+}
+
+// addToSet adds the values set doesn't have yet, to a copy of set
+func addToSet(set []string, values []string) []string {
+	added := set
+	for _, v := range values {
+		if !containsString(added, v) {
+			added = append(added[:len(added):len(added)], v)
+		}
+	}
+	return added
+}
+
+// DeleteComment deletes a comment of a user. A comment with replies is only
+// marked Deleted and loses its text and email, so that its replies keep their place
+func (q *Queries) DeleteComment(ctx context.Context, id primitive.ObjectID, name string) (int64, error) {
+	return q.deleteComment(id, func(comment db.Comments) bool { return comment.Name == name })
+}
+
+// RemoveComment deletes a comment on behalf of a moderator, like DeleteComment
+func (q *Queries) RemoveComment(ctx context.Context, id primitive.ObjectID) (int64, error) {
+	return q.deleteComment(id, func(db.Comments) bool { return true })
+}
+
+// deleteComment deletes the comment with the id if match matches it and it
+// isn't deleted already, and records the deletion in the ledger
+func (q *Queries) deleteComment(id primitive.ObjectID, match func(comment db.Comments) bool) (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := q.data.findComment(id)
+	if i < 0 || q.data.comments[i].Deleted || !match(q.data.comments[i]) {
+		return 0, mongo.ErrNoDocuments
+	}
+	if q.data.hasReplies(id) {
+		q.data.comments[i].Deleted = true
+		q.data.comments[i].Text = ""
+		q.data.comments[i].Email = ""
+	} else {
+		q.data.comments = append(q.data.comments[:i:i], q.data.comments[i+1:]...)
+		q.data.deleteFeedback(map[primitive.ObjectID]bool{id: true})
+	}
+	q.data.appendLedger(db.LedgerComment, ledger.OpDelete, id, ledger.Hash{})
+	return 1, nil
+}
+
+func (t *tables) hasReplies(id primitive.ObjectID) bool {
+	for _, comment := range t.comments {
+		if comment.ParentID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteFeedback deletes the reactions to and the reports of the comments
+func (t *tables) deleteFeedback(ids map[primitive.ObjectID]bool) {
+	var reactions []db.CommentReaction
+	for _, reaction := range t.reactions {
+		if !ids[reaction.CommentID] {
+			reactions = append(reactions, reaction)
+		}
+	}
+	var reports []db.CommentReport
+	for _, report := range t.reports {
+		if !ids[report.CommentID] {
+			reports = append(reports, report)
+		}
+	}
+	t.reactions, t.reports = reactions, reports
+}
+
+// DeleteCommentsByMovieID deletes every comment on a movie, with their reactions
+// and reports, and gives the ids of the comments it deleted
+func (q *Queries) DeleteCommentsByMovieID(ctx context.Context, movieID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var ids []primitive.ObjectID
+	deleted := make(map[primitive.ObjectID]bool)
+	var kept []db.Comments
+	for _, comment := range q.data.comments {
+		if comment.MovieID != movieID {
+			kept = append(kept, comment)
+			continue
+		}
+		ids = append(ids, comment.ID)
+		deleted[comment.ID] = true
+		// The comments that were kept for their replies are in the ledger as deleted already
+		if !comment.Deleted {
+			q.data.appendLedger(db.LedgerComment, ledger.OpDelete, comment.ID, ledger.Hash{})
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	q.data.comments = kept
+	q.data.deleteFeedback(deleted)
+	return ids, nil
+}
+
+// GetReplies gets a page of the replies to a comment, the oldest first
+func (q *Queries) GetReplies(ctx context.Context, arg db.GetRepliesParams) ([]db.Comments, error) {
+	return q.filterComments(func(comment db.Comments) bool {
+		return comment.ParentID == arg.ParentID
+	}, func(a, b db.Comments) bool { return idLess(a.ID, b.ID) }, arg.Skip, arg.Limit), nil
+}
+
+// GetReplySummaries counts the replies to each of the comments and gets
+// the first n of them, the comments without replies are left out
+func (q *Queries) GetReplySummaries(ctx context.Context, parentIDs []primitive.ObjectID, n int64) ([]db.ReplySummary, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	var summaries []db.ReplySummary
+	seen := make(map[primitive.ObjectID]bool)
+	for _, parentID := range parentIDs {
+		if seen[parentID] {
+			continue
+		}
+		seen[parentID] = true
+		var replies []db.Comments
+		for _, comment := range q.data.comments {
+			if comment.ParentID == parentID && !parentID.IsZero() {
+				replies = append(replies, comment)
+			}
+		}
+		if len(replies) == 0 {
+			continue
+		}
+		sort.SliceStable(replies, func(i, j int) bool { return idLess(replies[i].ID, replies[j].ID) })
+		summary := db.ReplySummary{ParentID: parentID, Count: int64(len(replies))}
+		first := replies
+		if n >= 0 && int(n) < len(first) {
+			first = first[:n]
+		}
+		db.Convert(first, &summary.Replies)
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
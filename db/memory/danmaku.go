@@ -0,0 +1,186 @@
+package memdb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"phantom/ledger"
+	"sort"
+	"time"
+)
+
+// AddDanmaku adds a danmaku dated now, and records it in the ledger
+func (q *Queries) AddDanmaku(ctx context.Context, arg db.AddDanmakuParams) (db.Danmaku, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	danmaku := db.Danmaku{
+		ID:      primitive.NewObjectID(),
+		MovieID: arg.MovieID,
+		Offset:  arg.Offset,
+		Text:    arg.Text,
+		Color:   arg.Color,
+		Mode:    arg.Mode,
+		Name:    arg.Name,
+		Date:    primitive.NewDateTimeFromTime(time.Now()),
+		Flags:   arg.Flags,
+	}
+	var stored db.Danmaku
+	db.Convert(danmaku, &stored)
+	q.data.danmaku = append(q.data.danmaku, stored)
+	q.data.appendLedger(db.LedgerDanmaku, ledger.OpAdd, danmaku.ID, db.DanmakuDigest(danmaku))
+	return danmaku, nil
+}
+
+// GetDanmaku gets the danmaku of a time window of a movie in the order they show,
+// leaving out the flagged ones. Where a scene has more than PerBucket in a bucket
+// the pinned ones and then the latest posted are kept
+func (q *Queries) GetDanmaku(ctx context.Context, arg db.GetDanmakuParams) ([]db.Danmaku, error) {
+	if arg.Bucket == 0 {
+		return nil, mongo.CommandError{Code: 2, Message: "can't $divide by zero"}
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	var matched []db.Danmaku
+	for _, danmaku := range q.data.danmaku {
+		if danmaku.MovieID == arg.MovieID && danmaku.Offset >= arg.From && danmaku.Offset < arg.To && len(danmaku.Flags) == 0 {
+			matched = append(matched, danmaku)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		if matched[i].Pinned != matched[j].Pinned {
+			return matched[i].Pinned
+		}
+		return idLess(matched[j].ID, matched[i].ID)
+	})
+
+	var danmaku []db.Danmaku
+	shown := make(map[int64]int64)
+	for _, d := range matched {
+		bucket := floorDiv(d.Offset, arg.Bucket)
+		if shown[bucket] < arg.PerBucket {
+			shown[bucket]++
+			danmaku = append(danmaku, d)
+		}
+	}
+	sort.Slice(danmaku, func(i, j int) bool {
+		if danmaku[i].Offset != danmaku[j].Offset {
+			return danmaku[i].Offset < danmaku[j].Offset
+		}
+		return idLess(danmaku[i].ID, danmaku[j].ID)
+	})
+	var copied []db.Danmaku
+	copyAll(danmaku, &copied)
+	return copied, nil
+}
+
+// floorDiv is a divided by b rounded down, like $floor of $divide
+func floorDiv(a, b int64) int64 {
+	d := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		d--
+	}
+	return d
+}
+
+// GetDanmakuModerationQueue gets a page of the danmaku the content filter
+// flagged, which wait for review, the oldest first
+func (q *Queries) GetDanmakuModerationQueue(ctx context.Context, arg db.GetModerationQueueParams) ([]db.Danmaku, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	var flagged []db.Danmaku
+	for _, danmaku := range q.data.danmaku {
+		if len(danmaku.Flags) > 0 {
+			flagged = append(flagged, danmaku)
+		}
+	}
+	sort.SliceStable(flagged, func(i, j int) bool { return idLess(flagged[i].ID, flagged[j].ID) })
+	from, to := page(len(flagged), arg.Skip, arg.Limit)
+	if from == to {
+		return nil, nil
+	}
+	var copied []db.Danmaku
+	copyAll(flagged[from:to], &copied)
+	return copied, nil
+}
+
+// ApproveDanmaku shows a flagged danmaku by clearing its flags, and returns it.
+// It returns mongo.ErrNoDocuments when there is no such danmaku
+func (q *Queries) ApproveDanmaku(ctx context.Context, id primitive.ObjectID) (db.Danmaku, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := q.data.findDanmaku(id)
+	if i < 0 {
+		return db.Danmaku{}, mongo.ErrNoDocuments
+	}
+	q.data.danmaku[i].Flags = nil
+	var danmaku db.Danmaku
+	db.Convert(q.data.danmaku[i], &danmaku)
+	return danmaku, nil
+}
+
+// RemoveDanmaku deletes a danmaku on behalf of a moderator, records it
+// in the ledger, and returns it
+func (q *Queries) RemoveDanmaku(ctx context.Context, id primitive.ObjectID) (db.Danmaku, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := q.data.findDanmaku(id)
+	if i < 0 {
+		return db.Danmaku{}, mongo.ErrNoDocuments
+	}
+	danmaku := q.data.danmaku[i]
+	q.data.danmaku = append(q.data.danmaku[:i:i], q.data.danmaku[i+1:]...)
+	q.data.appendLedger(db.LedgerDanmaku, ledger.OpDelete, id, ledger.Hash{})
+	return danmaku, nil
+}
+
+func (t *tables) findDanmaku(id primitive.ObjectID) int {
+	for i, danmaku := range t.danmaku {
+		if danmaku.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// PinDanmakuTx pins a danmaku and takes the coins for it together.
+// A danmaku is pinned once, the flagged ones can't be
+func (store *Store) PinDanmakuTx(ctx context.Context, arg db.PinDanmakuTxParams) (db.PinDanmakuTxResult, error) {
+	var result db.PinDanmakuTxResult
+	err := store.execTx(func(data *tables) error {
+		i := -1
+		for j, danmaku := range data.danmaku {
+			if danmaku.ID == arg.DanmakuID && len(danmaku.Flags) == 0 {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			return mongo.ErrNoDocuments
+		}
+		if data.danmaku[i].Pinned {
+			return db.ErrDanmakuPinned
+		}
+
+		transfer, err := data.transferCoins(db.TransferCoinsParams{
+			Key:    db.CoinPinDanmaku + ":" + arg.DanmakuID.Hex(),
+			Kind:   db.CoinPinDanmaku,
+			From:   db.UserCoinAccount(arg.Name),
+			To:     db.CoinAccountSpent,
+			Amount: arg.Cost,
+		})
+		if err != nil {
+			return err
+		}
+		result.Transaction = transfer.Transaction
+		data.danmaku[i].Pinned = true
+		db.Convert(data.danmaku[i], &result.Danmaku)
+		return nil
+	})
+	return result, err
+}
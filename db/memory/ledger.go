@@ -0,0 +1,104 @@
+package memdb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"phantom/ledger"
+)
+
+// appendLedger appends an entry to the ledger, and checkpoints the ledger
+// when an interval of entries has followed the latest checkpoint. The entries
+// are never deleted, so that each is at its Seq in the table
+func (t *tables) appendLedger(kind, op string, refID primitive.ObjectID, digest ledger.Hash) {
+	var prev *ledger.Entry
+	if len(t.ledger) > 0 {
+		prev = &t.ledger[len(t.ledger)-1]
+	}
+	entry := ledger.Append(prev, kind, op, refID.Hex(), digest)
+	t.ledger = append(t.ledger, entry)
+	t.checkpointLedger(false)
+}
+
+// checkpointLedger checkpoints the entries that follow the latest checkpoint,
+// an interval at a time, and the ones left over when tail is true
+func (t *tables) checkpointLedger(tail bool) {
+	var from int64
+	if len(t.ledgerCheckpoints) > 0 {
+		from = t.ledgerCheckpoints[len(t.ledgerCheckpoints)-1].To
+	}
+	to := int64(len(t.ledger))
+	for ; from < to; from += db.LedgerCheckpointInterval {
+		end := from + db.LedgerCheckpointInterval
+		if end > to {
+			if !tail {
+				return
+			}
+			end = to
+		}
+		t.ledgerCheckpoints = append(t.ledgerCheckpoints, ledger.NewCheckpoint(t.ledger[from:end]))
+	}
+}
+
+// CheckpointLedger checkpoints the entries that follow the latest checkpoint,
+// the last of which are fewer than an interval, so that each entry so far
+// can be proved
+func (q *Queries) CheckpointLedger(ctx context.Context) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.data.checkpointLedger(true)
+	return nil
+}
+
+// GetLedgerProof proves the latest entry of a comment or a danmaku
+// is in the ledger. It is db.ErrNotCheckpointed until the entry is checkpointed
+func (q *Queries) GetLedgerProof(ctx context.Context, refID primitive.ObjectID) (ledger.Proof, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	ref := refID.Hex()
+	latest := -1
+	for i := len(q.data.ledger) - 1; i >= 0; i-- {
+		if q.data.ledger[i].Ref == ref {
+			latest = i
+			break
+		}
+	}
+	if latest < 0 {
+		return ledger.Proof{}, mongo.ErrNoDocuments
+	}
+
+	seq := q.data.ledger[latest].Seq
+	for _, checkpoint := range q.data.ledgerCheckpoints {
+		if !checkpoint.Covers(seq) {
+			continue
+		}
+		proof, err := ledger.NewProof(q.data.ledger[checkpoint.From:checkpoint.To], seq)
+		if err != nil {
+			return ledger.Proof{}, err
+		}
+		proof.Checkpoint = checkpoint
+		return proof, nil
+	}
+	return ledger.Proof{}, db.ErrNotCheckpointed
+}
+
+// VerifyLedger checks the whole ledger against its checkpoints and against
+// the comments and danmaku it records, see ledger.Verify
+func (q *Queries) VerifyLedger(ctx context.Context) ([]ledger.Problem, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	// A deleted comment that is kept for its replies counts as gone
+	current := make(map[string]ledger.Hash)
+	for _, comment := range q.data.comments {
+		if !comment.Deleted {
+			current[comment.ID.Hex()] = db.CommentDigest(comment)
+		}
+	}
+	for _, danmaku := range q.data.danmaku {
+		current[danmaku.ID.Hex()] = db.DanmakuDigest(danmaku)
+	}
+	return ledger.Verify(q.data.ledger, q.data.ledgerCheckpoints, current), nil
+}
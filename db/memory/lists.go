@@ -0,0 +1,127 @@
+package memdb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"sort"
+	"time"
+)
+
+func (t *tables) findListEntry(name, list string, movieID primitive.ObjectID) int {
+	for i, entry := range t.lists {
+		if entry.Name == name && entry.List == list && entry.MovieID == movieID {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddListEntry adds a movie to a list of a user,
+// a movie that is already in the list keeps its place
+func (q *Queries) AddListEntry(ctx context.Context, arg db.ListEntryParams) (*mongo.UpdateResult, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.data.findListEntry(arg.Name, arg.List, arg.MovieID) >= 0 {
+		return &mongo.UpdateResult{MatchedCount: 1}, nil
+	}
+	now := time.Now()
+	entry := db.ListEntry{
+		ID:       primitive.NewObjectID(),
+		Name:     arg.Name,
+		List:     arg.List,
+		MovieID:  arg.MovieID,
+		Position: now.UnixNano(),
+		AddedAt:  primitive.NewDateTimeFromTime(now),
+	}
+	q.data.lists = append(q.data.lists, entry)
+	return &mongo.UpdateResult{UpsertedCount: 1, UpsertedID: entry.ID}, nil
+}
+
+func (q *Queries) DeleteListEntry(ctx context.Context, arg db.ListEntryParams) (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := q.data.findListEntry(arg.Name, arg.List, arg.MovieID)
+	if i < 0 {
+		return 0, mongo.ErrNoDocuments
+	}
+	q.data.lists = append(q.data.lists[:i:i], q.data.lists[i+1:]...)
+	return 1, nil
+}
+
+// GetListEntries gets a page of the entries of a list of a user, in order
+func (q *Queries) GetListEntries(ctx context.Context, arg db.GetListParams) ([]db.ListEntry, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	var entries []db.ListEntry
+	for _, entry := range q.data.lists {
+		if entry.Name == arg.Name && entry.List == arg.List {
+			entries = append(entries, entry)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Position != entries[j].Position {
+			return entries[i].Position > entries[j].Position
+		}
+		return idLess(entries[j].ID, entries[i].ID)
+	})
+	from, to := page(len(entries), arg.Skip, arg.Limit)
+	if from == to {
+		return nil, nil
+	}
+	return entries[from:to], nil
+}
+
+// ReorderList puts some movies of a list in a new order. They swap their places
+// among themselves, so the other movies of the list stay where they are.
+// It returns mongo.ErrNoDocuments when a movie is not in the list
+func (q *Queries) ReorderList(ctx context.Context, arg db.ReorderListParams) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	indexes := make([]int, 0, len(arg.MovieIDs))
+	seen := make(map[primitive.ObjectID]bool, len(arg.MovieIDs))
+	for _, id := range arg.MovieIDs {
+		if seen[id] {
+			// A movie is in a list once, so it can't match twice
+			return mongo.ErrNoDocuments
+		}
+		seen[id] = true
+		i := q.data.findListEntry(arg.Name, arg.List, id)
+		if i < 0 {
+			return mongo.ErrNoDocuments
+		}
+		indexes = append(indexes, i)
+	}
+	if len(indexes) == 0 {
+		return mongo.ErrEmptySlice
+	}
+
+	positions := make([]int64, len(indexes))
+	for i, index := range indexes {
+		positions[i] = q.data.lists[index].Position
+	}
+	sort.Slice(positions, func(i, j int) bool { return positions[i] > positions[j] })
+	for i, index := range indexes {
+		q.data.lists[index].Position = positions[i]
+	}
+	return nil
+}
+
+// GetListsByMovie gets the names of the lists of a user that have the movie
+func (q *Queries) GetListsByMovie(ctx context.Context, name string, movieID primitive.ObjectID) ([]string, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	lists := []string{}
+	for _, entry := range q.data.lists {
+		if entry.Name == name && entry.MovieID == movieID && !containsString(lists, entry.List) {
+			lists = append(lists, entry.List)
+		}
+	}
+	return lists, nil
+}
@@ -0,0 +1,142 @@
+package memdb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"sort"
+	"time"
+)
+
+// ReportComment adds a report of a user to a comment and counts it on the comment,
+// hiding the comment when it has enough reports. It returns the comment after
+// the report, or a duplicate key error when the user had already reported it
+func (q *Queries) ReportComment(ctx context.Context, arg db.ReportCommentParams) (db.Comments, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, report := range q.data.reports {
+		if report.CommentID == arg.CommentID && report.Name == arg.Name {
+			return db.Comments{}, errDuplicateKey("reports", "comment_id_1_name_1")
+		}
+	}
+	q.data.reports = append(q.data.reports, db.CommentReport{
+		ID:        primitive.NewObjectID(),
+		CommentID: arg.CommentID,
+		Name:      arg.Name,
+		Reason:    arg.Reason,
+		Note:      arg.Note,
+		CreatedAt: primitive.NewDateTimeFromTime(time.Now()),
+	})
+
+	i := q.data.findComment(arg.CommentID)
+	if i < 0 {
+		return db.Comments{}, mongo.ErrNoDocuments
+	}
+	reported := &q.data.comments[i]
+	reported.Reports = incCount(reported.Reports, arg.Reason, 1)
+	reported.ReportCount++
+	reported.Hidden = reported.Hidden || reported.ReportCount >= arg.HideThreshold
+	var comment db.Comments
+	db.Convert(*reported, &comment)
+	return comment, nil
+}
+
+// GetModerationQueue gets a page of the reported or flagged comments waiting
+// for review, the hidden ones first, then the most reported
+func (q *Queries) GetModerationQueue(ctx context.Context, arg db.GetModerationQueueParams) ([]db.Comments, error) {
+	return q.filterComments(func(comment db.Comments) bool {
+		return (comment.ReportCount > 0 || len(comment.Flags) > 0) && !comment.Deleted
+	}, func(a, b db.Comments) bool {
+		if a.Hidden != b.Hidden {
+			return a.Hidden
+		}
+		if a.ReportCount != b.ReportCount {
+			return a.ReportCount > b.ReportCount
+		}
+		return idLess(a.ID, b.ID)
+	}, arg.Skip, arg.Limit), nil
+}
+
+// ApproveComment shows a reported comment again and clears its reports
+// and flags, so that users can report it again. It returns mongo.ErrNoDocuments
+// when there is no such comment
+func (q *Queries) ApproveComment(ctx context.Context, id primitive.ObjectID) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := q.data.findComment(id)
+	if i < 0 {
+		return mongo.ErrNoDocuments
+	}
+	approved := &q.data.comments[i]
+	approved.Hidden = false
+	approved.Reports = nil
+	approved.ReportCount = 0
+	approved.Flags = nil
+
+	var reports []db.CommentReport
+	for _, report := range q.data.reports {
+		if report.CommentID != id {
+			reports = append(reports, report)
+		}
+	}
+	q.data.reports = reports
+	return nil
+}
+
+// GetCommentReporters gets the names of the users who reported a comment,
+// until a moderator reviews it
+func (q *Queries) GetCommentReporters(ctx context.Context, commentID primitive.ObjectID) ([]string, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	var reports []db.CommentReport
+	for _, report := range q.data.reports {
+		if report.CommentID == commentID {
+			reports = append(reports, report)
+		}
+	}
+	sort.SliceStable(reports, func(i, j int) bool { return idLess(reports[i].ID, reports[j].ID) })
+	names := make([]string, len(reports))
+	for i, report := range reports {
+		names[i] = report.Name
+	}
+	return names, nil
+}
+
+func (q *Queries) AddModerationLog(ctx context.Context, arg db.AddModerationLogParams) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.data.moderationLog = append(q.data.moderationLog, db.ModerationLogEntry{
+		ID:        primitive.NewObjectID(),
+		Moderator: arg.Moderator,
+		Action:    arg.Action,
+		CommentID: arg.CommentID,
+		DanmakuID: arg.DanmakuID,
+		Author:    arg.Author,
+		CreatedAt: primitive.NewDateTimeFromTime(time.Now()),
+	})
+	return nil
+}
+
+// GetModerationLog gets a page of the moderation log, the latest first
+func (q *Queries) GetModerationLog(ctx context.Context, arg db.GetModerationLogParams) ([]db.ModerationLogEntry, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	entries := append([]db.ModerationLogEntry(nil), q.data.moderationLog...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].CreatedAt != entries[j].CreatedAt {
+			return entries[i].CreatedAt > entries[j].CreatedAt
+		}
+		return idLess(entries[j].ID, entries[i].ID)
+	})
+	from, to := page(len(entries), arg.Skip, arg.Limit)
+	if from == to {
+		return nil, nil
+	}
+	return entries[from:to], nil
+}
@@ -0,0 +1,414 @@
+package memdb
+
+import (
+	"bytes"
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"phantom/util"
+	"sort"
+	"time"
+)
+
+// AddMovie can add a movie information, it needs a title like the validator
+// of 'movies', and the sort title is made from the title when it is not given
+func (q *Queries) AddMovie(ctx context.Context, arg db.AddMovieParams) (primitive.ObjectID, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.data.addMovie(arg)
+}
+
+// AddMovies adds the movies in order and stops at the first one
+// it can't add, keeping the ones before it like an ordered insert does
+func (q *Queries) AddMovies(ctx context.Context, arg []db.AddMovieParams) ([]interface{}, error) {
+	if len(arg) == 0 {
+		return nil, mongo.ErrEmptySlice
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	ids := make([]interface{}, 0, len(arg))
+	for i, v := range arg {
+		id, err := q.data.addMovie(v)
+		if err != nil {
+			return nil, mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{{
+				WriteError: mongo.WriteError{Index: i, Code: codeValidationFailed, Message: "Document failed validation"},
+			}}}
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// UpsertMovies adds each movie or updates the one it matches by its key,
+// see db.Queries.UpsertMovies. It writes the movies unordered like it does,
+// and the ones it can't write are in the mongo.BulkWriteException
+func (q *Queries) UpsertMovies(ctx context.Context, movies []db.Movies) (*mongo.BulkWriteResult, error) {
+	if len(movies) == 0 {
+		return nil, mongo.ErrEmptySlice
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	keys := newMovieKeys(q.data.movies)
+	res := &mongo.BulkWriteResult{UpsertedIDs: make(map[int64]interface{})}
+	var writeErrors []mongo.BulkWriteError
+	for i, movie := range movies {
+		if j := keys.find(q.data.movies, movie.Key()); j >= 0 {
+			merged := db.MergeMovie(q.data.movies[j], movie)
+			res.MatchedCount++
+			if !sameDocument(merged, q.data.movies[j]) {
+				res.ModifiedCount++
+			}
+			q.data.movies[j] = merged
+			keys.add(merged, j)
+			continue
+		}
+
+		merged := db.MergeMovie(db.Movies{}, movie)
+		merged.Id = movie.Id
+		merged.NumMflixComments = movie.NumMflixComments
+		if merged.Id.IsZero() {
+			merged.Id = primitive.NewObjectID()
+		}
+		if merged.Title == "" {
+			writeErrors = append(writeErrors, bulkWriteError(i, errValidation()))
+			continue
+		}
+		if q.data.findMovie(merged.Id) >= 0 {
+			writeErrors = append(writeErrors, bulkWriteError(i, errDuplicateKey("movies", "_id_")))
+			continue
+		}
+		q.data.movies = append(q.data.movies, merged)
+		keys.add(merged, len(q.data.movies)-1)
+		res.UpsertedCount++
+		res.UpsertedIDs[int64(i)] = merged.Id
+	}
+	if len(writeErrors) > 0 {
+		return res, mongo.BulkWriteException{WriteErrors: writeErrors}
+	}
+	return res, nil
+}
+
+// movieKeys finds the first movie with a key, like the indexes of the
+// keys in MongoDB do, so that an upsert of many movies doesn't scan for each
+type movieKeys map[db.MovieKey]int
+
+func newMovieKeys(movies []db.Movies) movieKeys {
+	keys := make(movieKeys, 2*len(movies))
+	for i, movie := range movies {
+		keys.add(movie, i)
+	}
+	return keys
+}
+
+func (keys movieKeys) add(movie db.Movies, i int) {
+	byImdb := db.MovieKey{ImdbID: movie.Imdb.Id}
+	byTitle := db.MovieKey{Title: movie.Title, Year: movie.Year}
+	for _, key := range []db.MovieKey{byImdb, byTitle} {
+		if j, ok := keys[key]; !ok || j > i {
+			keys[key] = i
+		}
+	}
+}
+
+// find is the index of the first movie with the key, or -1. A movie whose
+// key was changed by an update is still under its old key, so it is checked
+func (keys movieKeys) find(movies []db.Movies, key db.MovieKey) int {
+	i, ok := keys[key]
+	if !ok {
+		return -1
+	}
+	if key.Matches(movies[i]) {
+		return i
+	}
+	for i, movie := range movies {
+		if key.Matches(movie) {
+			return i
+		}
+	}
+	return -1
+}
+
+// bulkWriteError is the error of the write at index i of a bulk write
+func bulkWriteError(i int, err error) mongo.BulkWriteError {
+	writeErr := err.(mongo.WriteException).WriteErrors[0]
+	writeErr.Index = i
+	return mongo.BulkWriteError{WriteError: writeErr}
+}
+
+func (t *tables) addMovie(arg db.AddMovieParams) (primitive.ObjectID, error) {
+	if arg.Title == "" {
+		return primitive.ObjectID{}, errValidation()
+	}
+	if arg.SortTitle == "" {
+		arg.SortTitle = util.SortTitle(arg.Title)
+	}
+	var movie db.Movies
+	db.Convert(arg, &movie)
+	movie.Id = primitive.NewObjectID()
+	t.movies = append(t.movies, movie)
+	return movie.Id, nil
+}
+
+func (t *tables) findMovie(id primitive.ObjectID) int {
+	for i, movie := range t.movies {
+		if movie.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// GetMovieByID can get the movie information by movie id
+func (q *Queries) GetMovieByID(ctx context.Context, id primitive.ObjectID) (db.Movies, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	i := q.data.findMovie(id)
+	if i < 0 {
+		return db.Movies{}, mongo.ErrNoDocuments
+	}
+	var movie db.Movies
+	db.Convert(q.data.movies[i], &movie)
+	return movie, nil
+}
+
+// GetAllMovies gets every movie in the order they were added
+func (q *Queries) GetAllMovies(ctx context.Context) ([]db.Movies, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	return q.data.copyMovies(q.data.movies, false), nil
+}
+
+// GetMoviesByIDs gets the movies with the given ids, in no particular order
+func (q *Queries) GetMoviesByIDs(ctx context.Context, ids []primitive.ObjectID) ([]db.Movies, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	wanted := make(map[primitive.ObjectID]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	var movies []db.Movies
+	for _, movie := range q.data.movies {
+		if wanted[movie.Id] {
+			movies = append(movies, movie)
+		}
+	}
+	return q.data.copyMovies(movies, false), nil
+}
+
+// SearchForMovies finds the movies with the words of the text in their titles,
+// plots, cast or genres, the best matches first
+func (q *Queries) SearchForMovies(ctx context.Context, arg db.SearchForMoviesParams) ([]db.Movies, error) {
+	if err := checkAggregatePage(arg.Skip, arg.Limit); err != nil {
+		return nil, err
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	query := parseTextQuery(arg.Text)
+	var movies []db.Movies
+	var scores []float64
+	for _, movie := range q.data.movies {
+		if score, ok := query.textScore(movieText(movie)); ok {
+			movies = append(movies, movie)
+			scores = append(scores, score)
+		}
+	}
+	sort.Stable(byScore{movies, scores})
+	from, to := page(len(movies), arg.Skip, arg.Limit)
+	return q.data.copyMovies(movies[from:to], true), nil
+}
+
+type byScore struct {
+	movies []db.Movies
+	scores []float64
+}
+
+func (s byScore) Len() int           { return len(s.movies) }
+func (s byScore) Less(i, j int) bool { return s.scores[i] > s.scores[j] }
+func (s byScore) Swap(i, j int) {
+	s.movies[i], s.movies[j] = s.movies[j], s.movies[i]
+	s.scores[i], s.scores[j] = s.scores[j], s.scores[i]
+}
+
+// GetMoviesByGenres Get the movie information of the past year by movie genres,
+// sorted the ways db.Queries.GetMoviesByGenres sorts them
+func (q *Queries) GetMoviesByGenres(ctx context.Context, arg db.GetMoviesParams) ([]db.Movies, error) {
+	if err := checkAggregatePage(arg.Skip, arg.Limit); err != nil {
+		return nil, err
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	since := primitive.NewDateTimeFromTime(time.Now().AddDate(-1, 0, 0))
+	var movies []db.Movies
+	for _, movie := range q.data.movies {
+		if containsString(movie.Genres, arg.Genres) && movie.Released != 0 && movie.Released >= since {
+			movies = append(movies, movie)
+		}
+	}
+	less := byRuntime
+	switch arg.SortOptions {
+	case "sort_time":
+		less = byReleased
+	case "sort_rating":
+		less = func(a, b db.Movies) bool { return a.Imdb.Rating > b.Imdb.Rating }
+	case "sort_community":
+		less = func(a, b db.Movies) bool {
+			if a.Community.Rating != b.Community.Rating {
+				return a.Community.Rating > b.Community.Rating
+			}
+			return a.Community.Count > b.Community.Count
+		}
+	case "sort_title":
+		less = func(a, b db.Movies) bool { return sortKey(a) < sortKey(b) }
+	}
+	sortMovies(movies, less)
+	from, to := page(len(movies), arg.Skip, arg.Limit)
+	return q.data.copyMovies(movies[from:to], true), nil
+}
+
+func (q *Queries) GetTheMostViewedMovies(ctx context.Context, arg db.GetMoviesParams) ([]db.Movies, error) {
+	return q.getSortedMovies(arg, byRuntime)
+}
+
+// GetTheLatestReleasedMovies is to get the latest released movies
+func (q *Queries) GetTheLatestReleasedMovies(ctx context.Context, arg db.GetMoviesParams) ([]db.Movies, error) {
+	return q.getSortedMovies(arg, byReleased)
+}
+
+func (q *Queries) getSortedMovies(arg db.GetMoviesParams, less func(a, b db.Movies) bool) ([]db.Movies, error) {
+	if err := checkAggregatePage(arg.Skip, arg.Limit); err != nil {
+		return nil, err
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	movies := append([]db.Movies(nil), q.data.movies...)
+	sortMovies(movies, less)
+	from, to := page(len(movies), arg.Skip, arg.Limit)
+	return q.data.copyMovies(movies[from:to], true), nil
+}
+
+func byRuntime(a, b db.Movies) bool { return a.Runtime > b.Runtime }
+
+// byReleased puts the movies without a release date last, like a descending sort does
+func byReleased(a, b db.Movies) bool { return a.Released > b.Released }
+
+// sortKey is the sort title of the movie, or the title for movies without one
+func sortKey(movie db.Movies) string {
+	if movie.SortTitle != "" {
+		return movie.SortTitle
+	}
+	return movie.Title
+}
+
+func sortMovies(movies []db.Movies, less func(a, b db.Movies) bool) {
+	sort.SliceStable(movies, func(i, j int) bool { return less(movies[i], movies[j]) })
+}
+
+// copyMovies copies the movies out of the tables, with only the fields
+// a list of movies shows when project is true
+func (t *tables) copyMovies(movies []db.Movies, project bool) []db.Movies {
+	if len(movies) == 0 {
+		return nil
+	}
+	if project {
+		projected := make([]db.Movies, len(movies))
+		for i, movie := range movies {
+			projected[i] = db.Movies{
+				Id:            movie.Id,
+				Plot:          movie.Plot,
+				Genres:        movie.Genres,
+				Runtime:       movie.Runtime,
+				Cast:          movie.Cast,
+				Poster:        movie.Poster,
+				Title:         movie.Title,
+				OriginalTitle: movie.OriginalTitle,
+				SortTitle:     movie.SortTitle,
+				Titles:        movie.Titles,
+				Released:      movie.Released,
+				Year:          movie.Year,
+				Imdb:          movie.Imdb,
+				Community:     movie.Community,
+				Countries:     movie.Countries,
+			}
+		}
+		movies = projected
+	}
+	var copied []db.Movies
+	db.Convert(movies, &copied)
+	return copied
+}
+
+// ReplaceMovieInfoByID replaces the information of the movie but its id,
+// community rating and number of comments, it returns mongo.ErrNoDocuments when nothing changed
+func (q *Queries) ReplaceMovieInfoByID(ctx context.Context, id primitive.ObjectID, movie db.Movies) (*mongo.UpdateResult, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := q.data.findMovie(id)
+	if i < 0 {
+		return nil, mongo.ErrNoDocuments
+	}
+	if movie.SortTitle == "" {
+		movie.SortTitle = util.SortTitle(movie.Title)
+	}
+	var replaced db.Movies
+	db.Convert(movie, &replaced)
+	replaced.Id = id
+	replaced.Community = q.data.movies[i].Community
+	replaced.NumMflixComments = q.data.movies[i].NumMflixComments
+	if sameDocument(replaced, q.data.movies[i]) {
+		return nil, mongo.ErrNoDocuments
+	}
+	q.data.movies[i] = replaced
+	return &mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil
+}
+
+func sameDocument(a, b interface{}) bool {
+	dataA, errA := bson.Marshal(a)
+	dataB, errB := bson.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(dataA, dataB)
+}
+
+// IncrementMovieComments adds n to the number of comments on a movie
+func (q *Queries) IncrementMovieComments(ctx context.Context, id primitive.ObjectID, n int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := q.data.findMovie(id)
+	if i < 0 {
+		return mongo.ErrNoDocuments
+	}
+	q.data.movies[i].NumMflixComments += n
+	return nil
+}
+
+func (q *Queries) DeleteMovieByID(ctx context.Context, id primitive.ObjectID) (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := q.data.findMovie(id)
+	if i < 0 {
+		return 0, mongo.ErrNoDocuments
+	}
+	q.data.movies = append(q.data.movies[:i:i], q.data.movies[i+1:]...)
+	return 1, nil
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,176 @@
+package memdb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"sort"
+	"strings"
+)
+
+func (q *Queries) AddPerson(ctx context.Context, arg db.AddPersonParams) (primitive.ObjectID, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var person db.Person
+	db.Convert(arg, &person)
+	person.ID = primitive.NewObjectID()
+	q.data.people = append(q.data.people, person)
+	return person.ID, nil
+}
+
+func (t *tables) findPerson(id primitive.ObjectID) int {
+	for i, person := range t.people {
+		if person.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (q *Queries) GetPersonByID(ctx context.Context, id primitive.ObjectID) (db.Person, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	i := q.data.findPerson(id)
+	if i < 0 {
+		return db.Person{}, mongo.ErrNoDocuments
+	}
+	var person db.Person
+	db.Convert(q.data.people[i], &person)
+	return person, nil
+}
+
+// GetPeopleByIDs gets the people with the given ids, in no particular order
+func (q *Queries) GetPeopleByIDs(ctx context.Context, ids []primitive.ObjectID) ([]db.Person, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	wanted := make(map[primitive.ObjectID]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	var people []db.Person
+	for _, person := range q.data.people {
+		if wanted[person.ID] {
+			people = append(people, person)
+		}
+	}
+	var copied []db.Person
+	copyAll(people, &copied)
+	return copied, nil
+}
+
+// SearchPeople finds the people whose name or one of whose aliases
+// contains the text, ignoring case
+func (q *Queries) SearchPeople(ctx context.Context, arg db.SearchPeopleParams) ([]db.Person, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	text := strings.ToLower(arg.Text)
+	contains := func(s string) bool { return strings.Contains(strings.ToLower(s), text) }
+	var people []db.Person
+	for _, person := range q.data.people {
+		matched := contains(person.Name)
+		for _, alias := range person.Aliases {
+			matched = matched || contains(alias)
+		}
+		if matched {
+			people = append(people, person)
+		}
+	}
+	sort.SliceStable(people, func(i, j int) bool { return people[i].Name < people[j].Name })
+	from, to := page(len(people), arg.Skip, arg.Limit)
+	var copied []db.Person
+	copyAll(people[from:to], &copied)
+	return copied, nil
+}
+
+// UpdatePerson replaces the details of a person. A new name is also written
+// to the name arrays of the movies the person is credited in
+func (q *Queries) UpdatePerson(ctx context.Context, person db.Person) (*mongo.UpdateResult, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := q.data.findPerson(person.ID)
+	if i < 0 {
+		return nil, mongo.ErrNoDocuments
+	}
+	old := q.data.people[i]
+	var updated db.Person
+	db.Convert(person, &updated)
+	res := &mongo.UpdateResult{MatchedCount: 1}
+	if !sameDocument(updated, old) {
+		res.ModifiedCount = 1
+	}
+	q.data.people[i] = updated
+
+	if old.Name != person.Name {
+		for j, movie := range q.data.movies {
+			for _, credit := range movie.Credits {
+				if credit.PersonID != person.ID {
+					continue
+				}
+				switch credit.Role {
+				case db.RoleCast:
+					movie.Cast = renamed(movie.Cast, old.Name, person.Name)
+				case db.RoleDirector:
+					movie.Directors = renamed(movie.Directors, old.Name, person.Name)
+				case db.RoleWriter:
+					movie.Writers = renamed(movie.Writers, old.Name, person.Name)
+				}
+			}
+			q.data.movies[j] = movie
+		}
+	}
+	return res, nil
+}
+
+// renamed is a copy of names with from renamed to, or names when it hasn't from
+func renamed(names []string, from, to string) []string {
+	if !containsString(names, from) {
+		return names
+	}
+	copied := make([]string, len(names))
+	for i, name := range names {
+		if name == from {
+			name = to
+		}
+		copied[i] = name
+	}
+	return copied
+}
+
+// GetMoviesByPersonID gets every movie a person is credited in, the newest first
+func (q *Queries) GetMoviesByPersonID(ctx context.Context, id primitive.ObjectID) ([]db.Movies, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	var movies []db.Movies
+	for _, movie := range q.data.movies {
+		for _, credit := range movie.Credits {
+			if credit.PersonID == id {
+				movies = append(movies, db.Movies{
+					Id:            movie.Id,
+					Title:         movie.Title,
+					OriginalTitle: movie.OriginalTitle,
+					Titles:        movie.Titles,
+					Poster:        movie.Poster,
+					Year:          movie.Year,
+					Credits:       movie.Credits,
+				})
+				break
+			}
+		}
+	}
+	sort.SliceStable(movies, func(i, j int) bool {
+		if movies[i].Year != movies[j].Year {
+			return movies[i].Year > movies[j].Year
+		}
+		return idLess(movies[i].Id, movies[j].Id)
+	})
+	var copied []db.Movies
+	copyAll(movies, &copied)
+	return copied, nil
+}
@@ -0,0 +1,131 @@
+package memdb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	db "phantom/db/mongo"
+	"sort"
+	"time"
+)
+
+// GetProgressBySeriesID gets the progress of a user in the episodes of a series,
+// the most recently watched first
+func (q *Queries) GetProgressBySeriesID(ctx context.Context, name string, seriesID primitive.ObjectID) ([]db.Progress, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	var progress []db.Progress
+	for _, p := range q.data.progress {
+		if p.Name == name && p.SeriesID == seriesID {
+			progress = append(progress, p)
+		}
+	}
+	sort.SliceStable(progress, func(i, j int) bool { return progress[i].UpdatedAt > progress[j].UpdatedAt })
+	return progress, nil
+}
+
+// SaveProgress records how far a user got in a movie or an episode,
+// only when the movie or episode becomes watched is it added to the history too
+func (q *Queries) SaveProgress(ctx context.Context, arg db.SaveProgressParams) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := primitive.NewDateTimeFromTime(time.Now())
+	i := -1
+	for j, p := range q.data.progress {
+		if p.Name == arg.Name && p.MediaID == arg.MediaID {
+			i = j
+			break
+		}
+	}
+	if i < 0 {
+		q.data.progress = append(q.data.progress, db.Progress{
+			ID:      primitive.NewObjectID(),
+			Name:    arg.Name,
+			MediaID: arg.MediaID,
+		})
+		i = len(q.data.progress) - 1
+	}
+	saved := &q.data.progress[i]
+	wasWatched := saved.Watched
+	saved.Position = arg.Position
+	saved.Duration = arg.Duration
+	saved.Watched = arg.Watched
+	saved.UpdatedAt = now
+	if !arg.SeriesID.IsZero() {
+		saved.SeriesID = arg.SeriesID
+	}
+
+	if !arg.Watched || wasWatched {
+		return nil
+	}
+	q.data.history = append(q.data.history, db.HistoryEntry{
+		ID:        primitive.NewObjectID(),
+		Name:      arg.Name,
+		MediaID:   arg.MediaID,
+		SeriesID:  arg.SeriesID,
+		Duration:  arg.Duration,
+		WatchedAt: now,
+	})
+	return nil
+}
+
+// GetContinueWatching gets a page of the movies and episodes a user started
+// and hasn't watched yet, the most recently watched first. A series only
+// comes once, with the episode the user was in last
+func (q *Queries) GetContinueWatching(ctx context.Context, arg db.GetContinueWatchingParams) ([]db.Progress, error) {
+	if err := checkAggregatePage(arg.Skip, arg.Limit); err != nil {
+		return nil, err
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	latest := make(map[primitive.ObjectID]int)
+	var progress []db.Progress
+	for _, p := range q.data.progress {
+		if p.Name != arg.Name || p.Watched || p.Position <= 0 {
+			continue
+		}
+		key := p.SeriesID
+		if key.IsZero() {
+			key = p.MediaID
+		}
+		i, ok := latest[key]
+		if !ok {
+			latest[key] = len(progress)
+			progress = append(progress, p)
+		} else if p.UpdatedAt > progress[i].UpdatedAt {
+			progress[i] = p
+		}
+	}
+	sort.SliceStable(progress, func(i, j int) bool {
+		if progress[i].UpdatedAt != progress[j].UpdatedAt {
+			return progress[i].UpdatedAt > progress[j].UpdatedAt
+		}
+		return idLess(progress[j].ID, progress[i].ID)
+	})
+	from, to := page(len(progress), arg.Skip, arg.Limit)
+	if from == to {
+		return nil, nil
+	}
+	return progress[from:to], nil
+}
+
+// GetProgressByMediaIDs gets the progress of a user in the given movies or episodes,
+// in no particular order. The ones the user hasn't started are left out
+func (q *Queries) GetProgressByMediaIDs(ctx context.Context, name string, ids []primitive.ObjectID) ([]db.Progress, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	wanted := make(map[primitive.ObjectID]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	var progress []db.Progress
+	for _, p := range q.data.progress {
+		if p.Name == name && wanted[p.MediaID] {
+			progress = append(progress, p)
+		}
+	}
+	return progress, nil
+}
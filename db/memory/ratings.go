@@ -0,0 +1,87 @@
+package memdb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"time"
+)
+
+func (t *tables) findRating(name string, movieID primitive.ObjectID) int {
+	for i, rating := range t.ratings {
+		if rating.Name == name && rating.MovieID == movieID {
+			return i
+		}
+	}
+	return -1
+}
+
+// RateMovie sets the score of a user for a movie, replacing their previous score,
+// and moves the community rating of the movie by the difference
+func (q *Queries) RateMovie(ctx context.Context, arg db.RateMovieParams) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := primitive.NewDateTimeFromTime(time.Now())
+	i := q.data.findRating(arg.Name, arg.MovieID)
+	if i < 0 {
+		q.data.ratings = append(q.data.ratings, db.Rating{
+			ID:        primitive.NewObjectID(),
+			Name:      arg.Name,
+			MovieID:   arg.MovieID,
+			Score:     arg.Score,
+			UpdatedAt: now,
+		})
+		q.data.addToCommunityRating(arg.MovieID, arg.Score, 1)
+		return nil
+	}
+	old := q.data.ratings[i].Score
+	q.data.ratings[i].Score = arg.Score
+	q.data.ratings[i].UpdatedAt = now
+	q.data.addToCommunityRating(arg.MovieID, arg.Score-old, 0)
+	return nil
+}
+
+// DeleteRating removes the score of a user for a movie from the community rating,
+// it returns mongo.ErrNoDocuments when the user hasn't rated the movie
+func (q *Queries) DeleteRating(ctx context.Context, name string, movieID primitive.ObjectID) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := q.data.findRating(name, movieID)
+	if i < 0 {
+		return mongo.ErrNoDocuments
+	}
+	old := q.data.ratings[i]
+	q.data.ratings = append(q.data.ratings[:i:i], q.data.ratings[i+1:]...)
+	q.data.addToCommunityRating(movieID, -old.Score, -1)
+	return nil
+}
+
+// addToCommunityRating adds to the sum and count of the community rating of a movie
+// and works out the average again
+func (t *tables) addToCommunityRating(movieID primitive.ObjectID, sum, count int64) {
+	i := t.findMovie(movieID)
+	if i < 0 {
+		return
+	}
+	community := &t.movies[i].Community
+	community.Sum += sum
+	community.Count += count
+	community.Rating = 0
+	if community.Count > 0 {
+		community.Rating = float64(community.Sum) / float64(community.Count)
+	}
+}
+
+func (q *Queries) GetRating(ctx context.Context, name string, movieID primitive.ObjectID) (db.Rating, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	i := q.data.findRating(name, movieID)
+	if i < 0 {
+		return db.Rating{}, mongo.ErrNoDocuments
+	}
+	return q.data.ratings[i], nil
+}
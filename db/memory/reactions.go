@@ -0,0 +1,90 @@
+package memdb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"time"
+)
+
+// AddReaction adds a reaction of a user to a comment and counts it on the comment.
+// It returns false when the user had already left that reaction
+func (q *Queries) AddReaction(ctx context.Context, arg db.CommentReactionParams) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.data.findReaction(arg) >= 0 {
+		return false, nil
+	}
+	q.data.reactions = append(q.data.reactions, db.CommentReaction{
+		ID:        primitive.NewObjectID(),
+		CommentID: arg.CommentID,
+		Name:      arg.Name,
+		Reaction:  arg.Reaction,
+		CreatedAt: primitive.NewDateTimeFromTime(time.Now()),
+	})
+	q.data.countReaction(arg.CommentID, arg.Reaction, 1)
+	return true, nil
+}
+
+// DeleteReaction takes back a reaction of a user to a comment,
+// it returns mongo.ErrNoDocuments when the user hasn't left that reaction
+func (q *Queries) DeleteReaction(ctx context.Context, arg db.CommentReactionParams) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := q.data.findReaction(arg)
+	if i < 0 {
+		return mongo.ErrNoDocuments
+	}
+	q.data.reactions = append(q.data.reactions[:i:i], q.data.reactions[i+1:]...)
+	q.data.countReaction(arg.CommentID, arg.Reaction, -1)
+	return nil
+}
+
+func (t *tables) findReaction(arg db.CommentReactionParams) int {
+	for i, reaction := range t.reactions {
+		if reaction.CommentID == arg.CommentID && reaction.Name == arg.Name && reaction.Reaction == arg.Reaction {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t *tables) countReaction(commentID primitive.ObjectID, reaction string, n int64) {
+	i := t.findComment(commentID)
+	if i < 0 {
+		return
+	}
+	t.comments[i].Reactions = incCount(t.comments[i].Reactions, reaction, n)
+	t.comments[i].ReactionCount += n
+}
+
+// incCount adds n to the count of key, in a copy of counts
+func incCount(counts map[string]int64, key string, n int64) map[string]int64 {
+	inc := make(map[string]int64, len(counts)+1)
+	for k, v := range counts {
+		inc[k] = v
+	}
+	inc[key] += n
+	return inc
+}
+
+// GetReactionsByName gets the reactions a user left on the comments, in no particular order
+func (q *Queries) GetReactionsByName(ctx context.Context, name string, commentIDs []primitive.ObjectID) ([]db.CommentReaction, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	ids := make(map[primitive.ObjectID]bool, len(commentIDs))
+	for _, id := range commentIDs {
+		ids[id] = true
+	}
+	var reactions []db.CommentReaction
+	for _, reaction := range q.data.reactions {
+		if reaction.Name == name && ids[reaction.CommentID] {
+			reactions = append(reactions, reaction)
+		}
+	}
+	return reactions, nil
+}
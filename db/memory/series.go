@@ -0,0 +1,118 @@
+package memdb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"sort"
+)
+
+// AddSeason adds a season, a series has one season of each number
+func (q *Queries) AddSeason(ctx context.Context, arg db.AddSeasonParams) (primitive.ObjectID, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, season := range q.data.seasons {
+		if season.SeriesID == arg.SeriesID && season.Number == arg.Number {
+			return primitive.ObjectID{}, errDuplicateKey("seasons", "series_id_1_number_1")
+		}
+	}
+	var season db.Season
+	db.Convert(arg, &season)
+	season.ID = primitive.NewObjectID()
+	q.data.seasons = append(q.data.seasons, season)
+	return season.ID, nil
+}
+
+// GetSeasonsBySeriesID gets the seasons of a series by their number,
+// the specials come first
+func (q *Queries) GetSeasonsBySeriesID(ctx context.Context, seriesID primitive.ObjectID) ([]db.Season, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	var seasons []db.Season
+	for _, season := range q.data.seasons {
+		if season.SeriesID == seriesID {
+			seasons = append(seasons, season)
+		}
+	}
+	sort.SliceStable(seasons, func(i, j int) bool { return seasons[i].Number < seasons[j].Number })
+	return seasons, nil
+}
+
+// AddEpisode adds an episode, a season has one episode of each number
+func (q *Queries) AddEpisode(ctx context.Context, arg db.AddEpisodeParams) (primitive.ObjectID, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, episode := range q.data.episodes {
+		if episode.SeriesID == arg.SeriesID && episode.Season == arg.Season && episode.Number == arg.Number {
+			return primitive.ObjectID{}, errDuplicateKey("episodes", "series_id_1_season_1_number_1")
+		}
+	}
+	var episode db.Episode
+	db.Convert(arg, &episode)
+	episode.ID = primitive.NewObjectID()
+	q.data.episodes = append(q.data.episodes, episode)
+	return episode.ID, nil
+}
+
+func (q *Queries) GetEpisodeByID(ctx context.Context, id primitive.ObjectID) (db.Episode, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	for _, episode := range q.data.episodes {
+		if episode.ID == id {
+			return episode, nil
+		}
+	}
+	return db.Episode{}, mongo.ErrNoDocuments
+}
+
+// GetEpisodes lists episodes in the order they aired, season by season,
+// or by their absolute number, which leaves out the episodes without one
+func (q *Queries) GetEpisodes(ctx context.Context, arg db.GetEpisodesParams) ([]db.Episode, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	absolute := arg.Order == db.EpisodeOrderAbsolute
+	var episodes []db.Episode
+	for _, episode := range q.data.episodes {
+		if episode.SeriesID != arg.SeriesID ||
+			(arg.Season != nil && episode.Season != *arg.Season) ||
+			(absolute && episode.AbsoluteNumber <= 0) {
+			continue
+		}
+		episodes = append(episodes, episode)
+	}
+	sort.SliceStable(episodes, func(i, j int) bool {
+		a, b := episodes[i], episodes[j]
+		if absolute {
+			return a.AbsoluteNumber < b.AbsoluteNumber
+		}
+		if a.Season != b.Season {
+			return a.Season < b.Season
+		}
+		return a.Number < b.Number
+	})
+	return episodes, nil
+}
+
+// GetEpisodesByIDs gets the episodes with the given ids, in no particular order
+func (q *Queries) GetEpisodesByIDs(ctx context.Context, ids []primitive.ObjectID) ([]db.Episode, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	wanted := make(map[primitive.ObjectID]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	var episodes []db.Episode
+	for _, episode := range q.data.episodes {
+		if wanted[episode.ID] {
+			episodes = append(episodes, episode)
+		}
+	}
+	return episodes, nil
+}
@@ -0,0 +1,200 @@
+// Package memdb keeps the data of the server in memory, for demos and tests
+// that have no MongoDB to run against. Its Store behaves like db.MongoStore,
+// down to the errors it returns, which package storetest checks for both.
+// Text search matches whole words, without the stemming MongoDB does,
+// and nothing is kept once the process exits
+package memdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"phantom/ledger"
+	"reflect"
+	"sync"
+)
+
+// Store is a db.Store in memory, safe for concurrent use
+type Store struct {
+	*Queries
+	mu sync.RWMutex
+}
+
+var _ db.Store = (*Store)(nil)
+
+func NewStore() *Store {
+	store := &Store{}
+	store.Queries = &Queries{mu: &store.mu, data: &tables{}}
+	return store
+}
+
+// ExecTx runs fn in a transaction: the queries fn makes with q are kept
+// when fn returns nil, and undone when it returns an error. The store
+// is locked until fn returns, so fn can only use q and not the store
+func (store *Store) ExecTx(ctx context.Context, fn func(q db.Querier) error) error {
+	return store.execTx(func(data *tables) error {
+		return fn(&Queries{mu: noLock{}, data: data})
+	})
+}
+
+// execTx runs fn on the data with the store locked,
+// and puts the data back the way it was when fn fails
+func (store *Store) execTx(fn func(data *tables) error) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	snapshot := store.data.clone()
+	if err := fn(store.data); err != nil {
+		*store.data = *snapshot
+		return err
+	}
+	return nil
+}
+
+// Queries answers the queries of db.Querier from the tables, holding mu
+// while it does. Every query reads or writes the tables under one lock,
+// so a query that takes several writes in MongoDB is atomic here
+type Queries struct {
+	mu   locker
+	data *tables
+}
+
+var _ db.Querier = (*Queries)(nil)
+
+type locker interface {
+	Lock()
+	Unlock()
+	RLock()
+	RUnlock()
+}
+
+// noLock is the lock of the queries of a transaction, which hold the lock of the store
+type noLock struct{}
+
+func (noLock) Lock()    {}
+func (noLock) Unlock()  {}
+func (noLock) RLock()   {}
+func (noLock) RUnlock() {}
+
+// tables are the collections of MongoDB, each in the order it was written,
+// which is the natural order MongoDB lists a collection in.
+// A document is only ever changed by setting its fields or replacing it,
+// never by changing the slices it holds, so that clone can share them
+type tables struct {
+	users         []db.User
+	movies        []db.Movies
+	people        []db.Person
+	seasons       []db.Season
+	episodes      []db.Episode
+	progress      []db.Progress
+	history       []db.HistoryEntry
+	collections   []db.Collection
+	lists         []db.ListEntry
+	ratings       []db.Rating
+	comments      []db.Comments
+	reactions     []db.CommentReaction
+	reports       []db.CommentReport
+	moderationLog []db.ModerationLogEntry
+	danmaku       []db.Danmaku
+
+	ledger            []ledger.Entry
+	ledgerCheckpoints []ledger.Checkpoint
+
+	coinTransactions []db.CoinTransaction
+	coinWallets      []db.CoinWallet
+	titleRequests    []db.TitleRequest
+}
+
+func (t *tables) clone() *tables {
+	return &tables{
+		users:         append([]db.User(nil), t.users...),
+		movies:        append([]db.Movies(nil), t.movies...),
+		people:        append([]db.Person(nil), t.people...),
+		seasons:       append([]db.Season(nil), t.seasons...),
+		episodes:      append([]db.Episode(nil), t.episodes...),
+		progress:      append([]db.Progress(nil), t.progress...),
+		history:       append([]db.HistoryEntry(nil), t.history...),
+		collections:   append([]db.Collection(nil), t.collections...),
+		lists:         append([]db.ListEntry(nil), t.lists...),
+		ratings:       append([]db.Rating(nil), t.ratings...),
+		comments:      append([]db.Comments(nil), t.comments...),
+		reactions:     append([]db.CommentReaction(nil), t.reactions...),
+		reports:       append([]db.CommentReport(nil), t.reports...),
+		moderationLog: append([]db.ModerationLogEntry(nil), t.moderationLog...),
+		danmaku:       append([]db.Danmaku(nil), t.danmaku...),
+
+		ledger:            append([]ledger.Entry(nil), t.ledger...),
+		ledgerCheckpoints: append([]ledger.Checkpoint(nil), t.ledgerCheckpoints...),
+
+		coinTransactions: append([]db.CoinTransaction(nil), t.coinTransactions...),
+		coinWallets:      append([]db.CoinWallet(nil), t.coinWallets...),
+		titleRequests:    append([]db.TitleRequest(nil), t.titleRequests...),
+	}
+}
+
+// copyAll copies the documents of the slice src out of the tables into dst,
+// which is left nil when there are none, like a cursor leaves it
+func copyAll(src, dst interface{}) {
+	if reflect.ValueOf(src).Len() > 0 {
+		db.Convert(src, dst)
+	}
+}
+
+// idLess orders ids the way MongoDB sorts them, which is by the time they were made
+func idLess(a, b primitive.ObjectID) bool {
+	return bytes.Compare(a[:], b[:]) < 0
+}
+
+// The codes MongoDB gives the writes it refuses
+const (
+	codeDuplicateKey     = 11000
+	codeValidationFailed = 121
+)
+
+// errDuplicateKey is the error of a write that breaks the unique index
+// with the name, like the one of MongoDB
+func errDuplicateKey(collection, index string) error {
+	return mongo.WriteException{WriteErrors: mongo.WriteErrors{{
+		Code:    codeDuplicateKey,
+		Message: fmt.Sprintf("E11000 duplicate key error collection: phantom.%s index: %s dup key", collection, index),
+	}}}
+}
+
+// errValidation is the error of a write that the validator of the collection refuses
+func errValidation() error {
+	return mongo.WriteException{WriteErrors: mongo.WriteErrors{{
+		Code:    codeValidationFailed,
+		Message: "Document failed validation",
+	}}}
+}
+
+// page is the part of n results from skip that limit lets through,
+// a limit of zero lets them all through like a find does
+func page(n int, skip, limit int64) (int, int) {
+	from := int(skip)
+	if from > n || from < 0 {
+		from = n
+	}
+	to := n
+	if limit > 0 && from+int(limit) < n {
+		to = from + int(limit)
+	}
+	return from, to
+}
+
+// errAggregateLimit is the error of an aggregation whose $limit isn't positive
+var errAggregateLimit = mongo.CommandError{Code: 15958, Message: "the limit must be positive"}
+
+// checkAggregatePage refuses the pages an aggregation refuses
+func checkAggregatePage(skip, limit int64) error {
+	if limit <= 0 {
+		return errAggregateLimit
+	}
+	if skip < 0 {
+		return mongo.CommandError{Code: 15956, Message: "invalid argument to $skip stage: Cannot skip a negative number of documents"}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package memdb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	db "phantom/db/mongo"
+	"phantom/db/storetest"
+	"phantom/util"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, NewStore())
+}
+
+func TestConcurrentUse(t *testing.T) {
+	store := NewStore()
+	ctx := context.Background()
+	movieID, err := store.AddMovie(ctx, db.AddMovieParams{Title: util.RandomString(12), Runtime: 10})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				err := store.ExecTx(ctx, func(q db.Querier) error {
+					_, err := q.AddComment(ctx, db.AddCommentParams{Name: fmt.Sprint("user", i), MovieID: movieID, Text: util.RandomString(20)})
+					if err != nil {
+						return err
+					}
+					return q.IncrementMovieComments(ctx, movieID, 1)
+				})
+				require.NoError(t, err)
+				_, err = store.GetCommentsByMovieID(ctx, db.GetCommentsParams{MovieID: movieID, Limit: 5})
+				require.NoError(t, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	movie, err := store.GetMovieByID(ctx, movieID)
+	require.NoError(t, err)
+	require.Equal(t, int64(200), movie.NumMflixComments)
+	comments, err := store.GetCommentsByMovieID(ctx, db.GetCommentsParams{MovieID: movieID})
+	require.NoError(t, err)
+	require.Len(t, comments, 200)
+}
@@ -0,0 +1,176 @@
+package memdb
+
+import (
+	db "phantom/db/mongo"
+	"phantom/search"
+	"strings"
+)
+
+// textQuery is a $search string of a $text query: a document matches when
+// it has one of the terms and all of the phrases, and none of the negated ones
+type textQuery struct {
+	terms          []string
+	phrases        []string
+	negatedTerms   []string
+	negatedPhrases []string
+}
+
+func parseTextQuery(s string) textQuery {
+	var query textQuery
+	for {
+		start := strings.IndexByte(s, '"')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(s[start+1:], '"')
+		if end < 0 {
+			break
+		}
+		phrase := search.Normalize(s[start+1 : start+1+end])
+		if start > 0 && s[start-1] == '-' {
+			query.negatedPhrases = append(query.negatedPhrases, phrase)
+			s = s[:start-1] + " " + s[start+2+end:]
+			continue
+		}
+		query.phrases = append(query.phrases, phrase)
+		// The words of a phrase are looked up like terms
+		query.terms = append(query.terms, textTerms(phrase)...)
+		s = s[:start] + " " + s[start+2+end:]
+	}
+	for _, field := range strings.Fields(s) {
+		if strings.HasPrefix(field, "-") {
+			query.negatedTerms = append(query.negatedTerms, textTerms(field[1:])...)
+			continue
+		}
+		query.terms = append(query.terms, textTerms(field)...)
+	}
+	return query
+}
+
+// textTerms are the words of s that a text index keeps
+func textTerms(s string) []string {
+	var terms []string
+	for _, word := range search.Words(s) {
+		if !stopWords[word] {
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// textScore scores the document with the strings by the query like MongoDB
+// does, each string weighing 1. It returns false when the document doesn't match
+func (query textQuery) textScore(strs []string) (float64, bool) {
+	normalized := make([]string, len(strs))
+	for i, s := range strs {
+		normalized[i] = search.Normalize(s)
+	}
+	for _, phrase := range query.phrases {
+		if !containsPhrase(normalized, phrase) {
+			return 0, false
+		}
+	}
+	for _, phrase := range query.negatedPhrases {
+		if containsPhrase(normalized, phrase) {
+			return 0, false
+		}
+	}
+
+	termScores := make(map[string]float64)
+	for _, s := range strs {
+		scoreString(termScores, s)
+	}
+	for _, term := range query.negatedTerms {
+		if _, ok := termScores[term]; ok {
+			return 0, false
+		}
+	}
+	var score float64
+	matched := false
+	for _, term := range uniqueStrings(query.terms) {
+		if termScore, ok := termScores[term]; ok {
+			score += termScore
+			matched = true
+		}
+	}
+	return score, matched
+}
+
+// scoreString adds the score of each term of s, the way a text index does:
+// a term counts less each time it comes up again, and more the more of s it is
+func scoreString(termScores map[string]float64, s string) {
+	type termData struct {
+		exp   float64
+		count float64
+		freq  float64
+	}
+	terms := make(map[string]*termData)
+	var numTokens float64
+	for _, term := range textTerms(s) {
+		data, ok := terms[term]
+		if !ok {
+			data = &termData{}
+			terms[term] = data
+		}
+		if data.exp > 0 {
+			data.exp *= 2
+		} else {
+			data.exp = 1
+		}
+		data.count++
+		data.freq += 1 / data.exp
+		numTokens++
+	}
+	for term, data := range terms {
+		coeff := 0.5*data.count/numTokens + 0.5
+		termScores[term] += data.freq * coeff
+	}
+}
+
+func containsPhrase(strs []string, phrase string) bool {
+	for _, s := range strs {
+		if strings.Contains(s, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var unique []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+// movieText is what the text index of 'movies' has of a movie
+func movieText(movie db.Movies) []string {
+	strs := append([]string{movie.Fullplot, movie.Title, movie.OriginalTitle}, movie.Cast...)
+	strs = append(strs, movie.Genres...)
+	for _, title := range movie.Titles {
+		strs = append(strs, title.Title)
+	}
+	return strs
+}
+
+// stopWords are the English words a text index leaves out
+var stopWords = func() map[string]bool {
+	words := strings.Fields(`a about above after again against all am an and any are as at
+		be because been before being below between both but by can did do does doing down during
+		each few for from further had has have having he her here hers herself him himself his how
+		i if in into is it its itself just me more most my myself no nor not now of off on once only
+		or other our ours ourselves out over own same she should so some such than that the their
+		theirs them themselves then there these they this those through to too under until up very
+		was we were what when where which while who whom why will with would you your yours yourself
+		yourselves`)
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		set[word] = true
+	}
+	return set
+}()
@@ -0,0 +1,70 @@
+package memdb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"sort"
+	"time"
+)
+
+// RequestTitleTx adds a title request and takes the coins for it together.
+// Requesting again with the same key gives back the first request
+func (store *Store) RequestTitleTx(ctx context.Context, arg db.RequestTitleTxParams) (db.TitleRequest, error) {
+	var request db.TitleRequest
+	err := store.execTx(func(data *tables) error {
+		transfer, err := data.transferCoins(db.TransferCoinsParams{
+			Key:    db.CoinRequestTitle + ":" + arg.Key,
+			Kind:   db.CoinRequestTitle,
+			From:   db.UserCoinAccount(arg.Name),
+			To:     db.CoinAccountSpent,
+			Amount: arg.Cost,
+			Memo:   arg.Title,
+		})
+		if err != nil {
+			return err
+		}
+		if transfer.Replayed {
+			for _, existing := range data.titleRequests {
+				if existing.TransactionID == transfer.Transaction.ID {
+					request = existing
+					return nil
+				}
+			}
+			return mongo.ErrNoDocuments
+		}
+
+		request = db.TitleRequest{
+			ID:            primitive.NewObjectID(),
+			Name:          arg.Name,
+			Title:         arg.Title,
+			Year:          arg.Year,
+			Note:          arg.Note,
+			TransactionID: transfer.Transaction.ID,
+			CreatedAt:     primitive.NewDateTimeFromTime(time.Now()),
+		}
+		data.titleRequests = append(data.titleRequests, request)
+		return nil
+	})
+	return request, err
+}
+
+// GetTitleRequests gets a page of the title requests, the latest first
+func (q *Queries) GetTitleRequests(ctx context.Context, arg db.GetTitleRequestsParams) ([]db.TitleRequest, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	requests := append([]db.TitleRequest(nil), q.data.titleRequests...)
+	sort.SliceStable(requests, func(i, j int) bool {
+		if requests[i].CreatedAt != requests[j].CreatedAt {
+			return requests[i].CreatedAt > requests[j].CreatedAt
+		}
+		return idLess(requests[j].ID, requests[i].ID)
+	})
+	from, to := page(len(requests), arg.Skip, arg.Limit)
+	if from == to {
+		return nil, nil
+	}
+	return requests[from:to], nil
+}
@@ -0,0 +1,144 @@
+package memdb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+)
+
+// AddUser needs an email and a password, and a name and an email
+// no other user has, like the validator and the unique indexes of 'users'
+func (q *Queries) AddUser(ctx context.Context, arg db.AddUserParams) (primitive.ObjectID, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if arg.Email == "" || arg.Password == "" {
+		return primitive.ObjectID{}, errValidation()
+	}
+	for _, user := range q.data.users {
+		if user.Name == arg.Name {
+			return primitive.ObjectID{}, errDuplicateKey("users", "name_1")
+		}
+		if user.Email == arg.Email {
+			return primitive.ObjectID{}, errDuplicateKey("users", "email_1")
+		}
+	}
+	var user db.User
+	db.Convert(arg, &user)
+	user.ID = primitive.NewObjectID()
+	q.data.users = append(q.data.users, user)
+	return user.ID, nil
+}
+
+func (q *Queries) GetUserByID(ctx context.Context, id primitive.ObjectID) (db.User, error) {
+	return q.getUser(func(user db.User) bool { return user.ID == id })
+}
+
+func (q *Queries) GetUserByName(ctx context.Context, name string) (db.User, error) {
+	return q.getUser(func(user db.User) bool { return user.Name == name })
+}
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (db.User, error) {
+	return q.getUser(func(user db.User) bool { return user.Email == email })
+}
+
+func (q *Queries) getUser(match func(user db.User) bool) (db.User, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	i := q.data.findUser(match)
+	if i < 0 {
+		return db.User{}, mongo.ErrNoDocuments
+	}
+	var user db.User
+	db.Convert(q.data.users[i], &user)
+	return user, nil
+}
+
+func (t *tables) findUser(match func(user db.User) bool) int {
+	for i, user := range t.users {
+		if match(user) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (q *Queries) UpdateUserName(ctx context.Context, user db.User) (*mongo.UpdateResult, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, other := range q.data.users {
+		if other.ID != user.ID && other.Name == user.Name {
+			return nil, errDuplicateKey("users", "name_1")
+		}
+	}
+	return q.data.updateUser(user.ID, func(u *db.User) bool {
+		changed := u.Name != user.Name
+		u.Name = user.Name
+		return changed
+	}), nil
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, user db.User) (*mongo.UpdateResult, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.data.updateUser(user.ID, func(u *db.User) bool {
+		changed := u.Password != user.Password
+		u.Password = user.Password
+		return changed
+	}), nil
+}
+
+// UpdateUserLocale sets the locale the user prefers titles in
+func (q *Queries) UpdateUserLocale(ctx context.Context, user db.User) (*mongo.UpdateResult, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.data.updateUser(user.ID, func(u *db.User) bool {
+		changed := u.Locale != user.Locale
+		u.Locale = user.Locale
+		return changed
+	}), nil
+}
+
+// UpdateUserRole sets the role of the user, such as db.UserRoleAdmin
+func (q *Queries) UpdateUserRole(ctx context.Context, user db.User) (*mongo.UpdateResult, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.data.updateUser(user.ID, func(u *db.User) bool {
+		changed := u.Role != user.Role
+		u.Role = user.Role
+		return changed
+	}), nil
+}
+
+// updateUser sets fields of the user with the id, set tells whether they changed
+func (t *tables) updateUser(id primitive.ObjectID, set func(user *db.User) bool) *mongo.UpdateResult {
+	i := t.findUser(func(user db.User) bool { return user.ID == id })
+	if i < 0 {
+		return &mongo.UpdateResult{}
+	}
+	res := &mongo.UpdateResult{MatchedCount: 1}
+	if set(&t.users[i]) {
+		res.ModifiedCount = 1
+	}
+	return res
+}
+
+// BanUser stops a user from commenting,
+// it returns mongo.ErrNoDocuments when there is no such user
+func (q *Queries) BanUser(ctx context.Context, name string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := q.data.findUser(func(user db.User) bool { return user.Name == name })
+	if i < 0 {
+		return mongo.ErrNoDocuments
+	}
+	q.data.users[i].Banned = true
+	return nil
+}
@@ -7,6 +7,7 @@ package mockdb
 import (
 	context "context"
 	mongo0 "phantom/db/mongo"
+	ledger "phantom/ledger"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -37,154 +38,926 @@ func (m *MockStore) EXPECT() *MockStoreMockRecorder {
 	return m.recorder
 }
 
+// AddCollection mocks base method.
+func (m *MockStore) AddCollection(arg0 context.Context, arg1 mongo0.AddCollectionParams) (primitive.ObjectID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddCollection", arg0, arg1)
+	ret0, _ := ret[0].(primitive.ObjectID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddCollection indicates an expected call of AddCollection.
+func (mr *MockStoreMockRecorder) AddCollection(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddCollection", reflect.TypeOf((*MockStore)(nil).AddCollection), arg0, arg1)
+}
+
 // AddComment mocks base method.
 func (m *MockStore) AddComment(arg0 context.Context, arg1 mongo0.AddCommentParams) (primitive.ObjectID, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddComment", arg0, arg1)
-	ret0, _ := ret[0].(primitive.ObjectID)
+	ret := m.ctrl.Call(m, "AddComment", arg0, arg1)
+	ret0, _ := ret[0].(primitive.ObjectID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddComment indicates an expected call of AddComment.
+func (mr *MockStoreMockRecorder) AddComment(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddComment", reflect.TypeOf((*MockStore)(nil).AddComment), arg0, arg1)
+}
+
+// AddDanmaku mocks base method.
+func (m *MockStore) AddDanmaku(arg0 context.Context, arg1 mongo0.AddDanmakuParams) (mongo0.Danmaku, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddDanmaku", arg0, arg1)
+	ret0, _ := ret[0].(mongo0.Danmaku)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddDanmaku indicates an expected call of AddDanmaku.
+func (mr *MockStoreMockRecorder) AddDanmaku(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddDanmaku", reflect.TypeOf((*MockStore)(nil).AddDanmaku), arg0, arg1)
+}
+
+// AddEpisode mocks base method.
+func (m *MockStore) AddEpisode(arg0 context.Context, arg1 mongo0.AddEpisodeParams) (primitive.ObjectID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddEpisode", arg0, arg1)
+	ret0, _ := ret[0].(primitive.ObjectID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddEpisode indicates an expected call of AddEpisode.
+func (mr *MockStoreMockRecorder) AddEpisode(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddEpisode", reflect.TypeOf((*MockStore)(nil).AddEpisode), arg0, arg1)
+}
+
+// AddListEntry mocks base method.
+func (m *MockStore) AddListEntry(arg0 context.Context, arg1 mongo0.ListEntryParams) (*mongo.UpdateResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddListEntry", arg0, arg1)
+	ret0, _ := ret[0].(*mongo.UpdateResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddListEntry indicates an expected call of AddListEntry.
+func (mr *MockStoreMockRecorder) AddListEntry(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddListEntry", reflect.TypeOf((*MockStore)(nil).AddListEntry), arg0, arg1)
+}
+
+// AddModerationLog mocks base method.
+func (m *MockStore) AddModerationLog(arg0 context.Context, arg1 mongo0.AddModerationLogParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddModerationLog", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddModerationLog indicates an expected call of AddModerationLog.
+func (mr *MockStoreMockRecorder) AddModerationLog(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddModerationLog", reflect.TypeOf((*MockStore)(nil).AddModerationLog), arg0, arg1)
+}
+
+// AddMovie mocks base method.
+func (m *MockStore) AddMovie(arg0 context.Context, arg1 mongo0.AddMovieParams) (primitive.ObjectID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddMovie", arg0, arg1)
+	ret0, _ := ret[0].(primitive.ObjectID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddMovie indicates an expected call of AddMovie.
+func (mr *MockStoreMockRecorder) AddMovie(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddMovie", reflect.TypeOf((*MockStore)(nil).AddMovie), arg0, arg1)
+}
+
+// AddMovies mocks base method.
+func (m *MockStore) AddMovies(arg0 context.Context, arg1 []mongo0.AddMovieParams) ([]interface{}, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddMovies", arg0, arg1)
+	ret0, _ := ret[0].([]interface{})
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddMovies indicates an expected call of AddMovies.
+func (mr *MockStoreMockRecorder) AddMovies(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddMovies", reflect.TypeOf((*MockStore)(nil).AddMovies), arg0, arg1)
+}
+
+// AddPerson mocks base method.
+func (m *MockStore) AddPerson(arg0 context.Context, arg1 mongo0.AddPersonParams) (primitive.ObjectID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddPerson", arg0, arg1)
+	ret0, _ := ret[0].(primitive.ObjectID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddPerson indicates an expected call of AddPerson.
+func (mr *MockStoreMockRecorder) AddPerson(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddPerson", reflect.TypeOf((*MockStore)(nil).AddPerson), arg0, arg1)
+}
+
+// AddReaction mocks base method.
+func (m *MockStore) AddReaction(arg0 context.Context, arg1 mongo0.CommentReactionParams) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddReaction", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddReaction indicates an expected call of AddReaction.
+func (mr *MockStoreMockRecorder) AddReaction(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddReaction", reflect.TypeOf((*MockStore)(nil).AddReaction), arg0, arg1)
+}
+
+// AddSeason mocks base method.
+func (m *MockStore) AddSeason(arg0 context.Context, arg1 mongo0.AddSeasonParams) (primitive.ObjectID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddSeason", arg0, arg1)
+	ret0, _ := ret[0].(primitive.ObjectID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddSeason indicates an expected call of AddSeason.
+func (mr *MockStoreMockRecorder) AddSeason(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddSeason", reflect.TypeOf((*MockStore)(nil).AddSeason), arg0, arg1)
+}
+
+// AddUser mocks base method.
+func (m *MockStore) AddUser(arg0 context.Context, arg1 mongo0.AddUserParams) (primitive.ObjectID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddUser", arg0, arg1)
+	ret0, _ := ret[0].(primitive.ObjectID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddUser indicates an expected call of AddUser.
+func (mr *MockStoreMockRecorder) AddUser(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUser", reflect.TypeOf((*MockStore)(nil).AddUser), arg0, arg1)
+}
+
+// ApproveComment mocks base method.
+func (m *MockStore) ApproveComment(arg0 context.Context, arg1 primitive.ObjectID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ApproveComment", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ApproveComment indicates an expected call of ApproveComment.
+func (mr *MockStoreMockRecorder) ApproveComment(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApproveComment", reflect.TypeOf((*MockStore)(nil).ApproveComment), arg0, arg1)
+}
+
+// ApproveDanmaku mocks base method.
+func (m *MockStore) ApproveDanmaku(arg0 context.Context, arg1 primitive.ObjectID) (mongo0.Danmaku, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ApproveDanmaku", arg0, arg1)
+	ret0, _ := ret[0].(mongo0.Danmaku)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ApproveDanmaku indicates an expected call of ApproveDanmaku.
+func (mr *MockStoreMockRecorder) ApproveDanmaku(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApproveDanmaku", reflect.TypeOf((*MockStore)(nil).ApproveDanmaku), arg0, arg1)
+}
+
+// BanUser mocks base method.
+func (m *MockStore) BanUser(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BanUser", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BanUser indicates an expected call of BanUser.
+func (mr *MockStoreMockRecorder) BanUser(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BanUser", reflect.TypeOf((*MockStore)(nil).BanUser), arg0, arg1)
+}
+
+// CheckpointLedger mocks base method.
+func (m *MockStore) CheckpointLedger(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckpointLedger", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckpointLedger indicates an expected call of CheckpointLedger.
+func (mr *MockStoreMockRecorder) CheckpointLedger(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckpointLedger", reflect.TypeOf((*MockStore)(nil).CheckpointLedger), arg0)
+}
+
+// DeleteCollection mocks base method.
+func (m *MockStore) DeleteCollection(arg0 context.Context, arg1 primitive.ObjectID) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteCollection", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteCollection indicates an expected call of DeleteCollection.
+func (mr *MockStoreMockRecorder) DeleteCollection(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteCollection", reflect.TypeOf((*MockStore)(nil).DeleteCollection), arg0, arg1)
+}
+
+// DeleteComment mocks base method.
+func (m *MockStore) DeleteComment(arg0 context.Context, arg1 primitive.ObjectID, arg2 string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteComment", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteComment indicates an expected call of DeleteComment.
+func (mr *MockStoreMockRecorder) DeleteComment(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteComment", reflect.TypeOf((*MockStore)(nil).DeleteComment), arg0, arg1, arg2)
+}
+
+// DeleteCommentsByMovieID mocks base method.
+func (m *MockStore) DeleteCommentsByMovieID(arg0 context.Context, arg1 primitive.ObjectID) ([]primitive.ObjectID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteCommentsByMovieID", arg0, arg1)
+	ret0, _ := ret[0].([]primitive.ObjectID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteCommentsByMovieID indicates an expected call of DeleteCommentsByMovieID.
+func (mr *MockStoreMockRecorder) DeleteCommentsByMovieID(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteCommentsByMovieID", reflect.TypeOf((*MockStore)(nil).DeleteCommentsByMovieID), arg0, arg1)
+}
+
+// DeleteHistoryEntry mocks base method.
+func (m *MockStore) DeleteHistoryEntry(arg0 context.Context, arg1 string, arg2 primitive.ObjectID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteHistoryEntry", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteHistoryEntry indicates an expected call of DeleteHistoryEntry.
+func (mr *MockStoreMockRecorder) DeleteHistoryEntry(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteHistoryEntry", reflect.TypeOf((*MockStore)(nil).DeleteHistoryEntry), arg0, arg1, arg2)
+}
+
+// DeleteListEntry mocks base method.
+func (m *MockStore) DeleteListEntry(arg0 context.Context, arg1 mongo0.ListEntryParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteListEntry", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteListEntry indicates an expected call of DeleteListEntry.
+func (mr *MockStoreMockRecorder) DeleteListEntry(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteListEntry", reflect.TypeOf((*MockStore)(nil).DeleteListEntry), arg0, arg1)
+}
+
+// DeleteMovieByID mocks base method.
+func (m *MockStore) DeleteMovieByID(arg0 context.Context, arg1 primitive.ObjectID) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteMovieByID", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteMovieByID indicates an expected call of DeleteMovieByID.
+func (mr *MockStoreMockRecorder) DeleteMovieByID(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMovieByID", reflect.TypeOf((*MockStore)(nil).DeleteMovieByID), arg0, arg1)
+}
+
+// DeleteRating mocks base method.
+func (m *MockStore) DeleteRating(arg0 context.Context, arg1 string, arg2 primitive.ObjectID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRating", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteRating indicates an expected call of DeleteRating.
+func (mr *MockStoreMockRecorder) DeleteRating(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRating", reflect.TypeOf((*MockStore)(nil).DeleteRating), arg0, arg1, arg2)
+}
+
+// DeleteReaction mocks base method.
+func (m *MockStore) DeleteReaction(arg0 context.Context, arg1 mongo0.CommentReactionParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteReaction", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteReaction indicates an expected call of DeleteReaction.
+func (mr *MockStoreMockRecorder) DeleteReaction(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteReaction", reflect.TypeOf((*MockStore)(nil).DeleteReaction), arg0, arg1)
+}
+
+// ExecTx mocks base method.
+func (m *MockStore) ExecTx(arg0 context.Context, arg1 func(mongo0.Querier) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExecTx", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExecTx indicates an expected call of ExecTx.
+func (mr *MockStoreMockRecorder) ExecTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExecTx", reflect.TypeOf((*MockStore)(nil).ExecTx), arg0, arg1)
+}
+
+// GetAllComments mocks base method.
+func (m *MockStore) GetAllComments(arg0 context.Context) ([]mongo0.Comments, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAllComments", arg0)
+	ret0, _ := ret[0].([]mongo0.Comments)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAllComments indicates an expected call of GetAllComments.
+func (mr *MockStoreMockRecorder) GetAllComments(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllComments", reflect.TypeOf((*MockStore)(nil).GetAllComments), arg0)
+}
+
+// GetAllMovies mocks base method.
+func (m *MockStore) GetAllMovies(arg0 context.Context) ([]mongo0.Movies, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAllMovies", arg0)
+	ret0, _ := ret[0].([]mongo0.Movies)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAllMovies indicates an expected call of GetAllMovies.
+func (mr *MockStoreMockRecorder) GetAllMovies(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllMovies", reflect.TypeOf((*MockStore)(nil).GetAllMovies), arg0)
+}
+
+// GetCoinTransactions mocks base method.
+func (m *MockStore) GetCoinTransactions(arg0 context.Context, arg1 mongo0.GetCoinTransactionsParams) ([]mongo0.CoinTransaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCoinTransactions", arg0, arg1)
+	ret0, _ := ret[0].([]mongo0.CoinTransaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCoinTransactions indicates an expected call of GetCoinTransactions.
+func (mr *MockStoreMockRecorder) GetCoinTransactions(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCoinTransactions", reflect.TypeOf((*MockStore)(nil).GetCoinTransactions), arg0, arg1)
+}
+
+// GetCoinWallet mocks base method.
+func (m *MockStore) GetCoinWallet(arg0 context.Context, arg1 string) (mongo0.CoinWallet, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCoinWallet", arg0, arg1)
+	ret0, _ := ret[0].(mongo0.CoinWallet)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCoinWallet indicates an expected call of GetCoinWallet.
+func (mr *MockStoreMockRecorder) GetCoinWallet(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCoinWallet", reflect.TypeOf((*MockStore)(nil).GetCoinWallet), arg0, arg1)
+}
+
+// GetCollectionByID mocks base method.
+func (m *MockStore) GetCollectionByID(arg0 context.Context, arg1 primitive.ObjectID) (mongo0.Collection, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCollectionByID", arg0, arg1)
+	ret0, _ := ret[0].(mongo0.Collection)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCollectionByID indicates an expected call of GetCollectionByID.
+func (mr *MockStoreMockRecorder) GetCollectionByID(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCollectionByID", reflect.TypeOf((*MockStore)(nil).GetCollectionByID), arg0, arg1)
+}
+
+// GetCollectionMovies mocks base method.
+func (m *MockStore) GetCollectionMovies(arg0 context.Context, arg1 mongo0.GetCollectionMoviesParams) ([]mongo0.Movies, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCollectionMovies", arg0, arg1)
+	ret0, _ := ret[0].([]mongo0.Movies)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCollectionMovies indicates an expected call of GetCollectionMovies.
+func (mr *MockStoreMockRecorder) GetCollectionMovies(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCollectionMovies", reflect.TypeOf((*MockStore)(nil).GetCollectionMovies), arg0, arg1)
+}
+
+// GetCollections mocks base method.
+func (m *MockStore) GetCollections(arg0 context.Context, arg1 mongo0.GetCollectionsParams) ([]mongo0.Collection, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCollections", arg0, arg1)
+	ret0, _ := ret[0].([]mongo0.Collection)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCollections indicates an expected call of GetCollections.
+func (mr *MockStoreMockRecorder) GetCollections(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCollections", reflect.TypeOf((*MockStore)(nil).GetCollections), arg0, arg1)
+}
+
+// GetCollectionsByMovie mocks base method.
+func (m *MockStore) GetCollectionsByMovie(arg0 context.Context, arg1 mongo0.Movies) ([]mongo0.Collection, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCollectionsByMovie", arg0, arg1)
+	ret0, _ := ret[0].([]mongo0.Collection)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCollectionsByMovie indicates an expected call of GetCollectionsByMovie.
+func (mr *MockStoreMockRecorder) GetCollectionsByMovie(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCollectionsByMovie", reflect.TypeOf((*MockStore)(nil).GetCollectionsByMovie), arg0, arg1)
+}
+
+// GetComment mocks base method.
+func (m *MockStore) GetComment(arg0 context.Context, arg1 primitive.ObjectID) (mongo0.Comments, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetComment", arg0, arg1)
+	ret0, _ := ret[0].(mongo0.Comments)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetComment indicates an expected call of GetComment.
+func (mr *MockStoreMockRecorder) GetComment(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetComment", reflect.TypeOf((*MockStore)(nil).GetComment), arg0, arg1)
+}
+
+// GetCommentReporters mocks base method.
+func (m *MockStore) GetCommentReporters(arg0 context.Context, arg1 primitive.ObjectID) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCommentReporters", arg0, arg1)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCommentReporters indicates an expected call of GetCommentReporters.
+func (mr *MockStoreMockRecorder) GetCommentReporters(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCommentReporters", reflect.TypeOf((*MockStore)(nil).GetCommentReporters), arg0, arg1)
+}
+
+// GetCommentsByMovieID mocks base method.
+func (m *MockStore) GetCommentsByMovieID(arg0 context.Context, arg1 mongo0.GetCommentsParams) ([]mongo0.Comments, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCommentsByMovieID", arg0, arg1)
+	ret0, _ := ret[0].([]mongo0.Comments)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCommentsByMovieID indicates an expected call of GetCommentsByMovieID.
+func (mr *MockStoreMockRecorder) GetCommentsByMovieID(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCommentsByMovieID", reflect.TypeOf((*MockStore)(nil).GetCommentsByMovieID), arg0, arg1)
+}
+
+// GetCommentsByName mocks base method.
+func (m *MockStore) GetCommentsByName(arg0 context.Context, arg1 mongo0.GetCommentsParams) ([]mongo0.Comments, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCommentsByName", arg0, arg1)
+	ret0, _ := ret[0].([]mongo0.Comments)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCommentsByName indicates an expected call of GetCommentsByName.
+func (mr *MockStoreMockRecorder) GetCommentsByName(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCommentsByName", reflect.TypeOf((*MockStore)(nil).GetCommentsByName), arg0, arg1)
+}
+
+// GetContinueWatching mocks base method.
+func (m *MockStore) GetContinueWatching(arg0 context.Context, arg1 mongo0.GetContinueWatchingParams) ([]mongo0.Progress, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetContinueWatching", arg0, arg1)
+	ret0, _ := ret[0].([]mongo0.Progress)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetContinueWatching indicates an expected call of GetContinueWatching.
+func (mr *MockStoreMockRecorder) GetContinueWatching(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetContinueWatching", reflect.TypeOf((*MockStore)(nil).GetContinueWatching), arg0, arg1)
+}
+
+// GetDanmaku mocks base method.
+func (m *MockStore) GetDanmaku(arg0 context.Context, arg1 mongo0.GetDanmakuParams) ([]mongo0.Danmaku, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDanmaku", arg0, arg1)
+	ret0, _ := ret[0].([]mongo0.Danmaku)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDanmaku indicates an expected call of GetDanmaku.
+func (mr *MockStoreMockRecorder) GetDanmaku(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDanmaku", reflect.TypeOf((*MockStore)(nil).GetDanmaku), arg0, arg1)
+}
+
+// GetDanmakuModerationQueue mocks base method.
+func (m *MockStore) GetDanmakuModerationQueue(arg0 context.Context, arg1 mongo0.GetModerationQueueParams) ([]mongo0.Danmaku, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDanmakuModerationQueue", arg0, arg1)
+	ret0, _ := ret[0].([]mongo0.Danmaku)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDanmakuModerationQueue indicates an expected call of GetDanmakuModerationQueue.
+func (mr *MockStoreMockRecorder) GetDanmakuModerationQueue(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDanmakuModerationQueue", reflect.TypeOf((*MockStore)(nil).GetDanmakuModerationQueue), arg0, arg1)
+}
+
+// GetEpisodeByID mocks base method.
+func (m *MockStore) GetEpisodeByID(arg0 context.Context, arg1 primitive.ObjectID) (mongo0.Episode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEpisodeByID", arg0, arg1)
+	ret0, _ := ret[0].(mongo0.Episode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEpisodeByID indicates an expected call of GetEpisodeByID.
+func (mr *MockStoreMockRecorder) GetEpisodeByID(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEpisodeByID", reflect.TypeOf((*MockStore)(nil).GetEpisodeByID), arg0, arg1)
+}
+
+// GetEpisodes mocks base method.
+func (m *MockStore) GetEpisodes(arg0 context.Context, arg1 mongo0.GetEpisodesParams) ([]mongo0.Episode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEpisodes", arg0, arg1)
+	ret0, _ := ret[0].([]mongo0.Episode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEpisodes indicates an expected call of GetEpisodes.
+func (mr *MockStoreMockRecorder) GetEpisodes(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEpisodes", reflect.TypeOf((*MockStore)(nil).GetEpisodes), arg0, arg1)
+}
+
+// GetEpisodesByIDs mocks base method.
+func (m *MockStore) GetEpisodesByIDs(arg0 context.Context, arg1 []primitive.ObjectID) ([]mongo0.Episode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEpisodesByIDs", arg0, arg1)
+	ret0, _ := ret[0].([]mongo0.Episode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEpisodesByIDs indicates an expected call of GetEpisodesByIDs.
+func (mr *MockStoreMockRecorder) GetEpisodesByIDs(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEpisodesByIDs", reflect.TypeOf((*MockStore)(nil).GetEpisodesByIDs), arg0, arg1)
+}
+
+// GetHistory mocks base method.
+func (m *MockStore) GetHistory(arg0 context.Context, arg1 mongo0.GetHistoryParams) ([]mongo0.HistoryEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetHistory", arg0, arg1)
+	ret0, _ := ret[0].([]mongo0.HistoryEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetHistory indicates an expected call of GetHistory.
+func (mr *MockStoreMockRecorder) GetHistory(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHistory", reflect.TypeOf((*MockStore)(nil).GetHistory), arg0, arg1)
+}
+
+// GetLedgerProof mocks base method.
+func (m *MockStore) GetLedgerProof(arg0 context.Context, arg1 primitive.ObjectID) (ledger.Proof, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLedgerProof", arg0, arg1)
+	ret0, _ := ret[0].(ledger.Proof)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLedgerProof indicates an expected call of GetLedgerProof.
+func (mr *MockStoreMockRecorder) GetLedgerProof(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLedgerProof", reflect.TypeOf((*MockStore)(nil).GetLedgerProof), arg0, arg1)
+}
+
+// GetListEntries mocks base method.
+func (m *MockStore) GetListEntries(arg0 context.Context, arg1 mongo0.GetListParams) ([]mongo0.ListEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetListEntries", arg0, arg1)
+	ret0, _ := ret[0].([]mongo0.ListEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetListEntries indicates an expected call of GetListEntries.
+func (mr *MockStoreMockRecorder) GetListEntries(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetListEntries", reflect.TypeOf((*MockStore)(nil).GetListEntries), arg0, arg1)
+}
+
+// GetListsByMovie mocks base method.
+func (m *MockStore) GetListsByMovie(arg0 context.Context, arg1 string, arg2 primitive.ObjectID) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetListsByMovie", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetListsByMovie indicates an expected call of GetListsByMovie.
+func (mr *MockStoreMockRecorder) GetListsByMovie(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetListsByMovie", reflect.TypeOf((*MockStore)(nil).GetListsByMovie), arg0, arg1, arg2)
+}
+
+// GetModerationLog mocks base method.
+func (m *MockStore) GetModerationLog(arg0 context.Context, arg1 mongo0.GetModerationLogParams) ([]mongo0.ModerationLogEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetModerationLog", arg0, arg1)
+	ret0, _ := ret[0].([]mongo0.ModerationLogEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetModerationLog indicates an expected call of GetModerationLog.
+func (mr *MockStoreMockRecorder) GetModerationLog(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetModerationLog", reflect.TypeOf((*MockStore)(nil).GetModerationLog), arg0, arg1)
+}
+
+// GetModerationQueue mocks base method.
+func (m *MockStore) GetModerationQueue(arg0 context.Context, arg1 mongo0.GetModerationQueueParams) ([]mongo0.Comments, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetModerationQueue", arg0, arg1)
+	ret0, _ := ret[0].([]mongo0.Comments)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetModerationQueue indicates an expected call of GetModerationQueue.
+func (mr *MockStoreMockRecorder) GetModerationQueue(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetModerationQueue", reflect.TypeOf((*MockStore)(nil).GetModerationQueue), arg0, arg1)
+}
+
+// GetMovieByID mocks base method.
+func (m *MockStore) GetMovieByID(arg0 context.Context, arg1 primitive.ObjectID) (mongo0.Movies, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMovieByID", arg0, arg1)
+	ret0, _ := ret[0].(mongo0.Movies)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMovieByID indicates an expected call of GetMovieByID.
+func (mr *MockStoreMockRecorder) GetMovieByID(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMovieByID", reflect.TypeOf((*MockStore)(nil).GetMovieByID), arg0, arg1)
+}
+
+// GetMoviesByGenres mocks base method.
+func (m *MockStore) GetMoviesByGenres(arg0 context.Context, arg1 mongo0.GetMoviesParams) ([]mongo0.Movies, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMoviesByGenres", arg0, arg1)
+	ret0, _ := ret[0].([]mongo0.Movies)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// AddComment indicates an expected call of AddComment.
-func (mr *MockStoreMockRecorder) AddComment(arg0, arg1 interface{}) *gomock.Call {
+// GetMoviesByGenres indicates an expected call of GetMoviesByGenres.
+func (mr *MockStoreMockRecorder) GetMoviesByGenres(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddComment", reflect.TypeOf((*MockStore)(nil).AddComment), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMoviesByGenres", reflect.TypeOf((*MockStore)(nil).GetMoviesByGenres), arg0, arg1)
 }
 
-// AddMovie mocks base method.
-func (m *MockStore) AddMovie(arg0 context.Context, arg1 mongo0.AddMovieParams) (primitive.ObjectID, error) {
+// GetMoviesByIDs mocks base method.
+func (m *MockStore) GetMoviesByIDs(arg0 context.Context, arg1 []primitive.ObjectID) ([]mongo0.Movies, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddMovie", arg0, arg1)
-	ret0, _ := ret[0].(primitive.ObjectID)
+	ret := m.ctrl.Call(m, "GetMoviesByIDs", arg0, arg1)
+	ret0, _ := ret[0].([]mongo0.Movies)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// AddMovie indicates an expected call of AddMovie.
-func (mr *MockStoreMockRecorder) AddMovie(arg0, arg1 interface{}) *gomock.Call {
+// GetMoviesByIDs indicates an expected call of GetMoviesByIDs.
+func (mr *MockStoreMockRecorder) GetMoviesByIDs(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddMovie", reflect.TypeOf((*MockStore)(nil).AddMovie), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMoviesByIDs", reflect.TypeOf((*MockStore)(nil).GetMoviesByIDs), arg0, arg1)
 }
 
-// AddMovies mocks base method.
-func (m *MockStore) AddMovies(arg0 context.Context, arg1 []mongo0.AddMovieParams) ([]interface{}, error) {
+// GetMoviesByPersonID mocks base method.
+func (m *MockStore) GetMoviesByPersonID(arg0 context.Context, arg1 primitive.ObjectID) ([]mongo0.Movies, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddMovies", arg0, arg1)
-	ret0, _ := ret[0].([]interface{})
+	ret := m.ctrl.Call(m, "GetMoviesByPersonID", arg0, arg1)
+	ret0, _ := ret[0].([]mongo0.Movies)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// AddMovies indicates an expected call of AddMovies.
-func (mr *MockStoreMockRecorder) AddMovies(arg0, arg1 interface{}) *gomock.Call {
+// GetMoviesByPersonID indicates an expected call of GetMoviesByPersonID.
+func (mr *MockStoreMockRecorder) GetMoviesByPersonID(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddMovies", reflect.TypeOf((*MockStore)(nil).AddMovies), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMoviesByPersonID", reflect.TypeOf((*MockStore)(nil).GetMoviesByPersonID), arg0, arg1)
 }
 
-// AddUser mocks base method.
-func (m *MockStore) AddUser(arg0 context.Context, arg1 mongo0.AddUserParams) (primitive.ObjectID, error) {
+// GetPeopleByIDs mocks base method.
+func (m *MockStore) GetPeopleByIDs(arg0 context.Context, arg1 []primitive.ObjectID) ([]mongo0.Person, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddUser", arg0, arg1)
-	ret0, _ := ret[0].(primitive.ObjectID)
+	ret := m.ctrl.Call(m, "GetPeopleByIDs", arg0, arg1)
+	ret0, _ := ret[0].([]mongo0.Person)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// AddUser indicates an expected call of AddUser.
-func (mr *MockStoreMockRecorder) AddUser(arg0, arg1 interface{}) *gomock.Call {
+// GetPeopleByIDs indicates an expected call of GetPeopleByIDs.
+func (mr *MockStoreMockRecorder) GetPeopleByIDs(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUser", reflect.TypeOf((*MockStore)(nil).AddUser), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPeopleByIDs", reflect.TypeOf((*MockStore)(nil).GetPeopleByIDs), arg0, arg1)
 }
 
-// DeleteComment mocks base method.
-func (m *MockStore) DeleteComment(arg0 context.Context, arg1 primitive.ObjectID, arg2 string) (int64, error) {
+// GetPersonByID mocks base method.
+func (m *MockStore) GetPersonByID(arg0 context.Context, arg1 primitive.ObjectID) (mongo0.Person, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteComment", arg0, arg1, arg2)
-	ret0, _ := ret[0].(int64)
+	ret := m.ctrl.Call(m, "GetPersonByID", arg0, arg1)
+	ret0, _ := ret[0].(mongo0.Person)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// DeleteComment indicates an expected call of DeleteComment.
-func (mr *MockStoreMockRecorder) DeleteComment(arg0, arg1, arg2 interface{}) *gomock.Call {
+// GetPersonByID indicates an expected call of GetPersonByID.
+func (mr *MockStoreMockRecorder) GetPersonByID(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteComment", reflect.TypeOf((*MockStore)(nil).DeleteComment), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPersonByID", reflect.TypeOf((*MockStore)(nil).GetPersonByID), arg0, arg1)
 }
 
-// GetComment mocks base method.
-func (m *MockStore) GetComment(arg0 context.Context, arg1 primitive.ObjectID) (mongo0.Comments, error) {
+// GetProgressByMediaIDs mocks base method.
+func (m *MockStore) GetProgressByMediaIDs(arg0 context.Context, arg1 string, arg2 []primitive.ObjectID) ([]mongo0.Progress, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetComment", arg0, arg1)
-	ret0, _ := ret[0].(mongo0.Comments)
+	ret := m.ctrl.Call(m, "GetProgressByMediaIDs", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]mongo0.Progress)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetComment indicates an expected call of GetComment.
-func (mr *MockStoreMockRecorder) GetComment(arg0, arg1 interface{}) *gomock.Call {
+// GetProgressByMediaIDs indicates an expected call of GetProgressByMediaIDs.
+func (mr *MockStoreMockRecorder) GetProgressByMediaIDs(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetComment", reflect.TypeOf((*MockStore)(nil).GetComment), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProgressByMediaIDs", reflect.TypeOf((*MockStore)(nil).GetProgressByMediaIDs), arg0, arg1, arg2)
 }
 
-// GetCommentsByMovieID mocks base method.
-func (m *MockStore) GetCommentsByMovieID(arg0 context.Context, arg1 mongo0.GetCommentsParams) ([]mongo0.Comments, error) {
+// GetProgressBySeriesID mocks base method.
+func (m *MockStore) GetProgressBySeriesID(arg0 context.Context, arg1 string, arg2 primitive.ObjectID) ([]mongo0.Progress, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetCommentsByMovieID", arg0, arg1)
-	ret0, _ := ret[0].([]mongo0.Comments)
+	ret := m.ctrl.Call(m, "GetProgressBySeriesID", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]mongo0.Progress)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetCommentsByMovieID indicates an expected call of GetCommentsByMovieID.
-func (mr *MockStoreMockRecorder) GetCommentsByMovieID(arg0, arg1 interface{}) *gomock.Call {
+// GetProgressBySeriesID indicates an expected call of GetProgressBySeriesID.
+func (mr *MockStoreMockRecorder) GetProgressBySeriesID(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCommentsByMovieID", reflect.TypeOf((*MockStore)(nil).GetCommentsByMovieID), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProgressBySeriesID", reflect.TypeOf((*MockStore)(nil).GetProgressBySeriesID), arg0, arg1, arg2)
 }
 
-// GetCommentsByName mocks base method.
-func (m *MockStore) GetCommentsByName(arg0 context.Context, arg1 mongo0.GetCommentsParams) ([]mongo0.Comments, error) {
+// GetRating mocks base method.
+func (m *MockStore) GetRating(arg0 context.Context, arg1 string, arg2 primitive.ObjectID) (mongo0.Rating, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetCommentsByName", arg0, arg1)
+	ret := m.ctrl.Call(m, "GetRating", arg0, arg1, arg2)
+	ret0, _ := ret[0].(mongo0.Rating)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRating indicates an expected call of GetRating.
+func (mr *MockStoreMockRecorder) GetRating(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRating", reflect.TypeOf((*MockStore)(nil).GetRating), arg0, arg1, arg2)
+}
+
+// GetReactionsByName mocks base method.
+func (m *MockStore) GetReactionsByName(arg0 context.Context, arg1 string, arg2 []primitive.ObjectID) ([]mongo0.CommentReaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReactionsByName", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]mongo0.CommentReaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReactionsByName indicates an expected call of GetReactionsByName.
+func (mr *MockStoreMockRecorder) GetReactionsByName(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReactionsByName", reflect.TypeOf((*MockStore)(nil).GetReactionsByName), arg0, arg1, arg2)
+}
+
+// GetReplies mocks base method.
+func (m *MockStore) GetReplies(arg0 context.Context, arg1 mongo0.GetRepliesParams) ([]mongo0.Comments, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReplies", arg0, arg1)
 	ret0, _ := ret[0].([]mongo0.Comments)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetCommentsByName indicates an expected call of GetCommentsByName.
-func (mr *MockStoreMockRecorder) GetCommentsByName(arg0, arg1 interface{}) *gomock.Call {
+// GetReplies indicates an expected call of GetReplies.
+func (mr *MockStoreMockRecorder) GetReplies(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCommentsByName", reflect.TypeOf((*MockStore)(nil).GetCommentsByName), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReplies", reflect.TypeOf((*MockStore)(nil).GetReplies), arg0, arg1)
 }
 
-// GetMovieByID mocks base method.
-func (m *MockStore) GetMovieByID(arg0 context.Context, arg1 primitive.ObjectID) (mongo0.Movies, error) {
+// GetReplySummaries mocks base method.
+func (m *MockStore) GetReplySummaries(arg0 context.Context, arg1 []primitive.ObjectID, arg2 int64) ([]mongo0.ReplySummary, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetMovieByID", arg0, arg1)
-	ret0, _ := ret[0].(mongo0.Movies)
+	ret := m.ctrl.Call(m, "GetReplySummaries", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]mongo0.ReplySummary)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetMovieByID indicates an expected call of GetMovieByID.
-func (mr *MockStoreMockRecorder) GetMovieByID(arg0, arg1 interface{}) *gomock.Call {
+// GetReplySummaries indicates an expected call of GetReplySummaries.
+func (mr *MockStoreMockRecorder) GetReplySummaries(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMovieByID", reflect.TypeOf((*MockStore)(nil).GetMovieByID), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReplySummaries", reflect.TypeOf((*MockStore)(nil).GetReplySummaries), arg0, arg1, arg2)
 }
 
-// GetMoviesByGenres mocks base method.
-func (m *MockStore) GetMoviesByGenres(arg0 context.Context, arg1 mongo0.GetMoviesParams) ([]mongo0.Movies, error) {
+// GetSeasonsBySeriesID mocks base method.
+func (m *MockStore) GetSeasonsBySeriesID(arg0 context.Context, arg1 primitive.ObjectID) ([]mongo0.Season, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetMoviesByGenres", arg0, arg1)
-	ret0, _ := ret[0].([]mongo0.Movies)
+	ret := m.ctrl.Call(m, "GetSeasonsBySeriesID", arg0, arg1)
+	ret0, _ := ret[0].([]mongo0.Season)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetMoviesByGenres indicates an expected call of GetMoviesByGenres.
-func (mr *MockStoreMockRecorder) GetMoviesByGenres(arg0, arg1 interface{}) *gomock.Call {
+// GetSeasonsBySeriesID indicates an expected call of GetSeasonsBySeriesID.
+func (mr *MockStoreMockRecorder) GetSeasonsBySeriesID(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMoviesByGenres", reflect.TypeOf((*MockStore)(nil).GetMoviesByGenres), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSeasonsBySeriesID", reflect.TypeOf((*MockStore)(nil).GetSeasonsBySeriesID), arg0, arg1)
 }
 
 // GetTheLatestReleasedMovies mocks base method.
@@ -217,6 +990,21 @@ func (mr *MockStoreMockRecorder) GetTheMostViewedMovies(arg0, arg1 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTheMostViewedMovies", reflect.TypeOf((*MockStore)(nil).GetTheMostViewedMovies), arg0, arg1)
 }
 
+// GetTitleRequests mocks base method.
+func (m *MockStore) GetTitleRequests(arg0 context.Context, arg1 mongo0.GetTitleRequestsParams) ([]mongo0.TitleRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTitleRequests", arg0, arg1)
+	ret0, _ := ret[0].([]mongo0.TitleRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTitleRequests indicates an expected call of GetTitleRequests.
+func (mr *MockStoreMockRecorder) GetTitleRequests(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTitleRequests", reflect.TypeOf((*MockStore)(nil).GetTitleRequests), arg0, arg1)
+}
+
 // GetUserByEmail mocks base method.
 func (m *MockStore) GetUserByEmail(arg0 context.Context, arg1 string) (mongo0.User, error) {
 	m.ctrl.T.Helper()
@@ -262,6 +1050,138 @@ func (mr *MockStoreMockRecorder) GetUserByName(arg0, arg1 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByName", reflect.TypeOf((*MockStore)(nil).GetUserByName), arg0, arg1)
 }
 
+// GetUserStats mocks base method.
+func (m *MockStore) GetUserStats(arg0 context.Context, arg1 mongo0.GetUserStatsParams) (mongo0.UserStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserStats", arg0, arg1)
+	ret0, _ := ret[0].(mongo0.UserStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserStats indicates an expected call of GetUserStats.
+func (mr *MockStoreMockRecorder) GetUserStats(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserStats", reflect.TypeOf((*MockStore)(nil).GetUserStats), arg0, arg1)
+}
+
+// IncrementMovieComments mocks base method.
+func (m *MockStore) IncrementMovieComments(arg0 context.Context, arg1 primitive.ObjectID, arg2 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrementMovieComments", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IncrementMovieComments indicates an expected call of IncrementMovieComments.
+func (mr *MockStoreMockRecorder) IncrementMovieComments(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementMovieComments", reflect.TypeOf((*MockStore)(nil).IncrementMovieComments), arg0, arg1, arg2)
+}
+
+// PinDanmakuTx mocks base method.
+func (m *MockStore) PinDanmakuTx(arg0 context.Context, arg1 mongo0.PinDanmakuTxParams) (mongo0.PinDanmakuTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PinDanmakuTx", arg0, arg1)
+	ret0, _ := ret[0].(mongo0.PinDanmakuTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PinDanmakuTx indicates an expected call of PinDanmakuTx.
+func (mr *MockStoreMockRecorder) PinDanmakuTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PinDanmakuTx", reflect.TypeOf((*MockStore)(nil).PinDanmakuTx), arg0, arg1)
+}
+
+// RateMovie mocks base method.
+func (m *MockStore) RateMovie(arg0 context.Context, arg1 mongo0.RateMovieParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RateMovie", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RateMovie indicates an expected call of RateMovie.
+func (mr *MockStoreMockRecorder) RateMovie(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RateMovie", reflect.TypeOf((*MockStore)(nil).RateMovie), arg0, arg1)
+}
+
+// ReconcileCoins mocks base method.
+func (m *MockStore) ReconcileCoins(arg0 context.Context) (mongo0.CoinReconciliation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReconcileCoins", arg0)
+	ret0, _ := ret[0].(mongo0.CoinReconciliation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReconcileCoins indicates an expected call of ReconcileCoins.
+func (mr *MockStoreMockRecorder) ReconcileCoins(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReconcileCoins", reflect.TypeOf((*MockStore)(nil).ReconcileCoins), arg0)
+}
+
+// RemoveComment mocks base method.
+func (m *MockStore) RemoveComment(arg0 context.Context, arg1 primitive.ObjectID) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveComment", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RemoveComment indicates an expected call of RemoveComment.
+func (mr *MockStoreMockRecorder) RemoveComment(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveComment", reflect.TypeOf((*MockStore)(nil).RemoveComment), arg0, arg1)
+}
+
+// RemoveDanmaku mocks base method.
+func (m *MockStore) RemoveDanmaku(arg0 context.Context, arg1 primitive.ObjectID) (mongo0.Danmaku, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveDanmaku", arg0, arg1)
+	ret0, _ := ret[0].(mongo0.Danmaku)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RemoveDanmaku indicates an expected call of RemoveDanmaku.
+func (mr *MockStoreMockRecorder) RemoveDanmaku(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveDanmaku", reflect.TypeOf((*MockStore)(nil).RemoveDanmaku), arg0, arg1)
+}
+
+// ReorderList mocks base method.
+func (m *MockStore) ReorderList(arg0 context.Context, arg1 mongo0.ReorderListParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReorderList", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReorderList indicates an expected call of ReorderList.
+func (mr *MockStoreMockRecorder) ReorderList(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReorderList", reflect.TypeOf((*MockStore)(nil).ReorderList), arg0, arg1)
+}
+
+// ReplaceCollection mocks base method.
+func (m *MockStore) ReplaceCollection(arg0 context.Context, arg1 mongo0.Collection) (*mongo.UpdateResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplaceCollection", arg0, arg1)
+	ret0, _ := ret[0].(*mongo.UpdateResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReplaceCollection indicates an expected call of ReplaceCollection.
+func (mr *MockStoreMockRecorder) ReplaceCollection(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceCollection", reflect.TypeOf((*MockStore)(nil).ReplaceCollection), arg0, arg1)
+}
+
 // ReplaceMovieInfoByID mocks base method.
 func (m *MockStore) ReplaceMovieInfoByID(arg0 context.Context, arg1 primitive.ObjectID, arg2 mongo0.Movies) (*mongo.UpdateResult, error) {
 	m.ctrl.T.Helper()
@@ -277,6 +1197,65 @@ func (mr *MockStoreMockRecorder) ReplaceMovieInfoByID(arg0, arg1, arg2 interface
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceMovieInfoByID", reflect.TypeOf((*MockStore)(nil).ReplaceMovieInfoByID), arg0, arg1, arg2)
 }
 
+// ReportComment mocks base method.
+func (m *MockStore) ReportComment(arg0 context.Context, arg1 mongo0.ReportCommentParams) (mongo0.Comments, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReportComment", arg0, arg1)
+	ret0, _ := ret[0].(mongo0.Comments)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReportComment indicates an expected call of ReportComment.
+func (mr *MockStoreMockRecorder) ReportComment(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReportComment", reflect.TypeOf((*MockStore)(nil).ReportComment), arg0, arg1)
+}
+
+// RequestTitleTx mocks base method.
+func (m *MockStore) RequestTitleTx(arg0 context.Context, arg1 mongo0.RequestTitleTxParams) (mongo0.TitleRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestTitleTx", arg0, arg1)
+	ret0, _ := ret[0].(mongo0.TitleRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RequestTitleTx indicates an expected call of RequestTitleTx.
+func (mr *MockStoreMockRecorder) RequestTitleTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestTitleTx", reflect.TypeOf((*MockStore)(nil).RequestTitleTx), arg0, arg1)
+}
+
+// ReverseCoins mocks base method.
+func (m *MockStore) ReverseCoins(arg0 context.Context, arg1 mongo0.ReverseCoinsParams) (mongo0.TransferCoinsResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReverseCoins", arg0, arg1)
+	ret0, _ := ret[0].(mongo0.TransferCoinsResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReverseCoins indicates an expected call of ReverseCoins.
+func (mr *MockStoreMockRecorder) ReverseCoins(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReverseCoins", reflect.TypeOf((*MockStore)(nil).ReverseCoins), arg0, arg1)
+}
+
+// SaveProgress mocks base method.
+func (m *MockStore) SaveProgress(arg0 context.Context, arg1 mongo0.SaveProgressParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveProgress", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveProgress indicates an expected call of SaveProgress.
+func (mr *MockStoreMockRecorder) SaveProgress(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveProgress", reflect.TypeOf((*MockStore)(nil).SaveProgress), arg0, arg1)
+}
+
 // SearchForMovies mocks base method.
 func (m *MockStore) SearchForMovies(arg0 context.Context, arg1 mongo0.SearchForMoviesParams) ([]mongo0.Movies, error) {
 	m.ctrl.T.Helper()
@@ -292,6 +1271,51 @@ func (mr *MockStoreMockRecorder) SearchForMovies(arg0, arg1 interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchForMovies", reflect.TypeOf((*MockStore)(nil).SearchForMovies), arg0, arg1)
 }
 
+// SearchPeople mocks base method.
+func (m *MockStore) SearchPeople(arg0 context.Context, arg1 mongo0.SearchPeopleParams) ([]mongo0.Person, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchPeople", arg0, arg1)
+	ret0, _ := ret[0].([]mongo0.Person)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchPeople indicates an expected call of SearchPeople.
+func (mr *MockStoreMockRecorder) SearchPeople(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchPeople", reflect.TypeOf((*MockStore)(nil).SearchPeople), arg0, arg1)
+}
+
+// TransferCoins mocks base method.
+func (m *MockStore) TransferCoins(arg0 context.Context, arg1 mongo0.TransferCoinsParams) (mongo0.TransferCoinsResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TransferCoins", arg0, arg1)
+	ret0, _ := ret[0].(mongo0.TransferCoinsResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TransferCoins indicates an expected call of TransferCoins.
+func (mr *MockStoreMockRecorder) TransferCoins(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransferCoins", reflect.TypeOf((*MockStore)(nil).TransferCoins), arg0, arg1)
+}
+
+// TransferCoinsTx mocks base method.
+func (m *MockStore) TransferCoinsTx(arg0 context.Context, arg1 mongo0.TransferCoinsParams) (mongo0.TransferCoinsResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TransferCoinsTx", arg0, arg1)
+	ret0, _ := ret[0].(mongo0.TransferCoinsResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TransferCoinsTx indicates an expected call of TransferCoinsTx.
+func (mr *MockStoreMockRecorder) TransferCoinsTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransferCoinsTx", reflect.TypeOf((*MockStore)(nil).TransferCoinsTx), arg0, arg1)
+}
+
 // UpdateComment mocks base method.
 func (m *MockStore) UpdateComment(arg0 context.Context, arg1 mongo0.Comments) (*mongo.UpdateResult, error) {
 	m.ctrl.T.Helper()
@@ -307,6 +1331,36 @@ func (mr *MockStoreMockRecorder) UpdateComment(arg0, arg1 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateComment", reflect.TypeOf((*MockStore)(nil).UpdateComment), arg0, arg1)
 }
 
+// UpdatePerson mocks base method.
+func (m *MockStore) UpdatePerson(arg0 context.Context, arg1 mongo0.Person) (*mongo.UpdateResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePerson", arg0, arg1)
+	ret0, _ := ret[0].(*mongo.UpdateResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdatePerson indicates an expected call of UpdatePerson.
+func (mr *MockStoreMockRecorder) UpdatePerson(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePerson", reflect.TypeOf((*MockStore)(nil).UpdatePerson), arg0, arg1)
+}
+
+// UpdateUserLocale mocks base method.
+func (m *MockStore) UpdateUserLocale(arg0 context.Context, arg1 mongo0.User) (*mongo.UpdateResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserLocale", arg0, arg1)
+	ret0, _ := ret[0].(*mongo.UpdateResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserLocale indicates an expected call of UpdateUserLocale.
+func (mr *MockStoreMockRecorder) UpdateUserLocale(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserLocale", reflect.TypeOf((*MockStore)(nil).UpdateUserLocale), arg0, arg1)
+}
+
 // UpdateUserName mocks base method.
 func (m *MockStore) UpdateUserName(arg0 context.Context, arg1 mongo0.User) (*mongo.UpdateResult, error) {
 	m.ctrl.T.Helper()
@@ -336,3 +1390,33 @@ func (mr *MockStoreMockRecorder) UpdateUserPassword(arg0, arg1 interface{}) *gom
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserPassword", reflect.TypeOf((*MockStore)(nil).UpdateUserPassword), arg0, arg1)
 }
+
+// UpdateUserRole mocks base method.
+func (m *MockStore) UpdateUserRole(arg0 context.Context, arg1 mongo0.User) (*mongo.UpdateResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserRole", arg0, arg1)
+	ret0, _ := ret[0].(*mongo.UpdateResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserRole indicates an expected call of UpdateUserRole.
+func (mr *MockStoreMockRecorder) UpdateUserRole(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserRole", reflect.TypeOf((*MockStore)(nil).UpdateUserRole), arg0, arg1)
+}
+
+// UpsertMovies mocks base method.
+func (m *MockStore) UpsertMovies(arg0 context.Context, arg1 []mongo0.Movies) (*mongo.BulkWriteResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertMovies", arg0, arg1)
+	ret0, _ := ret[0].(*mongo.BulkWriteResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpsertMovies indicates an expected call of UpsertMovies.
+func (mr *MockStoreMockRecorder) UpsertMovies(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertMovies", reflect.TypeOf((*MockStore)(nil).UpsertMovies), arg0, arg1)
+}
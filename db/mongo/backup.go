@@ -0,0 +1,86 @@
+package db
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sort"
+	"strings"
+)
+
+// migrationCollections are the collections of the Migrator, which are
+// not data: a restored database keeps the migrations it has
+var migrationCollections = map[string]bool{
+	"schema_migrations":      true,
+	"schema_migrations_lock": true,
+}
+
+// CollectionNames lists the collections of the data in the database, in order
+func (store *MongoStore) CollectionNames(ctx context.Context) ([]string, error) {
+	list, err := store.database.ListCollectionNames(ctx, bson.M{"type": "collection"})
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, name := range list {
+		if strings.HasPrefix(name, "system.") || migrationCollections[name] {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ExportCollection calls fn with each document of the collection, in the
+// order of their ids. The document is only valid until fn returns
+func (store *MongoStore) ExportCollection(ctx context.Context, name string, fn func(doc bson.Raw) error) error {
+	cursor, err := store.database.Collection(name).Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		if err = fn(cursor.Current); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// ClearCollection deletes the documents of the collection, and keeps its
+// validator and indexes
+func (store *MongoStore) ClearCollection(ctx context.Context, name string) error {
+	_, err := store.database.Collection(name).DeleteMany(ctx, bson.M{})
+	return err
+}
+
+// InsertDocuments adds the documents to the collection as they are. They are
+// not validated, as documents of an older schema are migrated after they are in
+func (store *MongoStore) InsertDocuments(ctx context.Context, name string, docs []bson.Raw) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	list := make([]interface{}, len(docs))
+	for i, doc := range docs {
+		list[i] = doc
+	}
+	_, err := store.database.Collection(name).InsertMany(ctx, list, options.InsertMany().SetBypassDocumentValidation(true))
+	return err
+}
+
+// SchemaVersion is the version of the latest migration applied to the database
+func (store *MongoStore) SchemaVersion(ctx context.Context) (int64, error) {
+	return NewMigrator(store.database).Version(ctx)
+}
+
+// LatestSchemaVersion is the version of the latest migration the server knows of
+func (store *MongoStore) LatestSchemaVersion() int64 {
+	return NewMigrator(store.database).Latest()
+}
+
+// MigrateFrom brings data of the schema of the version up to the latest, by
+// running the migrations after it again, see Migrator.Redo
+func (store *MongoStore) MigrateFrom(ctx context.Context, version int64) ([]Migration, error) {
+	return NewMigrator(store.database).Redo(ctx, version)
+}
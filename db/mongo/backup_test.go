@@ -0,0 +1,51 @@
+package db
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+func TestExportAndInsertDocuments(t *testing.T) {
+	ctx := context.Background()
+	database := migrationTestDatabase(t)
+	_, err := NewMigrator(database).Up(ctx)
+	require.NoError(t, err)
+	store := NewMongoStore(database)
+
+	movie := randomMovie()
+	_, err = database.Collection("movies").InsertOne(ctx, movie)
+	require.NoError(t, err)
+
+	names, err := store.CollectionNames(ctx)
+	require.NoError(t, err)
+	require.Contains(t, names, "movies")
+	require.NotContains(t, names, "schema_migrations")
+
+	var docs []bson.Raw
+	err = store.ExportCollection(ctx, "movies", func(doc bson.Raw) error {
+		docs = append(docs, append(bson.Raw(nil), doc...))
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, docs, 1)
+	require.Equal(t, movie.Title, docs[0].Lookup("title").StringValue())
+
+	require.NoError(t, store.ClearCollection(ctx, "movies"))
+	n, err := database.Collection("movies").CountDocuments(ctx, bson.M{})
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	// The documents go back in as they were, even ones the validator would refuse
+	invalid, err := bson.Marshal(bson.M{"year": 2000})
+	require.NoError(t, err)
+	require.NoError(t, store.InsertDocuments(ctx, "movies", append(docs, invalid)))
+	n, err = database.Collection("movies").CountDocuments(ctx, bson.M{"title": movie.Title})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+
+	version, err := store.SchemaVersion(ctx)
+	require.NoError(t, err)
+	require.Equal(t, store.LatestSchemaVersion(), version)
+}
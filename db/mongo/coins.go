@@ -0,0 +1,344 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// The accounts of the coin ledger that aren't of a user, see UserCoinAccount.
+// Earned and granted coins come out of CoinAccountIssued, which goes below
+// zero, and spent coins go to CoinAccountSpent. The account of a user only
+// goes below zero when a reward it spent is taken back, see TransferCoinsParams.Debt
+const (
+	CoinAccountIssued = "system:issued"
+	CoinAccountSpent  = "system:spent"
+)
+
+// The kinds of coin transactions
+const (
+	CoinEarnComment    = "earn_comment"
+	CoinEarnRating     = "earn_rating"
+	CoinEarnModeration = "earn_moderation"
+	CoinRevokeComment  = "revoke_comment"
+	CoinGrant          = "grant"
+	CoinPinDanmaku     = "pin_danmaku"
+	CoinRequestTitle   = "request_title"
+)
+
+var (
+	ErrInsufficientCoins = errors.New("not enough coins")
+	ErrCoinKeyReused     = errors.New("the idempotency key is of another transaction")
+	errCoinAmount        = errors.New("the amount has to be positive")
+)
+
+// UserCoinAccount is the account of the user with the name
+func UserCoinAccount(name string) string {
+	return "user:" + name
+}
+
+// CoinPosting is what a transaction adds to an account, negative when it takes
+type CoinPosting struct {
+	Account string `json:"account" bson:"account"`
+	Amount  int64  `json:"amount" bson:"amount"`
+}
+
+// CoinTransaction moves coins between accounts. Its postings add up to zero,
+// and it is never changed once written. Key is the idempotency key, making
+// the same transaction again gives back the one made the first time
+type CoinTransaction struct {
+	ID        primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	Key       string             `json:"key" bson:"key"`
+	Kind      string             `json:"kind" bson:"kind"`
+	Postings  []CoinPosting      `json:"postings" bson:"postings"`
+	Memo      string             `json:"memo" bson:"memo,omitempty"`
+	CreatedAt primitive.DateTime `json:"created_at" bson:"created_at"`
+}
+
+// CoinWallet is the balance of an account, kept with the transactions
+// that make it up, see ReconcileCoins
+type CoinWallet struct {
+	Account   string             `json:"account" bson:"_id"`
+	Balance   int64              `json:"balance" bson:"balance"`
+	UpdatedAt primitive.DateTime `json:"updated_at" bson:"updated_at"`
+}
+
+// TransferCoinsParams moves Amount coins From an account To another. From
+// needs to have them, unless Debt lets it go below zero and owe them, until
+// it gets them back
+type TransferCoinsParams struct {
+	Key    string `json:"key"`
+	Kind   string `json:"kind"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Amount int64  `json:"amount"`
+	Memo   string `json:"memo"`
+	Debt   bool   `json:"debt"`
+}
+
+// TransferCoinsResult is the transaction of a transfer,
+// Replayed when it had been made before with the same key
+type TransferCoinsResult struct {
+	Transaction CoinTransaction `json:"transaction"`
+	Replayed    bool            `json:"replayed"`
+}
+
+// transferCoins makes a transfer, within the transaction of sessCtx
+func (q *Queries) transferCoins(sessCtx mongo.SessionContext, arg TransferCoinsParams) (TransferCoinsResult, error) {
+	if arg.Amount <= 0 {
+		return TransferCoinsResult{}, errCoinAmount
+	}
+	postings := []CoinPosting{{Account: arg.From, Amount: -arg.Amount}, {Account: arg.To, Amount: arg.Amount}}
+
+	var existing CoinTransaction
+	err := q.coinTransactions.FindOne(sessCtx, bson.M{"key": arg.Key}).Decode(&existing)
+	switch {
+	case err == nil:
+		same := existing.Kind == arg.Kind && len(existing.Postings) == len(postings)
+		for i := 0; same && i < len(postings); i++ {
+			same = existing.Postings[i] == postings[i]
+		}
+		if !same {
+			return TransferCoinsResult{}, ErrCoinKeyReused
+		}
+		return TransferCoinsResult{Transaction: existing, Replayed: true}, nil
+	case err != mongo.ErrNoDocuments:
+		return TransferCoinsResult{}, err
+	}
+
+	now := primitive.NewDateTimeFromTime(time.Now())
+	owes := arg.From == CoinAccountIssued || arg.Debt
+	debit := bson.M{"_id": arg.From}
+	if !owes {
+		debit["balance"] = bson.M{"$gte": arg.Amount}
+	}
+	res, err := q.coinWallets.UpdateOne(sessCtx, debit,
+		bson.M{"$inc": bson.M{"balance": -arg.Amount}, "$set": bson.M{"updated_at": now}},
+		options.Update().SetUpsert(owes))
+	if err != nil {
+		return TransferCoinsResult{}, err
+	}
+	if res.MatchedCount == 0 && res.UpsertedCount == 0 {
+		return TransferCoinsResult{}, ErrInsufficientCoins
+	}
+	_, err = q.coinWallets.UpdateOne(sessCtx, bson.M{"_id": arg.To},
+		bson.M{"$inc": bson.M{"balance": arg.Amount}, "$set": bson.M{"updated_at": now}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return TransferCoinsResult{}, err
+	}
+
+	transaction := CoinTransaction{
+		Key:       arg.Key,
+		Kind:      arg.Kind,
+		Postings:  postings,
+		Memo:      arg.Memo,
+		CreatedAt: now,
+	}
+	inserted, err := q.coinTransactions.InsertOne(sessCtx, transaction)
+	if err != nil {
+		return TransferCoinsResult{}, err
+	}
+	transaction.ID = inserted.InsertedID.(primitive.ObjectID)
+	return TransferCoinsResult{Transaction: transaction}, nil
+}
+
+// TransferCoins makes a transfer in the transaction of ExecTx, or in one of
+// its own. The transaction and both balances are written together or not at all
+func (q *Queries) TransferCoins(ctx context.Context, arg TransferCoinsParams) (TransferCoinsResult, error) {
+	var result TransferCoinsResult
+	err := q.inTx(ctx, func(sessCtx mongo.SessionContext) error {
+		var err error
+		result, err = q.transferCoins(sessCtx, arg)
+		return err
+	})
+	return result, err
+}
+
+// TransferCoinsTx makes a transfer like TransferCoins, in a transaction of its
+// own, which runs again when another transfer with the same key commits first
+func (store *MongoStore) TransferCoinsTx(ctx context.Context, arg TransferCoinsParams) (TransferCoinsResult, error) {
+	var result TransferCoinsResult
+	err := store.execCoinTx(ctx, func(sessCtx mongo.SessionContext) error {
+		var err error
+		result, err = store.transferCoins(sessCtx, arg)
+		return err
+	})
+	return result, err
+}
+
+// ReverseCoinsParams undoes the transaction with the key Reverses, by moving
+// its coins back in a transaction of its own, with Key and Kind
+type ReverseCoinsParams struct {
+	Key      string `json:"key"`
+	Kind     string `json:"kind"`
+	Reverses string `json:"reverses"`
+}
+
+// Transfer is the transfer that moves the coins of reversed back. The account
+// that got them may have spent them since, it owes them then
+func (arg ReverseCoinsParams) Transfer(reversed CoinTransaction) TransferCoinsParams {
+	// A transfer has the posting of the account it takes from first
+	return TransferCoinsParams{
+		Key:    arg.Key,
+		Kind:   arg.Kind,
+		From:   reversed.Postings[1].Account,
+		To:     reversed.Postings[0].Account,
+		Amount: reversed.Postings[1].Amount,
+		Debt:   true,
+	}
+}
+
+// ReverseCoins moves the coins of a transaction back, in the transaction of
+// ExecTx or in one of its own, and is replayed by its key like a transfer.
+// It is ErrNoDocuments when there is no transaction to reverse
+func (q *Queries) ReverseCoins(ctx context.Context, arg ReverseCoinsParams) (TransferCoinsResult, error) {
+	var result TransferCoinsResult
+	err := q.inTx(ctx, func(sessCtx mongo.SessionContext) error {
+		var reversed CoinTransaction
+		err := q.coinTransactions.FindOne(sessCtx, bson.M{"key": arg.Reverses}).Decode(&reversed)
+		if err != nil {
+			return err
+		}
+		result, err = q.transferCoins(sessCtx, arg.Transfer(reversed))
+		return err
+	})
+	return result, err
+}
+
+// execCoinTx runs fn in a transaction. When another transaction with the same key
+// commits first, fn runs once more, to find it made and give it back
+func (store *MongoStore) execCoinTx(ctx context.Context, fn func(sessCtx mongo.SessionContext) error) error {
+	err := store.execTx(ctx, fn)
+	if mongo.IsDuplicateKeyError(err) {
+		err = store.execTx(ctx, fn)
+	}
+	return err
+}
+
+// GetCoinWallet gets the wallet of an account, ErrNoDocuments
+// until the account has had a transaction
+func (q *Queries) GetCoinWallet(ctx context.Context, account string) (CoinWallet, error) {
+	var wallet CoinWallet
+	err := q.coinWallets.FindOne(ctx, bson.M{"_id": account}).Decode(&wallet)
+	if err != nil {
+		return CoinWallet{}, err
+	}
+	return wallet, nil
+}
+
+type GetCoinTransactionsParams struct {
+	Account string `json:"account"`
+	Limit   int64  `json:"limit"`
+	Skip    int64  `json:"skip"`
+}
+
+// GetCoinTransactions gets a page of the transactions of an account, the latest first
+func (q *Queries) GetCoinTransactions(ctx context.Context, arg GetCoinTransactionsParams) ([]CoinTransaction, error) {
+	findOptions := options.Find().
+		SetSort(bson.D{{"created_at", -1}, {"_id", -1}}).
+		SetSkip(arg.Skip).
+		SetLimit(arg.Limit)
+	cursor, err := q.coinTransactions.Find(ctx, bson.M{"postings.account": arg.Account}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var transactions []CoinTransaction
+	if err = cursor.All(ctx, &transactions); err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+// CoinMismatch is an account whose Balance isn't the sum of its transactions
+type CoinMismatch struct {
+	Account  string `json:"account" bson:"_id"`
+	Balance  int64  `json:"balance" bson:"balance"`
+	Expected int64  `json:"expected" bson:"expected"`
+}
+
+// CoinReconciliation is what ReconcileCoins found. Unbalanced are the
+// transactions whose postings don't add up to zero, and Total is the sum
+// of every balance, zero when the books balance
+type CoinReconciliation struct {
+	Transactions int64                `json:"transactions"`
+	Unbalanced   []primitive.ObjectID `json:"unbalanced"`
+	Mismatches   []CoinMismatch       `json:"mismatches"`
+	Total        int64                `json:"total"`
+}
+
+// OK tells whether the books balance
+func (r CoinReconciliation) OK() bool {
+	return len(r.Unbalanced) == 0 && len(r.Mismatches) == 0 && r.Total == 0
+}
+
+// ReconcileCoins checks the balances against the transactions
+func (q *Queries) ReconcileCoins(ctx context.Context) (CoinReconciliation, error) {
+	var result CoinReconciliation
+	var err error
+	result.Transactions, err = q.coinTransactions.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return CoinReconciliation{}, err
+	}
+
+	cursor, err := q.coinTransactions.Aggregate(ctx, mongo.Pipeline{
+		{{"$match", bson.D{{"$expr", bson.D{{"$ne", bson.A{bson.D{{"$sum", "$postings.amount"}}, 0}}}}}}},
+		{{"$project", bson.D{{"_id", 1}}}},
+	})
+	if err != nil {
+		return CoinReconciliation{}, err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var transaction CoinTransaction
+		if err = cursor.Decode(&transaction); err != nil {
+			return CoinReconciliation{}, err
+		}
+		result.Unbalanced = append(result.Unbalanced, transaction.ID)
+	}
+	if err = cursor.Err(); err != nil {
+		return CoinReconciliation{}, err
+	}
+
+	// The sums of the postings of every account, against its wallet,
+	// including the wallets with no transactions at all
+	mismatchCursor, err := q.coinWallets.Aggregate(ctx, mongo.Pipeline{
+		{{"$unionWith", bson.D{
+			{"coll", q.coinTransactions.Name()},
+			{"pipeline", bson.A{
+				bson.D{{"$unwind", "$postings"}},
+				bson.D{{"$project", bson.D{{"_id", "$postings.account"}, {"expected", "$postings.amount"}}}},
+			}},
+		}}},
+		{{"$group", bson.D{
+			{"_id", "$_id"},
+			{"balance", bson.D{{"$sum", bson.D{{"$ifNull", bson.A{"$balance", 0}}}}}},
+			{"expected", bson.D{{"$sum", bson.D{{"$ifNull", bson.A{"$expected", 0}}}}}},
+		}}},
+		{{"$sort", bson.D{{"_id", 1}}}},
+	})
+	if err != nil {
+		return CoinReconciliation{}, err
+	}
+	defer mismatchCursor.Close(ctx)
+	for mismatchCursor.Next(ctx) {
+		var account CoinMismatch
+		if err = mismatchCursor.Decode(&account); err != nil {
+			return CoinReconciliation{}, err
+		}
+		result.Total += account.Balance
+		if account.Balance != account.Expected {
+			result.Mismatches = append(result.Mismatches, account)
+		}
+	}
+	if err = mismatchCursor.Err(); err != nil {
+		return CoinReconciliation{}, err
+	}
+	return result, nil
+}
@@ -0,0 +1,190 @@
+package db
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"phantom/util"
+	"testing"
+)
+
+// grantCoins gives a new account amount coins
+func grantCoins(t *testing.T, account string, amount int64) {
+	result, err := testStore.TransferCoinsTx(context.Background(), TransferCoinsParams{
+		Key:    CoinGrant + ":" + util.RandomString(20),
+		Kind:   CoinGrant,
+		From:   CoinAccountIssued,
+		To:     account,
+		Amount: amount,
+	})
+	require.NoError(t, err)
+	require.False(t, result.Replayed)
+}
+
+func getBalance(t *testing.T, account string) int64 {
+	wallet, err := testQueries.GetCoinWallet(context.Background(), account)
+	if err == mongo.ErrNoDocuments {
+		return 0
+	}
+	require.NoError(t, err)
+	return wallet.Balance
+}
+
+func TestTransferCoinsTx(t *testing.T) {
+	alice := UserCoinAccount(util.RandomUser())
+	bob := UserCoinAccount(util.RandomUser())
+	grantCoins(t, alice, 30)
+
+	arg := TransferCoinsParams{
+		Key:    util.RandomString(20),
+		Kind:   CoinGrant,
+		From:   alice,
+		To:     bob,
+		Amount: 20,
+		Memo:   "thanks",
+	}
+	result, err := testStore.TransferCoinsTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.False(t, result.Replayed)
+	require.NotEmpty(t, result.Transaction.ID)
+	require.Equal(t, []CoinPosting{{Account: alice, Amount: -20}, {Account: bob, Amount: 20}}, result.Transaction.Postings)
+	require.Equal(t, int64(10), getBalance(t, alice))
+	require.Equal(t, int64(20), getBalance(t, bob))
+
+	// The same transfer again is the first one
+	replayed, err := testStore.TransferCoinsTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.True(t, replayed.Replayed)
+	require.Equal(t, result.Transaction.ID, replayed.Transaction.ID)
+	require.Equal(t, int64(10), getBalance(t, alice))
+
+	other := arg
+	other.Amount = 5
+	_, err = testStore.TransferCoinsTx(context.Background(), other)
+	require.Equal(t, ErrCoinKeyReused, err)
+
+	// Nothing is written when the balance is short
+	short := arg
+	short.Key = util.RandomString(20)
+	_, err = testStore.TransferCoinsTx(context.Background(), short)
+	require.Equal(t, ErrInsufficientCoins, err)
+	require.Equal(t, int64(10), getBalance(t, alice))
+	require.Equal(t, int64(20), getBalance(t, bob))
+
+	_, err = testStore.TransferCoinsTx(context.Background(), TransferCoinsParams{Key: util.RandomString(20), From: bob, To: alice})
+	require.Error(t, err)
+
+	transactions, err := testQueries.GetCoinTransactions(context.Background(), GetCoinTransactionsParams{Account: alice, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, transactions, 2)
+	require.Equal(t, result.Transaction.ID, transactions[0].ID)
+}
+
+func TestTransferCoinsTxConcurrent(t *testing.T) {
+	alice := UserCoinAccount(util.RandomUser())
+	grantCoins(t, alice, 50)
+
+	// Ten spends of 10 race for 50 coins, five of them make it
+	n := 10
+	errs := make(chan error)
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := testStore.TransferCoinsTx(context.Background(), TransferCoinsParams{
+				Key:    util.RandomString(20),
+				Kind:   CoinRequestTitle,
+				From:   alice,
+				To:     CoinAccountSpent,
+				Amount: 10,
+			})
+			errs <- err
+		}()
+	}
+	spent := 0
+	for i := 0; i < n; i++ {
+		err := <-errs
+		if err == nil {
+			spent++
+			continue
+		}
+		require.Equal(t, ErrInsufficientCoins, err)
+	}
+	require.Equal(t, 5, spent)
+	require.Zero(t, getBalance(t, alice))
+}
+
+func TestPinDanmakuTx(t *testing.T) {
+	name := util.RandomUser()
+	grantCoins(t, UserCoinAccount(name), 15)
+	danmaku := addDanmaku(t, AddDanmakuParams{MovieID: addMovie(t, randomMovie()), Text: util.RandomString(10), Name: name})
+
+	arg := PinDanmakuTxParams{DanmakuID: danmaku.ID, Name: name, Cost: 10}
+	result, err := testStore.PinDanmakuTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.True(t, result.Danmaku.Pinned)
+	require.Equal(t, CoinPinDanmaku, result.Transaction.Kind)
+	require.Equal(t, int64(5), getBalance(t, UserCoinAccount(name)))
+
+	_, err = testStore.PinDanmakuTx(context.Background(), arg)
+	require.Equal(t, ErrDanmakuPinned, err)
+
+	// Without the coins it isn't pinned
+	poor := addDanmaku(t, AddDanmakuParams{MovieID: danmaku.MovieID, Text: util.RandomString(10), Name: name})
+	arg.DanmakuID = poor.ID
+	_, err = testStore.PinDanmakuTx(context.Background(), arg)
+	require.Equal(t, ErrInsufficientCoins, err)
+	got, err := testQueries.GetDanmaku(context.Background(), GetDanmakuParams{MovieID: danmaku.MovieID, To: 1000, Bucket: 1000, PerBucket: 1})
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	// and the pinned one shows first
+	require.Equal(t, danmaku.ID, got[0].ID)
+
+	arg.DanmakuID = addMovie(t, randomMovie())
+	_, err = testStore.PinDanmakuTx(context.Background(), arg)
+	require.Equal(t, mongo.ErrNoDocuments, err)
+}
+
+func TestRequestTitleTx(t *testing.T) {
+	name := util.RandomUser()
+	grantCoins(t, UserCoinAccount(name), 25)
+
+	arg := RequestTitleTxParams{Key: util.RandomString(20), Name: name, Title: util.RandomString(12), Year: 2024, Cost: 20}
+	request, err := testStore.RequestTitleTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotEmpty(t, request.ID)
+	require.Equal(t, arg.Title, request.Title)
+
+	again, err := testStore.RequestTitleTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, request.ID, again.ID)
+	require.Equal(t, int64(5), getBalance(t, UserCoinAccount(name)))
+
+	arg.Key = util.RandomString(20)
+	_, err = testStore.RequestTitleTx(context.Background(), arg)
+	require.Equal(t, ErrInsufficientCoins, err)
+
+	requests, err := testQueries.GetTitleRequests(context.Background(), GetTitleRequestsParams{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, requests, 1)
+}
+
+func TestReconcileCoins(t *testing.T) {
+	alice := UserCoinAccount(util.RandomUser())
+	grantCoins(t, alice, 40)
+
+	result, err := testQueries.ReconcileCoins(context.Background())
+	require.NoError(t, err)
+	require.True(t, result.OK(), "%+v", result)
+	require.NotZero(t, result.Transactions)
+
+	// A balance changed behind the transactions
+	_, err = testQueries.coinWallets.UpdateOne(context.Background(), bson.M{"_id": alice}, bson.M{"$inc": bson.M{"balance": 100}})
+	require.NoError(t, err)
+	defer testQueries.coinWallets.UpdateOne(context.Background(), bson.M{"_id": alice}, bson.M{"$inc": bson.M{"balance": -100}})
+
+	result, err = testQueries.ReconcileCoins(context.Background())
+	require.NoError(t, err)
+	require.False(t, result.OK())
+	require.Equal(t, []CoinMismatch{{Account: alice, Balance: 140, Expected: 40}}, result.Mismatches)
+	require.Equal(t, int64(100), result.Total)
+}
@@ -0,0 +1,305 @@
+package db
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The orders the movies of a smart collection can be in
+const (
+	CollectionSortYear   = "year"
+	CollectionSortRating = "rating"
+	CollectionSortTitle  = "title"
+)
+
+// Collection groups related movies, such as a franchise.
+// A curated collection lists its movies in MovieIDs, in order,
+// a smart collection has a Filter that is evaluated when it is read
+type Collection struct {
+	ID          primitive.ObjectID   `json:"_id" bson:"_id,omitempty"`
+	Title       string               `json:"title" bson:"title"`
+	Poster      string               `json:"poster" bson:"poster,omitempty"`
+	Description string               `json:"description" bson:"description,omitempty"`
+	MovieIDs    []primitive.ObjectID `json:"movie_ids" bson:"movie_ids,omitempty"`
+	Filter      *CollectionFilter    `json:"filter" bson:"filter,omitempty"`
+}
+
+// Smart tells whether the movies of the collection are chosen by a filter
+func (c Collection) Smart() bool {
+	return c.Filter != nil
+}
+
+// CollectionFilter chooses the movies of a smart collection. A movie matches
+// when it has all of Genres, any of each other list, and is within the
+// year and rating bounds that are set
+type CollectionFilter struct {
+	Genres    []string `json:"genres" bson:"genres,omitempty"`
+	Cast      []string `json:"cast" bson:"cast,omitempty"`
+	Directors []string `json:"directors" bson:"directors,omitempty"`
+	Writers   []string `json:"writers" bson:"writers,omitempty"`
+	Countries []string `json:"countries" bson:"countries,omitempty"`
+	Type      string   `json:"type" bson:"type,omitempty"`
+	YearFrom  int64    `json:"year_from" bson:"year_from,omitempty"`
+	YearTo    int64    `json:"year_to" bson:"year_to,omitempty"`
+	MinRating float64  `json:"min_rating" bson:"min_rating,omitempty"`
+	Sort      string   `json:"sort" bson:"sort,omitempty"`
+}
+
+// query is the filter as a query on the 'movies' collection
+func (f CollectionFilter) query() bson.D {
+	query := bson.D{}
+	if len(f.Genres) > 0 {
+		query = append(query, bson.E{"genres", bson.M{"$all": f.Genres}})
+	}
+	for _, field := range []struct {
+		name   string
+		values []string
+	}{
+		{"cast", f.Cast},
+		{"directors", f.Directors},
+		{"writers", f.Writers},
+		{"countries", f.Countries},
+	} {
+		if len(field.values) > 0 {
+			query = append(query, bson.E{field.name, bson.M{"$in": field.values}})
+		}
+	}
+	if f.Type != "" {
+		query = append(query, bson.E{"type", f.Type})
+	}
+	year := bson.M{}
+	if f.YearFrom > 0 {
+		year["$gte"] = f.YearFrom
+	}
+	if f.YearTo > 0 {
+		year["$lte"] = f.YearTo
+	}
+	if len(year) > 0 {
+		query = append(query, bson.E{"year", year})
+	}
+	if f.MinRating > 0 {
+		query = append(query, bson.E{"imdb.rating", bson.M{"$gte": f.MinRating}})
+	}
+	return query
+}
+
+// sort is the order of the movies that match the filter, by year unless set
+func (f CollectionFilter) sort() bson.D {
+	switch f.Sort {
+	case CollectionSortRating:
+		return bson.D{{"imdb.rating", -1}, {"_id", 1}}
+	case CollectionSortTitle:
+		return bson.D{{"sort_title", 1}, {"_id", 1}}
+	}
+	return bson.D{{"year", 1}, {"_id", 1}}
+}
+
+// Matches tells whether a movie is chosen by the filter, the same way query does
+func (f CollectionFilter) Matches(movie Movies) bool {
+	for _, genre := range f.Genres {
+		if !containsString(movie.Genres, genre) {
+			return false
+		}
+	}
+	for _, field := range []struct {
+		want []string
+		have []string
+	}{
+		{f.Cast, movie.Cast},
+		{f.Directors, movie.Directors},
+		{f.Writers, movie.Writers},
+		{f.Countries, movie.Countries},
+	} {
+		if len(field.want) > 0 && !containsAny(field.have, field.want) {
+			return false
+		}
+	}
+	if f.Type != "" && f.Type != movie.Type {
+		return false
+	}
+	if f.YearFrom > 0 && movie.Year < f.YearFrom {
+		return false
+	}
+	if f.YearTo > 0 && movie.Year > f.YearTo {
+		return false
+	}
+	return f.MinRating <= 0 || movie.Imdb.Rating >= f.MinRating
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAny(values []string, wanted []string) bool {
+	for _, want := range wanted {
+		if containsString(values, want) {
+			return true
+		}
+	}
+	return false
+}
+
+type AddCollectionParams struct {
+	Title       string               `json:"title" bson:"title"`
+	Poster      string               `json:"poster" bson:"poster,omitempty"`
+	Description string               `json:"description" bson:"description,omitempty"`
+	MovieIDs    []primitive.ObjectID `json:"movie_ids" bson:"movie_ids,omitempty"`
+	Filter      *CollectionFilter    `json:"filter" bson:"filter,omitempty"`
+}
+
+type GetCollectionsParams struct {
+	Skip  int64 `json:"skip"`
+	Limit int64 `json:"limit"`
+}
+
+type GetCollectionMoviesParams struct {
+	Collection Collection `json:"collection"`
+	Skip       int64      `json:"skip"`
+	Limit      int64      `json:"limit"`
+}
+
+func (q *Queries) AddCollection(ctx context.Context, arg AddCollectionParams) (primitive.ObjectID, error) {
+	res, err := q.collections.InsertOne(ctx, arg)
+	if err != nil {
+		return primitive.ObjectID{}, err
+	}
+	return res.InsertedID.(primitive.ObjectID), nil
+}
+
+func (q *Queries) GetCollectionByID(ctx context.Context, id primitive.ObjectID) (Collection, error) {
+	var collection Collection
+	err := q.collections.FindOne(ctx, bson.M{"_id": id}).Decode(&collection)
+	if err != nil {
+		return Collection{}, err
+	}
+	return collection, nil
+}
+
+// GetCollections gets a page of collections by title
+func (q *Queries) GetCollections(ctx context.Context, arg GetCollectionsParams) ([]Collection, error) {
+	findOptions := options.Find().
+		SetSort(bson.D{{"title", 1}, {"_id", 1}}).
+		SetSkip(arg.Skip).
+		SetLimit(arg.Limit)
+	cursor, err := q.collections.Find(ctx, bson.M{}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var collections []Collection
+	if err = cursor.All(ctx, &collections); err != nil {
+		return nil, err
+	}
+	return collections, nil
+}
+
+// GetCollectionsByMovie gets the curated collections that list the movie
+// and the smart collections whose filter matches it
+func (q *Queries) GetCollectionsByMovie(ctx context.Context, movie Movies) ([]Collection, error) {
+	cursor, err := q.collections.Find(ctx,
+		bson.M{"$or": bson.A{
+			bson.M{"movie_ids": movie.Id},
+			bson.M{"filter": bson.M{"$exists": true}},
+		}},
+		options.Find().SetSort(bson.D{{"title", 1}, {"_id", 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var collections []Collection
+	for cursor.Next(ctx) {
+		var collection Collection
+		if err = cursor.Decode(&collection); err != nil {
+			return nil, err
+		}
+		if collection.Smart() && !collection.Filter.Matches(movie) {
+			continue
+		}
+		collections = append(collections, collection)
+	}
+	return collections, cursor.Err()
+}
+
+// GetCollectionMovies gets a page of the movies of a collection,
+// in the order of a curated collection or the sort of a smart one
+func (q *Queries) GetCollectionMovies(ctx context.Context, arg GetCollectionMoviesParams) ([]Movies, error) {
+	if arg.Collection.Smart() {
+		filter := arg.Collection.Filter
+		findOptions := options.Find().
+			SetSort(filter.sort()).
+			SetSkip(arg.Skip).
+			SetLimit(arg.Limit).
+			SetProjection(projectStage()[0].Value)
+		cursor, err := q.movies.Find(ctx, filter.query(), findOptions)
+		if err != nil {
+			return nil, err
+		}
+		defer cursor.Close(ctx)
+
+		var movies []Movies
+		if err = cursor.All(ctx, &movies); err != nil {
+			return nil, err
+		}
+		return movies, nil
+	}
+
+	ids := arg.Collection.MovieIDs
+	if arg.Skip >= int64(len(ids)) {
+		return nil, nil
+	}
+	ids = ids[arg.Skip:]
+	if arg.Limit > 0 && arg.Limit < int64(len(ids)) {
+		ids = ids[:arg.Limit]
+	}
+	found, err := q.GetMoviesByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[primitive.ObjectID]Movies, len(found))
+	for _, movie := range found {
+		byID[movie.Id] = movie
+	}
+	// Movies removed from the catalog are left out
+	var movies []Movies
+	for _, id := range ids {
+		if movie, ok := byID[id]; ok {
+			movies = append(movies, movie)
+		}
+	}
+	return movies, nil
+}
+
+// ReplaceCollection replaces a collection, it can turn a curated collection
+// into a smart one and back
+func (q *Queries) ReplaceCollection(ctx context.Context, collection Collection) (*mongo.UpdateResult, error) {
+	res, err := q.collections.ReplaceOne(ctx, bson.M{"_id": collection.ID}, collection)
+	if err != nil {
+		return nil, err
+	}
+	if res.MatchedCount == 0 {
+		return nil, mongo.ErrNoDocuments
+	}
+	return res, nil
+}
+
+func (q *Queries) DeleteCollection(ctx context.Context, id primitive.ObjectID) (int64, error) {
+	res, err := q.collections.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return 0, err
+	}
+	if res.DeletedCount == 0 {
+		return 0, mongo.ErrNoDocuments
+	}
+	return res.DeletedCount, nil
+}
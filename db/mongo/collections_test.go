@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"phantom/util"
+	"testing"
+)
+
+func TestCollectionFilterMatches(t *testing.T) {
+	movie := Movies{
+		Genres:    []string{"Animation", "Fantasy"},
+		Directors: []string{"Hayao Miyazaki"},
+		Year:      1988,
+	}
+	movie.Imdb.Rating = 8.1
+
+	require.True(t, CollectionFilter{}.Matches(movie))
+	require.True(t, CollectionFilter{
+		Genres:    []string{"Animation"},
+		Directors: []string{"Isao Takahata", "Hayao Miyazaki"},
+		YearFrom:  1984,
+		YearTo:    1997,
+		MinRating: 7.5,
+	}.Matches(movie))
+	require.False(t, CollectionFilter{Genres: []string{"Animation", "Drama"}}.Matches(movie))
+	require.False(t, CollectionFilter{Cast: []string{"Chieko Baishô"}}.Matches(movie))
+	require.False(t, CollectionFilter{YearFrom: 1990}.Matches(movie))
+	require.False(t, CollectionFilter{MinRating: 8.5}.Matches(movie))
+	require.False(t, CollectionFilter{Type: TypeSeries}.Matches(movie))
+}
+
+func TestCuratedCollection(t *testing.T) {
+	first := addMovie(t, randomMovie())
+	second := addMovie(t, randomMovie())
+	removed := primitive.NewObjectID()
+
+	id, err := testQueries.AddCollection(context.Background(), AddCollectionParams{
+		Title:    util.RandomString(10),
+		MovieIDs: []primitive.ObjectID{second, removed, first},
+	})
+	require.NoError(t, err)
+	collection, err := testQueries.GetCollectionByID(context.Background(), id)
+	require.NoError(t, err)
+	require.False(t, collection.Smart())
+
+	movies, err := testQueries.GetCollectionMovies(context.Background(), GetCollectionMoviesParams{Collection: collection, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, movies, 2)
+	require.Equal(t, second, movies[0].Id)
+	require.Equal(t, first, movies[1].Id)
+
+	movies, err = testQueries.GetCollectionMovies(context.Background(), GetCollectionMoviesParams{Collection: collection, Skip: 2, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, movies, 1)
+	require.Equal(t, first, movies[0].Id)
+
+	collections, err := testQueries.GetCollectionsByMovie(context.Background(), getMovieByID(t, first))
+	require.NoError(t, err)
+	requireCollection(t, id, collections)
+
+	_, err = testQueries.DeleteCollection(context.Background(), id)
+	require.NoError(t, err)
+	_, err = testQueries.DeleteCollection(context.Background(), id)
+	require.ErrorIs(t, err, mongo.ErrNoDocuments)
+}
+
+func TestSmartCollection(t *testing.T) {
+	director := util.RandomString(12)
+	var ids []primitive.ObjectID
+	for _, year := range []int64{1997, 1984, 2001} {
+		movie := randomMovie()
+		movie.Directors = []string{director}
+		movie.Year = year
+		ids = append(ids, addMovie(t, movie))
+	}
+
+	filter := &CollectionFilter{Directors: []string{director}, YearFrom: 1984, YearTo: 1997}
+	id, err := testQueries.AddCollection(context.Background(), AddCollectionParams{Title: util.RandomString(10), Filter: filter})
+	require.NoError(t, err)
+	collection, err := testQueries.GetCollectionByID(context.Background(), id)
+	require.NoError(t, err)
+	require.True(t, collection.Smart())
+	require.Equal(t, filter, collection.Filter)
+
+	movies, err := testQueries.GetCollectionMovies(context.Background(), GetCollectionMoviesParams{Collection: collection, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, movies, 2)
+	require.Equal(t, ids[1], movies[0].Id)
+	require.Equal(t, ids[0], movies[1].Id)
+
+	collections, err := testQueries.GetCollectionsByMovie(context.Background(), getMovieByID(t, ids[0]))
+	require.NoError(t, err)
+	requireCollection(t, id, collections)
+	collections, err = testQueries.GetCollectionsByMovie(context.Background(), getMovieByID(t, ids[2]))
+	require.NoError(t, err)
+	for _, c := range collections {
+		require.NotEqual(t, id, c.ID)
+	}
+
+	// A smart collection can become a curated one
+	collection.Filter = nil
+	collection.MovieIDs = []primitive.ObjectID{ids[2]}
+	_, err = testQueries.ReplaceCollection(context.Background(), collection)
+	require.NoError(t, err)
+	collections, err = testQueries.GetCollectionsByMovie(context.Background(), getMovieByID(t, ids[2]))
+	require.NoError(t, err)
+	requireCollection(t, id, collections)
+}
+
+func requireCollection(t *testing.T, id primitive.ObjectID, collections []Collection) {
+	for _, collection := range collections {
+		if collection.ID == id {
+			return
+		}
+	}
+	require.Fail(t, "the collection is not found", id.Hex())
+}
@@ -6,37 +6,99 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"phantom/ledger"
+	"time"
 )
 
+// The orders comments can be listed in, natural order when none is given
+const (
+	CommentSortTop = "top"
+	CommentSortNew = "new"
+	CommentSortOld = "old"
+)
+
+// Comments is a comment on a movie, or a reply to another comment when ParentID
+// is set. Depth counts the comments above it, top-level comments are at 0.
+// A deleted comment that has replies is kept, Deleted and without its text,
+// so that the thread stays readable. Reactions counts the reactions to
+// the comment by kind, and ReactionCount all of them. Reports and ReportCount
+// count the reports the same way, until a moderator reviews the comment.
+// A Hidden comment was reported too many times
 type Comments struct {
-	ID      primitive.ObjectID `json:"_id" bson:"_id"`
-	Name    string             `json:"name" bson:"name"`
-	Email   string             `json:"email" bson:"email"`
-	MovieID primitive.ObjectID `json:"movie_id" bson:"movie_id"`
-	Text    string             `json:"text" bson:"text"`
-	Date    primitive.DateTime `json:"date" bson:"date"`
+	ID       primitive.ObjectID `json:"_id" bson:"_id"`
+	Name     string             `json:"name" bson:"name"`
+	Email    string             `json:"email" bson:"email"`
+	MovieID  primitive.ObjectID `json:"movie_id" bson:"movie_id"`
+	ParentID primitive.ObjectID `json:"parent_id" bson:"parent_id,omitempty"`
+	Depth    int64              `json:"depth" bson:"depth,omitempty"`
+	Text     string             `json:"text" bson:"text"`
+	Deleted  bool               `json:"deleted" bson:"deleted,omitempty"`
+	Date     primitive.DateTime `json:"date" bson:"date"`
+
+	Reactions     map[string]int64 `json:"reactions" bson:"reactions,omitempty"`
+	ReactionCount int64            `json:"reaction_count" bson:"reaction_count,omitempty"`
+
+	Reports     map[string]int64 `json:"reports" bson:"reports,omitempty"`
+	ReportCount int64            `json:"report_count" bson:"report_count,omitempty"`
+	Hidden      bool             `json:"hidden" bson:"hidden,omitempty"`
+	// Flags are the reasons the content filter put the comment in the moderation queue
+	Flags []string `json:"flags" bson:"flags,omitempty"`
 }
 
 type AddCommentParams struct {
-	Name    string             `json:"name" bson:"name,omitempty"`
-	Email   string             `json:"email" bson:"email,omitempty"`
-	MovieID primitive.ObjectID `json:"movie_id" bson:"movie_id,omitempty"`
-	Text    string             `json:"text" bson:"text,omitempty"`
+	Name     string             `json:"name" bson:"name,omitempty"`
+	Email    string             `json:"email" bson:"email,omitempty"`
+	MovieID  primitive.ObjectID `json:"movie_id" bson:"movie_id,omitempty"`
+	ParentID primitive.ObjectID `json:"parent_id" bson:"parent_id,omitempty"`
+	Depth    int64              `json:"depth" bson:"depth,omitempty"`
+	Text     string             `json:"text" bson:"text,omitempty"`
+	Flags    []string           `json:"flags" bson:"flags,omitempty"`
 }
 
+// GetCommentsParams gets the comments of a movie or of a user.
+// TopLevel leaves out the replies, Sort is one of the CommentSort orders
 type GetCommentsParams struct {
-	Name    string             `json:"name" bson:"name"`
-	MovieID primitive.ObjectID `json:"movie_id" bson:"movie_id,omitempty"`
-	Limit   int64              `json:"limit"`
-	Skip    int64              `json:"skip"`
+	Name     string             `json:"name" bson:"name"`
+	MovieID  primitive.ObjectID `json:"movie_id" bson:"movie_id,omitempty"`
+	TopLevel bool               `json:"top_level"`
+	Sort     string             `json:"sort"`
+	Limit    int64              `json:"limit"`
+	Skip     int64              `json:"skip"`
 }
 
+type GetRepliesParams struct {
+	ParentID primitive.ObjectID `json:"parent_id"`
+	Limit    int64              `json:"limit"`
+	Skip     int64              `json:"skip"`
+}
+
+// ReplySummary is how many replies a comment has and the first of them
+type ReplySummary struct {
+	ParentID primitive.ObjectID `json:"parent_id" bson:"_id"`
+	Count    int64              `json:"count" bson:"count"`
+	Replies  []Comments         `json:"replies" bson:"replies"`
+}
+
+// AddComment adds a comment dated now, and records it in the ledger in the
+// same transaction, the one of ExecTx or one of its own
 func (q *Queries) AddComment(ctx context.Context, arg AddCommentParams) (primitive.ObjectID, error) {
-	res, err := q.comments.InsertOne(ctx, arg)
+	var id primitive.ObjectID
+	err := q.inTx(ctx, func(sessCtx mongo.SessionContext) error {
+		res, err := q.comments.InsertOne(sessCtx, struct {
+			AddCommentParams `bson:",inline"`
+			Date             primitive.DateTime `bson:"date"`
+		}{arg, primitive.NewDateTimeFromTime(time.Now())})
+		if err != nil {
+			return err
+		}
+		id = res.InsertedID.(primitive.ObjectID)
+		digest := CommentDigest(Comments{ID: id, Name: arg.Name, MovieID: arg.MovieID, ParentID: arg.ParentID, Text: arg.Text})
+		return q.appendLedger(sessCtx, LedgerComment, ledger.OpAdd, id, digest)
+	})
 	if err != nil {
 		return primitive.ObjectID{}, err
 	}
-	return res.InsertedID.(primitive.ObjectID), nil
+	return id, nil
 }
 
 func (q Queries) GetComment(ctx context.Context, id primitive.ObjectID) (Comments, error) {
@@ -48,14 +110,41 @@ func (q Queries) GetComment(ctx context.Context, id primitive.ObjectID) (Comment
 	return comment, nil
 }
 
+// GetAllComments gets every comment but the deleted and hidden ones,
+// it is used to build the embedded search index
+func (q *Queries) GetAllComments(ctx context.Context) ([]Comments, error) {
+	cursor, err := q.comments.Find(ctx, bson.M{"deleted": bson.M{"$ne": true}, "hidden": bson.M{"$ne": true}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var comments []Comments
+	if err = cursor.All(ctx, &comments); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
 func (q Queries) GetCommentsByMovieID(ctx context.Context, arg GetCommentsParams) ([]Comments, error) {
-	var findOptions *options.FindOptions
+	findOptions := &options.FindOptions{}
 	if arg.Limit > 0 {
-		findOptions = &options.FindOptions{}
 		findOptions.SetLimit(arg.Limit)
 		findOptions.SetSkip(arg.Skip)
 	}
-	cursor, err := q.comments.Find(ctx, bson.M{"movie_id": arg.MovieID}, findOptions)
+	switch arg.Sort {
+	case CommentSortTop:
+		findOptions.SetSort(bson.D{{"reaction_count", -1}, {"date", -1}, {"_id", -1}})
+	case CommentSortNew:
+		findOptions.SetSort(bson.D{{"date", -1}, {"_id", -1}})
+	case CommentSortOld:
+		findOptions.SetSort(bson.D{{"date", 1}, {"_id", 1}})
+	}
+	filter := bson.M{"movie_id": arg.MovieID}
+	if arg.TopLevel {
+		filter["parent_id"] = bson.M{"$exists": false}
+	}
+	cursor, err := q.comments.Find(ctx, filter, findOptions)
 	defer cursor.Close(ctx)
 	if err != nil {
 		return nil, err
@@ -75,7 +164,7 @@ func (q Queries) GetCommentsByName(ctx context.Context, arg GetCommentsParams) (
 		findOptions.SetLimit(arg.Limit)
 		findOptions.SetSkip(arg.Skip)
 	}
-	cursor, err := q.comments.Find(ctx, bson.M{"name": arg.Name}, findOptions)
+	cursor, err := q.comments.Find(ctx, bson.M{"name": arg.Name, "deleted": bson.M{"$ne": true}}, findOptions)
 	defer cursor.Close(ctx)
 	if err != nil {
 		return nil, err
@@ -88,36 +177,196 @@ func (q Queries) GetCommentsByName(ctx context.Context, arg GetCommentsParams) (
 	return comments, nil
 }
 
+// UpdateComment changes the text of a comment of a user, and adds
+// the Flags of the comment to the ones it had. The edit is recorded in the ledger
 func (q Queries) UpdateComment(ctx context.Context, comment Comments) (*mongo.UpdateResult, error) {
-	res, err := q.comments.UpdateOne(ctx,
-		bson.D{
-			{"_id", comment.ID},
-			{"name", comment.Name},
+	update := bson.D{
+		{
+			"$set", bson.D{
+				{"text", comment.Text},
+			},
 		},
-		bson.D{
-			{
-				"$set", bson.D{
-					{"text", comment.Text},
-				},
+	}
+	if len(comment.Flags) > 0 {
+		update = append(update, bson.E{"$addToSet", bson.D{{"flags", bson.D{{"$each", comment.Flags}}}}})
+	}
+	var res *mongo.UpdateResult
+	err := q.inTx(ctx, func(sessCtx mongo.SessionContext) error {
+		var err error
+		res, err = q.comments.UpdateOne(sessCtx,
+			bson.D{
+				{"_id", comment.ID},
+				{"name", comment.Name},
+				{"deleted", bson.M{"$ne": true}},
 			},
-		})
+			update)
+		if err != nil {
+			return err
+		}
+		if res.ModifiedCount == 0 {
+			return mongo.ErrNoDocuments
+		}
+
+		edited, err := q.GetComment(sessCtx, comment.ID)
+		if err != nil {
+			return err
+		}
+		return q.appendLedger(sessCtx, LedgerComment, ledger.OpEdit, comment.ID, CommentDigest(edited))
+	})
 	if err != nil {
 		return nil, err
 	}
-	if res.ModifiedCount == 0 {
-		return nil, mongo.ErrNoDocuments
-	}
 	return res, nil
 }
 
+// DeleteComment deletes a comment of a user. A comment with replies is only
+// marked Deleted and loses its text and email, so that its replies keep their place.
+// Such a comment is deleted already, deleting it again is no document
 func (q *Queries) DeleteComment(ctx context.Context, id primitive.ObjectID, name string) (int64, error) {
-	deleteResult, err := q.comments.DeleteOne(ctx, bson.D{{"_id", id}, {"name", name}})
+	return q.deleteComment(ctx, id, bson.D{{"_id", id}, {"name", name}, {"deleted", bson.M{"$ne": true}}})
+}
+
+// RemoveComment deletes a comment on behalf of a moderator, like DeleteComment
+func (q *Queries) RemoveComment(ctx context.Context, id primitive.ObjectID) (int64, error) {
+	return q.deleteComment(ctx, id, bson.D{{"_id", id}, {"deleted", bson.M{"$ne": true}}})
+}
+
+// deleteComment deletes the comment with the id if filter matches it,
+// and records the deletion in the ledger in the same transaction
+func (q *Queries) deleteComment(ctx context.Context, id primitive.ObjectID, filter bson.D) (int64, error) {
+	var deleted int64
+	err := q.inTx(ctx, func(sessCtx mongo.SessionContext) error {
+		replies, err := q.comments.CountDocuments(sessCtx, bson.M{"parent_id": id}, options.Count().SetLimit(1))
+		if err != nil {
+			return err
+		}
+		if replies > 0 {
+			res, err := q.comments.UpdateOne(sessCtx, filter,
+				bson.D{
+					{"$set", bson.D{{"deleted", true}, {"text", ""}}},
+					{"$unset", bson.D{{"email", ""}}},
+				})
+			if err != nil {
+				return err
+			}
+			if res.ModifiedCount == 0 {
+				return mongo.ErrNoDocuments
+			}
+			deleted = res.ModifiedCount
+			return q.appendLedger(sessCtx, LedgerComment, ledger.OpDelete, id, ledger.Hash{})
+		}
+
+		res, err := q.comments.DeleteOne(sessCtx, filter)
+		if err != nil {
+			return err
+		}
+		if res.DeletedCount == 0 {
+			return mongo.ErrNoDocuments
+		}
+		if _, err = q.reactions.DeleteMany(sessCtx, bson.M{"comment_id": id}); err != nil {
+			return err
+		}
+		if _, err = q.reports.DeleteMany(sessCtx, bson.M{"comment_id": id}); err != nil {
+			return err
+		}
+		deleted = res.DeletedCount
+		return q.appendLedger(sessCtx, LedgerComment, ledger.OpDelete, id, ledger.Hash{})
+	})
 	if err != nil {
 		return 0, err
 	}
-	if deleteResult.DeletedCount == 0 {
-		return 0, mongo.ErrNoDocuments
+	return deleted, nil
+}
+
+// DeleteCommentsByMovieID deletes every comment on a movie, with their reactions
+// and reports, and gives the ids of the comments it deleted
+func (q *Queries) DeleteCommentsByMovieID(ctx context.Context, movieID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	cursor, err := q.comments.Find(ctx, bson.M{"movie_id": movieID},
+		options.Find().SetProjection(bson.D{{"_id", 1}, {"deleted", 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var comments []Comments
+	if err = cursor.All(ctx, &comments); err != nil {
+		return nil, err
+	}
+	if len(comments) == 0 {
+		return nil, nil
 	}
-	return deleteResult.DeletedCount, nil
 
+	ids := make([]primitive.ObjectID, len(comments))
+	for i, comment := range comments {
+		ids[i] = comment.ID
+	}
+	err = q.inTx(ctx, func(sessCtx mongo.SessionContext) error {
+		if _, err := q.comments.DeleteMany(sessCtx, bson.M{"_id": bson.M{"$in": ids}}); err != nil {
+			return err
+		}
+		if _, err := q.reactions.DeleteMany(sessCtx, bson.M{"comment_id": bson.M{"$in": ids}}); err != nil {
+			return err
+		}
+		if _, err := q.reports.DeleteMany(sessCtx, bson.M{"comment_id": bson.M{"$in": ids}}); err != nil {
+			return err
+		}
+		// The comments that were kept for their replies are in the ledger as deleted already
+		for _, comment := range comments {
+			if comment.Deleted {
+				continue
+			}
+			if err := q.appendLedger(sessCtx, LedgerComment, ledger.OpDelete, comment.ID, ledger.Hash{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// GetReplies gets a page of the replies to a comment, the oldest first
+func (q *Queries) GetReplies(ctx context.Context, arg GetRepliesParams) ([]Comments, error) {
+	findOptions := options.Find().
+		SetSort(bson.D{{"_id", 1}}).
+		SetSkip(arg.Skip).
+		SetLimit(arg.Limit)
+	cursor, err := q.comments.Find(ctx, bson.M{"parent_id": arg.ParentID}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var comments []Comments
+	if err = cursor.All(ctx, &comments); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// GetReplySummaries counts the replies to each of the comments and gets
+// the first n of them, the comments without replies are left out
+func (q *Queries) GetReplySummaries(ctx context.Context, parentIDs []primitive.ObjectID, n int64) ([]ReplySummary, error) {
+	pipeline := mongo.Pipeline{
+		{{"$match", bson.D{{"parent_id", bson.M{"$in": parentIDs}}}}},
+		{{"$sort", bson.D{{"_id", 1}}}},
+		{{"$group", bson.D{
+			{"_id", "$parent_id"},
+			{"count", bson.D{{"$sum", 1}}},
+			{"replies", bson.D{{"$push", "$$ROOT"}}},
+		}}},
+		{{"$set", bson.D{{"replies", bson.D{{"$slice", bson.A{"$replies", n}}}}}}},
+	}
+	cursor, err := q.comments.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var summaries []ReplySummary
+	if err = cursor.All(ctx, &summaries); err != nil {
+		return nil, err
+	}
+	return summaries, nil
 }
@@ -7,6 +7,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"phantom/util"
 	"testing"
+	"time"
 )
 
 func randomComment(user User, movie Movies) AddCommentParams {
@@ -45,6 +46,22 @@ func TestGetComment(t *testing.T) {
 	getCommentByID(t, commentId)
 }
 
+func TestGetAllComments(t *testing.T) {
+	user := getUserByID(t, addUser(t, randomUser()))
+	movie := getMovieByID(t, addMovie(t, randomMovie()))
+	id := addComment(t, user, movie)
+
+	comments, err := testQueries.GetAllComments(context.Background())
+	require.NoError(t, err)
+	found := false
+	for i := range comments {
+		if comments[i].ID == id {
+			found = true
+		}
+	}
+	require.True(t, found)
+}
+
 func TestGetCommentsByMovieID(t *testing.T) {
 	n := 10
 	movie := getMovieByID(t, addMovie(t, randomMovie()))
@@ -129,3 +146,173 @@ func TestDeleteComment(t *testing.T) {
 	_, err = testQueries.DeleteComment(context.Background(), comment1.ID, comment1.Name)
 	require.Equal(t, mongo.ErrNoDocuments, err)
 }
+
+func addReply(t *testing.T, user User, parent Comments) Comments {
+	arg := AddCommentParams{
+		Name:     user.Name,
+		MovieID:  parent.MovieID,
+		ParentID: parent.ID,
+		Depth:    parent.Depth + 1,
+		Text:     util.RandomString(140),
+	}
+	id, err := testQueries.AddComment(context.Background(), arg)
+	require.NoError(t, err)
+	return getCommentByID(t, id)
+}
+
+func TestGetReplies(t *testing.T) {
+	user := getUserByID(t, addUser(t, randomUser()))
+	movie := getMovieByID(t, addMovie(t, randomMovie()))
+	parent := getCommentByID(t, addComment(t, user, movie))
+	other := getCommentByID(t, addComment(t, user, movie))
+	var replies []Comments
+	for i := 0; i < 4; i++ {
+		replies = append(replies, addReply(t, user, parent))
+	}
+	nested := addReply(t, user, replies[0])
+	require.Equal(t, int64(2), nested.Depth)
+
+	page, err := testQueries.GetReplies(context.Background(), GetRepliesParams{ParentID: parent.ID, Skip: 1, Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	require.Equal(t, replies[1].ID, page[0].ID)
+	require.Equal(t, replies[2].ID, page[1].ID)
+
+	topLevel, err := testQueries.GetCommentsByMovieID(context.Background(), GetCommentsParams{MovieID: movie.Id, TopLevel: true})
+	require.NoError(t, err)
+	require.Len(t, topLevel, 2)
+
+	summaries, err := testQueries.GetReplySummaries(context.Background(), []primitive.ObjectID{parent.ID, other.ID, replies[0].ID}, 3)
+	require.NoError(t, err)
+	require.Len(t, summaries, 2)
+	for _, summary := range summaries {
+		switch summary.ParentID {
+		case parent.ID:
+			require.Equal(t, int64(4), summary.Count)
+			require.Len(t, summary.Replies, 3)
+			require.Equal(t, replies[0].ID, summary.Replies[0].ID)
+		case replies[0].ID:
+			require.Equal(t, int64(1), summary.Count)
+			require.Equal(t, nested.ID, summary.Replies[0].ID)
+		default:
+			t.Fatalf("unexpected summary of %v", summary.ParentID)
+		}
+	}
+}
+
+func TestDeleteCommentWithReplies(t *testing.T) {
+	user := getUserByID(t, addUser(t, randomUser()))
+	movie := getMovieByID(t, addMovie(t, randomMovie()))
+	parent := getCommentByID(t, addComment(t, user, movie))
+	reply := addReply(t, user, parent)
+
+	// The parent stays for its reply, without its text
+	deletedCount, err := testQueries.DeleteComment(context.Background(), parent.ID, parent.Name)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deletedCount)
+	deleted := getCommentByID(t, parent.ID)
+	require.True(t, deleted.Deleted)
+	require.Empty(t, deleted.Text)
+	require.Empty(t, deleted.Email)
+
+	_, err = testQueries.DeleteComment(context.Background(), parent.ID, parent.Name)
+	require.Equal(t, mongo.ErrNoDocuments, err)
+	_, err = testQueries.UpdateComment(context.Background(), Comments{ID: parent.ID, Name: parent.Name, Text: "back"})
+	require.Equal(t, mongo.ErrNoDocuments, err)
+
+	// The reply has none, so it goes
+	_, err = testQueries.DeleteComment(context.Background(), reply.ID, reply.Name)
+	require.NoError(t, err)
+	_, err = testQueries.GetComment(context.Background(), reply.ID)
+	require.Equal(t, mongo.ErrNoDocuments, err)
+
+	// and the parent, deleted already, stays so without it
+	_, err = testQueries.DeleteComment(context.Background(), parent.ID, parent.Name)
+	require.Equal(t, mongo.ErrNoDocuments, err)
+	require.True(t, getCommentByID(t, parent.ID).Deleted)
+}
+
+func TestDeleteCommentsByMovieID(t *testing.T) {
+	user := getUserByID(t, addUser(t, randomUser()))
+	movie := getMovieByID(t, addMovie(t, randomMovie()))
+	other := getMovieByID(t, addMovie(t, randomMovie()))
+	parent := getCommentByID(t, addComment(t, user, movie))
+	reply := addReply(t, user, parent)
+	_, err := testQueries.DeleteComment(context.Background(), parent.ID, parent.Name)
+	require.NoError(t, err)
+	kept := addComment(t, user, other)
+
+	ids, err := testQueries.DeleteCommentsByMovieID(context.Background(), movie.Id)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []primitive.ObjectID{parent.ID, reply.ID}, ids)
+	for _, id := range ids {
+		_, err = testQueries.GetComment(context.Background(), id)
+		require.Equal(t, mongo.ErrNoDocuments, err)
+	}
+	getCommentByID(t, kept)
+
+	ids, err = testQueries.DeleteCommentsByMovieID(context.Background(), movie.Id)
+	require.NoError(t, err)
+	require.Empty(t, ids)
+}
+
+func TestCommentReactions(t *testing.T) {
+	user := getUserByID(t, addUser(t, randomUser()))
+	movie := getMovieByID(t, addMovie(t, randomMovie()))
+	comment := getCommentByID(t, addComment(t, user, movie))
+	require.WithinDuration(t, time.Now(), comment.Date.Time(), time.Second)
+
+	names := []string{util.RandomUser(), util.RandomUser()}
+	for _, name := range names {
+		added, err := testQueries.AddReaction(context.Background(), CommentReactionParams{CommentID: comment.ID, Name: name, Reaction: ReactionLike})
+		require.NoError(t, err)
+		require.True(t, added)
+	}
+	// A user likes a comment once
+	added, err := testQueries.AddReaction(context.Background(), CommentReactionParams{CommentID: comment.ID, Name: names[0], Reaction: ReactionLike})
+	require.NoError(t, err)
+	require.False(t, added)
+	_, err = testQueries.AddReaction(context.Background(), CommentReactionParams{CommentID: comment.ID, Name: names[0], Reaction: ReactionWow})
+	require.NoError(t, err)
+
+	comment = getCommentByID(t, comment.ID)
+	require.Equal(t, map[string]int64{ReactionLike: 2, ReactionWow: 1}, comment.Reactions)
+	require.Equal(t, int64(3), comment.ReactionCount)
+
+	reactions, err := testQueries.GetReactionsByName(context.Background(), names[0], []primitive.ObjectID{comment.ID})
+	require.NoError(t, err)
+	require.Len(t, reactions, 2)
+
+	err = testQueries.DeleteReaction(context.Background(), CommentReactionParams{CommentID: comment.ID, Name: names[1], Reaction: ReactionLike})
+	require.NoError(t, err)
+	err = testQueries.DeleteReaction(context.Background(), CommentReactionParams{CommentID: comment.ID, Name: names[1], Reaction: ReactionLike})
+	require.Equal(t, mongo.ErrNoDocuments, err)
+	comment = getCommentByID(t, comment.ID)
+	require.Equal(t, int64(1), comment.Reactions[ReactionLike])
+	require.Equal(t, int64(2), comment.ReactionCount)
+}
+
+func TestGetCommentsByMovieIDSorted(t *testing.T) {
+	user := getUserByID(t, addUser(t, randomUser()))
+	movie := getMovieByID(t, addMovie(t, randomMovie()))
+	var ids []primitive.ObjectID
+	for i := 0; i < 3; i++ {
+		ids = append(ids, addComment(t, user, movie))
+		time.Sleep(2 * time.Millisecond)
+	}
+	_, err := testQueries.AddReaction(context.Background(), CommentReactionParams{CommentID: ids[1], Name: user.Name, Reaction: ReactionLove})
+	require.NoError(t, err)
+
+	for sort, want := range map[string][]primitive.ObjectID{
+		CommentSortNew: {ids[2], ids[1], ids[0]},
+		CommentSortOld: {ids[0], ids[1], ids[2]},
+		CommentSortTop: {ids[1], ids[2], ids[0]},
+	} {
+		comments, err := testQueries.GetCommentsByMovieID(context.Background(), GetCommentsParams{MovieID: movie.Id, Sort: sort})
+		require.NoError(t, err)
+		require.Len(t, comments, 3)
+		for i := range want {
+			require.Equal(t, want[i], comments[i].ID, sort)
+		}
+	}
+}
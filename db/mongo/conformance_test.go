@@ -0,0 +1,11 @@
+package db_test
+
+import (
+	db "phantom/db/mongo"
+	"phantom/db/storetest"
+	"testing"
+)
+
+func TestStoreConformance(t *testing.T) {
+	storetest.Run(t, db.TestingStore())
+}
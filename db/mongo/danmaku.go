@@ -0,0 +1,211 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"phantom/ledger"
+	"time"
+)
+
+// How a danmaku crosses the screen
+const (
+	DanmakuScroll = "scroll"
+	DanmakuTop    = "top"
+	DanmakuBottom = "bottom"
+)
+
+// Danmaku is a bullet comment shown over a movie or an episode
+// at Offset milliseconds into it
+type Danmaku struct {
+	ID      primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	MovieID primitive.ObjectID `json:"movie_id" bson:"movie_id"`
+	Offset  int64              `json:"offset" bson:"offset"`
+	Text    string             `json:"text" bson:"text"`
+	Color   string             `json:"color" bson:"color"`
+	Mode    string             `json:"mode" bson:"mode"`
+	Name    string             `json:"name" bson:"name"`
+	Date    primitive.DateTime `json:"date" bson:"date"`
+	// Flags are the reasons the content filter held the danmaku back
+	Flags []string `json:"flags" bson:"flags,omitempty"`
+	// Pinned danmaku were paid for with coins, they show before the others
+	Pinned bool `json:"pinned" bson:"pinned,omitempty"`
+}
+
+type AddDanmakuParams struct {
+	MovieID primitive.ObjectID `json:"movie_id"`
+	Offset  int64              `json:"offset"`
+	Text    string             `json:"text"`
+	Color   string             `json:"color"`
+	Mode    string             `json:"mode"`
+	Name    string             `json:"name"`
+	Flags   []string           `json:"flags"`
+}
+
+// AddDanmaku adds a danmaku dated now, and records it in the ledger in the
+// same transaction, the one of ExecTx or one of its own
+func (q *Queries) AddDanmaku(ctx context.Context, arg AddDanmakuParams) (Danmaku, error) {
+	danmaku := Danmaku{
+		ID:      primitive.NewObjectID(),
+		MovieID: arg.MovieID,
+		Offset:  arg.Offset,
+		Text:    arg.Text,
+		Color:   arg.Color,
+		Mode:    arg.Mode,
+		Name:    arg.Name,
+		Date:    primitive.NewDateTimeFromTime(time.Now()),
+		Flags:   arg.Flags,
+	}
+	err := q.inTx(ctx, func(sessCtx mongo.SessionContext) error {
+		if _, err := q.danmaku.InsertOne(sessCtx, danmaku); err != nil {
+			return err
+		}
+		return q.appendLedger(sessCtx, LedgerDanmaku, ledger.OpAdd, danmaku.ID, DanmakuDigest(danmaku))
+	})
+	if err != nil {
+		return Danmaku{}, err
+	}
+	return danmaku, nil
+}
+
+// GetDanmakuParams gets the danmaku from From to To milliseconds into a movie.
+// The window is cut in buckets of Bucket milliseconds, each showing
+// at most PerBucket danmaku
+type GetDanmakuParams struct {
+	MovieID   primitive.ObjectID `json:"movie_id"`
+	From      int64              `json:"from"`
+	To        int64              `json:"to"`
+	Bucket    int64              `json:"bucket"`
+	PerBucket int64              `json:"per_bucket"`
+}
+
+// GetDanmaku gets the danmaku of a time window of a movie in the order they show,
+// leaving out the flagged ones. Where a scene has more than PerBucket in a bucket
+// the pinned ones and then the latest posted are kept, so that a busy scene
+// changes over time
+func (q *Queries) GetDanmaku(ctx context.Context, arg GetDanmakuParams) ([]Danmaku, error) {
+	matchStage := bson.D{{"$match", bson.D{
+		{"movie_id", arg.MovieID},
+		{"offset", bson.D{{"$gte", arg.From}, {"$lt", arg.To}}},
+		{"flags", bson.D{{"$exists", false}}},
+	}}}
+	latestStage := bson.D{{"$sort", bson.D{{"pinned", -1}, {"_id", -1}}}}
+	groupStage := bson.D{{"$group", bson.D{
+		{"_id", bson.D{{"$floor", bson.D{{"$divide", bson.A{"$offset", arg.Bucket}}}}}},
+		{"danmaku", bson.D{{"$push", "$$ROOT"}}},
+	}}}
+	capStage := bson.D{{"$project", bson.D{{"danmaku", bson.D{{"$slice", bson.A{"$danmaku", arg.PerBucket}}}}}}}
+	unwindStage := bson.D{{"$unwind", "$danmaku"}}
+	replaceStage := bson.D{{"$replaceWith", "$danmaku"}}
+	sortStage := bson.D{{"$sort", bson.D{{"offset", 1}, {"_id", 1}}}}
+
+	cursor, err := q.danmaku.Aggregate(ctx, mongo.Pipeline{
+		matchStage, latestStage, groupStage, capStage, unwindStage, replaceStage, sortStage,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var danmaku []Danmaku
+	if err = cursor.All(ctx, &danmaku); err != nil {
+		return nil, err
+	}
+	return danmaku, nil
+}
+
+// GetDanmakuModerationQueue gets a page of the danmaku the content filter
+// flagged, which wait for review, the oldest first
+func (q *Queries) GetDanmakuModerationQueue(ctx context.Context, arg GetModerationQueueParams) ([]Danmaku, error) {
+	findOptions := options.Find().
+		SetSort(bson.D{{"_id", 1}}).
+		SetSkip(arg.Skip).
+		SetLimit(arg.Limit)
+	cursor, err := q.danmaku.Find(ctx, bson.M{"flags": bson.M{"$exists": true}}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var danmaku []Danmaku
+	if err = cursor.All(ctx, &danmaku); err != nil {
+		return nil, err
+	}
+	return danmaku, nil
+}
+
+// ApproveDanmaku shows a flagged danmaku by clearing its flags, and returns it.
+// It returns mongo.ErrNoDocuments when there is no such danmaku
+func (q *Queries) ApproveDanmaku(ctx context.Context, id primitive.ObjectID) (Danmaku, error) {
+	var danmaku Danmaku
+	err := q.danmaku.FindOneAndUpdate(ctx, bson.M{"_id": id}, bson.M{"$unset": bson.M{"flags": ""}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&danmaku)
+	if err != nil {
+		return Danmaku{}, err
+	}
+	return danmaku, nil
+}
+
+// RemoveDanmaku deletes a danmaku on behalf of a moderator, records it
+// in the ledger, and returns it
+func (q *Queries) RemoveDanmaku(ctx context.Context, id primitive.ObjectID) (Danmaku, error) {
+	var danmaku Danmaku
+	err := q.inTx(ctx, func(sessCtx mongo.SessionContext) error {
+		if err := q.danmaku.FindOneAndDelete(sessCtx, bson.M{"_id": id}).Decode(&danmaku); err != nil {
+			return err
+		}
+		return q.appendLedger(sessCtx, LedgerDanmaku, ledger.OpDelete, id, ledger.Hash{})
+	})
+	if err != nil {
+		return Danmaku{}, err
+	}
+	return danmaku, nil
+}
+
+var ErrDanmakuPinned = errors.New("the danmaku is pinned already")
+
+// PinDanmakuTxParams pins a danmaku for Cost coins of the user with the Name
+type PinDanmakuTxParams struct {
+	DanmakuID primitive.ObjectID `json:"danmaku_id"`
+	Name      string             `json:"name"`
+	Cost      int64              `json:"cost"`
+}
+
+type PinDanmakuTxResult struct {
+	Danmaku     Danmaku         `json:"danmaku"`
+	Transaction CoinTransaction `json:"transaction"`
+}
+
+// PinDanmakuTx pins a danmaku and takes the coins for it together.
+// A danmaku is pinned once, the flagged ones can't be
+func (store *MongoStore) PinDanmakuTx(ctx context.Context, arg PinDanmakuTxParams) (PinDanmakuTxResult, error) {
+	var result PinDanmakuTxResult
+	err := store.execCoinTx(ctx, func(sessCtx mongo.SessionContext) error {
+		err := store.danmaku.FindOne(sessCtx, bson.M{"_id": arg.DanmakuID, "flags": bson.M{"$exists": false}}).Decode(&result.Danmaku)
+		if err != nil {
+			return err
+		}
+		if result.Danmaku.Pinned {
+			return ErrDanmakuPinned
+		}
+
+		transfer, err := store.transferCoins(sessCtx, TransferCoinsParams{
+			Key:    CoinPinDanmaku + ":" + arg.DanmakuID.Hex(),
+			Kind:   CoinPinDanmaku,
+			From:   UserCoinAccount(arg.Name),
+			To:     CoinAccountSpent,
+			Amount: arg.Cost,
+		})
+		if err != nil {
+			return err
+		}
+		result.Transaction = transfer.Transaction
+		_, err = store.danmaku.UpdateOne(sessCtx, bson.M{"_id": arg.DanmakuID}, bson.M{"$set": bson.M{"pinned": true}})
+		result.Danmaku.Pinned = true
+		return err
+	})
+	return result, err
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"phantom/util"
+	"testing"
+)
+
+func addDanmaku(t *testing.T, arg AddDanmakuParams) Danmaku {
+	danmaku, err := testQueries.AddDanmaku(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotEmpty(t, danmaku.ID)
+	return danmaku
+}
+
+func TestGetDanmaku(t *testing.T) {
+	movieID := addMovie(t, randomMovie())
+	name := util.RandomUser()
+	danmaku := func(offset int64) AddDanmakuParams {
+		return AddDanmakuParams{
+			MovieID: movieID,
+			Offset:  offset,
+			Text:    util.RandomString(10),
+			Color:   "#ffffff",
+			Mode:    DanmakuScroll,
+			Name:    name,
+		}
+	}
+
+	early := addDanmaku(t, danmaku(500))
+	// A busy second keeps the latest two
+	addDanmaku(t, danmaku(1100))
+	busy1 := addDanmaku(t, danmaku(1900))
+	busy2 := addDanmaku(t, danmaku(1500))
+	flagged := danmaku(2500)
+	flagged.Flags = []string{"blocked word"}
+	addDanmaku(t, flagged)
+	addDanmaku(t, danmaku(3000))
+
+	got, err := testQueries.GetDanmaku(context.Background(), GetDanmakuParams{
+		MovieID:   movieID,
+		From:      0,
+		To:        3000,
+		Bucket:    1000,
+		PerBucket: 2,
+	})
+	require.NoError(t, err)
+	require.Len(t, got, 3)
+	require.Equal(t, early.ID, got[0].ID)
+	require.Equal(t, busy2.ID, got[1].ID)
+	require.Equal(t, busy1.ID, got[2].ID)
+	require.Equal(t, name, got[0].Name)
+
+	got, err = testQueries.GetDanmaku(context.Background(), GetDanmakuParams{
+		MovieID:   addMovie(t, randomMovie()),
+		From:      0,
+		To:        3000,
+		Bucket:    1000,
+		PerBucket: 2,
+	})
+	require.NoError(t, err)
+	require.Empty(t, got)
+}
@@ -0,0 +1,6 @@
+package db
+
+// TestingStore is the store of the tests, for the tests of package db_test
+func TestingStore() *MongoStore {
+	return testStore
+}
@@ -0,0 +1,272 @@
+package db
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"math"
+	"time"
+)
+
+// HistoryEntry is a movie or an episode a user watched to the end, it is added
+// by SaveProgress. Duration is what the player reported, in seconds
+type HistoryEntry struct {
+	ID        primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	Name      string             `json:"name" bson:"name"`
+	MediaID   primitive.ObjectID `json:"media_id" bson:"media_id"`
+	SeriesID  primitive.ObjectID `json:"series_id" bson:"series_id,omitempty"`
+	Duration  int64              `json:"duration" bson:"duration"`
+	WatchedAt primitive.DateTime `json:"watched_at" bson:"watched_at"`
+}
+
+type GetHistoryParams struct {
+	Name  string `json:"name"`
+	Skip  int64  `json:"skip"`
+	Limit int64  `json:"limit"`
+}
+
+// GetHistory gets a page of the history of a user, the latest first
+func (q *Queries) GetHistory(ctx context.Context, arg GetHistoryParams) ([]HistoryEntry, error) {
+	findOptions := options.Find().
+		SetSort(bson.D{{"watched_at", -1}, {"_id", -1}}).
+		SetSkip(arg.Skip).
+		SetLimit(arg.Limit)
+	cursor, err := q.history.Find(ctx, bson.M{"name": arg.Name}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var entries []HistoryEntry
+	if err = cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// DeleteHistoryEntry removes an entry from the history of a user,
+// it returns mongo.ErrNoDocuments when the user has no such entry
+func (q *Queries) DeleteHistoryEntry(ctx context.Context, name string, id primitive.ObjectID) error {
+	res, err := q.history.DeleteOne(ctx, bson.M{"_id": id, "name": name})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// StatsCount is how many times a genre or a person came up in what a user watched
+type StatsCount struct {
+	Name  string `json:"name" bson:"_id"`
+	Count int64  `json:"count" bson:"count"`
+}
+
+type MonthStats struct {
+	// Month is written as "2006-01"
+	Month    string  `json:"month"`
+	Movies   int64   `json:"movies"`
+	Episodes int64   `json:"episodes"`
+	Hours    float64 `json:"hours"`
+}
+
+// statsTotals is what the stats pipeline counts, for all time or for a month
+type statsTotals struct {
+	ID       string  `bson:"_id"`
+	Movies   int64   `bson:"movies"`
+	Episodes int64   `bson:"episodes"`
+	Minutes  float64 `bson:"minutes"`
+}
+
+// WatchStreak counts days in a row with something watched, the current
+// streak ends today or yesterday
+type WatchStreak struct {
+	Current int64 `json:"current"`
+	Longest int64 `json:"longest"`
+}
+
+type UserStats struct {
+	Movies       int64        `json:"movies"`
+	Episodes     int64        `json:"episodes"`
+	Hours        float64      `json:"hours"`
+	TopGenres    []StatsCount `json:"top_genres"`
+	TopDirectors []StatsCount `json:"top_directors"`
+	TopCast      []StatsCount `json:"top_cast"`
+	Streak       WatchStreak  `json:"streak"`
+	Months       []MonthStats `json:"months"`
+}
+
+// GetUserStatsParams limits the stats to what was watched from From until
+// before To, a zero time leaves that end open. Top is the length of the top lists
+type GetUserStatsParams struct {
+	Name string    `json:"name"`
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	Top  int64     `json:"top"`
+}
+
+// GetUserStats works out the stats of a user from their history. Genres and
+// people come from the movie or the series, and the time watched from the
+// runtime of the movie, or from the duration the player reported for episodes
+// and for movies without a runtime. Days and months are in UTC
+func (q *Queries) GetUserStats(ctx context.Context, arg GetUserStatsParams) (UserStats, error) {
+	match := bson.D{{"name", arg.Name}}
+	watchedAt := bson.D{}
+	if !arg.From.IsZero() {
+		watchedAt = append(watchedAt, bson.E{Key: "$gte", Value: primitive.NewDateTimeFromTime(arg.From)})
+	}
+	if !arg.To.IsZero() {
+		watchedAt = append(watchedAt, bson.E{Key: "$lt", Value: primitive.NewDateTimeFromTime(arg.To)})
+	}
+	if len(watchedAt) > 0 {
+		match = append(match, bson.E{Key: "watched_at", Value: watchedAt})
+	}
+
+	isEpisode := bson.D{{"$gt", bson.A{"$series_id", nil}}}
+	playerMinutes := bson.D{{"$divide", bson.A{"$duration", 60}}}
+	top := func(field string) bson.A {
+		return bson.A{
+			bson.D{{"$unwind", field}},
+			bson.D{{"$group", bson.D{{"_id", field}, {"count", bson.D{{"$sum", 1}}}}}},
+			bson.D{{"$sort", bson.D{{"count", -1}, {"_id", 1}}}},
+			bson.D{{"$limit", arg.Top}},
+		}
+	}
+	date := func(format string) bson.D {
+		return bson.D{{"$dateToString", bson.D{{"format", format}, {"date", "$watched_at"}}}}
+	}
+	counts := bson.D{
+		{"movies", bson.D{{"$sum", bson.D{{"$cond", bson.A{"$is_episode", 0, 1}}}}}},
+		{"episodes", bson.D{{"$sum", bson.D{{"$cond", bson.A{"$is_episode", 1, 0}}}}}},
+		{"minutes", bson.D{{"$sum", "$minutes"}}},
+	}
+	pipeline := mongo.Pipeline{
+		{{"$match", match}},
+		{{"$lookup", bson.D{
+			{"from", "movies"},
+			{"let", bson.D{{"movie_id", bson.D{{"$ifNull", bson.A{"$series_id", "$media_id"}}}}}},
+			{"pipeline", bson.A{
+				bson.D{{"$match", bson.D{{"$expr", bson.D{{"$eq", bson.A{"$_id", "$$movie_id"}}}}}}},
+				bson.D{{"$project", bson.D{{"genres", 1}, {"directors", 1}, {"cast", 1}, {"runtime", 1}}}},
+			}},
+			{"as", "movie"},
+		}}},
+		{{"$unwind", bson.D{{"path", "$movie"}, {"preserveNullAndEmptyArrays", true}}}},
+		{{"$set", bson.D{
+			{"is_episode", isEpisode},
+			{"minutes", bson.D{{"$cond", bson.A{
+				isEpisode,
+				playerMinutes,
+				bson.D{{"$ifNull", bson.A{"$movie.runtime", playerMinutes}}},
+			}}}},
+		}}},
+		{{"$facet", bson.D{
+			{"totals", bson.A{bson.D{{"$group", append(bson.D{{"_id", nil}}, counts...)}}}},
+			{"genres", top("$movie.genres")},
+			{"directors", top("$movie.directors")},
+			{"cast", top("$movie.cast")},
+			{"months", bson.A{
+				bson.D{{"$group", append(bson.D{{"_id", date("%Y-%m")}}, counts...)}},
+				bson.D{{"$sort", bson.D{{"_id", 1}}}},
+			}},
+			{"days", bson.A{
+				bson.D{{"$group", bson.D{{"_id", date("%Y-%m-%d")}}}},
+				bson.D{{"$sort", bson.D{{"_id", 1}}}},
+			}},
+		}}},
+	}
+	cursor, err := q.history.Aggregate(ctx, pipeline)
+	if err != nil {
+		return UserStats{}, err
+	}
+	defer cursor.Close(ctx)
+
+	var result []struct {
+		Totals    []statsTotals `bson:"totals"`
+		Genres    []StatsCount  `bson:"genres"`
+		Directors []StatsCount  `bson:"directors"`
+		Cast      []StatsCount  `bson:"cast"`
+		Months    []statsTotals `bson:"months"`
+		Days      []struct {
+			Day string `bson:"_id"`
+		} `bson:"days"`
+	}
+	if err = cursor.All(ctx, &result); err != nil {
+		return UserStats{}, err
+	}
+
+	stats := UserStats{
+		TopGenres:    []StatsCount{},
+		TopDirectors: []StatsCount{},
+		TopCast:      []StatsCount{},
+		Months:       []MonthStats{},
+	}
+	if len(result) == 0 {
+		return stats, nil
+	}
+	facets := result[0]
+	if len(facets.Totals) > 0 {
+		stats.Movies = facets.Totals[0].Movies
+		stats.Episodes = facets.Totals[0].Episodes
+		stats.Hours = minutesToHours(facets.Totals[0].Minutes)
+	}
+	if facets.Genres != nil {
+		stats.TopGenres = facets.Genres
+	}
+	if facets.Directors != nil {
+		stats.TopDirectors = facets.Directors
+	}
+	if facets.Cast != nil {
+		stats.TopCast = facets.Cast
+	}
+	for _, month := range facets.Months {
+		stats.Months = append(stats.Months, MonthStats{
+			Month:    month.ID,
+			Movies:   month.Movies,
+			Episodes: month.Episodes,
+			Hours:    minutesToHours(month.Minutes),
+		})
+	}
+	days := make([]time.Time, 0, len(facets.Days))
+	for _, day := range facets.Days {
+		t, err := time.Parse("2006-01-02", day.Day)
+		if err != nil {
+			return UserStats{}, err
+		}
+		days = append(days, t)
+	}
+	stats.Streak = watchStreak(days, time.Now().UTC())
+	return stats, nil
+}
+
+// minutesToHours rounds to a tenth of an hour
+func minutesToHours(minutes float64) float64 {
+	return math.Round(minutes/6) / 10
+}
+
+// watchStreak works out the streaks from the days something was watched,
+// in order and at midnight UTC
+func watchStreak(days []time.Time, now time.Time) WatchStreak {
+	var streak WatchStreak
+	var run int64
+	for i, day := range days {
+		if i > 0 && day.Sub(days[i-1]) == 24*time.Hour {
+			run++
+		} else {
+			run = 1
+		}
+		if run > streak.Longest {
+			streak.Longest = run
+		}
+	}
+	if len(days) > 0 {
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		if since := today.Sub(days[len(days)-1]); since == 0 || since == 24*time.Hour {
+			streak.Current = run
+		}
+	}
+	return streak
+}
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"phantom/util"
+	"testing"
+	"time"
+)
+
+func addHistoryEntry(t *testing.T, entry HistoryEntry) {
+	_, err := testQueries.history.InsertOne(context.Background(), entry)
+	require.NoError(t, err)
+}
+
+func TestSaveProgressAddsHistory(t *testing.T) {
+	name := util.RandomUser()
+	movieID := addMovie(t, randomMovie())
+
+	// The heartbeats after the movie is watched don't add to the history
+	saveProgress(t, SaveProgressParams{Name: name, MediaID: movieID, Position: 100, Duration: 6000})
+	saveProgress(t, SaveProgressParams{Name: name, MediaID: movieID, Position: 5500, Duration: 6000, Watched: true})
+	saveProgress(t, SaveProgressParams{Name: name, MediaID: movieID, Position: 5510, Duration: 6000, Watched: true})
+	entries, err := testQueries.GetHistory(context.Background(), GetHistoryParams{Name: name, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, movieID, entries[0].MediaID)
+	require.Equal(t, int64(6000), entries[0].Duration)
+
+	// Watching it again does
+	saveProgress(t, SaveProgressParams{Name: name, MediaID: movieID, Position: 10, Duration: 6000})
+	saveProgress(t, SaveProgressParams{Name: name, MediaID: movieID, Position: 5900, Duration: 6000, Watched: true})
+	entries, err = testQueries.GetHistory(context.Background(), GetHistoryParams{Name: name, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.True(t, entries[0].WatchedAt.Time().After(entries[1].WatchedAt.Time()))
+
+	err = testQueries.DeleteHistoryEntry(context.Background(), util.RandomUser(), entries[0].ID)
+	require.ErrorIs(t, err, mongo.ErrNoDocuments)
+	err = testQueries.DeleteHistoryEntry(context.Background(), name, entries[0].ID)
+	require.NoError(t, err)
+	entries, err = testQueries.GetHistory(context.Background(), GetHistoryParams{Name: name, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+func TestGetUserStats(t *testing.T) {
+	name := util.RandomUser()
+	drama := addMovie(t, AddMovieParams{
+		Title:     util.RandomString(8),
+		Runtime:   120,
+		Genres:    []string{"Drama"},
+		Directors: []string{"Ang Lee"},
+		Cast:      []string{"Tony Leung"},
+	})
+	comedy := addMovie(t, AddMovieParams{
+		Title:     util.RandomString(8),
+		Genres:    []string{"Comedy", "Drama"},
+		Directors: []string{"Ang Lee"},
+	})
+	seriesID := addMovie(t, AddMovieParams{Title: util.RandomString(8), Type: TypeSeries})
+	episode := addEpisode(t, seriesID, 1, 1, 1)
+
+	day := func(month time.Month, d int) primitive.DateTime {
+		return primitive.NewDateTimeFromTime(time.Date(2021, month, d, 20, 0, 0, 0, time.UTC))
+	}
+	addHistoryEntry(t, HistoryEntry{Name: name, MediaID: drama, Duration: 7000, WatchedAt: day(time.March, 1)})
+	addHistoryEntry(t, HistoryEntry{Name: name, MediaID: comedy, Duration: 5400, WatchedAt: day(time.March, 2)})
+	addHistoryEntry(t, HistoryEntry{Name: name, MediaID: episode, SeriesID: seriesID, Duration: 1800, WatchedAt: day(time.April, 5)})
+	addHistoryEntry(t, HistoryEntry{
+		Name:      name,
+		MediaID:   drama,
+		Duration:  7000,
+		WatchedAt: primitive.NewDateTimeFromTime(time.Date(2020, time.December, 31, 20, 0, 0, 0, time.UTC)),
+	})
+
+	stats, err := testQueries.GetUserStats(context.Background(), GetUserStatsParams{
+		Name: name,
+		From: time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC),
+		Top:  5,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), stats.Movies)
+	require.Equal(t, int64(1), stats.Episodes)
+	// 120 minutes of runtime, then 90 and 30 reported by the player
+	require.Equal(t, 4.0, stats.Hours)
+	require.Equal(t, StatsCount{Name: "Drama", Count: 2}, stats.TopGenres[0])
+	require.Equal(t, []StatsCount{{Name: "Ang Lee", Count: 2}}, stats.TopDirectors)
+	require.Equal(t, []StatsCount{{Name: "Tony Leung", Count: 1}}, stats.TopCast)
+	require.Equal(t, WatchStreak{Current: 0, Longest: 2}, stats.Streak)
+	require.Equal(t, []MonthStats{
+		{Month: "2021-03", Movies: 2, Hours: 3.5},
+		{Month: "2021-04", Episodes: 1, Hours: 0.5},
+	}, stats.Months)
+
+	stats, err = testQueries.GetUserStats(context.Background(), GetUserStatsParams{Name: name, Top: 5})
+	require.NoError(t, err)
+	require.Equal(t, int64(3), stats.Movies)
+	require.Equal(t, WatchStreak{Current: 0, Longest: 2}, stats.Streak)
+
+	stats, err = testQueries.GetUserStats(context.Background(), GetUserStatsParams{Name: util.RandomUser(), Top: 5})
+	require.NoError(t, err)
+	require.Zero(t, stats.Movies)
+	require.Empty(t, stats.Months)
+}
+
+func TestWatchStreak(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2021, time.March, d, 0, 0, 0, 0, time.UTC)
+	}
+	now := time.Date(2021, time.March, 10, 21, 30, 0, 0, time.UTC)
+
+	require.Equal(t, WatchStreak{}, watchStreak(nil, now))
+	require.Equal(t, WatchStreak{Current: 2, Longest: 3}, watchStreak([]time.Time{day(1), day(2), day(3), day(9), day(10)}, now))
+	require.Equal(t, WatchStreak{Current: 1, Longest: 1}, watchStreak([]time.Time{day(5), day(9)}, now))
+	require.Equal(t, WatchStreak{Current: 0, Longest: 2}, watchStreak([]time.Time{day(7), day(8)}, now))
+}
@@ -0,0 +1,351 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/mongo/driver"
+	"phantom/ledger"
+	"strconv"
+	"time"
+)
+
+// The kinds of what the ledger records
+const (
+	LedgerComment = "comment"
+	LedgerDanmaku = "danmaku"
+)
+
+// LedgerCheckpointInterval is how many entries a checkpoint of the ledger is of,
+// but for those CheckpointLedger makes of the latest entries
+const LedgerCheckpointInterval = 64
+
+// ErrNotCheckpointed means the entry is in the ledger, but the next checkpoint
+// is yet to come, so that it can't be proved until CheckpointLedger
+var ErrNotCheckpointed = errors.New("the entry is not checkpointed yet")
+
+// LedgerEntry is a ledger.Entry as stored, the hashes in hex
+type LedgerEntry struct {
+	Seq    int64              `json:"seq" bson:"_id"`
+	Kind   string             `json:"kind" bson:"kind"`
+	Op     string             `json:"op" bson:"op"`
+	RefID  primitive.ObjectID `json:"ref_id" bson:"ref_id"`
+	Digest string             `json:"digest" bson:"digest"`
+	Prev   string             `json:"prev" bson:"prev"`
+	Hash   string             `json:"hash" bson:"hash"`
+	Date   primitive.DateTime `json:"date" bson:"date"`
+}
+
+// LedgerCheckpoint is a ledger.Checkpoint as stored
+type LedgerCheckpoint struct {
+	From int64              `json:"from" bson:"_id"`
+	To   int64              `json:"to" bson:"to"`
+	Root string             `json:"root" bson:"root"`
+	Date primitive.DateTime `json:"date" bson:"date"`
+}
+
+func newLedgerEntry(entry ledger.Entry, refID primitive.ObjectID) LedgerEntry {
+	return LedgerEntry{
+		Seq:    entry.Seq,
+		Kind:   entry.Kind,
+		Op:     entry.Op,
+		RefID:  refID,
+		Digest: entry.Digest.String(),
+		Prev:   entry.Prev.String(),
+		Hash:   entry.Hash.String(),
+		Date:   primitive.NewDateTimeFromTime(time.Now()),
+	}
+}
+
+func (e LedgerEntry) entry() (ledger.Entry, error) {
+	entry := ledger.Entry{Seq: e.Seq, Kind: e.Kind, Op: e.Op, Ref: e.RefID.Hex()}
+	var err error
+	if entry.Digest, err = ledger.ParseHash(e.Digest); err != nil {
+		return ledger.Entry{}, err
+	}
+	if entry.Prev, err = ledger.ParseHash(e.Prev); err != nil {
+		return ledger.Entry{}, err
+	}
+	if entry.Hash, err = ledger.ParseHash(e.Hash); err != nil {
+		return ledger.Entry{}, err
+	}
+	return entry, nil
+}
+
+func (c LedgerCheckpoint) checkpoint() (ledger.Checkpoint, error) {
+	root, err := ledger.ParseHash(c.Root)
+	if err != nil {
+		return ledger.Checkpoint{}, err
+	}
+	return ledger.Checkpoint{From: c.From, To: c.To, Root: root}, nil
+}
+
+// commentContent is what the ledger records of a comment: the digest of
+// a comment is the SHA-256 of this as JSON, the ids in hex
+type commentContent struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	MovieID  string `json:"movie_id"`
+	ParentID string `json:"parent_id,omitempty"`
+	Text     string `json:"text"`
+}
+
+// danmakuContent is what the ledger records of a danmaku, like commentContent
+type danmakuContent struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	MovieID string `json:"movie_id"`
+	Offset  int64  `json:"offset"`
+	Text    string `json:"text"`
+	Color   string `json:"color"`
+	Mode    string `json:"mode"`
+}
+
+// CommentDigest is the digest of a comment in the ledger
+func CommentDigest(comment Comments) ledger.Hash {
+	content := commentContent{
+		ID:      comment.ID.Hex(),
+		Name:    comment.Name,
+		MovieID: comment.MovieID.Hex(),
+		Text:    comment.Text,
+	}
+	if !comment.ParentID.IsZero() {
+		content.ParentID = comment.ParentID.Hex()
+	}
+	return digestJSON(content)
+}
+
+// DanmakuDigest is the digest of a danmaku in the ledger
+func DanmakuDigest(danmaku Danmaku) ledger.Hash {
+	return digestJSON(danmakuContent{
+		ID:      danmaku.ID.Hex(),
+		Name:    danmaku.Name,
+		MovieID: danmaku.MovieID.Hex(),
+		Offset:  danmaku.Offset,
+		Text:    danmaku.Text,
+		Color:   danmaku.Color,
+		Mode:    danmaku.Mode,
+	})
+}
+
+func digestJSON(content interface{}) ledger.Hash {
+	// The contents are plain structs, which always marshal
+	data, _ := json.Marshal(content)
+	return ledger.Digest(data)
+}
+
+// appendLedger appends an entry to the ledger, and checkpoints the ledger
+// when an interval of entries has followed the latest checkpoint. It runs in
+// the transaction of the change it records. The entries are numbered by the
+// database: when another server takes the next Seq first, the error is a
+// transient one, on which WithTransaction runs the whole transaction again
+func (q *Queries) appendLedger(ctx context.Context, kind, op string, refID primitive.ObjectID, digest ledger.Hash) error {
+	var prev *ledger.Entry
+	head, err := q.getLedgerHead(ctx)
+	switch {
+	case err == nil:
+		prev = &head
+	case err != mongo.ErrNoDocuments:
+		return err
+	}
+
+	entry := ledger.Append(prev, kind, op, refID.Hex(), digest)
+	_, err = q.ledger.InsertOne(ctx, newLedgerEntry(entry, refID))
+	if mongo.IsDuplicateKeyError(err) {
+		return mongo.CommandError{
+			Message: "the ledger entry " + strconv.FormatInt(entry.Seq, 10) + " was appended by another server",
+			Labels:  []string{driver.TransientTransactionError},
+			Wrapped: err,
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return q.checkpointLedger(ctx, entry.Seq+1, false)
+}
+
+func (q *Queries) getLedgerHead(ctx context.Context) (ledger.Entry, error) {
+	var head LedgerEntry
+	err := q.ledger.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.D{{"_id", -1}})).Decode(&head)
+	if err != nil {
+		return ledger.Entry{}, err
+	}
+	return head.entry()
+}
+
+// CheckpointLedger checkpoints the entries that follow the latest checkpoint,
+// the last of which are fewer than an interval, so that each entry so far
+// can be proved
+func (q *Queries) CheckpointLedger(ctx context.Context) error {
+	head, err := q.getLedgerHead(ctx)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return q.checkpointLedger(ctx, head.Seq+1, true)
+}
+
+// checkpointLedger checkpoints the entries before to that follow the latest
+// checkpoint, an interval at a time, and the ones left over when tail is true.
+// Each checkpoint starts where the one before ends, so that the intervals a
+// failed checkpoint missed are checkpointed next time. When another server
+// has checkpointed the same entries, it is left to that server
+func (q *Queries) checkpointLedger(ctx context.Context, to int64, tail bool) error {
+	var latest LedgerCheckpoint
+	err := q.ledgerCheckpoints.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.D{{"to", -1}})).Decode(&latest)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+	for from := latest.To; from < to; from += LedgerCheckpointInterval {
+		end := from + LedgerCheckpointInterval
+		if end > to {
+			if !tail {
+				return nil
+			}
+			end = to
+		}
+		entries, err := q.getLedgerEntries(ctx, bson.M{"_id": bson.M{"$gte": from, "$lt": end}})
+		if err != nil {
+			return err
+		}
+		checkpoint := ledger.NewCheckpoint(entries)
+		_, err = q.ledgerCheckpoints.InsertOne(ctx, LedgerCheckpoint{
+			From: checkpoint.From,
+			To:   checkpoint.To,
+			Root: checkpoint.Root.String(),
+			Date: primitive.NewDateTimeFromTime(time.Now()),
+		})
+		if mongo.IsDuplicateKeyError(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getLedgerEntries gets the entries filter matches in order
+func (q *Queries) getLedgerEntries(ctx context.Context, filter bson.M) ([]ledger.Entry, error) {
+	cursor, err := q.ledger.Find(ctx, filter, options.Find().SetSort(bson.D{{"_id", 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var entries []ledger.Entry
+	for cursor.Next(ctx) {
+		var stored LedgerEntry
+		if err = cursor.Decode(&stored); err != nil {
+			return nil, err
+		}
+		entry, err := stored.entry()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, cursor.Err()
+}
+
+// GetLedgerProof proves the latest entry of a comment or a danmaku
+// is in the ledger. It is ErrNotCheckpointed until the entry is checkpointed
+func (q *Queries) GetLedgerProof(ctx context.Context, refID primitive.ObjectID) (ledger.Proof, error) {
+	var latest LedgerEntry
+	err := q.ledger.FindOne(ctx, bson.M{"ref_id": refID}, options.FindOne().SetSort(bson.D{{"_id", -1}})).Decode(&latest)
+	if err != nil {
+		return ledger.Proof{}, err
+	}
+
+	var stored LedgerCheckpoint
+	err = q.ledgerCheckpoints.FindOne(ctx, bson.M{"_id": bson.M{"$lte": latest.Seq}, "to": bson.M{"$gt": latest.Seq}}).Decode(&stored)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return ledger.Proof{}, ErrNotCheckpointed
+		}
+		return ledger.Proof{}, err
+	}
+	checkpoint, err := stored.checkpoint()
+	if err != nil {
+		return ledger.Proof{}, err
+	}
+
+	entries, err := q.getLedgerEntries(ctx, bson.M{"_id": bson.M{"$gte": checkpoint.From, "$lt": checkpoint.To}})
+	if err != nil {
+		return ledger.Proof{}, err
+	}
+	proof, err := ledger.NewProof(entries, latest.Seq)
+	if err != nil {
+		return ledger.Proof{}, err
+	}
+	// The entries may have changed since they were checkpointed
+	proof.Checkpoint = checkpoint
+	return proof, nil
+}
+
+// VerifyLedger checks the whole ledger against its checkpoints and against
+// the comments and danmaku it records, see ledger.Verify
+func (q *Queries) VerifyLedger(ctx context.Context) ([]ledger.Problem, error) {
+	entries, err := q.getLedgerEntries(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := q.ledgerCheckpoints.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{"_id", 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var stored []LedgerCheckpoint
+	if err = cursor.All(ctx, &stored); err != nil {
+		return nil, err
+	}
+	checkpoints := make([]ledger.Checkpoint, len(stored))
+	for i, c := range stored {
+		if checkpoints[i], err = c.checkpoint(); err != nil {
+			return nil, err
+		}
+	}
+
+	// A deleted comment that is kept for its replies counts as gone
+	current := make(map[string]ledger.Hash)
+	commentCursor, err := q.comments.Find(ctx, bson.M{"deleted": bson.M{"$ne": true}})
+	if err != nil {
+		return nil, err
+	}
+	defer commentCursor.Close(ctx)
+	for commentCursor.Next(ctx) {
+		var comment Comments
+		if err = commentCursor.Decode(&comment); err != nil {
+			return nil, err
+		}
+		current[comment.ID.Hex()] = CommentDigest(comment)
+	}
+	if err = commentCursor.Err(); err != nil {
+		return nil, err
+	}
+
+	danmakuCursor, err := q.danmaku.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer danmakuCursor.Close(ctx)
+	for danmakuCursor.Next(ctx) {
+		var danmaku Danmaku
+		if err = danmakuCursor.Decode(&danmaku); err != nil {
+			return nil, err
+		}
+		current[danmaku.ID.Hex()] = DanmakuDigest(danmaku)
+	}
+	if err = danmakuCursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return ledger.Verify(entries, checkpoints, current), nil
+}
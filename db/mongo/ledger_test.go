@@ -0,0 +1,144 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"phantom/ledger"
+	"phantom/util"
+	"sync"
+	"testing"
+)
+
+// fillCheckpoint adds enough danmaku for the entries so far to be checkpointed
+func fillCheckpoint(t *testing.T, movie Movies) {
+	for i := 0; i < LedgerCheckpointInterval; i++ {
+		addDanmaku(t, AddDanmakuParams{MovieID: movie.Id, Text: util.RandomString(10), Name: util.RandomUser()})
+	}
+}
+
+func TestGetLedgerProof(t *testing.T) {
+	user := getUserByID(t, addUser(t, randomUser()))
+	movie := getMovieByID(t, addMovie(t, randomMovie()))
+	comment := getCommentByID(t, addComment(t, user, movie))
+
+	_, err := testQueries.GetLedgerProof(context.Background(), comment.ID)
+	require.Equal(t, ErrNotCheckpointed, err)
+	_, err = testQueries.GetLedgerProof(context.Background(), movie.Id)
+	require.Equal(t, mongo.ErrNoDocuments, err)
+
+	fillCheckpoint(t, movie)
+	proof, err := testQueries.GetLedgerProof(context.Background(), comment.ID)
+	require.NoError(t, err)
+	require.NoError(t, proof.Verify())
+	require.Equal(t, LedgerComment, proof.Entry.Kind)
+	require.Equal(t, ledger.OpAdd, proof.Entry.Op)
+	require.Equal(t, CommentDigest(comment), proof.Entry.Digest)
+
+	// The proof is of the latest entry
+	comment.Text = util.RandomString(140)
+	_, err = testQueries.UpdateComment(context.Background(), comment)
+	require.NoError(t, err)
+	fillCheckpoint(t, movie)
+	proof, err = testQueries.GetLedgerProof(context.Background(), comment.ID)
+	require.NoError(t, err)
+	require.NoError(t, proof.Verify())
+	require.Equal(t, ledger.OpEdit, proof.Entry.Op)
+	require.Equal(t, CommentDigest(comment), proof.Entry.Digest)
+}
+
+func TestVerifyLedger(t *testing.T) {
+	user := getUserByID(t, addUser(t, randomUser()))
+	movie := getMovieByID(t, addMovie(t, randomMovie()))
+	edited := getCommentByID(t, addComment(t, user, movie))
+	deleted := addComment(t, user, movie)
+	parent := getCommentByID(t, addComment(t, user, movie))
+	addReply(t, user, parent)
+
+	// Going through the queries is recorded
+	edited.Text = util.RandomString(140)
+	_, err := testQueries.UpdateComment(context.Background(), edited)
+	require.NoError(t, err)
+	_, err = testQueries.DeleteComment(context.Background(), deleted, user.Name)
+	require.NoError(t, err)
+	_, err = testQueries.DeleteComment(context.Background(), parent.ID, user.Name)
+	require.NoError(t, err)
+
+	problems, err := testQueries.VerifyLedger(context.Background())
+	require.NoError(t, err)
+	for _, problem := range problems {
+		require.NotContains(t, []string{edited.ID.Hex(), deleted.Hex(), parent.ID.Hex()}, problem.Ref)
+	}
+
+	// Going behind it is found
+	behind := getCommentByID(t, addComment(t, user, movie))
+	gone := addComment(t, user, movie)
+	_, err = testQueries.comments.UpdateOne(context.Background(), bson.M{"_id": behind.ID}, bson.M{"$set": bson.M{"text": "edited"}})
+	require.NoError(t, err)
+	_, err = testQueries.comments.DeleteOne(context.Background(), bson.M{"_id": gone})
+	require.NoError(t, err)
+
+	problems, err = testQueries.VerifyLedger(context.Background())
+	require.NoError(t, err)
+	reasons := make(map[string]string)
+	for _, problem := range problems {
+		reasons[problem.Ref] = problem.Reason
+	}
+	require.Equal(t, ledger.ProblemEdited, reasons[behind.ID.Hex()])
+	require.Equal(t, ledger.ProblemDeleted, reasons[gone.Hex()])
+}
+
+func TestAppendLedgerConcurrently(t *testing.T) {
+	movie := getMovieByID(t, addMovie(t, randomMovie()))
+
+	// Appends that take the same Seq run their transactions again
+	n := 10
+	ids := make(chan primitive.ObjectID, n)
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			danmaku, err := testQueries.AddDanmaku(context.Background(), AddDanmakuParams{MovieID: movie.Id, Text: util.RandomString(10), Name: util.RandomUser()})
+			errs <- err
+			ids <- danmaku.ID
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	close(ids)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	for id := range ids {
+		count, err := testQueries.ledger.CountDocuments(context.Background(), bson.M{"ref_id": id})
+		require.NoError(t, err)
+		require.Equal(t, int64(1), count)
+	}
+}
+
+func TestAddDanmakuRollsBackWithItsTx(t *testing.T) {
+	movie := getMovieByID(t, addMovie(t, randomMovie()))
+
+	var added Danmaku
+	errFailed := errors.New("failed")
+	err := testStore.ExecTx(context.Background(), func(q Querier) error {
+		var err error
+		added, err = q.AddDanmaku(context.Background(), AddDanmakuParams{MovieID: movie.Id, Text: util.RandomString(10), Name: util.RandomUser()})
+		require.NoError(t, err)
+		return errFailed
+	})
+	require.Equal(t, errFailed, err)
+
+	// Neither the danmaku nor its entry is written
+	count, err := testQueries.danmaku.CountDocuments(context.Background(), bson.M{"_id": added.ID})
+	require.NoError(t, err)
+	require.Zero(t, count)
+	count, err = testQueries.ledger.CountDocuments(context.Background(), bson.M{"ref_id": added.ID})
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
@@ -0,0 +1,146 @@
+package db
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sort"
+	"time"
+)
+
+// The lists a user can keep movies in
+const (
+	ListWatchlist = "watchlist"
+	ListFavorites = "favorites"
+)
+
+// ListEntry is a movie in one of the lists of a user. The entries of a list are
+// ordered by Position, the highest first, which starts as the time the movie
+// was added so that the latest additions come first until the list is reordered
+type ListEntry struct {
+	ID       primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	Name     string             `json:"name" bson:"name"`
+	List     string             `json:"list" bson:"list"`
+	MovieID  primitive.ObjectID `json:"movie_id" bson:"movie_id"`
+	Position int64              `json:"position" bson:"position"`
+	AddedAt  primitive.DateTime `json:"added_at" bson:"added_at"`
+}
+
+type ListEntryParams struct {
+	Name    string             `json:"name"`
+	List    string             `json:"list"`
+	MovieID primitive.ObjectID `json:"movie_id"`
+}
+
+type GetListParams struct {
+	Name  string `json:"name"`
+	List  string `json:"list"`
+	Skip  int64  `json:"skip"`
+	Limit int64  `json:"limit"`
+}
+
+// ReorderListParams puts the movies in the order of MovieIDs, first to last
+type ReorderListParams struct {
+	Name     string               `json:"name"`
+	List     string               `json:"list"`
+	MovieIDs []primitive.ObjectID `json:"movie_ids"`
+}
+
+// AddListEntry adds a movie to a list of a user,
+// a movie that is already in the list keeps its place
+func (q *Queries) AddListEntry(ctx context.Context, arg ListEntryParams) (*mongo.UpdateResult, error) {
+	now := time.Now()
+	return q.lists.UpdateOne(ctx,
+		bson.D{{"name", arg.Name}, {"list", arg.List}, {"movie_id", arg.MovieID}},
+		bson.D{{"$setOnInsert", bson.D{
+			{"position", now.UnixNano()},
+			{"added_at", primitive.NewDateTimeFromTime(now)},
+		}}},
+		options.Update().SetUpsert(true),
+	)
+}
+
+func (q *Queries) DeleteListEntry(ctx context.Context, arg ListEntryParams) (int64, error) {
+	res, err := q.lists.DeleteOne(ctx, bson.D{{"name", arg.Name}, {"list", arg.List}, {"movie_id", arg.MovieID}})
+	if err != nil {
+		return 0, err
+	}
+	if res.DeletedCount == 0 {
+		return 0, mongo.ErrNoDocuments
+	}
+	return res.DeletedCount, nil
+}
+
+// GetListEntries gets a page of the entries of a list of a user, in order
+func (q *Queries) GetListEntries(ctx context.Context, arg GetListParams) ([]ListEntry, error) {
+	findOptions := options.Find().
+		SetSort(bson.D{{"position", -1}, {"_id", -1}}).
+		SetSkip(arg.Skip).
+		SetLimit(arg.Limit)
+	cursor, err := q.lists.Find(ctx, bson.D{{"name", arg.Name}, {"list", arg.List}}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var entries []ListEntry
+	if err = cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ReorderList puts some movies of a list in a new order. They swap their places
+// among themselves, so the other movies of the list stay where they are.
+// It returns mongo.ErrNoDocuments when a movie is not in the list
+func (q *Queries) ReorderList(ctx context.Context, arg ReorderListParams) error {
+	cursor, err := q.lists.Find(ctx, bson.D{
+		{"name", arg.Name},
+		{"list", arg.List},
+		{"movie_id", bson.M{"$in": arg.MovieIDs}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var entries []ListEntry
+	if err = cursor.All(ctx, &entries); err != nil {
+		return err
+	}
+	if len(entries) != len(arg.MovieIDs) {
+		return mongo.ErrNoDocuments
+	}
+
+	positions := make([]int64, len(entries))
+	for i, entry := range entries {
+		positions[i] = entry.Position
+	}
+	sort.Slice(positions, func(i, j int) bool { return positions[i] > positions[j] })
+
+	models := make([]mongo.WriteModel, len(arg.MovieIDs))
+	for i, id := range arg.MovieIDs {
+		models[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.D{{"name", arg.Name}, {"list", arg.List}, {"movie_id", id}}).
+			SetUpdate(bson.D{{"$set", bson.D{{"position", positions[i]}}}})
+	}
+	_, err = q.lists.BulkWrite(ctx, models)
+	return err
+}
+
+// GetListsByMovie gets the names of the lists of a user that have the movie
+func (q *Queries) GetListsByMovie(ctx context.Context, name string, movieID primitive.ObjectID) ([]string, error) {
+	values, err := q.lists.Distinct(ctx, "list", bson.D{{"name", name}, {"movie_id", movieID}})
+	if err != nil {
+		return nil, err
+	}
+	lists := make([]string, 0, len(values))
+	for _, value := range values {
+		if list, ok := value.(string); ok {
+			lists = append(lists, list)
+		}
+	}
+	return lists, nil
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"phantom/util"
+	"testing"
+)
+
+func addListEntry(t *testing.T, name, list string, movieID primitive.ObjectID) {
+	_, err := testQueries.AddListEntry(context.Background(), ListEntryParams{Name: name, List: list, MovieID: movieID})
+	require.NoError(t, err)
+}
+
+func requireListOrder(t *testing.T, name, list string, ids ...primitive.ObjectID) {
+	entries, err := testQueries.GetListEntries(context.Background(), GetListParams{Name: name, List: list, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, entries, len(ids))
+	for i := range ids {
+		require.Equal(t, ids[i], entries[i].MovieID)
+	}
+}
+
+func TestListEntries(t *testing.T) {
+	name := util.RandomUser()
+	first := addMovie(t, randomMovie())
+	second := addMovie(t, randomMovie())
+	third := addMovie(t, randomMovie())
+	for _, id := range []primitive.ObjectID{first, second, third} {
+		addListEntry(t, name, ListWatchlist, id)
+	}
+	addListEntry(t, name, ListFavorites, second)
+
+	// Adding a movie again keeps its place
+	addListEntry(t, name, ListWatchlist, first)
+	requireListOrder(t, name, ListWatchlist, third, second, first)
+	requireListOrder(t, name, ListFavorites, second)
+
+	lists, err := testQueries.GetListsByMovie(context.Background(), name, second)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{ListWatchlist, ListFavorites}, lists)
+
+	_, err = testQueries.DeleteListEntry(context.Background(), ListEntryParams{Name: name, List: ListWatchlist, MovieID: second})
+	require.NoError(t, err)
+	_, err = testQueries.DeleteListEntry(context.Background(), ListEntryParams{Name: name, List: ListWatchlist, MovieID: second})
+	require.ErrorIs(t, err, mongo.ErrNoDocuments)
+	requireListOrder(t, name, ListWatchlist, third, first)
+}
+
+func TestReorderList(t *testing.T) {
+	name := util.RandomUser()
+	var ids []primitive.ObjectID
+	for i := 0; i < 4; i++ {
+		id := addMovie(t, randomMovie())
+		addListEntry(t, name, ListWatchlist, id)
+		ids = append(ids, id)
+	}
+	requireListOrder(t, name, ListWatchlist, ids[3], ids[2], ids[1], ids[0])
+
+	// Only the movies given move, among the places they had
+	err := testQueries.ReorderList(context.Background(), ReorderListParams{
+		Name:     name,
+		List:     ListWatchlist,
+		MovieIDs: []primitive.ObjectID{ids[0], ids[2]},
+	})
+	require.NoError(t, err)
+	requireListOrder(t, name, ListWatchlist, ids[3], ids[0], ids[1], ids[2])
+
+	// A movie added afterwards comes first
+	latest := addMovie(t, randomMovie())
+	addListEntry(t, name, ListWatchlist, latest)
+	requireListOrder(t, name, ListWatchlist, latest, ids[3], ids[0], ids[1], ids[2])
+
+	err = testQueries.ReorderList(context.Background(), ReorderListParams{
+		Name:     name,
+		List:     ListWatchlist,
+		MovieIDs: []primitive.ObjectID{ids[0], primitive.NewObjectID()},
+	})
+	require.ErrorIs(t, err, mongo.ErrNoDocuments)
+}
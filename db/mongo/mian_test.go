@@ -12,6 +12,7 @@ import (
 )
 
 var testQueries *Queries
+var testStore *MongoStore
 var testDatabase *mongo.Database
 
 func TestMain(m *testing.M) {
@@ -31,7 +32,11 @@ func TestMain(m *testing.M) {
 	}
 
 	testDatabase = mongoClient.Database("phantom")
+	if _, err = NewMigrator(testDatabase).Up(ctx); err != nil {
+		log.Fatal("cannot migrate mongodb: ", err)
+	}
 	testQueries = NewMongoQueries(testDatabase)
+	testStore = &MongoStore{Queries: testQueries, client: mongoClient, database: testDatabase}
 
 	os.Exit(m.Run())
 }
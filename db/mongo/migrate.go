@@ -0,0 +1,303 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"os"
+	"sort"
+	"time"
+)
+
+var (
+	ErrMigrationsPending = errors.New("the database has migrations to run, run 'migrate up'")
+	ErrMigrationLocked   = errors.New("another migration is running")
+	ErrNoMigration       = errors.New("no migration is applied")
+)
+
+// migrationLockTTL is how long a lock is held before another migrator
+// may take it over, in case its owner died without releasing it
+const migrationLockTTL = 30 * time.Minute
+
+// Migration changes the schema of the database from the version before it to
+// Version. Down undoes Up, and never drops the data of the collections
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(ctx context.Context, db *mongo.Database) error
+	Down    func(ctx context.Context, db *mongo.Database) error
+}
+
+// MigrationStatus tells whether a migration is applied. A dirty migration
+// started but didn't finish, and is run again by Up
+type MigrationStatus struct {
+	Version   int64              `json:"version"`
+	Name      string             `json:"name"`
+	Applied   bool               `json:"applied"`
+	Dirty     bool               `json:"dirty"`
+	AppliedAt primitive.DateTime `json:"applied_at,omitempty"`
+}
+
+// migrationRecord is the document of an applied migration in 'schema_migrations'
+type migrationRecord struct {
+	Version   int64              `bson:"_id"`
+	Name      string             `bson:"name"`
+	Dirty     bool               `bson:"dirty"`
+	AppliedAt primitive.DateTime `bson:"applied_at"`
+}
+
+// Migrator runs the migrations of the database, a lock in the database lets
+// a single migrator run them at a time
+type Migrator struct {
+	db         *mongo.Database
+	migrations []Migration
+	records    *mongo.Collection
+	lock       *mongo.Collection
+	owner      string
+}
+
+func NewMigrator(database *mongo.Database) *Migrator {
+	return newMigrator(database, migrations)
+}
+
+func newMigrator(database *mongo.Database, list []Migration) *Migrator {
+	sorted := make([]Migration, len(list))
+	copy(sorted, list)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	host, _ := os.Hostname()
+	return &Migrator{
+		db:         database,
+		migrations: sorted,
+		records:    database.Collection("schema_migrations"),
+		lock:       database.Collection("schema_migrations_lock"),
+		owner:      fmt.Sprintf("%s:%d:%s", host, os.Getpid(), primitive.NewObjectID().Hex()),
+	}
+}
+
+// Status lists the migrations known to the server, in order
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	records, err := m.appliedRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]MigrationStatus, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i] = MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if record, ok := records[migration.Version]; ok {
+			statuses[i].Applied = !record.Dirty
+			statuses[i].Dirty = record.Dirty
+			statuses[i].AppliedAt = record.AppliedAt
+		}
+	}
+	return statuses, nil
+}
+
+// Check returns ErrMigrationsPending when a migration is not applied or is
+// dirty, and an error when the database has a migration the server doesn't
+// know of, as a newer server migrated it
+func (m *Migrator) Check(ctx context.Context) error {
+	records, err := m.appliedRecords(ctx)
+	if err != nil {
+		return err
+	}
+	if err = m.checkUnknown(records); err != nil {
+		return err
+	}
+	for _, migration := range m.migrations {
+		if record, ok := records[migration.Version]; !ok || record.Dirty {
+			return ErrMigrationsPending
+		}
+	}
+	return nil
+}
+
+// Up runs the migrations that are not applied or are dirty, in order, and
+// returns the ones it ran. It stops at the first that fails, which is left dirty
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(ctx, func() error {
+		var err error
+		applied, err = m.up(ctx)
+		return err
+	})
+	return applied, err
+}
+
+// Redo runs the migrations after the version again, and then the ones that
+// are not applied, like Up. It is for a database whose data was put back
+// from an older schema, such as by a restore
+func (m *Migrator) Redo(ctx context.Context, after int64) ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(ctx, func() error {
+		_, err := m.records.UpdateMany(ctx, bson.M{"_id": bson.M{"$gt": after}}, bson.M{"$set": bson.M{"dirty": true}})
+		if err != nil {
+			return err
+		}
+		applied, err = m.up(ctx)
+		return err
+	})
+	return applied, err
+}
+
+func (m *Migrator) up(ctx context.Context) ([]Migration, error) {
+	records, err := m.appliedRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = m.checkUnknown(records); err != nil {
+		return nil, err
+	}
+	var applied []Migration
+	for _, migration := range m.migrations {
+		if record, ok := records[migration.Version]; ok && !record.Dirty {
+			continue
+		}
+		if err = m.run(ctx, migration, migration.Up); err != nil {
+			return applied, fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+		if err = m.setApplied(ctx, migration); err != nil {
+			return applied, err
+		}
+		applied = append(applied, migration)
+	}
+	return applied, nil
+}
+
+// Version is the version of the latest migration that is applied and not dirty,
+// or zero when there is none
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	records, err := m.appliedRecords(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var version int64
+	for _, record := range records {
+		if !record.Dirty && record.Version > version {
+			version = record.Version
+		}
+	}
+	return version, nil
+}
+
+// Latest is the version of the latest migration the server knows of
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Down undoes the latest applied migration and returns it,
+// it returns ErrNoMigration when none is applied
+func (m *Migrator) Down(ctx context.Context) (Migration, error) {
+	var undone Migration
+	err := m.withLock(ctx, func() error {
+		records, err := m.appliedRecords(ctx)
+		if err != nil {
+			return err
+		}
+		if err = m.checkUnknown(records); err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := records[migration.Version]; !ok {
+				continue
+			}
+			if err = m.run(ctx, migration, migration.Down); err != nil {
+				return fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+			}
+			if _, err = m.records.DeleteOne(ctx, bson.M{"_id": migration.Version}); err != nil {
+				return err
+			}
+			undone = migration
+			return nil
+		}
+		return ErrNoMigration
+	})
+	return undone, err
+}
+
+// run marks the migration dirty before it runs fn, so a migration that
+// stops partway is known to be so
+func (m *Migrator) run(ctx context.Context, migration Migration, fn func(ctx context.Context, db *mongo.Database) error) error {
+	_, err := m.records.UpdateOne(ctx,
+		bson.M{"_id": migration.Version},
+		bson.M{"$set": bson.M{"name": migration.Name, "dirty": true}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+	return fn(ctx, m.db)
+}
+
+func (m *Migrator) setApplied(ctx context.Context, migration Migration) error {
+	_, err := m.records.UpdateOne(ctx,
+		bson.M{"_id": migration.Version},
+		bson.M{"$set": bson.M{"dirty": false, "applied_at": primitive.NewDateTimeFromTime(time.Now())}})
+	return err
+}
+
+func (m *Migrator) appliedRecords(ctx context.Context) (map[int64]migrationRecord, error) {
+	cursor, err := m.records.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	var list []migrationRecord
+	if err = cursor.All(ctx, &list); err != nil {
+		return nil, err
+	}
+	records := make(map[int64]migrationRecord, len(list))
+	for _, record := range list {
+		records[record.Version] = record
+	}
+	return records, nil
+}
+
+// checkUnknown fails on an applied migration the server doesn't know of
+func (m *Migrator) checkUnknown(records map[int64]migrationRecord) error {
+	known := make(map[int64]bool, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+	}
+	for version := range records {
+		if !known[version] {
+			return fmt.Errorf("the database has migration %d, which is newer than the server", version)
+		}
+	}
+	return nil
+}
+
+// withLock runs fn while holding the lock of the migrations,
+// it returns ErrMigrationLocked when another migrator holds it
+func (m *Migrator) withLock(ctx context.Context, fn func() error) error {
+	if err := m.acquireLock(ctx); err != nil {
+		return err
+	}
+	defer m.lock.DeleteOne(context.Background(), bson.M{"_id": "migrate", "owner": m.owner})
+	return fn()
+}
+
+func (m *Migrator) acquireLock(ctx context.Context) error {
+	now := time.Now()
+	expiresAt := primitive.NewDateTimeFromTime(now.Add(migrationLockTTL))
+	_, err := m.lock.InsertOne(ctx, bson.M{"_id": "migrate", "owner": m.owner, "expires_at": expiresAt})
+	if !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+	// A lock whose owner died is taken over once it expires
+	res, err := m.lock.UpdateOne(ctx,
+		bson.M{"_id": "migrate", "expires_at": bson.M{"$lt": primitive.NewDateTimeFromTime(now)}},
+		bson.M{"$set": bson.M{"owner": m.owner, "expires_at": expiresAt}})
+	if err != nil {
+		return err
+	}
+	if res.ModifiedCount == 0 {
+		return ErrMigrationLocked
+	}
+	return nil
+}
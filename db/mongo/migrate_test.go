@@ -0,0 +1,165 @@
+package db
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"phantom/util"
+	"testing"
+	"time"
+)
+
+// migrationTestDatabase is a database of its own, so the migrations start from nothing
+func migrationTestDatabase(t *testing.T) *mongo.Database {
+	database := testDatabase.Client().Database("phantom_migrate_" + util.RandomString(8))
+	t.Cleanup(func() { database.Drop(context.Background()) })
+	return database
+}
+
+func TestMigrateUpAndDown(t *testing.T) {
+	ctx := context.Background()
+	database := migrationTestDatabase(t)
+	migrator := NewMigrator(database)
+
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, len(migrations))
+	for _, status := range statuses {
+		require.False(t, status.Applied)
+	}
+	require.Equal(t, ErrMigrationsPending, migrator.Check(ctx))
+
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	require.Len(t, applied, len(migrations))
+	require.NoError(t, migrator.Check(ctx))
+
+	// The validators are in place
+	_, err = database.Collection("movies").InsertOne(ctx, bson.M{"year": 2000})
+	require.Error(t, err)
+
+	// Up is a no-op once everything is applied
+	applied, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	require.Empty(t, applied)
+
+	undone, err := migrator.Down(ctx)
+	require.NoError(t, err)
+	require.Equal(t, migrations[len(migrations)-1].Version, undone.Version)
+	require.Equal(t, ErrMigrationsPending, migrator.Check(ctx))
+
+	applied, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	require.Len(t, applied, 1)
+	require.NoError(t, migrator.Check(ctx))
+}
+
+func TestMigrateKeepsData(t *testing.T) {
+	ctx := context.Background()
+	database := migrationTestDatabase(t)
+	movie := randomMovie()
+	_, err := database.Collection("movies").InsertOne(ctx, movie)
+	require.NoError(t, err)
+
+	migrator := NewMigrator(database)
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	for range migrations {
+		_, err = migrator.Down(ctx)
+		require.NoError(t, err)
+	}
+	_, err = migrator.Down(ctx)
+	require.Equal(t, ErrNoMigration, err)
+
+	n, err := database.Collection("movies").CountDocuments(ctx, bson.M{"title": movie.Title})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+}
+
+func TestMigrateMovieSortTitles(t *testing.T) {
+	ctx := context.Background()
+	database := migrationTestDatabase(t)
+	movie := randomMovie()
+	movie.Title = "The " + movie.Title
+	movie.SortTitle = ""
+	_, err := database.Collection("movies").InsertOne(ctx, movie)
+	require.NoError(t, err)
+
+	_, err = NewMigrator(database).Up(ctx)
+	require.NoError(t, err)
+
+	var got Movies
+	err = database.Collection("movies").FindOne(ctx, bson.M{"title": movie.Title}).Decode(&got)
+	require.NoError(t, err)
+	require.Equal(t, util.SortTitle(movie.Title), got.SortTitle)
+}
+
+func TestMigrateDirtyAndUnknown(t *testing.T) {
+	ctx := context.Background()
+	database := migrationTestDatabase(t)
+	runs := 0
+	failing := []Migration{{
+		Version: 1,
+		Name:    "fails once",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			runs++
+			if runs == 1 {
+				return ErrMigrationsPending
+			}
+			return nil
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error { return nil },
+	}}
+	migrator := newMigrator(database, failing)
+
+	_, err := migrator.Up(ctx)
+	require.Error(t, err)
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	require.True(t, statuses[0].Dirty)
+
+	// A dirty migration is run again
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	require.Len(t, applied, 1)
+	require.Equal(t, 2, runs)
+
+	// A server without the migration refuses the database
+	require.Error(t, newMigrator(database, nil).Check(ctx))
+}
+
+func TestMigrateLock(t *testing.T) {
+	ctx := context.Background()
+	database := migrationTestDatabase(t)
+	migrator := NewMigrator(database)
+	other := NewMigrator(database)
+
+	require.NoError(t, other.acquireLock(ctx))
+	_, err := migrator.Up(ctx)
+	require.Equal(t, ErrMigrationLocked, err)
+
+	// An expired lock is taken over
+	_, err = database.Collection("schema_migrations_lock").UpdateOne(ctx, bson.M{"_id": "migrate"},
+		bson.M{"$set": bson.M{"expires_at": time.Now().Add(-time.Minute)}})
+	require.NoError(t, err)
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+}
+
+func TestMigrateRedo(t *testing.T) {
+	ctx := context.Background()
+	database := migrationTestDatabase(t)
+	migrator := NewMigrator(database)
+	_, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	version, err := migrator.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, migrator.Latest(), version)
+
+	// The migrations after the first run again, as if the data had the first schema
+	applied, err := migrator.Redo(ctx, migrations[0].Version)
+	require.NoError(t, err)
+	require.Len(t, applied, len(migrations)-1)
+	require.NoError(t, migrator.Check(ctx))
+}
@@ -0,0 +1,394 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"phantom/util"
+)
+
+// migrations are the changes to the schema, in order. A migration is never
+// changed once released, a new one is added instead. Up and Down may be run
+// again after they fail partway, see Migrator.Up
+var migrations = []Migration{
+	{Version: 1, Name: "validators and indexes", Up: upValidatorsAndIndexes, Down: downValidatorsAndIndexes},
+	{Version: 2, Name: "movie keys", Up: upMovieKeys, Down: downMovieKeys},
+	{Version: 3, Name: "movie sort titles", Up: upMovieSortTitles, Down: downMovieSortTitles},
+}
+
+// The codes MongoDB gives the commands a migration runs
+const (
+	codeNamespaceNotFound = 26
+	codeIndexNotFound     = 27
+	codeNamespaceExists   = 48
+)
+
+// moviesTextIndex is the name MongoDB gives the text index of 'movies'
+const moviesTextIndex = "cast_text_fullplot_text_genres_text_title_text_original_title_text_titles.title_text"
+
+// validatedCollections are the collections migration 1 adds a validator to
+var validatedCollections = []string{"users", "movies", "comments"}
+
+// indexedCollections are the collections migration 1 adds indexes to
+var indexedCollections = []string{
+	"users", "movies", "people", "seasons", "episodes", "progress", "history", "collections",
+	"lists", "ratings", "comments", "reactions", "reports", "moderation_log", "danmaku",
+	"ledger", "ledger_checkpoints", "coin_transactions", "title_requests",
+}
+
+// upValidatorsAndIndexes makes the validators and indexes the server used to
+// make each time it started. The collections of a database that had those
+// keep their data, and get the validators and indexes they lack
+func upValidatorsAndIndexes(ctx context.Context, db *mongo.Database) error {
+	s := &schema{ctx: ctx, db: db}
+
+	// Require that the data inserted into the 'users' collection
+	// must contain both 'email' and 'password' fields
+	usersValidatorModels := &options.CreateCollectionOptions{Validator: bson.D{{
+		"$jsonSchema", bson.D{
+			{"bsonType", "object"},
+			{"required", []string{"email", "password"}},
+			{"properties", bson.D{
+				{"email", bson.D{
+					{"bsonType", "string"},
+					{"description", "must be a string and is required"},
+				}},
+				{"password", bson.D{
+					{"bsonType", "string"},
+					{"description", "must be a string and is required"},
+				}},
+			}},
+		},
+	}}}
+	s.validator("users", usersValidatorModels)
+
+	// Create unique indexes for the 'name' and 'email' fields
+	// in the 'users' collection
+	userIndexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.M{"name": 1},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.M{"email": 1},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+	s.indexes("users", userIndexModels...)
+
+	// Requires that the data inserted into the 'movies' collection
+	// must contain the 'title' field
+	moviesValidatorModels := &options.CreateCollectionOptions{Validator: bson.D{{
+		"$jsonSchema", bson.D{
+			{"bsonType", "object"},
+			{"required", []string{"title"}},
+			{"properties", bson.D{{
+				"title", bson.D{
+					{"bsonType", "string"},
+					{"description", "must be a string and is required"},
+				},
+			}}},
+		},
+	}}}
+
+	// Create text indexes for the 'cast', 'fullplot', 'genres',
+	// 'title', 'original_title' and localized title fields in the 'movies' collection,
+	// as well as a general index for the runtime field.
+	// A collection has a single text index, an older one without the
+	// title fields has to be dropped before this one can be created
+	s.validator("movies", moviesValidatorModels)
+	s.dropTextIndexesBut("movies", moviesTextIndex)
+	moviesIndexModels := []mongo.IndexModel{
+		{Keys: bson.M{"runtime": -1}},
+		{Keys: bson.M{"credits.person_id": 1}},
+		{Keys: bson.D{
+			{"cast", "text"},
+			{"fullplot", "text"},
+			{"genres", "text"},
+			{"title", "text"},
+			{"original_title", "text"},
+			{"titles.title", "text"},
+		}},
+	}
+	s.indexes("movies", moviesIndexModels...)
+
+	// Create indexes for the 'name' and 'aliases' fields in the 'people' collection,
+	// names are not unique because two people can share one
+	peopleIndexModels := []mongo.IndexModel{
+		{Keys: bson.M{"name": 1}},
+		{Keys: bson.M{"aliases": 1}},
+	}
+	s.indexes("people", peopleIndexModels...)
+
+	// A series has one season of each number, and one episode
+	// of each number in a season
+	seasonsIndexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{"series_id", 1}, {"number", 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+	s.indexes("seasons", seasonsIndexModels...)
+	episodesIndexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{"series_id", 1}, {"season", 1}, {"number", 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{"series_id", 1}, {"absolute_number", 1}}},
+	}
+	s.indexes("episodes", episodesIndexModels...)
+
+	// A user has one progress for each movie or episode
+	progressIndexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{"name", 1}, {"media_id", 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{"name", 1}, {"series_id", 1}, {"updated_at", -1}}},
+		{Keys: bson.D{{"name", 1}, {"watched", 1}, {"updated_at", -1}}},
+	}
+	s.indexes("progress", progressIndexModels...)
+
+	// Create an index for the 'name' and 'watched_at' fields in the 'history' collection,
+	// to page through the timeline of a user
+	s.indexes("history", mongo.IndexModel{Keys: bson.D{{"name", 1}, {"watched_at", -1}}})
+
+	// Create an index for the 'movie_ids' field in the 'collections' collection,
+	// to find the collections of a movie
+	s.indexes("collections", mongo.IndexModel{Keys: bson.M{"movie_ids": 1}})
+
+	// A movie is in a list of a user at most once
+	listsIndexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{"name", 1}, {"list", 1}, {"movie_id", 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{"name", 1}, {"list", 1}, {"position", -1}}},
+	}
+	s.indexes("lists", listsIndexModels...)
+
+	// A user rates a movie once
+	ratingsIndexModel := mongo.IndexModel{
+		Keys:    bson.D{{"name", 1}, {"movie_id", 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	s.indexes("ratings", ratingsIndexModel)
+
+	// Requires that the data inserted into the 'comments' collection
+	// must contain the 'text' field
+	commentsValidatorModels := &options.CreateCollectionOptions{Validator: bson.D{{
+		"$jsonSchema", bson.D{
+			{"bsonType", "object"},
+			{"required", []string{"text"}},
+			{"properties", bson.D{{
+				"text", bson.D{
+					{"bsonType", "string"},
+					{"description", "must be a string and is required"},
+				},
+			}}},
+		},
+	}}}
+	s.validator("comments", commentsValidatorModels)
+
+	// Create an index for the 'parent_id' field in the 'comments' collection,
+	// to find the replies to a comment
+	s.indexes("comments", mongo.IndexModel{Keys: bson.D{{"parent_id", 1}, {"_id", 1}}})
+
+	// A user leaves each kind of reaction once on a comment
+	reactionsIndexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{"comment_id", 1}, {"name", 1}, {"reaction", 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{"name", 1}, {"comment_id", 1}}},
+	}
+	s.indexes("reactions", reactionsIndexModels...)
+
+	// A user reports a comment once
+	reportsIndexModel := mongo.IndexModel{
+		Keys:    bson.D{{"comment_id", 1}, {"name", 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	s.indexes("reports", reportsIndexModel)
+
+	// Create an index for the 'report_count' field in the 'comments' collection,
+	// for the moderation queue
+	s.indexes("comments", mongo.IndexModel{Keys: bson.D{{"hidden", -1}, {"report_count", -1}}})
+	// and for the comments the content filter flagged
+	s.indexes("comments", mongo.IndexModel{
+		Keys:    bson.D{{"flags", 1}},
+		Options: options.Index().SetSparse(true),
+	})
+	s.indexes("moderation_log", mongo.IndexModel{Keys: bson.D{{"created_at", -1}}})
+
+	// The player gets the danmaku of a movie a time window at a time
+	s.indexes("danmaku", mongo.IndexModel{Keys: bson.D{{"movie_id", 1}, {"offset", 1}}})
+
+	// The ledger is numbered by _id, and a proof is of the latest entry of a comment or a danmaku
+	s.indexes("ledger", mongo.IndexModel{Keys: bson.D{{"ref_id", 1}, {"_id", -1}}})
+	// and a checkpoint is found by the entries it is of
+	s.indexes("ledger_checkpoints", mongo.IndexModel{Keys: bson.D{{"to", 1}}})
+
+	// A coin transaction is made once for each idempotency key,
+	// and the history of an account is found by its postings
+	coinTransactionsIndexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{"key", 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{"postings.account", 1}, {"created_at", -1}}},
+	}
+	s.indexes("coin_transactions", coinTransactionsIndexModels...)
+
+	// A title request is paid for by a transaction of its own
+	titleRequestsIndexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{"transaction_id", 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{"created_at", -1}}},
+	}
+	s.indexes("title_requests", titleRequestsIndexModels...)
+
+	return s.err
+}
+
+// downValidatorsAndIndexes drops the validators and the indexes of
+// migration 1, the collections and their documents are kept
+func downValidatorsAndIndexes(ctx context.Context, db *mongo.Database) error {
+	for _, name := range indexedCollections {
+		_, err := db.Collection(name).Indexes().DropAll(ctx)
+		if err != nil && !isCommandError(err, codeNamespaceNotFound) {
+			return err
+		}
+	}
+	for _, name := range validatedCollections {
+		err := db.RunCommand(ctx, bson.D{{"collMod", name}, {"validator", bson.D{}}}).Err()
+		if err != nil && !isCommandError(err, codeNamespaceNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// movieKeyIndexes are the indexes UpsertMovies finds a movie by, see MovieKey.
+// They aren't unique, as catalogues that were imported before may repeat a key
+var movieKeyIndexes = []mongo.IndexModel{
+	{Keys: bson.D{{"imdb.id", 1}}, Options: options.Index().SetName("imdb.id_1")},
+	{Keys: bson.D{{"title", 1}, {"year", 1}}, Options: options.Index().SetName("title_1_year_1")},
+}
+
+func upMovieKeys(ctx context.Context, db *mongo.Database) error {
+	s := &schema{ctx: ctx, db: db}
+	s.indexes("movies", movieKeyIndexes...)
+	return s.err
+}
+
+func downMovieKeys(ctx context.Context, db *mongo.Database) error {
+	for _, model := range movieKeyIndexes {
+		_, err := db.Collection("movies").Indexes().DropOne(ctx, *model.Options.Name)
+		if err != nil && !isCommandError(err, codeNamespaceNotFound) && !isCommandError(err, codeIndexNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// upMovieSortTitles sets the sort title of the movies that were added
+// before movies had one, a movie that has one already is left as it is
+func upMovieSortTitles(ctx context.Context, db *mongo.Database) error {
+	movies := db.Collection("movies")
+	filter := bson.M{"sort_title": bson.M{"$exists": false}}
+	cursor, err := movies.Find(ctx, filter, options.Find().SetProjection(bson.M{"title": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var models []mongo.WriteModel
+	for cursor.Next(ctx) {
+		var movie struct {
+			ID    interface{} `bson:"_id"`
+			Title string      `bson:"title"`
+		}
+		if err = cursor.Decode(&movie); err != nil {
+			return err
+		}
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": movie.ID}).
+			SetUpdate(bson.M{"$set": bson.M{"sort_title": util.SortTitle(movie.Title)}}))
+	}
+	if err = cursor.Err(); err != nil || len(models) == 0 {
+		return err
+	}
+	_, err = movies.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// downMovieSortTitles keeps the sort titles, the movies added since
+// migration 3 have one all the same
+func downMovieSortTitles(ctx context.Context, db *mongo.Database) error {
+	return nil
+}
+
+// schema makes the validators and indexes of a migration,
+// it stops at the first error, which is left in err
+type schema struct {
+	ctx context.Context
+	db  *mongo.Database
+	err error
+}
+
+// validator makes the collection with the validator,
+// or sets the validator of the collection when it is there already
+func (s *schema) validator(name string, validator *options.CreateCollectionOptions) {
+	if s.err != nil {
+		return
+	}
+	s.err = s.db.CreateCollection(s.ctx, name, validator)
+	if isCommandError(s.err, codeNamespaceExists) {
+		s.err = s.db.RunCommand(s.ctx, bson.D{{"collMod", name}, {"validator", validator.Validator}}).Err()
+	}
+}
+
+// indexes makes the indexes of the collection, an index that is there
+// already is left as it is, and one with the same keys and other options
+// is an error
+func (s *schema) indexes(name string, models ...mongo.IndexModel) {
+	if s.err != nil {
+		return
+	}
+	_, s.err = s.db.Collection(name).Indexes().CreateMany(s.ctx, models)
+}
+
+// dropTextIndexesBut drops the text indexes of the collection but the one named keep
+func (s *schema) dropTextIndexesBut(name, keep string) {
+	if s.err != nil {
+		return
+	}
+	var indexes []struct {
+		Name string `bson:"name"`
+		Key  bson.M `bson:"key"`
+	}
+	cursor, err := s.db.Collection(name).Indexes().List(s.ctx)
+	if err == nil {
+		err = cursor.All(s.ctx, &indexes)
+	}
+	for _, index := range indexes {
+		if err != nil {
+			break
+		}
+		if index.Key["_fts"] == "text" && index.Name != keep {
+			_, err = s.db.Collection(name).Indexes().DropOne(s.ctx, index.Name)
+		}
+	}
+	s.err = err
+}
+
+// isCommandError tells whether err is the error of a command with the code
+func isCommandError(err error, code int32) bool {
+	var cmdErr mongo.CommandError
+	return errors.As(err, &cmdErr) && cmdErr.Code == code
+}
@@ -0,0 +1,210 @@
+package db
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// The reasons a user can report a comment for
+const (
+	ReportReasonSpam    = "spam"
+	ReportReasonAbuse   = "abuse"
+	ReportReasonSpoiler = "spoiler"
+	ReportReasonOther   = "other"
+)
+
+// The actions the moderation log records
+const (
+	ModerationHide    = "hide"
+	ModerationFlag    = "flag"
+	ModerationApprove = "approve"
+	ModerationRemove  = "remove"
+	ModerationBan     = "ban"
+)
+
+// ModeratorSystem is the moderator of the actions the server takes by itself
+const ModeratorSystem = "system"
+
+// CommentReport is a report of a comment by a user, who reports a comment once
+// until a moderator reviews it
+type CommentReport struct {
+	ID        primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	CommentID primitive.ObjectID `json:"comment_id" bson:"comment_id"`
+	Name      string             `json:"name" bson:"name"`
+	Reason    string             `json:"reason" bson:"reason"`
+	Note      string             `json:"note" bson:"note,omitempty"`
+	CreatedAt primitive.DateTime `json:"created_at" bson:"created_at"`
+}
+
+// ReportCommentParams reports a comment, which is hidden once it has
+// HideThreshold reports
+type ReportCommentParams struct {
+	CommentID     primitive.ObjectID `json:"comment_id"`
+	Name          string             `json:"name"`
+	Reason        string             `json:"reason"`
+	Note          string             `json:"note"`
+	HideThreshold int64              `json:"hide_threshold"`
+}
+
+// ReportComment adds a report of a user to a comment and counts it on the comment,
+// hiding the comment when it has enough reports. It returns the comment after
+// the report, or a duplicate key error when the user had already reported it
+func (q *Queries) ReportComment(ctx context.Context, arg ReportCommentParams) (Comments, error) {
+	_, err := q.reports.InsertOne(ctx, CommentReport{
+		CommentID: arg.CommentID,
+		Name:      arg.Name,
+		Reason:    arg.Reason,
+		Note:      arg.Note,
+		CreatedAt: primitive.NewDateTimeFromTime(time.Now()),
+	})
+	if err != nil {
+		return Comments{}, err
+	}
+
+	var comment Comments
+	err = q.comments.FindOneAndUpdate(ctx, bson.M{"_id": arg.CommentID}, mongo.Pipeline{
+		{{"$set", bson.D{
+			{"reports." + arg.Reason, bson.D{{"$add", bson.A{bson.D{{"$ifNull", bson.A{"$reports." + arg.Reason, 0}}}, 1}}}},
+			{"report_count", bson.D{{"$add", bson.A{bson.D{{"$ifNull", bson.A{"$report_count", 0}}}, 1}}}},
+		}}},
+		{{"$set", bson.D{
+			{"hidden", bson.D{{"$or", bson.A{
+				bson.D{{"$eq", bson.A{"$hidden", true}}},
+				bson.D{{"$gte", bson.A{"$report_count", arg.HideThreshold}}},
+			}}}},
+		}}},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&comment)
+	if err != nil {
+		return Comments{}, err
+	}
+	return comment, nil
+}
+
+type GetModerationQueueParams struct {
+	Skip  int64 `json:"skip"`
+	Limit int64 `json:"limit"`
+}
+
+// GetModerationQueue gets a page of the reported or flagged comments waiting
+// for review, the hidden ones first, then the most reported
+func (q *Queries) GetModerationQueue(ctx context.Context, arg GetModerationQueueParams) ([]Comments, error) {
+	findOptions := options.Find().
+		SetSort(bson.D{{"hidden", -1}, {"report_count", -1}, {"_id", 1}}).
+		SetSkip(arg.Skip).
+		SetLimit(arg.Limit)
+	filter := bson.M{
+		"$or": bson.A{
+			bson.M{"report_count": bson.M{"$gt": 0}},
+			bson.M{"flags": bson.M{"$exists": true}},
+		},
+		"deleted": bson.M{"$ne": true},
+	}
+	cursor, err := q.comments.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var comments []Comments
+	if err = cursor.All(ctx, &comments); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// ApproveComment shows a reported comment again and clears its reports
+// and flags, so that users can report it again. It returns mongo.ErrNoDocuments
+// when there is no such comment
+func (q *Queries) ApproveComment(ctx context.Context, id primitive.ObjectID) error {
+	res, err := q.comments.UpdateByID(ctx, id, bson.D{
+		{"$unset", bson.D{{"hidden", ""}, {"reports", ""}, {"report_count", ""}, {"flags", ""}}},
+	})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	_, err = q.reports.DeleteMany(ctx, bson.M{"comment_id": id})
+	return err
+}
+
+// ModerationLogEntry records an action of a moderator on a comment, or on
+// a danmaku when DanmakuID is set, and the user who wrote it
+type ModerationLogEntry struct {
+	ID        primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	Moderator string             `json:"moderator" bson:"moderator"`
+	Action    string             `json:"action" bson:"action"`
+	CommentID primitive.ObjectID `json:"comment_id" bson:"comment_id,omitempty"`
+	DanmakuID primitive.ObjectID `json:"danmaku_id" bson:"danmaku_id,omitempty"`
+	Author    string             `json:"author" bson:"author"`
+	CreatedAt primitive.DateTime `json:"created_at" bson:"created_at"`
+}
+
+type AddModerationLogParams struct {
+	Moderator string             `json:"moderator"`
+	Action    string             `json:"action"`
+	CommentID primitive.ObjectID `json:"comment_id"`
+	DanmakuID primitive.ObjectID `json:"danmaku_id"`
+	Author    string             `json:"author"`
+}
+
+// GetCommentReporters gets the names of the users who reported a comment,
+// until a moderator reviews it
+func (q *Queries) GetCommentReporters(ctx context.Context, commentID primitive.ObjectID) ([]string, error) {
+	cursor, err := q.reports.Find(ctx, bson.M{"comment_id": commentID}, options.Find().SetSort(bson.D{{"_id", 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var reports []CommentReport
+	if err = cursor.All(ctx, &reports); err != nil {
+		return nil, err
+	}
+	names := make([]string, len(reports))
+	for i, report := range reports {
+		names[i] = report.Name
+	}
+	return names, nil
+}
+
+func (q *Queries) AddModerationLog(ctx context.Context, arg AddModerationLogParams) error {
+	_, err := q.moderationLog.InsertOne(ctx, ModerationLogEntry{
+		Moderator: arg.Moderator,
+		Action:    arg.Action,
+		CommentID: arg.CommentID,
+		DanmakuID: arg.DanmakuID,
+		Author:    arg.Author,
+		CreatedAt: primitive.NewDateTimeFromTime(time.Now()),
+	})
+	return err
+}
+
+type GetModerationLogParams struct {
+	Skip  int64 `json:"skip"`
+	Limit int64 `json:"limit"`
+}
+
+// GetModerationLog gets a page of the moderation log, the latest first
+func (q *Queries) GetModerationLog(ctx context.Context, arg GetModerationLogParams) ([]ModerationLogEntry, error) {
+	findOptions := options.Find().
+		SetSort(bson.D{{"created_at", -1}, {"_id", -1}}).
+		SetSkip(arg.Skip).
+		SetLimit(arg.Limit)
+	cursor, err := q.moderationLog.Find(ctx, bson.M{}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var entries []ModerationLogEntry
+	if err = cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
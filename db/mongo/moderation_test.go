@@ -0,0 +1,123 @@
+package db
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"testing"
+)
+
+func TestReportComment(t *testing.T) {
+	author := getUserByID(t, addUser(t, randomUser()))
+	movie := getMovieByID(t, addMovie(t, randomMovie()))
+	id := addComment(t, author, movie)
+
+	report := func(name, reason string) (Comments, error) {
+		return testQueries.ReportComment(context.Background(), ReportCommentParams{
+			CommentID:     id,
+			Name:          name,
+			Reason:        reason,
+			HideThreshold: 2,
+		})
+	}
+	reporters := []User{getUserByID(t, addUser(t, randomUser())), getUserByID(t, addUser(t, randomUser()))}
+
+	comment, err := report(reporters[0].Name, ReportReasonSpam)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), comment.ReportCount)
+	require.False(t, comment.Hidden)
+
+	_, err = report(reporters[0].Name, ReportReasonAbuse)
+	require.True(t, mongo.IsDuplicateKeyError(err))
+
+	comment, err = report(reporters[1].Name, ReportReasonAbuse)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), comment.ReportCount)
+	require.Equal(t, map[string]int64{ReportReasonSpam: 1, ReportReasonAbuse: 1}, comment.Reports)
+	require.True(t, comment.Hidden)
+
+	queue, err := testQueries.GetModerationQueue(context.Background(), GetModerationQueueParams{Limit: 50})
+	require.NoError(t, err)
+	require.NotEmpty(t, queue)
+	require.True(t, queue[0].Hidden)
+
+	// Approving clears the reports, so the same users can report it again
+	err = testQueries.ApproveComment(context.Background(), id)
+	require.NoError(t, err)
+	comment = getCommentByID(t, id)
+	require.False(t, comment.Hidden)
+	require.Zero(t, comment.ReportCount)
+	require.Empty(t, comment.Reports)
+	comment, err = report(reporters[0].Name, ReportReasonSpoiler)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), comment.ReportCount)
+}
+
+func TestRemoveCommentAndBanUser(t *testing.T) {
+	author := getUserByID(t, addUser(t, randomUser()))
+	movie := getMovieByID(t, addMovie(t, randomMovie()))
+	id := addComment(t, author, movie)
+
+	deleted, err := testQueries.RemoveComment(context.Background(), id)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deleted)
+	_, err = testQueries.GetComment(context.Background(), id)
+	require.ErrorIs(t, err, mongo.ErrNoDocuments)
+
+	require.NoError(t, testQueries.BanUser(context.Background(), author.Name))
+	require.True(t, getUserByID(t, author.ID).Banned)
+	err = testQueries.BanUser(context.Background(), randomUser().Name)
+	require.ErrorIs(t, err, mongo.ErrNoDocuments)
+
+	err = testQueries.AddModerationLog(context.Background(), AddModerationLogParams{
+		Moderator: "admin",
+		Action:    ModerationBan,
+		CommentID: id,
+		Author:    author.Name,
+	})
+	require.NoError(t, err)
+	entries, err := testQueries.GetModerationLog(context.Background(), GetModerationLogParams{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, id, entries[0].CommentID)
+	require.Equal(t, ModerationBan, entries[0].Action)
+}
+
+func TestFlaggedComment(t *testing.T) {
+	author := getUserByID(t, addUser(t, randomUser()))
+	movie := getMovieByID(t, addMovie(t, randomMovie()))
+	arg := randomComment(author, movie)
+	arg.Flags = []string{"blocked word"}
+	id, err := testQueries.AddComment(context.Background(), arg)
+	require.NoError(t, err)
+
+	// Editing adds the new flags to the ones the comment has
+	_, err = testQueries.UpdateComment(context.Background(), Comments{
+		ID:    id,
+		Name:  author.Name,
+		Text:  "edited",
+		Flags: []string{"blocked word", "too many links"},
+	})
+	require.NoError(t, err)
+	comment := getCommentByID(t, id)
+	require.Equal(t, []string{"blocked word", "too many links"}, comment.Flags)
+
+	queue, err := testQueries.GetModerationQueue(context.Background(), GetModerationQueueParams{Limit: 1000})
+	require.NoError(t, err)
+	require.Contains(t, commentIDs(queue), id)
+
+	require.NoError(t, testQueries.ApproveComment(context.Background(), id))
+	require.Empty(t, getCommentByID(t, id).Flags)
+	queue, err = testQueries.GetModerationQueue(context.Background(), GetModerationQueueParams{Limit: 1000})
+	require.NoError(t, err)
+	require.NotContains(t, commentIDs(queue), id)
+}
+
+func commentIDs(comments []Comments) []primitive.ObjectID {
+	ids := make([]primitive.ObjectID, len(comments))
+	for i, comment := range comments {
+		ids[i] = comment.ID
+	}
+	return ids
+}
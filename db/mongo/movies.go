@@ -5,6 +5,9 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"phantom/util"
+	"strings"
 	"time"
 )
 
@@ -18,11 +21,15 @@ type Movies struct {
 	NumMflixComments int64              `json:"num_mflix_comments" bson:"num_mflix_comments,omitempty"`
 	Poster           string             `json:"poster" bson:"poster,omitempty"`
 	Title            string             `json:"title" bson:"title,omitempty"`
+	OriginalTitle    string             `json:"original_title" bson:"original_title,omitempty"`
+	SortTitle        string             `json:"sort_title" bson:"sort_title,omitempty"`
+	Titles           []LocalizedTitle   `json:"titles" bson:"titles,omitempty"`
 	Fullplot         string             `json:"fullplot" bson:"fullplot,omitempty"`
 	Languages        []string           `json:"languages" bson:"languages,omitempty"`
 	Released         primitive.DateTime `json:"released" bson:"released,omitempty"`
 	Directors        []string           `json:"directors" bson:"directors,omitempty"`
 	Writers          []string           `json:"writers" bson:"writers,omitempty"`
+	Credits          []Credit           `json:"credits" bson:"credits,omitempty"`
 	Awards           struct {
 		Wins        int64  `json:"wins" bson:"wins,omitempty"`
 		Nominations int64  `json:"nominations" bson:"nominations,omitempty"`
@@ -35,8 +42,9 @@ type Movies struct {
 		Votes  int64   `json:"votes" bson:"votes,omitempty"`
 		Id     int64   `json:"id" bson:"id,omitempty"`
 	} `json:"imdb" bson:"imdb,omitempty"`
-	Countries []string `json:"countries" bson:"countries,omitempty"`
-	Type      string   `json:"type" bson:"type,omitempty"`
+	Countries []string        `json:"countries" bson:"countries,omitempty"`
+	Type      string          `json:"type" bson:"type,omitempty"`
+	Community CommunityRating `json:"community" bson:"community,omitempty"`
 	Tomatoes  struct {
 		Viewer struct {
 			Rating     float64 `json:"rating" bson:"rating,omitempty"`
@@ -47,19 +55,37 @@ type Movies struct {
 	} `json:"tomatoes" bson:"tomatoes,omitempty"`
 }
 
+// LocalizedTitle is the title of a movie in one locale, such as "zh-Hans" or "ja"
+type LocalizedTitle struct {
+	Locale string `json:"locale" bson:"locale"`
+	Title  string `json:"title" bson:"title"`
+}
+
 type AddMovieParams struct {
-	Genres   []string           `json:"genres" bson:"genres,omitempty"`
-	Runtime  int64              `json:"runtime" bson:"runtime,omitempty"`
-	Title    string             `json:"title" bson:"title,omitempty"`
-	Released primitive.DateTime `json:"released" bson:"released,omitempty"`
-	Year     int64              `json:"year" bson:"year,omitempty"`
-	Imdb     struct {
+	Genres        []string           `json:"genres" bson:"genres,omitempty"`
+	Runtime       int64              `json:"runtime" bson:"runtime,omitempty"`
+	Title         string             `json:"title" bson:"title,omitempty"`
+	OriginalTitle string             `json:"original_title" bson:"original_title,omitempty"`
+	SortTitle     string             `json:"sort_title" bson:"sort_title,omitempty"`
+	Titles        []LocalizedTitle   `json:"titles" bson:"titles,omitempty"`
+	Cast          []string           `json:"cast" bson:"cast,omitempty"`
+	Directors     []string           `json:"directors" bson:"directors,omitempty"`
+	Writers       []string           `json:"writers" bson:"writers,omitempty"`
+	Credits       []Credit           `json:"credits" bson:"credits,omitempty"`
+	Released      primitive.DateTime `json:"released" bson:"released,omitempty"`
+	Year          int64              `json:"year" bson:"year,omitempty"`
+	Type          string             `json:"type" bson:"type,omitempty"`
+	Imdb          struct {
 		Rating float64 `json:"rating" bson:"rating,omitempty"`
 	} `json:"imdb" bson:"imdb,omitempty"`
 }
 
-// AddMovie can add a movie information
+// AddMovie can add a movie information,
+// the sort title is made from the title when it is not given
 func (q *Queries) AddMovie(ctx context.Context, arg AddMovieParams) (primitive.ObjectID, error) {
+	if arg.SortTitle == "" {
+		arg.SortTitle = util.SortTitle(arg.Title)
+	}
 	res, err := q.movies.InsertOne(ctx, arg)
 	if err != nil {
 		return primitive.ObjectID{}, err
@@ -71,6 +97,9 @@ func (q *Queries) AddMovie(ctx context.Context, arg AddMovieParams) (primitive.O
 func (q *Queries) AddMovies(ctx context.Context, arg []AddMovieParams) ([]interface{}, error) {
 	movies := make([]interface{}, len(arg))
 	for i, v := range arg {
+		if v.SortTitle == "" {
+			v.SortTitle = util.SortTitle(v.Title)
+		}
 		movies[i] = v
 	}
 	res, err := q.movies.InsertMany(ctx, movies)
@@ -80,6 +109,122 @@ func (q *Queries) AddMovies(ctx context.Context, arg []AddMovieParams) ([]interf
 	return res.InsertedIDs, nil
 }
 
+// UpsertMovies adds each movie, or updates the movie it matches by its
+// MovieKey. The fields a movie has replace the ones of the movie it matches
+// and the others are kept, so upserting the same movies again changes
+// nothing. A movie keeps its id when it is added, if it has one. The movies
+// are written unordered, the ones that fail are in the mongo.BulkWriteException.
+// The number of comments of a movie is only taken when it is added, the
+// server counts them after that
+func (q *Queries) UpsertMovies(ctx context.Context, movies []Movies) (*mongo.BulkWriteResult, error) {
+	models := make([]mongo.WriteModel, len(movies))
+	for i, movie := range movies {
+		update := bson.M{"$set": movieUpsertFields(movie)}
+		onInsert := bson.M{}
+		if !movie.Id.IsZero() {
+			onInsert["_id"] = movie.Id
+		}
+		if movie.NumMflixComments != 0 {
+			onInsert["num_mflix_comments"] = movie.NumMflixComments
+		}
+		if len(onInsert) > 0 {
+			update["$setOnInsert"] = onInsert
+		}
+		models[i] = mongo.NewUpdateOneModel().
+			SetFilter(movie.Key().filter()).
+			SetUpdate(update).
+			SetUpsert(true)
+	}
+	return q.movies.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+}
+
+// MovieKey is what UpsertMovies matches a movie by: its IMDb id,
+// or its title and year when it has no IMDb id
+type MovieKey struct {
+	ImdbID int64
+	Title  string
+	Year   int64
+}
+
+func (movie Movies) Key() MovieKey {
+	if movie.Imdb.Id != 0 {
+		return MovieKey{ImdbID: movie.Imdb.Id}
+	}
+	return MovieKey{Title: movie.Title, Year: movie.Year}
+}
+
+// Matches tells whether the movie has the key
+func (key MovieKey) Matches(movie Movies) bool {
+	if key.ImdbID != 0 {
+		return movie.Imdb.Id == key.ImdbID
+	}
+	return movie.Title == key.Title && movie.Year == key.Year
+}
+
+// filter finds the movies with the key. A movie without a title matches none,
+// and isn't given an empty title when it is added, so the validator refuses it
+func (key MovieKey) filter() bson.M {
+	if key.ImdbID != 0 {
+		return bson.M{"imdb.id": key.ImdbID}
+	}
+	if key.Title == "" {
+		return bson.M{"title": bson.M{"$exists": false}, "year": key.Year}
+	}
+	return bson.M{"title": key.Title, "year": key.Year}
+}
+
+// movieUpsertFields are the fields UpsertMovies sets, the ones the movie has
+// but its id, community rating and number of comments, which belong to the
+// movie it matches.
+// The fields of subdocuments such as imdb are set one by one, by their path.
+// The sort title is made from the title when it is not given
+func movieUpsertFields(movie Movies) bson.M {
+	if movie.SortTitle == "" {
+		movie.SortTitle = util.SortTitle(movie.Title)
+	}
+	movie.Id = primitive.NilObjectID
+	var doc bson.M
+	Convert(movie, &doc)
+	delete(doc, "community")
+	delete(doc, "num_mflix_comments")
+	fields := bson.M{}
+	flattenFields(fields, "", doc)
+	return fields
+}
+
+func flattenFields(fields bson.M, prefix string, doc bson.M) {
+	for name, value := range doc {
+		if sub, ok := value.(bson.M); ok {
+			flattenFields(fields, prefix+name+".", sub)
+			continue
+		}
+		fields[prefix+name] = value
+	}
+}
+
+// MergeMovie is the movie UpsertMovies leaves when it updates old with movie,
+// the stores that are not MongoDB use it to upsert the way it does
+func MergeMovie(old, movie Movies) Movies {
+	var doc bson.M
+	Convert(old, &doc)
+	for path, value := range movieUpsertFields(movie) {
+		sub := doc
+		names := strings.Split(path, ".")
+		for _, name := range names[:len(names)-1] {
+			next, ok := sub[name].(bson.M)
+			if !ok {
+				next = bson.M{}
+				sub[name] = next
+			}
+			sub = next
+		}
+		sub[names[len(names)-1]] = value
+	}
+	var merged Movies
+	Convert(doc, &merged)
+	return merged
+}
+
 // GetMovieByID can get the movie information by movie id
 func (q *Queries) GetMovieByID(ctx context.Context, id primitive.ObjectID) (Movies, error) {
 	var movie Movies
@@ -90,6 +235,37 @@ func (q *Queries) GetMovieByID(ctx context.Context, id primitive.ObjectID) (Movi
 	return movie, nil
 }
 
+// GetAllMovies gets every movie in the collection,
+// it is used to build in-process indexes such as search suggestions
+func (q *Queries) GetAllMovies(ctx context.Context) ([]Movies, error) {
+	cursor, err := q.movies.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var movies []Movies
+	if err = cursor.All(ctx, &movies); err != nil {
+		return nil, err
+	}
+	return movies, nil
+}
+
+// GetMoviesByIDs gets the movies with the given ids, in no particular order
+func (q *Queries) GetMoviesByIDs(ctx context.Context, ids []primitive.ObjectID) ([]Movies, error) {
+	cursor, err := q.movies.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var movies []Movies
+	if err = cursor.All(ctx, &movies); err != nil {
+		return nil, err
+	}
+	return movies, nil
+}
+
 type GetMoviesParams struct {
 	Title       string `json:"title" bson:"title,omitempty"`
 	Genres      string `json:"genres"`
@@ -119,7 +295,8 @@ func (q *Queries) SearchForMovies(ctx context.Context, arg SearchForMoviesParams
 
 // GetMoviesByGenres Get the movie information of the past year by movie genres,
 // you can also choose to sort by hotness, sort by time, sort by rating,
-// the default is sort by hotness
+// sort by community rating, sort by title, the default is sort by hotness.
+// Sorting by title uses the sort title, or the title for movies without one
 func (q *Queries) GetMoviesByGenres(ctx context.Context, arg GetMoviesParams) ([]Movies, error) {
 	projectStage := projectStage()
 	matchStage := bson.D{{
@@ -131,25 +308,23 @@ func (q *Queries) GetMoviesByGenres(ctx context.Context, arg GetMoviesParams) ([
 	sortStageWithRuntime := bson.D{{"$sort", bson.D{{"runtime", -1}}}}
 	sortStageWithTime := bson.D{{"$sort", bson.D{{"released", -1}}}}
 	sortStageWithRating := bson.D{{"$sort", bson.D{{"imdb.rating", -1}}}}
+	sortStageWithCommunity := bson.D{{"$sort", bson.D{{"community.rating", -1}, {"community.count", -1}}}}
+	sortStageWithTitle := bson.D{{"$sort", bson.D{{"sort_title", 1}, {"_id", 1}}}}
 	skipStage := bson.D{{"$skip", arg.Skip}}
 	limitStage := bson.D{{"$limit", arg.Limit}}
 	sortStage := sortStageWithRuntime
+	pipeline := mongo.Pipeline{projectStage, matchStage}
 	switch arg.SortOptions {
 	case "sort_time":
 		sortStage = sortStageWithTime
 	case "sort_rating":
 		sortStage = sortStageWithRating
+	case "sort_community":
+		sortStage = sortStageWithCommunity
+	case "sort_title":
+		sortStage = sortStageWithTitle
 	}
-	cursor, err := q.movies.Aggregate(
-		ctx,
-		mongo.Pipeline{
-			projectStage,
-			matchStage,
-			sortStage,
-			skipStage,
-			limitStage,
-		},
-	)
+	cursor, err := q.movies.Aggregate(ctx, append(pipeline, sortStage, skipStage, limitStage))
 	defer cursor.Close(ctx)
 	if err != nil {
 		return nil, err
@@ -216,11 +391,22 @@ func (q *Queries) GetTheMostRecentlyMovies(ctx context.Context, arg GetMoviesPar
 }
 
 func (q *Queries) ReplaceMovieInfoByID(ctx context.Context, id primitive.ObjectID, movie Movies) (*mongo.UpdateResult, error) {
+	if movie.SortTitle == "" {
+		movie.SortTitle = util.SortTitle(movie.Title)
+	}
 	data, err := bson.Marshal(&movie)
 	if err != nil {
 		return nil, err
 	}
-	updateResult, err := q.movies.ReplaceOne(ctx, bson.M{"_id": id}, data)
+	// The community rating is kept by the ratings and the number of comments by
+	// the comments, not by the movie information, replacing the rest in the same
+	// update doesn't lose a rating or a comment made meanwhile
+	updateResult, err := q.movies.UpdateOne(ctx, bson.M{"_id": id}, mongo.Pipeline{
+		{{"$replaceWith", bson.D{{"$mergeObjects", bson.A{
+			bson.D{{"$literal", bson.Raw(data)}},
+			bson.D{{"_id", "$_id"}, {"community", "$community"}, {"num_mflix_comments", "$num_mflix_comments"}},
+		}}}}},
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -230,6 +416,18 @@ func (q *Queries) ReplaceMovieInfoByID(ctx context.Context, id primitive.ObjectI
 	return updateResult, nil
 }
 
+// IncrementMovieComments adds n to the number of comments on a movie
+func (q *Queries) IncrementMovieComments(ctx context.Context, id primitive.ObjectID, n int64) error {
+	res, err := q.movies.UpdateByID(ctx, id, bson.D{{"$inc", bson.D{{"num_mflix_comments", n}}}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
 func (q *Queries) DeleteMovieByID(ctx context.Context, id primitive.ObjectID) (int64, error) {
 	deleteResult, err := q.movies.DeleteOne(ctx, bson.M{"_id": id})
 	if err != nil {
@@ -251,9 +449,13 @@ func projectStage() bson.D {
 			{"cast", 1},
 			{"poster", 1},
 			{"title", 1},
+			{"original_title", 1},
+			{"sort_title", 1},
+			{"titles", 1},
 			{"released", 1},
 			{"year", 1},
 			{"imdb", 1},
+			{"community", 1},
 			{"countries", 1},
 		},
 	}}
@@ -54,6 +54,45 @@ func TestGetMovieByID(t *testing.T) {
 	require.Equal(t, movie1.Genres, movie2.Genres)
 }
 
+func TestAddMovieWithTitles(t *testing.T) {
+	movie := randomMovie()
+	movie.Title = "The " + movie.Title
+	movie.OriginalTitle = util.RandomString(8)
+	movie.Titles = []LocalizedTitle{
+		{Locale: "zh-Hans", Title: util.RandomString(8)},
+		{Locale: "ja", Title: util.RandomString(8)},
+	}
+	id := addMovie(t, movie)
+
+	got := getMovieByID(t, id)
+	require.Equal(t, movie.OriginalTitle, got.OriginalTitle)
+	require.Equal(t, movie.Titles, got.Titles)
+	require.Equal(t, util.SortTitle(movie.Title), got.SortTitle)
+}
+
+func TestGetAllMovies(t *testing.T) {
+	id := addMovie(t, randomMovie())
+	movies, err := testQueries.GetAllMovies(context.Background())
+	require.NoError(t, err)
+	require.NotEmpty(t, movies)
+
+	found := false
+	for i := range movies {
+		if movies[i].Id == id {
+			found = true
+		}
+	}
+	require.True(t, found)
+}
+
+func TestGetMoviesByIDs(t *testing.T) {
+	ids := []primitive.ObjectID{addMovie(t, randomMovie()), addMovie(t, randomMovie())}
+	movies, err := testQueries.GetMoviesByIDs(context.Background(), append(ids, primitive.NewObjectID()))
+	require.NoError(t, err)
+	require.Len(t, movies, 2)
+	require.ElementsMatch(t, ids, []primitive.ObjectID{movies[0].Id, movies[1].Id})
+}
+
 func TestSearchForMovies(t *testing.T) {
 	movie := randomMovie()
 	addMovie(t, movie)
@@ -88,7 +127,7 @@ func TestGetMoviesByGenres(t *testing.T) {
 		Skip:        0,
 		Limit:       5,
 	}
-	for i := 0; i < 3; i++ {
+	for i := 0; i < 4; i++ {
 		switch i {
 		case 0:
 			arg.SortOptions = "sort_hotness"
@@ -105,6 +144,11 @@ func TestGetMoviesByGenres(t *testing.T) {
 			sort.Slice(movies1, func(i, j int) bool {
 				return movies1[i].Imdb.Rating > movies1[j].Imdb.Rating // desc
 			})
+		case 3:
+			arg.SortOptions = "sort_title"
+			sort.Slice(movies1, func(i, j int) bool {
+				return util.SortTitle(movies1[i].Title) < util.SortTitle(movies1[j].Title) // asc
+			})
 		}
 		movies2, err := testQueries.GetMoviesByGenres(context.Background(), arg)
 		require.NoError(t, err)
@@ -120,6 +164,8 @@ func TestGetMoviesByGenres(t *testing.T) {
 				require.Equal(t, movies1[j].Released, movies2[j].Released)
 			case 2:
 				require.Equal(t, movies1[j].Imdb.Rating, movies2[j].Imdb.Rating)
+			case 3:
+				require.Equal(t, movies1[j].Title, movies2[j].Title)
 			}
 		}
 	}
@@ -201,3 +247,13 @@ func TestDeleteMovieByID(t *testing.T) {
 	_, err := testQueries.DeleteMovieByID(context.Background(), primitive.NewObjectID())
 	require.Equal(t, mongo.ErrNoDocuments, err)
 }
+
+func TestIncrementMovieComments(t *testing.T) {
+	movie := getMovieByID(t, addMovie(t, randomMovie()))
+	require.NoError(t, testQueries.IncrementMovieComments(context.Background(), movie.Id, 2))
+	require.NoError(t, testQueries.IncrementMovieComments(context.Background(), movie.Id, -1))
+	require.Equal(t, movie.NumMflixComments+1, getMovieByID(t, movie.Id).NumMflixComments)
+
+	err := testQueries.IncrementMovieComments(context.Background(), primitive.NewObjectID(), 1)
+	require.Equal(t, mongo.ErrNoDocuments, err)
+}
@@ -0,0 +1,250 @@
+package db
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"regexp"
+)
+
+// The roles a person can have in a movie
+const (
+	RoleCast     = "cast"
+	RoleDirector = "director"
+	RoleWriter   = "writer"
+)
+
+// roleFields maps a role to the name array it mirrors on Movies
+var roleFields = map[string]string{
+	RoleCast:     "cast",
+	RoleDirector: "directors",
+	RoleWriter:   "writers",
+}
+
+type Person struct {
+	ID        primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	Name      string             `json:"name" bson:"name"`
+	Aliases   []string           `json:"aliases" bson:"aliases,omitempty"`
+	Photo     string             `json:"photo" bson:"photo,omitempty"`
+	Biography string             `json:"biography" bson:"biography,omitempty"`
+}
+
+// Credit links a movie to a person in one role
+type Credit struct {
+	PersonID primitive.ObjectID `json:"person_id" bson:"person_id"`
+	Role     string             `json:"role" bson:"role"`
+}
+
+type AddPersonParams struct {
+	Name      string   `json:"name" bson:"name"`
+	Aliases   []string `json:"aliases" bson:"aliases,omitempty"`
+	Photo     string   `json:"photo" bson:"photo,omitempty"`
+	Biography string   `json:"biography" bson:"biography,omitempty"`
+}
+
+type SearchPeopleParams struct {
+	Text  string `json:"text"`
+	Skip  int64  `json:"skip"`
+	Limit int64  `json:"limit"`
+}
+
+func (q *Queries) AddPerson(ctx context.Context, arg AddPersonParams) (primitive.ObjectID, error) {
+	res, err := q.people.InsertOne(ctx, arg)
+	if err != nil {
+		return primitive.ObjectID{}, err
+	}
+	return res.InsertedID.(primitive.ObjectID), nil
+}
+
+func (q *Queries) GetPersonByID(ctx context.Context, id primitive.ObjectID) (Person, error) {
+	var person Person
+	err := q.people.FindOne(ctx, bson.M{"_id": id}).Decode(&person)
+	if err != nil {
+		return Person{}, err
+	}
+	return person, nil
+}
+
+// GetPeopleByIDs gets the people with the given ids, in no particular order
+func (q *Queries) GetPeopleByIDs(ctx context.Context, ids []primitive.ObjectID) ([]Person, error) {
+	cursor, err := q.people.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var people []Person
+	if err = cursor.All(ctx, &people); err != nil {
+		return nil, err
+	}
+	return people, nil
+}
+
+// SearchPeople finds the people whose name or one of whose aliases
+// contains the text, ignoring case
+func (q *Queries) SearchPeople(ctx context.Context, arg SearchPeopleParams) ([]Person, error) {
+	pattern := primitive.Regex{Pattern: regexp.QuoteMeta(arg.Text), Options: "i"}
+	filter := bson.M{"$or": bson.A{
+		bson.M{"name": pattern},
+		bson.M{"aliases": pattern},
+	}}
+	findOptions := options.Find().
+		SetSort(bson.D{{"name", 1}}).
+		SetSkip(arg.Skip).
+		SetLimit(arg.Limit)
+	cursor, err := q.people.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var people []Person
+	if err = cursor.All(ctx, &people); err != nil {
+		return nil, err
+	}
+	return people, nil
+}
+
+// UpdatePerson replaces the details of a person. A new name is also written
+// to the name arrays of the movies the person is credited in, so that a
+// misspelled name only has to be fixed here
+func (q *Queries) UpdatePerson(ctx context.Context, person Person) (*mongo.UpdateResult, error) {
+	old, err := q.GetPersonByID(ctx, person.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := q.people.UpdateByID(ctx, person.ID, bson.D{
+		{"$set", bson.D{
+			{"name", person.Name},
+			{"aliases", person.Aliases},
+			{"photo", person.Photo},
+			{"biography", person.Biography},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if old.Name != person.Name {
+		for role, field := range roleFields {
+			_, err = q.movies.UpdateMany(ctx,
+				bson.D{
+					{"credits", bson.D{{"$elemMatch", bson.D{{"person_id", person.ID}, {"role", role}}}}},
+					{field, old.Name},
+				},
+				bson.D{{"$set", bson.D{{field + ".$[name]", person.Name}}}},
+				options.Update().SetArrayFilters(options.ArrayFilters{
+					Filters: []interface{}{bson.M{"name": old.Name}},
+				}),
+			)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return res, nil
+}
+
+// GetMoviesByPersonID gets every movie a person is credited in, the newest first
+func (q *Queries) GetMoviesByPersonID(ctx context.Context, id primitive.ObjectID) ([]Movies, error) {
+	findOptions := options.Find().
+		SetSort(bson.D{{"year", -1}, {"_id", 1}}).
+		SetProjection(bson.D{
+			{"title", 1},
+			{"original_title", 1},
+			{"titles", 1},
+			{"poster", 1},
+			{"year", 1},
+			{"credits", 1},
+		})
+	cursor, err := q.movies.Find(ctx, bson.M{"credits.person_id": id}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var movies []Movies
+	if err = cursor.All(ctx, &movies); err != nil {
+		return nil, err
+	}
+	return movies, nil
+}
+
+// MigratePeopleResult counts what MigratePeople did
+type MigratePeopleResult struct {
+	Movies int
+	People int
+}
+
+// MigratePeople links the movies that have no credits yet to people,
+// from the names in their cast, directors and writers.
+// A name is matched against the names and aliases of existing people,
+// and a person is added for every name that matches nobody.
+// Movies that already have credits are left alone, so it can be run again
+func (q *Queries) MigratePeople(ctx context.Context) (MigratePeopleResult, error) {
+	var result MigratePeopleResult
+	cursor, err := q.movies.Find(ctx,
+		bson.M{"credits": bson.M{"$exists": false}},
+		options.Find().SetProjection(bson.D{{"cast", 1}, {"directors", 1}, {"writers", 1}}),
+	)
+	if err != nil {
+		return result, err
+	}
+	defer cursor.Close(ctx)
+
+	ids := map[string]primitive.ObjectID{}
+	personID := func(name string) (primitive.ObjectID, error) {
+		if id, ok := ids[name]; ok {
+			return id, nil
+		}
+		var person Person
+		err := q.people.FindOne(ctx, bson.M{"$or": bson.A{
+			bson.M{"name": name},
+			bson.M{"aliases": name},
+		}}).Decode(&person)
+		if err == mongo.ErrNoDocuments {
+			person.ID, err = q.AddPerson(ctx, AddPersonParams{Name: name})
+			result.People++
+		}
+		if err != nil {
+			return primitive.ObjectID{}, err
+		}
+		ids[name] = person.ID
+		return person.ID, nil
+	}
+
+	for cursor.Next(ctx) {
+		var movie Movies
+		if err = cursor.Decode(&movie); err != nil {
+			return result, err
+		}
+
+		credits := []Credit{}
+		for _, names := range []struct {
+			role  string
+			names []string
+		}{
+			{RoleCast, movie.Cast},
+			{RoleDirector, movie.Directors},
+			{RoleWriter, movie.Writers},
+		} {
+			for _, name := range names.names {
+				id, err := personID(name)
+				if err != nil {
+					return result, err
+				}
+				credits = append(credits, Credit{PersonID: id, Role: names.role})
+			}
+		}
+
+		_, err = q.movies.UpdateByID(ctx, movie.Id, bson.D{{"$set", bson.D{{"credits", credits}}}})
+		if err != nil {
+			return result, err
+		}
+		result.Movies++
+	}
+	return result, cursor.Err()
+}
@@ -0,0 +1,151 @@
+package db
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"phantom/util"
+	"testing"
+)
+
+func randomPerson() AddPersonParams {
+	return AddPersonParams{
+		Name:      util.RandomString(6) + " " + util.RandomString(8),
+		Aliases:   []string{util.RandomString(10)},
+		Photo:     "https://example.com/" + util.RandomString(8) + ".jpg",
+		Biography: util.RandomString(30),
+	}
+}
+
+func addPerson(t *testing.T, person AddPersonParams) primitive.ObjectID {
+	id, err := testQueries.AddPerson(context.Background(), person)
+	require.NoError(t, err)
+	require.NotEmpty(t, id)
+	return id
+}
+
+func TestGetPersonByID(t *testing.T) {
+	person1 := randomPerson()
+	id := addPerson(t, person1)
+
+	person2, err := testQueries.GetPersonByID(context.Background(), id)
+	require.NoError(t, err)
+	require.Equal(t, person1.Name, person2.Name)
+	require.Equal(t, person1.Aliases, person2.Aliases)
+	require.Equal(t, person1.Photo, person2.Photo)
+	require.Equal(t, person1.Biography, person2.Biography)
+
+	_, err = testQueries.GetPersonByID(context.Background(), primitive.NewObjectID())
+	require.ErrorIs(t, err, mongo.ErrNoDocuments)
+}
+
+func TestGetPeopleByIDs(t *testing.T) {
+	id1 := addPerson(t, randomPerson())
+	id2 := addPerson(t, randomPerson())
+	people, err := testQueries.GetPeopleByIDs(context.Background(), []primitive.ObjectID{id1, id2, primitive.NewObjectID()})
+	require.NoError(t, err)
+	require.Len(t, people, 2)
+}
+
+func TestSearchPeople(t *testing.T) {
+	person := randomPerson()
+	id := addPerson(t, person)
+
+	for _, text := range []string{person.Name[:4], person.Aliases[0], "(" + person.Name} {
+		people, err := testQueries.SearchPeople(context.Background(), SearchPeopleParams{Text: text, Limit: 10})
+		require.NoError(t, err)
+		if text[0] == '(' {
+			// The text is not a regular expression
+			require.Empty(t, people)
+			continue
+		}
+		require.Len(t, people, 1)
+		require.Equal(t, id, people[0].ID)
+	}
+}
+
+func TestUpdatePersonRenamesCredits(t *testing.T) {
+	person := randomPerson()
+	id := addPerson(t, person)
+	other := util.RandomString(8)
+
+	movie := randomMovie()
+	movie.Cast = []string{other, person.Name}
+	movie.Directors = []string{person.Name}
+	movie.Credits = []Credit{
+		{PersonID: primitive.NewObjectID(), Role: RoleCast},
+		{PersonID: id, Role: RoleCast},
+		{PersonID: id, Role: RoleDirector},
+	}
+	movieID := addMovie(t, movie)
+
+	fixed := Person{ID: id, Name: util.RandomString(12), Aliases: []string{person.Name}}
+	_, err := testQueries.UpdatePerson(context.Background(), fixed)
+	require.NoError(t, err)
+
+	got, err := testQueries.GetPersonByID(context.Background(), id)
+	require.NoError(t, err)
+	require.Equal(t, fixed.Name, got.Name)
+	require.Equal(t, fixed.Aliases, got.Aliases)
+
+	updated := getMovieByID(t, movieID)
+	require.Equal(t, []string{other, fixed.Name}, updated.Cast)
+	require.Equal(t, []string{fixed.Name}, updated.Directors)
+
+	_, err = testQueries.UpdatePerson(context.Background(), Person{ID: primitive.NewObjectID(), Name: "nobody"})
+	require.ErrorIs(t, err, mongo.ErrNoDocuments)
+}
+
+func TestGetMoviesByPersonID(t *testing.T) {
+	id := addPerson(t, randomPerson())
+	older := randomMovie()
+	older.Year = 1990
+	older.Credits = []Credit{{PersonID: id, Role: RoleWriter}}
+	newer := randomMovie()
+	newer.Year = 2010
+	newer.Credits = []Credit{{PersonID: id, Role: RoleCast}, {PersonID: id, Role: RoleDirector}}
+	olderID := addMovie(t, older)
+	newerID := addMovie(t, newer)
+
+	movies, err := testQueries.GetMoviesByPersonID(context.Background(), id)
+	require.NoError(t, err)
+	require.Len(t, movies, 2)
+	require.Equal(t, newerID, movies[0].Id)
+	require.Equal(t, olderID, movies[1].Id)
+	require.Len(t, movies[0].Credits, 2)
+}
+
+func TestMigratePeople(t *testing.T) {
+	existing := randomPerson()
+	existingID := addPerson(t, existing)
+	newName := util.RandomString(12)
+
+	movie := randomMovie()
+	movie.Cast = []string{existing.Aliases[0], newName}
+	movie.Directors = []string{newName}
+	movieID := addMovie(t, movie)
+
+	result, err := testQueries.MigratePeople(context.Background())
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, result.Movies, 1)
+	require.GreaterOrEqual(t, result.People, 1)
+
+	migrated := getMovieByID(t, movieID)
+	require.Len(t, migrated.Credits, 3)
+	require.Equal(t, Credit{PersonID: existingID, Role: RoleCast}, migrated.Credits[0])
+	require.Equal(t, RoleDirector, migrated.Credits[2].Role)
+	require.Equal(t, migrated.Credits[1].PersonID, migrated.Credits[2].PersonID)
+
+	var person Person
+	err = testQueries.people.FindOne(context.Background(), bson.M{"_id": migrated.Credits[1].PersonID}).Decode(&person)
+	require.NoError(t, err)
+	require.Equal(t, newName, person.Name)
+
+	// Running it again leaves linked movies alone
+	again, err := testQueries.MigratePeople(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 0, again.Movies)
+	require.Equal(t, migrated.Credits, getMovieByID(t, movieID).Credits)
+}
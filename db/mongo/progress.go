@@ -0,0 +1,139 @@
+package db
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// Progress is how far a user got in a movie or an episode, positions are in seconds.
+// SeriesID is only set for episodes. Watched is worked out from the position
+// the player sent last, so watching a movie again unsets it until its end
+type Progress struct {
+	ID        primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	Name      string             `json:"name" bson:"name"`
+	MediaID   primitive.ObjectID `json:"media_id" bson:"media_id"`
+	SeriesID  primitive.ObjectID `json:"series_id" bson:"series_id,omitempty"`
+	Position  int64              `json:"position" bson:"position"`
+	Duration  int64              `json:"duration" bson:"duration"`
+	Watched   bool               `json:"watched" bson:"watched"`
+	UpdatedAt primitive.DateTime `json:"updated_at" bson:"updated_at"`
+}
+
+// GetProgressBySeriesID gets the progress of a user in the episodes of a series,
+// the most recently watched first
+func (q *Queries) GetProgressBySeriesID(ctx context.Context, name string, seriesID primitive.ObjectID) ([]Progress, error) {
+	findOptions := options.Find().SetSort(bson.D{{"updated_at", -1}})
+	cursor, err := q.progress.Find(ctx, bson.M{"name": name, "series_id": seriesID}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var progress []Progress
+	if err = cursor.All(ctx, &progress); err != nil {
+		return nil, err
+	}
+	return progress, nil
+}
+
+type SaveProgressParams struct {
+	Name     string             `json:"name"`
+	MediaID  primitive.ObjectID `json:"media_id"`
+	SeriesID primitive.ObjectID `json:"series_id"`
+	Position int64              `json:"position"`
+	Duration int64              `json:"duration"`
+	Watched  bool               `json:"watched"`
+}
+
+// SaveProgress records how far a user got in a movie or an episode. Players
+// send it every few seconds, so it is a single upsert on the unique index,
+// only when the movie or episode becomes watched is it added to the history too
+func (q *Queries) SaveProgress(ctx context.Context, arg SaveProgressParams) error {
+	set := bson.D{
+		{"position", arg.Position},
+		{"duration", arg.Duration},
+		{"watched", arg.Watched},
+		{"updated_at", primitive.NewDateTimeFromTime(time.Now())},
+	}
+	if !arg.SeriesID.IsZero() {
+		set = append(set, bson.E{Key: "series_id", Value: arg.SeriesID})
+	}
+	var old Progress
+	err := q.progress.FindOneAndUpdate(ctx,
+		bson.D{{"name", arg.Name}, {"media_id", arg.MediaID}},
+		bson.D{{"$set", set}},
+		options.FindOneAndUpdate().
+			SetUpsert(true).
+			SetReturnDocument(options.Before).
+			SetProjection(bson.D{{"watched", 1}}),
+	).Decode(&old)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+	if !arg.Watched || old.Watched {
+		return nil
+	}
+	_, err = q.history.InsertOne(ctx, HistoryEntry{
+		Name:      arg.Name,
+		MediaID:   arg.MediaID,
+		SeriesID:  arg.SeriesID,
+		Duration:  arg.Duration,
+		WatchedAt: primitive.NewDateTimeFromTime(time.Now()),
+	})
+	return err
+}
+
+type GetContinueWatchingParams struct {
+	Name  string `json:"name"`
+	Skip  int64  `json:"skip"`
+	Limit int64  `json:"limit"`
+}
+
+// GetContinueWatching gets a page of the movies and episodes a user started
+// and hasn't watched yet, the most recently watched first. A series only
+// comes once, with the episode the user was in last
+func (q *Queries) GetContinueWatching(ctx context.Context, arg GetContinueWatchingParams) ([]Progress, error) {
+	pipeline := mongo.Pipeline{
+		{{"$match", bson.D{{"name", arg.Name}, {"watched", false}, {"position", bson.M{"$gt": 0}}}}},
+		{{"$sort", bson.D{{"updated_at", -1}}}},
+		{{"$group", bson.D{
+			{"_id", bson.D{{"$ifNull", bson.A{"$series_id", "$media_id"}}}},
+			{"progress", bson.D{{"$first", "$$ROOT"}}},
+		}}},
+		{{"$replaceWith", "$progress"}},
+		{{"$sort", bson.D{{"updated_at", -1}, {"_id", -1}}}},
+		{{"$skip", arg.Skip}},
+		{{"$limit", arg.Limit}},
+	}
+	cursor, err := q.progress.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var progress []Progress
+	if err = cursor.All(ctx, &progress); err != nil {
+		return nil, err
+	}
+	return progress, nil
+}
+
+// GetProgressByMediaIDs gets the progress of a user in the given movies or episodes,
+// in no particular order. The ones the user hasn't started are left out
+func (q *Queries) GetProgressByMediaIDs(ctx context.Context, name string, ids []primitive.ObjectID) ([]Progress, error) {
+	cursor, err := q.progress.Find(ctx, bson.M{"name": name, "media_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var progress []Progress
+	if err = cursor.All(ctx, &progress); err != nil {
+		return nil, err
+	}
+	return progress, nil
+}
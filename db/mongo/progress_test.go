@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"phantom/util"
+	"testing"
+	"time"
+)
+
+func saveProgress(t *testing.T, arg SaveProgressParams) {
+	require.NoError(t, testQueries.SaveProgress(context.Background(), arg))
+	// updated_at has millisecond precision
+	time.Sleep(2 * time.Millisecond)
+}
+
+func TestSaveProgress(t *testing.T) {
+	name := util.RandomUser()
+	movieID := addMovie(t, randomMovie())
+
+	saveProgress(t, SaveProgressParams{Name: name, MediaID: movieID, Position: 10, Duration: 6000})
+	saveProgress(t, SaveProgressParams{Name: name, MediaID: movieID, Position: 5500, Duration: 6000, Watched: true})
+
+	progress, err := testQueries.GetProgressByMediaIDs(context.Background(), name, []primitive.ObjectID{movieID, primitive.NewObjectID()})
+	require.NoError(t, err)
+	require.Len(t, progress, 1)
+	require.Equal(t, int64(5500), progress[0].Position)
+	require.True(t, progress[0].Watched)
+	require.True(t, progress[0].SeriesID.IsZero())
+	require.WithinDuration(t, time.Now(), progress[0].UpdatedAt.Time(), time.Second)
+}
+
+func TestGetContinueWatching(t *testing.T) {
+	name := util.RandomUser()
+	watched := addMovie(t, randomMovie())
+	started := addMovie(t, randomMovie())
+	opened := addMovie(t, randomMovie())
+	seriesID := addSeries(t)
+	first := addEpisode(t, seriesID, 1, 1, 1)
+	second := addEpisode(t, seriesID, 1, 2, 2)
+
+	saveProgress(t, SaveProgressParams{Name: name, MediaID: started, Position: 600, Duration: 6000})
+	saveProgress(t, SaveProgressParams{Name: name, MediaID: first, SeriesID: seriesID, Position: 100, Duration: 1500})
+	saveProgress(t, SaveProgressParams{Name: name, MediaID: second, SeriesID: seriesID, Position: 200, Duration: 1500})
+	saveProgress(t, SaveProgressParams{Name: name, MediaID: watched, Position: 5800, Duration: 6000, Watched: true})
+	saveProgress(t, SaveProgressParams{Name: name, MediaID: opened, Position: 0, Duration: 6000})
+	saveProgress(t, SaveProgressParams{Name: util.RandomUser(), MediaID: started, Position: 600, Duration: 6000})
+
+	progress, err := testQueries.GetContinueWatching(context.Background(), GetContinueWatchingParams{Name: name, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, progress, 2)
+	require.Equal(t, second, progress[0].MediaID)
+	require.Equal(t, seriesID, progress[0].SeriesID)
+	require.Equal(t, started, progress[1].MediaID)
+
+	// Watching the movie again brings it back
+	saveProgress(t, SaveProgressParams{Name: name, MediaID: watched, Position: 30, Duration: 6000})
+	progress, err = testQueries.GetContinueWatching(context.Background(), GetContinueWatchingParams{Name: name, Skip: 1, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, progress, 1)
+	require.Equal(t, second, progress[0].MediaID)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"phantom/ledger"
 )
 
 type Querier interface {
@@ -13,20 +14,89 @@ type Querier interface {
 	GetUserByEmail(ctx context.Context, email string) (User, error)
 	UpdateUserName(ctx context.Context, user User) (*mongo.UpdateResult, error)
 	UpdateUserPassword(ctx context.Context, user User) (*mongo.UpdateResult, error)
+	UpdateUserLocale(ctx context.Context, user User) (*mongo.UpdateResult, error)
+	UpdateUserRole(ctx context.Context, user User) (*mongo.UpdateResult, error)
+	BanUser(ctx context.Context, name string) error
 	AddComment(ctx context.Context, arg AddCommentParams) (primitive.ObjectID, error)
 	GetComment(ctx context.Context, id primitive.ObjectID) (Comments, error)
+	GetAllComments(ctx context.Context) ([]Comments, error)
 	GetCommentsByMovieID(ctx context.Context, arg GetCommentsParams) ([]Comments, error)
 	GetCommentsByName(ctx context.Context, arg GetCommentsParams) ([]Comments, error)
 	UpdateComment(ctx context.Context, comment Comments) (*mongo.UpdateResult, error)
 	DeleteComment(ctx context.Context, id primitive.ObjectID, name string) (int64, error)
+	DeleteCommentsByMovieID(ctx context.Context, movieID primitive.ObjectID) ([]primitive.ObjectID, error)
+	GetReplies(ctx context.Context, arg GetRepliesParams) ([]Comments, error)
+	GetReplySummaries(ctx context.Context, parentIDs []primitive.ObjectID, n int64) ([]ReplySummary, error)
+	AddReaction(ctx context.Context, arg CommentReactionParams) (bool, error)
+	DeleteReaction(ctx context.Context, arg CommentReactionParams) error
+	GetReactionsByName(ctx context.Context, name string, commentIDs []primitive.ObjectID) ([]CommentReaction, error)
+	RemoveComment(ctx context.Context, id primitive.ObjectID) (int64, error)
+	ReportComment(ctx context.Context, arg ReportCommentParams) (Comments, error)
+	GetModerationQueue(ctx context.Context, arg GetModerationQueueParams) ([]Comments, error)
+	ApproveComment(ctx context.Context, id primitive.ObjectID) error
+	AddModerationLog(ctx context.Context, arg AddModerationLogParams) error
+	GetModerationLog(ctx context.Context, arg GetModerationLogParams) ([]ModerationLogEntry, error)
+	GetCommentReporters(ctx context.Context, commentID primitive.ObjectID) ([]string, error)
+	AddDanmaku(ctx context.Context, arg AddDanmakuParams) (Danmaku, error)
+	GetDanmaku(ctx context.Context, arg GetDanmakuParams) ([]Danmaku, error)
+	GetDanmakuModerationQueue(ctx context.Context, arg GetModerationQueueParams) ([]Danmaku, error)
+	ApproveDanmaku(ctx context.Context, id primitive.ObjectID) (Danmaku, error)
+	RemoveDanmaku(ctx context.Context, id primitive.ObjectID) (Danmaku, error)
+	GetLedgerProof(ctx context.Context, refID primitive.ObjectID) (ledger.Proof, error)
+	CheckpointLedger(ctx context.Context) error
+	TransferCoins(ctx context.Context, arg TransferCoinsParams) (TransferCoinsResult, error)
+	ReverseCoins(ctx context.Context, arg ReverseCoinsParams) (TransferCoinsResult, error)
+	GetCoinWallet(ctx context.Context, account string) (CoinWallet, error)
+	GetCoinTransactions(ctx context.Context, arg GetCoinTransactionsParams) ([]CoinTransaction, error)
+	ReconcileCoins(ctx context.Context) (CoinReconciliation, error)
+	GetTitleRequests(ctx context.Context, arg GetTitleRequestsParams) ([]TitleRequest, error)
 	AddMovie(ctx context.Context, arg AddMovieParams) (primitive.ObjectID, error)
 	AddMovies(ctx context.Context, arg []AddMovieParams) ([]interface{}, error)
+	UpsertMovies(ctx context.Context, movies []Movies) (*mongo.BulkWriteResult, error)
 	GetMovieByID(ctx context.Context, id primitive.ObjectID) (Movies, error)
+	GetAllMovies(ctx context.Context) ([]Movies, error)
+	GetMoviesByIDs(ctx context.Context, ids []primitive.ObjectID) ([]Movies, error)
 	GetMoviesByGenres(ctx context.Context, arg GetMoviesParams) ([]Movies, error)
 	SearchForMovies(ctx context.Context, arg SearchForMoviesParams) ([]Movies, error)
 	GetTheMostViewedMovies(ctx context.Context, arg GetMoviesParams) ([]Movies, error)
 	GetTheLatestReleasedMovies(ctx context.Context, arg GetMoviesParams) ([]Movies, error)
+	AddPerson(ctx context.Context, arg AddPersonParams) (primitive.ObjectID, error)
+	GetPersonByID(ctx context.Context, id primitive.ObjectID) (Person, error)
+	GetPeopleByIDs(ctx context.Context, ids []primitive.ObjectID) ([]Person, error)
+	SearchPeople(ctx context.Context, arg SearchPeopleParams) ([]Person, error)
+	UpdatePerson(ctx context.Context, person Person) (*mongo.UpdateResult, error)
+	GetMoviesByPersonID(ctx context.Context, id primitive.ObjectID) ([]Movies, error)
+	AddSeason(ctx context.Context, arg AddSeasonParams) (primitive.ObjectID, error)
+	GetSeasonsBySeriesID(ctx context.Context, seriesID primitive.ObjectID) ([]Season, error)
+	AddEpisode(ctx context.Context, arg AddEpisodeParams) (primitive.ObjectID, error)
+	GetEpisodeByID(ctx context.Context, id primitive.ObjectID) (Episode, error)
+	GetEpisodes(ctx context.Context, arg GetEpisodesParams) ([]Episode, error)
+	GetEpisodesByIDs(ctx context.Context, ids []primitive.ObjectID) ([]Episode, error)
+	GetProgressBySeriesID(ctx context.Context, name string, seriesID primitive.ObjectID) ([]Progress, error)
+	SaveProgress(ctx context.Context, arg SaveProgressParams) error
+	GetContinueWatching(ctx context.Context, arg GetContinueWatchingParams) ([]Progress, error)
+	GetProgressByMediaIDs(ctx context.Context, name string, ids []primitive.ObjectID) ([]Progress, error)
+	GetHistory(ctx context.Context, arg GetHistoryParams) ([]HistoryEntry, error)
+	DeleteHistoryEntry(ctx context.Context, name string, id primitive.ObjectID) error
+	GetUserStats(ctx context.Context, arg GetUserStatsParams) (UserStats, error)
+	AddCollection(ctx context.Context, arg AddCollectionParams) (primitive.ObjectID, error)
+	GetCollectionByID(ctx context.Context, id primitive.ObjectID) (Collection, error)
+	GetCollections(ctx context.Context, arg GetCollectionsParams) ([]Collection, error)
+	GetCollectionsByMovie(ctx context.Context, movie Movies) ([]Collection, error)
+	GetCollectionMovies(ctx context.Context, arg GetCollectionMoviesParams) ([]Movies, error)
+	ReplaceCollection(ctx context.Context, collection Collection) (*mongo.UpdateResult, error)
+	DeleteCollection(ctx context.Context, id primitive.ObjectID) (int64, error)
+	AddListEntry(ctx context.Context, arg ListEntryParams) (*mongo.UpdateResult, error)
+	DeleteListEntry(ctx context.Context, arg ListEntryParams) (int64, error)
+	GetListEntries(ctx context.Context, arg GetListParams) ([]ListEntry, error)
+	ReorderList(ctx context.Context, arg ReorderListParams) error
+	GetListsByMovie(ctx context.Context, name string, movieID primitive.ObjectID) ([]string, error)
+	RateMovie(ctx context.Context, arg RateMovieParams) error
+	DeleteRating(ctx context.Context, name string, movieID primitive.ObjectID) error
+	GetRating(ctx context.Context, name string, movieID primitive.ObjectID) (Rating, error)
 	ReplaceMovieInfoByID(ctx context.Context, id primitive.ObjectID, movie Movies) (*mongo.UpdateResult, error)
+	IncrementMovieComments(ctx context.Context, id primitive.ObjectID, n int64) error
+	DeleteMovieByID(ctx context.Context, id primitive.ObjectID) (int64, error)
 }
 
 var _ Querier = (*Queries)(nil)
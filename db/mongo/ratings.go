@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// Rating is the score from 1 to 10 a user gave a movie
+type Rating struct {
+	ID        primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	Name      string             `json:"name" bson:"name"`
+	MovieID   primitive.ObjectID `json:"movie_id" bson:"movie_id"`
+	Score     int64              `json:"score" bson:"score"`
+	UpdatedAt primitive.DateTime `json:"updated_at" bson:"updated_at"`
+}
+
+// CommunityRating is the average score the users of the server gave a movie,
+// it is kept up to date as they rate the movie
+type CommunityRating struct {
+	Rating float64 `json:"rating" bson:"rating"`
+	Count  int64   `json:"count" bson:"count"`
+	Sum    int64   `json:"sum" bson:"sum"`
+}
+
+type RateMovieParams struct {
+	Name    string             `json:"name"`
+	MovieID primitive.ObjectID `json:"movie_id"`
+	Score   int64              `json:"score"`
+}
+
+// RateMovie sets the score of a user for a movie, replacing their previous score,
+// and moves the community rating of the movie by the difference. Those are two
+// writes, run it in Store.ExecTx for them to be made together
+func (q *Queries) RateMovie(ctx context.Context, arg RateMovieParams) error {
+	var old Rating
+	err := q.ratings.FindOneAndUpdate(ctx,
+		bson.D{{"name", arg.Name}, {"movie_id", arg.MovieID}},
+		bson.D{{"$set", bson.D{
+			{"score", arg.Score},
+			{"updated_at", primitive.NewDateTimeFromTime(time.Now())},
+		}}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before),
+	).Decode(&old)
+	if err == mongo.ErrNoDocuments {
+		return q.addToCommunityRating(ctx, arg.MovieID, arg.Score, 1)
+	}
+	if err != nil {
+		return err
+	}
+	return q.addToCommunityRating(ctx, arg.MovieID, arg.Score-old.Score, 0)
+}
+
+// DeleteRating removes the score of a user for a movie from the community rating,
+// it returns mongo.ErrNoDocuments when the user hasn't rated the movie.
+// Like RateMovie, it is two writes to run in Store.ExecTx
+func (q *Queries) DeleteRating(ctx context.Context, name string, movieID primitive.ObjectID) error {
+	var old Rating
+	err := q.ratings.FindOneAndDelete(ctx, bson.D{{"name", name}, {"movie_id", movieID}}).Decode(&old)
+	if err != nil {
+		return err
+	}
+	return q.addToCommunityRating(ctx, movieID, -old.Score, -1)
+}
+
+// addToCommunityRating adds to the sum and count of the community rating of a movie
+// and works out the average again, in a single update of the movie so that
+// concurrent ratings don't lose each other's changes
+func (q *Queries) addToCommunityRating(ctx context.Context, movieID primitive.ObjectID, sum, count int64) error {
+	_, err := q.movies.UpdateByID(ctx, movieID, mongo.Pipeline{
+		{{"$set", bson.D{
+			{"community.sum", bson.D{{"$add", bson.A{bson.D{{"$ifNull", bson.A{"$community.sum", 0}}}, sum}}}},
+			{"community.count", bson.D{{"$add", bson.A{bson.D{{"$ifNull", bson.A{"$community.count", 0}}}, count}}}},
+		}}},
+		{{"$set", bson.D{
+			{"community.rating", bson.D{{"$cond", bson.D{
+				{"if", bson.D{{"$gt", bson.A{"$community.count", 0}}}},
+				{"then", bson.D{{"$divide", bson.A{"$community.sum", "$community.count"}}}},
+				{"else", 0},
+			}}}},
+		}}},
+	})
+	return err
+}
+
+func (q *Queries) GetRating(ctx context.Context, name string, movieID primitive.ObjectID) (Rating, error) {
+	var rating Rating
+	err := q.ratings.FindOne(ctx, bson.D{{"name", name}, {"movie_id", movieID}}).Decode(&rating)
+	if err != nil {
+		return Rating{}, err
+	}
+	return rating, nil
+}
@@ -0,0 +1,98 @@
+package db
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"phantom/util"
+	"sync"
+	"testing"
+)
+
+func rateMovie(t *testing.T, name string, movieID primitive.ObjectID, score int64) {
+	err := testQueries.RateMovie(context.Background(), RateMovieParams{Name: name, MovieID: movieID, Score: score})
+	require.NoError(t, err)
+}
+
+func TestRateMovie(t *testing.T) {
+	movieID := addMovie(t, randomMovie())
+	user1, user2 := util.RandomUser(), util.RandomUser()
+
+	rateMovie(t, user1, movieID, 6)
+	rateMovie(t, user2, movieID, 9)
+	require.Equal(t, CommunityRating{Rating: 7.5, Count: 2, Sum: 15}, getMovieByID(t, movieID).Community)
+
+	// Rating again replaces the score
+	rateMovie(t, user1, movieID, 10)
+	require.Equal(t, CommunityRating{Rating: 9.5, Count: 2, Sum: 19}, getMovieByID(t, movieID).Community)
+
+	rating, err := testQueries.GetRating(context.Background(), user1, movieID)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), rating.Score)
+
+	require.NoError(t, testQueries.DeleteRating(context.Background(), user1, movieID))
+	require.ErrorIs(t, testQueries.DeleteRating(context.Background(), user1, movieID), mongo.ErrNoDocuments)
+	require.Equal(t, CommunityRating{Rating: 9, Count: 1, Sum: 9}, getMovieByID(t, movieID).Community)
+
+	require.NoError(t, testQueries.DeleteRating(context.Background(), user2, movieID))
+	require.Equal(t, CommunityRating{}, getMovieByID(t, movieID).Community)
+
+	_, err = testQueries.GetRating(context.Background(), user2, movieID)
+	require.ErrorIs(t, err, mongo.ErrNoDocuments)
+}
+
+func TestRateMovieConcurrently(t *testing.T) {
+	movieID := addMovie(t, randomMovie())
+	n := 10
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := testQueries.RateMovie(context.Background(), RateMovieParams{Name: util.RandomUser(), MovieID: movieID, Score: 7})
+			require.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, CommunityRating{Rating: 7, Count: int64(n), Sum: int64(7 * n)}, getMovieByID(t, movieID).Community)
+}
+
+func TestReplaceMovieKeepsCommunityRating(t *testing.T) {
+	movieID := addMovie(t, randomMovie())
+	rateMovie(t, util.RandomUser(), movieID, 4)
+
+	movie := getMovieByID(t, movieID)
+	movie.Title = util.RandomString(10)
+	movie.Community = CommunityRating{}
+	_, err := testQueries.ReplaceMovieInfoByID(context.Background(), movieID, movie)
+	require.NoError(t, err)
+
+	replaced := getMovieByID(t, movieID)
+	require.Equal(t, movie.Title, replaced.Title)
+	require.Equal(t, CommunityRating{Rating: 4, Count: 1, Sum: 4}, replaced.Community)
+}
+
+func TestGetMoviesByGenresSortByCommunity(t *testing.T) {
+	genre := util.RandomString(10)
+	var ids []primitive.ObjectID
+	for _, score := range []int64{5, 9, 7} {
+		movie := randomMovie()
+		movie.Genres = []string{genre}
+		id := addMovie(t, movie)
+		rateMovie(t, util.RandomUser(), id, score)
+		ids = append(ids, id)
+	}
+
+	movies, err := testQueries.GetMoviesByGenres(context.Background(), GetMoviesParams{
+		Genres:      genre,
+		SortOptions: "sort_community",
+		Limit:       5,
+	})
+	require.NoError(t, err)
+	require.Len(t, movies, 3)
+	require.Equal(t, ids[1], movies[0].Id)
+	require.Equal(t, ids[2], movies[1].Id)
+	require.Equal(t, ids[0], movies[2].Id)
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+// The reactions a user can leave on a comment, a like is one of them
+const (
+	ReactionLike  = "like"
+	ReactionLove  = "love"
+	ReactionHaha  = "haha"
+	ReactionWow   = "wow"
+	ReactionSad   = "sad"
+	ReactionAngry = "angry"
+)
+
+// CommentReaction is a reaction of a user to a comment, a user leaves
+// each kind of reaction once on a comment
+type CommentReaction struct {
+	ID        primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	CommentID primitive.ObjectID `json:"comment_id" bson:"comment_id"`
+	Name      string             `json:"name" bson:"name"`
+	Reaction  string             `json:"reaction" bson:"reaction"`
+	CreatedAt primitive.DateTime `json:"created_at" bson:"created_at"`
+}
+
+type CommentReactionParams struct {
+	CommentID primitive.ObjectID `json:"comment_id"`
+	Name      string             `json:"name"`
+	Reaction  string             `json:"reaction"`
+}
+
+// AddReaction adds a reaction of a user to a comment and counts it on the comment.
+// It returns false when the user had already left that reaction
+func (q *Queries) AddReaction(ctx context.Context, arg CommentReactionParams) (bool, error) {
+	_, err := q.reactions.InsertOne(ctx, CommentReaction{
+		CommentID: arg.CommentID,
+		Name:      arg.Name,
+		Reaction:  arg.Reaction,
+		CreatedAt: primitive.NewDateTimeFromTime(time.Now()),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, q.countReaction(ctx, arg.CommentID, arg.Reaction, 1)
+}
+
+// DeleteReaction takes back a reaction of a user to a comment,
+// it returns mongo.ErrNoDocuments when the user hasn't left that reaction
+func (q *Queries) DeleteReaction(ctx context.Context, arg CommentReactionParams) error {
+	res, err := q.reactions.DeleteOne(ctx, bson.M{
+		"comment_id": arg.CommentID,
+		"name":       arg.Name,
+		"reaction":   arg.Reaction,
+	})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return q.countReaction(ctx, arg.CommentID, arg.Reaction, -1)
+}
+
+func (q *Queries) countReaction(ctx context.Context, commentID primitive.ObjectID, reaction string, n int64) error {
+	_, err := q.comments.UpdateByID(ctx, commentID, bson.D{{"$inc", bson.D{
+		{"reactions." + reaction, n},
+		{"reaction_count", n},
+	}}})
+	return err
+}
+
+// GetReactionsByName gets the reactions a user left on the comments, in no particular order
+func (q *Queries) GetReactionsByName(ctx context.Context, name string, commentIDs []primitive.ObjectID) ([]CommentReaction, error) {
+	cursor, err := q.reactions.Find(ctx, bson.M{"name": name, "comment_id": bson.M{"$in": commentIDs}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var reactions []CommentReaction
+	if err = cursor.All(ctx, &reactions); err != nil {
+		return nil, err
+	}
+	return reactions, nil
+}
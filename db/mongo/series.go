@@ -0,0 +1,158 @@
+package db
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// A series is kept in the 'movies' collection with this type, like mflix does,
+// its seasons and episodes are in collections of their own
+const (
+	TypeMovie  = "movie"
+	TypeSeries = "series"
+)
+
+// SpecialsSeason is the season number of the specials of a series
+const SpecialsSeason = 0
+
+// The orders episodes can be listed in
+const (
+	EpisodeOrderAired    = "aired"
+	EpisodeOrderAbsolute = "absolute"
+)
+
+type Season struct {
+	ID       primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	SeriesID primitive.ObjectID `json:"series_id" bson:"series_id"`
+	Number   int64              `json:"number" bson:"number"`
+	Title    string             `json:"title" bson:"title,omitempty"`
+	Overview string             `json:"overview" bson:"overview,omitempty"`
+	Poster   string             `json:"poster" bson:"poster,omitempty"`
+	AirDate  primitive.DateTime `json:"air_date" bson:"air_date,omitempty"`
+}
+
+type AddSeasonParams struct {
+	SeriesID primitive.ObjectID `json:"series_id" bson:"series_id"`
+	Number   int64              `json:"number" bson:"number"`
+	Title    string             `json:"title" bson:"title,omitempty"`
+	Overview string             `json:"overview" bson:"overview,omitempty"`
+	Poster   string             `json:"poster" bson:"poster,omitempty"`
+	AirDate  primitive.DateTime `json:"air_date" bson:"air_date,omitempty"`
+}
+
+// Episode is numbered within its season, specials are in SpecialsSeason.
+// AbsoluteNumber counts the episodes of the whole series, it is zero when unknown
+type Episode struct {
+	ID             primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	SeriesID       primitive.ObjectID `json:"series_id" bson:"series_id"`
+	Season         int64              `json:"season" bson:"season"`
+	Number         int64              `json:"number" bson:"number"`
+	AbsoluteNumber int64              `json:"absolute_number" bson:"absolute_number,omitempty"`
+	Title          string             `json:"title" bson:"title,omitempty"`
+	Plot           string             `json:"plot" bson:"plot,omitempty"`
+	Runtime        int64              `json:"runtime" bson:"runtime,omitempty"`
+	AirDate        primitive.DateTime `json:"air_date" bson:"air_date,omitempty"`
+}
+
+type AddEpisodeParams struct {
+	SeriesID       primitive.ObjectID `json:"series_id" bson:"series_id"`
+	Season         int64              `json:"season" bson:"season"`
+	Number         int64              `json:"number" bson:"number"`
+	AbsoluteNumber int64              `json:"absolute_number" bson:"absolute_number,omitempty"`
+	Title          string             `json:"title" bson:"title,omitempty"`
+	Plot           string             `json:"plot" bson:"plot,omitempty"`
+	Runtime        int64              `json:"runtime" bson:"runtime,omitempty"`
+	AirDate        primitive.DateTime `json:"air_date" bson:"air_date,omitempty"`
+}
+
+// GetEpisodesParams lists the episodes of a series, of one season
+// when Season is set, or of every season otherwise
+type GetEpisodesParams struct {
+	SeriesID primitive.ObjectID `json:"series_id"`
+	Season   *int64             `json:"season"`
+	Order    string             `json:"order"`
+}
+
+func (q *Queries) AddSeason(ctx context.Context, arg AddSeasonParams) (primitive.ObjectID, error) {
+	res, err := q.seasons.InsertOne(ctx, arg)
+	if err != nil {
+		return primitive.ObjectID{}, err
+	}
+	return res.InsertedID.(primitive.ObjectID), nil
+}
+
+// GetSeasonsBySeriesID gets the seasons of a series by their number,
+// the specials come first
+func (q *Queries) GetSeasonsBySeriesID(ctx context.Context, seriesID primitive.ObjectID) ([]Season, error) {
+	findOptions := options.Find().SetSort(bson.D{{"number", 1}})
+	cursor, err := q.seasons.Find(ctx, bson.M{"series_id": seriesID}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var seasons []Season
+	if err = cursor.All(ctx, &seasons); err != nil {
+		return nil, err
+	}
+	return seasons, nil
+}
+
+func (q *Queries) AddEpisode(ctx context.Context, arg AddEpisodeParams) (primitive.ObjectID, error) {
+	res, err := q.episodes.InsertOne(ctx, arg)
+	if err != nil {
+		return primitive.ObjectID{}, err
+	}
+	return res.InsertedID.(primitive.ObjectID), nil
+}
+
+func (q *Queries) GetEpisodeByID(ctx context.Context, id primitive.ObjectID) (Episode, error) {
+	var episode Episode
+	err := q.episodes.FindOne(ctx, bson.M{"_id": id}).Decode(&episode)
+	if err != nil {
+		return Episode{}, err
+	}
+	return episode, nil
+}
+
+// GetEpisodes lists episodes in the order they aired, season by season,
+// or by their absolute number, which leaves out the episodes without one
+func (q *Queries) GetEpisodes(ctx context.Context, arg GetEpisodesParams) ([]Episode, error) {
+	filter := bson.M{"series_id": arg.SeriesID}
+	if arg.Season != nil {
+		filter["season"] = *arg.Season
+	}
+	sort := bson.D{{"season", 1}, {"number", 1}}
+	if arg.Order == EpisodeOrderAbsolute {
+		filter["absolute_number"] = bson.M{"$gt": 0}
+		sort = bson.D{{"absolute_number", 1}}
+	}
+	cursor, err := q.episodes.Find(ctx, filter, options.Find().SetSort(sort))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var episodes []Episode
+	if err = cursor.All(ctx, &episodes); err != nil {
+		return nil, err
+	}
+	return episodes, nil
+}
+
+// GetEpisodesByIDs gets the episodes with the given ids, in no particular order
+func (q *Queries) GetEpisodesByIDs(ctx context.Context, ids []primitive.ObjectID) ([]Episode, error) {
+	cursor, err := q.episodes.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var episodes []Episode
+	if err = cursor.All(ctx, &episodes); err != nil {
+		return nil, err
+	}
+	return episodes, nil
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"phantom/util"
+	"testing"
+	"time"
+)
+
+func addSeries(t *testing.T) primitive.ObjectID {
+	series := randomMovie()
+	series.Type = TypeSeries
+	return addMovie(t, series)
+}
+
+func addEpisode(t *testing.T, seriesID primitive.ObjectID, season, number, absolute int64) primitive.ObjectID {
+	id, err := testQueries.AddEpisode(context.Background(), AddEpisodeParams{
+		SeriesID:       seriesID,
+		Season:         season,
+		Number:         number,
+		AbsoluteNumber: absolute,
+		Title:          util.RandomString(8),
+		Runtime:        util.RandomInt(20, 60),
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, id)
+	return id
+}
+
+func TestGetSeasonsBySeriesID(t *testing.T) {
+	seriesID := addSeries(t)
+	for _, number := range []int64{2, SpecialsSeason, 1} {
+		_, err := testQueries.AddSeason(context.Background(), AddSeasonParams{SeriesID: seriesID, Number: number})
+		require.NoError(t, err)
+	}
+	_, err := testQueries.AddSeason(context.Background(), AddSeasonParams{SeriesID: seriesID, Number: 1})
+	require.True(t, mongo.IsDuplicateKeyError(err))
+
+	seasons, err := testQueries.GetSeasonsBySeriesID(context.Background(), seriesID)
+	require.NoError(t, err)
+	require.Len(t, seasons, 3)
+	for i, season := range seasons {
+		require.Equal(t, int64(i), season.Number)
+	}
+}
+
+func TestGetEpisodes(t *testing.T) {
+	seriesID := addSeries(t)
+	s2e1 := addEpisode(t, seriesID, 2, 1, 3)
+	special := addEpisode(t, seriesID, SpecialsSeason, 1, 0)
+	s1e2 := addEpisode(t, seriesID, 1, 2, 2)
+	s1e1 := addEpisode(t, seriesID, 1, 1, 1)
+
+	episode, err := testQueries.GetEpisodeByID(context.Background(), s1e2)
+	require.NoError(t, err)
+	require.Equal(t, seriesID, episode.SeriesID)
+	require.Equal(t, int64(2), episode.Number)
+
+	episodes, err := testQueries.GetEpisodes(context.Background(), GetEpisodesParams{SeriesID: seriesID, Order: EpisodeOrderAired})
+	require.NoError(t, err)
+	requireEpisodeIDs(t, []primitive.ObjectID{special, s1e1, s1e2, s2e1}, episodes)
+
+	season := int64(1)
+	episodes, err = testQueries.GetEpisodes(context.Background(), GetEpisodesParams{SeriesID: seriesID, Season: &season, Order: EpisodeOrderAired})
+	require.NoError(t, err)
+	requireEpisodeIDs(t, []primitive.ObjectID{s1e1, s1e2}, episodes)
+
+	// Specials have no absolute number
+	episodes, err = testQueries.GetEpisodes(context.Background(), GetEpisodesParams{SeriesID: seriesID, Order: EpisodeOrderAbsolute})
+	require.NoError(t, err)
+	requireEpisodeIDs(t, []primitive.ObjectID{s1e1, s1e2, s2e1}, episodes)
+}
+
+func requireEpisodeIDs(t *testing.T, ids []primitive.ObjectID, episodes []Episode) {
+	require.Len(t, episodes, len(ids))
+	for i := range ids {
+		require.Equal(t, ids[i], episodes[i].ID)
+	}
+}
+
+func TestGetProgressBySeriesID(t *testing.T) {
+	seriesID := addSeries(t)
+	name := util.RandomUser()
+	first := addEpisode(t, seriesID, 1, 1, 1)
+	second := addEpisode(t, seriesID, 1, 2, 2)
+
+	now := time.Now()
+	for _, progress := range []Progress{
+		{Name: name, MediaID: first, SeriesID: seriesID, Watched: true, UpdatedAt: primitive.NewDateTimeFromTime(now.Add(-time.Hour))},
+		{Name: name, MediaID: second, SeriesID: seriesID, Position: 60, UpdatedAt: primitive.NewDateTimeFromTime(now)},
+		{Name: util.RandomUser(), MediaID: first, SeriesID: seriesID, UpdatedAt: primitive.NewDateTimeFromTime(now)},
+	} {
+		_, err := testQueries.progress.InsertOne(context.Background(), progress)
+		require.NoError(t, err)
+	}
+
+	progress, err := testQueries.GetProgressBySeriesID(context.Background(), name, seriesID)
+	require.NoError(t, err)
+	require.Len(t, progress, 2)
+	require.Equal(t, second, progress[0].MediaID)
+	require.Equal(t, first, progress[1].MediaID)
+	require.True(t, progress[1].Watched)
+}
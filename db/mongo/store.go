@@ -1,17 +1,90 @@
 package db
 
-import "go.mongodb.org/mongo-driver/mongo"
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
 
+// Store is a Querier that also makes the changes that take several writes,
+// each in a transaction
 type Store interface {
 	Querier
+	ExecTx(ctx context.Context, fn func(q Querier) error) error
+	TransferCoinsTx(ctx context.Context, arg TransferCoinsParams) (TransferCoinsResult, error)
+	PinDanmakuTx(ctx context.Context, arg PinDanmakuTxParams) (PinDanmakuTxResult, error)
+	RequestTitleTx(ctx context.Context, arg RequestTitleTxParams) (TitleRequest, error)
 }
 
+// MongoStore needs MongoDB to run as a replica set for the transactions
 type MongoStore struct {
 	*Queries
+	client   *mongo.Client
+	database *mongo.Database
 }
 
 func NewMongoStore(database *mongo.Database) *MongoStore {
 	return &MongoStore{
-		Queries: NewMongoQueries(database),
+		Queries:  NewMongoQueries(database),
+		client:   database.Client(),
+		database: database,
+	}
+}
+
+// execTx runs fn in a transaction, which is retried on transient errors
+func (store *MongoStore) execTx(ctx context.Context, fn func(sessCtx mongo.SessionContext) error) error {
+	session, err := store.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+	return err
+}
+
+// inTx runs fn in the transaction of ctx, which ExecTx gives its queries,
+// or in a transaction of its own when ctx has none
+func (q *Queries) inTx(ctx context.Context, fn func(sessCtx mongo.SessionContext) error) error {
+	if sessCtx, ok := ctx.(mongo.SessionContext); ok {
+		return fn(sessCtx)
+	}
+	session, err := q.users.Database().Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+	return err
+}
+
+// ExecTx runs fn in a transaction: the queries fn makes with q are written
+// together when fn returns nil, and not at all when it returns an error.
+// A transaction that fails for a transient reason, like a write conflict
+// with another transaction, is run again, so fn may run more than once
+// and should only change its own variables when it does
+func (store *MongoStore) ExecTx(ctx context.Context, fn func(q Querier) error) error {
+	return store.execTx(ctx, func(sessCtx mongo.SessionContext) error {
+		return fn(txQueries{queries: store.Queries, session: sessCtx})
+	})
+}
+
+// Convert copies src into dst through BSON, the way a document is written
+// to MongoDB and read back: the fields dst doesn't have or src leaves empty
+// are dropped, and dst shares no slices or maps with src. Either can be a
+// slice. The stores that are not MongoDB keep their documents with it
+func Convert(src, dst interface{}) {
+	// The documents are plain structs and maps, which always marshal and unmarshal
+	data, err := bson.Marshal(bson.D{{"v", src}})
+	if err != nil {
+		panic(err)
+	}
+	if err = bson.Raw(data).Lookup("v").Unmarshal(dst); err != nil {
+		panic(err)
 	}
 }
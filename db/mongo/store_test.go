@@ -0,0 +1,61 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"testing"
+)
+
+func TestExecTx(t *testing.T) {
+	user := getUserByID(t, addUser(t, randomUser()))
+	movie := getMovieByID(t, addMovie(t, randomMovie()))
+
+	ctx := context.Background()
+	var committed primitive.ObjectID
+	err := testStore.ExecTx(ctx, func(q Querier) error {
+		var err error
+		committed, err = q.AddComment(ctx, randomComment(user, movie))
+		if err != nil {
+			return err
+		}
+		return q.IncrementMovieComments(ctx, movie.Id, 1)
+	})
+	require.NoError(t, err)
+	getCommentByID(t, committed)
+	require.Equal(t, movie.NumMflixComments+1, getMovieByID(t, movie.Id).NumMflixComments)
+
+	// Nothing fn wrote is kept when it fails
+	errRollback := errors.New("rollback")
+	var rolledBack primitive.ObjectID
+	err = testStore.ExecTx(ctx, func(q Querier) error {
+		var err error
+		rolledBack, err = q.AddComment(ctx, randomComment(user, movie))
+		if err != nil {
+			return err
+		}
+		if err = q.IncrementMovieComments(ctx, movie.Id, 1); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	require.Equal(t, errRollback, err)
+	_, err = testQueries.GetComment(ctx, rolledBack)
+	require.Equal(t, mongo.ErrNoDocuments, err)
+	require.Equal(t, movie.NumMflixComments+1, getMovieByID(t, movie.Id).NumMflixComments)
+
+	// Nor when a query fails
+	err = testStore.ExecTx(ctx, func(q Querier) error {
+		var err error
+		rolledBack, err = q.AddComment(ctx, randomComment(user, movie))
+		if err != nil {
+			return err
+		}
+		return q.IncrementMovieComments(ctx, primitive.NewObjectID(), 1)
+	})
+	require.Equal(t, mongo.ErrNoDocuments, err)
+	_, err = testQueries.GetComment(ctx, rolledBack)
+	require.Equal(t, mongo.ErrNoDocuments, err)
+}
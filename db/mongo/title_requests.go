@@ -0,0 +1,95 @@
+package db
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// TitleRequest asks for a movie or a series to be added to the catalog,
+// paid for with the coin transaction TransactionID
+type TitleRequest struct {
+	ID            primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	Name          string             `json:"name" bson:"name"`
+	Title         string             `json:"title" bson:"title"`
+	Year          int32              `json:"year" bson:"year,omitempty"`
+	Note          string             `json:"note" bson:"note,omitempty"`
+	TransactionID primitive.ObjectID `json:"transaction_id" bson:"transaction_id"`
+	CreatedAt     primitive.DateTime `json:"created_at" bson:"created_at"`
+}
+
+// RequestTitleTxParams requests a title for Cost coins of the user with the Name.
+// Key is the idempotency key of the request
+type RequestTitleTxParams struct {
+	Key   string `json:"key"`
+	Name  string `json:"name"`
+	Title string `json:"title"`
+	Year  int32  `json:"year"`
+	Note  string `json:"note"`
+	Cost  int64  `json:"cost"`
+}
+
+// RequestTitleTx adds a title request and takes the coins for it together.
+// Requesting again with the same key gives back the first request
+func (store *MongoStore) RequestTitleTx(ctx context.Context, arg RequestTitleTxParams) (TitleRequest, error) {
+	var request TitleRequest
+	err := store.execCoinTx(ctx, func(sessCtx mongo.SessionContext) error {
+		transfer, err := store.transferCoins(sessCtx, TransferCoinsParams{
+			Key:    CoinRequestTitle + ":" + arg.Key,
+			Kind:   CoinRequestTitle,
+			From:   UserCoinAccount(arg.Name),
+			To:     CoinAccountSpent,
+			Amount: arg.Cost,
+			Memo:   arg.Title,
+		})
+		if err != nil {
+			return err
+		}
+		if transfer.Replayed {
+			return store.titleRequests.FindOne(sessCtx, bson.M{"transaction_id": transfer.Transaction.ID}).Decode(&request)
+		}
+
+		request = TitleRequest{
+			Name:          arg.Name,
+			Title:         arg.Title,
+			Year:          arg.Year,
+			Note:          arg.Note,
+			TransactionID: transfer.Transaction.ID,
+			CreatedAt:     primitive.NewDateTimeFromTime(time.Now()),
+		}
+		res, err := store.titleRequests.InsertOne(sessCtx, request)
+		if err != nil {
+			return err
+		}
+		request.ID = res.InsertedID.(primitive.ObjectID)
+		return nil
+	})
+	return request, err
+}
+
+type GetTitleRequestsParams struct {
+	Limit int64 `json:"limit"`
+	Skip  int64 `json:"skip"`
+}
+
+// GetTitleRequests gets a page of the title requests, the latest first
+func (q *Queries) GetTitleRequests(ctx context.Context, arg GetTitleRequestsParams) ([]TitleRequest, error) {
+	findOptions := options.Find().
+		SetSort(bson.D{{"created_at", -1}, {"_id", -1}}).
+		SetSkip(arg.Skip).
+		SetLimit(arg.Limit)
+	cursor, err := q.titleRequests.Find(ctx, bson.M{}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var requests []TitleRequest
+	if err = cursor.All(ctx, &requests); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
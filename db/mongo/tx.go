@@ -0,0 +1,380 @@
+package db
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"phantom/ledger"
+)
+
+// txQueries runs the queries of ExecTx in its session. The mongo driver finds
+// the session in the context of each call, and fn is given the Querier but not
+// the session, so every method binds the session to the context it is called with.
+// It has a method for each method of Querier and nothing else, so that a query
+// added to Querier can't run outside the transaction unnoticed
+type txQueries struct {
+	queries *Queries
+	session mongo.Session
+}
+
+var _ Querier = txQueries{}
+
+func (q txQueries) bind(ctx context.Context) context.Context {
+	return mongo.NewSessionContext(ctx, q.session)
+}
+
+func (q txQueries) AddUser(ctx context.Context, arg AddUserParams) (primitive.ObjectID, error) {
+	return q.queries.AddUser(q.bind(ctx), arg)
+}
+
+func (q txQueries) GetUserByID(ctx context.Context, id primitive.ObjectID) (User, error) {
+	return q.queries.GetUserByID(q.bind(ctx), id)
+}
+
+func (q txQueries) GetUserByName(ctx context.Context, name string) (User, error) {
+	return q.queries.GetUserByName(q.bind(ctx), name)
+}
+
+func (q txQueries) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	return q.queries.GetUserByEmail(q.bind(ctx), email)
+}
+
+func (q txQueries) UpdateUserName(ctx context.Context, user User) (*mongo.UpdateResult, error) {
+	return q.queries.UpdateUserName(q.bind(ctx), user)
+}
+
+func (q txQueries) UpdateUserPassword(ctx context.Context, user User) (*mongo.UpdateResult, error) {
+	return q.queries.UpdateUserPassword(q.bind(ctx), user)
+}
+
+func (q txQueries) UpdateUserLocale(ctx context.Context, user User) (*mongo.UpdateResult, error) {
+	return q.queries.UpdateUserLocale(q.bind(ctx), user)
+}
+
+func (q txQueries) UpdateUserRole(ctx context.Context, user User) (*mongo.UpdateResult, error) {
+	return q.queries.UpdateUserRole(q.bind(ctx), user)
+}
+
+func (q txQueries) BanUser(ctx context.Context, name string) error {
+	return q.queries.BanUser(q.bind(ctx), name)
+}
+
+func (q txQueries) AddComment(ctx context.Context, arg AddCommentParams) (primitive.ObjectID, error) {
+	return q.queries.AddComment(q.bind(ctx), arg)
+}
+
+func (q txQueries) GetComment(ctx context.Context, id primitive.ObjectID) (Comments, error) {
+	return q.queries.GetComment(q.bind(ctx), id)
+}
+
+func (q txQueries) GetAllComments(ctx context.Context) ([]Comments, error) {
+	return q.queries.GetAllComments(q.bind(ctx))
+}
+
+func (q txQueries) GetCommentsByMovieID(ctx context.Context, arg GetCommentsParams) ([]Comments, error) {
+	return q.queries.GetCommentsByMovieID(q.bind(ctx), arg)
+}
+
+func (q txQueries) GetCommentsByName(ctx context.Context, arg GetCommentsParams) ([]Comments, error) {
+	return q.queries.GetCommentsByName(q.bind(ctx), arg)
+}
+
+func (q txQueries) UpdateComment(ctx context.Context, comment Comments) (*mongo.UpdateResult, error) {
+	return q.queries.UpdateComment(q.bind(ctx), comment)
+}
+
+func (q txQueries) DeleteComment(ctx context.Context, id primitive.ObjectID, name string) (int64, error) {
+	return q.queries.DeleteComment(q.bind(ctx), id, name)
+}
+
+func (q txQueries) DeleteCommentsByMovieID(ctx context.Context, movieID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	return q.queries.DeleteCommentsByMovieID(q.bind(ctx), movieID)
+}
+
+func (q txQueries) GetReplies(ctx context.Context, arg GetRepliesParams) ([]Comments, error) {
+	return q.queries.GetReplies(q.bind(ctx), arg)
+}
+
+func (q txQueries) GetReplySummaries(ctx context.Context, parentIDs []primitive.ObjectID, n int64) ([]ReplySummary, error) {
+	return q.queries.GetReplySummaries(q.bind(ctx), parentIDs, n)
+}
+
+func (q txQueries) AddReaction(ctx context.Context, arg CommentReactionParams) (bool, error) {
+	return q.queries.AddReaction(q.bind(ctx), arg)
+}
+
+func (q txQueries) DeleteReaction(ctx context.Context, arg CommentReactionParams) error {
+	return q.queries.DeleteReaction(q.bind(ctx), arg)
+}
+
+func (q txQueries) GetReactionsByName(ctx context.Context, name string, commentIDs []primitive.ObjectID) ([]CommentReaction, error) {
+	return q.queries.GetReactionsByName(q.bind(ctx), name, commentIDs)
+}
+
+func (q txQueries) RemoveComment(ctx context.Context, id primitive.ObjectID) (int64, error) {
+	return q.queries.RemoveComment(q.bind(ctx), id)
+}
+
+func (q txQueries) ReportComment(ctx context.Context, arg ReportCommentParams) (Comments, error) {
+	return q.queries.ReportComment(q.bind(ctx), arg)
+}
+
+func (q txQueries) GetModerationQueue(ctx context.Context, arg GetModerationQueueParams) ([]Comments, error) {
+	return q.queries.GetModerationQueue(q.bind(ctx), arg)
+}
+
+func (q txQueries) ApproveComment(ctx context.Context, id primitive.ObjectID) error {
+	return q.queries.ApproveComment(q.bind(ctx), id)
+}
+
+func (q txQueries) AddModerationLog(ctx context.Context, arg AddModerationLogParams) error {
+	return q.queries.AddModerationLog(q.bind(ctx), arg)
+}
+
+func (q txQueries) GetModerationLog(ctx context.Context, arg GetModerationLogParams) ([]ModerationLogEntry, error) {
+	return q.queries.GetModerationLog(q.bind(ctx), arg)
+}
+
+func (q txQueries) GetCommentReporters(ctx context.Context, commentID primitive.ObjectID) ([]string, error) {
+	return q.queries.GetCommentReporters(q.bind(ctx), commentID)
+}
+
+func (q txQueries) AddDanmaku(ctx context.Context, arg AddDanmakuParams) (Danmaku, error) {
+	return q.queries.AddDanmaku(q.bind(ctx), arg)
+}
+
+func (q txQueries) GetDanmaku(ctx context.Context, arg GetDanmakuParams) ([]Danmaku, error) {
+	return q.queries.GetDanmaku(q.bind(ctx), arg)
+}
+
+func (q txQueries) GetDanmakuModerationQueue(ctx context.Context, arg GetModerationQueueParams) ([]Danmaku, error) {
+	return q.queries.GetDanmakuModerationQueue(q.bind(ctx), arg)
+}
+
+func (q txQueries) ApproveDanmaku(ctx context.Context, id primitive.ObjectID) (Danmaku, error) {
+	return q.queries.ApproveDanmaku(q.bind(ctx), id)
+}
+
+func (q txQueries) RemoveDanmaku(ctx context.Context, id primitive.ObjectID) (Danmaku, error) {
+	return q.queries.RemoveDanmaku(q.bind(ctx), id)
+}
+
+func (q txQueries) GetLedgerProof(ctx context.Context, refID primitive.ObjectID) (ledger.Proof, error) {
+	return q.queries.GetLedgerProof(q.bind(ctx), refID)
+}
+
+func (q txQueries) CheckpointLedger(ctx context.Context) error {
+	return q.queries.CheckpointLedger(q.bind(ctx))
+}
+
+func (q txQueries) TransferCoins(ctx context.Context, arg TransferCoinsParams) (TransferCoinsResult, error) {
+	return q.queries.TransferCoins(q.bind(ctx), arg)
+}
+
+func (q txQueries) ReverseCoins(ctx context.Context, arg ReverseCoinsParams) (TransferCoinsResult, error) {
+	return q.queries.ReverseCoins(q.bind(ctx), arg)
+}
+
+func (q txQueries) GetCoinWallet(ctx context.Context, account string) (CoinWallet, error) {
+	return q.queries.GetCoinWallet(q.bind(ctx), account)
+}
+
+func (q txQueries) GetCoinTransactions(ctx context.Context, arg GetCoinTransactionsParams) ([]CoinTransaction, error) {
+	return q.queries.GetCoinTransactions(q.bind(ctx), arg)
+}
+
+func (q txQueries) ReconcileCoins(ctx context.Context) (CoinReconciliation, error) {
+	return q.queries.ReconcileCoins(q.bind(ctx))
+}
+
+func (q txQueries) GetTitleRequests(ctx context.Context, arg GetTitleRequestsParams) ([]TitleRequest, error) {
+	return q.queries.GetTitleRequests(q.bind(ctx), arg)
+}
+
+func (q txQueries) AddMovie(ctx context.Context, arg AddMovieParams) (primitive.ObjectID, error) {
+	return q.queries.AddMovie(q.bind(ctx), arg)
+}
+
+func (q txQueries) AddMovies(ctx context.Context, arg []AddMovieParams) ([]interface{}, error) {
+	return q.queries.AddMovies(q.bind(ctx), arg)
+}
+
+func (q txQueries) UpsertMovies(ctx context.Context, movies []Movies) (*mongo.BulkWriteResult, error) {
+	return q.queries.UpsertMovies(q.bind(ctx), movies)
+}
+
+func (q txQueries) GetMovieByID(ctx context.Context, id primitive.ObjectID) (Movies, error) {
+	return q.queries.GetMovieByID(q.bind(ctx), id)
+}
+
+func (q txQueries) GetAllMovies(ctx context.Context) ([]Movies, error) {
+	return q.queries.GetAllMovies(q.bind(ctx))
+}
+
+func (q txQueries) GetMoviesByIDs(ctx context.Context, ids []primitive.ObjectID) ([]Movies, error) {
+	return q.queries.GetMoviesByIDs(q.bind(ctx), ids)
+}
+
+func (q txQueries) GetMoviesByGenres(ctx context.Context, arg GetMoviesParams) ([]Movies, error) {
+	return q.queries.GetMoviesByGenres(q.bind(ctx), arg)
+}
+
+func (q txQueries) SearchForMovies(ctx context.Context, arg SearchForMoviesParams) ([]Movies, error) {
+	return q.queries.SearchForMovies(q.bind(ctx), arg)
+}
+
+func (q txQueries) GetTheMostViewedMovies(ctx context.Context, arg GetMoviesParams) ([]Movies, error) {
+	return q.queries.GetTheMostViewedMovies(q.bind(ctx), arg)
+}
+
+func (q txQueries) GetTheLatestReleasedMovies(ctx context.Context, arg GetMoviesParams) ([]Movies, error) {
+	return q.queries.GetTheLatestReleasedMovies(q.bind(ctx), arg)
+}
+
+func (q txQueries) AddPerson(ctx context.Context, arg AddPersonParams) (primitive.ObjectID, error) {
+	return q.queries.AddPerson(q.bind(ctx), arg)
+}
+
+func (q txQueries) GetPersonByID(ctx context.Context, id primitive.ObjectID) (Person, error) {
+	return q.queries.GetPersonByID(q.bind(ctx), id)
+}
+
+func (q txQueries) GetPeopleByIDs(ctx context.Context, ids []primitive.ObjectID) ([]Person, error) {
+	return q.queries.GetPeopleByIDs(q.bind(ctx), ids)
+}
+
+func (q txQueries) SearchPeople(ctx context.Context, arg SearchPeopleParams) ([]Person, error) {
+	return q.queries.SearchPeople(q.bind(ctx), arg)
+}
+
+func (q txQueries) UpdatePerson(ctx context.Context, person Person) (*mongo.UpdateResult, error) {
+	return q.queries.UpdatePerson(q.bind(ctx), person)
+}
+
+func (q txQueries) GetMoviesByPersonID(ctx context.Context, id primitive.ObjectID) ([]Movies, error) {
+	return q.queries.GetMoviesByPersonID(q.bind(ctx), id)
+}
+
+func (q txQueries) AddSeason(ctx context.Context, arg AddSeasonParams) (primitive.ObjectID, error) {
+	return q.queries.AddSeason(q.bind(ctx), arg)
+}
+
+func (q txQueries) GetSeasonsBySeriesID(ctx context.Context, seriesID primitive.ObjectID) ([]Season, error) {
+	return q.queries.GetSeasonsBySeriesID(q.bind(ctx), seriesID)
+}
+
+func (q txQueries) AddEpisode(ctx context.Context, arg AddEpisodeParams) (primitive.ObjectID, error) {
+	return q.queries.AddEpisode(q.bind(ctx), arg)
+}
+
+func (q txQueries) GetEpisodeByID(ctx context.Context, id primitive.ObjectID) (Episode, error) {
+	return q.queries.GetEpisodeByID(q.bind(ctx), id)
+}
+
+func (q txQueries) GetEpisodes(ctx context.Context, arg GetEpisodesParams) ([]Episode, error) {
+	return q.queries.GetEpisodes(q.bind(ctx), arg)
+}
+
+func (q txQueries) GetEpisodesByIDs(ctx context.Context, ids []primitive.ObjectID) ([]Episode, error) {
+	return q.queries.GetEpisodesByIDs(q.bind(ctx), ids)
+}
+
+func (q txQueries) GetProgressBySeriesID(ctx context.Context, name string, seriesID primitive.ObjectID) ([]Progress, error) {
+	return q.queries.GetProgressBySeriesID(q.bind(ctx), name, seriesID)
+}
+
+func (q txQueries) SaveProgress(ctx context.Context, arg SaveProgressParams) error {
+	return q.queries.SaveProgress(q.bind(ctx), arg)
+}
+
+func (q txQueries) GetContinueWatching(ctx context.Context, arg GetContinueWatchingParams) ([]Progress, error) {
+	return q.queries.GetContinueWatching(q.bind(ctx), arg)
+}
+
+func (q txQueries) GetProgressByMediaIDs(ctx context.Context, name string, ids []primitive.ObjectID) ([]Progress, error) {
+	return q.queries.GetProgressByMediaIDs(q.bind(ctx), name, ids)
+}
+
+func (q txQueries) GetHistory(ctx context.Context, arg GetHistoryParams) ([]HistoryEntry, error) {
+	return q.queries.GetHistory(q.bind(ctx), arg)
+}
+
+func (q txQueries) DeleteHistoryEntry(ctx context.Context, name string, id primitive.ObjectID) error {
+	return q.queries.DeleteHistoryEntry(q.bind(ctx), name, id)
+}
+
+func (q txQueries) GetUserStats(ctx context.Context, arg GetUserStatsParams) (UserStats, error) {
+	return q.queries.GetUserStats(q.bind(ctx), arg)
+}
+
+func (q txQueries) AddCollection(ctx context.Context, arg AddCollectionParams) (primitive.ObjectID, error) {
+	return q.queries.AddCollection(q.bind(ctx), arg)
+}
+
+func (q txQueries) GetCollectionByID(ctx context.Context, id primitive.ObjectID) (Collection, error) {
+	return q.queries.GetCollectionByID(q.bind(ctx), id)
+}
+
+func (q txQueries) GetCollections(ctx context.Context, arg GetCollectionsParams) ([]Collection, error) {
+	return q.queries.GetCollections(q.bind(ctx), arg)
+}
+
+func (q txQueries) GetCollectionsByMovie(ctx context.Context, movie Movies) ([]Collection, error) {
+	return q.queries.GetCollectionsByMovie(q.bind(ctx), movie)
+}
+
+func (q txQueries) GetCollectionMovies(ctx context.Context, arg GetCollectionMoviesParams) ([]Movies, error) {
+	return q.queries.GetCollectionMovies(q.bind(ctx), arg)
+}
+
+func (q txQueries) ReplaceCollection(ctx context.Context, collection Collection) (*mongo.UpdateResult, error) {
+	return q.queries.ReplaceCollection(q.bind(ctx), collection)
+}
+
+func (q txQueries) DeleteCollection(ctx context.Context, id primitive.ObjectID) (int64, error) {
+	return q.queries.DeleteCollection(q.bind(ctx), id)
+}
+
+func (q txQueries) AddListEntry(ctx context.Context, arg ListEntryParams) (*mongo.UpdateResult, error) {
+	return q.queries.AddListEntry(q.bind(ctx), arg)
+}
+
+func (q txQueries) DeleteListEntry(ctx context.Context, arg ListEntryParams) (int64, error) {
+	return q.queries.DeleteListEntry(q.bind(ctx), arg)
+}
+
+func (q txQueries) GetListEntries(ctx context.Context, arg GetListParams) ([]ListEntry, error) {
+	return q.queries.GetListEntries(q.bind(ctx), arg)
+}
+
+func (q txQueries) ReorderList(ctx context.Context, arg ReorderListParams) error {
+	return q.queries.ReorderList(q.bind(ctx), arg)
+}
+
+func (q txQueries) GetListsByMovie(ctx context.Context, name string, movieID primitive.ObjectID) ([]string, error) {
+	return q.queries.GetListsByMovie(q.bind(ctx), name, movieID)
+}
+
+func (q txQueries) RateMovie(ctx context.Context, arg RateMovieParams) error {
+	return q.queries.RateMovie(q.bind(ctx), arg)
+}
+
+func (q txQueries) DeleteRating(ctx context.Context, name string, movieID primitive.ObjectID) error {
+	return q.queries.DeleteRating(q.bind(ctx), name, movieID)
+}
+
+func (q txQueries) GetRating(ctx context.Context, name string, movieID primitive.ObjectID) (Rating, error) {
+	return q.queries.GetRating(q.bind(ctx), name, movieID)
+}
+
+func (q txQueries) ReplaceMovieInfoByID(ctx context.Context, id primitive.ObjectID, movie Movies) (*mongo.UpdateResult, error) {
+	return q.queries.ReplaceMovieInfoByID(q.bind(ctx), id, movie)
+}
+
+func (q txQueries) IncrementMovieComments(ctx context.Context, id primitive.ObjectID, n int64) error {
+	return q.queries.IncrementMovieComments(q.bind(ctx), id, n)
+}
+
+func (q txQueries) DeleteMovieByID(ctx context.Context, id primitive.ObjectID) (int64, error) {
+	return q.queries.DeleteMovieByID(q.bind(ctx), id)
+}
@@ -12,8 +12,16 @@ type User struct {
 	Name     string             `json:"name" bson:"name,omitempty"`
 	Email    string             `json:"email" bson:"email,omitempty"`
 	Password string             `json:"password" bson:"password,omitempty"`
+	Locale   string             `json:"locale" bson:"locale,omitempty"`
+	Role     string             `json:"role" bson:"role,omitempty"`
+	// A Banned user can't comment
+	Banned bool `json:"banned" bson:"banned,omitempty"`
 }
 
+// UserRoleAdmin is the role of the users who can curate the catalog,
+// other users have no role
+const UserRoleAdmin = "admin"
+
 type AddUserParams struct {
 	Name     string `json:"name" bson:"name,omitempty"`
 	Email    string `json:"email" bson:"email,omitempty"`
@@ -80,3 +88,46 @@ func (q *Queries) UpdateUserPassword(ctx context.Context, user User) (*mongo.Upd
 	}
 	return res, nil
 }
+
+// UpdateUserLocale sets the locale the user prefers titles in
+func (q *Queries) UpdateUserLocale(ctx context.Context, user User) (*mongo.UpdateResult, error) {
+	res, err := q.users.UpdateByID(ctx, user.ID, bson.D{
+		{"$set", bson.D{
+			{"locale", user.Locale},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// UpdateUserRole sets the role of the user, such as UserRoleAdmin
+func (q *Queries) UpdateUserRole(ctx context.Context, user User) (*mongo.UpdateResult, error) {
+	res, err := q.users.UpdateByID(ctx, user.ID, bson.D{
+		{"$set", bson.D{
+			{"role", user.Role},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// BanUser stops a user from commenting,
+// it returns mongo.ErrNoDocuments when there is no such user
+func (q *Queries) BanUser(ctx context.Context, name string) error {
+	res, err := q.users.UpdateOne(ctx, bson.M{"name": name}, bson.D{
+		{"$set", bson.D{
+			{"banned", true},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
@@ -101,3 +101,15 @@ func TestUpdateUserPassword(t *testing.T) {
 	require.Equal(t, user1.Name, user2.Name)
 	require.Equal(t, user1.Password, user2.Password)
 }
+
+func TestUpdateUserLocale(t *testing.T) {
+	id := addUser(t, randomUser())
+	user1 := getUserByID(t, id)
+	user1.Locale = "zh-Hans"
+	updateResult, err := testQueries.UpdateUserLocale(context.Background(), user1)
+	require.NoError(t, err)
+	require.NotEmpty(t, updateResult)
+	user2 := getUserByID(t, id)
+	require.Equal(t, user1.Locale, user2.Locale)
+	require.Equal(t, user1.Name, user2.Name)
+}
@@ -0,0 +1,216 @@
+package sqlitedb
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"sort"
+	"time"
+)
+
+var errCoinAmount = errors.New("the amount has to be positive")
+
+// transferCoins makes a transfer, within the transaction q is part of
+func (q *Queries) transferCoins(ctx context.Context, arg db.TransferCoinsParams) (db.TransferCoinsResult, error) {
+	if arg.Amount <= 0 {
+		return db.TransferCoinsResult{}, errCoinAmount
+	}
+	postings := []db.CoinPosting{{Account: arg.From, Amount: -arg.Amount}, {Account: arg.To, Amount: arg.Amount}}
+
+	var existing db.CoinTransaction
+	err := q.getDoc(ctx, &existing, "SELECT doc FROM coin_transactions WHERE key = ?", arg.Key)
+	if err == nil {
+		same := existing.Kind == arg.Kind && len(existing.Postings) == len(postings)
+		for i := 0; same && i < len(postings); i++ {
+			same = existing.Postings[i] == postings[i]
+		}
+		if !same {
+			return db.TransferCoinsResult{}, db.ErrCoinKeyReused
+		}
+		return db.TransferCoinsResult{Transaction: existing, Replayed: true}, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return db.TransferCoinsResult{}, err
+	}
+
+	now := primitive.NewDateTimeFromTime(time.Now())
+	owes := arg.From == db.CoinAccountIssued || arg.Debt
+	from, err := q.GetCoinWallet(ctx, arg.From)
+	if err == mongo.ErrNoDocuments && owes {
+		from, err = db.CoinWallet{Account: arg.From}, nil
+	}
+	if err == mongo.ErrNoDocuments || (err == nil && !owes && from.Balance < arg.Amount) {
+		return db.TransferCoinsResult{}, db.ErrInsufficientCoins
+	}
+	if err != nil {
+		return db.TransferCoinsResult{}, err
+	}
+	if err = q.addCoins(ctx, from, -arg.Amount, now); err != nil {
+		return db.TransferCoinsResult{}, err
+	}
+
+	to, err := q.GetCoinWallet(ctx, arg.To)
+	if err == mongo.ErrNoDocuments {
+		to, err = db.CoinWallet{Account: arg.To}, nil
+	}
+	if err != nil {
+		return db.TransferCoinsResult{}, err
+	}
+	if err = q.addCoins(ctx, to, arg.Amount, now); err != nil {
+		return db.TransferCoinsResult{}, err
+	}
+
+	transaction := db.CoinTransaction{
+		ID:        primitive.NewObjectID(),
+		Key:       arg.Key,
+		Kind:      arg.Kind,
+		Postings:  postings,
+		Memo:      arg.Memo,
+		CreatedAt: now,
+	}
+	_, err = q.insert(ctx, "coin_transactions", hex(transaction.ID), transaction,
+		"key", transaction.Key, "created_at", int64(transaction.CreatedAt))
+	if err != nil {
+		return db.TransferCoinsResult{}, err
+	}
+	for _, posting := range postings {
+		_, err = q.db.ExecContext(ctx, "INSERT INTO coin_postings (transaction_id, account, amount) VALUES (?, ?, ?)",
+			hex(transaction.ID), posting.Account, posting.Amount)
+		if err != nil {
+			return db.TransferCoinsResult{}, err
+		}
+	}
+	return db.TransferCoinsResult{Transaction: transaction}, nil
+}
+
+// addCoins adds n to the balance of the wallet, writing the wallet when it is new
+func (q *Queries) addCoins(ctx context.Context, wallet db.CoinWallet, n int64, now primitive.DateTime) error {
+	wallet.Balance += n
+	wallet.UpdatedAt = now
+	data, err := bson.Marshal(wallet)
+	if err != nil {
+		return err
+	}
+	_, err = q.db.ExecContext(ctx, `
+INSERT INTO coin_wallets (id, balance, doc) VALUES (?, ?, ?)
+ON CONFLICT (id) DO UPDATE SET balance = excluded.balance, doc = excluded.doc`, wallet.Account, wallet.Balance, data)
+	return err
+}
+
+// TransferCoinsTx makes a transfer: the transaction and both balances are
+// written together or not at all
+func (store *Store) TransferCoinsTx(ctx context.Context, arg db.TransferCoinsParams) (db.TransferCoinsResult, error) {
+	var result db.TransferCoinsResult
+	err := execTx(ctx, store.db, func(q *Queries) error {
+		var err error
+		result, err = q.transferCoins(ctx, arg)
+		return err
+	})
+	return result, err
+}
+
+// TransferCoins makes a transfer, in the transaction q is part of or in one of its own
+func (q *Queries) TransferCoins(ctx context.Context, arg db.TransferCoinsParams) (db.TransferCoinsResult, error) {
+	var result db.TransferCoinsResult
+	err := q.inTx(ctx, func(q *Queries) error {
+		var err error
+		result, err = q.transferCoins(ctx, arg)
+		return err
+	})
+	return result, err
+}
+
+// ReverseCoins moves the coins of a transaction back, and is replayed by its
+// key like a transfer. It is ErrNoDocuments when there is no transaction to reverse
+func (q *Queries) ReverseCoins(ctx context.Context, arg db.ReverseCoinsParams) (db.TransferCoinsResult, error) {
+	var result db.TransferCoinsResult
+	err := q.inTx(ctx, func(q *Queries) error {
+		var reversed db.CoinTransaction
+		err := q.getDoc(ctx, &reversed, "SELECT doc FROM coin_transactions WHERE key = ?", arg.Reverses)
+		if err != nil {
+			return err
+		}
+		result, err = q.transferCoins(ctx, arg.Transfer(reversed))
+		return err
+	})
+	return result, err
+}
+
+// GetCoinWallet gets the wallet of an account, ErrNoDocuments
+// until the account has had a transaction
+func (q *Queries) GetCoinWallet(ctx context.Context, account string) (db.CoinWallet, error) {
+	var wallet db.CoinWallet
+	err := q.getDoc(ctx, &wallet, "SELECT doc FROM coin_wallets WHERE id = ?", account)
+	return wallet, err
+}
+
+// GetCoinTransactions gets a page of the transactions of an account, the latest first
+func (q *Queries) GetCoinTransactions(ctx context.Context, arg db.GetCoinTransactionsParams) ([]db.CoinTransaction, error) {
+	var transactions []db.CoinTransaction
+	err := q.getDocs(ctx, &transactions, `
+SELECT doc FROM coin_transactions
+WHERE id IN (SELECT transaction_id FROM coin_postings WHERE account = ?)
+ORDER BY created_at DESC, id DESC`+limit(arg.Skip, arg.Limit), arg.Account)
+	return transactions, err
+}
+
+// ReconcileCoins checks the balances against the transactions
+func (q *Queries) ReconcileCoins(ctx context.Context) (db.CoinReconciliation, error) {
+	var result db.CoinReconciliation
+	err := q.inTx(ctx, func(q *Queries) error {
+		var wallets []db.CoinWallet
+		if err := q.getDocs(ctx, &wallets, "SELECT doc FROM coin_wallets"); err != nil {
+			return err
+		}
+		var transactions []db.CoinTransaction
+		if err := q.getDocs(ctx, &transactions, "SELECT doc FROM coin_transactions ORDER BY created_at, id"); err != nil {
+			return err
+		}
+		result = reconcileCoins(wallets, transactions)
+		return nil
+	})
+	return result, err
+}
+
+// reconcileCoins adds up the postings of the transactions of each account
+// and compares them with the balances of the wallets
+func reconcileCoins(wallets []db.CoinWallet, transactions []db.CoinTransaction) db.CoinReconciliation {
+	result := db.CoinReconciliation{Transactions: int64(len(transactions))}
+	accounts := make(map[string]*db.CoinMismatch)
+	account := func(name string) *db.CoinMismatch {
+		if accounts[name] == nil {
+			accounts[name] = &db.CoinMismatch{Account: name}
+		}
+		return accounts[name]
+	}
+	for _, wallet := range wallets {
+		account(wallet.Account).Balance += wallet.Balance
+	}
+	for _, transaction := range transactions {
+		var sum int64
+		for _, posting := range transaction.Postings {
+			sum += posting.Amount
+			account(posting.Account).Expected += posting.Amount
+		}
+		if sum != 0 {
+			result.Unbalanced = append(result.Unbalanced, transaction.ID)
+		}
+	}
+
+	names := make([]string, 0, len(accounts))
+	for name := range accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		a := accounts[name]
+		result.Total += a.Balance
+		if a.Balance != a.Expected {
+			result.Mismatches = append(result.Mismatches, *a)
+		}
+	}
+	return result
+}
@@ -0,0 +1,172 @@
+package sqlitedb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"sort"
+)
+
+func (q *Queries) AddCollection(ctx context.Context, arg db.AddCollectionParams) (primitive.ObjectID, error) {
+	var collection db.Collection
+	db.Convert(arg, &collection)
+	collection.ID = primitive.NewObjectID()
+	if _, err := q.insert(ctx, "collections", hex(collection.ID), collection, "title", collection.Title); err != nil {
+		return primitive.ObjectID{}, err
+	}
+	return collection.ID, nil
+}
+
+func (q *Queries) GetCollectionByID(ctx context.Context, id primitive.ObjectID) (db.Collection, error) {
+	var collection db.Collection
+	err := q.getDoc(ctx, &collection, "SELECT doc FROM collections WHERE id = ?", hex(id))
+	return collection, err
+}
+
+// GetCollections gets a page of collections by title
+func (q *Queries) GetCollections(ctx context.Context, arg db.GetCollectionsParams) ([]db.Collection, error) {
+	var collections []db.Collection
+	err := q.getDocs(ctx, &collections, "SELECT doc FROM collections ORDER BY title, id"+limit(arg.Skip, arg.Limit))
+	return collections, err
+}
+
+// GetCollectionsByMovie gets the curated collections that list the movie
+// and the smart collections whose filter matches it
+func (q *Queries) GetCollectionsByMovie(ctx context.Context, movie db.Movies) ([]db.Collection, error) {
+	var all []db.Collection
+	if err := q.getDocs(ctx, &all, "SELECT doc FROM collections ORDER BY title, id"); err != nil {
+		return nil, err
+	}
+	var collections []db.Collection
+	for _, collection := range all {
+		if collection.Smart() {
+			if collection.Filter.Matches(movie) {
+				collections = append(collections, collection)
+			}
+			continue
+		}
+		for _, id := range collection.MovieIDs {
+			if id == movie.Id {
+				collections = append(collections, collection)
+				break
+			}
+		}
+	}
+	return collections, nil
+}
+
+// GetCollectionMovies gets a page of the movies of a collection,
+// in the order of a curated collection or the sort of a smart one
+func (q *Queries) GetCollectionMovies(ctx context.Context, arg db.GetCollectionMoviesParams) ([]db.Movies, error) {
+	if arg.Collection.Smart() {
+		return q.getSmartCollectionMovies(ctx, arg)
+	}
+
+	ids := arg.Collection.MovieIDs
+	if arg.Skip >= int64(len(ids)) {
+		return nil, nil
+	}
+	ids = ids[arg.Skip:]
+	if arg.Limit > 0 && arg.Limit < int64(len(ids)) {
+		ids = ids[:arg.Limit]
+	}
+	found, err := q.GetMoviesByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[primitive.ObjectID]db.Movies, len(found))
+	for _, movie := range found {
+		byID[movie.Id] = movie
+	}
+	// Movies removed from the catalog are left out
+	var movies []db.Movies
+	for _, id := range ids {
+		if movie, ok := byID[id]; ok {
+			movies = append(movies, movie)
+		}
+	}
+	return movies, nil
+}
+
+// getSmartCollectionMovies matches the filter of a smart collection against
+// every movie, which CollectionFilter.Matches does the way MongoDB would
+func (q *Queries) getSmartCollectionMovies(ctx context.Context, arg db.GetCollectionMoviesParams) ([]db.Movies, error) {
+	var all []db.Movies
+	if err := q.getDocs(ctx, &all, "SELECT doc FROM movies ORDER BY seq"); err != nil {
+		return nil, err
+	}
+	filter := arg.Collection.Filter
+	var movies []db.Movies
+	for _, movie := range all {
+		if filter.Matches(movie) {
+			movies = append(movies, movie)
+		}
+	}
+	sort.SliceStable(movies, func(i, j int) bool {
+		a, b := movies[i], movies[j]
+		switch filter.Sort {
+		case db.CollectionSortRating:
+			if a.Imdb.Rating != b.Imdb.Rating {
+				return a.Imdb.Rating > b.Imdb.Rating
+			}
+		case db.CollectionSortTitle:
+			if a.SortTitle != b.SortTitle {
+				return a.SortTitle < b.SortTitle
+			}
+		default:
+			if a.Year != b.Year {
+				return a.Year < b.Year
+			}
+		}
+		return hex(a.Id) < hex(b.Id)
+	})
+
+	from := int(arg.Skip)
+	if from > len(movies) || from < 0 {
+		from = len(movies)
+	}
+	to := len(movies)
+	if arg.Limit > 0 && from+int(arg.Limit) < to {
+		to = from + int(arg.Limit)
+	}
+	if from == to {
+		return nil, nil
+	}
+	return projectMovies(movies[from:to]), nil
+}
+
+// ReplaceCollection replaces a collection, it can turn a curated collection
+// into a smart one and back
+func (q *Queries) ReplaceCollection(ctx context.Context, collection db.Collection) (*mongo.UpdateResult, error) {
+	res := &mongo.UpdateResult{MatchedCount: 1}
+	err := q.inTx(ctx, func(q *Queries) error {
+		old, err := q.GetCollectionByID(ctx, collection.ID)
+		if err != nil {
+			return err
+		}
+		var replaced db.Collection
+		db.Convert(collection, &replaced)
+		if !sameDocument(replaced, old) {
+			res.ModifiedCount = 1
+		}
+		_, err = q.update(ctx, "collections", hex(replaced.ID), replaced, "title", replaced.Title)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (q *Queries) DeleteCollection(ctx context.Context, id primitive.ObjectID) (int64, error) {
+	res, err := q.db.ExecContext(ctx, "DELETE FROM collections WHERE id = ?", hex(id))
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		return 0, mongo.ErrNoDocuments
+	}
+	return n, err
+}
@@ -0,0 +1,284 @@
+package sqlitedb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"phantom/ledger"
+	"time"
+)
+
+// AddComment adds a comment dated now, and records it in the ledger.
+// It needs a text like the validator of 'comments'
+func (q *Queries) AddComment(ctx context.Context, arg db.AddCommentParams) (primitive.ObjectID, error) {
+	if arg.Text == "" {
+		return primitive.ObjectID{}, errValidation()
+	}
+	var comment db.Comments
+	db.Convert(arg, &comment)
+	comment.ID = primitive.NewObjectID()
+	comment.Date = primitive.NewDateTimeFromTime(time.Now())
+	err := q.inTx(ctx, func(q *Queries) error {
+		if _, err := q.insert(ctx, "comments", hex(comment.ID), comment, commentColumns(comment)...); err != nil {
+			return err
+		}
+		return q.appendLedger(ctx, db.LedgerComment, ledger.OpAdd, comment.ID, db.CommentDigest(comment))
+	})
+	if err != nil {
+		return primitive.ObjectID{}, err
+	}
+	return comment.ID, nil
+}
+
+// commentColumns are the columns of 'comments' the queries look up or sort by
+func commentColumns(comment db.Comments) []interface{} {
+	var parentID interface{}
+	if !comment.ParentID.IsZero() {
+		parentID = hex(comment.ParentID)
+	}
+	return []interface{}{
+		"movie_id", hex(comment.MovieID),
+		"name", comment.Name,
+		"parent_id", parentID,
+		"date", int64(comment.Date),
+		"deleted", boolInt(comment.Deleted),
+		"hidden", boolInt(comment.Hidden),
+		"flagged", boolInt(len(comment.Flags) > 0),
+		"reaction_count", comment.ReactionCount,
+		"report_count", comment.ReportCount,
+	}
+}
+
+func (q *Queries) putComment(ctx context.Context, comment db.Comments) error {
+	_, err := q.update(ctx, "comments", hex(comment.ID), comment, commentColumns(comment)...)
+	return err
+}
+
+func (q *Queries) GetComment(ctx context.Context, id primitive.ObjectID) (db.Comments, error) {
+	var comment db.Comments
+	err := q.getDoc(ctx, &comment, "SELECT doc FROM comments WHERE id = ?", hex(id))
+	return comment, err
+}
+
+// GetAllComments gets every comment but the deleted and hidden ones
+func (q *Queries) GetAllComments(ctx context.Context) ([]db.Comments, error) {
+	var comments []db.Comments
+	err := q.getDocs(ctx, &comments, "SELECT doc FROM comments WHERE NOT deleted AND NOT hidden ORDER BY seq")
+	return comments, err
+}
+
+func (q *Queries) GetCommentsByMovieID(ctx context.Context, arg db.GetCommentsParams) ([]db.Comments, error) {
+	orderBy := "seq"
+	switch arg.Sort {
+	case db.CommentSortTop:
+		orderBy = "reaction_count DESC, date DESC, id DESC"
+	case db.CommentSortNew:
+		orderBy = "date DESC, id DESC"
+	case db.CommentSortOld:
+		orderBy = "date, id"
+	}
+	where := "movie_id = ?"
+	if arg.TopLevel {
+		where += " AND parent_id IS NULL"
+	}
+	var comments []db.Comments
+	err := q.getDocs(ctx, &comments, "SELECT doc FROM comments WHERE "+where+" ORDER BY "+orderBy+commentsPage(arg.Skip, arg.Limit), hex(arg.MovieID))
+	return comments, err
+}
+
+func (q *Queries) GetCommentsByName(ctx context.Context, arg db.GetCommentsParams) ([]db.Comments, error) {
+	var comments []db.Comments
+	err := q.getDocs(ctx, &comments, "SELECT doc FROM comments WHERE name = ? AND NOT deleted ORDER BY seq"+commentsPage(arg.Skip, arg.Limit), arg.Name)
+	return comments, err
+}
+
+// commentsPage is the page of a list of comments, which is every comment
+// when the limit isn't positive, however many it skips
+func commentsPage(skip, n int64) string {
+	if n <= 0 {
+		skip = 0
+	}
+	return limit(skip, n)
+}
+
+// UpdateComment changes the text of a comment of a user, and adds
+// the Flags of the comment to the ones it had. The edit is recorded in the ledger
+func (q *Queries) UpdateComment(ctx context.Context, comment db.Comments) (*mongo.UpdateResult, error) {
+	err := q.inTx(ctx, func(q *Queries) error {
+		edited, err := q.GetComment(ctx, comment.ID)
+		if err != nil {
+			return err
+		}
+		if edited.Name != comment.Name || edited.Deleted {
+			return mongo.ErrNoDocuments
+		}
+		flags := addToSet(edited.Flags, comment.Flags)
+		if edited.Text == comment.Text && len(flags) == len(edited.Flags) {
+			return mongo.ErrNoDocuments
+		}
+		edited.Text = comment.Text
+		edited.Flags = flags
+		if err = q.putComment(ctx, edited); err != nil {
+			return err
+		}
+		return q.appendLedger(ctx, db.LedgerComment, ledger.OpEdit, comment.ID, db.CommentDigest(edited))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil
+}
+
+// addToSet adds the values set doesn't have yet, to a copy of set
+func addToSet(set []string, values []string) []string {
+	added := set
+	for _, v := range values {
+		if !containsString(added, v) {
+			added = append(added[:len(added):len(added)], v)
+		}
+	}
+	return added
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteComment deletes a comment of a user. A comment with replies is only
+// marked Deleted and loses its text and email, so that its replies keep their place
+func (q *Queries) DeleteComment(ctx context.Context, id primitive.ObjectID, name string) (int64, error) {
+	return q.deleteComment(ctx, id, func(comment db.Comments) bool { return comment.Name == name })
+}
+
+// RemoveComment deletes a comment on behalf of a moderator, like DeleteComment
+func (q *Queries) RemoveComment(ctx context.Context, id primitive.ObjectID) (int64, error) {
+	return q.deleteComment(ctx, id, func(db.Comments) bool { return true })
+}
+
+// deleteComment deletes the comment with the id if match matches it and it
+// isn't deleted already, and records the deletion in the ledger
+func (q *Queries) deleteComment(ctx context.Context, id primitive.ObjectID, match func(comment db.Comments) bool) (int64, error) {
+	err := q.inTx(ctx, func(q *Queries) error {
+		comment, err := q.GetComment(ctx, id)
+		if err != nil {
+			return err
+		}
+		if comment.Deleted || !match(comment) {
+			return mongo.ErrNoDocuments
+		}
+		var hasReplies bool
+		err = q.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM comments WHERE parent_id = ?)", hex(id)).Scan(&hasReplies)
+		if err != nil {
+			return err
+		}
+		if hasReplies {
+			comment.Deleted = true
+			comment.Text = ""
+			comment.Email = ""
+			err = q.putComment(ctx, comment)
+		} else {
+			err = q.deleteComments(ctx, []string{hex(id)})
+		}
+		if err != nil {
+			return err
+		}
+		return q.appendLedger(ctx, db.LedgerComment, ledger.OpDelete, id, ledger.Hash{})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+// deleteComments deletes the comments with the ids,
+// with the reactions to them and the reports of them
+func (q *Queries) deleteComments(ctx context.Context, ids []string) error {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	in := placeholders(len(ids))
+	for _, table := range []string{"reactions", "reports"} {
+		if _, err := q.db.ExecContext(ctx, "DELETE FROM "+table+" WHERE comment_id IN ("+in+")", args...); err != nil {
+			return err
+		}
+	}
+	_, err := q.db.ExecContext(ctx, "DELETE FROM comments WHERE id IN ("+in+")", args...)
+	return err
+}
+
+// DeleteCommentsByMovieID deletes every comment on a movie, with their reactions
+// and reports, and gives the ids of the comments it deleted
+func (q *Queries) DeleteCommentsByMovieID(ctx context.Context, movieID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	var ids []primitive.ObjectID
+	err := q.inTx(ctx, func(q *Queries) error {
+		var comments []db.Comments
+		if err := q.getDocs(ctx, &comments, "SELECT doc FROM comments WHERE movie_id = ? ORDER BY seq", hex(movieID)); err != nil {
+			return err
+		}
+		if len(comments) == 0 {
+			return nil
+		}
+		hexIDs := make([]string, len(comments))
+		for i, comment := range comments {
+			ids = append(ids, comment.ID)
+			hexIDs[i] = hex(comment.ID)
+			// The comments that were kept for their replies are in the ledger as deleted already
+			if comment.Deleted {
+				continue
+			}
+			if err := q.appendLedger(ctx, db.LedgerComment, ledger.OpDelete, comment.ID, ledger.Hash{}); err != nil {
+				return err
+			}
+		}
+		return q.deleteComments(ctx, hexIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// GetReplies gets a page of the replies to a comment, the oldest first
+func (q *Queries) GetReplies(ctx context.Context, arg db.GetRepliesParams) ([]db.Comments, error) {
+	var replies []db.Comments
+	err := q.getDocs(ctx, &replies, "SELECT doc FROM comments WHERE parent_id = ? ORDER BY id"+limit(arg.Skip, arg.Limit), hex(arg.ParentID))
+	return replies, err
+}
+
+// GetReplySummaries counts the replies to each of the comments and gets
+// the first n of them, the comments without replies are left out
+func (q *Queries) GetReplySummaries(ctx context.Context, parentIDs []primitive.ObjectID, n int64) ([]db.ReplySummary, error) {
+	var summaries []db.ReplySummary
+	err := q.inTx(ctx, func(q *Queries) error {
+		seen := make(map[primitive.ObjectID]bool)
+		for _, parentID := range parentIDs {
+			if seen[parentID] || parentID.IsZero() {
+				continue
+			}
+			seen[parentID] = true
+			var replies []db.Comments
+			if err := q.getDocs(ctx, &replies, "SELECT doc FROM comments WHERE parent_id = ? ORDER BY id", hex(parentID)); err != nil {
+				return err
+			}
+			if len(replies) == 0 {
+				continue
+			}
+			summary := db.ReplySummary{ParentID: parentID, Count: int64(len(replies))}
+			first := replies
+			if n >= 0 && int(n) < len(first) {
+				first = first[:n]
+			}
+			db.Convert(first, &summary.Replies)
+			summaries = append(summaries, summary)
+		}
+		return nil
+	})
+	return summaries, err
+}
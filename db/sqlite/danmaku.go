@@ -0,0 +1,167 @@
+package sqlitedb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"phantom/ledger"
+	"sort"
+	"time"
+)
+
+// AddDanmaku adds a danmaku dated now, and records it in the ledger
+func (q *Queries) AddDanmaku(ctx context.Context, arg db.AddDanmakuParams) (db.Danmaku, error) {
+	danmaku := db.Danmaku{
+		ID:      primitive.NewObjectID(),
+		MovieID: arg.MovieID,
+		Offset:  arg.Offset,
+		Text:    arg.Text,
+		Color:   arg.Color,
+		Mode:    arg.Mode,
+		Name:    arg.Name,
+		Date:    primitive.NewDateTimeFromTime(time.Now()),
+		Flags:   arg.Flags,
+	}
+	err := q.inTx(ctx, func(q *Queries) error {
+		if _, err := q.insert(ctx, "danmaku", hex(danmaku.ID), danmaku, danmakuColumns(danmaku)...); err != nil {
+			return err
+		}
+		return q.appendLedger(ctx, db.LedgerDanmaku, ledger.OpAdd, danmaku.ID, db.DanmakuDigest(danmaku))
+	})
+	if err != nil {
+		return db.Danmaku{}, err
+	}
+	return danmaku, nil
+}
+
+func danmakuColumns(danmaku db.Danmaku) []interface{} {
+	return []interface{}{
+		"movie_id", hex(danmaku.MovieID),
+		"offset_ms", danmaku.Offset,
+		"flagged", boolInt(len(danmaku.Flags) > 0),
+		"pinned", boolInt(danmaku.Pinned),
+	}
+}
+
+// GetDanmaku gets the danmaku of a time window of a movie in the order they show,
+// leaving out the flagged ones. Where a scene has more than PerBucket in a bucket
+// the pinned ones and then the latest posted are kept
+func (q *Queries) GetDanmaku(ctx context.Context, arg db.GetDanmakuParams) ([]db.Danmaku, error) {
+	if arg.Bucket == 0 {
+		return nil, mongo.CommandError{Code: 2, Message: "can't $divide by zero"}
+	}
+	var matched []db.Danmaku
+	err := q.getDocs(ctx, &matched, `
+SELECT doc FROM danmaku WHERE movie_id = ? AND offset_ms >= ? AND offset_ms < ? AND NOT flagged
+ORDER BY pinned DESC, id DESC`, hex(arg.MovieID), arg.From, arg.To)
+	if err != nil {
+		return nil, err
+	}
+
+	var danmaku []db.Danmaku
+	shown := make(map[int64]int64)
+	for _, d := range matched {
+		bucket := floorDiv(d.Offset, arg.Bucket)
+		if shown[bucket] < arg.PerBucket {
+			shown[bucket]++
+			danmaku = append(danmaku, d)
+		}
+	}
+	sort.Slice(danmaku, func(i, j int) bool {
+		if danmaku[i].Offset != danmaku[j].Offset {
+			return danmaku[i].Offset < danmaku[j].Offset
+		}
+		return hex(danmaku[i].ID) < hex(danmaku[j].ID)
+	})
+	return danmaku, nil
+}
+
+// floorDiv is a divided by b rounded down, like $floor of $divide
+func floorDiv(a, b int64) int64 {
+	d := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		d--
+	}
+	return d
+}
+
+// GetDanmakuModerationQueue gets a page of the danmaku the content filter
+// flagged, which wait for review, the oldest first
+func (q *Queries) GetDanmakuModerationQueue(ctx context.Context, arg db.GetModerationQueueParams) ([]db.Danmaku, error) {
+	var danmaku []db.Danmaku
+	err := q.getDocs(ctx, &danmaku, "SELECT doc FROM danmaku WHERE flagged ORDER BY id"+limit(arg.Skip, arg.Limit))
+	return danmaku, err
+}
+
+// ApproveDanmaku shows a flagged danmaku by clearing its flags, and returns it.
+// It returns mongo.ErrNoDocuments when there is no such danmaku
+func (q *Queries) ApproveDanmaku(ctx context.Context, id primitive.ObjectID) (db.Danmaku, error) {
+	var danmaku db.Danmaku
+	err := q.inTx(ctx, func(q *Queries) error {
+		if err := q.getDoc(ctx, &danmaku, "SELECT doc FROM danmaku WHERE id = ?", hex(id)); err != nil {
+			return err
+		}
+		danmaku.Flags = nil
+		_, err := q.update(ctx, "danmaku", hex(id), danmaku, danmakuColumns(danmaku)...)
+		return err
+	})
+	if err != nil {
+		return db.Danmaku{}, err
+	}
+	return danmaku, nil
+}
+
+// RemoveDanmaku deletes a danmaku on behalf of a moderator, records it
+// in the ledger, and returns it
+func (q *Queries) RemoveDanmaku(ctx context.Context, id primitive.ObjectID) (db.Danmaku, error) {
+	var danmaku db.Danmaku
+	err := q.inTx(ctx, func(q *Queries) error {
+		if err := q.getDoc(ctx, &danmaku, "SELECT doc FROM danmaku WHERE id = ?", hex(id)); err != nil {
+			return err
+		}
+		if _, err := q.db.ExecContext(ctx, "DELETE FROM danmaku WHERE id = ?", hex(id)); err != nil {
+			return err
+		}
+		return q.appendLedger(ctx, db.LedgerDanmaku, ledger.OpDelete, id, ledger.Hash{})
+	})
+	if err != nil {
+		return db.Danmaku{}, err
+	}
+	return danmaku, nil
+}
+
+// PinDanmakuTx pins a danmaku and takes the coins for it together.
+// A danmaku is pinned once, the flagged ones can't be
+func (store *Store) PinDanmakuTx(ctx context.Context, arg db.PinDanmakuTxParams) (db.PinDanmakuTxResult, error) {
+	var result db.PinDanmakuTxResult
+	err := execTx(ctx, store.db, func(q *Queries) error {
+		var danmaku db.Danmaku
+		err := q.getDoc(ctx, &danmaku, "SELECT doc FROM danmaku WHERE id = ? AND NOT flagged", hex(arg.DanmakuID))
+		if err != nil {
+			return err
+		}
+		if danmaku.Pinned {
+			return db.ErrDanmakuPinned
+		}
+
+		transfer, err := q.transferCoins(ctx, db.TransferCoinsParams{
+			Key:    db.CoinPinDanmaku + ":" + arg.DanmakuID.Hex(),
+			Kind:   db.CoinPinDanmaku,
+			From:   db.UserCoinAccount(arg.Name),
+			To:     db.CoinAccountSpent,
+			Amount: arg.Cost,
+		})
+		if err != nil {
+			return err
+		}
+		result.Transaction = transfer.Transaction
+		danmaku.Pinned = true
+		if _, err = q.update(ctx, "danmaku", hex(danmaku.ID), danmaku, danmakuColumns(danmaku)...); err != nil {
+			return err
+		}
+		result.Danmaku = danmaku
+		return nil
+	})
+	return result, err
+}
@@ -0,0 +1,195 @@
+package sqlitedb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"math"
+	db "phantom/db/mongo"
+	"sort"
+	"time"
+)
+
+// GetHistory gets a page of the history of a user, the latest first
+func (q *Queries) GetHistory(ctx context.Context, arg db.GetHistoryParams) ([]db.HistoryEntry, error) {
+	var entries []db.HistoryEntry
+	err := q.getDocs(ctx, &entries, "SELECT doc FROM history WHERE name = ? ORDER BY watched_at DESC, id DESC"+limit(arg.Skip, arg.Limit),
+		arg.Name)
+	return entries, err
+}
+
+// DeleteHistoryEntry removes an entry from the history of a user,
+// it returns mongo.ErrNoDocuments when the user has no such entry
+func (q *Queries) DeleteHistoryEntry(ctx context.Context, name string, id primitive.ObjectID) error {
+	res, err := q.db.ExecContext(ctx, "DELETE FROM history WHERE id = ? AND name = ?", hex(id), name)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return err
+}
+
+// GetUserStats works out the stats of a user from their history, the way
+// db.Queries.GetUserStats does. Days and months are in UTC
+func (q *Queries) GetUserStats(ctx context.Context, arg db.GetUserStatsParams) (db.UserStats, error) {
+	if arg.Top <= 0 {
+		return db.UserStats{}, errAggregateLimit
+	}
+	where := "name = ?"
+	args := []interface{}{arg.Name}
+	if !arg.From.IsZero() {
+		where += " AND watched_at >= ?"
+		args = append(args, int64(primitive.NewDateTimeFromTime(arg.From)))
+	}
+	if !arg.To.IsZero() {
+		where += " AND watched_at < ?"
+		args = append(args, int64(primitive.NewDateTimeFromTime(arg.To)))
+	}
+
+	var entries []db.HistoryEntry
+	movies := make(map[primitive.ObjectID]db.Movies)
+	err := q.inTx(ctx, func(q *Queries) error {
+		if err := q.getDocs(ctx, &entries, "SELECT doc FROM history WHERE "+where+" ORDER BY rowid", args...); err != nil {
+			return err
+		}
+		ids := make([]primitive.ObjectID, len(entries))
+		for i, entry := range entries {
+			ids[i] = entry.SeriesID
+			if ids[i].IsZero() {
+				ids[i] = entry.MediaID
+			}
+		}
+		found, err := q.GetMoviesByIDs(ctx, ids)
+		for _, movie := range found {
+			movies[movie.Id] = movie
+		}
+		return err
+	})
+	if err != nil {
+		return db.UserStats{}, err
+	}
+
+	stats := db.UserStats{Months: []db.MonthStats{}}
+	var minutes float64
+	months := make(map[string]*db.MonthStats)
+	monthMinutes := make(map[string]float64)
+	watchedDays := make(map[string]bool)
+	genres, directors, cast := make(map[string]int64), make(map[string]int64), make(map[string]int64)
+	for _, entry := range entries {
+		watchedAt := entry.WatchedAt.Time().UTC()
+		movieID := entry.SeriesID
+		isEpisode := !movieID.IsZero()
+		if !isEpisode {
+			movieID = entry.MediaID
+		}
+		movie := movies[movieID]
+		watched := float64(entry.Duration) / 60
+		if !isEpisode && movie.Runtime != 0 {
+			watched = float64(movie.Runtime)
+		}
+
+		month := watchedAt.Format("2006-01")
+		if months[month] == nil {
+			months[month] = &db.MonthStats{Month: month}
+		}
+		if isEpisode {
+			stats.Episodes++
+			months[month].Episodes++
+		} else {
+			stats.Movies++
+			months[month].Movies++
+		}
+		minutes += watched
+		monthMinutes[month] += watched
+		watchedDays[watchedAt.Format("2006-01-02")] = true
+
+		for _, genre := range movie.Genres {
+			genres[genre]++
+		}
+		for _, director := range movie.Directors {
+			directors[director]++
+		}
+		for _, name := range movie.Cast {
+			cast[name]++
+		}
+	}
+
+	stats.Hours = minutesToHours(minutes)
+	stats.TopGenres = topCounts(genres, arg.Top)
+	stats.TopDirectors = topCounts(directors, arg.Top)
+	stats.TopCast = topCounts(cast, arg.Top)
+	for _, month := range sortedKeys(months) {
+		months[month].Hours = minutesToHours(monthMinutes[month])
+		stats.Months = append(stats.Months, *months[month])
+	}
+	days := make([]time.Time, 0, len(watchedDays))
+	for day := range watchedDays {
+		t, err := time.Parse("2006-01-02", day)
+		if err != nil {
+			return db.UserStats{}, err
+		}
+		days = append(days, t)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	stats.Streak = watchStreak(days, time.Now().UTC())
+	return stats, nil
+}
+
+// topCounts are the top n of the counts, the most counted first and then by name
+func topCounts(counts map[string]int64, n int64) []db.StatsCount {
+	top := make([]db.StatsCount, 0, len(counts))
+	for name, count := range counts {
+		top = append(top, db.StatsCount{Name: name, Count: count})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Name < top[j].Name
+	})
+	if int64(len(top)) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+func sortedKeys(months map[string]*db.MonthStats) []string {
+	keys := make([]string, 0, len(months))
+	for key := range months {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// minutesToHours rounds to a tenth of an hour
+func minutesToHours(minutes float64) float64 {
+	return math.Round(minutes/6) / 10
+}
+
+// watchStreak works out the streaks from the days something was watched,
+// in order and at midnight UTC
+func watchStreak(days []time.Time, now time.Time) db.WatchStreak {
+	var streak db.WatchStreak
+	var run int64
+	for i, day := range days {
+		if i > 0 && day.Sub(days[i-1]) == 24*time.Hour {
+			run++
+		} else {
+			run = 1
+		}
+		if run > streak.Longest {
+			streak.Longest = run
+		}
+	}
+	if len(days) > 0 {
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		if since := today.Sub(days[len(days)-1]); since == 0 || since == 24*time.Hour {
+			streak.Current = run
+		}
+	}
+	return streak
+}
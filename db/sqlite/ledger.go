@@ -0,0 +1,195 @@
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"phantom/ledger"
+)
+
+// appendLedger appends an entry to the ledger, and checkpoints the ledger
+// when an interval of entries has followed the latest checkpoint. It is always
+// part of the transaction of the write it records, which keeps the entries in
+// a chain
+func (q *Queries) appendLedger(ctx context.Context, kind, op string, refID primitive.ObjectID, digest ledger.Hash) error {
+	var prev *ledger.Entry
+	entries, err := q.getLedgerEntries(ctx, "ORDER BY seq DESC LIMIT 1")
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		prev = &entries[0]
+	}
+	entry := ledger.Append(prev, kind, op, refID.Hex(), digest)
+	_, err = q.db.ExecContext(ctx, "INSERT INTO ledger (seq, kind, op, ref, digest, prev, hash) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		entry.Seq, entry.Kind, entry.Op, entry.Ref, entry.Digest.String(), entry.Prev.String(), entry.Hash.String())
+	if err != nil {
+		return err
+	}
+	return q.checkpointLedger(ctx, false)
+}
+
+// CheckpointLedger checkpoints the entries that follow the latest checkpoint,
+// the last of which are fewer than an interval, so that each entry so far
+// can be proved
+func (q *Queries) CheckpointLedger(ctx context.Context) error {
+	return q.inTx(ctx, func(q *Queries) error {
+		return q.checkpointLedger(ctx, true)
+	})
+}
+
+// checkpointLedger checkpoints the entries that follow the latest checkpoint,
+// an interval at a time, and the ones left over when tail is true
+func (q *Queries) checkpointLedger(ctx context.Context, tail bool) error {
+	var from, to int64
+	err := q.db.QueryRowContext(ctx, `SELECT COALESCE(MAX("to"), 0), COALESCE((SELECT MAX(seq) + 1 FROM ledger), 0) FROM ledger_checkpoints`).Scan(&from, &to)
+	if err != nil {
+		return err
+	}
+	for ; from < to; from += db.LedgerCheckpointInterval {
+		end := from + db.LedgerCheckpointInterval
+		if end > to {
+			if !tail {
+				return nil
+			}
+			end = to
+		}
+		entries, err := q.getLedgerEntries(ctx, "WHERE seq >= ? AND seq < ? ORDER BY seq", from, end)
+		if err != nil {
+			return err
+		}
+		checkpoint := ledger.NewCheckpoint(entries)
+		_, err = q.db.ExecContext(ctx, `INSERT INTO ledger_checkpoints ("from", "to", root) VALUES (?, ?, ?)`,
+			checkpoint.From, checkpoint.To, checkpoint.Root.String())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getLedgerEntries gets the entries the rest of the query selects
+func (q *Queries) getLedgerEntries(ctx context.Context, rest string, args ...interface{}) ([]ledger.Entry, error) {
+	rows, err := q.db.QueryContext(ctx, "SELECT seq, kind, op, ref, digest, prev, hash FROM ledger "+rest, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []ledger.Entry
+	for rows.Next() {
+		var entry ledger.Entry
+		var digest, prev, hash string
+		if err = rows.Scan(&entry.Seq, &entry.Kind, &entry.Op, &entry.Ref, &digest, &prev, &hash); err != nil {
+			return nil, err
+		}
+		if entry.Digest, err = ledger.ParseHash(digest); err != nil {
+			return nil, err
+		}
+		if entry.Prev, err = ledger.ParseHash(prev); err != nil {
+			return nil, err
+		}
+		if entry.Hash, err = ledger.ParseHash(hash); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// getLedgerCheckpoints gets the checkpoints the rest of the query selects
+func (q *Queries) getLedgerCheckpoints(ctx context.Context, rest string, args ...interface{}) ([]ledger.Checkpoint, error) {
+	rows, err := q.db.QueryContext(ctx, `SELECT "from", "to", root FROM ledger_checkpoints `+rest, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checkpoints []ledger.Checkpoint
+	for rows.Next() {
+		var checkpoint ledger.Checkpoint
+		var root string
+		if err = rows.Scan(&checkpoint.From, &checkpoint.To, &root); err != nil {
+			return nil, err
+		}
+		if checkpoint.Root, err = ledger.ParseHash(root); err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	return checkpoints, rows.Err()
+}
+
+// GetLedgerProof proves the latest entry of a comment or a danmaku
+// is in the ledger. It is db.ErrNotCheckpointed until the entry is checkpointed
+func (q *Queries) GetLedgerProof(ctx context.Context, refID primitive.ObjectID) (ledger.Proof, error) {
+	var proof ledger.Proof
+	err := q.inTx(ctx, func(q *Queries) error {
+		var seq int64
+		err := q.db.QueryRowContext(ctx, "SELECT seq FROM ledger WHERE ref = ? ORDER BY seq DESC LIMIT 1", refID.Hex()).Scan(&seq)
+		if err == sql.ErrNoRows {
+			return mongo.ErrNoDocuments
+		}
+		if err != nil {
+			return err
+		}
+		checkpoints, err := q.getLedgerCheckpoints(ctx, `WHERE "from" <= ? AND ? < "to"`, seq, seq)
+		if err != nil {
+			return err
+		}
+		if len(checkpoints) == 0 {
+			return db.ErrNotCheckpointed
+		}
+		checkpoint := checkpoints[0]
+		entries, err := q.getLedgerEntries(ctx, "WHERE seq >= ? AND seq < ? ORDER BY seq", checkpoint.From, checkpoint.To)
+		if err != nil {
+			return err
+		}
+		if proof, err = ledger.NewProof(entries, seq); err != nil {
+			return err
+		}
+		proof.Checkpoint = checkpoint
+		return nil
+	})
+	if err != nil {
+		return ledger.Proof{}, err
+	}
+	return proof, nil
+}
+
+// VerifyLedger checks the whole ledger against its checkpoints and against
+// the comments and danmaku it records, see ledger.Verify
+func (q *Queries) VerifyLedger(ctx context.Context) ([]ledger.Problem, error) {
+	var problems []ledger.Problem
+	err := q.inTx(ctx, func(q *Queries) error {
+		entries, err := q.getLedgerEntries(ctx, "ORDER BY seq")
+		if err != nil {
+			return err
+		}
+		checkpoints, err := q.getLedgerCheckpoints(ctx, `ORDER BY "from"`)
+		if err != nil {
+			return err
+		}
+		// A deleted comment that is kept for its replies counts as gone
+		var comments []db.Comments
+		if err = q.getDocs(ctx, &comments, "SELECT doc FROM comments WHERE NOT deleted"); err != nil {
+			return err
+		}
+		var danmaku []db.Danmaku
+		if err = q.getDocs(ctx, &danmaku, "SELECT doc FROM danmaku"); err != nil {
+			return err
+		}
+		current := make(map[string]ledger.Hash, len(comments)+len(danmaku))
+		for _, comment := range comments {
+			current[comment.ID.Hex()] = db.CommentDigest(comment)
+		}
+		for _, d := range danmaku {
+			current[d.ID.Hex()] = db.DanmakuDigest(d)
+		}
+		problems = ledger.Verify(entries, checkpoints, current)
+		return nil
+	})
+	return problems, err
+}
@@ -0,0 +1,111 @@
+package sqlitedb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"sort"
+	"time"
+)
+
+// AddListEntry adds a movie to a list of a user,
+// a movie that is already in the list keeps its place
+func (q *Queries) AddListEntry(ctx context.Context, arg db.ListEntryParams) (*mongo.UpdateResult, error) {
+	now := time.Now()
+	entry := db.ListEntry{
+		ID:       primitive.NewObjectID(),
+		Name:     arg.Name,
+		List:     arg.List,
+		MovieID:  arg.MovieID,
+		Position: now.UnixNano(),
+		AddedAt:  primitive.NewDateTimeFromTime(now),
+	}
+	_, err := q.insert(ctx, "lists", hex(entry.ID), entry, listColumns(entry)...)
+	if uniqueFailed(err, "lists.name") {
+		return &mongo.UpdateResult{MatchedCount: 1}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &mongo.UpdateResult{UpsertedCount: 1, UpsertedID: entry.ID}, nil
+}
+
+func listColumns(entry db.ListEntry) []interface{} {
+	return []interface{}{
+		"name", entry.Name,
+		"list", entry.List,
+		"movie_id", hex(entry.MovieID),
+		"position", entry.Position,
+	}
+}
+
+func (q *Queries) DeleteListEntry(ctx context.Context, arg db.ListEntryParams) (int64, error) {
+	res, err := q.db.ExecContext(ctx, "DELETE FROM lists WHERE name = ? AND list = ? AND movie_id = ?",
+		arg.Name, arg.List, hex(arg.MovieID))
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		return 0, mongo.ErrNoDocuments
+	}
+	return n, err
+}
+
+// GetListEntries gets a page of the entries of a list of a user, in order
+func (q *Queries) GetListEntries(ctx context.Context, arg db.GetListParams) ([]db.ListEntry, error) {
+	var entries []db.ListEntry
+	err := q.getDocs(ctx, &entries, "SELECT doc FROM lists WHERE name = ? AND list = ? ORDER BY position DESC, id DESC"+limit(arg.Skip, arg.Limit),
+		arg.Name, arg.List)
+	return entries, err
+}
+
+// ReorderList puts some movies of a list in a new order. They swap their places
+// among themselves, so the other movies of the list stay where they are.
+// It returns mongo.ErrNoDocuments when a movie is not in the list
+func (q *Queries) ReorderList(ctx context.Context, arg db.ReorderListParams) error {
+	return q.inTx(ctx, func(q *Queries) error {
+		entries := make([]db.ListEntry, 0, len(arg.MovieIDs))
+		seen := make(map[primitive.ObjectID]bool, len(arg.MovieIDs))
+		for _, id := range arg.MovieIDs {
+			if seen[id] {
+				// A movie is in a list once, so it can't match twice
+				return mongo.ErrNoDocuments
+			}
+			seen[id] = true
+			var entry db.ListEntry
+			err := q.getDoc(ctx, &entry, "SELECT doc FROM lists WHERE name = ? AND list = ? AND movie_id = ?",
+				arg.Name, arg.List, hex(id))
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+		}
+		if len(entries) == 0 {
+			return mongo.ErrEmptySlice
+		}
+
+		positions := make([]int64, len(entries))
+		for i, entry := range entries {
+			positions[i] = entry.Position
+		}
+		sort.Slice(positions, func(i, j int) bool { return positions[i] > positions[j] })
+		for i, entry := range entries {
+			entry.Position = positions[i]
+			if _, err := q.update(ctx, "lists", hex(entry.ID), entry, listColumns(entry)...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetListsByMovie gets the names of the lists of a user that have the movie
+func (q *Queries) GetListsByMovie(ctx context.Context, name string, movieID primitive.ObjectID) ([]string, error) {
+	lists, err := q.getStrings(ctx, "SELECT DISTINCT list FROM lists WHERE name = ? AND movie_id = ? ORDER BY rowid", name, hex(movieID))
+	if lists == nil && err == nil {
+		lists = []string{}
+	}
+	return lists, err
+}
@@ -0,0 +1,103 @@
+package sqlitedb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	db "phantom/db/mongo"
+	"time"
+)
+
+// ReportComment adds a report of a user to a comment and counts it on the comment,
+// hiding the comment when it has enough reports. It returns the comment after
+// the report, or a duplicate key error when the user had already reported it
+func (q *Queries) ReportComment(ctx context.Context, arg db.ReportCommentParams) (db.Comments, error) {
+	var comment db.Comments
+	err := q.inTx(ctx, func(q *Queries) error {
+		report := db.CommentReport{
+			ID:        primitive.NewObjectID(),
+			CommentID: arg.CommentID,
+			Name:      arg.Name,
+			Reason:    arg.Reason,
+			Note:      arg.Note,
+			CreatedAt: primitive.NewDateTimeFromTime(time.Now()),
+		}
+		_, err := q.insert(ctx, "reports", hex(report.ID), report, "comment_id", hex(arg.CommentID), "name", arg.Name)
+		if err != nil {
+			return duplicateKey(err, "reports", "comment_id_1_name_1")
+		}
+
+		if comment, err = q.GetComment(ctx, arg.CommentID); err != nil {
+			return err
+		}
+		comment.Reports = incCount(comment.Reports, arg.Reason, 1)
+		comment.ReportCount++
+		comment.Hidden = comment.Hidden || comment.ReportCount >= arg.HideThreshold
+		return q.putComment(ctx, comment)
+	})
+	if err != nil {
+		return db.Comments{}, err
+	}
+	return comment, nil
+}
+
+// GetModerationQueue gets a page of the reported or flagged comments waiting
+// for review, the hidden ones first, then the most reported
+func (q *Queries) GetModerationQueue(ctx context.Context, arg db.GetModerationQueueParams) ([]db.Comments, error) {
+	var comments []db.Comments
+	err := q.getDocs(ctx, &comments, `
+SELECT doc FROM comments WHERE (report_count > 0 OR flagged) AND NOT deleted
+ORDER BY hidden DESC, report_count DESC, id`+limit(arg.Skip, arg.Limit))
+	return comments, err
+}
+
+// ApproveComment shows a reported comment again and clears its reports
+// and flags, so that users can report it again. It returns mongo.ErrNoDocuments
+// when there is no such comment
+func (q *Queries) ApproveComment(ctx context.Context, id primitive.ObjectID) error {
+	return q.inTx(ctx, func(q *Queries) error {
+		comment, err := q.GetComment(ctx, id)
+		if err != nil {
+			return err
+		}
+		comment.Hidden = false
+		comment.Reports = nil
+		comment.ReportCount = 0
+		comment.Flags = nil
+		if err = q.putComment(ctx, comment); err != nil {
+			return err
+		}
+		_, err = q.db.ExecContext(ctx, "DELETE FROM reports WHERE comment_id = ?", hex(id))
+		return err
+	})
+}
+
+// GetCommentReporters gets the names of the users who reported a comment,
+// until a moderator reviews it
+func (q *Queries) GetCommentReporters(ctx context.Context, commentID primitive.ObjectID) ([]string, error) {
+	names, err := q.getStrings(ctx, "SELECT name FROM reports WHERE comment_id = ? ORDER BY id", hex(commentID))
+	if names == nil && err == nil {
+		names = []string{}
+	}
+	return names, err
+}
+
+func (q *Queries) AddModerationLog(ctx context.Context, arg db.AddModerationLogParams) error {
+	entry := db.ModerationLogEntry{
+		ID:        primitive.NewObjectID(),
+		Moderator: arg.Moderator,
+		Action:    arg.Action,
+		CommentID: arg.CommentID,
+		DanmakuID: arg.DanmakuID,
+		Author:    arg.Author,
+		CreatedAt: primitive.NewDateTimeFromTime(time.Now()),
+	}
+	_, err := q.insert(ctx, "moderation_log", hex(entry.ID), entry, "created_at", int64(entry.CreatedAt))
+	return err
+}
+
+// GetModerationLog gets a page of the moderation log, the latest first
+func (q *Queries) GetModerationLog(ctx context.Context, arg db.GetModerationLogParams) ([]db.ModerationLogEntry, error) {
+	var entries []db.ModerationLogEntry
+	err := q.getDocs(ctx, &entries, "SELECT doc FROM moderation_log ORDER BY created_at DESC, id DESC"+limit(arg.Skip, arg.Limit))
+	return entries, err
+}
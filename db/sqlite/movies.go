@@ -0,0 +1,385 @@
+package sqlitedb
+
+import (
+	"bytes"
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"phantom/util"
+	"time"
+)
+
+// AddMovie can add a movie information, it needs a title like the validator
+// of 'movies', and the sort title is made from the title when it is not given
+func (q *Queries) AddMovie(ctx context.Context, arg db.AddMovieParams) (primitive.ObjectID, error) {
+	if arg.Title == "" {
+		return primitive.ObjectID{}, errValidation()
+	}
+	if arg.SortTitle == "" {
+		arg.SortTitle = util.SortTitle(arg.Title)
+	}
+	var movie db.Movies
+	db.Convert(arg, &movie)
+	movie.Id = primitive.NewObjectID()
+	err := q.inTx(ctx, func(q *Queries) error {
+		return q.writeMovie(ctx, movie, true)
+	})
+	if err != nil {
+		return primitive.ObjectID{}, err
+	}
+	return movie.Id, nil
+}
+
+// AddMovies adds the movies in order and stops at the first one
+// it can't add, keeping the ones before it like an ordered insert does
+func (q *Queries) AddMovies(ctx context.Context, arg []db.AddMovieParams) ([]interface{}, error) {
+	if len(arg) == 0 {
+		return nil, mongo.ErrEmptySlice
+	}
+	ids := make([]interface{}, 0, len(arg))
+	for i, v := range arg {
+		id, err := q.AddMovie(ctx, v)
+		if err != nil {
+			if _, ok := err.(mongo.WriteException); !ok {
+				return nil, err
+			}
+			return nil, mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{{
+				WriteError: mongo.WriteError{Index: i, Code: codeValidationFailed, Message: "Document failed validation"},
+			}}}
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// UpsertMovies adds each movie or updates the one it matches by its key,
+// see db.Queries.UpsertMovies. It writes the movies unordered like it does,
+// and the ones it can't write are in the mongo.BulkWriteException
+func (q *Queries) UpsertMovies(ctx context.Context, movies []db.Movies) (*mongo.BulkWriteResult, error) {
+	if len(movies) == 0 {
+		return nil, mongo.ErrEmptySlice
+	}
+	var res *mongo.BulkWriteResult
+	var writeErrors []mongo.BulkWriteError
+	err := q.inTx(ctx, func(q *Queries) error {
+		res = &mongo.BulkWriteResult{UpsertedIDs: make(map[int64]interface{})}
+		writeErrors = nil
+		for i, movie := range movies {
+			old, err := q.getMovieByKey(ctx, movie.Key())
+			if err == nil {
+				merged := db.MergeMovie(old, movie)
+				res.MatchedCount++
+				if sameDocument(merged, old) {
+					continue
+				}
+				res.ModifiedCount++
+				if err = q.writeMovie(ctx, merged, false); err != nil {
+					return err
+				}
+				continue
+			}
+			if err != mongo.ErrNoDocuments {
+				return err
+			}
+
+			merged := db.MergeMovie(db.Movies{}, movie)
+			merged.Id = movie.Id
+			merged.NumMflixComments = movie.NumMflixComments
+			if merged.Id.IsZero() {
+				merged.Id = primitive.NewObjectID()
+			}
+			if merged.Title == "" {
+				writeErrors = append(writeErrors, bulkWriteError(i, errValidation()))
+				continue
+			}
+			err = duplicateKey(q.writeMovie(ctx, merged, true), "movies", "_id_")
+			if _, ok := err.(mongo.WriteException); ok {
+				writeErrors = append(writeErrors, bulkWriteError(i, err))
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			res.UpsertedCount++
+			res.UpsertedIDs[int64(i)] = merged.Id
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(writeErrors) > 0 {
+		return res, mongo.BulkWriteException{WriteErrors: writeErrors}
+	}
+	return res, nil
+}
+
+// getMovieByKey gets the first movie with the key
+func (q *Queries) getMovieByKey(ctx context.Context, key db.MovieKey) (db.Movies, error) {
+	var movie db.Movies
+	var err error
+	if key.ImdbID != 0 {
+		err = q.getDoc(ctx, &movie, "SELECT doc FROM movies WHERE imdb_id = ? ORDER BY seq LIMIT 1", key.ImdbID)
+	} else {
+		err = q.getDoc(ctx, &movie, "SELECT doc FROM movies WHERE title = ? AND year = ? ORDER BY seq LIMIT 1", key.Title, key.Year)
+	}
+	return movie, err
+}
+
+// writeMovie writes the movie to its table and to the tables
+// of its genres, credits and text, adding it when insert is true
+func (q *Queries) writeMovie(ctx context.Context, movie db.Movies, insert bool) error {
+	columns := []interface{}{
+		"sort_key", sortKey(movie),
+		"released", nullTime(movie.Released),
+		"runtime", movie.Runtime,
+		"year", movie.Year,
+		"imdb_id", movie.Imdb.Id,
+		"title", movie.Title,
+		"imdb_rating", movie.Imdb.Rating,
+		"community_rating", movie.Community.Rating,
+		"community_count", movie.Community.Count,
+	}
+	id := hex(movie.Id)
+	if insert {
+		if _, err := q.insert(ctx, "movies", id, movie, columns...); err != nil {
+			return err
+		}
+	} else {
+		found, err := q.update(ctx, "movies", id, movie, columns...)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return mongo.ErrNoDocuments
+		}
+		if err = q.deleteMovieIndexes(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	for _, genre := range movie.Genres {
+		if _, err := q.db.ExecContext(ctx, "INSERT INTO movie_genres (movie_id, genre) VALUES (?, ?)", id, genre); err != nil {
+			return err
+		}
+	}
+	for _, credit := range movie.Credits {
+		if _, err := q.db.ExecContext(ctx, "INSERT INTO movie_credits (movie_id, person_id) VALUES (?, ?)", id, hex(credit.PersonID)); err != nil {
+			return err
+		}
+	}
+	_, err := q.db.ExecContext(ctx, `
+INSERT INTO movies_fts (rowid, title, original_title, titles, cast_names, genres, fullplot)
+SELECT seq, ?, ?, ?, ?, ?, ? FROM movies WHERE id = ?`, append(movieText(movie), id)...)
+	return err
+}
+
+// deleteMovieIndexes deletes the genres, credits and text of a movie
+func (q *Queries) deleteMovieIndexes(ctx context.Context, id string) error {
+	for _, query := range []string{
+		"DELETE FROM movie_genres WHERE movie_id = ?",
+		"DELETE FROM movie_credits WHERE movie_id = ?",
+		"DELETE FROM movies_fts WHERE rowid = (SELECT seq FROM movies WHERE id = ?)",
+	} {
+		if _, err := q.db.ExecContext(ctx, query, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortKey is the sort title of the movie, or the title for movies without one
+func sortKey(movie db.Movies) string {
+	if movie.SortTitle != "" {
+		return movie.SortTitle
+	}
+	return movie.Title
+}
+
+// GetMovieByID can get the movie information by movie id
+func (q *Queries) GetMovieByID(ctx context.Context, id primitive.ObjectID) (db.Movies, error) {
+	var movie db.Movies
+	err := q.getDoc(ctx, &movie, "SELECT doc FROM movies WHERE id = ?", hex(id))
+	return movie, err
+}
+
+// GetAllMovies gets every movie in the order they were added
+func (q *Queries) GetAllMovies(ctx context.Context) ([]db.Movies, error) {
+	var movies []db.Movies
+	err := q.getDocs(ctx, &movies, "SELECT doc FROM movies ORDER BY seq")
+	return movies, err
+}
+
+// GetMoviesByIDs gets the movies with the given ids, in no particular order
+func (q *Queries) GetMoviesByIDs(ctx context.Context, ids []primitive.ObjectID) ([]db.Movies, error) {
+	in, args := hexes(ids)
+	var movies []db.Movies
+	err := q.getDocs(ctx, &movies, "SELECT doc FROM movies WHERE id IN ("+in+") ORDER BY seq", args...)
+	return movies, err
+}
+
+// SearchForMovies finds the movies with the words of the text in their titles,
+// plots, cast or genres, the best matches first. The words are stemmed
+// like MongoDB stems them, but the matches are ranked by BM25
+func (q *Queries) SearchForMovies(ctx context.Context, arg db.SearchForMoviesParams) ([]db.Movies, error) {
+	if err := checkAggregatePage(arg.Skip, arg.Limit); err != nil {
+		return nil, err
+	}
+	match := parseTextQuery(arg.Text).match()
+	if match == "" {
+		return nil, nil
+	}
+	var movies []db.Movies
+	err := q.getDocs(ctx, &movies, `
+SELECT movies.doc FROM movies_fts JOIN movies ON movies.seq = movies_fts.rowid
+WHERE movies_fts MATCH ?
+ORDER BY bm25(movies_fts), movies.seq`+limit(arg.Skip, arg.Limit), match)
+	return projectMovies(movies), err
+}
+
+// GetMoviesByGenres Get the movie information of the past year by movie genres,
+// sorted the ways db.Queries.GetMoviesByGenres sorts them
+func (q *Queries) GetMoviesByGenres(ctx context.Context, arg db.GetMoviesParams) ([]db.Movies, error) {
+	if err := checkAggregatePage(arg.Skip, arg.Limit); err != nil {
+		return nil, err
+	}
+	orderBy := "runtime DESC"
+	switch arg.SortOptions {
+	case "sort_time":
+		orderBy = "released DESC"
+	case "sort_rating":
+		orderBy = "imdb_rating DESC"
+	case "sort_community":
+		orderBy = "community_rating DESC, community_count DESC"
+	case "sort_title":
+		orderBy = "sort_key"
+	}
+	since := primitive.NewDateTimeFromTime(time.Now().AddDate(-1, 0, 0))
+	var movies []db.Movies
+	err := q.getDocs(ctx, &movies, `
+SELECT doc FROM movies
+WHERE id IN (SELECT movie_id FROM movie_genres WHERE genre = ?) AND released >= ?
+ORDER BY `+orderBy+`, seq`+limit(arg.Skip, arg.Limit), arg.Genres, int64(since))
+	return projectMovies(movies), err
+}
+
+func (q *Queries) GetTheMostViewedMovies(ctx context.Context, arg db.GetMoviesParams) ([]db.Movies, error) {
+	return q.getSortedMovies(ctx, arg, "runtime DESC")
+}
+
+// GetTheLatestReleasedMovies is to get the latest released movies
+func (q *Queries) GetTheLatestReleasedMovies(ctx context.Context, arg db.GetMoviesParams) ([]db.Movies, error) {
+	return q.getSortedMovies(ctx, arg, "released DESC")
+}
+
+func (q *Queries) getSortedMovies(ctx context.Context, arg db.GetMoviesParams, orderBy string) ([]db.Movies, error) {
+	if err := checkAggregatePage(arg.Skip, arg.Limit); err != nil {
+		return nil, err
+	}
+	var movies []db.Movies
+	err := q.getDocs(ctx, &movies, "SELECT doc FROM movies ORDER BY "+orderBy+", seq"+limit(arg.Skip, arg.Limit))
+	return projectMovies(movies), err
+}
+
+// projectMovies leaves only the fields a list of movies shows
+func projectMovies(movies []db.Movies) []db.Movies {
+	for i, movie := range movies {
+		movies[i] = db.Movies{
+			Id:            movie.Id,
+			Plot:          movie.Plot,
+			Genres:        movie.Genres,
+			Runtime:       movie.Runtime,
+			Cast:          movie.Cast,
+			Poster:        movie.Poster,
+			Title:         movie.Title,
+			OriginalTitle: movie.OriginalTitle,
+			SortTitle:     movie.SortTitle,
+			Titles:        movie.Titles,
+			Released:      movie.Released,
+			Year:          movie.Year,
+			Imdb:          movie.Imdb,
+			Community:     movie.Community,
+			Countries:     movie.Countries,
+		}
+	}
+	return movies
+}
+
+// ReplaceMovieInfoByID replaces the information of the movie but its id,
+// community rating and number of comments, it returns mongo.ErrNoDocuments
+// when nothing changed
+func (q *Queries) ReplaceMovieInfoByID(ctx context.Context, id primitive.ObjectID, movie db.Movies) (*mongo.UpdateResult, error) {
+	if movie.SortTitle == "" {
+		movie.SortTitle = util.SortTitle(movie.Title)
+	}
+	err := q.inTx(ctx, func(q *Queries) error {
+		old, err := q.GetMovieByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		var replaced db.Movies
+		db.Convert(movie, &replaced)
+		replaced.Id = id
+		replaced.Community = old.Community
+		replaced.NumMflixComments = old.NumMflixComments
+		if sameDocument(replaced, old) {
+			return mongo.ErrNoDocuments
+		}
+		return q.writeMovie(ctx, replaced, false)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil
+}
+
+func sameDocument(a, b interface{}) bool {
+	dataA, errA := bson.Marshal(a)
+	dataB, errB := bson.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(dataA, dataB)
+}
+
+// IncrementMovieComments adds n to the number of comments on a movie
+func (q *Queries) IncrementMovieComments(ctx context.Context, id primitive.ObjectID, n int64) error {
+	return q.updateMovie(ctx, id, func(movie *db.Movies) {
+		movie.NumMflixComments += n
+	})
+}
+
+// updateMovie changes the movie with the id, which only changes its table
+func (q *Queries) updateMovie(ctx context.Context, id primitive.ObjectID, set func(movie *db.Movies)) error {
+	return q.inTx(ctx, func(q *Queries) error {
+		movie, err := q.GetMovieByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		set(&movie)
+		_, err = q.update(ctx, "movies", hex(id), movie,
+			"community_rating", movie.Community.Rating,
+			"community_count", movie.Community.Count)
+		return err
+	})
+}
+
+func (q *Queries) DeleteMovieByID(ctx context.Context, id primitive.ObjectID) (int64, error) {
+	err := q.inTx(ctx, func(q *Queries) error {
+		if err := q.deleteMovieIndexes(ctx, hex(id)); err != nil {
+			return err
+		}
+		res, err := q.db.ExecContext(ctx, "DELETE FROM movies WHERE id = ?", hex(id))
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err == nil && n == 0 {
+			return mongo.ErrNoDocuments
+		}
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
@@ -0,0 +1,141 @@
+package sqlitedb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"strings"
+)
+
+func (q *Queries) AddPerson(ctx context.Context, arg db.AddPersonParams) (primitive.ObjectID, error) {
+	var person db.Person
+	db.Convert(arg, &person)
+	person.ID = primitive.NewObjectID()
+	if _, err := q.insert(ctx, "people", hex(person.ID), person, personColumns(person)...); err != nil {
+		return primitive.ObjectID{}, err
+	}
+	return person.ID, nil
+}
+
+// personColumns are the columns of 'people', where search has the name
+// and the aliases of the person in lower case, one a line
+func personColumns(person db.Person) []interface{} {
+	names := append([]string{person.Name}, person.Aliases...)
+	return []interface{}{
+		"name", person.Name,
+		"search", strings.ToLower(strings.Join(names, "\n")),
+	}
+}
+
+func (q *Queries) GetPersonByID(ctx context.Context, id primitive.ObjectID) (db.Person, error) {
+	var person db.Person
+	err := q.getDoc(ctx, &person, "SELECT doc FROM people WHERE id = ?", hex(id))
+	return person, err
+}
+
+// GetPeopleByIDs gets the people with the given ids, in no particular order
+func (q *Queries) GetPeopleByIDs(ctx context.Context, ids []primitive.ObjectID) ([]db.Person, error) {
+	in, args := hexes(ids)
+	var people []db.Person
+	err := q.getDocs(ctx, &people, "SELECT doc FROM people WHERE id IN ("+in+") ORDER BY rowid", args...)
+	return people, err
+}
+
+// SearchPeople finds the people whose name or one of whose aliases
+// contains the text, ignoring case
+func (q *Queries) SearchPeople(ctx context.Context, arg db.SearchPeopleParams) ([]db.Person, error) {
+	var people []db.Person
+	err := q.getDocs(ctx, &people, "SELECT doc FROM people WHERE instr(search, ?) > 0 ORDER BY name, rowid"+limit(arg.Skip, arg.Limit),
+		strings.ToLower(arg.Text))
+	return people, err
+}
+
+// UpdatePerson replaces the details of a person. A new name is also written
+// to the name arrays of the movies the person is credited in
+func (q *Queries) UpdatePerson(ctx context.Context, person db.Person) (*mongo.UpdateResult, error) {
+	res := &mongo.UpdateResult{MatchedCount: 1}
+	err := q.inTx(ctx, func(q *Queries) error {
+		old, err := q.GetPersonByID(ctx, person.ID)
+		if err != nil {
+			return err
+		}
+		var updated db.Person
+		db.Convert(person, &updated)
+		if !sameDocument(updated, old) {
+			res.ModifiedCount = 1
+		}
+		if _, err = q.update(ctx, "people", hex(person.ID), updated, personColumns(updated)...); err != nil {
+			return err
+		}
+		if old.Name == person.Name {
+			return nil
+		}
+
+		var movies []db.Movies
+		err = q.getDocs(ctx, &movies, "SELECT doc FROM movies WHERE id IN (SELECT movie_id FROM movie_credits WHERE person_id = ?) ORDER BY seq",
+			hex(person.ID))
+		if err != nil {
+			return err
+		}
+		for _, movie := range movies {
+			for _, credit := range movie.Credits {
+				if credit.PersonID != person.ID {
+					continue
+				}
+				switch credit.Role {
+				case db.RoleCast:
+					movie.Cast = renamed(movie.Cast, old.Name, person.Name)
+				case db.RoleDirector:
+					movie.Directors = renamed(movie.Directors, old.Name, person.Name)
+				case db.RoleWriter:
+					movie.Writers = renamed(movie.Writers, old.Name, person.Name)
+				}
+			}
+			// The cast is in the text of the movie, which has to change with it
+			if err = q.writeMovie(ctx, movie, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// renamed is a copy of names with from renamed to, or names when it hasn't from
+func renamed(names []string, from, to string) []string {
+	if !containsString(names, from) {
+		return names
+	}
+	copied := make([]string, len(names))
+	for i, name := range names {
+		if name == from {
+			name = to
+		}
+		copied[i] = name
+	}
+	return copied
+}
+
+// GetMoviesByPersonID gets every movie a person is credited in, the newest first
+func (q *Queries) GetMoviesByPersonID(ctx context.Context, id primitive.ObjectID) ([]db.Movies, error) {
+	var movies []db.Movies
+	err := q.getDocs(ctx, &movies, `
+SELECT doc FROM movies WHERE id IN (SELECT movie_id FROM movie_credits WHERE person_id = ?)
+ORDER BY year DESC, id`, hex(id))
+	for i, movie := range movies {
+		movies[i] = db.Movies{
+			Id:            movie.Id,
+			Title:         movie.Title,
+			OriginalTitle: movie.OriginalTitle,
+			Titles:        movie.Titles,
+			Poster:        movie.Poster,
+			Year:          movie.Year,
+			Credits:       movie.Credits,
+		}
+	}
+	return movies, err
+}
@@ -0,0 +1,130 @@
+package sqlitedb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"sort"
+	"time"
+)
+
+// GetProgressBySeriesID gets the progress of a user in the episodes of a series,
+// the most recently watched first
+func (q *Queries) GetProgressBySeriesID(ctx context.Context, name string, seriesID primitive.ObjectID) ([]db.Progress, error) {
+	var progress []db.Progress
+	err := q.getDocs(ctx, &progress, "SELECT doc FROM progress WHERE name = ? AND series_id = ? ORDER BY updated_at DESC, rowid",
+		name, hex(seriesID))
+	return progress, err
+}
+
+// SaveProgress records how far a user got in a movie or an episode,
+// only when the movie or episode becomes watched is it added to the history too
+func (q *Queries) SaveProgress(ctx context.Context, arg db.SaveProgressParams) error {
+	return q.inTx(ctx, func(q *Queries) error {
+		now := primitive.NewDateTimeFromTime(time.Now())
+		var saved db.Progress
+		err := q.getDoc(ctx, &saved, "SELECT doc FROM progress WHERE name = ? AND media_id = ?", arg.Name, hex(arg.MediaID))
+		found := err == nil
+		if err == mongo.ErrNoDocuments {
+			saved = db.Progress{ID: primitive.NewObjectID(), Name: arg.Name, MediaID: arg.MediaID}
+		} else if err != nil {
+			return err
+		}
+		wasWatched := saved.Watched
+		saved.Position = arg.Position
+		saved.Duration = arg.Duration
+		saved.Watched = arg.Watched
+		saved.UpdatedAt = now
+		if !arg.SeriesID.IsZero() {
+			saved.SeriesID = arg.SeriesID
+		}
+
+		var seriesID interface{}
+		if !saved.SeriesID.IsZero() {
+			seriesID = hex(saved.SeriesID)
+		}
+		columns := []interface{}{
+			"name", saved.Name,
+			"media_id", hex(saved.MediaID),
+			"series_id", seriesID,
+			"position", saved.Position,
+			"watched", boolInt(saved.Watched),
+			"updated_at", int64(saved.UpdatedAt),
+		}
+		if found {
+			_, err = q.update(ctx, "progress", hex(saved.ID), saved, columns...)
+		} else {
+			_, err = q.insert(ctx, "progress", hex(saved.ID), saved, columns...)
+		}
+		if err != nil || !arg.Watched || wasWatched {
+			return err
+		}
+
+		entry := db.HistoryEntry{
+			ID:        primitive.NewObjectID(),
+			Name:      arg.Name,
+			MediaID:   arg.MediaID,
+			SeriesID:  arg.SeriesID,
+			Duration:  arg.Duration,
+			WatchedAt: now,
+		}
+		_, err = q.insert(ctx, "history", hex(entry.ID), entry, "name", entry.Name, "watched_at", int64(entry.WatchedAt))
+		return err
+	})
+}
+
+// GetContinueWatching gets a page of the movies and episodes a user started
+// and hasn't watched yet, the most recently watched first. A series only
+// comes once, with the episode the user was in last
+func (q *Queries) GetContinueWatching(ctx context.Context, arg db.GetContinueWatchingParams) ([]db.Progress, error) {
+	if err := checkAggregatePage(arg.Skip, arg.Limit); err != nil {
+		return nil, err
+	}
+	var started []db.Progress
+	err := q.getDocs(ctx, &started, "SELECT doc FROM progress WHERE name = ? AND NOT watched AND position > 0 ORDER BY rowid", arg.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := make(map[primitive.ObjectID]int)
+	var progress []db.Progress
+	for _, p := range started {
+		key := p.SeriesID
+		if key.IsZero() {
+			key = p.MediaID
+		}
+		i, ok := latest[key]
+		if !ok {
+			latest[key] = len(progress)
+			progress = append(progress, p)
+		} else if p.UpdatedAt > progress[i].UpdatedAt {
+			progress[i] = p
+		}
+	}
+	sort.SliceStable(progress, func(i, j int) bool {
+		if progress[i].UpdatedAt != progress[j].UpdatedAt {
+			return progress[i].UpdatedAt > progress[j].UpdatedAt
+		}
+		return hex(progress[j].ID) < hex(progress[i].ID)
+	})
+	from := int(arg.Skip)
+	if from >= len(progress) {
+		return nil, nil
+	}
+	to := len(progress)
+	if from+int(arg.Limit) < to {
+		to = from + int(arg.Limit)
+	}
+	return progress[from:to], nil
+}
+
+// GetProgressByMediaIDs gets the progress of a user in the given movies or episodes,
+// in no particular order. The ones the user hasn't started are left out
+func (q *Queries) GetProgressByMediaIDs(ctx context.Context, name string, ids []primitive.ObjectID) ([]db.Progress, error) {
+	in, args := hexes(ids)
+	var progress []db.Progress
+	err := q.getDocs(ctx, &progress, "SELECT doc FROM progress WHERE name = ? AND media_id IN ("+in+") ORDER BY rowid",
+		append([]interface{}{name}, args...)...)
+	return progress, err
+}
@@ -0,0 +1,81 @@
+package sqlitedb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"time"
+)
+
+// RateMovie sets the score of a user for a movie, replacing their previous score,
+// and moves the community rating of the movie by the difference
+func (q *Queries) RateMovie(ctx context.Context, arg db.RateMovieParams) error {
+	return q.inTx(ctx, func(q *Queries) error {
+		now := primitive.NewDateTimeFromTime(time.Now())
+		rating, err := q.GetRating(ctx, arg.Name, arg.MovieID)
+		if err == mongo.ErrNoDocuments {
+			rating = db.Rating{
+				ID:        primitive.NewObjectID(),
+				Name:      arg.Name,
+				MovieID:   arg.MovieID,
+				Score:     arg.Score,
+				UpdatedAt: now,
+			}
+			_, err = q.insert(ctx, "ratings", hex(rating.ID), rating, "name", rating.Name, "movie_id", hex(rating.MovieID))
+			if err != nil {
+				return err
+			}
+			return q.addToCommunityRating(ctx, arg.MovieID, arg.Score, 1)
+		}
+		if err != nil {
+			return err
+		}
+		old := rating.Score
+		rating.Score = arg.Score
+		rating.UpdatedAt = now
+		if _, err = q.update(ctx, "ratings", hex(rating.ID), rating, "name", rating.Name, "movie_id", hex(rating.MovieID)); err != nil {
+			return err
+		}
+		return q.addToCommunityRating(ctx, arg.MovieID, arg.Score-old, 0)
+	})
+}
+
+// DeleteRating removes the score of a user for a movie from the community rating,
+// it returns mongo.ErrNoDocuments when the user hasn't rated the movie
+func (q *Queries) DeleteRating(ctx context.Context, name string, movieID primitive.ObjectID) error {
+	return q.inTx(ctx, func(q *Queries) error {
+		old, err := q.GetRating(ctx, name, movieID)
+		if err != nil {
+			return err
+		}
+		if _, err = q.db.ExecContext(ctx, "DELETE FROM ratings WHERE id = ?", hex(old.ID)); err != nil {
+			return err
+		}
+		return q.addToCommunityRating(ctx, movieID, -old.Score, -1)
+	})
+}
+
+// addToCommunityRating adds to the sum and count of the community rating of a movie
+// and works out the average again, if there is such a movie
+func (q *Queries) addToCommunityRating(ctx context.Context, movieID primitive.ObjectID, sum, count int64) error {
+	err := q.updateMovie(ctx, movieID, func(movie *db.Movies) {
+		community := &movie.Community
+		community.Sum += sum
+		community.Count += count
+		community.Rating = 0
+		if community.Count > 0 {
+			community.Rating = float64(community.Sum) / float64(community.Count)
+		}
+	})
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	return err
+}
+
+func (q *Queries) GetRating(ctx context.Context, name string, movieID primitive.ObjectID) (db.Rating, error) {
+	var rating db.Rating
+	err := q.getDoc(ctx, &rating, "SELECT doc FROM ratings WHERE name = ? AND movie_id = ?", name, hex(movieID))
+	return rating, err
+}
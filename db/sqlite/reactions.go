@@ -0,0 +1,87 @@
+package sqlitedb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"time"
+)
+
+// AddReaction adds a reaction of a user to a comment and counts it on the comment.
+// It returns false when the user had already left that reaction
+func (q *Queries) AddReaction(ctx context.Context, arg db.CommentReactionParams) (bool, error) {
+	added := false
+	err := q.inTx(ctx, func(q *Queries) error {
+		reaction := db.CommentReaction{
+			ID:        primitive.NewObjectID(),
+			CommentID: arg.CommentID,
+			Name:      arg.Name,
+			Reaction:  arg.Reaction,
+			CreatedAt: primitive.NewDateTimeFromTime(time.Now()),
+		}
+		_, err := q.insert(ctx, "reactions", hex(reaction.ID), reaction,
+			"comment_id", hex(arg.CommentID), "name", arg.Name, "reaction", arg.Reaction)
+		if uniqueFailed(err, "reactions.comment_id") {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		added = true
+		return q.countReaction(ctx, arg.CommentID, arg.Reaction, 1)
+	})
+	return added, err
+}
+
+// DeleteReaction takes back a reaction of a user to a comment,
+// it returns mongo.ErrNoDocuments when the user hasn't left that reaction
+func (q *Queries) DeleteReaction(ctx context.Context, arg db.CommentReactionParams) error {
+	return q.inTx(ctx, func(q *Queries) error {
+		res, err := q.db.ExecContext(ctx, "DELETE FROM reactions WHERE comment_id = ? AND name = ? AND reaction = ?",
+			hex(arg.CommentID), arg.Name, arg.Reaction)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			if err == nil {
+				err = mongo.ErrNoDocuments
+			}
+			return err
+		}
+		return q.countReaction(ctx, arg.CommentID, arg.Reaction, -1)
+	})
+}
+
+// countReaction adds n to the count of the reaction on the comment, if there is such a comment
+func (q *Queries) countReaction(ctx context.Context, commentID primitive.ObjectID, reaction string, n int64) error {
+	comment, err := q.GetComment(ctx, commentID)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	comment.Reactions = incCount(comment.Reactions, reaction, n)
+	comment.ReactionCount += n
+	return q.putComment(ctx, comment)
+}
+
+// incCount adds n to the count of key, in a copy of counts
+func incCount(counts map[string]int64, key string, n int64) map[string]int64 {
+	inc := make(map[string]int64, len(counts)+1)
+	for k, v := range counts {
+		inc[k] = v
+	}
+	inc[key] += n
+	return inc
+}
+
+// GetReactionsByName gets the reactions a user left on the comments, in no particular order
+func (q *Queries) GetReactionsByName(ctx context.Context, name string, commentIDs []primitive.ObjectID) ([]db.CommentReaction, error) {
+	in, args := hexes(commentIDs)
+	var reactions []db.CommentReaction
+	err := q.getDocs(ctx, &reactions, "SELECT doc FROM reactions WHERE name = ? AND comment_id IN ("+in+")",
+		append([]interface{}{name}, args...)...)
+	return reactions, err
+}
@@ -0,0 +1,292 @@
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	db "phantom/db/mongo"
+)
+
+// migrations make the schema, each from the one the migration before it made.
+// The database keeps the number of migrations it has had in its user_version,
+// so a migration is never changed once released, a new one is added instead
+var migrations = []string{
+	// 1: the collections of MongoDB with their unique indexes,
+	// and the full-text index of the movies
+	`
+CREATE TABLE users (
+	id    TEXT PRIMARY KEY,
+	name  TEXT NOT NULL,
+	email TEXT NOT NULL,
+	doc   BLOB NOT NULL
+);
+CREATE UNIQUE INDEX users_name ON users (name);
+CREATE UNIQUE INDEX users_email ON users (email);
+
+CREATE TABLE movies (
+	seq              INTEGER PRIMARY KEY,
+	id               TEXT NOT NULL UNIQUE,
+	sort_key         TEXT NOT NULL,
+	released         INTEGER,
+	runtime          INTEGER NOT NULL,
+	year             INTEGER NOT NULL,
+	imdb_rating      REAL NOT NULL,
+	community_rating REAL NOT NULL,
+	community_count  INTEGER NOT NULL,
+	doc              BLOB NOT NULL
+);
+CREATE INDEX movies_runtime ON movies (runtime DESC);
+CREATE INDEX movies_released ON movies (released DESC);
+CREATE TABLE movie_genres (
+	movie_id TEXT NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+	genre    TEXT NOT NULL
+);
+CREATE INDEX movie_genres_genre ON movie_genres (genre, movie_id);
+CREATE INDEX movie_genres_movie_id ON movie_genres (movie_id);
+CREATE TABLE movie_credits (
+	movie_id  TEXT NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+	person_id TEXT NOT NULL
+);
+CREATE INDEX movie_credits_person_id ON movie_credits (person_id);
+CREATE INDEX movie_credits_movie_id ON movie_credits (movie_id);
+CREATE VIRTUAL TABLE movies_fts USING fts5 (
+	title, original_title, titles, cast_names, genres, fullplot,
+	tokenize = 'porter unicode61'
+);
+
+CREATE TABLE people (
+	id     TEXT PRIMARY KEY,
+	name   TEXT NOT NULL,
+	search TEXT NOT NULL,
+	doc    BLOB NOT NULL
+);
+CREATE INDEX people_name ON people (name);
+
+CREATE TABLE seasons (
+	id        TEXT PRIMARY KEY,
+	series_id TEXT NOT NULL,
+	number    INTEGER NOT NULL,
+	doc       BLOB NOT NULL
+);
+CREATE UNIQUE INDEX seasons_series_id_number ON seasons (series_id, number);
+CREATE TABLE episodes (
+	id              TEXT PRIMARY KEY,
+	series_id       TEXT NOT NULL,
+	season          INTEGER NOT NULL,
+	number          INTEGER NOT NULL,
+	absolute_number INTEGER NOT NULL,
+	doc             BLOB NOT NULL
+);
+CREATE UNIQUE INDEX episodes_series_id_season_number ON episodes (series_id, season, number);
+
+CREATE TABLE progress (
+	id         TEXT PRIMARY KEY,
+	name       TEXT NOT NULL,
+	media_id   TEXT NOT NULL,
+	series_id  TEXT,
+	position   INTEGER NOT NULL,
+	watched    INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL,
+	doc        BLOB NOT NULL
+);
+CREATE UNIQUE INDEX progress_name_media_id ON progress (name, media_id);
+CREATE INDEX progress_name_series_id ON progress (name, series_id, updated_at DESC);
+CREATE TABLE history (
+	id         TEXT PRIMARY KEY,
+	name       TEXT NOT NULL,
+	watched_at INTEGER NOT NULL,
+	doc        BLOB NOT NULL
+);
+CREATE INDEX history_name_watched_at ON history (name, watched_at DESC);
+
+CREATE TABLE collections (
+	seq   INTEGER PRIMARY KEY,
+	id    TEXT NOT NULL UNIQUE,
+	title TEXT NOT NULL,
+	doc   BLOB NOT NULL
+);
+CREATE TABLE lists (
+	id       TEXT PRIMARY KEY,
+	name     TEXT NOT NULL,
+	list     TEXT NOT NULL,
+	movie_id TEXT NOT NULL,
+	position INTEGER NOT NULL,
+	doc      BLOB NOT NULL
+);
+CREATE UNIQUE INDEX lists_name_list_movie_id ON lists (name, list, movie_id);
+CREATE INDEX lists_name_list_position ON lists (name, list, position DESC);
+CREATE TABLE ratings (
+	id       TEXT PRIMARY KEY,
+	name     TEXT NOT NULL,
+	movie_id TEXT NOT NULL,
+	doc      BLOB NOT NULL
+);
+CREATE UNIQUE INDEX ratings_name_movie_id ON ratings (name, movie_id);
+
+CREATE TABLE comments (
+	seq            INTEGER PRIMARY KEY,
+	id             TEXT NOT NULL UNIQUE,
+	movie_id       TEXT NOT NULL,
+	name           TEXT NOT NULL,
+	parent_id      TEXT,
+	date           INTEGER NOT NULL,
+	deleted        INTEGER NOT NULL,
+	hidden         INTEGER NOT NULL,
+	flagged        INTEGER NOT NULL,
+	reaction_count INTEGER NOT NULL,
+	report_count   INTEGER NOT NULL,
+	doc            BLOB NOT NULL
+);
+CREATE INDEX comments_movie_id ON comments (movie_id);
+CREATE INDEX comments_name ON comments (name);
+CREATE INDEX comments_parent_id ON comments (parent_id, id);
+CREATE INDEX comments_moderation ON comments (hidden DESC, report_count DESC);
+CREATE TABLE reactions (
+	id         TEXT PRIMARY KEY,
+	comment_id TEXT NOT NULL,
+	name       TEXT NOT NULL,
+	reaction   TEXT NOT NULL,
+	doc        BLOB NOT NULL
+);
+CREATE UNIQUE INDEX reactions_comment_id_name_reaction ON reactions (comment_id, name, reaction);
+CREATE INDEX reactions_name_comment_id ON reactions (name, comment_id);
+CREATE TABLE reports (
+	id         TEXT PRIMARY KEY,
+	comment_id TEXT NOT NULL,
+	name       TEXT NOT NULL,
+	doc        BLOB NOT NULL
+);
+CREATE UNIQUE INDEX reports_comment_id_name ON reports (comment_id, name);
+CREATE TABLE moderation_log (
+	id         TEXT PRIMARY KEY,
+	created_at INTEGER NOT NULL,
+	doc        BLOB NOT NULL
+);
+CREATE INDEX moderation_log_created_at ON moderation_log (created_at DESC);
+
+CREATE TABLE danmaku (
+	id        TEXT PRIMARY KEY,
+	movie_id  TEXT NOT NULL,
+	offset_ms INTEGER NOT NULL,
+	flagged   INTEGER NOT NULL,
+	pinned    INTEGER NOT NULL,
+	doc       BLOB NOT NULL
+);
+CREATE INDEX danmaku_movie_id_offset ON danmaku (movie_id, offset_ms);
+
+CREATE TABLE ledger (
+	seq    INTEGER PRIMARY KEY,
+	kind   TEXT NOT NULL,
+	op     TEXT NOT NULL,
+	ref    TEXT NOT NULL,
+	digest TEXT NOT NULL,
+	prev   TEXT NOT NULL,
+	hash   TEXT NOT NULL
+);
+CREATE INDEX ledger_ref ON ledger (ref, seq DESC);
+CREATE TABLE ledger_checkpoints (
+	"from" INTEGER NOT NULL,
+	"to"   INTEGER PRIMARY KEY,
+	root   TEXT NOT NULL
+);
+
+CREATE TABLE coin_transactions (
+	id         TEXT PRIMARY KEY,
+	key        TEXT NOT NULL,
+	created_at INTEGER NOT NULL,
+	doc        BLOB NOT NULL
+);
+CREATE UNIQUE INDEX coin_transactions_key ON coin_transactions (key);
+CREATE TABLE coin_postings (
+	transaction_id TEXT NOT NULL REFERENCES coin_transactions (id),
+	account        TEXT NOT NULL,
+	amount         INTEGER NOT NULL
+);
+CREATE INDEX coin_postings_account ON coin_postings (account);
+CREATE TABLE coin_wallets (
+	id      TEXT PRIMARY KEY,
+	balance INTEGER NOT NULL,
+	doc     BLOB NOT NULL
+);
+CREATE TABLE title_requests (
+	id             TEXT PRIMARY KEY,
+	transaction_id TEXT NOT NULL,
+	created_at     INTEGER NOT NULL,
+	doc            BLOB NOT NULL
+);
+CREATE UNIQUE INDEX title_requests_transaction_id ON title_requests (transaction_id);
+CREATE INDEX title_requests_created_at ON title_requests (created_at DESC);
+`,
+	// 2: the keys UpsertMovies finds a movie by, which backfillMovieKeys
+	// fills in for the movies there are
+	`
+ALTER TABLE movies ADD COLUMN imdb_id INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE movies ADD COLUMN title TEXT NOT NULL DEFAULT '';
+CREATE INDEX movies_imdb_id ON movies (imdb_id);
+CREATE INDEX movies_title_year ON movies (title, year);
+`,
+}
+
+// backfills fill in what the migration with the version adds from the
+// documents, which SQL can't read, in the transaction of the migration
+var backfills = map[int]func(ctx context.Context, q *Queries) error{
+	2: backfillMovieKeys,
+}
+
+func backfillMovieKeys(ctx context.Context, q *Queries) error {
+	var movies []db.Movies
+	if err := q.getDocs(ctx, &movies, "SELECT doc FROM movies"); err != nil {
+		return err
+	}
+	for _, movie := range movies {
+		_, err := q.db.ExecContext(ctx, "UPDATE movies SET imdb_id = ?, title = ? WHERE id = ?",
+			movie.Imdb.Id, movie.Title, hex(movie.Id))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// errNoFTS5 is the error of a SQLite built without FTS5, see the package doc
+var errNoFTS5 = errors.New("SQLite has no FTS5, build the server with -tags sqlite_fts5")
+
+// migrate runs the migrations the database hasn't had yet, each in a
+// transaction of its own, and refuses a database made by a newer server
+func migrate(ctx context.Context, sqlDB *sql.DB) error {
+	var fts5 bool
+	if err := sqlDB.QueryRowContext(ctx, "SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5); err != nil {
+		return err
+	}
+	if !fts5 {
+		return errNoFTS5
+	}
+
+	var version int
+	if err := sqlDB.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("the database is at version %d, which is newer than this server's %d", version, len(migrations))
+	}
+	for ; version < len(migrations); version++ {
+		err := execTx(ctx, sqlDB, func(q *Queries) error {
+			if _, err := q.db.ExecContext(ctx, migrations[version]); err != nil {
+				return err
+			}
+			if backfill := backfills[version+1]; backfill != nil {
+				if err := backfill(ctx, q); err != nil {
+					return err
+				}
+			}
+			// PRAGMA takes no placeholders
+			_, err := q.db.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", version+1))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d: %w", version+1, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package sqlitedb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	db "phantom/db/mongo"
+)
+
+// AddSeason adds a season, a series has one season of each number
+func (q *Queries) AddSeason(ctx context.Context, arg db.AddSeasonParams) (primitive.ObjectID, error) {
+	var season db.Season
+	db.Convert(arg, &season)
+	season.ID = primitive.NewObjectID()
+	_, err := q.insert(ctx, "seasons", hex(season.ID), season, "series_id", hex(season.SeriesID), "number", season.Number)
+	if err != nil {
+		return primitive.ObjectID{}, duplicateKey(err, "seasons", "series_id_1_number_1")
+	}
+	return season.ID, nil
+}
+
+// GetSeasonsBySeriesID gets the seasons of a series by their number,
+// the specials come first
+func (q *Queries) GetSeasonsBySeriesID(ctx context.Context, seriesID primitive.ObjectID) ([]db.Season, error) {
+	var seasons []db.Season
+	err := q.getDocs(ctx, &seasons, "SELECT doc FROM seasons WHERE series_id = ? ORDER BY number", hex(seriesID))
+	return seasons, err
+}
+
+// AddEpisode adds an episode, a season has one episode of each number
+func (q *Queries) AddEpisode(ctx context.Context, arg db.AddEpisodeParams) (primitive.ObjectID, error) {
+	var episode db.Episode
+	db.Convert(arg, &episode)
+	episode.ID = primitive.NewObjectID()
+	_, err := q.insert(ctx, "episodes", hex(episode.ID), episode,
+		"series_id", hex(episode.SeriesID),
+		"season", episode.Season,
+		"number", episode.Number,
+		"absolute_number", episode.AbsoluteNumber)
+	if err != nil {
+		return primitive.ObjectID{}, duplicateKey(err, "episodes", "series_id_1_season_1_number_1")
+	}
+	return episode.ID, nil
+}
+
+func (q *Queries) GetEpisodeByID(ctx context.Context, id primitive.ObjectID) (db.Episode, error) {
+	var episode db.Episode
+	err := q.getDoc(ctx, &episode, "SELECT doc FROM episodes WHERE id = ?", hex(id))
+	return episode, err
+}
+
+// GetEpisodes lists episodes in the order they aired, season by season,
+// or by their absolute number, which leaves out the episodes without one
+func (q *Queries) GetEpisodes(ctx context.Context, arg db.GetEpisodesParams) ([]db.Episode, error) {
+	where := "series_id = ?"
+	args := []interface{}{hex(arg.SeriesID)}
+	if arg.Season != nil {
+		where += " AND season = ?"
+		args = append(args, *arg.Season)
+	}
+	orderBy := "season, number"
+	if arg.Order == db.EpisodeOrderAbsolute {
+		where += " AND absolute_number > 0"
+		orderBy = "absolute_number"
+	}
+	var episodes []db.Episode
+	err := q.getDocs(ctx, &episodes, "SELECT doc FROM episodes WHERE "+where+" ORDER BY "+orderBy+", rowid", args...)
+	return episodes, err
+}
+
+// GetEpisodesByIDs gets the episodes with the given ids, in no particular order
+func (q *Queries) GetEpisodesByIDs(ctx context.Context, ids []primitive.ObjectID) ([]db.Episode, error) {
+	in, args := hexes(ids)
+	var episodes []db.Episode
+	err := q.getDocs(ctx, &episodes, "SELECT doc FROM episodes WHERE id IN ("+in+") ORDER BY rowid", args...)
+	return episodes, err
+}
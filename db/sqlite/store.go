@@ -0,0 +1,308 @@
+// Package sqlitedb keeps the data of the server in a SQLite database file,
+// for servers that would rather not run MongoDB. Its Store behaves like
+// db.MongoStore, down to the errors it returns, which package storetest
+// checks for both. Each collection of MongoDB is a table that keeps the
+// documents as BSON, with the fields the queries look up or sort by copied
+// to columns of their own. Movies are searched with FTS5, which SQLite only
+// has when the server is built with the sqlite_fts5 tag
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"reflect"
+	"strings"
+)
+
+// DBTX is what the queries run on, the database or a transaction
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Queries answers the queries of db.Querier from the tables. A query that
+// takes several statements runs them in a transaction of its own, or in
+// the one it is part of
+type Queries struct {
+	db DBTX
+}
+
+var _ db.Querier = (*Queries)(nil)
+
+func New(db DBTX) *Queries {
+	return &Queries{db: db}
+}
+
+// Store is a db.Store on a SQLite database, safe for concurrent use
+type Store struct {
+	*Queries
+	db *sql.DB
+}
+
+var _ db.Store = (*Store)(nil)
+
+// Open opens the database file at the path, making it when there is none,
+// and brings its schema up to date
+func Open(ctx context.Context, path string) (*Store, error) {
+	sqlDB, err := sql.Open("sqlite3", path+"?_journal_mode=WAL&_busy_timeout=5000&_foreign_keys=on")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite writes one transaction at a time, and a single connection
+	// queues them up instead of failing them with SQLITE_BUSY
+	sqlDB.SetMaxOpenConns(1)
+	if err = migrate(ctx, sqlDB); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return &Store{Queries: New(sqlDB), db: sqlDB}, nil
+}
+
+func (store *Store) Close() error {
+	return store.db.Close()
+}
+
+// ExecTx runs fn in a transaction: the queries fn makes with q are written
+// together when fn returns nil, and not at all when it returns an error.
+// The store has one connection, which the transaction holds until fn returns,
+// so fn can only use q and not the store
+func (store *Store) ExecTx(ctx context.Context, fn func(q db.Querier) error) error {
+	return execTx(ctx, store.db, func(q *Queries) error {
+		return fn(q)
+	})
+}
+
+func execTx(ctx context.Context, sqlDB *sql.DB, fn func(q *Queries) error) error {
+	tx, err := sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err = fn(New(tx)); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("tx err: %v, rb err: %v", err, rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// inTx runs fn in a transaction, or in the one q is part of
+func (q *Queries) inTx(ctx context.Context, fn func(q *Queries) error) error {
+	sqlDB, ok := q.db.(*sql.DB)
+	if !ok {
+		return fn(q)
+	}
+	return execTx(ctx, sqlDB, fn)
+}
+
+// hex is how an id is kept in a column, which sorts like the id
+func hex(id primitive.ObjectID) string {
+	return id.Hex()
+}
+
+// hexes are the ids as arguments of a query, with the placeholders for them
+func hexes(ids []primitive.ObjectID) (string, []interface{}) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = hex(id)
+	}
+	return placeholders(len(ids)), args
+}
+
+func placeholders(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strings.Repeat("?,", n-1) + "?"
+}
+
+// insert adds the document to the table, with the id and the values
+// of the columns, which are pairs of a column and its value
+func (q *Queries) insert(ctx context.Context, table, id string, doc interface{}, columns ...interface{}) (sql.Result, error) {
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{"id", "doc"}
+	args := []interface{}{id, data}
+	for i := 0; i < len(columns); i += 2 {
+		names = append(names, columns[i].(string))
+		args = append(args, columns[i+1])
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(names, ", "), placeholders(len(args)))
+	return q.db.ExecContext(ctx, query, args...)
+}
+
+// update writes the document with the id back to the table, like insert,
+// and tells whether there was a document to write to
+func (q *Queries) update(ctx context.Context, table, id string, doc interface{}, columns ...interface{}) (bool, error) {
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return false, err
+	}
+	set := []string{"doc = ?"}
+	args := []interface{}{data}
+	for i := 0; i < len(columns); i += 2 {
+		set = append(set, columns[i].(string)+" = ?")
+		args = append(args, columns[i+1])
+	}
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE id = ?", table, strings.Join(set, ", "))
+	res, err := q.db.ExecContext(ctx, query, append(args, id)...)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// getDoc decodes the doc column of the row the query selects into dst,
+// it returns mongo.ErrNoDocuments when there is no such row
+func (q *Queries) getDoc(ctx context.Context, dst interface{}, query string, args ...interface{}) error {
+	var data []byte
+	err := q.db.QueryRowContext(ctx, query, args...).Scan(&data)
+	if err == sql.ErrNoRows {
+		return mongo.ErrNoDocuments
+	}
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(data, dst)
+}
+
+// getDocs decodes the doc column of the rows the query selects into the slice
+// dst points to, which is left nil when there are none, like a cursor leaves it.
+// The rows are read to the end before it returns, so that the connection
+// is free for the next query
+func (q *Queries) getDocs(ctx context.Context, dst interface{}, query string, args ...interface{}) error {
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	slice := reflect.ValueOf(dst).Elem()
+	for rows.Next() {
+		var data []byte
+		if err = rows.Scan(&data); err != nil {
+			return err
+		}
+		doc := reflect.New(slice.Type().Elem())
+		if err = bson.Unmarshal(data, doc.Interface()); err != nil {
+			return err
+		}
+		slice.Set(reflect.Append(slice, doc.Elem()))
+	}
+	return rows.Err()
+}
+
+// getStrings gets the first column of the rows the query selects
+func (q *Queries) getStrings(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var strs []string
+	for rows.Next() {
+		var s string
+		if err = rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		strs = append(strs, s)
+	}
+	return strs, rows.Err()
+}
+
+// limit is a page of a query, where a limit of zero lets every row through
+// like a find does
+func limit(skip, n int64) string {
+	if n <= 0 {
+		n = -1
+	}
+	if skip < 0 {
+		skip = 0
+	}
+	return fmt.Sprintf(" LIMIT %d OFFSET %d", n, skip)
+}
+
+// The codes MongoDB gives the writes it refuses
+const (
+	codeDuplicateKey     = 11000
+	codeValidationFailed = 121
+)
+
+// duplicateKey turns the error of a write that breaks a unique index
+// into the one MongoDB gives for the index with the name
+func duplicateKey(err error, collection, index string) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return mongo.WriteException{WriteErrors: mongo.WriteErrors{{
+			Code:    codeDuplicateKey,
+			Message: fmt.Sprintf("E11000 duplicate key error collection: phantom.%s index: %s dup key", collection, index),
+		}}}
+	}
+	return err
+}
+
+// uniqueFailed tells whether err is of a write that broke a unique index
+// on the column, which SQLite names as table.column
+func uniqueFailed(err error, column string) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique &&
+		strings.Contains(sqliteErr.Error(), column)
+}
+
+// errValidation is the error of a write that the validator of the collection refuses
+func errValidation() error {
+	return mongo.WriteException{WriteErrors: mongo.WriteErrors{{
+		Code:    codeValidationFailed,
+		Message: "Document failed validation",
+	}}}
+}
+
+// bulkWriteError is the error of the write at index i of a bulk write
+func bulkWriteError(i int, err error) mongo.BulkWriteError {
+	writeErr := err.(mongo.WriteException).WriteErrors[0]
+	writeErr.Index = i
+	return mongo.BulkWriteError{WriteError: writeErr}
+}
+
+// errAggregateLimit is the error of an aggregation whose $limit isn't positive
+var errAggregateLimit = mongo.CommandError{Code: 15958, Message: "the limit must be positive"}
+
+// checkAggregatePage refuses the pages an aggregation refuses
+func checkAggregatePage(skip, limit int64) error {
+	if limit <= 0 {
+		return errAggregateLimit
+	}
+	if skip < 0 {
+		return mongo.CommandError{Code: 15956, Message: "invalid argument to $skip stage: Cannot skip a negative number of documents"}
+	}
+	return nil
+}
+
+// boolInt is a bool as SQLite keeps it
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// nullTime is a time as a column, NULL for no time so that it sorts
+// the way a missing field does
+func nullTime(t primitive.DateTime) interface{} {
+	if t == 0 {
+		return nil
+	}
+	return int64(t)
+}
@@ -0,0 +1,103 @@
+//go:build sqlite_fts5
+
+package sqlitedb
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	db "phantom/db/mongo"
+	"phantom/db/storetest"
+	"phantom/util"
+)
+
+func openStore(t *testing.T) *Store {
+	store, err := Open(context.Background(), filepath.Join(t.TempDir(), "phantom.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, openStore(t))
+}
+
+func TestReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "phantom.db")
+	store, err := Open(ctx, path)
+	require.NoError(t, err)
+	movieID, err := store.AddMovie(ctx, db.AddMovieParams{Title: "The Reopened Door", Runtime: 10})
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	store, err = Open(ctx, path)
+	require.NoError(t, err)
+	defer store.Close()
+	movie, err := store.GetMovieByID(ctx, movieID)
+	require.NoError(t, err)
+	require.Equal(t, "The Reopened Door", movie.Title)
+	movies, err := store.SearchForMovies(ctx, db.SearchForMoviesParams{Text: "doors", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, movies, 1)
+}
+
+// TestBackfillMovieKeys upserts into movies that were added before the keys had columns
+func TestBackfillMovieKeys(t *testing.T) {
+	ctx := context.Background()
+	store := openStore(t)
+	arg := db.AddMovieParams{Title: util.RandomString(12), Year: 1999}
+	movieID, err := store.AddMovie(ctx, arg)
+	require.NoError(t, err)
+	_, err = store.db.ExecContext(ctx, "UPDATE movies SET imdb_id = 0, title = ''")
+	require.NoError(t, err)
+
+	require.NoError(t, execTx(ctx, store.db, func(q *Queries) error {
+		return backfillMovieKeys(ctx, q)
+	}))
+	res, err := store.UpsertMovies(ctx, []db.Movies{{Title: arg.Title, Year: arg.Year, Runtime: 90}})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), res.ModifiedCount)
+	movie, err := store.GetMovieByID(ctx, movieID)
+	require.NoError(t, err)
+	require.Equal(t, int64(90), movie.Runtime)
+}
+
+func TestConcurrentUse(t *testing.T) {
+	store := openStore(t)
+	ctx := context.Background()
+	movieID, err := store.AddMovie(ctx, db.AddMovieParams{Title: util.RandomString(12), Runtime: 10})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				err := store.ExecTx(ctx, func(q db.Querier) error {
+					_, err := q.AddComment(ctx, db.AddCommentParams{Name: fmt.Sprint("user", i), MovieID: movieID, Text: util.RandomString(20)})
+					if err != nil {
+						return err
+					}
+					return q.IncrementMovieComments(ctx, movieID, 1)
+				})
+				require.NoError(t, err)
+				_, err = store.GetCommentsByMovieID(ctx, db.GetCommentsParams{MovieID: movieID, Limit: 5})
+				require.NoError(t, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	movie, err := store.GetMovieByID(ctx, movieID)
+	require.NoError(t, err)
+	require.Equal(t, int64(200), movie.NumMflixComments)
+	comments, err := store.GetCommentsByMovieID(ctx, db.GetCommentsParams{MovieID: movieID})
+	require.NoError(t, err)
+	require.Len(t, comments, 200)
+}
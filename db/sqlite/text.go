@@ -0,0 +1,121 @@
+package sqlitedb
+
+import (
+	db "phantom/db/mongo"
+	"phantom/search"
+	"strings"
+)
+
+// textQuery is a $search string of a $text query: a document matches when
+// it has one of the terms and all of the phrases, and none of the negated ones
+type textQuery struct {
+	terms          []string
+	phrases        []string
+	negatedTerms   []string
+	negatedPhrases []string
+}
+
+func parseTextQuery(s string) textQuery {
+	var query textQuery
+	for {
+		start := strings.IndexByte(s, '"')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(s[start+1:], '"')
+		if end < 0 {
+			break
+		}
+		phrase := search.Normalize(s[start+1 : start+1+end])
+		if start > 0 && s[start-1] == '-' {
+			query.negatedPhrases = append(query.negatedPhrases, phrase)
+			s = s[:start-1] + " " + s[start+2+end:]
+			continue
+		}
+		query.phrases = append(query.phrases, phrase)
+		// The words of a phrase are looked up like terms
+		query.terms = append(query.terms, textTerms(phrase)...)
+		s = s[:start] + " " + s[start+2+end:]
+	}
+	for _, field := range strings.Fields(s) {
+		if strings.HasPrefix(field, "-") {
+			query.negatedTerms = append(query.negatedTerms, textTerms(field[1:])...)
+			continue
+		}
+		query.terms = append(query.terms, textTerms(field)...)
+	}
+	return query
+}
+
+// match is the query as an FTS5 query, empty when nothing can match it
+func (query textQuery) match() string {
+	if len(query.terms) == 0 {
+		return ""
+	}
+	match := "(" + ftsOr(query.terms) + ")"
+	for _, phrase := range query.phrases {
+		match += " AND " + ftsString(phrase)
+	}
+	if negated := append(query.negatedTerms, query.negatedPhrases...); len(negated) > 0 {
+		match += " NOT (" + ftsOr(negated) + ")"
+	}
+	return match
+}
+
+func ftsOr(strs []string) string {
+	quoted := make([]string, len(strs))
+	for i, s := range strs {
+		quoted[i] = ftsString(s)
+	}
+	return strings.Join(quoted, " OR ")
+}
+
+// ftsString is s as an FTS5 string, which FTS5 matches as a phrase of its words
+func ftsString(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// textTerms are the words of s that a text index keeps
+func textTerms(s string) []string {
+	var terms []string
+	for _, word := range search.Words(s) {
+		if !stopWords[word] {
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// movieText is what the text index of 'movies' has of a movie,
+// by the columns of movies_fts
+func movieText(movie db.Movies) []interface{} {
+	titles := make([]string, len(movie.Titles))
+	for i, title := range movie.Titles {
+		titles[i] = title.Title
+	}
+	return []interface{}{
+		movie.Title,
+		movie.OriginalTitle,
+		strings.Join(titles, "\n"),
+		strings.Join(movie.Cast, "\n"),
+		strings.Join(movie.Genres, "\n"),
+		movie.Fullplot,
+	}
+}
+
+// stopWords are the English words a text index leaves out
+var stopWords = func() map[string]bool {
+	words := strings.Fields(`a about above after again against all am an and any are as at
+		be because been before being below between both but by can did do does doing down during
+		each few for from further had has have having he her here hers herself him himself his how
+		i if in into is it its itself just me more most my myself no nor not now of off on once only
+		or other our ours ourselves out over own same she should so some such than that the their
+		theirs them themselves then there these they this those through to too under until up very
+		was we were what when where which while who whom why will with would you your yours yourself
+		yourselves`)
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		set[word] = true
+	}
+	return set
+}()
@@ -0,0 +1,51 @@
+package sqlitedb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	db "phantom/db/mongo"
+	"time"
+)
+
+// RequestTitleTx adds a title request and takes the coins for it together.
+// Requesting again with the same key gives back the first request
+func (store *Store) RequestTitleTx(ctx context.Context, arg db.RequestTitleTxParams) (db.TitleRequest, error) {
+	var request db.TitleRequest
+	err := execTx(ctx, store.db, func(q *Queries) error {
+		transfer, err := q.transferCoins(ctx, db.TransferCoinsParams{
+			Key:    db.CoinRequestTitle + ":" + arg.Key,
+			Kind:   db.CoinRequestTitle,
+			From:   db.UserCoinAccount(arg.Name),
+			To:     db.CoinAccountSpent,
+			Amount: arg.Cost,
+			Memo:   arg.Title,
+		})
+		if err != nil {
+			return err
+		}
+		if transfer.Replayed {
+			return q.getDoc(ctx, &request, "SELECT doc FROM title_requests WHERE transaction_id = ?", hex(transfer.Transaction.ID))
+		}
+
+		request = db.TitleRequest{
+			ID:            primitive.NewObjectID(),
+			Name:          arg.Name,
+			Title:         arg.Title,
+			Year:          arg.Year,
+			Note:          arg.Note,
+			TransactionID: transfer.Transaction.ID,
+			CreatedAt:     primitive.NewDateTimeFromTime(time.Now()),
+		}
+		_, err = q.insert(ctx, "title_requests", hex(request.ID), request,
+			"transaction_id", hex(request.TransactionID), "created_at", int64(request.CreatedAt))
+		return err
+	})
+	return request, err
+}
+
+// GetTitleRequests gets a page of the title requests, the latest first
+func (q *Queries) GetTitleRequests(ctx context.Context, arg db.GetTitleRequestsParams) ([]db.TitleRequest, error) {
+	var requests []db.TitleRequest
+	err := q.getDocs(ctx, &requests, "SELECT doc FROM title_requests ORDER BY created_at DESC, id DESC"+limit(arg.Skip, arg.Limit))
+	return requests, err
+}
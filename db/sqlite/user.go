@@ -0,0 +1,126 @@
+package sqlitedb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+)
+
+// AddUser needs an email and a password, and a name and an email
+// no other user has, like the validator and the unique indexes of 'users'
+func (q *Queries) AddUser(ctx context.Context, arg db.AddUserParams) (primitive.ObjectID, error) {
+	if arg.Email == "" || arg.Password == "" {
+		return primitive.ObjectID{}, errValidation()
+	}
+	user := db.User{ID: primitive.NewObjectID(), Name: arg.Name, Email: arg.Email, Password: arg.Password}
+	_, err := q.insert(ctx, "users", hex(user.ID), user, "name", user.Name, "email", user.Email)
+	if err != nil {
+		return primitive.ObjectID{}, userDuplicateKey(err)
+	}
+	return user.ID, nil
+}
+
+// userDuplicateKey names the unique index of 'users' the write broke
+func userDuplicateKey(err error) error {
+	if uniqueFailed(err, "users.email") {
+		return duplicateKey(err, "users", "email_1")
+	}
+	return duplicateKey(err, "users", "name_1")
+}
+
+func (q *Queries) GetUserByID(ctx context.Context, id primitive.ObjectID) (db.User, error) {
+	return q.getUser(ctx, "id", hex(id))
+}
+
+func (q *Queries) GetUserByName(ctx context.Context, name string) (db.User, error) {
+	return q.getUser(ctx, "name", name)
+}
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (db.User, error) {
+	return q.getUser(ctx, "email", email)
+}
+
+func (q *Queries) getUser(ctx context.Context, column, value string) (db.User, error) {
+	var user db.User
+	err := q.getDoc(ctx, &user, "SELECT doc FROM users WHERE "+column+" = ?", value)
+	return user, err
+}
+
+func (q *Queries) UpdateUserName(ctx context.Context, user db.User) (*mongo.UpdateResult, error) {
+	res, err := q.updateUser(ctx, user.ID, func(u *db.User) bool {
+		changed := u.Name != user.Name
+		u.Name = user.Name
+		return changed
+	})
+	return res, userDuplicateKey(err)
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, user db.User) (*mongo.UpdateResult, error) {
+	return q.updateUser(ctx, user.ID, func(u *db.User) bool {
+		changed := u.Password != user.Password
+		u.Password = user.Password
+		return changed
+	})
+}
+
+// UpdateUserLocale sets the locale the user prefers titles in
+func (q *Queries) UpdateUserLocale(ctx context.Context, user db.User) (*mongo.UpdateResult, error) {
+	return q.updateUser(ctx, user.ID, func(u *db.User) bool {
+		changed := u.Locale != user.Locale
+		u.Locale = user.Locale
+		return changed
+	})
+}
+
+// UpdateUserRole sets the role of the user, such as db.UserRoleAdmin
+func (q *Queries) UpdateUserRole(ctx context.Context, user db.User) (*mongo.UpdateResult, error) {
+	return q.updateUser(ctx, user.ID, func(u *db.User) bool {
+		changed := u.Role != user.Role
+		u.Role = user.Role
+		return changed
+	})
+}
+
+// updateUser sets fields of the user with the id, set tells whether they changed
+func (q *Queries) updateUser(ctx context.Context, id primitive.ObjectID, set func(user *db.User) bool) (*mongo.UpdateResult, error) {
+	res := &mongo.UpdateResult{}
+	err := q.inTx(ctx, func(q *Queries) error {
+		user, err := q.GetUserByID(ctx, id)
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		res.MatchedCount = 1
+		if !set(&user) {
+			return nil
+		}
+		res.ModifiedCount = 1
+		_, err = q.putUser(ctx, user)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (q *Queries) putUser(ctx context.Context, user db.User) (bool, error) {
+	return q.update(ctx, "users", hex(user.ID), user, "name", user.Name, "email", user.Email)
+}
+
+// BanUser stops a user from commenting,
+// it returns mongo.ErrNoDocuments when there is no such user
+func (q *Queries) BanUser(ctx context.Context, name string) error {
+	return q.inTx(ctx, func(q *Queries) error {
+		user, err := q.GetUserByName(ctx, name)
+		if err != nil {
+			return err
+		}
+		user.Banned = true
+		_, err = q.putUser(ctx, user)
+		return err
+	})
+}
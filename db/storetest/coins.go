@@ -0,0 +1,154 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"phantom/util"
+)
+
+// grant gives coins to the user with the name
+func grant(t *testing.T, store db.Store, name string, amount int64) {
+	_, err := store.TransferCoinsTx(context.Background(), db.TransferCoinsParams{
+		Key:    db.CoinGrant + ":" + util.RandomString(12),
+		Kind:   db.CoinGrant,
+		From:   db.CoinAccountIssued,
+		To:     db.UserCoinAccount(name),
+		Amount: amount,
+	})
+	require.NoError(t, err)
+}
+
+func balance(t *testing.T, store db.Store, account string) int64 {
+	wallet, err := store.GetCoinWallet(context.Background(), account)
+	require.NoError(t, err)
+	return wallet.Balance
+}
+
+func testCoins(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	alice, bob := db.UserCoinAccount(util.RandomString(12)), db.UserCoinAccount(util.RandomString(12))
+	_, err := store.GetCoinWallet(ctx, alice)
+	require.Equal(t, mongo.ErrNoDocuments, err)
+
+	arg := db.TransferCoinsParams{
+		Key:    db.CoinGrant + ":" + util.RandomString(12),
+		Kind:   db.CoinGrant,
+		From:   db.CoinAccountIssued,
+		To:     alice,
+		Amount: 30,
+	}
+	first, err := store.TransferCoinsTx(ctx, arg)
+	require.NoError(t, err)
+	require.False(t, first.Replayed)
+	require.Equal(t, []db.CoinPosting{{Account: db.CoinAccountIssued, Amount: -30}, {Account: alice, Amount: 30}}, first.Transaction.Postings)
+
+	// The same key gives back the same transaction, once
+	again, err := store.TransferCoinsTx(ctx, arg)
+	require.NoError(t, err)
+	require.True(t, again.Replayed)
+	require.Equal(t, first.Transaction.ID, again.Transaction.ID)
+	require.Equal(t, int64(30), balance(t, store, alice))
+	arg.Amount = 20
+	_, err = store.TransferCoinsTx(ctx, arg)
+	require.Equal(t, db.ErrCoinKeyReused, err)
+
+	// Users only spend the coins they have
+	spend := db.TransferCoinsParams{Key: util.RandomString(12), Kind: db.CoinGrant, From: alice, To: bob, Amount: 40}
+	_, err = store.TransferCoinsTx(ctx, spend)
+	require.Equal(t, db.ErrInsufficientCoins, err)
+	spend.Amount = 0
+	_, err = store.TransferCoinsTx(ctx, spend)
+	require.Error(t, err)
+	spend.Amount = 10
+	_, err = store.TransferCoinsTx(ctx, spend)
+	require.NoError(t, err)
+	require.Equal(t, int64(20), balance(t, store, alice))
+	require.Equal(t, int64(10), balance(t, store, bob))
+
+	transactions, err := store.GetCoinTransactions(ctx, db.GetCoinTransactionsParams{Account: alice})
+	require.NoError(t, err)
+	require.Len(t, transactions, 2)
+	require.Equal(t, spend.Key, transactions[0].Key)
+	transactions, err = store.GetCoinTransactions(ctx, db.GetCoinTransactionsParams{Account: alice, Skip: 1, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, transactions, 1)
+	require.Equal(t, first.Transaction.ID, transactions[0].ID)
+
+	// A reversal moves the coins of a transaction back, once per key
+	reverse := db.ReverseCoinsParams{Key: util.RandomString(12), Kind: db.CoinRevokeComment, Reverses: spend.Key}
+	reversed, err := store.ReverseCoins(ctx, reverse)
+	require.NoError(t, err)
+	require.Equal(t, []db.CoinPosting{{Account: bob, Amount: -10}, {Account: alice, Amount: 10}}, reversed.Transaction.Postings)
+	again, err = store.ReverseCoins(ctx, reverse)
+	require.NoError(t, err)
+	require.True(t, again.Replayed)
+	require.Equal(t, int64(30), balance(t, store, alice))
+	require.Equal(t, int64(0), balance(t, store, bob))
+	reverse.Reverses = util.RandomString(12)
+	_, err = store.ReverseCoins(ctx, reverse)
+	require.Equal(t, mongo.ErrNoDocuments, err)
+
+	// A reward taken back after it was spent is owed, and nothing
+	// is spent until the coins are back
+	carol := util.RandomString(12)
+	earned, err := store.TransferCoins(ctx, db.TransferCoinsParams{
+		Key: util.RandomString(12), Kind: db.CoinEarnComment, From: db.CoinAccountIssued, To: db.UserCoinAccount(carol), Amount: 2,
+	})
+	require.NoError(t, err)
+	_, err = store.TransferCoins(ctx, db.TransferCoinsParams{
+		Key: util.RandomString(12), Kind: db.CoinPinDanmaku, From: db.UserCoinAccount(carol), To: db.CoinAccountSpent, Amount: 2,
+	})
+	require.NoError(t, err)
+	_, err = store.ReverseCoins(ctx, db.ReverseCoinsParams{Key: util.RandomString(12), Kind: db.CoinRevokeComment, Reverses: earned.Transaction.Key})
+	require.NoError(t, err)
+	require.Equal(t, int64(-2), balance(t, store, db.UserCoinAccount(carol)))
+	_, err = store.TransferCoins(ctx, db.TransferCoinsParams{
+		Key: util.RandomString(12), Kind: db.CoinPinDanmaku, From: db.UserCoinAccount(carol), To: db.CoinAccountSpent, Amount: 1,
+	})
+	require.Equal(t, db.ErrInsufficientCoins, err)
+	grant(t, store, carol, 5)
+	require.Equal(t, int64(3), balance(t, store, db.UserCoinAccount(carol)))
+
+	// A reversal that fails takes the transaction it is part of down with it
+	dave := util.RandomString(12)
+	err = store.ExecTx(ctx, func(q db.Querier) error {
+		if _, err := q.TransferCoins(ctx, db.TransferCoinsParams{
+			Key: util.RandomString(12), Kind: db.CoinGrant, From: db.CoinAccountIssued, To: db.UserCoinAccount(dave), Amount: 5,
+		}); err != nil {
+			return err
+		}
+		// The key is of the first reversal, which moved other coins
+		_, err := q.ReverseCoins(ctx, db.ReverseCoinsParams{Key: reversed.Transaction.Key, Kind: db.CoinRevokeComment, Reverses: earned.Transaction.Key})
+		return err
+	})
+	require.Equal(t, db.ErrCoinKeyReused, err)
+	_, err = store.GetCoinWallet(ctx, db.UserCoinAccount(dave))
+	require.Equal(t, mongo.ErrNoDocuments, err)
+
+	// A title request is paid for once per key
+	name := util.RandomString(12)
+	grant(t, store, name, 10)
+	request := db.RequestTitleTxParams{Key: util.RandomString(12), Name: name, Title: util.RandomString(12), Year: 1999, Cost: 6}
+	requested, err := store.RequestTitleTx(ctx, request)
+	require.NoError(t, err)
+	require.Equal(t, request.Title, requested.Title)
+	again2, err := store.RequestTitleTx(ctx, request)
+	require.NoError(t, err)
+	require.Equal(t, requested.ID, again2.ID)
+	require.Equal(t, int64(4), balance(t, store, db.UserCoinAccount(name)))
+	request.Key = util.RandomString(12)
+	_, err = store.RequestTitleTx(ctx, request)
+	require.Equal(t, db.ErrInsufficientCoins, err)
+	requests, err := store.GetTitleRequests(ctx, db.GetTitleRequestsParams{Limit: 1})
+	require.NoError(t, err)
+	require.Equal(t, requested.ID, requests[0].ID)
+
+	reconciliation, err := store.ReconcileCoins(ctx)
+	require.NoError(t, err)
+	require.True(t, reconciliation.OK(), "%+v", reconciliation)
+	require.NotZero(t, reconciliation.Transactions)
+}
@@ -0,0 +1,362 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"phantom/util"
+)
+
+func addComment(t *testing.T, store db.Store, arg db.AddCommentParams) db.Comments {
+	if arg.Name == "" {
+		arg.Name = util.RandomString(12)
+	}
+	if arg.Text == "" {
+		arg.Text = util.RandomString(20)
+	}
+	id, err := store.AddComment(context.Background(), arg)
+	require.NoError(t, err)
+	comment, err := store.GetComment(context.Background(), id)
+	require.NoError(t, err)
+	return comment
+}
+
+func testComments(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	movieID := addMovie(t, store, randomMovie())
+	name := util.RandomString(12)
+
+	first := addComment(t, store, db.AddCommentParams{Name: name, Email: util.RandomEmail(), MovieID: movieID})
+	second := addComment(t, store, db.AddCommentParams{Name: name, MovieID: movieID})
+	reply := addComment(t, store, db.AddCommentParams{MovieID: movieID, ParentID: first.ID, Depth: 1})
+	require.Equal(t, movieID, first.MovieID)
+	require.NotZero(t, first.Date)
+	_, err := store.AddComment(ctx, db.AddCommentParams{Name: name, MovieID: movieID})
+	require.Error(t, err)
+
+	list := func(arg db.GetCommentsParams) []primitive.ObjectID {
+		arg.MovieID = movieID
+		comments, err := store.GetCommentsByMovieID(ctx, arg)
+		require.NoError(t, err)
+		return commentIDs(comments)
+	}
+	require.Equal(t, []primitive.ObjectID{first.ID, second.ID, reply.ID}, list(db.GetCommentsParams{Sort: db.CommentSortOld}))
+	require.Equal(t, []primitive.ObjectID{reply.ID, second.ID, first.ID}, list(db.GetCommentsParams{Sort: db.CommentSortNew}))
+	require.Equal(t, []primitive.ObjectID{first.ID, second.ID}, list(db.GetCommentsParams{Sort: db.CommentSortOld, TopLevel: true}))
+	require.Equal(t, []primitive.ObjectID{second.ID}, list(db.GetCommentsParams{Sort: db.CommentSortOld, Skip: 1, Limit: 1}))
+
+	_, err = store.AddReaction(ctx, db.CommentReactionParams{CommentID: second.ID, Name: name, Reaction: db.ReactionLike})
+	require.NoError(t, err)
+	require.Equal(t, []primitive.ObjectID{second.ID, reply.ID, first.ID}, list(db.GetCommentsParams{Sort: db.CommentSortTop}))
+
+	byName, err := store.GetCommentsByName(ctx, db.GetCommentsParams{Name: name})
+	require.NoError(t, err)
+	require.Equal(t, []primitive.ObjectID{first.ID, second.ID}, commentIDs(byName))
+
+	// Only the author edits a comment, and an edit that changes nothing is no document
+	edit := db.Comments{ID: second.ID, Name: name, Text: util.RandomString(20), Flags: []string{"spam"}}
+	res, err := store.UpdateComment(ctx, edit)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), res.ModifiedCount)
+	_, err = store.UpdateComment(ctx, edit)
+	require.Equal(t, mongo.ErrNoDocuments, err)
+	edit.Name = util.RandomString(12)
+	edit.Text = util.RandomString(20)
+	_, err = store.UpdateComment(ctx, edit)
+	require.Equal(t, mongo.ErrNoDocuments, err)
+	edited, err := store.GetComment(ctx, second.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"spam"}, edited.Flags)
+
+	// A comment with replies is kept for them
+	deleted, err := store.DeleteComment(ctx, first.ID, name)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deleted)
+	kept, err := store.GetComment(ctx, first.ID)
+	require.NoError(t, err)
+	require.True(t, kept.Deleted)
+	require.Empty(t, kept.Text)
+	require.Empty(t, kept.Email)
+	_, err = store.DeleteComment(ctx, first.ID, name)
+	require.Equal(t, mongo.ErrNoDocuments, err)
+	byName, err = store.GetCommentsByName(ctx, db.GetCommentsParams{Name: name})
+	require.NoError(t, err)
+	require.Equal(t, []primitive.ObjectID{second.ID}, commentIDs(byName))
+
+	// and one without replies is gone, with its reactions
+	_, err = store.DeleteComment(ctx, second.ID, util.RandomString(12))
+	require.Equal(t, mongo.ErrNoDocuments, err)
+	_, err = store.RemoveComment(ctx, second.ID)
+	require.NoError(t, err)
+	_, err = store.GetComment(ctx, second.ID)
+	require.Equal(t, mongo.ErrNoDocuments, err)
+	reactions, err := store.GetReactionsByName(ctx, name, []primitive.ObjectID{second.ID})
+	require.NoError(t, err)
+	require.Empty(t, reactions)
+
+	// A comment kept for its replies stays deleted once they are gone
+	_, err = store.RemoveComment(ctx, reply.ID)
+	require.NoError(t, err)
+	_, err = store.DeleteComment(ctx, first.ID, name)
+	require.Equal(t, mongo.ErrNoDocuments, err)
+	_, err = store.RemoveComment(ctx, first.ID)
+	require.Equal(t, mongo.ErrNoDocuments, err)
+	kept, err = store.GetComment(ctx, first.ID)
+	require.NoError(t, err)
+	require.True(t, kept.Deleted)
+
+	third := addComment(t, store, db.AddCommentParams{Name: name, MovieID: movieID})
+
+	ids, err := store.DeleteCommentsByMovieID(ctx, movieID)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []primitive.ObjectID{first.ID, third.ID}, ids)
+	require.Empty(t, list(db.GetCommentsParams{}))
+	ids, err = store.DeleteCommentsByMovieID(ctx, movieID)
+	require.NoError(t, err)
+	require.Nil(t, ids)
+}
+
+func testReplies(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	movieID := addMovie(t, store, randomMovie())
+	parent := addComment(t, store, db.AddCommentParams{MovieID: movieID})
+	lonely := addComment(t, store, db.AddCommentParams{MovieID: movieID})
+	var replies []primitive.ObjectID
+	for i := 0; i < 3; i++ {
+		reply := addComment(t, store, db.AddCommentParams{MovieID: movieID, ParentID: parent.ID, Depth: 1})
+		replies = append(replies, reply.ID)
+	}
+
+	page, err := store.GetReplies(ctx, db.GetRepliesParams{ParentID: parent.ID, Skip: 1, Limit: 5})
+	require.NoError(t, err)
+	require.Equal(t, replies[1:], commentIDs(page))
+
+	summaries, err := store.GetReplySummaries(ctx, []primitive.ObjectID{parent.ID, lonely.ID}, 2)
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	require.Equal(t, parent.ID, summaries[0].ParentID)
+	require.Equal(t, int64(3), summaries[0].Count)
+	require.Equal(t, replies[:2], commentIDs(summaries[0].Replies))
+}
+
+func testReactions(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	comment := addComment(t, store, db.AddCommentParams{MovieID: addMovie(t, store, randomMovie())})
+	name := util.RandomString(12)
+	like := db.CommentReactionParams{CommentID: comment.ID, Name: name, Reaction: db.ReactionLike}
+
+	added, err := store.AddReaction(ctx, like)
+	require.NoError(t, err)
+	require.True(t, added)
+	// A user leaves each kind of reaction once
+	added, err = store.AddReaction(ctx, like)
+	require.NoError(t, err)
+	require.False(t, added)
+	love := like
+	love.Reaction = db.ReactionLove
+	added, err = store.AddReaction(ctx, love)
+	require.NoError(t, err)
+	require.True(t, added)
+
+	got, err := store.GetComment(ctx, comment.ID)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{db.ReactionLike: 1, db.ReactionLove: 1}, got.Reactions)
+	require.Equal(t, int64(2), got.ReactionCount)
+
+	reactions, err := store.GetReactionsByName(ctx, name, []primitive.ObjectID{comment.ID})
+	require.NoError(t, err)
+	require.Len(t, reactions, 2)
+
+	require.NoError(t, store.DeleteReaction(ctx, like))
+	require.Equal(t, mongo.ErrNoDocuments, store.DeleteReaction(ctx, like))
+	got, err = store.GetComment(ctx, comment.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), got.Reactions[db.ReactionLike])
+	require.Equal(t, int64(1), got.ReactionCount)
+}
+
+func testModeration(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	comment := addComment(t, store, db.AddCommentParams{MovieID: addMovie(t, store, randomMovie())})
+	first, second := util.RandomString(12), util.RandomString(12)
+	report := db.ReportCommentParams{CommentID: comment.ID, Name: first, Reason: db.ReportReasonSpam, HideThreshold: 2}
+
+	reported, err := store.ReportComment(ctx, report)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), reported.ReportCount)
+	require.False(t, reported.Hidden)
+	// A user reports a comment once
+	_, err = store.ReportComment(ctx, report)
+	require.True(t, mongo.IsDuplicateKeyError(err))
+
+	report.Name = second
+	report.Reason = db.ReportReasonAbuse
+	reported, err = store.ReportComment(ctx, report)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{db.ReportReasonSpam: 1, db.ReportReasonAbuse: 1}, reported.Reports)
+	require.True(t, reported.Hidden)
+
+	report.CommentID = primitive.NewObjectID()
+	_, err = store.ReportComment(ctx, report)
+	require.Equal(t, mongo.ErrNoDocuments, err)
+
+	reporters, err := store.GetCommentReporters(ctx, comment.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{first, second}, reporters)
+
+	// Hidden comments come first in the queue
+	queue, err := store.GetModerationQueue(ctx, db.GetModerationQueueParams{Limit: 1000})
+	require.NoError(t, err)
+	require.Contains(t, commentIDs(queue), comment.ID)
+	require.True(t, queue[0].Hidden)
+
+	require.NoError(t, store.ApproveComment(ctx, comment.ID))
+	require.Equal(t, mongo.ErrNoDocuments, store.ApproveComment(ctx, primitive.NewObjectID()))
+	approved, err := store.GetComment(ctx, comment.ID)
+	require.NoError(t, err)
+	require.False(t, approved.Hidden)
+	require.Zero(t, approved.ReportCount)
+	require.Empty(t, approved.Reports)
+	reporters, err = store.GetCommentReporters(ctx, comment.ID)
+	require.NoError(t, err)
+	require.Empty(t, reporters)
+
+	moderator := util.RandomString(12)
+	for _, action := range []string{db.ModerationHide, db.ModerationApprove} {
+		err = store.AddModerationLog(ctx, db.AddModerationLogParams{Moderator: moderator, Action: action, CommentID: comment.ID, Author: comment.Name})
+		require.NoError(t, err)
+	}
+	log, err := store.GetModerationLog(ctx, db.GetModerationLogParams{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, log, 2)
+	require.Equal(t, db.ModerationApprove, log[0].Action)
+	require.Equal(t, moderator, log[0].Moderator)
+}
+
+func testDanmaku(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	movieID := addMovie(t, store, randomMovie())
+	add := func(offset int64, flags []string) db.Danmaku {
+		danmaku, err := store.AddDanmaku(ctx, db.AddDanmakuParams{
+			MovieID: movieID,
+			Offset:  offset,
+			Text:    util.RandomString(10),
+			Color:   "#ffffff",
+			Mode:    db.DanmakuScroll,
+			Name:    util.RandomString(12),
+			Flags:   flags,
+		})
+		require.NoError(t, err)
+		return danmaku
+	}
+	// Three danmaku in the first second, where two show
+	a := add(100, nil)
+	b := add(200, nil)
+	c := add(300, nil)
+	d := add(1500, nil)
+	flagged := add(1600, []string{"spam"})
+	add(5000, nil)
+
+	get := func() []primitive.ObjectID {
+		danmaku, err := store.GetDanmaku(ctx, db.GetDanmakuParams{MovieID: movieID, From: 0, To: 2000, Bucket: 1000, PerBucket: 2})
+		require.NoError(t, err)
+		ids := make([]primitive.ObjectID, len(danmaku))
+		for i, d := range danmaku {
+			ids[i] = d.ID
+		}
+		return ids
+	}
+	require.Equal(t, []primitive.ObjectID{b.ID, c.ID, d.ID}, get())
+
+	// A pinned danmaku shows before the latest ones
+	name := util.RandomString(12)
+	grant(t, store, name, 10)
+	pinned, err := store.PinDanmakuTx(ctx, db.PinDanmakuTxParams{DanmakuID: a.ID, Name: name, Cost: 4})
+	require.NoError(t, err)
+	require.True(t, pinned.Danmaku.Pinned)
+	require.Equal(t, a.Text, pinned.Danmaku.Text)
+	require.Equal(t, []primitive.ObjectID{a.ID, c.ID, d.ID}, get())
+	require.Equal(t, int64(6), balance(t, store, db.UserCoinAccount(name)))
+
+	_, err = store.PinDanmakuTx(ctx, db.PinDanmakuTxParams{DanmakuID: a.ID, Name: name, Cost: 4})
+	require.Equal(t, db.ErrDanmakuPinned, err)
+	_, err = store.PinDanmakuTx(ctx, db.PinDanmakuTxParams{DanmakuID: b.ID, Name: name, Cost: 40})
+	require.Equal(t, db.ErrInsufficientCoins, err)
+	_, err = store.PinDanmakuTx(ctx, db.PinDanmakuTxParams{DanmakuID: primitive.NewObjectID(), Name: name, Cost: 4})
+	require.Equal(t, mongo.ErrNoDocuments, err)
+	require.Equal(t, []primitive.ObjectID{a.ID, c.ID, d.ID}, get())
+	require.Equal(t, int64(6), balance(t, store, db.UserCoinAccount(name)))
+
+	// The flagged danmaku wait for review, and show once approved
+	queued := func() []primitive.ObjectID {
+		danmaku, err := store.GetDanmakuModerationQueue(ctx, db.GetModerationQueueParams{Limit: 100})
+		require.NoError(t, err)
+		ids := make([]primitive.ObjectID, len(danmaku))
+		for i, d := range danmaku {
+			ids[i] = d.ID
+		}
+		return ids
+	}
+	require.Contains(t, queued(), flagged.ID)
+	approved, err := store.ApproveDanmaku(ctx, flagged.ID)
+	require.NoError(t, err)
+	require.Empty(t, approved.Flags)
+	require.Equal(t, flagged.Text, approved.Text)
+	require.NotContains(t, queued(), flagged.ID)
+	require.Equal(t, []primitive.ObjectID{a.ID, c.ID, d.ID, flagged.ID}, get())
+
+	removed, err := store.RemoveDanmaku(ctx, d.ID)
+	require.NoError(t, err)
+	require.Equal(t, d.Name, removed.Name)
+	require.Equal(t, []primitive.ObjectID{a.ID, c.ID, flagged.ID}, get())
+	_, err = store.RemoveDanmaku(ctx, d.ID)
+	require.Equal(t, mongo.ErrNoDocuments, err)
+	_, err = store.ApproveDanmaku(ctx, d.ID)
+	require.Equal(t, mongo.ErrNoDocuments, err)
+}
+
+func testLedger(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	movieID := addMovie(t, store, randomMovie())
+	comment := addComment(t, store, db.AddCommentParams{MovieID: movieID})
+	_, err := store.GetLedgerProof(ctx, primitive.NewObjectID())
+	require.Equal(t, mongo.ErrNoDocuments, err)
+
+	// The proof comes once the entry is checkpointed, which more entries do
+	for i := 0; ; i++ {
+		proof, err := store.GetLedgerProof(ctx, comment.ID)
+		if err == db.ErrNotCheckpointed && i < db.LedgerCheckpointInterval {
+			addComment(t, store, db.AddCommentParams{MovieID: movieID})
+			continue
+		}
+		require.NoError(t, err)
+		require.NoError(t, proof.Verify())
+		require.Equal(t, comment.ID.Hex(), proof.Entry.Ref)
+		require.Equal(t, db.CommentDigest(comment), proof.Entry.Digest)
+		break
+	}
+
+	// The latest entries are checkpointed on demand, and the next
+	// checkpoints follow theirs
+	latest := addComment(t, store, db.AddCommentParams{MovieID: movieID})
+	_, err = store.GetLedgerProof(ctx, latest.ID)
+	require.Equal(t, db.ErrNotCheckpointed, err)
+	require.NoError(t, store.CheckpointLedger(ctx))
+	require.NoError(t, store.CheckpointLedger(ctx))
+	proof, err := store.GetLedgerProof(ctx, latest.ID)
+	require.NoError(t, err)
+	require.NoError(t, proof.Verify())
+	require.Equal(t, proof.Entry.Seq+1, proof.Checkpoint.To)
+
+	var next db.Comments
+	for i := 0; i < db.LedgerCheckpointInterval; i++ {
+		next = addComment(t, store, db.AddCommentParams{MovieID: movieID})
+	}
+	proof, err = store.GetLedgerProof(ctx, next.ID)
+	require.NoError(t, err)
+	require.Equal(t, proof.Entry.Seq-db.LedgerCheckpointInterval+1, proof.Checkpoint.From)
+}
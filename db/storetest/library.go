@@ -0,0 +1,353 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"phantom/util"
+)
+
+func testPeople(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	prefix := util.RandomString(12)
+	name := prefix + " Smith"
+	id, err := store.AddPerson(ctx, db.AddPersonParams{Name: name, Aliases: []string{util.RandomString(12)}})
+	require.NoError(t, err)
+	other, err := store.AddPerson(ctx, db.AddPersonParams{Name: prefix + " Jones"})
+	require.NoError(t, err)
+
+	person, err := store.GetPersonByID(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, name, person.Name)
+	_, err = store.GetPersonByID(ctx, primitive.NewObjectID())
+	require.Equal(t, mongo.ErrNoDocuments, err)
+	people, err := store.GetPeopleByIDs(ctx, []primitive.ObjectID{id, other})
+	require.NoError(t, err)
+	require.Len(t, people, 2)
+
+	// Names and aliases are searched ignoring case
+	search := func(text string) []primitive.ObjectID {
+		people, err := store.SearchPeople(ctx, db.SearchPeopleParams{Text: text, Limit: 10})
+		require.NoError(t, err)
+		ids := make([]primitive.ObjectID, len(people))
+		for i, person := range people {
+			ids[i] = person.ID
+		}
+		return ids
+	}
+	require.Equal(t, []primitive.ObjectID{other, id}, search(prefix))
+	require.Equal(t, []primitive.ObjectID{id}, search(person.Aliases[0][2:]))
+
+	older := randomMovie()
+	older.Year = 1990
+	older.Cast = []string{name, util.RandomString(12)}
+	older.Credits = []db.Credit{{PersonID: id, Role: db.RoleCast}}
+	olderID := addMovie(t, store, older)
+	newer := randomMovie()
+	newer.Year = 2000
+	newer.Directors = []string{name}
+	newer.Credits = []db.Credit{{PersonID: id, Role: db.RoleDirector}}
+	newerID := addMovie(t, store, newer)
+	movies, err := store.GetMoviesByPersonID(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, []primitive.ObjectID{newerID, olderID}, movieIDs(movies))
+
+	// A new name is written to the movies too
+	person.Name = util.RandomString(12)
+	_, err = store.UpdatePerson(ctx, person)
+	require.NoError(t, err)
+	require.Equal(t, []string{person.Name, older.Cast[1]}, getMovie(t, store, olderID).Cast)
+	require.Equal(t, []string{person.Name}, getMovie(t, store, newerID).Directors)
+	_, err = store.UpdatePerson(ctx, db.Person{ID: primitive.NewObjectID(), Name: person.Name})
+	require.Equal(t, mongo.ErrNoDocuments, err)
+}
+
+func testSeries(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	series := randomMovie()
+	series.Type = db.TypeSeries
+	seriesID := addMovie(t, store, series)
+
+	for _, number := range []int64{2, db.SpecialsSeason, 1} {
+		_, err := store.AddSeason(ctx, db.AddSeasonParams{SeriesID: seriesID, Number: number})
+		require.NoError(t, err)
+	}
+	_, err := store.AddSeason(ctx, db.AddSeasonParams{SeriesID: seriesID, Number: 1})
+	require.True(t, mongo.IsDuplicateKeyError(err))
+	seasons, err := store.GetSeasonsBySeriesID(ctx, seriesID)
+	require.NoError(t, err)
+	require.Len(t, seasons, 3)
+	for i, season := range seasons {
+		require.Equal(t, int64(i), season.Number)
+	}
+
+	add := func(season, number, absolute int64) primitive.ObjectID {
+		id, err := store.AddEpisode(ctx, db.AddEpisodeParams{SeriesID: seriesID, Season: season, Number: number, AbsoluteNumber: absolute})
+		require.NoError(t, err)
+		return id
+	}
+	s2e1 := add(2, 1, 3)
+	s1e2 := add(1, 2, 2)
+	s1e1 := add(1, 1, 1)
+	special := add(db.SpecialsSeason, 1, 0)
+	_, err = store.AddEpisode(ctx, db.AddEpisodeParams{SeriesID: seriesID, Season: 1, Number: 1})
+	require.True(t, mongo.IsDuplicateKeyError(err))
+
+	episodes := func(arg db.GetEpisodesParams) []primitive.ObjectID {
+		arg.SeriesID = seriesID
+		episodes, err := store.GetEpisodes(ctx, arg)
+		require.NoError(t, err)
+		ids := make([]primitive.ObjectID, len(episodes))
+		for i, episode := range episodes {
+			ids[i] = episode.ID
+		}
+		return ids
+	}
+	season := int64(1)
+	require.Equal(t, []primitive.ObjectID{special, s1e1, s1e2, s2e1}, episodes(db.GetEpisodesParams{Order: db.EpisodeOrderAired}))
+	require.Equal(t, []primitive.ObjectID{s1e1, s1e2, s2e1}, episodes(db.GetEpisodesParams{Order: db.EpisodeOrderAbsolute}))
+	require.Equal(t, []primitive.ObjectID{s1e1, s1e2}, episodes(db.GetEpisodesParams{Season: &season}))
+
+	episode, err := store.GetEpisodeByID(ctx, s1e2)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), episode.Number)
+	_, err = store.GetEpisodeByID(ctx, primitive.NewObjectID())
+	require.Equal(t, mongo.ErrNoDocuments, err)
+	found, err := store.GetEpisodesByIDs(ctx, []primitive.ObjectID{s1e1, s2e1})
+	require.NoError(t, err)
+	require.Len(t, found, 2)
+}
+
+func testProgress(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	name := util.RandomString(12)
+	movie := randomMovie()
+	movie.Runtime = 90
+	movie.Genres = []string{util.RandomString(12)}
+	movieID := addMovie(t, store, movie)
+	seriesID := primitive.NewObjectID()
+	first, second := primitive.NewObjectID(), primitive.NewObjectID()
+
+	save := func(arg db.SaveProgressParams) {
+		arg.Name = name
+		require.NoError(t, store.SaveProgress(ctx, arg))
+		// so that the times of the saves differ
+		time.Sleep(2 * time.Millisecond)
+	}
+	save(db.SaveProgressParams{MediaID: first, SeriesID: seriesID, Position: 100, Duration: 1200})
+	save(db.SaveProgressParams{MediaID: movieID, Position: 60, Duration: 5400})
+	save(db.SaveProgressParams{MediaID: second, SeriesID: seriesID, Position: 50, Duration: 1200})
+
+	// A series comes once, with the episode watched last
+	continueWatching := func() []primitive.ObjectID {
+		progress, err := store.GetContinueWatching(ctx, db.GetContinueWatchingParams{Name: name, Limit: 10})
+		require.NoError(t, err)
+		ids := make([]primitive.ObjectID, len(progress))
+		for i, p := range progress {
+			ids[i] = p.MediaID
+		}
+		return ids
+	}
+	require.Equal(t, []primitive.ObjectID{second, movieID}, continueWatching())
+	progress, err := store.GetProgressBySeriesID(ctx, name, seriesID)
+	require.NoError(t, err)
+	require.Len(t, progress, 2)
+	require.Equal(t, second, progress[0].MediaID)
+
+	// Watching to the end adds to the history, once
+	save(db.SaveProgressParams{MediaID: movieID, Position: 5400, Duration: 5400, Watched: true})
+	save(db.SaveProgressParams{MediaID: movieID, Position: 5400, Duration: 5400, Watched: true})
+	require.Equal(t, []primitive.ObjectID{second}, continueWatching())
+	progress, err = store.GetProgressByMediaIDs(ctx, name, []primitive.ObjectID{movieID, primitive.NewObjectID()})
+	require.NoError(t, err)
+	require.Len(t, progress, 1)
+	require.True(t, progress[0].Watched)
+
+	history, err := store.GetHistory(ctx, db.GetHistoryParams{Name: name, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	require.Equal(t, movieID, history[0].MediaID)
+
+	stats, err := store.GetUserStats(ctx, db.GetUserStatsParams{Name: name, Top: 3})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), stats.Movies)
+	require.Zero(t, stats.Episodes)
+	require.Equal(t, 1.5, stats.Hours)
+	require.Equal(t, []db.StatsCount{{Name: movie.Genres[0], Count: 1}}, stats.TopGenres)
+	require.Equal(t, int64(1), stats.Streak.Current)
+
+	require.NoError(t, store.DeleteHistoryEntry(ctx, name, history[0].ID))
+	require.Equal(t, mongo.ErrNoDocuments, store.DeleteHistoryEntry(ctx, name, history[0].ID))
+	history, err = store.GetHistory(ctx, db.GetHistoryParams{Name: name, Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, history)
+}
+
+func testCollections(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	genre := util.RandomString(12)
+	add := func(year int64, rating float64) db.Movies {
+		movie := randomMovie()
+		movie.Genres = []string{genre}
+		movie.Year = year
+		movie.Imdb.Rating = rating
+		return getMovie(t, store, addMovie(t, store, movie))
+	}
+	older := add(1990, 9)
+	newer := add(2000, 7)
+	other := getMovie(t, store, addMovie(t, store, randomMovie()))
+
+	curatedID, err := store.AddCollection(ctx, db.AddCollectionParams{
+		Title:    "a" + util.RandomString(12),
+		MovieIDs: []primitive.ObjectID{newer.Id, primitive.NewObjectID(), older.Id},
+	})
+	require.NoError(t, err)
+	smartID, err := store.AddCollection(ctx, db.AddCollectionParams{
+		Title:  "b" + util.RandomString(12),
+		Filter: &db.CollectionFilter{Genres: []string{genre}},
+	})
+	require.NoError(t, err)
+	curated, err := store.GetCollectionByID(ctx, curatedID)
+	require.NoError(t, err)
+	smart, err := store.GetCollectionByID(ctx, smartID)
+	require.NoError(t, err)
+	require.True(t, smart.Smart())
+
+	// A curated collection keeps its order and leaves out missing movies,
+	// a smart one is in the order of its sort
+	movies := func(collection db.Collection, skip, limit int64) []primitive.ObjectID {
+		movies, err := store.GetCollectionMovies(ctx, db.GetCollectionMoviesParams{Collection: collection, Skip: skip, Limit: limit})
+		require.NoError(t, err)
+		return movieIDs(movies)
+	}
+	require.Equal(t, []primitive.ObjectID{newer.Id, older.Id}, movies(curated, 0, 0))
+	require.Equal(t, []primitive.ObjectID{older.Id}, movies(curated, 1, 2))
+	require.Equal(t, []primitive.ObjectID{older.Id, newer.Id}, movies(smart, 0, 10))
+	require.Equal(t, []primitive.ObjectID{newer.Id}, movies(smart, 1, 10))
+	smart.Filter.Sort = db.CollectionSortRating
+	smart.Filter.MinRating = 8
+	require.Equal(t, []primitive.ObjectID{older.Id}, movies(smart, 0, 10))
+
+	collectionIDs := func(movie db.Movies) []primitive.ObjectID {
+		collections, err := store.GetCollectionsByMovie(ctx, movie)
+		require.NoError(t, err)
+		ids := make([]primitive.ObjectID, len(collections))
+		for i, collection := range collections {
+			ids[i] = collection.ID
+		}
+		return ids
+	}
+	require.Subset(t, collectionIDs(newer), []primitive.ObjectID{curatedID, smartID})
+	require.NotContains(t, collectionIDs(other), curatedID)
+	require.NotContains(t, collectionIDs(other), smartID)
+
+	// Replacing turns a smart collection into a curated one
+	smart.Filter = nil
+	smart.MovieIDs = []primitive.ObjectID{other.Id}
+	_, err = store.ReplaceCollection(ctx, smart)
+	require.NoError(t, err)
+	require.Contains(t, collectionIDs(other), smartID)
+	require.NotContains(t, collectionIDs(newer), smartID)
+	_, err = store.ReplaceCollection(ctx, db.Collection{ID: primitive.NewObjectID(), Title: "gone"})
+	require.Equal(t, mongo.ErrNoDocuments, err)
+
+	collections, err := store.GetCollections(ctx, db.GetCollectionsParams{})
+	require.NoError(t, err)
+	require.Subset(t, collectionIDsOf(collections), []primitive.ObjectID{curatedID, smartID})
+
+	deleted, err := store.DeleteCollection(ctx, curatedID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deleted)
+	_, err = store.GetCollectionByID(ctx, curatedID)
+	require.Equal(t, mongo.ErrNoDocuments, err)
+}
+
+func collectionIDsOf(collections []db.Collection) []primitive.ObjectID {
+	ids := make([]primitive.ObjectID, len(collections))
+	for i, collection := range collections {
+		ids[i] = collection.ID
+	}
+	return ids
+}
+
+func testLists(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	name := util.RandomString(12)
+	var ids []primitive.ObjectID
+	for i := 0; i < 3; i++ {
+		id := addMovie(t, store, randomMovie())
+		res, err := store.AddListEntry(ctx, db.ListEntryParams{Name: name, List: db.ListWatchlist, MovieID: id})
+		require.NoError(t, err)
+		require.Equal(t, int64(1), res.UpsertedCount)
+		ids = append(ids, id)
+	}
+	// A movie that is in the list already keeps its place
+	res, err := store.AddListEntry(ctx, db.ListEntryParams{Name: name, List: db.ListWatchlist, MovieID: ids[0]})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), res.MatchedCount)
+	require.Zero(t, res.UpsertedCount)
+	_, err = store.AddListEntry(ctx, db.ListEntryParams{Name: name, List: db.ListFavorites, MovieID: ids[0]})
+	require.NoError(t, err)
+
+	entries := func(skip, limit int64) []primitive.ObjectID {
+		entries, err := store.GetListEntries(ctx, db.GetListParams{Name: name, List: db.ListWatchlist, Skip: skip, Limit: limit})
+		require.NoError(t, err)
+		movieIDs := make([]primitive.ObjectID, len(entries))
+		for i, entry := range entries {
+			movieIDs[i] = entry.MovieID
+		}
+		return movieIDs
+	}
+	require.Equal(t, []primitive.ObjectID{ids[2], ids[1], ids[0]}, entries(0, 10))
+	require.Equal(t, []primitive.ObjectID{ids[1]}, entries(1, 1))
+
+	// The reordered movies swap places among themselves
+	require.NoError(t, store.ReorderList(ctx, db.ReorderListParams{Name: name, List: db.ListWatchlist, MovieIDs: []primitive.ObjectID{ids[0], ids[2]}}))
+	require.Equal(t, []primitive.ObjectID{ids[0], ids[1], ids[2]}, entries(0, 10))
+	err = store.ReorderList(ctx, db.ReorderListParams{Name: name, List: db.ListWatchlist, MovieIDs: []primitive.ObjectID{ids[1], primitive.NewObjectID()}})
+	require.Equal(t, mongo.ErrNoDocuments, err)
+	err = store.ReorderList(ctx, db.ReorderListParams{Name: name, List: db.ListWatchlist, MovieIDs: []primitive.ObjectID{ids[1], ids[1]}})
+	require.Equal(t, mongo.ErrNoDocuments, err)
+	require.Equal(t, []primitive.ObjectID{ids[0], ids[1], ids[2]}, entries(0, 10))
+
+	lists, err := store.GetListsByMovie(ctx, name, ids[0])
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{db.ListWatchlist, db.ListFavorites}, lists)
+
+	deleted, err := store.DeleteListEntry(ctx, db.ListEntryParams{Name: name, List: db.ListWatchlist, MovieID: ids[0]})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deleted)
+	_, err = store.DeleteListEntry(ctx, db.ListEntryParams{Name: name, List: db.ListWatchlist, MovieID: ids[0]})
+	require.Equal(t, mongo.ErrNoDocuments, err)
+	lists, err = store.GetListsByMovie(ctx, name, ids[0])
+	require.NoError(t, err)
+	require.Equal(t, []string{db.ListFavorites}, lists)
+}
+
+func testRatings(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	movieID := addMovie(t, store, randomMovie())
+	alice, bob := util.RandomString(12), util.RandomString(12)
+
+	require.NoError(t, store.RateMovie(ctx, db.RateMovieParams{Name: alice, MovieID: movieID, Score: 6}))
+	require.NoError(t, store.RateMovie(ctx, db.RateMovieParams{Name: bob, MovieID: movieID, Score: 9}))
+	require.Equal(t, db.CommunityRating{Rating: 7.5, Count: 2, Sum: 15}, getMovie(t, store, movieID).Community)
+
+	// Rating again replaces the score
+	require.NoError(t, store.RateMovie(ctx, db.RateMovieParams{Name: alice, MovieID: movieID, Score: 3}))
+	require.Equal(t, db.CommunityRating{Rating: 6, Count: 2, Sum: 12}, getMovie(t, store, movieID).Community)
+	rating, err := store.GetRating(ctx, alice, movieID)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), rating.Score)
+
+	require.NoError(t, store.DeleteRating(ctx, alice, movieID))
+	require.Equal(t, mongo.ErrNoDocuments, store.DeleteRating(ctx, alice, movieID))
+	_, err = store.GetRating(ctx, alice, movieID)
+	require.Equal(t, mongo.ErrNoDocuments, err)
+	require.Equal(t, db.CommunityRating{Rating: 9, Count: 1, Sum: 9}, getMovie(t, store, movieID).Community)
+}
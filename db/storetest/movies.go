@@ -0,0 +1,268 @@
+package storetest
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"phantom/util"
+)
+
+func randomMovie() db.AddMovieParams {
+	date := util.RandomDate()
+	movie := db.AddMovieParams{
+		Runtime:  util.RandomInt(1, 100),
+		Title:    util.RandomString(12),
+		Released: primitive.NewDateTimeFromTime(date),
+		Genres:   util.RandomGenres(),
+		Year:     int64(date.Year()),
+	}
+	movie.Imdb.Rating = util.Randomfloat(1, 10)
+	return movie
+}
+
+func addMovie(t *testing.T, store db.Store, movie db.AddMovieParams) primitive.ObjectID {
+	id, err := store.AddMovie(context.Background(), movie)
+	require.NoError(t, err)
+	require.False(t, id.IsZero())
+	return id
+}
+
+func getMovie(t *testing.T, store db.Store, id primitive.ObjectID) db.Movies {
+	movie, err := store.GetMovieByID(context.Background(), id)
+	require.NoError(t, err)
+	return movie
+}
+
+func testMovies(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	arg := randomMovie()
+	arg.Title = "The " + arg.Title
+	arg.Directors = []string{util.RandomString(12)}
+	arg.Titles = []db.LocalizedTitle{{Locale: "ja", Title: util.RandomString(12)}}
+	id := addMovie(t, store, arg)
+
+	movie := getMovie(t, store, id)
+	require.Equal(t, id, movie.Id)
+	require.Equal(t, arg.Title, movie.Title)
+	require.Equal(t, util.SortTitle(arg.Title), movie.SortTitle)
+	require.Equal(t, arg.Genres, movie.Genres)
+	require.Equal(t, arg.Directors, movie.Directors)
+	require.Equal(t, arg.Titles, movie.Titles)
+	require.Equal(t, arg.Released, movie.Released)
+	_, err := store.GetMovieByID(ctx, primitive.NewObjectID())
+	require.Equal(t, mongo.ErrNoDocuments, err)
+
+	// A movie needs a title
+	_, err = store.AddMovie(ctx, db.AddMovieParams{Runtime: 10})
+	require.Error(t, err)
+	_, err = store.AddMovies(ctx, nil)
+	require.Equal(t, mongo.ErrEmptySlice, err)
+	ids, err := store.AddMovies(ctx, []db.AddMovieParams{randomMovie(), randomMovie()})
+	require.NoError(t, err)
+	require.Len(t, ids, 2)
+
+	found, err := store.GetMoviesByIDs(ctx, []primitive.ObjectID{id, ids[0].(primitive.ObjectID), primitive.NewObjectID()})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []primitive.ObjectID{id, ids[0].(primitive.ObjectID)}, movieIDs(found))
+
+	all, err := store.GetAllMovies(ctx)
+	require.NoError(t, err)
+	require.Subset(t, movieIDs(all), []primitive.ObjectID{id, ids[0].(primitive.ObjectID), ids[1].(primitive.ObjectID)})
+
+	// Replacing keeps the id and the community rating
+	require.NoError(t, store.RateMovie(ctx, db.RateMovieParams{Name: util.RandomString(12), MovieID: id, Score: 8}))
+	replacement := getMovie(t, store, id)
+	replacement.Id = primitive.NewObjectID()
+	replacement.Title = util.RandomString(12)
+	replacement.SortTitle = ""
+	replacement.Community = db.CommunityRating{}
+	res, err := store.ReplaceMovieInfoByID(ctx, id, replacement)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), res.ModifiedCount)
+	replaced := getMovie(t, store, id)
+	require.Equal(t, id, replaced.Id)
+	require.Equal(t, replacement.Title, replaced.Title)
+	require.Equal(t, util.SortTitle(replacement.Title), replaced.SortTitle)
+	require.Equal(t, db.CommunityRating{Rating: 8, Count: 1, Sum: 8}, replaced.Community)
+	// and nothing changed is no document
+	_, err = store.ReplaceMovieInfoByID(ctx, id, replacement)
+	require.Equal(t, mongo.ErrNoDocuments, err)
+	_, err = store.ReplaceMovieInfoByID(ctx, primitive.NewObjectID(), replacement)
+	require.Equal(t, mongo.ErrNoDocuments, err)
+
+	require.NoError(t, store.IncrementMovieComments(ctx, id, 2))
+	require.Equal(t, int64(2), getMovie(t, store, id).NumMflixComments)
+	require.Equal(t, mongo.ErrNoDocuments, store.IncrementMovieComments(ctx, primitive.NewObjectID(), 1))
+	// The number of comments survives a replacement that leaves it out
+	replacement.Title = util.RandomString(12)
+	replacement.NumMflixComments = 0
+	_, err = store.ReplaceMovieInfoByID(ctx, id, replacement)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), getMovie(t, store, id).NumMflixComments)
+
+	deleted, err := store.DeleteMovieByID(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deleted)
+	_, err = store.DeleteMovieByID(ctx, id)
+	require.Equal(t, mongo.ErrNoDocuments, err)
+
+	mostViewed, err := store.GetTheMostViewedMovies(ctx, db.GetMoviesParams{Limit: 10})
+	require.NoError(t, err)
+	require.NotEmpty(t, mostViewed)
+	require.True(t, sort.SliceIsSorted(mostViewed, func(i, j int) bool { return mostViewed[i].Runtime > mostViewed[j].Runtime }))
+	latest, err := store.GetTheLatestReleasedMovies(ctx, db.GetMoviesParams{Limit: 10})
+	require.NoError(t, err)
+	require.NotEmpty(t, latest)
+	require.True(t, sort.SliceIsSorted(latest, func(i, j int) bool { return latest[i].Released > latest[j].Released }))
+	// The lists are aggregations, which need a limit
+	_, err = store.GetTheMostViewedMovies(ctx, db.GetMoviesParams{})
+	require.Error(t, err)
+}
+
+func testSearchForMovies(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	word, other := util.RandomString(12), util.RandomString(12)
+
+	inTitle := randomMovie()
+	inTitle.Title = word
+	inTitleID := addMovie(t, store, inTitle)
+	// A word counts for less in a longer text
+	inCast := randomMovie()
+	inCast.Cast = []string{word + " " + other + " " + util.RandomString(12)}
+	inCastID := addMovie(t, store, inCast)
+
+	search := func(text string, skip, limit int64) []primitive.ObjectID {
+		movies, err := store.SearchForMovies(ctx, db.SearchForMoviesParams{Text: text, Skip: skip, Limit: limit})
+		require.NoError(t, err)
+		return movieIDs(movies)
+	}
+	require.Equal(t, []primitive.ObjectID{inTitleID, inCastID}, search(word, 0, 10))
+	require.Equal(t, []primitive.ObjectID{inCastID}, search(word, 1, 10))
+	require.Equal(t, []primitive.ObjectID{inTitleID}, search(word, 0, 1))
+	require.Equal(t, []primitive.ObjectID{inCastID}, search(other, 0, 10))
+	require.Equal(t, []primitive.ObjectID{inTitleID}, search(word+" -"+other, 0, 10))
+	require.Equal(t, []primitive.ObjectID{inCastID}, search(`"`+word+" "+other+`"`, 0, 10))
+	require.Empty(t, search(util.RandomString(12), 0, 10))
+
+	movies, err := store.SearchForMovies(ctx, db.SearchForMoviesParams{Text: word, Limit: 10})
+	require.NoError(t, err)
+	// The results only have the fields a list shows
+	require.Equal(t, inTitle.Title, movies[0].Title)
+	require.Equal(t, inTitle.Genres, movies[0].Genres)
+
+	_, err = store.SearchForMovies(ctx, db.SearchForMoviesParams{Text: word})
+	require.Error(t, err)
+}
+
+func testGetMoviesByGenres(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	genre := util.RandomString(12)
+	now := time.Now()
+
+	older := randomMovie()
+	older.Genres = []string{genre, "Drama"}
+	older.Title = "a" + util.RandomString(12)
+	older.Runtime = 50
+	older.Imdb.Rating = 5
+	older.Released = primitive.NewDateTimeFromTime(now.AddDate(0, 0, -10))
+	older.Directors = []string{util.RandomString(12)}
+	olderID := addMovie(t, store, older)
+
+	newer := randomMovie()
+	newer.Genres = []string{genre}
+	newer.Title = "b" + util.RandomString(12)
+	newer.Runtime = 100
+	newer.Imdb.Rating = 8
+	newer.Released = primitive.NewDateTimeFromTime(now.AddDate(0, 0, -5))
+	newerID := addMovie(t, store, newer)
+
+	// The movies of more than a year ago are left out
+	old := randomMovie()
+	old.Genres = []string{genre}
+	old.Released = primitive.NewDateTimeFromTime(now.AddDate(-2, 0, 0))
+	addMovie(t, store, old)
+
+	list := func(sortOptions string, skip int64) []db.Movies {
+		movies, err := store.GetMoviesByGenres(ctx, db.GetMoviesParams{Genres: genre, SortOptions: sortOptions, Skip: skip, Limit: 10})
+		require.NoError(t, err)
+		return movies
+	}
+	require.Equal(t, []primitive.ObjectID{newerID, olderID}, movieIDs(list("", 0)))
+	require.Equal(t, []primitive.ObjectID{newerID, olderID}, movieIDs(list("sort_time", 0)))
+	require.Equal(t, []primitive.ObjectID{newerID, olderID}, movieIDs(list("sort_rating", 0)))
+	require.Equal(t, []primitive.ObjectID{olderID, newerID}, movieIDs(list("sort_title", 0)))
+	require.Equal(t, []primitive.ObjectID{olderID}, movieIDs(list("", 1)))
+
+	require.NoError(t, store.RateMovie(ctx, db.RateMovieParams{Name: util.RandomString(12), MovieID: olderID, Score: 9}))
+	require.Equal(t, []primitive.ObjectID{olderID, newerID}, movieIDs(list("sort_community", 0)))
+
+	// Lists leave out the fields they don't show
+	movies := list("sort_title", 0)
+	require.Equal(t, older.Title, movies[0].Title)
+	require.Empty(t, movies[0].Directors)
+}
+
+func testUpsertMovies(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	_, err := store.UpsertMovies(ctx, nil)
+	require.Equal(t, mongo.ErrEmptySlice, err)
+
+	var byImdb db.Movies
+	byImdb.Id = primitive.NewObjectID()
+	byImdb.Title = util.RandomString(12)
+	byImdb.Imdb.Id = util.RandomInt(1, 1<<40)
+	byImdb.Imdb.Rating = 7.5
+	byTitle := db.Movies{Title: util.RandomString(12), Year: 1999, Genres: []string{"Drama"}, NumMflixComments: 3}
+
+	res, err := store.UpsertMovies(ctx, []db.Movies{byImdb, byTitle})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), res.UpsertedCount)
+	require.Equal(t, byImdb.Id, res.UpsertedIDs[0])
+	added := getMovie(t, store, byImdb.Id)
+	require.Equal(t, byImdb.Title, added.Title)
+	require.Equal(t, util.SortTitle(byImdb.Title), added.SortTitle)
+	byTitle.Id = res.UpsertedIDs[1].(primitive.ObjectID)
+	require.Equal(t, int64(3), getMovie(t, store, byTitle.Id).NumMflixComments)
+
+	// Upserting the same movies again changes nothing
+	res, err = store.UpsertMovies(ctx, []db.Movies{byImdb, byTitle})
+	require.NoError(t, err)
+	require.Equal(t, int64(0), res.UpsertedCount)
+	require.Equal(t, int64(2), res.MatchedCount)
+	require.Equal(t, int64(0), res.ModifiedCount)
+
+	// The fields a movie has replace the ones of the movie it matches,
+	// even in subdocuments, and the others are kept
+	var update db.Movies
+	update.Imdb.Id = byImdb.Imdb.Id
+	update.Imdb.Votes = 100
+	update.Plot = util.RandomString(20)
+	// but the number of comments, which the server counts once a movie is added
+	res, err = store.UpsertMovies(ctx, []db.Movies{update, {Title: byTitle.Title, Year: byTitle.Year, Runtime: 90, NumMflixComments: 10}})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), res.ModifiedCount)
+	updated := getMovie(t, store, byImdb.Id)
+	require.Equal(t, byImdb.Title, updated.Title)
+	require.Equal(t, byImdb.Imdb.Rating, updated.Imdb.Rating)
+	require.Equal(t, int64(100), updated.Imdb.Votes)
+	require.Equal(t, update.Plot, updated.Plot)
+	updated = getMovie(t, store, byTitle.Id)
+	require.Equal(t, byTitle.Genres, updated.Genres)
+	require.Equal(t, int64(90), updated.Runtime)
+	require.Equal(t, int64(3), updated.NumMflixComments)
+
+	// The movies are written unordered, and the ones that fail are reported by index
+	other := db.Movies{Title: util.RandomString(12), Year: 2001}
+	res, err = store.UpsertMovies(ctx, []db.Movies{{Year: 1999}, other})
+	var bulkErr mongo.BulkWriteException
+	require.ErrorAs(t, err, &bulkErr)
+	require.Len(t, bulkErr.WriteErrors, 1)
+	require.Equal(t, 0, bulkErr.WriteErrors[0].Index)
+	require.Equal(t, int64(1), res.UpsertedCount)
+}
@@ -0,0 +1,177 @@
+// Package storetest checks that a db.Store answers every query the way the
+// MongoDB one does, so that the backends can stand in for each other.
+// Run is called by the tests of each backend. The data it adds has random
+// names, so that it can run against a database that has data already
+package storetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	db "phantom/db/mongo"
+	"phantom/util"
+)
+
+// Run runs the conformance tests against the store
+func Run(t *testing.T, store db.Store) {
+	for _, test := range []struct {
+		name string
+		fn   func(t *testing.T, store db.Store)
+	}{
+		{"Users", testUsers},
+		{"ExecTx", testExecTx},
+		{"Movies", testMovies},
+		{"UpsertMovies", testUpsertMovies},
+		{"SearchForMovies", testSearchForMovies},
+		{"GetMoviesByGenres", testGetMoviesByGenres},
+		{"Comments", testComments},
+		{"Replies", testReplies},
+		{"Reactions", testReactions},
+		{"Moderation", testModeration},
+		{"Danmaku", testDanmaku},
+		{"Ledger", testLedger},
+		{"Coins", testCoins},
+		{"People", testPeople},
+		{"Series", testSeries},
+		{"Progress", testProgress},
+		{"Collections", testCollections},
+		{"Lists", testLists},
+		{"Ratings", testRatings},
+	} {
+		test := test
+		t.Run(test.name, func(t *testing.T) { test.fn(t, store) })
+	}
+}
+
+func addUser(t *testing.T, store db.Store) db.User {
+	arg := db.AddUserParams{
+		Name:     util.RandomString(12),
+		Password: util.RandomString(12),
+		Email:    util.RandomString(12) + "@vmail.com",
+	}
+	id, err := store.AddUser(context.Background(), arg)
+	require.NoError(t, err)
+	user, err := store.GetUserByID(context.Background(), id)
+	require.NoError(t, err)
+	return user
+}
+
+func testUsers(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	user := addUser(t, store)
+
+	byName, err := store.GetUserByName(ctx, user.Name)
+	require.NoError(t, err)
+	require.Equal(t, user, byName)
+	byEmail, err := store.GetUserByEmail(ctx, user.Email)
+	require.NoError(t, err)
+	require.Equal(t, user, byEmail)
+	_, err = store.GetUserByName(ctx, util.RandomString(12))
+	require.Equal(t, mongo.ErrNoDocuments, err)
+
+	// Names and emails are unique, and an email and a password are needed
+	_, err = store.AddUser(ctx, db.AddUserParams{Name: user.Name, Password: "secret", Email: util.RandomString(12) + "@vmail.com"})
+	require.True(t, mongo.IsDuplicateKeyError(err))
+	_, err = store.AddUser(ctx, db.AddUserParams{Name: util.RandomString(12), Password: "secret", Email: user.Email})
+	require.True(t, mongo.IsDuplicateKeyError(err))
+	_, err = store.AddUser(ctx, db.AddUserParams{Name: util.RandomString(12), Password: "secret"})
+	require.Error(t, err)
+
+	other := addUser(t, store)
+	renamed := user
+	renamed.Name = other.Name
+	_, err = store.UpdateUserName(ctx, renamed)
+	require.True(t, mongo.IsDuplicateKeyError(err))
+
+	renamed.Name = util.RandomString(12)
+	res, err := store.UpdateUserName(ctx, renamed)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), res.ModifiedCount)
+	res, err = store.UpdateUserName(ctx, renamed)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), res.MatchedCount)
+	require.Zero(t, res.ModifiedCount)
+
+	renamed.Role = db.UserRoleAdmin
+	_, err = store.UpdateUserRole(ctx, renamed)
+	require.NoError(t, err)
+	require.NoError(t, store.BanUser(ctx, renamed.Name))
+	got, err := store.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, renamed.Name, got.Name)
+	require.Equal(t, db.UserRoleAdmin, got.Role)
+	require.True(t, got.Banned)
+	require.Equal(t, mongo.ErrNoDocuments, store.BanUser(ctx, util.RandomString(12)))
+}
+
+func testExecTx(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	user := addUser(t, store)
+	movieID := addMovie(t, store, randomMovie())
+
+	// The queries of a failed transaction are undone
+	errRollback := errors.New("rollback")
+	name := util.RandomString(12)
+	err := store.ExecTx(ctx, func(q db.Querier) error {
+		renamed := user
+		renamed.Name = name
+		if _, err := q.UpdateUserName(ctx, renamed); err != nil {
+			return err
+		}
+		if _, err := q.AddComment(ctx, db.AddCommentParams{Name: name, MovieID: movieID, Text: "undone"}); err != nil {
+			return err
+		}
+		if err := q.IncrementMovieComments(ctx, movieID, 1); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	require.Equal(t, errRollback, err)
+	got, err := store.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, user.Name, got.Name)
+	comments, err := store.GetCommentsByMovieID(ctx, db.GetCommentsParams{MovieID: movieID})
+	require.NoError(t, err)
+	require.Empty(t, comments)
+	require.Zero(t, getMovie(t, store, movieID).NumMflixComments)
+
+	// and the ones of a transaction that succeeds are kept
+	err = store.ExecTx(ctx, func(q db.Querier) error {
+		if _, err := q.AddComment(ctx, db.AddCommentParams{Name: user.Name, MovieID: movieID, Text: "kept"}); err != nil {
+			return err
+		}
+		return q.IncrementMovieComments(ctx, movieID, 1)
+	})
+	require.NoError(t, err)
+	comments, err = store.GetCommentsByMovieID(ctx, db.GetCommentsParams{MovieID: movieID})
+	require.NoError(t, err)
+	require.Len(t, comments, 1)
+	require.Equal(t, int64(1), getMovie(t, store, movieID).NumMflixComments)
+
+	// An error of a query fails the transaction too
+	err = store.ExecTx(ctx, func(q db.Querier) error {
+		return q.IncrementMovieComments(ctx, primitive.NewObjectID(), 1)
+	})
+	require.Equal(t, mongo.ErrNoDocuments, err)
+}
+
+// ids are the ids of the documents, in order
+func commentIDs(comments []db.Comments) []primitive.ObjectID {
+	ids := make([]primitive.ObjectID, len(comments))
+	for i, comment := range comments {
+		ids[i] = comment.ID
+	}
+	return ids
+}
+
+func movieIDs(movies []db.Movies) []primitive.ObjectID {
+	ids := make([]primitive.ObjectID, len(movies))
+	for i, movie := range movies {
+		ids[i] = movie.Id
+	}
+	return ids
+}
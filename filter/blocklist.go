@@ -0,0 +1,176 @@
+package filter
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/width"
+	"phantom/search"
+)
+
+// The bundled blocklists, one per language, used when the server
+// is given no directory of its own
+//
+//go:embed blocklist/*.txt
+var bundled embed.FS
+
+// blockReason is the reason of the violations of a Blocklist
+const blockReason = "blocked word"
+
+// Blocklist matches blocked words and patterns, each with its own action.
+// Words are matched on folded text, see fold, and an English word only
+// matches a whole word, so that "ass" leaves "class" alone
+type Blocklist struct {
+	entries []blockEntry
+}
+
+type blockEntry struct {
+	action  Action
+	word    []rune
+	ascii   bool
+	pattern *regexp.Regexp
+}
+
+// LoadBlocklist reads every *.txt file of dir, or the bundled lists
+// when dir is empty. See blocklist/en.txt for the format
+func LoadBlocklist(dir string) (*Blocklist, error) {
+	var fsys fs.FS = os.DirFS(dir)
+	if dir == "" {
+		sub, err := fs.Sub(bundled, "blocklist")
+		if err != nil {
+			return nil, err
+		}
+		fsys = sub
+	}
+	names, err := fs.Glob(fsys, "*.txt")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	b := &Blocklist{}
+	for _, name := range names {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		if err = b.parse(string(data)); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return b, nil
+}
+
+func (b *Blocklist) parse(data string) error {
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		action := Mask
+		if fields := strings.SplitN(line, " ", 2); len(fields) == 2 {
+			if a, ok := ParseAction(fields[0]); ok {
+				action, line = a, strings.TrimSpace(fields[1])
+			}
+		}
+		if err := b.Add(action, line); err != nil {
+			return fmt.Errorf("line %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// Add blocks a word, or a regular expression when term is between slashes
+func (b *Blocklist) Add(action Action, term string) error {
+	if len(term) > 2 && strings.HasPrefix(term, "/") && strings.HasSuffix(term, "/") {
+		// Lowercasing the pattern would change escapes such as \S, so it
+		// ignores case instead
+		pattern, err := regexp.Compile("(?i)" + foldPattern(term[1:len(term)-1]))
+		if err != nil {
+			return err
+		}
+		b.entries = append(b.entries, blockEntry{action: action, pattern: pattern})
+		return nil
+	}
+	word := fold(term)
+	if len(word) == 0 {
+		return fmt.Errorf("empty term")
+	}
+	ascii := true
+	for _, r := range word {
+		if r > unicode.MaxASCII {
+			ascii = false
+			break
+		}
+	}
+	b.entries = append(b.entries, blockEntry{action: action, word: word, ascii: ascii})
+	return nil
+}
+
+// foldPattern is fold for a regular expression, with the case left as is
+func foldPattern(pattern string) string {
+	return strings.Map(func(r rune) rune {
+		if folded := width.LookupRune(r).Folded(); folded != 0 {
+			return folded
+		}
+		return r
+	}, search.ToSimplified(pattern))
+}
+
+// Len is the number of blocked words and patterns
+func (b *Blocklist) Len() int {
+	return len(b.entries)
+}
+
+func (b *Blocklist) Check(in Input) []Violation {
+	runes := fold(in.Text)
+	folded := string(runes)
+	spans := map[Action][]Span{}
+	for _, entry := range b.entries {
+		if entry.pattern != nil {
+			for _, match := range entry.pattern.FindAllStringIndex(folded, -1) {
+				spans[entry.action] = append(spans[entry.action], runeSpan(folded, match[0], match[1]))
+			}
+			continue
+		}
+		for i := indexRunes(runes, entry.word, 0); i >= 0; i = indexRunes(runes, entry.word, i+1) {
+			end := i + len(entry.word)
+			if entry.ascii && (isWordRune(runes, i-1) || isWordRune(runes, end)) {
+				continue
+			}
+			spans[entry.action] = append(spans[entry.action], Span{i, end})
+		}
+	}
+
+	var violations []Violation
+	for _, action := range []Action{Reject, Mask, Flag} {
+		if len(spans[action]) > 0 {
+			violations = append(violations, Violation{Action: action, Reason: blockReason, Spans: spans[action]})
+		}
+	}
+	return violations
+}
+
+// indexRunes is the index of the first word in runes from from, or -1
+func indexRunes(runes, word []rune, from int) int {
+	for i := from; i+len(word) <= len(runes); i++ {
+		if equalRunes(runes[i:i+len(word)], word) {
+			return i
+		}
+	}
+	return -1
+}
+
+// isWordRune tells if runes[i] is an ASCII letter or digit
+func isWordRune(runes []rune, i int) bool {
+	if i < 0 || i >= len(runes) || runes[i] > unicode.MaxASCII {
+		return false
+	}
+	return unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])
+}
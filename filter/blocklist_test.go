@@ -0,0 +1,53 @@
+package filter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadBundledBlocklist(t *testing.T) {
+	b, err := LoadBlocklist("")
+	require.NoError(t, err)
+	require.NotZero(t, b.Len())
+}
+
+func TestLoadBlocklist(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "en.txt"), []byte("# comment\n\nbad\nreject /spam+/\nflag  plot twist\nmasked words\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "zh.txt"), []byte("坏蛋\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("/[/\n"), 0o644))
+
+	b, err := LoadBlocklist(dir)
+	require.NoError(t, err)
+	require.Equal(t, 5, b.Len())
+	require.Equal(t, Reject, b.entries[1].action)
+	require.Equal(t, Flag, b.entries[2].action)
+	require.Equal(t, []rune("plot twist"), b.entries[2].word)
+	require.Equal(t, Mask, b.entries[3].action)
+	require.Equal(t, []rune("masked words"), b.entries[3].word)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bad.txt"), []byte("ok\nreject /[/\n"), 0o644))
+	_, err = LoadBlocklist(dir)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "bad.txt: line 2")
+}
+
+func TestBlocklistCheck(t *testing.T) {
+	b := &Blocklist{}
+	require.NoError(t, b.Add(Mask, "ass"))
+	require.NoError(t, b.Add(Mask, "坏蛋"))
+	require.NoError(t, b.Add(Flag, "/結局/"))
+
+	// English words only match whole, Chinese ones anywhere, traditional or
+	// full-width text included
+	violations := b.Check(Input{Text: "Class, ＡＳＳ! 你个壞蛋 ass 结局"})
+	require.Equal(t, []Violation{
+		{Action: Mask, Reason: blockReason, Spans: []Span{{7, 10}, {17, 20}, {14, 16}}},
+		{Action: Flag, Reason: blockReason, Spans: []Span{{21, 23}}},
+	}, violations)
+
+	require.Nil(t, b.Check(Input{Text: "passable"}))
+}
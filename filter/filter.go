@@ -0,0 +1,215 @@
+// Package filter checks the text of comments before they are stored.
+// A Pipeline runs a list of rules over a comment, and each rule that
+// matches rejects the comment, masks the matching text or flags the
+// comment for the moderators
+package filter
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/width"
+	"phantom/search"
+)
+
+// Action is what a rule does to a comment it matches
+type Action string
+
+const (
+	// Reject refuses the comment
+	Reject Action = "reject"
+	// Mask replaces the matching text with MaskText
+	Mask Action = "mask"
+	// Flag keeps the comment but puts it in the moderation queue
+	Flag Action = "flag"
+)
+
+// MaskText replaces the text a rule masks
+const MaskText = "***"
+
+// ParseAction parses the name of an action, ok is false for an unknown one
+func ParseAction(s string) (action Action, ok bool) {
+	switch action = Action(strings.ToLower(s)); action {
+	case Reject, Mask, Flag:
+		return action, true
+	}
+	return "", false
+}
+
+// Input is a comment to check
+type Input struct {
+	// Name is the user who writes the comment
+	Name string
+	Text string
+	// Edit is set when an existing comment is changed rather than posted
+	Edit bool
+}
+
+// Span is a part of a text, in runes
+type Span struct {
+	Start int
+	End   int
+}
+
+// Violation is a match of a rule. Spans are the parts of the text that
+// matched, none meaning the text as a whole
+type Violation struct {
+	Action Action
+	Reason string
+	Spans  []Span
+}
+
+// Rule checks a comment
+type Rule interface {
+	Check(in Input) []Violation
+}
+
+// Result is the outcome of a Pipeline on a comment
+type Result struct {
+	// Text is the text to store, with the masked parts replaced
+	Text string
+	// Rejected is set when a rule rejected the comment, for Reason
+	Rejected bool
+	Reason   string
+	// Flags are the reasons the comment needs a moderator, if any
+	Flags []string
+}
+
+// Pipeline runs rules over comments in order
+type Pipeline struct {
+	rules []Rule
+}
+
+func NewPipeline(rules ...Rule) *Pipeline {
+	return &Pipeline{rules: rules}
+}
+
+// Run checks a comment against every rule in turn, each one seeing the text
+// the previous ones masked. It stops at the first rule that rejects it
+func (p *Pipeline) Run(in Input) Result {
+	res := Result{Text: in.Text}
+	for _, rule := range p.rules {
+		in.Text = res.Text
+		var spans []Span
+		masked := false
+		for _, v := range rule.Check(in) {
+			switch v.Action {
+			case Reject:
+				return Result{Text: res.Text, Rejected: true, Reason: v.Reason, Flags: res.Flags}
+			case Mask:
+				masked = true
+				if len(v.Spans) == 0 {
+					spans = append(spans, Span{0, len([]rune(in.Text))})
+				}
+				spans = append(spans, v.Spans...)
+			case Flag:
+				res.Flags = addFlag(res.Flags, v.Reason)
+			}
+		}
+		if masked {
+			res.Text = maskSpans(in.Text, spans)
+		}
+	}
+	return res
+}
+
+func addFlag(flags []string, reason string) []string {
+	for _, flag := range flags {
+		if flag == reason {
+			return flags
+		}
+	}
+	return append(flags, reason)
+}
+
+// maskSpans replaces each run of overlapping spans of text with MaskText
+func maskSpans(text string, spans []Span) string {
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Start < spans[j].Start })
+	runes := []rune(text)
+	var sb strings.Builder
+	pos := 0
+	for i := 0; i < len(spans); {
+		start, end := spans[i].Start, spans[i].End
+		for i++; i < len(spans) && spans[i].Start <= end; i++ {
+			if spans[i].End > end {
+				end = spans[i].End
+			}
+		}
+		if start < pos {
+			start = pos
+		}
+		if end > len(runes) {
+			end = len(runes)
+		}
+		if start >= end {
+			continue
+		}
+		sb.WriteString(string(runes[pos:start]))
+		sb.WriteString(MaskText)
+		pos = end
+	}
+	sb.WriteString(string(runes[pos:]))
+	return sb.String()
+}
+
+// fold lowercases text, turns full-width letters into plain ones and
+// traditional Chinese into simplified, rune by rune, so that the result
+// has the runes of text at the same places
+func fold(text string) []rune {
+	runes := []rune(search.ToSimplified(text))
+	for i, r := range runes {
+		if folded := width.LookupRune(r).Folded(); folded != 0 {
+			r = folded
+		}
+		runes[i] = unicode.ToLower(r)
+	}
+	return runes
+}
+
+// Config sets up the Default pipeline
+type Config struct {
+	Blocklist *Blocklist
+	// MinLength and MaxLength bound the length of a comment in characters
+	MinLength int
+	MaxLength int
+	// MaxLinks is how many links a comment can have
+	MaxLinks int
+	// MaxRepeat is how many times in a row a short piece of text can repeat
+	MaxRepeat int
+	// FloodLimit is how many comments a user can post in FloodWindow
+	FloodLimit  int
+	FloodWindow time.Duration
+	// DuplicateWindow is how long a user can't post the same text again
+	DuplicateWindow time.Duration
+}
+
+// DefaultConfig is the Config of the server, without a blocklist
+var DefaultConfig = Config{
+	MinLength:       1,
+	MaxLength:       2000,
+	MaxLinks:        2,
+	MaxRepeat:       10,
+	FloodLimit:      5,
+	FloodWindow:     time.Minute,
+	DuplicateWindow: 10 * time.Minute,
+}
+
+// Default is the pipeline of the server. Cheap rules that reject come first,
+// the blocklist masks before the links are counted
+func Default(config Config) *Pipeline {
+	rules := []Rule{
+		Length{Min: config.MinLength, Max: config.MaxLength, Action: Reject},
+		NewFlood(config.FloodLimit, config.FloodWindow, Reject),
+		NewDuplicate(config.DuplicateWindow, Reject),
+	}
+	if config.Blocklist != nil {
+		rules = append(rules, config.Blocklist)
+	}
+	rules = append(rules,
+		LinkLimit{Max: config.MaxLinks, Action: Flag},
+		Repetition{MaxRepeat: config.MaxRepeat, Action: Flag},
+	)
+	return NewPipeline(rules...)
+}
@@ -0,0 +1,115 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaskSpans(t *testing.T) {
+	require.Equal(t, "a *** c", maskSpans("a bad c", []Span{{2, 5}}))
+	// Overlapping and touching spans make one mask, out of range ones are clipped
+	require.Equal(t, "***d", maskSpans("abcd", []Span{{1, 2}, {0, 2}, {2, 3}}))
+	require.Equal(t, "星际***", maskSpans("星际穿越", []Span{{2, 10}}))
+	require.Equal(t, "***x***", maskSpans("abxcd", []Span{{3, 5}, {0, 2}}))
+}
+
+func TestPipeline(t *testing.T) {
+	blocklist := &Blocklist{}
+	require.NoError(t, blocklist.Add(Mask, "damn"))
+	require.NoError(t, blocklist.Add(Flag, "spoiler"))
+	require.NoError(t, blocklist.Add(Reject, "/free money/"))
+	p := NewPipeline(Length{Min: 1, Max: 50, Action: Reject}, blocklist, LinkLimit{Max: 1, Action: Mask})
+
+	res := p.Run(Input{Name: "user", Text: "Damn, that SPOILER at www.a.com and b.net"})
+	require.False(t, res.Rejected)
+	require.Equal(t, "***, that SPOILER at www.a.com and ***", res.Text)
+	require.Equal(t, []string{blockReason}, res.Flags)
+
+	res = p.Run(Input{Name: "user", Text: "spoiler: FREE MONEY"})
+	require.True(t, res.Rejected)
+	require.Equal(t, blockReason, res.Reason)
+
+	res = p.Run(Input{Name: "user", Text: strings.Repeat("a", 51)})
+	require.True(t, res.Rejected)
+	require.Equal(t, "too long", res.Reason)
+
+	res = p.Run(Input{Name: "user", Text: "   "})
+	require.True(t, res.Rejected)
+	require.Equal(t, "too short", res.Reason)
+
+	res = p.Run(Input{Name: "user", Text: "nice movie"})
+	require.Equal(t, Result{Text: "nice movie"}, res)
+}
+
+func TestLengthMask(t *testing.T) {
+	p := NewPipeline(Length{Max: 5, Action: Mask})
+	require.Equal(t, "  星际穿越啊***  ", p.Run(Input{Text: "  星际穿越啊啊啊  "}).Text)
+}
+
+func TestLinkLimit(t *testing.T) {
+	rule := LinkLimit{Max: 0, Action: Flag}
+	require.Nil(t, rule.Check(Input{Text: "no links here, e.g. this one."}))
+	violations := rule.Check(Input{Text: "看 https://example.com/a?b=c 和 douban.com"})
+	require.Len(t, violations, 1)
+	require.Equal(t, []Span{{2, 27}, {30, 40}}, violations[0].Spans)
+}
+
+func TestRepetition(t *testing.T) {
+	rule := Repetition{MaxRepeat: 3, Action: Flag}
+	require.Nil(t, rule.Check(Input{Text: "哈哈哈 good good good"}))
+	require.Nil(t, rule.Check(Input{Text: "so        much space"}))
+
+	violations := rule.Check(Input{Text: "哈哈哈哈 wow!!!!! ab ab ab AB "})
+	require.Len(t, violations, 1)
+	require.Equal(t, []Span{{0, 4}, {8, 13}, {13, 25}}, violations[0].Spans)
+}
+
+func TestFlood(t *testing.T) {
+	now := time.Date(2022, time.March, 1, 20, 0, 0, 0, time.UTC)
+	rule := NewFlood(2, time.Minute, Reject)
+	rule.recent.now = func() time.Time { return now }
+
+	require.Nil(t, rule.Check(Input{Name: "a", Text: "1"}))
+	require.Nil(t, rule.Check(Input{Name: "a", Text: "2"}))
+	require.NotNil(t, rule.Check(Input{Name: "a", Text: "3"}))
+	require.Nil(t, rule.Check(Input{Name: "b", Text: "1"}))
+	require.Nil(t, rule.Check(Input{Name: "a", Text: "edit", Edit: true}))
+
+	now = now.Add(2 * time.Minute)
+	require.Nil(t, rule.Check(Input{Name: "a", Text: "4"}))
+	// The users who stopped posting are forgotten
+	require.NotContains(t, rule.recent.posts, "b")
+}
+
+func TestDuplicate(t *testing.T) {
+	now := time.Date(2022, time.March, 1, 20, 0, 0, 0, time.UTC)
+	rule := NewDuplicate(10*time.Minute, Reject)
+	rule.recent.now = func() time.Time { return now }
+
+	require.Nil(t, rule.Check(Input{Name: "a", Text: "Great  movie"}))
+	require.Nil(t, rule.Check(Input{Name: "b", Text: "great movie"}))
+	require.NotNil(t, rule.Check(Input{Name: "a", Text: " GREAT movie "}))
+	require.Nil(t, rule.Check(Input{Name: "a", Text: "great movie", Edit: true}))
+
+	now = now.Add(11 * time.Minute)
+	require.Nil(t, rule.Check(Input{Name: "a", Text: "great movie"}))
+}
+
+func TestDefault(t *testing.T) {
+	blocklist, err := LoadBlocklist("")
+	require.NoError(t, err)
+	config := DefaultConfig
+	config.Blocklist = blocklist
+	p := Default(config)
+
+	res := p.Run(Input{Name: "user", Text: "這部電影真是傻逼，剧透：结局他死了"})
+	require.False(t, res.Rejected)
+	require.Equal(t, "這部電影真是***，剧透：结局他死了", res.Text)
+	require.Equal(t, []string{blockReason}, res.Flags)
+
+	res = p.Run(Input{Name: "user", Text: "想要好评加微信: abc12345"})
+	require.True(t, res.Rejected)
+}
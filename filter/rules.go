@@ -0,0 +1,211 @@
+package filter
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Length bounds the length of a comment in characters, leading and trailing
+// spaces left out. Masking a comment that is too long masks its tail
+type Length struct {
+	Min    int
+	Max    int
+	Action Action
+}
+
+func (r Length) Check(in Input) []Violation {
+	text := strings.TrimSpace(in.Text)
+	n := utf8.RuneCountInString(text)
+	if n < r.Min {
+		return []Violation{{Action: r.Action, Reason: "too short"}}
+	}
+	if r.Max > 0 && n > r.Max {
+		// The tail in the untrimmed text
+		leading := len(in.Text) - len(strings.TrimLeftFunc(in.Text, unicode.IsSpace))
+		start := utf8.RuneCountInString(in.Text[:leading]) + r.Max
+		return []Violation{{Action: r.Action, Reason: "too long", Spans: []Span{{start, start + n - r.Max}}}}
+	}
+	return nil
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s]+|\b[a-z0-9-]+(?:\.[a-z0-9-]+)*\.(?:com|net|org|cn|io|me|cc|xyz|top|info)\b(?:/[^\s]*)?`)
+
+// LinkLimit allows Max links in a comment, the ones past it violate the rule
+type LinkLimit struct {
+	Max    int
+	Action Action
+}
+
+func (r LinkLimit) Check(in Input) []Violation {
+	links := linkPattern.FindAllStringIndex(in.Text, -1)
+	if len(links) <= r.Max {
+		return nil
+	}
+	spans := make([]Span, 0, len(links)-r.Max)
+	for _, link := range links[r.Max:] {
+		spans = append(spans, runeSpan(in.Text, link[0], link[1]))
+	}
+	return []Violation{{Action: r.Action, Reason: "too many links", Spans: spans}}
+}
+
+// maxRepeatUnit is the longest piece of text Repetition looks for repeats of
+const maxRepeatUnit = 8
+
+// Repetition catches a piece of text, up to maxRepeatUnit characters long,
+// repeated more than MaxRepeat times in a row, like "!!!!!!" or "哈哈哈哈"
+type Repetition struct {
+	MaxRepeat int
+	Action    Action
+}
+
+func (r Repetition) Check(in Input) []Violation {
+	runes := fold(in.Text)
+	var spans []Span
+	for i := 0; i < len(runes); {
+		end := 0
+		for l := 1; l <= maxRepeatUnit && i+l*(r.MaxRepeat+1) <= len(runes); l++ {
+			unit := runes[i : i+l]
+			if isSpace(unit) {
+				continue
+			}
+			k := 1
+			for i+(k+1)*l <= len(runes) && equalRunes(runes[i+k*l:i+(k+1)*l], unit) {
+				k++
+			}
+			if k > r.MaxRepeat && i+k*l > end {
+				end = i + k*l
+			}
+		}
+		if end == 0 {
+			i++
+			continue
+		}
+		spans = append(spans, Span{i, end})
+		i = end
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+	return []Violation{{Action: r.Action, Reason: "repeated text", Spans: spans}}
+}
+
+func isSpace(runes []rune) bool {
+	for _, r := range runes {
+		if !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalRunes(a, b []rune) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// runeSpan turns the byte offsets of a match in text into a Span
+func runeSpan(text string, start, end int) Span {
+	s := utf8.RuneCountInString(text[:start])
+	return Span{s, s + utf8.RuneCountInString(text[start:end])}
+}
+
+// recentPosts keeps what each user posted in the last window, in memory
+type recentPosts struct {
+	mu        sync.Mutex
+	window    time.Duration
+	posts     map[string][]post
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type post struct {
+	text string
+	at   time.Time
+}
+
+func newRecentPosts(window time.Duration) *recentPosts {
+	return &recentPosts{window: window, posts: map[string][]post{}, now: time.Now}
+}
+
+// add records a post of name and returns the ones before it in the window
+func (r *recentPosts) add(name, text string) []post {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	since := now.Add(-r.window)
+	// Forget the users who stopped posting now and then
+	if now.Sub(r.lastSweep) > r.window {
+		for user, posts := range r.posts {
+			if len(posts) == 0 || !posts[len(posts)-1].at.After(since) {
+				delete(r.posts, user)
+			}
+		}
+		r.lastSweep = now
+	}
+
+	posts := r.posts[name]
+	i := 0
+	for i < len(posts) && !posts[i].at.After(since) {
+		i++
+	}
+	recent := append([]post(nil), posts[i:]...)
+	r.posts[name] = append(posts[i:], post{text: text, at: now})
+	return recent
+}
+
+// Flood allows a user Limit comments in Window, edits left out
+type Flood struct {
+	Limit  int
+	Action Action
+	recent *recentPosts
+}
+
+func NewFlood(limit int, window time.Duration, action Action) *Flood {
+	return &Flood{Limit: limit, Action: action, recent: newRecentPosts(window)}
+}
+
+func (r *Flood) Check(in Input) []Violation {
+	if in.Edit {
+		return nil
+	}
+	if len(r.recent.add(in.Name, "")) >= r.Limit {
+		return []Violation{{Action: r.Action, Reason: "posting too fast"}}
+	}
+	return nil
+}
+
+// Duplicate catches a user posting the same text again within a window,
+// regardless of case and spacing. Edits are left out
+type Duplicate struct {
+	Action Action
+	recent *recentPosts
+}
+
+func NewDuplicate(window time.Duration, action Action) *Duplicate {
+	return &Duplicate{Action: action, recent: newRecentPosts(window)}
+}
+
+func (r *Duplicate) Check(in Input) []Violation {
+	if in.Edit {
+		return nil
+	}
+	text := strings.Join(strings.Fields(string(fold(in.Text))), " ")
+	for _, p := range r.recent.add(in.Name, text) {
+		if p.text == text {
+			return []Violation{{Action: r.Action, Reason: "duplicate comment"}}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package importer
+
+import (
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	db "phantom/db/mongo"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// field is a field of a movie that a column of a CSV file can be mapped to
+type field struct {
+	path string
+	set  func(movie *db.Movies, value string) error
+}
+
+// fields are the fields of a movie by their path, the way they are
+// named in the documents of the 'movies' collection
+var fields = map[string]func(movie *db.Movies, value string) error{
+	"title":              setString(func(m *db.Movies) *string { return &m.Title }),
+	"original_title":     setString(func(m *db.Movies) *string { return &m.OriginalTitle }),
+	"sort_title":         setString(func(m *db.Movies) *string { return &m.SortTitle }),
+	"plot":               setString(func(m *db.Movies) *string { return &m.Plot }),
+	"fullplot":           setString(func(m *db.Movies) *string { return &m.Fullplot }),
+	"rated":              setString(func(m *db.Movies) *string { return &m.Rated }),
+	"poster":             setString(func(m *db.Movies) *string { return &m.Poster }),
+	"type":               setString(func(m *db.Movies) *string { return &m.Type }),
+	"awards.text":        setString(func(m *db.Movies) *string { return &m.Awards.Text }),
+	"genres":             setList(func(m *db.Movies) *[]string { return &m.Genres }),
+	"cast":               setList(func(m *db.Movies) *[]string { return &m.Cast }),
+	"directors":          setList(func(m *db.Movies) *[]string { return &m.Directors }),
+	"writers":            setList(func(m *db.Movies) *[]string { return &m.Writers }),
+	"languages":          setList(func(m *db.Movies) *[]string { return &m.Languages }),
+	"countries":          setList(func(m *db.Movies) *[]string { return &m.Countries }),
+	"year":               setInt(func(m *db.Movies) *int64 { return &m.Year }),
+	"runtime":            setInt(func(m *db.Movies) *int64 { return &m.Runtime }),
+	"num_mflix_comments": setInt(func(m *db.Movies) *int64 { return &m.NumMflixComments }),
+	"awards.wins":        setInt(func(m *db.Movies) *int64 { return &m.Awards.Wins }),
+	"awards.nominations": setInt(func(m *db.Movies) *int64 { return &m.Awards.Nominations }),
+	"imdb.votes":         setInt(func(m *db.Movies) *int64 { return &m.Imdb.Votes }),
+	"imdb.rating":        setFloat(func(m *db.Movies) *float64 { return &m.Imdb.Rating }),
+	"imdb.id":            setImdbID,
+	"released":           setDate,
+}
+
+// Fields are the paths of the fields a column can be mapped to, in order
+func Fields() []string {
+	paths := make([]string, 0, len(fields))
+	for path := range fields {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func errUnknownField(path string) error {
+	return errors.New("unknown field " + strconv.Quote(path) + ", it can be one of " + strings.Join(Fields(), ", "))
+}
+
+// ParseColumns parses a mapping of fields to columns like
+// "title=Series_Title,imdb.rating=IMDB_Rating", see Options.Columns
+func ParseColumns(s string) (map[string]string, error) {
+	columns := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		i := strings.Index(pair, "=")
+		if i < 0 {
+			return nil, errors.New(strconv.Quote(pair) + " is not like field=column")
+		}
+		path := strings.TrimSpace(pair[:i])
+		if _, ok := fields[path]; !ok {
+			return nil, errUnknownField(path)
+		}
+		columns[path] = strings.TrimSpace(pair[i+1:])
+	}
+	return columns, nil
+}
+
+func setString(field func(m *db.Movies) *string) func(movie *db.Movies, value string) error {
+	return func(movie *db.Movies, value string) error {
+		*field(movie) = value
+		return nil
+	}
+}
+
+// setList splits a list on commas, or on bars like "Action|Drama"
+func setList(field func(m *db.Movies) *[]string) func(movie *db.Movies, value string) error {
+	return func(movie *db.Movies, value string) error {
+		var list []string
+		for _, item := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '|' }) {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*field(movie) = list
+		return nil
+	}
+}
+
+// setInt takes thousands separators, like the votes "2,343,110"
+func setInt(field func(m *db.Movies) *int64) func(movie *db.Movies, value string) error {
+	return func(movie *db.Movies, value string) error {
+		n, err := strconv.ParseInt(strings.ReplaceAll(value, ",", ""), 10, 64)
+		if err != nil {
+			return errors.New(strconv.Quote(value) + " is not a number")
+		}
+		*field(movie) = n
+		return nil
+	}
+}
+
+func setFloat(field func(m *db.Movies) *float64) func(movie *db.Movies, value string) error {
+	return func(movie *db.Movies, value string) error {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return errors.New(strconv.Quote(value) + " is not a number")
+		}
+		*field(movie) = f
+		return nil
+	}
+}
+
+// setImdbID takes the id of the title on IMDb, like "tt0111161", or its number
+func setImdbID(movie *db.Movies, value string) error {
+	id, err := strconv.ParseInt(strings.TrimPrefix(value, "tt"), 10, 64)
+	if err != nil {
+		return errors.New(strconv.Quote(value) + " is not an IMDb id")
+	}
+	movie.Imdb.Id = id
+	return nil
+}
+
+// dateLayouts are the layouts a release date can have
+var dateLayouts = []string{"2006-01-02", time.RFC3339, "02 Jan 2006", "2 Jan 2006"}
+
+func setDate(movie *db.Movies, value string) error {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			movie.Released = primitive.NewDateTimeFromTime(t)
+			return nil
+		}
+	}
+	return errors.New(strconv.Quote(value) + " is not a date like 2006-01-02")
+}
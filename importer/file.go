@@ -0,0 +1,91 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+)
+
+// checkpoint is where an import of a file got to, it is kept next to the file
+// while the import runs, so that an import that was stopped can be resumed
+type checkpoint struct {
+	Size     int64    `json:"size"`
+	ModTime  int64    `json:"mod_time"`
+	Format   string   `json:"format"`
+	Progress Progress `json:"progress"`
+}
+
+// CheckpointPath is the path of the checkpoint of an import of the file
+func CheckpointPath(path string) string {
+	return path + ".import"
+}
+
+// ImportFile imports the file like Import does, and resumes an import of the
+// same file that was stopped from the rows it had done, unless the file has
+// changed since. The format is that of the extension of the file when
+// opt.Format is not set. The checkpoint is removed once the import is done
+func ImportFile(ctx context.Context, store Upserter, path string, opt Options) (Progress, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Progress{}, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return Progress{}, err
+	}
+	if opt.Format == "" {
+		opt.Format = FormatOf(path)
+	}
+
+	current := checkpoint{Size: info.Size(), ModTime: info.ModTime().UnixNano(), Format: opt.Format}
+	if saved, err := readCheckpoint(path); err == nil &&
+		saved.Size == current.Size && saved.ModTime == current.ModTime && saved.Format == current.Format {
+		opt.Skip = saved.Progress.Rows
+	}
+	report := opt.Progress
+	var saveErr error
+	opt.Progress = func(progress Progress) {
+		current.Progress = progress
+		if err := writeCheckpoint(path, current); err != nil && saveErr == nil {
+			saveErr = err
+		}
+		if report != nil {
+			report(progress)
+		}
+	}
+
+	progress, err := Import(ctx, store, file, opt)
+	if err != nil {
+		return progress, err
+	}
+	if saveErr != nil {
+		return progress, saveErr
+	}
+	if err = os.Remove(CheckpointPath(path)); err != nil && !os.IsNotExist(err) {
+		return progress, err
+	}
+	return progress, nil
+}
+
+func readCheckpoint(path string) (checkpoint, error) {
+	var saved checkpoint
+	data, err := os.ReadFile(CheckpointPath(path))
+	if err == nil {
+		err = json.Unmarshal(data, &saved)
+	}
+	return saved, err
+}
+
+// writeCheckpoint replaces the checkpoint at once, so that it is never half written
+func writeCheckpoint(path string, saved checkpoint) error {
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	tmp := CheckpointPath(path) + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, CheckpointPath(path))
+}
@@ -0,0 +1,189 @@
+// Package importer adds catalogues of movies to a store, such as the mflix
+// sample dataset. It streams the records of a file as Mongo Extended JSON,
+// NDJSON or CSV, checks each against db.Movies, and upserts them in batches
+// by their db.MovieKey, so that an import can be run again, or resumed from
+// the rows it had done, without adding a movie twice
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/mongo"
+	"io"
+	"path/filepath"
+	db "phantom/db/mongo"
+	"strings"
+)
+
+// The formats of the records
+const (
+	// FormatJSON is Mongo Extended JSON, as an array of documents or as
+	// documents one after another like mongoexport writes them
+	FormatJSON = "json"
+	// FormatNDJSON is a document of Extended JSON on each line
+	FormatNDJSON = "ndjson"
+	// FormatCSV has a header row, whose columns are mapped to the fields
+	// of the movies, see Fields
+	FormatCSV = "csv"
+)
+
+const (
+	// DefaultBatchSize is the number of movies upserted at once
+	DefaultBatchSize = 500
+	// MaxRowErrors is the number of row errors a Progress keeps,
+	// the rows after them are only counted
+	MaxRowErrors = 100
+)
+
+// Upserter is the part of db.Store an import writes to
+type Upserter interface {
+	UpsertMovies(ctx context.Context, movies []db.Movies) (*mongo.BulkWriteResult, error)
+}
+
+type Options struct {
+	Format string
+	// Columns maps the fields of a movie to the columns of a CSV file, like
+	// "imdb.rating" to "IMDB_Rating". A field that isn't mapped is read from
+	// the column with its own name
+	Columns map[string]string
+	// BatchSize is DefaultBatchSize when it is zero
+	BatchSize int
+	// Skip is the number of rows that were imported already,
+	// which are read but not written
+	Skip int64
+	// Progress is called after each batch, if it is set
+	Progress func(Progress)
+}
+
+// Progress counts the rows an import has done. Rows is where it can be
+// resumed from, the rows after it were not written
+type Progress struct {
+	Rows      int64      `json:"rows"`
+	Inserted  int64      `json:"inserted"`
+	Updated   int64      `json:"updated"`
+	Unchanged int64      `json:"unchanged"`
+	Failed    int64      `json:"failed"`
+	Errors    []RowError `json:"errors"`
+}
+
+// RowError is why a row was not imported, rows are counted from 1
+// and the header of a CSV file is not a row
+type RowError struct {
+	Row   int64  `json:"row"`
+	Error string `json:"error"`
+}
+
+// FileError is the error of a file that can't be imported, such as one that
+// isn't in its format, unlike the errors of the store
+type FileError struct {
+	Err error
+}
+
+func (e *FileError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+func (progress *Progress) fail(row int64, err error) {
+	progress.Failed++
+	if len(progress.Errors) < MaxRowErrors {
+		progress.Errors = append(progress.Errors, RowError{Row: row, Error: err.Error()})
+	}
+}
+
+// FormatOf is the format of a file by its extension, or "" when it has none of them
+func FormatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON
+	case ".csv":
+		return FormatCSV
+	}
+	return ""
+}
+
+// Import reads the movies from r and upserts them into the store. A row
+// that can't be read or isn't a valid movie is counted as failed and the
+// import goes on, it stops at an error of the file or of the store.
+// The progress it returns is where it stopped
+func Import(ctx context.Context, store Upserter, r io.Reader, opt Options) (Progress, error) {
+	progress := Progress{Errors: []RowError{}}
+	records, err := newReader(r, opt)
+	if err != nil {
+		return progress, &FileError{err}
+	}
+	batchSize := opt.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	var row int64
+	var batch []db.Movies
+	var batchRows []int64
+	flush := func() error {
+		if len(batch) > 0 {
+			res, err := store.UpsertMovies(ctx, batch)
+			var bulkErr mongo.BulkWriteException
+			if err != nil && !errors.As(err, &bulkErr) {
+				return err
+			}
+			for _, writeErr := range bulkErr.WriteErrors {
+				progress.fail(batchRows[writeErr.Index], errors.New(writeErr.Message))
+			}
+			if res != nil {
+				progress.Inserted += res.UpsertedCount
+				progress.Updated += res.ModifiedCount
+				progress.Unchanged += res.MatchedCount - res.ModifiedCount
+			}
+			batch, batchRows = batch[:0], batchRows[:0]
+		}
+		progress.Rows = row
+		if opt.Progress != nil {
+			opt.Progress(progress)
+		}
+		return nil
+	}
+
+	for {
+		if err = ctx.Err(); err != nil {
+			return progress, err
+		}
+		movie, err := records.next()
+		if err == io.EOF {
+			break
+		}
+		var recordErr *recordError
+		if err != nil && !errors.As(err, &recordErr) {
+			return progress, &FileError{fmt.Errorf("row %d: %w", row+1, err)}
+		}
+		row++
+		if row <= opt.Skip {
+			progress.Rows = row
+			continue
+		}
+		if err == nil {
+			err = validate(movie)
+		}
+		if err != nil {
+			progress.fail(row, err)
+		} else {
+			batch = append(batch, movie)
+			batchRows = append(batchRows, row)
+		}
+		if len(batch) >= batchSize {
+			if err = flush(); err != nil {
+				return progress, err
+			}
+		}
+	}
+	if err = flush(); err != nil {
+		return progress, err
+	}
+	return progress, nil
+}
@@ -0,0 +1,163 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	memdb "phantom/db/memory"
+	db "phantom/db/mongo"
+)
+
+// mflix is a few movies the way mongoexport writes the mflix sample dataset,
+// the third has the year of a movie that was still being released
+const mflix = `{"_id":{"$oid":"573a1390f29313caabcd4135"},"title":"Blacksmith Scene","year":1893,"runtime":1,"genres":["Short"],"released":{"$date":{"$numberLong":"-2418768000000"}},"imdb":{"rating":6.2,"votes":1189,"id":5},"metacritic":12}
+{"_id":{"$oid":"573a1390f29313caabcd42e8"},"title":"The Great Train Robbery","year":1903,"imdb":{"rating":7.4,"votes":9847,"id":439},"cast":["A.C. Abadie","Gilbert M. 'Bronco Billy' Anderson"]}
+
+{"_id":{"$oid":"573a13a8f29313caabd1b5d5"},"title":"Gentleman Jack","year":"2010è","imdb":{"rating":8.2,"votes":1,"id":1682194}}
+{"_id":{"$oid":"573a1390f29313caabcd4323"},"title":"The Land Beyond the Sunset","year":1912,"imdb":{"rating":7.1,"votes":448,"id":488}}
+`
+
+func allMovies(t *testing.T, store db.Store) map[string]db.Movies {
+	movies, err := store.GetAllMovies(context.Background())
+	require.NoError(t, err)
+	byTitle := make(map[string]db.Movies, len(movies))
+	for _, movie := range movies {
+		byTitle[movie.Title] = movie
+	}
+	return byTitle
+}
+
+func TestImportNDJSON(t *testing.T) {
+	store := memdb.NewStore()
+	progress, err := Import(context.Background(), store, strings.NewReader(mflix), Options{Format: FormatNDJSON})
+	require.NoError(t, err)
+	require.Equal(t, int64(4), progress.Rows)
+	require.Equal(t, int64(3), progress.Inserted)
+	require.Equal(t, int64(1), progress.Failed)
+	require.Len(t, progress.Errors, 1)
+	require.Equal(t, int64(3), progress.Errors[0].Row)
+
+	movies := allMovies(t, store)
+	require.Len(t, movies, 3)
+	movie := movies["Blacksmith Scene"]
+	id, _ := primitive.ObjectIDFromHex("573a1390f29313caabcd4135")
+	require.Equal(t, id, movie.Id)
+	require.Equal(t, int64(1893), movie.Year)
+	require.Equal(t, int64(5), movie.Imdb.Id)
+	require.Equal(t, int64(-2418768000000), int64(movie.Released))
+	require.Equal(t, "great train robbery", movies["The Great Train Robbery"].SortTitle)
+
+	// An import can be run again, the movies are found by their IMDb ids
+	progress, err = Import(context.Background(), store, strings.NewReader(mflix), Options{Format: FormatNDJSON})
+	require.NoError(t, err)
+	require.Equal(t, int64(0), progress.Inserted)
+	require.Equal(t, int64(3), progress.Unchanged)
+	require.Len(t, allMovies(t, store), 3)
+}
+
+func TestImportJSON(t *testing.T) {
+	for name, input := range map[string]string{
+		"array":     `[{"title":"Alien","year":1979,"imdb":{"id":78748}}, {"title":"Aliens","year":{"$numberInt":"1986"}}]`,
+		"documents": "{\"title\":\"Alien\",\"year\":1979,\"imdb\":{\"id\":78748}}\n{\"title\":\"Aliens\",\n\"year\":1986}",
+	} {
+		t.Run(name, func(t *testing.T) {
+			store := memdb.NewStore()
+			progress, err := Import(context.Background(), store, strings.NewReader(input), Options{Format: FormatJSON})
+			require.NoError(t, err)
+			require.Equal(t, int64(2), progress.Inserted)
+			require.Equal(t, int64(1986), allMovies(t, store)["Aliens"].Year)
+		})
+	}
+
+	// The file can't be read on after a document that isn't JSON
+	_, err := Import(context.Background(), memdb.NewStore(), strings.NewReader(`[{"title":"Alien"}, {"title":`), Options{Format: FormatJSON})
+	require.Error(t, err)
+}
+
+// imdbTop is a CSV file with the columns of the IMDb top 1000 dataset
+const imdbTop = `Series_Title,Released_Year,Runtime,Genre,IMDB_Rating,Director,No_of_Votes,imdb.id
+The Shawshank Redemption,1994,142,Drama,9.3,Frank Darabont,"2,343,110",tt0111161
+The Godfather,1972,175,"Crime, Drama",9.2,Francis Ford Coppola,"1,620,367",tt0068646
+The Dark Knight,2008,152 min,"Action, Crime, Drama",9.0,Christopher Nolan,"2,303,232",tt0468569
+,1957,96,"Crime, Drama",9.0,Sidney Lumet,"689,845",
+`
+
+func TestImportCSV(t *testing.T) {
+	store := memdb.NewStore()
+	columns := map[string]string{
+		"title":       "Series_Title",
+		"year":        "Released_Year",
+		"runtime":     "Runtime",
+		"genres":      "Genre",
+		"imdb.rating": "IMDB_Rating",
+		"directors":   "Director",
+		"imdb.votes":  "No_of_Votes",
+	}
+	progress, err := Import(context.Background(), store, strings.NewReader(imdbTop), Options{Format: FormatCSV, Columns: columns})
+	require.NoError(t, err)
+	require.Equal(t, int64(4), progress.Rows)
+	require.Equal(t, int64(2), progress.Inserted)
+	require.Equal(t, int64(2), progress.Failed)
+	require.Equal(t, int64(3), progress.Errors[0].Row)
+	require.Contains(t, progress.Errors[0].Error, "runtime")
+	require.Equal(t, int64(4), progress.Errors[1].Row)
+	require.Contains(t, progress.Errors[1].Error, "title")
+
+	movie := allMovies(t, store)["The Godfather"]
+	require.Equal(t, []string{"Crime", "Drama"}, movie.Genres)
+	require.Equal(t, int64(1620367), movie.Imdb.Votes)
+	require.Equal(t, 9.2, movie.Imdb.Rating)
+	// The column named after the field needs no mapping
+	require.Equal(t, int64(68646), movie.Imdb.Id)
+
+	_, err = Import(context.Background(), store, strings.NewReader(imdbTop), Options{Format: FormatCSV, Columns: map[string]string{"rating": "IMDB_Rating"}})
+	require.Error(t, err)
+	_, err = Import(context.Background(), store, strings.NewReader(imdbTop), Options{Format: FormatCSV, Columns: map[string]string{"title": "Name"}})
+	require.Error(t, err)
+}
+
+// failingStore fails the upserts after the first n batches, like a database that went away
+type failingStore struct {
+	db.Store
+	n int
+}
+
+func (store *failingStore) UpsertMovies(ctx context.Context, movies []db.Movies) (*mongo.BulkWriteResult, error) {
+	if store.n == 0 {
+		return nil, errors.New("connection reset")
+	}
+	store.n--
+	return store.Store.UpsertMovies(ctx, movies)
+}
+
+func TestImportFileResumes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "movies.ndjson")
+	require.NoError(t, os.WriteFile(path, []byte(mflix), 0644))
+	store := memdb.NewStore()
+
+	progress, err := ImportFile(context.Background(), &failingStore{Store: store, n: 1}, path, Options{BatchSize: 2})
+	require.Error(t, err)
+	require.Equal(t, int64(2), progress.Rows)
+	require.FileExists(t, CheckpointPath(path))
+
+	// The import goes on from the rows it had done
+	var batches []Progress
+	progress, err = ImportFile(context.Background(), store, path, Options{
+		BatchSize: 2,
+		Progress:  func(progress Progress) { batches = append(batches, progress) },
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(4), progress.Rows)
+	require.Equal(t, int64(1), progress.Inserted)
+	require.Equal(t, int64(1), progress.Failed)
+	require.NotEmpty(t, batches)
+	require.Len(t, allMovies(t, store), 3)
+	require.NoFileExists(t, CheckpointPath(path))
+}
@@ -0,0 +1,227 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"io"
+	db "phantom/db/mongo"
+	"strings"
+	"time"
+)
+
+// maxLineSize is the longest line of an NDJSON file
+const maxLineSize = 16 << 20
+
+// recordError is the error of a row that can be skipped, unlike
+// the errors of the file that the reader can't go on after
+type recordError struct {
+	err error
+}
+
+func (e *recordError) Error() string {
+	return e.err.Error()
+}
+
+func (e *recordError) Unwrap() error {
+	return e.err
+}
+
+// reader reads the movies of a file one row at a time,
+// it returns io.EOF after the last one
+type reader interface {
+	next() (db.Movies, error)
+}
+
+func newReader(r io.Reader, opt Options) (reader, error) {
+	switch opt.Format {
+	case FormatJSON:
+		return newJSONReader(r)
+	case FormatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(nil, maxLineSize)
+		return &ndjsonReader{scanner: scanner}, nil
+	case FormatCSV:
+		return newCSVReader(r, opt.Columns)
+	}
+	return nil, fmt.Errorf("unknown format %q, it can be %s, %s or %s", opt.Format, FormatJSON, FormatNDJSON, FormatCSV)
+}
+
+// jsonReader reads an array of documents, or documents one after another
+type jsonReader struct {
+	decoder *json.Decoder
+	array   bool
+}
+
+func newJSONReader(r io.Reader) (*jsonReader, error) {
+	buffered := bufio.NewReader(r)
+	first, err := peekNonSpace(buffered)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	reader := &jsonReader{decoder: json.NewDecoder(buffered), array: first == '['}
+	if reader.array {
+		// The opening bracket
+		if _, err = reader.decoder.Token(); err != nil {
+			return nil, err
+		}
+	}
+	return reader, nil
+}
+
+func peekNonSpace(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if !strings.ContainsRune(" \t\r\n", rune(b)) {
+			return b, r.UnreadByte()
+		}
+	}
+}
+
+func (r *jsonReader) next() (db.Movies, error) {
+	if r.array && !r.decoder.More() {
+		return db.Movies{}, io.EOF
+	}
+	var raw json.RawMessage
+	if err := r.decoder.Decode(&raw); err != nil {
+		return db.Movies{}, err
+	}
+	return decodeExtJSON(raw)
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+}
+
+func (r *ndjsonReader) next() (db.Movies, error) {
+	for r.scanner.Scan() {
+		line := bytes.TrimSpace(r.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		return decodeExtJSON(line)
+	}
+	if err := r.scanner.Err(); err != nil {
+		return db.Movies{}, err
+	}
+	return db.Movies{}, io.EOF
+}
+
+// decodeExtJSON decodes a document of Extended JSON, canonical or relaxed,
+// into a movie. The fields a movie doesn't have are dropped
+func decodeExtJSON(data []byte) (db.Movies, error) {
+	var movie db.Movies
+	if err := bson.UnmarshalExtJSON(data, false, &movie); err != nil {
+		return db.Movies{}, &recordError{err}
+	}
+	return movie, nil
+}
+
+// csvReader reads the rows of a CSV file into the fields its columns are mapped to
+type csvReader struct {
+	reader  *csv.Reader
+	columns map[int]field
+}
+
+func newCSVReader(r io.Reader, mapping map[string]string) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("the file has no header")
+	}
+	if err != nil {
+		return nil, err
+	}
+	for path := range mapping {
+		if _, ok := fields[path]; !ok {
+			return nil, errUnknownField(path)
+		}
+	}
+
+	// The columns by their names, the first of a name wins
+	byName := make(map[string]int, len(header))
+	for i := len(header) - 1; i >= 0; i-- {
+		byName[strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff"))] = i
+	}
+	columns := make(map[int]field)
+	for path, set := range fields {
+		name, mapped := mapping[path]
+		if !mapped {
+			name = path
+		}
+		i, ok := byName[name]
+		if !ok {
+			if mapped {
+				return nil, fmt.Errorf("the file has no column %q for %s", name, path)
+			}
+			continue
+		}
+		columns[i] = field{path: path, set: set}
+	}
+	if len(columns) == 0 {
+		return nil, errors.New("no column of the file is mapped to a field")
+	}
+	return &csvReader{reader: reader, columns: columns}, nil
+}
+
+func (r *csvReader) next() (db.Movies, error) {
+	record, err := r.reader.Read()
+	if err != nil {
+		// The reader goes on at the next line after a row it can't parse
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return db.Movies{}, &recordError{err}
+		}
+		return db.Movies{}, err
+	}
+	var movie db.Movies
+	for i, field := range r.columns {
+		if i >= len(record) {
+			continue
+		}
+		value := strings.TrimSpace(record[i])
+		if value == "" {
+			continue
+		}
+		if err = field.set(&movie, value); err != nil {
+			return db.Movies{}, &recordError{fmt.Errorf("%s: %w", field.path, err)}
+		}
+	}
+	return movie, nil
+}
+
+// validate checks the movie the way the server would take it,
+// beyond the types the decoding checked already
+func validate(movie db.Movies) error {
+	if strings.TrimSpace(movie.Title) == "" {
+		return errors.New("title: a movie needs a title")
+	}
+	if movie.Year != 0 && (movie.Year < 1870 || movie.Year > int64(time.Now().Year())+10) {
+		return fmt.Errorf("year: %d is not a year of a movie", movie.Year)
+	}
+	if movie.Runtime < 0 {
+		return fmt.Errorf("runtime: %d is negative", movie.Runtime)
+	}
+	if movie.Imdb.Id < 0 {
+		return fmt.Errorf("imdb.id: %d is negative", movie.Imdb.Id)
+	}
+	if movie.Imdb.Rating < 0 || movie.Imdb.Rating > 10 {
+		return fmt.Errorf("imdb.rating: %g is not between 0 and 10", movie.Imdb.Rating)
+	}
+	if movie.Imdb.Votes < 0 {
+		return fmt.Errorf("imdb.votes: %d is negative", movie.Imdb.Votes)
+	}
+	if movie.Type != "" && movie.Type != db.TypeMovie && movie.Type != db.TypeSeries {
+		return fmt.Errorf("type: %q is not %s or %s", movie.Type, db.TypeMovie, db.TypeSeries)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// Package ledger keeps a tamper-evident log of what users post, danmaku and
+// comments. Every entry commits to the one before it, so that no historic entry
+// can change without the ones after it changing too, and the Merkle roots of
+// the entries are checkpointed every so often, so that anyone who kept a root
+// can prove an entry is in the log with a Proof, offline
+package ledger
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+)
+
+// The operations an entry records
+const (
+	OpAdd    = "add"
+	OpEdit   = "edit"
+	OpDelete = "delete"
+)
+
+// Hash is a SHA-256 hash, written in hex
+type Hash [sha256.Size]byte
+
+// Digest is the hash of the content an entry records
+func Digest(content []byte) Hash {
+	return sha256.Sum256(content)
+}
+
+func ParseHash(s string) (Hash, error) {
+	var h Hash
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return h, err
+	}
+	if len(b) != len(h) {
+		return h, fmt.Errorf("a hash has %d bytes, not %d", len(h), len(b))
+	}
+	copy(h[:], b)
+	return h, nil
+}
+
+func (h Hash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+func (h Hash) MarshalText() ([]byte, error) {
+	return []byte(h.String()), nil
+}
+
+func (h *Hash) UnmarshalText(text []byte) error {
+	parsed, err := ParseHash(string(text))
+	if err != nil {
+		return err
+	}
+	*h = parsed
+	return nil
+}
+
+// Entry records an operation on something posted, the Ref of it of some Kind,
+// and Digest is the hash of its content after the operation.
+// Seq counts the entries from 0, and Prev is the Hash of the entry before,
+// zero for the first one
+type Entry struct {
+	Seq    int64  `json:"seq"`
+	Kind   string `json:"kind"`
+	Op     string `json:"op"`
+	Ref    string `json:"ref"`
+	Digest Hash   `json:"digest"`
+	Prev   Hash   `json:"prev"`
+	Hash   Hash   `json:"hash"`
+}
+
+// Append makes the entry that follows prev, or the first entry when prev is nil
+func Append(prev *Entry, kind, op, ref string, digest Hash) Entry {
+	entry := Entry{Kind: kind, Op: op, Ref: ref, Digest: digest}
+	if prev != nil {
+		entry.Seq = prev.Seq + 1
+		entry.Prev = prev.Hash
+	}
+	entry.Hash = entry.ComputeHash()
+	return entry
+}
+
+// ComputeHash is the SHA-256 of Prev, Seq as 8 bytes big-endian,
+// Kind, Op and Ref each after its length as 8 bytes big-endian, then Digest
+func (e Entry) ComputeHash() Hash {
+	h := sha256.New()
+	h.Write(e.Prev[:])
+	writeUint64(h, uint64(e.Seq))
+	for _, s := range []string{e.Kind, e.Op, e.Ref} {
+		writeUint64(h, uint64(len(s)))
+		h.Write([]byte(s))
+	}
+	h.Write(e.Digest[:])
+	var sum Hash
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+func writeUint64(h interface{ Write([]byte) (int, error) }, n uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], n)
+	h.Write(b[:])
+}
@@ -0,0 +1,176 @@
+package ledger
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func chain(n int) []Entry {
+	entries := make([]Entry, 0, n)
+	var prev *Entry
+	for i := 0; i < n; i++ {
+		entry := Append(prev, "comment", OpAdd, fmt.Sprintf("ref%d", i), Digest([]byte(fmt.Sprint("text", i))))
+		entries = append(entries, entry)
+		prev = &entries[i]
+	}
+	return entries
+}
+
+func TestAppend(t *testing.T) {
+	entries := chain(3)
+	require.Equal(t, int64(0), entries[0].Seq)
+	require.Equal(t, Hash{}, entries[0].Prev)
+	require.Equal(t, int64(2), entries[2].Seq)
+	require.Equal(t, entries[1].Hash, entries[2].Prev)
+
+	// Every field is committed to
+	for _, change := range []func(e *Entry){
+		func(e *Entry) { e.Seq++ },
+		func(e *Entry) { e.Kind = "danmaku" },
+		func(e *Entry) { e.Op = OpEdit },
+		func(e *Entry) { e.Ref = "other" },
+		func(e *Entry) { e.Digest[0]++ },
+		func(e *Entry) { e.Prev[0]++ },
+	} {
+		entry := entries[1]
+		change(&entry)
+		require.NotEqual(t, entries[1].Hash, entry.ComputeHash())
+	}
+
+	// Lengths keep the fields apart
+	a := Append(nil, "ab", "c", "", Hash{})
+	b := Append(nil, "a", "bc", "", Hash{})
+	require.NotEqual(t, a.Hash, b.Hash)
+}
+
+func TestHashJSON(t *testing.T) {
+	h := Digest([]byte("phantom"))
+	data, err := json.Marshal(h)
+	require.NoError(t, err)
+	require.Equal(t, `"`+h.String()+`"`, string(data))
+
+	var got Hash
+	require.NoError(t, json.Unmarshal(data, &got))
+	require.Equal(t, h, got)
+
+	require.Error(t, json.Unmarshal([]byte(`"abcd"`), &got))
+	require.Error(t, json.Unmarshal([]byte(`"xyz"`), &got))
+}
+
+func TestMerkle(t *testing.T) {
+	for size := 1; size <= 17; size++ {
+		entries := chain(size)
+		leaves := hashes(entries)
+		root := MerkleRoot(leaves)
+		for i := range leaves {
+			path := InclusionPath(leaves, i)
+			require.True(t, VerifyInclusion(leaves[i], int64(i), int64(size), path, root), "%d of %d", i, size)
+			require.False(t, VerifyInclusion(leaves[i], int64(i), int64(i), path, root), "%d of %d", i, size)
+			if size > 1 {
+				require.False(t, VerifyInclusion(leaves[(i+1)%size], int64(i), int64(size), path, root))
+				require.False(t, VerifyInclusion(leaves[i], int64((i+1)%size), int64(size), path, root))
+			}
+		}
+	}
+	// An odd tree isn't a balanced one padded
+	leaves := hashes(chain(3))
+	require.NotEqual(t, MerkleRoot(leaves), MerkleRoot(append(leaves, leaves[2])))
+}
+
+func TestProof(t *testing.T) {
+	entries := chain(10)[4:8]
+	proof, err := NewProof(entries, 6)
+	require.NoError(t, err)
+	require.Equal(t, Checkpoint{From: 4, To: 8, Root: MerkleRoot(hashes(entries))}, proof.Checkpoint)
+	require.Equal(t, entries[2], proof.Entry)
+	require.NoError(t, proof.Verify())
+
+	// A client gets it as JSON
+	data, err := json.Marshal(proof)
+	require.NoError(t, err)
+	var got Proof
+	require.NoError(t, json.Unmarshal(data, &got))
+	require.NoError(t, got.Verify())
+
+	edited := proof
+	edited.Entry.Digest = Digest([]byte("edited"))
+	require.Equal(t, errEntryHash, edited.Verify())
+
+	rehashed := edited
+	rehashed.Entry.Hash = rehashed.Entry.ComputeHash()
+	require.Equal(t, errInclusionPath, rehashed.Verify())
+
+	moved := proof
+	moved.Checkpoint.From = 7
+	require.Equal(t, errNotCovered, moved.Verify())
+
+	_, err = NewProof(entries, 8)
+	require.Error(t, err)
+}
+
+func TestVerify(t *testing.T) {
+	entries := chain(6)
+	entries = append(entries, Append(&entries[5], "comment", OpEdit, "ref1", Digest([]byte("edited"))))
+	entries = append(entries, Append(&entries[6], "comment", OpDelete, "ref2", Hash{}))
+	checkpoints := []Checkpoint{NewCheckpoint(entries[:4]), NewCheckpoint(entries[4:8])}
+	current := map[string]Hash{}
+	for _, entry := range entries {
+		current[entry.Ref] = entry.Digest
+	}
+	delete(current, "ref2")
+
+	require.Empty(t, Verify(entries, checkpoints, current))
+	// Not everything is checkpointed yet
+	require.Empty(t, Verify(entries, checkpoints[:1], current))
+
+	t.Run("Content", func(t *testing.T) {
+		content := map[string]Hash{}
+		for ref, digest := range current {
+			content[ref] = digest
+		}
+		content["ref0"] = Digest([]byte("edited"))
+		delete(content, "ref3")
+		content["ref2"] = Hash{}
+
+		require.Equal(t, []Problem{
+			{Seq: 0, Ref: "ref0", Reason: ProblemEdited},
+			{Seq: 7, Ref: "ref2", Reason: ProblemRestored},
+			{Seq: 3, Ref: "ref3", Reason: ProblemDeleted},
+		}, Verify(entries, checkpoints, content))
+	})
+
+	t.Run("EditedEntry", func(t *testing.T) {
+		tampered := append([]Entry(nil), entries...)
+		tampered[1].Digest = Digest([]byte("edited"))
+		require.Equal(t, []Problem{{Seq: 1, Ref: "ref1", Reason: ProblemTampered}}, Verify(tampered, checkpoints, current))
+	})
+
+	t.Run("RewrittenChain", func(t *testing.T) {
+		// Every entry after the edited one is hashed again, the checkpoints still tell
+		tampered := append([]Entry(nil), entries[:3]...)
+		tampered[2] = Append(&tampered[1], "comment", OpAdd, "ref2", Digest([]byte("edited")))
+		for _, entry := range entries[3:] {
+			tampered = append(tampered, Append(&tampered[len(tampered)-1], entry.Kind, entry.Op, entry.Ref, entry.Digest))
+		}
+		require.Equal(t, []Problem{
+			{Seq: 0, Reason: ProblemCheckpoint},
+			{Seq: 4, Reason: ProblemCheckpoint},
+		}, Verify(tampered, checkpoints, current))
+	})
+
+	t.Run("DeletedEntry", func(t *testing.T) {
+		tampered := append(append([]Entry(nil), entries[:2]...), entries[3:]...)
+		require.Equal(t, []Problem{{Seq: 2, Reason: ProblemMissing}}, Verify(tampered, checkpoints, current))
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		require.Equal(t, []Problem{
+			{Seq: 6, Reason: ProblemMissing},
+			{Seq: 1, Ref: "ref1", Reason: ProblemEdited},
+			{Seq: 2, Ref: "ref2", Reason: ProblemDeleted},
+		}, Verify(entries[:6], checkpoints, current))
+	})
+}
@@ -0,0 +1,77 @@
+package ledger
+
+import "crypto/sha256"
+
+// The Merkle trees are the ones of RFC 9162, the leaves being entry hashes
+
+func leafHash(leaf Hash) Hash {
+	return sha256.Sum256(append([]byte{0}, leaf[:]...))
+}
+
+func nodeHash(left, right Hash) Hash {
+	b := make([]byte, 0, 1+2*len(left))
+	b = append(b, 1)
+	b = append(b, left[:]...)
+	b = append(b, right[:]...)
+	return sha256.Sum256(b)
+}
+
+// split is the largest power of 2 smaller than n, n > 1
+func split(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+// MerkleRoot is the root of the tree of leaves
+func MerkleRoot(leaves []Hash) Hash {
+	switch len(leaves) {
+	case 0:
+		return sha256.Sum256(nil)
+	case 1:
+		return leafHash(leaves[0])
+	}
+	k := split(len(leaves))
+	return nodeHash(MerkleRoot(leaves[:k]), MerkleRoot(leaves[k:]))
+}
+
+// InclusionPath is the audit path of leaves[index], from the leaf up
+func InclusionPath(leaves []Hash, index int) []Hash {
+	if len(leaves) <= 1 {
+		return nil
+	}
+	k := split(len(leaves))
+	if index < k {
+		return append(InclusionPath(leaves[:k], index), MerkleRoot(leaves[k:]))
+	}
+	return append(InclusionPath(leaves[k:], index-k), MerkleRoot(leaves[:k]))
+}
+
+// VerifyInclusion tells whether path proves that leaf is leaf index
+// of a tree of size leaves with root
+func VerifyInclusion(leaf Hash, index, size int64, path []Hash, root Hash) bool {
+	if index < 0 || index >= size {
+		return false
+	}
+	fn, sn := index, size-1
+	r := leafHash(leaf)
+	for _, p := range path {
+		if sn == 0 {
+			return false
+		}
+		if fn&1 == 1 || fn == sn {
+			r = nodeHash(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = nodeHash(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	return sn == 0 && r == root
+}
@@ -0,0 +1,80 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Checkpoint is the Merkle root of the hashes of the entries From to To, To left out
+type Checkpoint struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+	Root Hash  `json:"root"`
+}
+
+// Covers tells whether the checkpoint is of the entry seq
+func (c Checkpoint) Covers(seq int64) bool {
+	return c.From <= seq && seq < c.To
+}
+
+// NewCheckpoint checkpoints entries, which follow each other
+func NewCheckpoint(entries []Entry) Checkpoint {
+	checkpoint := Checkpoint{Root: MerkleRoot(hashes(entries))}
+	if len(entries) > 0 {
+		checkpoint.From = entries[0].Seq
+		checkpoint.To = entries[len(entries)-1].Seq + 1
+	}
+	return checkpoint
+}
+
+// Proof proves that Entry is in the log, under the root of Checkpoint.
+// Path is the audit path of the entry in the tree of the checkpoint
+type Proof struct {
+	Entry      Entry      `json:"entry"`
+	Checkpoint Checkpoint `json:"checkpoint"`
+	Path       []Hash     `json:"path"`
+}
+
+var (
+	errEntryHash     = errors.New("the entry doesn't match its hash")
+	errNotCovered    = errors.New("the checkpoint is not of the entry")
+	errInclusionPath = errors.New("the path doesn't lead to the root of the checkpoint")
+)
+
+// NewProof proves that entry seq is under the checkpoint of entries
+func NewProof(entries []Entry, seq int64) (Proof, error) {
+	checkpoint := NewCheckpoint(entries)
+	if !checkpoint.Covers(seq) {
+		return Proof{}, fmt.Errorf("entry %d is not in %d to %d", seq, checkpoint.From, checkpoint.To)
+	}
+	index := int(seq - checkpoint.From)
+	return Proof{
+		Entry:      entries[index],
+		Checkpoint: checkpoint,
+		Path:       InclusionPath(hashes(entries), index),
+	}, nil
+}
+
+// Verify checks the proof on its own. Whoever checks it should also know
+// the root of the checkpoint from elsewhere, such as from an earlier proof
+func (p Proof) Verify() error {
+	if p.Entry.ComputeHash() != p.Entry.Hash {
+		return errEntryHash
+	}
+	if !p.Checkpoint.Covers(p.Entry.Seq) {
+		return errNotCovered
+	}
+	size := p.Checkpoint.To - p.Checkpoint.From
+	if !VerifyInclusion(p.Entry.Hash, p.Entry.Seq-p.Checkpoint.From, size, p.Path, p.Checkpoint.Root) {
+		return errInclusionPath
+	}
+	return nil
+}
+
+func hashes(entries []Entry) []Hash {
+	leaves := make([]Hash, len(entries))
+	for i, entry := range entries {
+		leaves[i] = entry.Hash
+	}
+	return leaves
+}
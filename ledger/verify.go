@@ -0,0 +1,85 @@
+package ledger
+
+import "fmt"
+
+// The reasons of a Problem
+const (
+	ProblemTampered   = "the entry doesn't match its hash"
+	ProblemBroken     = "the entry doesn't follow the one before"
+	ProblemMissing    = "entries are missing"
+	ProblemCheckpoint = "the checkpoint doesn't match the entries"
+	ProblemEdited     = "edited without an entry"
+	ProblemDeleted    = "deleted without an entry"
+	ProblemRestored   = "back after its deletion"
+)
+
+// Problem is something wrong with the log, or with what it records
+type Problem struct {
+	Seq    int64  `json:"seq"`
+	Ref    string `json:"ref,omitempty"`
+	Reason string `json:"reason"`
+}
+
+func (p Problem) String() string {
+	if p.Ref == "" {
+		return fmt.Sprintf("entry %d: %s", p.Seq, p.Reason)
+	}
+	return fmt.Sprintf("entry %d of %s: %s", p.Seq, p.Ref, p.Reason)
+}
+
+// Verify checks the whole log, entries from the first in order, against
+// its checkpoints and against current, the digest of the content of each
+// Ref that is still there. Whatever was edited or deleted without going
+// through the log is found, and so is any change to the log itself
+func Verify(entries []Entry, checkpoints []Checkpoint, current map[string]Hash) []Problem {
+	var problems []Problem
+
+	var prev Hash
+	for i, entry := range entries {
+		if entry.Seq != int64(i) {
+			// The rest can't line up with the checkpoints anymore
+			return append(problems, Problem{Seq: int64(i), Reason: ProblemMissing})
+		}
+		if entry.ComputeHash() != entry.Hash {
+			problems = append(problems, Problem{Seq: entry.Seq, Ref: entry.Ref, Reason: ProblemTampered})
+		}
+		if entry.Prev != prev {
+			problems = append(problems, Problem{Seq: entry.Seq, Ref: entry.Ref, Reason: ProblemBroken})
+		}
+		prev = entry.Hash
+	}
+
+	for _, checkpoint := range checkpoints {
+		if checkpoint.To > int64(len(entries)) {
+			problems = append(problems, Problem{Seq: int64(len(entries)), Reason: ProblemMissing})
+			continue
+		}
+		if MerkleRoot(hashes(entries[checkpoint.From:checkpoint.To])) != checkpoint.Root {
+			problems = append(problems, Problem{Seq: checkpoint.From, Reason: ProblemCheckpoint})
+		}
+	}
+
+	latest := make(map[string]Entry)
+	var refs []string
+	for _, entry := range entries {
+		if _, ok := latest[entry.Ref]; !ok {
+			refs = append(refs, entry.Ref)
+		}
+		latest[entry.Ref] = entry
+	}
+	for _, ref := range refs {
+		entry := latest[ref]
+		digest, ok := current[ref]
+		switch {
+		case entry.Op == OpDelete:
+			if ok {
+				problems = append(problems, Problem{Seq: entry.Seq, Ref: ref, Reason: ProblemRestored})
+			}
+		case !ok:
+			problems = append(problems, Problem{Seq: entry.Seq, Ref: ref, Reason: ProblemDeleted})
+		case digest != entry.Digest:
+			problems = append(problems, Problem{Seq: entry.Seq, Ref: ref, Reason: ProblemEdited})
+		}
+	}
+	return problems
+}
@@ -0,0 +1,116 @@
+package live
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ClientConfig sets up the connection of a viewer
+type ClientConfig struct {
+	// Buffer is how many events wait for a slow viewer before it is dropped
+	Buffer int
+	// DanmakuRate is how many danmaku a second a viewer gets, with bursts of
+	// DanmakuBurst. The ones past it are skipped, comments are always sent
+	DanmakuRate  float64
+	DanmakuBurst int
+	// WriteWait is how long writing a message can take
+	WriteWait time.Duration
+	// PingPeriod is how often the viewer is pinged, it has PongWait to answer
+	PingPeriod time.Duration
+	PongWait   time.Duration
+}
+
+var DefaultClientConfig = ClientConfig{
+	Buffer:       64,
+	DanmakuRate:  15,
+	DanmakuBurst: 30,
+	WriteWait:    10 * time.Second,
+	PingPeriod:   50 * time.Second,
+	PongWait:     60 * time.Second,
+}
+
+// maxMessageSize is the largest message a viewer can send. Viewers only
+// listen, so there is nothing to read but control frames
+const maxMessageSize = 512
+
+// closeTooSlow is the close reason sent to a viewer the hub dropped
+const closeTooSlow = "too slow"
+
+// Serve pushes the events of topic to a viewer until either end closes
+// the connection, and closes it
+func Serve(conn *websocket.Conn, hub Hub, topic string, config ClientConfig) {
+	sub := hub.Subscribe(topic, config.Buffer)
+	defer hub.Unsubscribe(sub)
+	defer conn.Close()
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadLimit(maxMessageSize)
+		conn.SetReadDeadline(time.Now().Add(config.PongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(config.PongWait))
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	limiter := newLimiter(config.DanmakuRate, config.DanmakuBurst, time.Now)
+	ticker := time.NewTicker(config.PingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case msg, ok := <-sub.C:
+			conn.SetWriteDeadline(time.Now().Add(config.WriteWait))
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.ClosePolicyViolation, closeTooSlow))
+				return
+			}
+			if msg.Type == EventDanmaku && !limiter.allow() {
+				continue
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, msg.Data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(config.WriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// limiter is a token bucket that fills with rate tokens a second, up to burst
+type limiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func newLimiter(rate float64, burst int, now func() time.Time) *limiter {
+	return &limiter{rate: rate, burst: float64(burst), tokens: float64(burst), last: now(), now: now}
+}
+
+func (l *limiter) allow() bool {
+	now := l.now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
@@ -0,0 +1,119 @@
+package live
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Now()
+	l := newLimiter(2, 3, func() time.Time { return now })
+
+	for i := 0; i < 3; i++ {
+		require.True(t, l.allow())
+	}
+	require.False(t, l.allow())
+
+	now = now.Add(500 * time.Millisecond)
+	require.True(t, l.allow())
+	require.False(t, l.allow())
+
+	// It fills up to the burst only
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		require.True(t, l.allow())
+	}
+	require.False(t, l.allow())
+}
+
+// dial serves topic of hub on a test server and connects a viewer to it
+func dial(t *testing.T, hub Hub, config ClientConfig) *websocket.Conn {
+	var upgrader websocket.Upgrader
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		Serve(conn, hub, "movie", config)
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// waitForSubscribers waits for the viewers to be subscribed, which Serve does after the upgrade
+func waitForSubscribers(t *testing.T, hub *MemoryHub, n int) {
+	require.Eventually(t, func() bool { return hub.Subscribers("movie") == n }, time.Second, 5*time.Millisecond)
+}
+
+func TestServe(t *testing.T) {
+	hub := NewMemoryHub()
+	config := DefaultClientConfig
+	config.DanmakuRate = 0.001
+	config.DanmakuBurst = 2
+	conn := dial(t, hub, config)
+	waitForSubscribers(t, hub, 1)
+
+	// Only the burst of danmaku gets through, comments always do
+	for i := 0; i < 4; i++ {
+		require.NoError(t, hub.Publish("movie", Event{Type: EventDanmaku, Data: i}))
+	}
+	require.NoError(t, hub.Publish("movie", Event{Type: EventComment, Data: "comment"}))
+
+	var got []Event
+	for i := 0; i < 3; i++ {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, data, err := conn.ReadMessage()
+		require.NoError(t, err)
+		var event Event
+		require.NoError(t, json.Unmarshal(data, &event))
+		got = append(got, event)
+	}
+	require.Equal(t, []Event{
+		{Type: EventDanmaku, Data: float64(0)},
+		{Type: EventDanmaku, Data: float64(1)},
+		{Type: EventComment, Data: "comment"},
+	}, got)
+
+	// The viewer leaving unsubscribes it
+	conn.Close()
+	waitForSubscribers(t, hub, 0)
+}
+
+func TestServeTooSlow(t *testing.T) {
+	hub := NewMemoryHub()
+	config := DefaultClientConfig
+	config.Buffer = 1
+	conn := dial(t, hub, config)
+	waitForSubscribers(t, hub, 1)
+
+	// The subscriber is dropped as if Serve couldn't keep up
+	for _, sub := range hubSubscriptions(hub) {
+		hub.Unsubscribe(sub)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err := conn.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation))
+	require.Contains(t, err.Error(), closeTooSlow)
+}
+
+func hubSubscriptions(hub *MemoryHub) []*Subscription {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	var subs []*Subscription
+	for sub := range hub.topics["movie"] {
+		subs = append(subs, sub)
+	}
+	return subs
+}
@@ -0,0 +1,125 @@
+// Package live pushes what is posted on a movie, danmaku and comments,
+// to the viewers watching it over WebSocket
+package live
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// The types of Event
+const (
+	EventDanmaku = "danmaku"
+	EventComment = "comment"
+)
+
+// Event is something posted on a topic, a movie or an episode,
+// sent to its viewers as JSON
+type Event struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// Message is an Event encoded once for every subscriber
+type Message struct {
+	Type string
+	Data []byte
+}
+
+// Hub hands the events published on each topic to its subscribers.
+// MemoryHub serves the viewers of a single server; a hub fed by Mongo change
+// streams would let several servers share them, with Publish left to the database
+type Hub interface {
+	// Subscribe starts receiving the events of topic. Up to buffer of them
+	// wait for a slow subscriber before the hub drops it
+	Subscribe(topic string, buffer int) *Subscription
+	Unsubscribe(sub *Subscription)
+	Publish(topic string, event Event) error
+}
+
+// Subscription receives the events of a topic on C,
+// which is closed when the hub drops a subscriber that fell behind
+type Subscription struct {
+	topic string
+	c     chan Message
+	C     <-chan Message
+}
+
+// MemoryHub is a Hub in the memory of the server
+type MemoryHub struct {
+	mu     sync.RWMutex
+	topics map[string]map[*Subscription]struct{}
+}
+
+func NewMemoryHub() *MemoryHub {
+	return &MemoryHub{topics: map[string]map[*Subscription]struct{}{}}
+}
+
+func (h *MemoryHub) Subscribe(topic string, buffer int) *Subscription {
+	c := make(chan Message, buffer)
+	sub := &Subscription{topic: topic, c: c, C: c}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.topics[topic] == nil {
+		h.topics[topic] = map[*Subscription]struct{}{}
+	}
+	h.topics[topic][sub] = struct{}{}
+	return sub
+}
+
+func (h *MemoryHub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(sub)
+}
+
+// remove closes sub unless it is gone already, h.mu has to be locked
+func (h *MemoryHub) remove(sub *Subscription) {
+	subs := h.topics[sub.topic]
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(h.topics, sub.topic)
+	}
+	close(sub.c)
+}
+
+// Publish never waits for a subscriber: the ones whose buffer is full
+// are dropped, so that one slow viewer doesn't hold up the others
+func (h *MemoryHub) Publish(topic string, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	msg := Message{Type: event.Type, Data: data}
+
+	var slow []*Subscription
+	h.mu.RLock()
+	for sub := range h.topics[topic] {
+		select {
+		case sub.c <- msg:
+		default:
+			slow = append(slow, sub)
+		}
+	}
+	h.mu.RUnlock()
+
+	if len(slow) > 0 {
+		h.mu.Lock()
+		for _, sub := range slow {
+			h.remove(sub)
+		}
+		h.mu.Unlock()
+	}
+	return nil
+}
+
+// Subscribers is how many subscribers topic has
+func (h *MemoryHub) Subscribers(topic string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.topics[topic])
+}
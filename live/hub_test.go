@@ -0,0 +1,56 @@
+package live
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryHubPublish(t *testing.T) {
+	hub := NewMemoryHub()
+	a := hub.Subscribe("movie", 1)
+	b := hub.Subscribe("movie", 1)
+	other := hub.Subscribe("other", 1)
+	require.Equal(t, 2, hub.Subscribers("movie"))
+
+	require.NoError(t, hub.Publish("movie", Event{Type: EventDanmaku, Data: "hi"}))
+	for _, sub := range []*Subscription{a, b} {
+		msg := <-sub.C
+		require.Equal(t, EventDanmaku, msg.Type)
+		var event Event
+		require.NoError(t, json.Unmarshal(msg.Data, &event))
+		require.Equal(t, "hi", event.Data)
+	}
+	require.Len(t, other.C, 0)
+
+	hub.Unsubscribe(a)
+	_, ok := <-a.C
+	require.False(t, ok)
+	require.Equal(t, 1, hub.Subscribers("movie"))
+	// Unsubscribing twice is fine
+	hub.Unsubscribe(a)
+}
+
+func TestMemoryHubDropsSlowSubscriber(t *testing.T) {
+	hub := NewMemoryHub()
+	slow := hub.Subscribe("movie", 1)
+	fast := hub.Subscribe("movie", 2)
+
+	require.NoError(t, hub.Publish("movie", Event{Type: EventComment}))
+	require.NoError(t, hub.Publish("movie", Event{Type: EventComment}))
+
+	require.Equal(t, 1, hub.Subscribers("movie"))
+	require.Len(t, fast.C, 2)
+	// The slow one keeps what it had buffered, then its channel is closed
+	_, ok := <-slow.C
+	require.True(t, ok)
+	_, ok = <-slow.C
+	require.False(t, ok)
+	hub.Unsubscribe(slow)
+}
+
+func TestMemoryHubPublishError(t *testing.T) {
+	hub := NewMemoryHub()
+	require.Error(t, hub.Publish("movie", Event{Type: EventComment, Data: make(chan int)}))
+}
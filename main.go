@@ -2,11 +2,20 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"log"
+	"os"
 	"phantom/api"
+	"phantom/backup"
+	memdb "phantom/db/memory"
 	db "phantom/db/mongo"
+	sqlitedb "phantom/db/sqlite"
+	"phantom/importer"
+	"phantom/ledger"
 	"phantom/util"
 	"time"
 )
@@ -18,24 +27,313 @@ func main() {
 		log.Fatal("cannot load config: ", err)
 	}
 
-	// Connect mongodb, Timeout: 10ms
-	serverAPIOptions := options.ServerAPI(options.ServerAPIVersion1)
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	mongoClient, err := mongo.Connect(ctx, options.Client().ApplyURI(config.MongoSource).SetServerAPIOptions(serverAPIOptions))
-	defer cancel()
-	if err != nil {
-		log.Fatal("cannot connect mongodb: ", err)
+	var store db.Store
+	switch config.MongoDriver {
+	case "memory":
+		// Nothing is kept, so there is nothing to maintain either
+		if len(os.Args) > 1 {
+			log.Fatal("cannot run ", os.Args[1], ": the memory store has no data to run it on")
+		}
+		store = memdb.NewStore()
+	case "sqlite":
+		sqliteStore, err := sqlitedb.Open(context.Background(), config.SQLiteFile)
+		if err != nil {
+			log.Fatal("cannot open sqlite: ", err)
+		}
+		defer sqliteStore.Close()
+		if len(os.Args) > 1 {
+			err = runCommand(context.Background(), config, sqliteStore, os.Args[1:])
+			if err != nil {
+				log.Fatal("cannot run ", os.Args[1], ": ", err)
+			}
+			return
+		}
+		store = sqliteStore
+	case "", "mongo":
+		// Connect mongodb, Timeout: 10ms
+		serverAPIOptions := options.ServerAPI(options.ServerAPIVersion1)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		mongoClient, err := mongo.Connect(ctx, options.Client().ApplyURI(config.MongoSource).SetServerAPIOptions(serverAPIOptions))
+		defer cancel()
+		if err != nil {
+			log.Fatal("cannot connect mongodb: ", err)
+		}
+
+		mongoDatabase := mongoClient.Database("phantom")
+		migrator := db.NewMigrator(mongoDatabase)
+		if len(os.Args) > 1 && os.Args[1] == "migrate" {
+			err = runMigrate(context.Background(), migrator, os.Args[2:])
+			if err != nil {
+				log.Fatal("cannot migrate: ", err)
+			}
+			return
+		}
+		// The server refuses a database its migrations haven't made
+		err = migrator.Check(context.Background())
+		if err != nil {
+			log.Fatal("cannot use mongodb: ", err)
+		}
+		mongoStore := db.NewMongoStore(mongoDatabase)
+		if len(os.Args) > 1 {
+			err = runCommand(context.Background(), config, mongoStore, os.Args[1:])
+			if err != nil {
+				log.Fatal("cannot run ", os.Args[1], ": ", err)
+			}
+			return
+		}
+		if config.BackupInterval > 0 {
+			go backup.Schedule(context.Background(), mongoStore, config.BackupDir, config.BackupInterval, config.BackupKeep, backup.Options{})
+		}
+		store = mongoStore
+	default:
+		log.Fatal("unknown MONGO_DRIVE ", config.MongoDriver)
 	}
 
-	mongoDatabase := mongoClient.Database("phantom")
-	mongoStore := db.NewMongoStore(mongoDatabase)
-	server, err := api.NewServer(config, mongoStore)
+	server, err := api.NewServer(config, store)
 	if err != nil {
 		log.Fatal("cannot create server:", err)
 	}
+	err = server.BuildIndexes(context.Background())
+	if err != nil {
+		log.Fatal("cannot build search indexes:", err)
+	}
+	go server.ScheduleLedgerCheckpoints(context.Background())
 	err = server.Start(config.ServerAddress)
 	if err != nil {
 		log.Fatal("cannot start server:", err)
 	}
 
 }
+
+// ledgerStore is a store that can check its ledger, which the stores
+// that keep their data do
+type ledgerStore interface {
+	db.Store
+	VerifyLedger(ctx context.Context) ([]ledger.Problem, error)
+}
+
+// runCommand runs a maintenance command instead of the server, such as
+//
+//	phantom migrate-people   link the movies to people by the names of their credits
+//	phantom make-admin NAME  let the user curate the catalog, such as collections
+//	phantom verify-ledger    find the comments and danmaku edited or deleted behind the ledger
+//	phantom import FILE      add or update the movies of a catalogue, see runImport
+//	phantom backup           write an archive of the database, see runBackup
+//	phantom restore FILE     replace the data of the database with an archive, see runRestore
+//
+// migrate-people, backup and restore only apply to MongoDB, whose movies may
+// predate the people, and which is not a file to copy. MongoDB has runMigrate
+// too, as 'phantom migrate'
+func runCommand(ctx context.Context, config util.Config, store ledgerStore, args []string) error {
+	switch args[0] {
+	case "backup", "restore":
+		mongoStore, ok := store.(*db.MongoStore)
+		if !ok {
+			return errors.New(args[0] + " only applies to MongoDB")
+		}
+		if args[0] == "backup" {
+			return runBackup(ctx, mongoStore, config.BackupDir, args[1:])
+		}
+		return runRestore(ctx, mongoStore, args[1:])
+	case "migrate-people":
+		mongoStore, ok := store.(*db.MongoStore)
+		if !ok {
+			return errors.New("migrate-people only applies to MongoDB")
+		}
+		result, err := mongoStore.MigratePeople(ctx)
+		if err != nil {
+			return err
+		}
+		log.Printf("linked %d movies, added %d people", result.Movies, result.People)
+		return nil
+	case "make-admin":
+		if len(args) != 2 {
+			return errors.New("usage: make-admin NAME")
+		}
+		user, err := store.GetUserByName(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		user.Role = db.UserRoleAdmin
+		_, err = store.UpdateUserRole(ctx, user)
+		return err
+	case "verify-ledger":
+		problems, err := store.VerifyLedger(ctx)
+		if err != nil {
+			return err
+		}
+		for _, problem := range problems {
+			log.Print(problem)
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("the ledger has %d problems", len(problems))
+		}
+		log.Print("the ledger is intact")
+		return nil
+	case "import":
+		return runImport(ctx, store, args[1:])
+	}
+	return errors.New("unknown command " + args[0])
+}
+
+// runImport imports a file of movies, such as the mflix sample dataset
+//
+//	phantom import [-format json|ndjson|csv] [-columns field=column,...] [-batch N] [-restart] FILE
+//
+// The format is that of the extension of the file when it is not given.
+// An import that was stopped goes on from where it got to, unless -restart
+// is given, and an import can be run again as the movies are upserted
+func runImport(ctx context.Context, store db.Store, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", "", "json, ndjson or csv")
+	columns := flags.String("columns", "", "the columns of a CSV file for the fields, like title=Series_Title,year=Released_Year")
+	batchSize := flags.Int("batch", importer.DefaultBatchSize, "the number of movies to upsert at once")
+	restart := flags.Bool("restart", false, "import the file from its first row")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: import [-format json|ndjson|csv] [-columns field=column,...] [-batch N] [-restart] FILE")
+	}
+	path := flags.Arg(0)
+	mapping, err := importer.ParseColumns(*columns)
+	if err != nil {
+		return err
+	}
+	if *restart {
+		if err = os.Remove(importer.CheckpointPath(path)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	progress, err := importer.ImportFile(ctx, store, path, importer.Options{
+		Format:    *format,
+		Columns:   mapping,
+		BatchSize: *batchSize,
+		Progress: func(progress importer.Progress) {
+			log.Printf("row %d: %d added, %d updated, %d unchanged, %d failed",
+				progress.Rows, progress.Inserted, progress.Updated, progress.Unchanged, progress.Failed)
+		},
+	})
+	for _, rowErr := range progress.Errors {
+		log.Printf("row %d: %s", rowErr.Row, rowErr.Error)
+	}
+	if progress.Failed > int64(len(progress.Errors)) {
+		log.Printf("and %d more rows failed", progress.Failed-int64(len(progress.Errors)))
+	}
+	if err != nil {
+		return fmt.Errorf("stopped after row %d, run it again to go on: %w", progress.Rows, err)
+	}
+	return nil
+}
+
+// runBackup writes an archive of every collection to a directory, BACKUP_DIR
+// unless -dir is given, see backup.Write
+//
+//	phantom backup [-omit-passwords] [-dir DIR]
+func runBackup(ctx context.Context, database backup.Database, dir string, args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	omitPasswords := flags.Bool("omit-passwords", false, "leave the password hashes of the users out")
+	flags.StringVar(&dir, "dir", dir, "the directory to write the archive to")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return errors.New("usage: backup [-omit-passwords] [-dir DIR]")
+	}
+	path, manifest, err := backup.WriteFile(ctx, database, dir, backup.Options{OmitPasswords: *omitPasswords})
+	if err != nil {
+		return err
+	}
+	for _, collection := range manifest.Collections {
+		log.Printf("%s: %d documents", collection.Name, collection.Documents)
+	}
+	log.Printf("wrote %s, schema version %d", path, manifest.SchemaVersion)
+	return nil
+}
+
+// runRestore replaces the collections of the database with those of an
+// archive, see backup.Restore. -dry-run only verifies the archive
+//
+//	phantom restore [-dry-run] FILE
+func runRestore(ctx context.Context, database backup.Database, args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "verify the archive and tell what would be restored")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: restore [-dry-run] FILE")
+	}
+	report, err := backup.Restore(ctx, database, flags.Arg(0), *dryRun)
+	if err != nil {
+		return err
+	}
+	log.Printf("the archive of %s has schema version %d", report.CreatedAt.Format(time.RFC3339), report.SchemaVersion)
+	for _, collection := range report.Collections {
+		log.Printf("%s: %d documents", collection.Name, collection.Documents)
+	}
+	for _, name := range report.Kept {
+		log.Printf("%s: kept, the archive doesn't have it", name)
+	}
+	if report.OmitPasswords {
+		log.Print("the archive has no passwords, the users have to reset theirs")
+	}
+	if *dryRun {
+		if report.SchemaVersion < report.LatestSchemaVersion {
+			log.Printf("the data would be migrated to schema version %d", report.LatestSchemaVersion)
+		}
+		log.Print("the archive is intact, nothing was restored")
+		return nil
+	}
+	for _, migration := range report.Migrations {
+		log.Printf("applied %s", migration)
+	}
+	log.Print("restored")
+	return nil
+}
+
+// runMigrate runs the schema migrations of MongoDB
+//
+//	phantom migrate up      run the migrations that are not applied
+//	phantom migrate down    undo the latest applied migration
+//	phantom migrate status  list the migrations and whether they are applied
+func runMigrate(ctx context.Context, migrator *db.Migrator, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: migrate up|down|status")
+	}
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			log.Printf("applied %d %s", migration.Version, migration.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			log.Print("the database is up to date")
+		}
+		return err
+	case "down":
+		migration, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		log.Printf("undid %d %s", migration.Version, migration.Name)
+		return nil
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Dirty {
+				state = "dirty"
+			} else if status.Applied {
+				state = "applied " + status.AppliedAt.Time().Format(time.RFC3339)
+			}
+			log.Printf("%d %s: %s", status.Version, status.Name, state)
+		}
+		return nil
+	}
+	return errors.New("usage: migrate up|down|status")
+}
@@ -0,0 +1,110 @@
+package search
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Token is a single analyzed term, with its position in the token stream
+// and its byte offsets in the original text
+type Token struct {
+	Term     string
+	Position int
+	Start    int
+	End      int
+}
+
+// Analyzer turns text into the tokens that get indexed or searched for
+type Analyzer interface {
+	Analyze(text string) []Token
+}
+
+// QueryAnalyzer is an Analyzer that analyzes queries differently from documents,
+// when documents get extra tokens a query shouldn't have to match
+type QueryAnalyzer interface {
+	Analyzer
+	AnalyzeQuery(text string) []Token
+}
+
+// StandardAnalyzer lowercases words and strips their diacritics.
+// Chinese, Japanese and Korean text doesn't separate words with spaces,
+// so runs of those characters are split into overlapping bigrams instead
+type StandardAnalyzer struct{}
+
+func (StandardAnalyzer) Analyze(text string) []Token {
+	var tokens []Token
+	emit := func(start, end int) {
+		tokens = append(tokens, Token{
+			Term:     Normalize(text[start:end]),
+			Position: len(tokens),
+			Start:    start,
+			End:      end,
+		})
+	}
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case isCJK(r):
+			j, starts := runOf(text, i, isCJK)
+			if len(starts) == 1 {
+				emit(i, j)
+			}
+			for k := 0; k+1 < len(starts); k++ {
+				end := j
+				if k+2 < len(starts) {
+					end = starts[k+2]
+				}
+				emit(starts[k], end)
+			}
+			i = j
+		case isWordRune(r):
+			j := i
+			for j < len(text) {
+				r, size := utf8.DecodeRuneInString(text[j:])
+				if isCJK(r) || !isWordRune(r) {
+					break
+				}
+				j += size
+			}
+			emit(i, j)
+			i = j
+		default:
+			i += size
+		}
+	}
+	return tokens
+}
+
+// runOf returns the end of the run of characters starting at i for which in
+// is true, and the byte offset of every character in it
+func runOf(text string, i int, in func(rune) bool) (int, []int) {
+	var starts []int
+	for i < len(text) {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if !in(r) {
+			break
+		}
+		starts = append(starts, i)
+		i += size
+	}
+	return i, starts
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.Mn, r)
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
+
+// isSingleCJK reports whether term is one CJK character, which StandardAnalyzer
+// never indexes on its own because longer runs are stored as bigrams
+func isSingleCJK(term string) bool {
+	r, size := utf8.DecodeRuneInString(term)
+	return size == len(term) && isCJK(r)
+}
@@ -0,0 +1,33 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func terms(tokens []Token) []string {
+	var ts []string
+	for _, token := range tokens {
+		ts = append(ts, token.Term)
+	}
+	return ts
+}
+
+func TestStandardAnalyzer(t *testing.T) {
+	tokens := StandardAnalyzer{}.Analyze("Amélie's Sci-Fi, 2001!")
+	require.Equal(t, []string{"amelie", "s", "sci", "fi", "2001"}, terms(tokens))
+	require.Equal(t, "Amélie", "Amélie's Sci-Fi, 2001!"[tokens[0].Start:tokens[0].End])
+	for i, token := range tokens {
+		require.Equal(t, i, token.Position)
+	}
+}
+
+func TestStandardAnalyzerCJK(t *testing.T) {
+	text := "星际穿越 Interstellar 千と千尋"
+	tokens := StandardAnalyzer{}.Analyze(text)
+	require.Equal(t, []string{"星际", "际穿", "穿越", "interstellar", "千と", "と千", "千尋"}, terms(tokens))
+	require.Equal(t, "际穿", text[tokens[1].Start:tokens[1].End])
+
+	require.Equal(t, []string{"星"}, terms(StandardAnalyzer{}.Analyze("星")))
+}
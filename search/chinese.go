@@ -0,0 +1,256 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// learnedWordCost ranks learned titles and names above the bundled words
+	// they are made of, the most frequent bundled word costs 50
+	learnedWordCost = 60
+	// unknownCharCost is the cost of a character that is left on its own
+	unknownCharCost = 200
+	// maxLearnedWordLen keeps long titles out of the dictionary
+	maxLearnedWordLen = 16
+	// maxPinyinRun is the longest run of characters that is also indexed
+	// as a whole by its pinyin, which covers titles and names but not plots
+	maxPinyinRun = 12
+)
+
+// ChineseAnalyzer segments Chinese text into words instead of bigrams.
+//
+// Traditional characters are folded to simplified ones first, then every run
+// of Chinese characters is split into the cheapest sequence of dictionary words.
+// Documents index every word along with the shorter words inside it, each
+// character on its own, and the pinyin and pinyin initials of every word,
+// so "星际", "xingji" and "xjcy" all find 星际穿越. Queries are only segmented,
+// with the bundled dictionary alone, so that they match the same way whatever
+// was learned since a document was indexed.
+//
+// Characters take one position each, so a phrase of words of different
+// lengths keeps the gaps between them. Text other than Chinese is analyzed
+// like StandardAnalyzer does
+type ChineseAnalyzer struct {
+	mu      sync.RWMutex
+	learned map[string]struct{}
+	maxLen  int
+}
+
+var (
+	_ Analyzer      = (*ChineseAnalyzer)(nil)
+	_ QueryAnalyzer = (*ChineseAnalyzer)(nil)
+)
+
+func NewChineseAnalyzer() *ChineseAnalyzer {
+	loadDicts()
+	return &ChineseAnalyzer{learned: map[string]struct{}{}, maxLen: maxWordLen}
+}
+
+// AddWords teaches the analyzer words missing from the bundled dictionary,
+// such as movie titles and the names of people
+func (a *ChineseAnalyzer) AddWords(texts ...string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, text := range texts {
+		pieces := strings.FieldsFunc(ToSimplified(text), func(r rune) bool {
+			return !unicode.Is(unicode.Han, r)
+		})
+		for _, word := range pieces {
+			n := utf8.RuneCountInString(word)
+			if n < 2 || n > maxLearnedWordLen {
+				continue
+			}
+			if _, ok := wordCosts[word]; ok {
+				continue
+			}
+			a.learned[word] = struct{}{}
+			if n > a.maxLen {
+				a.maxLen = n
+			}
+		}
+	}
+}
+
+func (a *ChineseAnalyzer) Analyze(text string) []Token {
+	return a.analyze(text, false)
+}
+
+func (a *ChineseAnalyzer) AnalyzeQuery(text string) []Token {
+	return a.analyze(text, true)
+}
+
+func (a *ChineseAnalyzer) analyze(text string, query bool) []Token {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var tokens []Token
+	position := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case unicode.Is(unicode.Han, r):
+			j, starts := runOf(text, i, func(r rune) bool { return unicode.Is(unicode.Han, r) })
+			tokens = a.appendHan(tokens, text, starts, j, position, query)
+			position += len(starts)
+			i = j
+		case isCJK(r):
+			// Kana and Hangul are left to bigrams
+			j, starts := runOf(text, i, func(r rune) bool { return isCJK(r) && !unicode.Is(unicode.Han, r) })
+			if len(starts) == 1 {
+				tokens = append(tokens, Token{Term: Normalize(text[i:j]), Position: position, Start: i, End: j})
+			}
+			for k := 0; k+1 < len(starts); k++ {
+				end := j
+				if k+2 < len(starts) {
+					end = starts[k+2]
+				}
+				tokens = append(tokens, Token{
+					Term:     Normalize(text[starts[k]:end]),
+					Position: position + k,
+					Start:    starts[k],
+					End:      end,
+				})
+			}
+			position += len(starts)
+			i = j
+		case isWordRune(r):
+			j := i
+			for j < len(text) {
+				r, size := utf8.DecodeRuneInString(text[j:])
+				if isCJK(r) || !isWordRune(r) {
+					break
+				}
+				j += size
+			}
+			tokens = append(tokens, Token{Term: Normalize(text[i:j]), Position: position, Start: i, End: j})
+			position++
+			i = j
+		default:
+			i += size
+		}
+	}
+	return tokens
+}
+
+// appendHan analyzes the run of Chinese characters starting at the byte
+// offsets in starts and ending at end
+func (a *ChineseAnalyzer) appendHan(tokens []Token, text string, starts []int, end, position int, query bool) []Token {
+	runes := make([]rune, len(starts))
+	for k, start := range starts {
+		r, _ := utf8.DecodeRuneInString(text[start:])
+		runes[k] = toSimplified(r)
+	}
+	// emit adds the characters from up to to as a token
+	var run []Token
+	emit := func(term string, from, to int) {
+		tokenEnd := end
+		if to < len(starts) {
+			tokenEnd = starts[to]
+		}
+		run = append(run, Token{Term: term, Position: position + from, Start: starts[from], End: tokenEnd})
+	}
+	emitPinyin := func(from, to int) {
+		if fulls, initials, ok := PinyinReadings(string(runes[from:to])); ok {
+			for _, full := range fulls {
+				emit(full, from, to)
+			}
+			for _, initial := range initials {
+				emit(initial, from, to)
+			}
+		}
+	}
+
+	spans := a.segment(runes, query)
+	if query {
+		for _, span := range spans {
+			emit(string(runes[span[0]:span[1]]), span[0], span[1])
+		}
+		return append(tokens, run...)
+	}
+
+	single := make([]bool, len(runes))
+	for _, span := range spans {
+		from, to := span[0], span[1]
+		emit(string(runes[from:to]), from, to)
+		if to-from == 1 {
+			single[from] = true
+			continue
+		}
+		emitPinyin(from, to)
+		for i := from; i < to; i++ {
+			for j := i + 2; j <= to && j-i <= a.maxLen; j++ {
+				if i == from && j == to {
+					continue
+				}
+				if _, ok := a.cost(string(runes[i:j]), true); ok {
+					emit(string(runes[i:j]), i, j)
+					emitPinyin(i, j)
+				}
+			}
+		}
+	}
+	for k, r := range runes {
+		if !single[k] {
+			emit(string(r), k, k+1)
+		}
+	}
+	if len(spans) > 1 && len(runes) <= maxPinyinRun {
+		emitPinyin(0, len(runes))
+	}
+
+	// Keep the tokens in text order, longer ones first, which is what snippets expect
+	sort.SliceStable(run, func(i, j int) bool {
+		if run[i].Start != run[j].Start {
+			return run[i].Start < run[j].Start
+		}
+		return run[i].End > run[j].End
+	})
+	return append(tokens, run...)
+}
+
+// segment splits runes into the sequence of words with the lowest total cost,
+// returned as [from, to) spans. Learned words are left out for queries
+func (a *ChineseAnalyzer) segment(runes []rune, query bool) [][2]int {
+	maxLen := maxWordLen
+	if !query {
+		maxLen = a.maxLen
+	}
+
+	n := len(runes)
+	total := make([]int, n+1)
+	next := make([]int, n+1)
+	for i := n - 1; i >= 0; i-- {
+		total[i] = unknownCharCost + total[i+1]
+		next[i] = i + 1
+		for j := i + 2; j <= n && j-i <= maxLen; j++ {
+			cost, ok := a.cost(string(runes[i:j]), !query)
+			// On a tie the longer word wins
+			if ok && cost+total[j] <= total[i] {
+				total[i] = cost + total[j]
+				next[i] = j
+			}
+		}
+	}
+
+	var spans [][2]int
+	for i := 0; i < n; i = next[i] {
+		spans = append(spans, [2]int{i, next[i]})
+	}
+	return spans
+}
+
+func (a *ChineseAnalyzer) cost(word string, withLearned bool) (int, bool) {
+	if cost, ok := wordCosts[word]; ok {
+		return cost, true
+	}
+	if withLearned {
+		if _, ok := a.learned[word]; ok {
+			return learnedWordCost, true
+		}
+	}
+	return 0, false
+}
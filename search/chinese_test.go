@@ -0,0 +1,166 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	db "phantom/db/mongo"
+)
+
+func TestToSimplified(t *testing.T) {
+	require.Equal(t, "星际穿越 Interstellar", ToSimplified("星際穿越 Interstellar"))
+	require.Equal(t, "霸王别姬", ToSimplified("霸王別姬"))
+}
+
+func TestPinyin(t *testing.T) {
+	full, initials, ok := Pinyin("星际穿越")
+	require.True(t, ok)
+	require.Equal(t, "xingjichuanyue", full)
+	require.Equal(t, "xjcy", initials)
+
+	// Traditional characters read the same
+	full, _, ok = Pinyin("星際")
+	require.True(t, ok)
+	require.Equal(t, "xingji", full)
+
+	_, _, ok = Pinyin("星a")
+	require.False(t, ok)
+
+	// Every reading of a character that has several
+	fulls, initialsList, ok := PinyinReadings("长城")
+	require.True(t, ok)
+	require.ElementsMatch(t, []string{"changcheng", "zhangcheng"}, fulls)
+	require.ElementsMatch(t, []string{"cc", "zc"}, initialsList)
+}
+
+func TestChineseAnalyzerQuery(t *testing.T) {
+	analyzer := NewChineseAnalyzer()
+	tokens := analyzer.AnalyzeQuery("霸王別姬 Farewell")
+	require.Equal(t, []string{"霸王", "别", "姬", "farewell"}, terms(tokens))
+	// One position per character
+	require.Equal(t, []int{0, 2, 3, 4}, []int{tokens[0].Position, tokens[1].Position, tokens[2].Position, tokens[3].Position})
+	require.Equal(t, "霸王", "霸王別姬 Farewell"[tokens[0].Start:tokens[0].End])
+}
+
+func TestChineseAnalyzerIndex(t *testing.T) {
+	analyzer := NewChineseAnalyzer()
+	text := "星際穿越"
+	tokens := analyzer.Analyze(text)
+	require.Subset(t, terms(tokens), []string{"星际", "穿越", "星", "际", "穿", "越", "xingji", "xj", "xingjichuanyue", "xjcy"})
+	for _, token := range tokens {
+		if token.Term == "xjcy" {
+			require.Equal(t, text, text[token.Start:token.End])
+			require.Equal(t, 0, token.Position)
+		}
+		if token.Term == "穿越" {
+			require.Equal(t, 2, token.Position)
+		}
+	}
+}
+
+func TestChineseAnalyzerAddWords(t *testing.T) {
+	analyzer := NewChineseAnalyzer()
+	require.NotContains(t, terms(analyzer.Analyze("星际穿越")), "星际穿越")
+
+	analyzer.AddWords("星際穿越", "克里斯托弗·诺兰")
+	tokens := terms(analyzer.Analyze("星际穿越"))
+	require.Contains(t, tokens, "星际穿越")
+	// The words inside a learned word are still indexed
+	require.Contains(t, tokens, "星际")
+	require.Contains(t, tokens, "穿越")
+	require.Contains(t, terms(analyzer.Analyze("诺兰执导")), "nuolan")
+
+	// Queries don't use learned words
+	require.Equal(t, []string{"星际", "穿越"}, terms(analyzer.AnalyzeQuery("星际穿越")))
+}
+
+func TestIndexChinese(t *testing.T) {
+	movies := []db.Movies{
+		{Id: primitive.NewObjectID(), Title: "星際穿越", Directors: []string{"克里斯托弗·诺兰"}, Year: 2014},
+		{Id: primitive.NewObjectID(), Title: "霸王别姬", Plot: "一个关于京剧的故事", Year: 1993},
+		{Id: primitive.NewObjectID(), Title: "Interstellar", Year: 2014},
+	}
+	ix := NewIndex(NewChineseAnalyzer(), DefaultWeights)
+	ix.Rebuild(movies, nil)
+
+	testCases := []struct {
+		query string
+		want  []primitive.ObjectID
+	}{
+		{"星际", []primitive.ObjectID{movies[0].Id}},
+		{"星際", []primitive.ObjectID{movies[0].Id}},
+		{"星际穿越", []primitive.ObjectID{movies[0].Id}},
+		{"xjcy", []primitive.ObjectID{movies[0].Id}},
+		{"xingjichuanyue", []primitive.ObjectID{movies[0].Id}},
+		{"nuolan", []primitive.ObjectID{movies[0].Id}},
+		{"bwbj", []primitive.ObjectID{movies[1].Id}},
+		{`"霸王別姬"`, []primitive.ObjectID{movies[1].Id}},
+		{"京剧", []primitive.ObjectID{movies[1].Id}},
+		{"interstellar", []primitive.ObjectID{movies[2].Id}},
+		{"穿越霸王", nil},
+	}
+	for _, tc := range testCases {
+		result, err := ix.Search(Request{Query: tc.query, Type: TypeMovie})
+		require.NoError(t, err, tc.query)
+		var got []primitive.ObjectID
+		for _, hit := range result.Hits {
+			got = append(got, hit.ID)
+		}
+		require.Equal(t, tc.want, got, tc.query)
+	}
+
+	result, err := ix.Search(Request{Query: "xjcy", Type: TypeMovie})
+	require.NoError(t, err)
+	require.Equal(t, []string{"<mark>星際穿越</mark>"}, result.Hits[0].Highlights["title"])
+}
+
+func TestIndexChineseLearnsNewTitles(t *testing.T) {
+	ix := NewIndex(NewChineseAnalyzer(), DefaultWeights)
+	movie := db.Movies{Id: primitive.NewObjectID(), Title: "诺兰", Plot: "诺兰的新片"}
+	ix.IndexMovie(movie)
+
+	result, err := ix.Search(Request{Query: "nl", Type: TypeMovie})
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Total)
+}
+
+// A document is removed by the terms it was indexed under, though the
+// analyzer splits its text otherwise after learning a new title
+func TestIndexChineseRemoveAfterLearning(t *testing.T) {
+	ix := NewIndex(NewChineseAnalyzer(), DefaultWeights)
+	comment := db.Comments{ID: primitive.NewObjectID(), MovieID: primitive.NewObjectID(), Text: "星际穿越好看"}
+	ix.IndexComment(comment)
+	ix.IndexMovie(db.Movies{Id: primitive.NewObjectID(), Title: "际穿越好看"})
+	ix.RemoveComment(comment.ID)
+
+	result, err := ix.Search(Request{Query: "星际"})
+	require.NoError(t, err)
+	for _, hit := range result.Hits {
+		require.NotEqual(t, comment.ID, hit.ID)
+	}
+	for field, terms := range ix.postings {
+		for term, docs := range terms {
+			_, ok := docs[docKey(TypeComment, comment.ID)]
+			require.False(t, ok, "%s:%s", field, term)
+		}
+	}
+}
+
+func TestIndexChinesePolyphones(t *testing.T) {
+	ix := NewIndex(NewChineseAnalyzer(), DefaultWeights)
+	chungking := db.Movies{Id: primitive.NewObjectID(), Title: "重庆森林"}
+	wall := db.Movies{Id: primitive.NewObjectID(), Title: "长城"}
+	ix.Rebuild([]db.Movies{chungking, wall}, nil)
+
+	for query, want := range map[string]primitive.ObjectID{
+		"chongqing":  chungking.Id,
+		"cqsl":       chungking.Id,
+		"changcheng": wall.Id,
+		"cc":         wall.Id,
+	} {
+		result, err := ix.Search(Request{Query: query, Type: TypeMovie})
+		require.NoError(t, err)
+		require.Equal(t, []primitive.ObjectID{want}, hitIDs(result), query)
+	}
+}
@@ -0,0 +1,148 @@
+package search
+
+import (
+	_ "embed"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// The bundled dictionaries are generated from ICU data, so that Chinese search
+// works offline:
+//
+//	dict/words.txt   simplified words and their cost, from the ICU cjdict
+//	                 segmentation dictionary. A lower cost is a more frequent word
+//	dict/pinyin.txt  a toneless pinyin syllable and the characters reading it,
+//	                 from the Han-Latin transliterator
+//	dict/polyphones.txt  a character followed by the readings it has besides
+//	                 the one of pinyin.txt, such as 长 chang, curated by hand
+//	                 for the common characters, as the transliterator has one
+//	                 reading each. ü is written v as well as u, the way it is typed
+//	dict/t2s.txt     a traditional character followed by its simplified form,
+//	                 from the Hant-Hans transliterator
+var (
+	//go:embed dict/words.txt
+	wordsData string
+	//go:embed dict/pinyin.txt
+	pinyinData string
+	//go:embed dict/polyphones.txt
+	polyphonesData string
+	//go:embed dict/t2s.txt
+	t2sData string
+)
+
+var (
+	loadDictsOnce sync.Once
+	wordCosts     map[string]int
+	maxWordLen    int
+	pinyinTable   map[rune][]string // the readings of a character, the most common first
+	t2sTable      map[rune]rune
+)
+
+// loadDicts parses the bundled dictionaries the first time they are needed
+func loadDicts() {
+	loadDictsOnce.Do(func() {
+		wordCosts = map[string]int{}
+		for _, line := range strings.Split(wordsData, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 2 {
+				continue
+			}
+			cost, err := strconv.Atoi(fields[1])
+			if err != nil {
+				continue
+			}
+			wordCosts[fields[0]] = cost
+			if n := utf8.RuneCountInString(fields[0]); n > maxWordLen {
+				maxWordLen = n
+			}
+		}
+
+		pinyinTable = map[rune][]string{}
+		for _, line := range strings.Split(pinyinData, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 2 {
+				continue
+			}
+			for _, r := range fields[1] {
+				pinyinTable[r] = []string{fields[0]}
+			}
+		}
+		for _, line := range strings.Split(polyphonesData, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				continue
+			}
+			r, _ := utf8.DecodeRuneInString(fields[0])
+			pinyinTable[r] = append(pinyinTable[r], fields[1:]...)
+		}
+
+		t2sTable = map[rune]rune{}
+		for _, line := range strings.Split(t2sData, "\n") {
+			runes := []rune(line)
+			if len(runes) == 2 {
+				t2sTable[runes[0]] = runes[1]
+			}
+		}
+	})
+}
+
+// ToSimplified converts traditional Chinese characters in s to their
+// simplified form, one character at a time
+func ToSimplified(s string) string {
+	loadDicts()
+	return strings.Map(toSimplified, s)
+}
+
+func toSimplified(r rune) rune {
+	if simplified, ok := t2sTable[r]; ok {
+		return simplified
+	}
+	return r
+}
+
+// maxPinyinReadings bounds the readings PinyinReadings makes of a run of
+// characters that have several each
+const maxPinyinReadings = 16
+
+// Pinyin returns the toneless pinyin of every character of s joined together,
+// and the initial letter of each of them, so 星际 gives "xingji" and "xj".
+// A character with several readings reads its most common one.
+// ok is false when a character has no known reading
+func Pinyin(s string) (full, initials string, ok bool) {
+	fulls, initialsList, ok := PinyinReadings(s)
+	if !ok {
+		return "", "", false
+	}
+	return fulls[0], initialsList[0], true
+}
+
+// PinyinReadings is Pinyin with every reading of the characters that have
+// several, so 长城 gives "changcheng" and "zhangcheng", and "cc" and "zc".
+// The most common reading is first, and there are at most maxPinyinReadings
+func PinyinReadings(s string) (fulls, initials []string, ok bool) {
+	loadDicts()
+	fulls, initials = []string{""}, []string{""}
+	for _, r := range s {
+		syllables, found := pinyinTable[toSimplified(r)]
+		if !found {
+			syllables, found = pinyinTable[r]
+		}
+		if !found {
+			return nil, nil, false
+		}
+		var nextFulls, nextInitials []string
+		for i := range fulls {
+			for _, syllable := range syllables {
+				if len(nextFulls) == maxPinyinReadings {
+					break
+				}
+				nextFulls = append(nextFulls, fulls[i]+syllable)
+				nextInitials = append(nextInitials, initials[i]+syllable[:1])
+			}
+		}
+		fulls, initials = nextFulls, nextInitials
+	}
+	return fulls, uniqueWords(initials), true
+}
@@ -0,0 +1,106 @@
+package search
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// snippetLength is the longest snippet, in bytes, returned for a field value
+const snippetLength = 200
+
+// highlight builds snippets for every field of doc containing a matched term
+func (ix *Index) highlight(doc *document, matchers []matcher) map[string][]string {
+	highlights := map[string][]string{}
+	fields := make([]string, 0, len(doc.values))
+	for field := range doc.values {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		if _, ok := ix.weights[field]; !ok {
+			continue
+		}
+		for _, value := range doc.values[field] {
+			var marks []Token
+			for _, token := range ix.analyzer.Analyze(value) {
+				if matchesToken(matchers, field, token.Term) {
+					marks = append(marks, token)
+				}
+			}
+			if len(marks) > 0 {
+				highlights[field] = append(highlights[field], snippet(value, marks))
+			}
+		}
+	}
+	if len(highlights) == 0 {
+		return nil
+	}
+	return highlights
+}
+
+func matchesToken(matchers []matcher, field, term string) bool {
+	for _, m := range matchers {
+		if m.field != "" && m.field != field {
+			continue
+		}
+		if m.term == term || m.prefix && strings.HasPrefix(term, m.term) {
+			return true
+		}
+	}
+	return false
+}
+
+// snippet cuts a window of value around the first mark, escapes it for HTML
+// and wraps every mark inside the window in <mark></mark>
+func snippet(value string, marks []Token) string {
+	start, end := 0, len(value)
+	if len(value) > snippetLength {
+		start = marks[0].Start - snippetLength/4
+		if start < 0 {
+			start = 0
+		}
+		for start > 0 && !utf8.RuneStart(value[start]) {
+			start--
+		}
+		end = start + snippetLength
+		if end > len(value) {
+			end = len(value)
+		}
+		for end < len(value) && !utf8.RuneStart(value[end]) {
+			end++
+		}
+	}
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("…")
+	}
+	pos := start
+	for _, mark := range marks {
+		// CJK bigrams overlap, so a mark may start inside the previous one
+		if mark.End <= pos || mark.Start >= end {
+			continue
+		}
+		markStart := mark.Start
+		if markStart < pos {
+			markStart = pos
+		}
+		markEnd := mark.End
+		if markEnd > end {
+			markEnd = end
+		}
+		sb.WriteString(html.EscapeString(value[pos:markStart]))
+		sb.WriteString("<mark>")
+		sb.WriteString(html.EscapeString(value[markStart:markEnd]))
+		sb.WriteString("</mark>")
+		pos = markEnd
+	}
+	sb.WriteString(html.EscapeString(value[pos:end]))
+	if end < len(value) {
+		sb.WriteString("…")
+	}
+	return strings.ReplaceAll(sb.String(), "</mark><mark>", "")
+}
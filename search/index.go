@@ -0,0 +1,534 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	db "phantom/db/mongo"
+)
+
+// DocType is the kind of document stored in a SearchIndex
+type DocType string
+
+const (
+	TypeMovie   DocType = "movie"
+	TypeComment DocType = "comment"
+)
+
+// fieldGap separates the values of an array field, such as two cast members,
+// so that a phrase never matches across them
+const fieldGap = 100
+
+// BM25 parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// DefaultWeights boosts matches in short, descriptive fields over long ones
+var DefaultWeights = map[string]float64{
+	"title":     5,
+	"cast":      3,
+	"directors": 3,
+	"genres":    2,
+	"plot":      1.5,
+	"fullplot":  1,
+	"text":      1,
+	"name":      0.5,
+}
+
+// numericFields are the fields that accept range queries
+var numericFields = map[string]bool{
+	"year":    true,
+	"runtime": true,
+	"rating":  true,
+	"date":    true,
+}
+
+// SearchIndex is a full-text index over movies and comments
+type SearchIndex interface {
+	// Rebuild replaces the whole index
+	Rebuild(movies []db.Movies, comments []db.Comments)
+	IndexMovie(movie db.Movies)
+	IndexComment(comment db.Comments)
+	RemoveMovie(id primitive.ObjectID)
+	RemoveComment(id primitive.ObjectID)
+	// Search runs a query, see Query for the syntax
+	Search(req Request) (Result, error)
+}
+
+// Request is a search request against a SearchIndex
+type Request struct {
+	Query string
+	Type  DocType
+	// MovieID restricts comment searches to a single movie
+	MovieID primitive.ObjectID
+	Skip    int
+	Limit   int
+}
+
+// Result is a page of search hits
+type Result struct {
+	Total int
+	Hits  []Hit
+}
+
+// Hit is a matching document. Fields holds its indexed text, and Highlights
+// holds HTML snippets with the matching words wrapped in <mark>, keyed by field
+type Hit struct {
+	ID         primitive.ObjectID
+	Type       DocType
+	MovieID    primitive.ObjectID
+	Score      float64
+	Fields     map[string]string
+	Highlights map[string][]string
+}
+
+type document struct {
+	key     string
+	typ     DocType
+	id      primitive.ObjectID
+	movieID primitive.ObjectID
+	values  map[string][]string
+	lengths map[string]int
+	numbers map[string]float64
+	// terms are the terms the document was indexed under, by field. They are
+	// what remove deletes, as the analyzer may split the values otherwise
+	// by then, having learned words since
+	terms map[string][]string
+}
+
+// Index is an embedded SearchIndex kept in memory.
+// It stores an inverted index with term positions per field and ranks
+// matches with BM25, weighted per field
+type Index struct {
+	mu       sync.RWMutex
+	analyzer Analyzer
+	weights  map[string]float64
+	docs     map[string]*document
+	// postings maps field -> term -> document key -> positions
+	postings     map[string]map[string]map[string][]int
+	fieldLengths map[string]int
+}
+
+var _ SearchIndex = (*Index)(nil)
+
+// NewIndex creates an empty index, fields missing from weights are not searchable
+func NewIndex(analyzer Analyzer, weights map[string]float64) *Index {
+	return &Index{
+		analyzer:     analyzer,
+		weights:      weights,
+		docs:         map[string]*document{},
+		postings:     map[string]map[string]map[string][]int{},
+		fieldLengths: map[string]int{},
+	}
+}
+
+func docKey(typ DocType, id primitive.ObjectID) string {
+	return string(typ) + ":" + id.Hex()
+}
+
+func movieDocument(movie db.Movies) *document {
+	doc := &document{
+		key: docKey(TypeMovie, movie.Id),
+		typ: TypeMovie,
+		id:  movie.Id,
+		values: map[string][]string{
+			"title":     movieTitles(movie),
+			"plot":      {movie.Plot},
+			"fullplot":  {movie.Fullplot},
+			"cast":      movie.Cast,
+			"directors": movie.Directors,
+			"genres":    movie.Genres,
+		},
+		numbers: map[string]float64{
+			"year":    float64(movie.Year),
+			"runtime": float64(movie.Runtime),
+			"rating":  movie.Imdb.Rating,
+		},
+	}
+	return doc
+}
+
+// movieTitles lists every title of a movie, localized ones included
+func movieTitles(movie db.Movies) []string {
+	titles := []string{movie.Title}
+	if movie.OriginalTitle != "" && movie.OriginalTitle != movie.Title {
+		titles = append(titles, movie.OriginalTitle)
+	}
+	for _, title := range movie.Titles {
+		if title.Title != movie.Title {
+			titles = append(titles, title.Title)
+		}
+	}
+	return titles
+}
+
+func commentDocument(comment db.Comments) *document {
+	return &document{
+		key:     docKey(TypeComment, comment.ID),
+		typ:     TypeComment,
+		id:      comment.ID,
+		movieID: comment.MovieID,
+		values: map[string][]string{
+			"text": {comment.Text},
+			"name": {comment.Name},
+		},
+		numbers: map[string]float64{
+			"date": float64(comment.Date.Time().Unix()),
+		},
+	}
+}
+
+// wordLearner is an Analyzer that learns words, such as titles and names,
+// from the movies being indexed
+type wordLearner interface {
+	AddWords(texts ...string)
+}
+
+func (ix *Index) learn(movie db.Movies) {
+	if learner, ok := ix.analyzer.(wordLearner); ok {
+		learner.AddWords(movieTitles(movie)...)
+		learner.AddWords(movie.Cast...)
+		learner.AddWords(movie.Directors...)
+	}
+}
+
+func (ix *Index) Rebuild(movies []db.Movies, comments []db.Comments) {
+	fresh := NewIndex(ix.analyzer, ix.weights)
+	// Learn every title first, so that all documents are segmented alike
+	for _, movie := range movies {
+		fresh.learn(movie)
+	}
+	for _, movie := range movies {
+		fresh.add(movieDocument(movie))
+	}
+	for _, comment := range comments {
+		fresh.add(commentDocument(comment))
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.docs = fresh.docs
+	ix.postings = fresh.postings
+	ix.fieldLengths = fresh.fieldLengths
+}
+
+func (ix *Index) IndexMovie(movie db.Movies) {
+	ix.learn(movie)
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(docKey(TypeMovie, movie.Id))
+	ix.add(movieDocument(movie))
+}
+
+func (ix *Index) IndexComment(comment db.Comments) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(docKey(TypeComment, comment.ID))
+	ix.add(commentDocument(comment))
+}
+
+func (ix *Index) RemoveMovie(id primitive.ObjectID) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(docKey(TypeMovie, id))
+}
+
+func (ix *Index) RemoveComment(id primitive.ObjectID) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(docKey(TypeComment, id))
+}
+
+func (ix *Index) add(doc *document) {
+	doc.lengths = map[string]int{}
+	doc.terms = map[string][]string{}
+	for field, values := range doc.values {
+		if _, ok := ix.weights[field]; !ok {
+			continue
+		}
+		terms := ix.postings[field]
+		if terms == nil {
+			terms = map[string]map[string][]int{}
+			ix.postings[field] = terms
+		}
+		offset := 0
+		for _, value := range values {
+			tokens := ix.analyzer.Analyze(value)
+			for _, token := range tokens {
+				if terms[token.Term] == nil {
+					terms[token.Term] = map[string][]int{}
+				}
+				if _, ok := terms[token.Term][doc.key]; !ok {
+					doc.terms[field] = append(doc.terms[field], token.Term)
+				}
+				terms[token.Term][doc.key] = append(terms[token.Term][doc.key], offset+token.Position)
+			}
+			doc.lengths[field] += len(tokens)
+			// Analyzers may put several tokens at one position
+			next := 0
+			for _, token := range tokens {
+				if token.Position >= next {
+					next = token.Position + 1
+				}
+			}
+			offset += next + fieldGap
+		}
+		ix.fieldLengths[field] += doc.lengths[field]
+	}
+	ix.docs[doc.key] = doc
+}
+
+func (ix *Index) remove(key string) {
+	doc, ok := ix.docs[key]
+	if !ok {
+		return
+	}
+	for field, indexed := range doc.terms {
+		terms := ix.postings[field]
+		for _, term := range indexed {
+			delete(terms[term], key)
+			if len(terms[term]) == 0 {
+				delete(terms, term)
+			}
+		}
+		ix.fieldLengths[field] -= doc.lengths[field]
+	}
+	delete(ix.docs, key)
+}
+
+func (ix *Index) Search(req Request) (Result, error) {
+	q, err := ParseQuery(req.Query, ix.analyzer, numericFields)
+	if err != nil {
+		return Result{}, err
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	scores := ix.eval(q)
+	var hits []Hit
+	for key, score := range scores {
+		doc, ok := ix.docs[key]
+		if !ok {
+			continue
+		}
+		if req.Type != "" && doc.typ != req.Type {
+			continue
+		}
+		if !req.MovieID.IsZero() && doc.movieID != req.MovieID {
+			continue
+		}
+		hits = append(hits, Hit{ID: doc.id, Type: doc.typ, MovieID: doc.movieID, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID.Hex() < hits[j].ID.Hex()
+	})
+
+	result := Result{Total: len(hits)}
+	if req.Skip >= len(hits) {
+		return result, nil
+	}
+	hits = hits[req.Skip:]
+	if req.Limit > 0 && len(hits) > req.Limit {
+		hits = hits[:req.Limit]
+	}
+
+	matchers := q.matchers()
+	for i := range hits {
+		doc := ix.docs[docKey(hits[i].Type, hits[i].ID)]
+		hits[i].Fields = map[string]string{}
+		for field, values := range doc.values {
+			if len(values) > 0 {
+				hits[i].Fields[field] = strings.Join(values, ", ")
+			}
+		}
+		hits[i].Highlights = ix.highlight(doc, matchers)
+	}
+	result.Hits = hits
+	return result, nil
+}
+
+// eval returns the score of every document matching q
+func (ix *Index) eval(q Query) map[string]float64 {
+	switch q := q.(type) {
+	case *BoolQuery:
+		return ix.evalBool(q)
+	case *TermQuery:
+		return ix.evalTerms(q.Field, func(term string) bool { return term == q.Term }, 1)
+	case *PrefixQuery:
+		// Expanded prefixes score a little lower than exact words
+		return ix.evalTerms(q.Field, func(term string) bool { return strings.HasPrefix(term, q.Prefix) }, 0.8)
+	case *PhraseQuery:
+		return ix.evalPhrase(q)
+	case *RangeQuery:
+		return ix.evalRange(q)
+	}
+	return nil
+}
+
+func (ix *Index) evalBool(q *BoolQuery) map[string]float64 {
+	var scores map[string]float64
+	for i, sub := range q.Must {
+		matches := ix.eval(sub)
+		if i == 0 {
+			scores = matches
+			continue
+		}
+		for key, score := range scores {
+			if s, ok := matches[key]; ok {
+				scores[key] = score + s
+			} else {
+				delete(scores, key)
+			}
+		}
+	}
+
+	if len(q.Must) == 0 {
+		scores = map[string]float64{}
+		for _, sub := range q.Should {
+			for key, score := range ix.eval(sub) {
+				scores[key] += score
+			}
+		}
+		if len(q.Should) == 0 && len(q.MustNot) > 0 {
+			for key := range ix.docs {
+				scores[key] = 0
+			}
+		}
+	} else {
+		for _, sub := range q.Should {
+			for key, score := range ix.eval(sub) {
+				if _, ok := scores[key]; ok {
+					scores[key] += score
+				}
+			}
+		}
+	}
+
+	for _, sub := range q.MustNot {
+		for key := range ix.eval(sub) {
+			delete(scores, key)
+		}
+	}
+	return scores
+}
+
+func (ix *Index) fields(field string) []string {
+	if field != "" {
+		return []string{field}
+	}
+	fields := make([]string, 0, len(ix.weights))
+	for f := range ix.weights {
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func (ix *Index) evalTerms(field string, match func(term string) bool, boost float64) map[string]float64 {
+	scores := map[string]float64{}
+	for _, f := range ix.fields(field) {
+		for term, docs := range ix.postings[f] {
+			if !match(term) {
+				continue
+			}
+			idf := ix.idf(len(docs))
+			for key, positions := range docs {
+				if _, ok := ix.docs[key]; !ok {
+					continue
+				}
+				scores[key] += boost * ix.bm25(f, key, len(positions), idf)
+			}
+		}
+	}
+	return scores
+}
+
+func (ix *Index) evalPhrase(q *PhraseQuery) map[string]float64 {
+	scores := map[string]float64{}
+	for _, f := range ix.fields(q.Field) {
+		terms := ix.postings[f]
+		first := terms[q.Terms[0]]
+		idf := 0.0
+		for _, term := range q.Terms {
+			idf += ix.idf(len(terms[term]))
+		}
+		for key, starts := range first {
+			if _, ok := ix.docs[key]; !ok {
+				continue
+			}
+			tf := 0
+			for _, start := range starts {
+				if ix.phraseAt(terms, q, key, start) {
+					tf++
+				}
+			}
+			if tf > 0 {
+				scores[key] += ix.bm25(f, key, tf, idf)
+			}
+		}
+	}
+	return scores
+}
+
+func (ix *Index) phraseAt(terms map[string]map[string][]int, q *PhraseQuery, key string, start int) bool {
+	for i := 1; i < len(q.Terms); i++ {
+		found := false
+		for _, pos := range terms[q.Terms[i]][key] {
+			if pos == start+q.offset(i) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (ix *Index) evalRange(q *RangeQuery) map[string]float64 {
+	scores := map[string]float64{}
+	for key, doc := range ix.docs {
+		value, ok := doc.numbers[q.Field]
+		if !ok {
+			continue
+		}
+		if q.Min != nil && (value < *q.Min || !q.MinInclusive && value == *q.Min) {
+			continue
+		}
+		if q.Max != nil && (value > *q.Max || !q.MaxInclusive && value == *q.Max) {
+			continue
+		}
+		scores[key] = 0
+	}
+	return scores
+}
+
+func (ix *Index) idf(docFreq int) float64 {
+	n := float64(len(ix.docs))
+	df := float64(docFreq)
+	return math.Log(1 + (n-df+0.5)/(df+0.5))
+}
+
+func (ix *Index) bm25(field, key string, tf int, idf float64) float64 {
+	doc, ok := ix.docs[key]
+	if !ok {
+		return 0
+	}
+	avg := float64(ix.fieldLengths[field]) / float64(len(ix.docs))
+	length := float64(doc.lengths[field])
+	norm := 1.0
+	if avg > 0 {
+		norm = 1 - bm25B + bm25B*length/avg
+	}
+	f := float64(tf)
+	return ix.weights[field] * idf * f * (bm25K1 + 1) / (f + bm25K1*norm)
+}
@@ -0,0 +1,209 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	db "phantom/db/mongo"
+)
+
+func newTestIndex(t *testing.T) (*Index, []db.Movies, []db.Comments) {
+	movies := []db.Movies{
+		{
+			Id:        primitive.NewObjectID(),
+			Title:     "The Dark Knight",
+			Plot:      "Batman faces the Joker in Gotham.",
+			Cast:      []string{"Christian Bale", "Heath Ledger"},
+			Directors: []string{"Christopher Nolan"},
+			Genres:    []string{"Action", "Crime"},
+			Year:      2008,
+		},
+		{
+			Id:        primitive.NewObjectID(),
+			Title:     "Interstellar",
+			Plot:      "A team of explorers travel through a wormhole in space.",
+			Cast:      []string{"Matthew McConaughey"},
+			Directors: []string{"Christopher Nolan"},
+			Genres:    []string{"Sci-Fi"},
+			Year:      2014,
+		},
+		{
+			Id:     primitive.NewObjectID(),
+			Title:  "星际穿越",
+			Plot:   "一队探险家穿越虫洞。",
+			Genres: []string{"科幻"},
+			Year:   2014,
+		},
+		{
+			Id:    primitive.NewObjectID(),
+			Title: "Batman Returns",
+			Plot:  "The dark knight returns to fight the Penguin.",
+			Year:  1992,
+		},
+	}
+	comments := []db.Comments{
+		{
+			ID:      primitive.NewObjectID(),
+			MovieID: movies[0].Id,
+			Name:    "alice",
+			Text:    "Heath Ledger's Joker is <unforgettable>",
+			Date:    primitive.NewDateTimeFromTime(time.Now()),
+		},
+		{
+			ID:      primitive.NewObjectID(),
+			MovieID: movies[1].Id,
+			Name:    "bob",
+			Text:    "The docking scene and the Joker... no wait, wrong film",
+			Date:    primitive.NewDateTimeFromTime(time.Now()),
+		},
+	}
+
+	ix := NewIndex(StandardAnalyzer{}, DefaultWeights)
+	ix.Rebuild(movies, comments)
+	return ix, movies, comments
+}
+
+func hitIDs(result Result) []primitive.ObjectID {
+	var ids []primitive.ObjectID
+	for _, hit := range result.Hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids
+}
+
+func search(t *testing.T, ix *Index, query string, typ DocType) Result {
+	result, err := ix.Search(Request{Query: query, Type: typ, Limit: 10})
+	require.NoError(t, err)
+	return result
+}
+
+func TestIndexFieldWeights(t *testing.T) {
+	ix, movies, _ := newTestIndex(t)
+
+	// "dark knight" is in the title of one movie and in the plot of another
+	result := search(t, ix, "dark knight", TypeMovie)
+	require.Equal(t, []primitive.ObjectID{movies[0].Id, movies[3].Id}, hitIDs(result))
+	require.Greater(t, result.Hits[0].Score, result.Hits[1].Score)
+}
+
+func TestIndexBooleanAndPhrase(t *testing.T) {
+	ix, movies, _ := newTestIndex(t)
+
+	require.Equal(t, []primitive.ObjectID{movies[3].Id}, hitIDs(search(t, ix, "batman -joker", TypeMovie)))
+	require.Equal(t, []primitive.ObjectID{movies[0].Id}, hitIDs(search(t, ix, "+nolan +joker", TypeMovie)))
+	require.Equal(t, []primitive.ObjectID{movies[0].Id}, hitIDs(search(t, ix, `"heath ledger"`, TypeMovie)))
+	// Cast members are separate values, a phrase doesn't match across them
+	require.Empty(t, hitIDs(search(t, ix, `"bale heath"`, TypeMovie)))
+	require.Equal(t, []primitive.ObjectID{movies[1].Id}, hitIDs(search(t, ix, "interst*", TypeMovie)))
+	require.Equal(t, []primitive.ObjectID{movies[3].Id}, hitIDs(search(t, ix, "title:batman", TypeMovie)))
+}
+
+func TestIndexRange(t *testing.T) {
+	ix, movies, _ := newTestIndex(t)
+
+	result := search(t, ix, "+nolan +year:>=2010", TypeMovie)
+	require.Equal(t, []primitive.ObjectID{movies[1].Id}, hitIDs(result))
+
+	result = search(t, ix, "year:1990..2009", TypeMovie)
+	require.ElementsMatch(t, []primitive.ObjectID{movies[0].Id, movies[3].Id}, hitIDs(result))
+}
+
+func TestIndexCJK(t *testing.T) {
+	ix, movies, _ := newTestIndex(t)
+
+	require.Equal(t, []primitive.ObjectID{movies[2].Id}, hitIDs(search(t, ix, "星际", TypeMovie)))
+	require.Equal(t, []primitive.ObjectID{movies[2].Id}, hitIDs(search(t, ix, "星际穿越", TypeMovie)))
+	require.Equal(t, []primitive.ObjectID{movies[2].Id}, hitIDs(search(t, ix, "虫洞", TypeMovie)))
+	require.Equal(t, []primitive.ObjectID{movies[2].Id}, hitIDs(search(t, ix, "星", TypeMovie)))
+	require.Empty(t, hitIDs(search(t, ix, "星际迷航", TypeMovie)))
+}
+
+func TestIndexComments(t *testing.T) {
+	ix, movies, comments := newTestIndex(t)
+
+	result := search(t, ix, "joker", TypeComment)
+	require.ElementsMatch(t, []primitive.ObjectID{comments[0].ID, comments[1].ID}, hitIDs(result))
+
+	result, err := ix.Search(Request{Query: "joker", Type: TypeComment, MovieID: movies[0].Id, Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, []primitive.ObjectID{comments[0].ID}, hitIDs(result))
+	require.Equal(t, movies[0].Id, result.Hits[0].MovieID)
+	require.Equal(t, "alice", result.Hits[0].Fields["name"])
+}
+
+func TestIndexHighlights(t *testing.T) {
+	ix, _, _ := newTestIndex(t)
+
+	result := search(t, ix, "joker", TypeComment)
+	for _, hit := range result.Hits {
+		if hit.Fields["name"] == "alice" {
+			require.Equal(t, []string{"Heath Ledger&#39;s <mark>Joker</mark> is &lt;unforgettable&gt;"}, hit.Highlights["text"])
+		}
+	}
+
+	result = search(t, ix, `"christopher nolan" 2014`, TypeMovie)
+	require.Equal(t, []string{"<mark>Christopher</mark> <mark>Nolan</mark>"}, result.Hits[0].Highlights["directors"])
+
+	result = search(t, ix, "星际", TypeMovie)
+	require.Equal(t, []string{"<mark>星际</mark>穿越"}, result.Hits[0].Highlights["title"])
+}
+
+func TestIndexUpdates(t *testing.T) {
+	ix, movies, comments := newTestIndex(t)
+
+	movie := movies[1]
+	movie.Title = "Tenet"
+	ix.IndexMovie(movie)
+	require.Equal(t, []primitive.ObjectID{movie.Id}, hitIDs(search(t, ix, "tenet", TypeMovie)))
+	require.Empty(t, hitIDs(search(t, ix, "interstellar", TypeMovie)))
+
+	ix.RemoveMovie(movie.Id)
+	require.Empty(t, hitIDs(search(t, ix, "tenet", TypeMovie)))
+
+	ix.RemoveComment(comments[0].ID)
+	require.Equal(t, []primitive.ObjectID{comments[1].ID}, hitIDs(search(t, ix, "joker", TypeComment)))
+
+	comment := comments[1]
+	comment.Text = "Changed my mind"
+	ix.IndexComment(comment)
+	require.Empty(t, hitIDs(search(t, ix, "joker", TypeComment)))
+}
+
+func TestIndexPagination(t *testing.T) {
+	ix, _, _ := newTestIndex(t)
+
+	result, err := ix.Search(Request{Query: "year:>0", Type: TypeMovie, Skip: 1, Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, 4, result.Total)
+	require.Len(t, result.Hits, 2)
+
+	result, err = ix.Search(Request{Query: "year:>0", Type: TypeMovie, Skip: 10, Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, 4, result.Total)
+	require.Empty(t, result.Hits)
+
+	_, err = ix.Search(Request{Query: "(oops"})
+	require.Error(t, err)
+}
+
+func TestIndexLocalizedTitles(t *testing.T) {
+	movie := db.Movies{
+		Id:            primitive.NewObjectID(),
+		Title:         "Spirited Away",
+		OriginalTitle: "千と千尋の神隠し",
+		Titles: []db.LocalizedTitle{
+			{Locale: "zh-Hans", Title: "千与千寻"},
+			{Locale: "fr", Title: "Le Voyage de Chihiro"},
+		},
+	}
+	ix := NewIndex(NewChineseAnalyzer(), DefaultWeights)
+	ix.IndexMovie(movie)
+
+	for _, query := range []string{"spirited", "千尋", "千与千寻", "qyqx", "chihiro", "title:voyage"} {
+		result, err := ix.Search(Request{Query: query, Type: TypeMovie})
+		require.NoError(t, err)
+		require.Equal(t, 1, result.Total, query)
+	}
+}
@@ -0,0 +1,28 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalize lowercases s and strips diacritics, so that "Amélie" and
+// "amelie" are treated as the same text
+func Normalize(s string) string {
+	var sb strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		sb.WriteRune(unicode.ToLower(r))
+	}
+	return norm.NFC.String(sb.String())
+}
+
+// Words splits s into normalized words, dropping punctuation and spaces
+func Words(s string) []string {
+	return strings.FieldsFunc(Normalize(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
@@ -0,0 +1,372 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var errUnbalancedQuery = errors.New("query has unbalanced parentheses or quotes")
+
+// Query is a parsed search query. A query string supports
+//
+//	interstellar nolan      either word, documents with both rank higher
+//	+nolan -batman          nolan is required, batman is excluded
+//	dark AND knight         the same as +dark +knight
+//	NOT batman              the same as -batman
+//	"the dark knight"       the words next to each other
+//	inter*                  any word starting with inter
+//	title:knight            the word in the title field only
+//	year:>=1990 year:<2000  numeric ranges, also year:1990..1999
+//	(batman OR superman)    grouping
+type Query interface {
+	matchers() []matcher
+}
+
+// BoolQuery combines clauses, a document must match every Must clause and
+// none of the MustNot clauses. Without Must clauses, it must match a Should clause
+type BoolQuery struct {
+	Must    []Query
+	Should  []Query
+	MustNot []Query
+}
+
+// TermQuery matches a single analyzed term, an empty Field matches every text field
+type TermQuery struct {
+	Field string
+	Term  string
+}
+
+// PrefixQuery matches every term starting with Prefix
+type PrefixQuery struct {
+	Field  string
+	Prefix string
+}
+
+// PhraseQuery matches terms at consecutive positions.
+// Offsets holds the position of every term relative to the first one
+// when they are not consecutive, such as Chinese words that take one
+// position per character
+type PhraseQuery struct {
+	Field   string
+	Terms   []string
+	Offsets []int
+}
+
+func (q *PhraseQuery) offset(i int) int {
+	if q.Offsets == nil {
+		return i
+	}
+	return q.Offsets[i]
+}
+
+// RangeQuery matches numeric fields between Min and Max,
+// a nil bound is open
+type RangeQuery struct {
+	Field        string
+	Min, Max     *float64
+	MinInclusive bool
+	MaxInclusive bool
+}
+
+// matcher describes a term to highlight
+type matcher struct {
+	field  string
+	term   string
+	prefix bool
+}
+
+func (q *BoolQuery) matchers() []matcher {
+	var ms []matcher
+	for _, sub := range q.Must {
+		ms = append(ms, sub.matchers()...)
+	}
+	for _, sub := range q.Should {
+		ms = append(ms, sub.matchers()...)
+	}
+	return ms
+}
+
+func (q *TermQuery) matchers() []matcher {
+	return []matcher{{field: q.Field, term: q.Term}}
+}
+
+func (q *PrefixQuery) matchers() []matcher {
+	return []matcher{{field: q.Field, term: q.Prefix, prefix: true}}
+}
+
+func (q *PhraseQuery) matchers() []matcher {
+	var ms []matcher
+	for _, term := range q.Terms {
+		ms = append(ms, matcher{field: q.Field, term: term})
+	}
+	return ms
+}
+
+func (q *RangeQuery) matchers() []matcher {
+	return nil
+}
+
+// ParseQuery parses a query string, see Query for the syntax.
+// numericFields lists the fields that take ranges instead of text
+func ParseQuery(s string, analyzer Analyzer, numericFields map[string]bool) (Query, error) {
+	lexemes, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{lexemes: lexemes, analyzer: analyzer, numeric: numericFields}
+	q, err := p.parseBool(false)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lexemes) {
+		return nil, errUnbalancedQuery
+	}
+	return q, nil
+}
+
+type parser struct {
+	lexemes  []string
+	pos      int
+	analyzer Analyzer
+	numeric  map[string]bool
+}
+
+type occur int
+
+const (
+	occurShould occur = iota
+	occurMust
+	occurMustNot
+)
+
+func (p *parser) parseBool(nested bool) (*BoolQuery, error) {
+	q := &BoolQuery{}
+	type clause struct {
+		occur occur
+		query Query
+	}
+	var clauses []clause
+	next := occurShould
+	closed := false
+
+	for p.pos < len(p.lexemes) {
+		lexeme := p.lexemes[p.pos]
+		p.pos++
+
+		if lexeme == ")" {
+			if !nested {
+				return nil, errUnbalancedQuery
+			}
+			closed = true
+			break
+		}
+		switch lexeme {
+		case "AND":
+			if n := len(clauses); n > 0 && clauses[n-1].occur == occurShould {
+				clauses[n-1].occur = occurMust
+			}
+			next = occurMust
+			continue
+		case "OR":
+			continue
+		case "NOT":
+			next = occurMustNot
+			continue
+		}
+
+		o := next
+		next = occurShould
+		switch lexeme[0] {
+		case '+':
+			o, lexeme = occurMust, lexeme[1:]
+		case '-':
+			o, lexeme = occurMustNot, lexeme[1:]
+		}
+
+		var sub Query
+		if lexeme == "(" {
+			group, err := p.parseBool(true)
+			if err != nil {
+				return nil, err
+			}
+			sub = group
+		} else if lexeme != "" {
+			atom, err := p.parseAtom(lexeme)
+			if err != nil {
+				return nil, err
+			}
+			sub = atom
+		}
+		if sub != nil {
+			clauses = append(clauses, clause{occur: o, query: sub})
+		}
+	}
+	if nested && !closed {
+		return nil, errUnbalancedQuery
+	}
+
+	for _, c := range clauses {
+		switch c.occur {
+		case occurMust:
+			q.Must = append(q.Must, c.query)
+		case occurMustNot:
+			q.MustNot = append(q.MustNot, c.query)
+		default:
+			q.Should = append(q.Should, c.query)
+		}
+	}
+	return q, nil
+}
+
+func (p *parser) parseAtom(lexeme string) (Query, error) {
+	field := ""
+	if i := strings.IndexByte(lexeme, ':'); i > 0 && isFieldName(lexeme[:i]) {
+		field, lexeme = lexeme[:i], lexeme[i+1:]
+	}
+	if p.numeric[field] {
+		return parseRange(field, lexeme)
+	}
+
+	if len(lexeme) >= 2 && lexeme[0] == '"' && lexeme[len(lexeme)-1] == '"' {
+		return p.textQuery(field, lexeme[1:len(lexeme)-1]), nil
+	}
+	if strings.HasSuffix(lexeme, "*") {
+		prefix := Normalize(strings.TrimRight(lexeme, "*"))
+		if prefix == "" {
+			return nil, nil
+		}
+		return &PrefixQuery{Field: field, Prefix: prefix}, nil
+	}
+	return p.textQuery(field, lexeme), nil
+}
+
+// textQuery analyzes text the same way documents are, text that turns into
+// several tokens has to match them in order
+func (p *parser) textQuery(field, text string) Query {
+	var tokens []Token
+	if qa, ok := p.analyzer.(QueryAnalyzer); ok {
+		tokens = qa.AnalyzeQuery(text)
+	} else {
+		tokens = p.analyzer.Analyze(text)
+	}
+	switch len(tokens) {
+	case 0:
+		return nil
+	case 1:
+		if isSingleCJK(tokens[0].Term) {
+			return &PrefixQuery{Field: field, Prefix: tokens[0].Term}
+		}
+		return &TermQuery{Field: field, Term: tokens[0].Term}
+	}
+	q := &PhraseQuery{Field: field, Terms: make([]string, len(tokens))}
+	consecutive := true
+	offsets := make([]int, len(tokens))
+	for i, token := range tokens {
+		q.Terms[i] = token.Term
+		offsets[i] = token.Position - tokens[0].Position
+		consecutive = consecutive && offsets[i] == i
+	}
+	if !consecutive {
+		q.Offsets = offsets
+	}
+	return q
+}
+
+func parseRange(field, value string) (Query, error) {
+	q := &RangeQuery{Field: field, MinInclusive: true, MaxInclusive: true}
+	parse := func(s string) (*float64, error) {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q for field %s", s, field)
+		}
+		return &f, nil
+	}
+
+	var err error
+	switch {
+	case strings.HasPrefix(value, ">="):
+		q.Min, err = parse(value[2:])
+	case strings.HasPrefix(value, "<="):
+		q.Max, err = parse(value[2:])
+	case strings.HasPrefix(value, ">"):
+		q.Min, err = parse(value[1:])
+		q.MinInclusive = false
+	case strings.HasPrefix(value, "<"):
+		q.Max, err = parse(value[1:])
+		q.MaxInclusive = false
+	case strings.Contains(value, ".."):
+		bounds := strings.SplitN(value, "..", 2)
+		if bounds[0] != "" {
+			if q.Min, err = parse(bounds[0]); err != nil {
+				return nil, err
+			}
+		}
+		if bounds[1] != "" {
+			q.Max, err = parse(bounds[1])
+		}
+	default:
+		q.Min, err = parse(value)
+		q.Max = q.Min
+	}
+	if err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func isFieldName(s string) bool {
+	for _, r := range s {
+		if !unicode.IsLetter(r) && r != '_' && r != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// lex splits a query string into parentheses, quoted phrases and words,
+// a quoted phrase stays attached to its field and +/- prefix
+func lex(s string) ([]string, error) {
+	var lexemes []string
+	var current strings.Builder
+	inQuote := false
+	flush := func() {
+		if current.Len() > 0 {
+			lexemes = append(lexemes, current.String())
+			current.Reset()
+		}
+	}
+
+	for _, r := range s {
+		switch {
+		case r == '"':
+			current.WriteRune(r)
+			if inQuote {
+				flush()
+			}
+			inQuote = !inQuote
+		case inQuote:
+			current.WriteRune(r)
+		case unicode.IsSpace(r):
+			flush()
+		case r == '(' || r == ')':
+			prefix := current.String()
+			if r == '(' && (prefix == "+" || prefix == "-") {
+				current.Reset()
+				lexemes = append(lexemes, prefix+"(")
+				continue
+			}
+			flush()
+			lexemes = append(lexemes, string(r))
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if inQuote {
+		return nil, errUnbalancedQuery
+	}
+	flush()
+	return lexemes, nil
+}
@@ -0,0 +1,71 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func parse(t *testing.T, s string) *BoolQuery {
+	q, err := ParseQuery(s, StandardAnalyzer{}, numericFields)
+	require.NoError(t, err)
+	return q.(*BoolQuery)
+}
+
+func TestParseQueryBoolean(t *testing.T) {
+	q := parse(t, "+nolan -batman dark")
+	require.Equal(t, []Query{&TermQuery{Term: "nolan"}}, q.Must)
+	require.Equal(t, []Query{&TermQuery{Term: "batman"}}, q.MustNot)
+	require.Equal(t, []Query{&TermQuery{Term: "dark"}}, q.Should)
+
+	q = parse(t, "dark AND knight NOT joker OR rises")
+	require.Equal(t, []Query{&TermQuery{Term: "dark"}, &TermQuery{Term: "knight"}}, q.Must)
+	require.Equal(t, []Query{&TermQuery{Term: "joker"}}, q.MustNot)
+	require.Equal(t, []Query{&TermQuery{Term: "rises"}}, q.Should)
+}
+
+func TestParseQueryAtoms(t *testing.T) {
+	q := parse(t, `title:"The Dark Knight" inter* cast:nolan 星际穿越 星`)
+	require.Equal(t, []Query{
+		&PhraseQuery{Field: "title", Terms: []string{"the", "dark", "knight"}},
+		&PrefixQuery{Prefix: "inter"},
+		&TermQuery{Field: "cast", Term: "nolan"},
+		&PhraseQuery{Terms: []string{"星际", "际穿", "穿越"}},
+		&PrefixQuery{Prefix: "星"},
+	}, q.Should)
+}
+
+func TestParseQueryRange(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	q := parse(t, "year:>=1990 year:<2000 rating:7.5..9 runtime:120")
+	require.Equal(t, []Query{
+		&RangeQuery{Field: "year", Min: f(1990), MinInclusive: true, MaxInclusive: true},
+		&RangeQuery{Field: "year", Max: f(2000), MinInclusive: true},
+		&RangeQuery{Field: "rating", Min: f(7.5), Max: f(9), MinInclusive: true, MaxInclusive: true},
+		&RangeQuery{Field: "runtime", Min: f(120), Max: f(120), MinInclusive: true, MaxInclusive: true},
+	}, q.Should)
+
+	_, err := ParseQuery("year:abc", StandardAnalyzer{}, numericFields)
+	require.Error(t, err)
+}
+
+func TestParseQueryGroups(t *testing.T) {
+	q := parse(t, "+(batman OR superman) -(joker)")
+	require.Len(t, q.Must, 1)
+	require.Len(t, q.Must[0].(*BoolQuery).Should, 2)
+	require.Len(t, q.MustNot, 1)
+
+	for _, s := range []string{"(batman", "batman)", `"batman`} {
+		_, err := ParseQuery(s, StandardAnalyzer{}, numericFields)
+		require.Error(t, err, s)
+	}
+}
+
+func TestParseQueryChinese(t *testing.T) {
+	q, err := ParseQuery("霸王別姬 xjcy", NewChineseAnalyzer(), numericFields)
+	require.NoError(t, err)
+	require.Equal(t, []Query{
+		&PhraseQuery{Terms: []string{"霸王", "别", "姬"}, Offsets: []int{0, 2, 3}},
+		&TermQuery{Term: "xjcy"},
+	}, q.(*BoolQuery).Should)
+}
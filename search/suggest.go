@@ -0,0 +1,463 @@
+package search
+
+import (
+	"container/heap"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	db "phantom/db/mongo"
+)
+
+// Kind is what a suggestion points at
+type Kind string
+
+const (
+	KindTitle  Kind = "title"
+	KindPerson Kind = "person"
+	KindGenre  Kind = "genre"
+)
+
+// Suggestion is a single autocomplete result
+type Suggestion struct {
+	Text    string `json:"text"`
+	Kind    Kind   `json:"kind"`
+	MovieID string `json:"movie_id,omitempty"`
+	Movies  int    `json:"movies"`
+}
+
+type entry struct {
+	key    string
+	kind   Kind
+	text   string
+	norm   string
+	words  []string
+	movies map[primitive.ObjectID]struct{}
+}
+
+type trieNode struct {
+	children map[rune]*trieNode
+	entries  map[*entry]struct{}
+}
+
+func newTrieNode() *trieNode {
+	return &trieNode{children: map[rune]*trieNode{}}
+}
+
+// Suggester is an in-process prefix index over movie titles, people and genres.
+// Every word of an indexed text is stored in a trie, and lookups walk the trie
+// with a bounded edit distance so that typos still find a match
+type Suggester struct {
+	mu      sync.RWMutex
+	root    *trieNode
+	entries map[string]*entry
+	byMovie map[primitive.ObjectID][]*entry
+}
+
+func NewSuggester() *Suggester {
+	return &Suggester{
+		root:    newTrieNode(),
+		entries: map[string]*entry{},
+		byMovie: map[primitive.ObjectID][]*entry{},
+	}
+}
+
+// Rebuild replaces the whole index with the given movies
+func (s *Suggester) Rebuild(movies []db.Movies) {
+	fresh := NewSuggester()
+	for _, movie := range movies {
+		fresh.addMovie(movie)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.root = fresh.root
+	s.entries = fresh.entries
+	s.byMovie = fresh.byMovie
+}
+
+// AddMovie indexes a movie, replacing what was indexed for it before
+func (s *Suggester) AddMovie(movie db.Movies) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeMovie(movie.Id)
+	s.addMovie(movie)
+}
+
+// RemoveMovie drops a movie from the index
+func (s *Suggester) RemoveMovie(id primitive.ObjectID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeMovie(id)
+}
+
+func (s *Suggester) addMovie(movie db.Movies) {
+	if movie.Title != "" {
+		s.link(movie.Id, KindTitle, "title:"+movie.Id.Hex(), movie.Title)
+	}
+	if movie.OriginalTitle != "" && movie.OriginalTitle != movie.Title {
+		s.link(movie.Id, KindTitle, "title:"+movie.Id.Hex()+":original", movie.OriginalTitle)
+	}
+	for _, title := range movie.Titles {
+		if title.Title != movie.Title {
+			s.link(movie.Id, KindTitle, "title:"+movie.Id.Hex()+":"+title.Locale, title.Title)
+		}
+	}
+	for _, name := range movie.Cast {
+		s.link(movie.Id, KindPerson, "person:"+Normalize(name), name)
+	}
+	for _, name := range movie.Directors {
+		s.link(movie.Id, KindPerson, "person:"+Normalize(name), name)
+	}
+	for _, genre := range movie.Genres {
+		s.link(movie.Id, KindGenre, "genre:"+Normalize(genre), genre)
+	}
+}
+
+func (s *Suggester) link(movieID primitive.ObjectID, kind Kind, key, text string) {
+	e, ok := s.entries[key]
+	if !ok {
+		words := Words(text)
+		if len(words) == 0 {
+			return
+		}
+		e = &entry{
+			key:    key,
+			kind:   kind,
+			text:   text,
+			norm:   strings.Join(words, " "),
+			words:  words,
+			movies: map[primitive.ObjectID]struct{}{},
+		}
+		s.entries[key] = e
+		for _, word := range uniqueWords(words) {
+			s.insert(word, e)
+		}
+	}
+	if _, ok := e.movies[movieID]; ok {
+		return
+	}
+	e.movies[movieID] = struct{}{}
+	s.byMovie[movieID] = append(s.byMovie[movieID], e)
+}
+
+func (s *Suggester) removeMovie(id primitive.ObjectID) {
+	for _, e := range s.byMovie[id] {
+		delete(e.movies, id)
+		if len(e.movies) > 0 {
+			continue
+		}
+		delete(s.entries, e.key)
+		for _, word := range uniqueWords(e.words) {
+			s.delete(s.root, []rune(word), e)
+		}
+	}
+	delete(s.byMovie, id)
+}
+
+func (s *Suggester) insert(word string, e *entry) {
+	node := s.root
+	for _, r := range word {
+		child, ok := node.children[r]
+		if !ok {
+			child = newTrieNode()
+			node.children[r] = child
+		}
+		node = child
+	}
+	if node.entries == nil {
+		node.entries = map[*entry]struct{}{}
+	}
+	node.entries[e] = struct{}{}
+}
+
+// delete removes e from the node at the end of word and prunes
+// the branches that became empty, it reports whether node is now empty
+func (s *Suggester) delete(node *trieNode, word []rune, e *entry) bool {
+	if len(word) == 0 {
+		delete(node.entries, e)
+	} else if child, ok := node.children[word[0]]; ok {
+		if s.delete(child, word[1:], e) {
+			delete(node.children, word[0])
+		}
+	}
+	return len(node.entries) == 0 && len(node.children) == 0
+}
+
+// Suggest returns up to limit suggestions for what the user has typed so far.
+// The last word of the query is treated as a prefix, the words before it
+// must match a whole word of the suggestion, both within a small edit distance
+func (s *Suggester) Suggest(query string, limit int) []Suggestion {
+	words := Words(query)
+	if len(words) == 0 || limit <= 0 {
+		return []Suggestion{}
+	}
+	last, rest := words[len(words)-1], words[:len(words)-1]
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	found := newCandidates(rest, strings.Join(words, " "), limit)
+	s.matchPrefix(last, fuzziness(last), found)
+
+	candidates := found.heap
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].better(candidates[j])
+	})
+	suggestions := make([]Suggestion, 0, len(candidates))
+	for _, c := range candidates {
+		suggestion := Suggestion{
+			Text:   c.entry.text,
+			Kind:   c.entry.kind,
+			Movies: len(c.entry.movies),
+		}
+		if c.entry.kind == KindTitle {
+			for id := range c.entry.movies {
+				suggestion.MovieID = id.Hex()
+			}
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions
+}
+
+// matchPrefix ranks into found every entry having a word that starts with something
+// within maxDist edits of prefix, together with the smallest such distance
+func (s *Suggester) matchPrefix(prefix string, maxDist int, found *candidates) {
+	q := []rune(prefix)
+	row := make([]int, len(q)+1)
+	for i := range row {
+		row[i] = i
+	}
+	for r, child := range s.root.children {
+		walk(child, r, q, row, len(q), maxDist, found)
+	}
+}
+
+// walk advances the Levenshtein row by one trie edge. best is the smallest
+// distance between the query and any prefix on the current path
+func walk(node *trieNode, r rune, q []rune, prev []int, best, maxDist int, found *candidates) {
+	row := make([]int, len(q)+1)
+	row[0] = prev[0] + 1
+	minRow := row[0]
+	for i := 1; i <= len(q); i++ {
+		cost := 1
+		if q[i-1] == r {
+			cost = 0
+		}
+		row[i] = min3(row[i-1]+1, prev[i]+1, prev[i-1]+cost)
+		if row[i] < minRow {
+			minRow = row[i]
+		}
+	}
+	if row[len(q)] < best {
+		best = row[len(q)]
+	}
+
+	if minRow > maxDist {
+		// Nothing deeper can get closer, but if the path already matched,
+		// every word below it shares that matching prefix
+		if best <= maxDist {
+			collect(node, best, found)
+		}
+		return
+	}
+	if best <= maxDist {
+		addEntries(node, best, found)
+	}
+	for r, child := range node.children {
+		walk(child, r, q, row, best, maxDist, found)
+	}
+}
+
+func collect(node *trieNode, dist int, found *candidates) {
+	addEntries(node, dist, found)
+	for _, child := range node.children {
+		collect(child, dist, found)
+	}
+}
+
+func addEntries(node *trieNode, dist int, found *candidates) {
+	for e := range node.entries {
+		found.add(e, dist)
+	}
+}
+
+// candidate is an entry that matches the query, dist is the edit distance
+// of the prefix and words the summed distance of the words before it
+type candidate struct {
+	entry  *entry
+	dist   int
+	words  int
+	prefix bool
+	index  int
+}
+
+// better tells whether c ranks before o in the suggestions
+func (c *candidate) better(o *candidate) bool {
+	if c.dist+c.words != o.dist+o.words {
+		return c.dist+c.words < o.dist+o.words
+	}
+	if c.prefix != o.prefix {
+		return c.prefix
+	}
+	if len(c.entry.movies) != len(o.entry.movies) {
+		return len(c.entry.movies) > len(o.entry.movies)
+	}
+	if len(c.entry.text) != len(o.entry.text) {
+		return len(c.entry.text) < len(o.entry.text)
+	}
+	return c.entry.key < o.entry.key
+}
+
+// candidates keeps the best limit candidates a lookup has met so far,
+// ranked as they are collected so that the walk can visit any number of
+// entries. The heap has the worst of them on top, to be dropped first
+type candidates struct {
+	rest  []string
+	query string
+	limit int
+	heap  candidateHeap
+	found map[*entry]*candidate
+}
+
+func newCandidates(rest []string, query string, limit int) *candidates {
+	return &candidates{
+		rest:  rest,
+		query: query,
+		limit: limit,
+		found: map[*entry]*candidate{},
+	}
+}
+
+// add ranks e, which the prefix matches within dist edits
+func (cs *candidates) add(e *entry, dist int) {
+	if c, ok := cs.found[e]; ok {
+		if dist < c.dist {
+			c.dist = dist
+			heap.Fix(&cs.heap, c.index)
+		}
+		return
+	}
+	total, ok := matchWords(cs.rest, e.words)
+	if !ok {
+		return
+	}
+	c := &candidate{entry: e, dist: dist, words: total, prefix: strings.HasPrefix(e.norm, cs.query)}
+	if len(cs.heap) < cs.limit {
+		heap.Push(&cs.heap, c)
+	} else if c.better(cs.heap[0]) {
+		delete(cs.found, cs.heap[0].entry)
+		c.index = 0
+		cs.heap[0] = c
+		heap.Fix(&cs.heap, 0)
+	} else {
+		return
+	}
+	cs.found[e] = c
+}
+
+// candidateHeap is a heap.Interface with the worst candidate on top
+type candidateHeap []*candidate
+
+func (h candidateHeap) Len() int           { return len(h) }
+func (h candidateHeap) Less(i, j int) bool { return h[j].better(h[i]) }
+
+func (h candidateHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *candidateHeap) Push(x interface{}) {
+	c := x.(*candidate)
+	c.index = len(*h)
+	*h = append(*h, c)
+}
+
+func (h *candidateHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// matchWords checks that every query word matches some whole word of the
+// entry, it returns the summed edit distance of the best matches
+func matchWords(query, words []string) (int, bool) {
+	total := 0
+	for _, q := range query {
+		best := -1
+		for _, w := range words {
+			d := Levenshtein(q, w)
+			if d <= fuzziness(q) && (best < 0 || d < best) {
+				best = d
+			}
+		}
+		if best < 0 {
+			return 0, false
+		}
+		total += best
+	}
+	return total, true
+}
+
+// fuzziness is the number of typos tolerated for a word, short words
+// have to match exactly or they would match nearly everything
+func fuzziness(word string) int {
+	n := len([]rune(word))
+	switch {
+	case n < 3:
+		return 0
+	case n < 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// Levenshtein returns the edit distance between a and b
+func Levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	row := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		row[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			row[j] = min3(row[j-1]+1, prev[j]+1, prev[j-1]+cost)
+		}
+		prev, row = row, prev
+	}
+	return prev[len(rb)]
+}
+
+func uniqueWords(words []string) []string {
+	seen := map[string]struct{}{}
+	var unique []string
+	for _, word := range words {
+		if _, ok := seen[word]; ok {
+			continue
+		}
+		seen[word] = struct{}{}
+		unique = append(unique, word)
+	}
+	return unique
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,160 @@
+package search
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	db "phantom/db/mongo"
+)
+
+func testMovies() []db.Movies {
+	return []db.Movies{
+		{
+			Id:        primitive.NewObjectID(),
+			Title:     "Interstellar",
+			Genres:    []string{"Adventure", "Drama", "Sci-Fi"},
+			Cast:      []string{"Matthew McConaughey", "Anne Hathaway"},
+			Directors: []string{"Christopher Nolan"},
+		},
+		{
+			Id:        primitive.NewObjectID(),
+			Title:     "Inception",
+			Genres:    []string{"Action", "Sci-Fi"},
+			Cast:      []string{"Leonardo DiCaprio"},
+			Directors: []string{"Christopher Nolan"},
+		},
+		{
+			Id:     primitive.NewObjectID(),
+			Title:  "Amélie",
+			Genres: []string{"Comedy", "Romance"},
+			Cast:   []string{"Audrey Tautou"},
+		},
+	}
+}
+
+func suggestionTexts(suggestions []Suggestion) []string {
+	var texts []string
+	for _, suggestion := range suggestions {
+		texts = append(texts, suggestion.Text)
+	}
+	return texts
+}
+
+func TestSuggestPrefix(t *testing.T) {
+	s := NewSuggester()
+	movies := testMovies()
+	s.Rebuild(movies)
+
+	suggestions := s.Suggest("inter", 10)
+	require.NotEmpty(t, suggestions)
+	require.Equal(t, "Interstellar", suggestions[0].Text)
+	require.Equal(t, KindTitle, suggestions[0].Kind)
+	require.Equal(t, movies[0].Id.Hex(), suggestions[0].MovieID)
+}
+
+func TestSuggestTypo(t *testing.T) {
+	s := NewSuggester()
+	s.Rebuild(testMovies())
+
+	require.Contains(t, suggestionTexts(s.Suggest("intersteller", 10)), "Interstellar")
+	require.Contains(t, suggestionTexts(s.Suggest("cristopher nol", 10)), "Christopher Nolan")
+	require.Contains(t, suggestionTexts(s.Suggest("amelie", 10)), "Amélie")
+}
+
+func TestSuggestKinds(t *testing.T) {
+	s := NewSuggester()
+	s.Rebuild(testMovies())
+
+	suggestions := s.Suggest("nolan", 10)
+	require.Len(t, suggestions, 1)
+	require.Equal(t, KindPerson, suggestions[0].Kind)
+	require.Equal(t, 2, suggestions[0].Movies)
+	require.Empty(t, suggestions[0].MovieID)
+
+	suggestions = s.Suggest("sci", 10)
+	require.Contains(t, suggestionTexts(suggestions), "Sci-Fi")
+}
+
+func TestSuggestShortQueryIsExact(t *testing.T) {
+	s := NewSuggester()
+	s.Rebuild(testMovies())
+
+	for _, suggestion := range s.Suggest("ax", 10) {
+		require.Fail(t, "unexpected suggestion", suggestion.Text)
+	}
+}
+
+func TestSuggestLimit(t *testing.T) {
+	s := NewSuggester()
+	s.Rebuild(testMovies())
+
+	require.Len(t, s.Suggest("a", 2), 2)
+	require.Empty(t, s.Suggest("", 10))
+	require.Empty(t, s.Suggest("a", 0))
+}
+
+func TestSuggestRanksEveryMatch(t *testing.T) {
+	s := NewSuggester()
+	var movies []db.Movies
+	for i := 0; i < 1000; i++ {
+		movies = append(movies, db.Movies{Id: primitive.NewObjectID(), Title: fmt.Sprintf("Star %d", i)})
+	}
+	// The genre every movie has ranks first however many titles match
+	for i := range movies {
+		movies[i].Genres = []string{"Stage"}
+	}
+	s.Rebuild(movies)
+
+	suggestions := s.Suggest("st", 5)
+	require.Len(t, suggestions, 5)
+	require.Equal(t, "Stage", suggestions[0].Text)
+	require.Equal(t, len(movies), suggestions[0].Movies)
+	require.Equal(t, suggestions, s.Suggest("st", 5))
+}
+
+func TestSuggestAddAndRemoveMovie(t *testing.T) {
+	s := NewSuggester()
+	movies := testMovies()
+	s.Rebuild(movies)
+
+	movie := movies[0]
+	movie.Title = "Tenet"
+	s.AddMovie(movie)
+	require.Empty(t, s.Suggest("interstellar", 10))
+	require.Equal(t, "Tenet", s.Suggest("tene", 10)[0].Text)
+
+	s.RemoveMovie(movie.Id)
+	require.Empty(t, s.Suggest("tenet", 10))
+	require.NotContains(t, suggestionTexts(s.Suggest("anne", 10)), "Anne Hathaway")
+
+	suggestions := s.Suggest("nolan", 10)
+	require.Len(t, suggestions, 1)
+	require.Equal(t, 1, suggestions[0].Movies)
+}
+
+func TestSuggestLocalizedTitles(t *testing.T) {
+	s := NewSuggester()
+	movie := db.Movies{
+		Id:            primitive.NewObjectID(),
+		Title:         "Spirited Away",
+		OriginalTitle: "Sen to Chihiro no Kamikakushi",
+		Titles:        []db.LocalizedTitle{{Locale: "fr", Title: "Le Voyage de Chihiro"}},
+	}
+	s.AddMovie(movie)
+
+	suggestions := s.Suggest("chihi", 10)
+	require.ElementsMatch(t, []string{"Sen to Chihiro no Kamikakushi", "Le Voyage de Chihiro"}, suggestionTexts(suggestions))
+	require.Equal(t, movie.Id.Hex(), suggestions[0].MovieID)
+
+	s.RemoveMovie(movie.Id)
+	require.Empty(t, s.Suggest("chihiro", 10))
+}
+
+func TestLevenshtein(t *testing.T) {
+	require.Equal(t, 0, Levenshtein("kitten", "kitten"))
+	require.Equal(t, 3, Levenshtein("kitten", "sitting"))
+	require.Equal(t, 2, Levenshtein("星际穿越", "星际"))
+	require.Equal(t, 5, Levenshtein("", "nolan"))
+}
@@ -2,6 +2,13 @@
 
 set -e
 
+# The environment overrides app.env, as it does for the app
+MONGO_DRIVE="${MONGO_DRIVE:-$(sed -n 's/^MONGO_DRIVE=//p' /app/app.env)}"
+if [ "${MONGO_DRIVE:-mongo}" = "mongo" ]; then
+    echo "run db migration"
+    /app/main migrate up
+fi
+
 echo "start the app"
 
 exec "$@"
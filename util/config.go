@@ -7,11 +7,36 @@ import (
 
 // The values are read by viper from a config file or environment variabiles.
 type Config struct {
+	// MongoDriver is the backend of the store: "mongo", the default, "sqlite",
+	// which keeps everything in SQLiteFile, or "memory", which keeps everything
+	// in the process until it exits, for demos and tests
 	MongoDriver         string        `mapstructure:"MONGO_DRIVE"`
 	MongoSource         string        `mapstructure:"MONGO_SOURCE"`
+	SQLiteFile          string        `mapstructure:"SQLITE_FILE"`
 	ServerAddress       string        `mapstructure:"SERVER_ADDRESS"`
 	TokenSymmetricKey   string        `mapstructure:"TOKEN_SYMMETRIC_KEY"`
 	AccessTokenDuration time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
+	// SearchBackend is "embedded", the default, an in-process index that
+	// segments Chinese and matches pinyin, or "mongo", the $text index,
+	// which only splits words on spaces
+	SearchBackend string `mapstructure:"SEARCH_BACKEND"`
+	// WatchedThreshold is the share of a movie or an episode, from 0 to 1,
+	// a user has to get past for it to count as watched
+	WatchedThreshold float64 `mapstructure:"WATCHED_THRESHOLD"`
+	// ReportThreshold is how many reports hide a comment until a moderator reviews it
+	ReportThreshold int64 `mapstructure:"REPORT_THRESHOLD"`
+	// BlocklistDir holds the blocklists of the comment filter, one *.txt file
+	// per language. The lists bundled with the server are used when it is empty
+	BlocklistDir string `mapstructure:"BLOCKLIST_DIR"`
+	// BackupDir is where 'phantom backup' and the scheduled backups write
+	// the archives of MongoDB, a backup is written every BackupInterval
+	// when it isn't 0, and the latest BackupKeep archives are kept
+	BackupDir      string        `mapstructure:"BACKUP_DIR"`
+	BackupInterval time.Duration `mapstructure:"BACKUP_INTERVAL"`
+	BackupKeep     int           `mapstructure:"BACKUP_KEEP"`
+	// LedgerCheckpointInterval is how often the latest entries of the ledger
+	// are checkpointed, so that they can be proved, a minute when it is 0
+	LedgerCheckpointInterval time.Duration `mapstructure:"LEDGER_CHECKPOINT_INTERVAL"`
 }
 
 // LoadConfig reads configuration from file or environment variable
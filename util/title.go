@@ -0,0 +1,18 @@
+package util
+
+import "strings"
+
+// leadingArticles are dropped from the front of a sort title
+var leadingArticles = []string{"the ", "a ", "an "}
+
+// SortTitle makes the title used to sort a movie, lowercased and without
+// a leading article, so that "The Matrix" sorts under M
+func SortTitle(title string) string {
+	sortTitle := strings.ToLower(strings.TrimSpace(title))
+	for _, article := range leadingArticles {
+		if strings.HasPrefix(sortTitle, article) && len(sortTitle) > len(article) {
+			return strings.TrimSpace(sortTitle[len(article):])
+		}
+	}
+	return sortTitle
+}
@@ -0,0 +1,15 @@
+package util
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSortTitle(t *testing.T) {
+	require.Equal(t, "matrix", SortTitle("The Matrix"))
+	require.Equal(t, "beautiful mind", SortTitle("A Beautiful Mind"))
+	require.Equal(t, "american in paris", SortTitle("An American in Paris"))
+	require.Equal(t, "theory of everything", SortTitle("Theory of Everything"))
+	require.Equal(t, "a", SortTitle("A"))
+	require.Equal(t, "星际穿越", SortTitle("星际穿越"))
+}